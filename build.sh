@@ -1,6 +1,7 @@
 #!/bin/bash
 go build -o $OUTPUT/test_crypto github.com/xuperchain/crypto/test
 go build -o $OUTPUT/test_gm_crypto github.com/xuperchain/crypto/test/gm
+go build -o $OUTPUT/gmcli github.com/xuperchain/crypto/cmd/gmcli
 
 echo 'start build plugins'
 go build --buildmode=plugin -o $OUTPUT/crypto-default.so.1.0.0 github.com/xuperchain/crypto/client/xchain/
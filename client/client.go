@@ -0,0 +1,127 @@
+// Package client defines algorithm-neutral interfaces to the primitives most
+// applications need: key pair generation, signing, encryption, hashing and
+// key agreement. An application written against CryptoClient picks its
+// suite by name, e.g. from its configuration, with New:
+//
+//	c, err := client.New(conf.CryptoSuite) // "gm"
+//	priv, err := c.GenerateKey()
+//	sig, err := c.Sign(priv, msg)
+//
+// The GM suite of SM2, SM3 and SM4 is registered as "gm". Other suites are
+// added with Register.
+package client
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrKeyType is returned when a key does not belong to the suite.
+	ErrKeyType = errors.New("client: key type not supported by the suite")
+	// ErrCiphertext is returned when a symmetric ciphertext is malformed or
+	// fails authentication.
+	ErrCiphertext = errors.New("client: invalid ciphertext")
+)
+
+// KeyPairGen generates key pairs.
+type KeyPairGen interface {
+	// GenerateKey returns a new private key; its public key is Public().
+	GenerateKey() (crypto.Signer, error)
+}
+
+// SignVerifier signs messages and verifies signatures. Messages are hashed
+// by the suite, as its signature scheme prescribes.
+type SignVerifier interface {
+	// Sign signs msg with priv, which may be any crypto.Signer of the
+	// suite, including keys held by hardware.
+	Sign(priv crypto.Signer, msg []byte) ([]byte, error)
+	// Verify reports whether sig is a valid signature of msg by pub. The
+	// error is only set for keys of the wrong type.
+	Verify(pub crypto.PublicKey, msg, sig []byte) (bool, error)
+}
+
+// EncryptDecrypter encrypts with public keys and with symmetric keys.
+type EncryptDecrypter interface {
+	// Encrypt encrypts msg to pub.
+	Encrypt(pub crypto.PublicKey, msg []byte) ([]byte, error)
+	// Decrypt decrypts a ciphertext of Encrypt.
+	Decrypt(priv crypto.PrivateKey, ciphertext []byte) ([]byte, error)
+	// KeySize is the size of the symmetric keys of Seal and Open.
+	KeySize() int
+	// Seal encrypts and authenticates plaintext with a symmetric key of
+	// KeySize bytes, under a random nonce it prepends to the result.
+	Seal(key, plaintext []byte) ([]byte, error)
+	// Open decrypts a ciphertext of Seal. It returns ErrCiphertext if the
+	// ciphertext was tampered with.
+	Open(key, ciphertext []byte) ([]byte, error)
+}
+
+// Hasher hashes data.
+type Hasher interface {
+	// Hash returns the digest of data.
+	Hash(data []byte) []byte
+	// NewHash returns a hash.Hash computing the same digest.
+	NewHash() hash.Hash
+}
+
+// KeyAgreement derives keys shared by two key pairs.
+type KeyAgreement interface {
+	// SharedKey derives a key of length bytes from the Diffie-Hellman
+	// secret of priv and peer. Both sides obtain the same key.
+	SharedKey(priv crypto.PrivateKey, peer crypto.PublicKey, length int) ([]byte, error)
+}
+
+// CryptoClient is a complete suite of algorithms.
+type CryptoClient interface {
+	// Name returns the name the suite is registered under.
+	Name() string
+	KeyPairGen
+	SignVerifier
+	EncryptDecrypter
+	Hasher
+	KeyAgreement
+}
+
+var (
+	mu     sync.RWMutex
+	suites = make(map[string]CryptoClient)
+)
+
+// Register makes a suite available by its name. It panics if the name is
+// already taken.
+func Register(c CryptoClient) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := suites[c.Name()]; dup {
+		panic("client: Register called twice for suite " + c.Name())
+	}
+	suites[c.Name()] = c
+}
+
+// New returns the suite registered under name.
+func New(name string) (CryptoClient, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := suites[name]
+	if !ok {
+		return nil, fmt.Errorf("client: unknown crypto suite %q", name)
+	}
+	return c, nil
+}
+
+// Suites returns the names of the registered suites, sorted.
+func Suites() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(suites))
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// testSuite is the GM suite under another name.
+type testSuite struct{ GM }
+
+func (testSuite) Name() string { return "test" }
+
+func TestRegistry(t *testing.T) {
+	c, err := New("gm")
+	if err != nil || c != CryptoClient(GM{}) {
+		t.Fatalf("New(gm) = %v, %v", c, err)
+	}
+	if _, err := New("nist"); err == nil {
+		t.Fatal("New returned an unregistered suite")
+	}
+
+	Register(testSuite{})
+	defer func() {
+		mu.Lock()
+		delete(suites, "test")
+		mu.Unlock()
+	}()
+	if got := Suites(); !reflect.DeepEqual(got, []string{"gm", "test"}) {
+		t.Fatalf("Suites() = %v", got)
+	}
+	if c, err := New("test"); err != nil || c.Name() != "test" {
+		t.Fatalf("New(test) = %v, %v", c, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a name twice did not panic")
+		}
+	}()
+	Register(GM{})
+}
+
+func generateKey(t *testing.T) *sm2.PrivateKey {
+	priv, err := GM{}.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv.(*sm2.PrivateKey)
+}
+
+func TestSignVerify(t *testing.T) {
+	c := GM{}
+	priv := generateKey(t)
+	msg := []byte("hello")
+	sig, err := c.Sign(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Verify(&priv.PublicKey, msg, sig); !ok || err != nil {
+		t.Fatalf("Verify = %v, %v", ok, err)
+	}
+	// Signatures are plain SM2 signatures under the default user ID.
+	r, s, err := sm2.SignDataToSignDigit(sig)
+	if err != nil || !sm2.Sm2Verify(&priv.PublicKey, msg, sm2.DefaultUID, r, s) {
+		t.Fatal("signature does not verify with Sm2Verify")
+	}
+
+	if ok, _ := c.Verify(&priv.PublicKey, []byte("hellO"), sig); ok {
+		t.Fatal("signature verifies for another message")
+	}
+	if ok, _ := c.Verify(&generateKey(t).PublicKey, msg, sig); ok {
+		t.Fatal("signature verifies under another key")
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := c.Sign(ecKey, msg); err != ErrKeyType {
+		t.Fatalf("ECDSA key: got %v", err)
+	}
+	if _, err := c.Sign((*sm2.PrivateKey)(nil), msg); err != ErrKeyType {
+		t.Fatalf("nil key: got %v", err)
+	}
+	if _, err := c.Verify(&ecKey.PublicKey, msg, sig); err != ErrKeyType {
+		t.Fatalf("ECDSA public key: got %v", err)
+	}
+	if _, err := c.Verify((*sm2.PublicKey)(nil), msg, sig); err != ErrKeyType {
+		t.Fatalf("nil public key: got %v", err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := GM{}
+	priv := generateKey(t)
+	msg := []byte("attack at dawn")
+	ciphertext, err := c.Encrypt(&priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decrypt(priv, ciphertext)
+	if err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := c.Decrypt(generateKey(t), ciphertext); err == nil {
+		t.Fatal("decrypted with another key")
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := c.Encrypt(&ecKey.PublicKey, msg); err != ErrKeyType {
+		t.Fatalf("ECDSA public key: got %v", err)
+	}
+	for _, key := range []interface{}{nil, ecKey, (*sm2.PrivateKey)(nil)} {
+		if _, err := c.Decrypt(key, ciphertext); err != ErrKeyType {
+			t.Fatalf("Decrypt with %T: got %v", key, err)
+		}
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	c := GM{}
+	key := make([]byte, c.KeySize())
+	rand.Read(key)
+	plaintext := []byte("attack at dawn")
+	ciphertext, err := c.Seal(key, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ciphertext) != 12+len(plaintext)+16 {
+		t.Fatalf("ciphertext of %d bytes", len(ciphertext))
+	}
+	got, err := c.Open(key, ciphertext)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+	if again, _ := c.Seal(key, plaintext); bytes.Equal(again, ciphertext) {
+		t.Fatal("Seal reused a nonce")
+	}
+
+	for i := 0; i < len(ciphertext); i++ {
+		if _, err := c.Open(key, ciphertext[:i]); err != ErrCiphertext {
+			t.Fatalf("truncated to %d bytes: got %v", i, err)
+		}
+		tampered := append([]byte(nil), ciphertext...)
+		tampered[i] ^= 1
+		if _, err := c.Open(key, tampered); err != ErrCiphertext {
+			t.Fatalf("byte %d flipped: got %v", i, err)
+		}
+	}
+	otherKey := make([]byte, c.KeySize())
+	if _, err := c.Open(otherKey, ciphertext); err != ErrCiphertext {
+		t.Fatalf("wrong key: got %v", err)
+	}
+	if _, err := c.Seal(key[:8], plaintext); err == nil {
+		t.Fatal("Seal accepted a short key")
+	}
+}
+
+func TestHash(t *testing.T) {
+	c := GM{}
+	// The example of GB/T 32905-2016.
+	const want = "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"
+	if got := hex.EncodeToString(c.Hash([]byte("abc"))); got != want {
+		t.Fatalf("Hash = %s", got)
+	}
+	h := c.NewHash()
+	h.Write([]byte("ab"))
+	h.Write([]byte("c"))
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		t.Fatalf("NewHash = %s", got)
+	}
+}
+
+func TestSharedKey(t *testing.T) {
+	c := GM{}
+	a, b := generateKey(t), generateKey(t)
+	ab, err := c.SharedKey(a, &b.PublicKey, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ba, err := c.SharedKey(b, &a.PublicKey, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ab) != 32 || !bytes.Equal(ab, ba) {
+		t.Fatalf("SharedKey is not symmetric: %x, %x", ab, ba)
+	}
+	if ac, _ := c.SharedKey(a, &generateKey(t).PublicKey, 32); bytes.Equal(ac, ab) {
+		t.Fatal("same key with another peer")
+	}
+	// The KDF output is a stream, so shorter keys are prefixes.
+	if short, _ := c.SharedKey(a, &b.PublicKey, 16); !bytes.Equal(short, ab[:16]) {
+		t.Fatal("16-byte key is not a prefix of the 32-byte key")
+	}
+
+	if _, err := c.SharedKey((*sm2.PrivateKey)(nil), &b.PublicKey, 32); err != ErrKeyType {
+		t.Fatalf("nil key: got %v", err)
+	}
+	if _, err := c.SharedKey(a, (*sm2.PublicKey)(nil), 32); err != ErrKeyType {
+		t.Fatalf("nil peer: got %v", err)
+	}
+}
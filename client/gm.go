@@ -0,0 +1,185 @@
+package client
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/rand"
+	"hash"
+	"io"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3kdf"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+func init() {
+	Register(GM{})
+}
+
+// GM is the suite of the Chinese national standards, registered as "gm":
+//
+//   - SM2 key pairs, signatures under sm2.DefaultUID, and public key
+//     encryption in the C1C3C2 format;
+//   - SM4-GCM with a 12-byte random nonce for symmetric encryption;
+//   - SM3 for hashing;
+//   - SM2 Diffie-Hellman with the SM3 KDF of GB/T 32918.4 for key
+//     agreement.
+//
+// Private keys are *sm2.PrivateKey or, for keys held by hardware, any
+// sm2.OpaqueSigner; public keys are *sm2.PublicKey.
+type GM struct{}
+
+var _ CryptoClient = GM{}
+
+// Name returns "gm".
+func (GM) Name() string {
+	return "gm"
+}
+
+// GenerateKey returns a new *sm2.PrivateKey.
+func (GM) GenerateKey() (crypto.Signer, error) {
+	return sm2.GenerateKey()
+}
+
+func sm2Public(pub crypto.PublicKey) (*sm2.PublicKey, error) {
+	if pub, ok := pub.(*sm2.PublicKey); ok && pub != nil {
+		return pub, nil
+	}
+	return nil, ErrKeyType
+}
+
+// nilPrivateKey reports whether priv is a nil *sm2.PrivateKey. Stored in an
+// interface it is not nil, and it satisfies sm2.OpaqueSigner.
+func nilPrivateKey(priv crypto.PrivateKey) bool {
+	key, ok := priv.(*sm2.PrivateKey)
+	return ok && key == nil
+}
+
+func sm2Private(priv crypto.PrivateKey) (sm2.OpaqueSigner, error) {
+	if nilPrivateKey(priv) {
+		return nil, ErrKeyType
+	}
+	if priv, ok := priv.(sm2.OpaqueSigner); ok {
+		return priv, nil
+	}
+	return nil, ErrKeyType
+}
+
+// Sign signs e = SM3(ZA || msg), with ZA of sm2.DefaultUID, and returns the
+// ASN.1 signature.
+func (GM) Sign(priv crypto.Signer, msg []byte) ([]byte, error) {
+	if priv == nil || nilPrivateKey(priv) {
+		return nil, ErrKeyType
+	}
+	pub, err := sm2Public(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+	za, err := sm2.ZA(pub, nil)
+	if err != nil {
+		return nil, err
+	}
+	h := sm3.New()
+	h.Write(za)
+	h.Write(msg)
+	return priv.Sign(nil, h.Sum(nil), sm3.CryptoHash)
+}
+
+// Verify verifies an ASN.1 signature of Sign.
+func (GM) Verify(pub crypto.PublicKey, msg, sig []byte) (bool, error) {
+	key, err := sm2Public(pub)
+	if err != nil {
+		return false, err
+	}
+	return sm2.VerifyEx(key, msg, sig), nil
+}
+
+// Encrypt encrypts msg to the SM2 key pub.
+func (GM) Encrypt(pub crypto.PublicKey, msg []byte) ([]byte, error) {
+	key, err := sm2Public(pub)
+	if err != nil {
+		return nil, err
+	}
+	return sm2.Encrypt(key, msg)
+}
+
+// Decrypt decrypts a ciphertext of Encrypt.
+func (GM) Decrypt(priv crypto.PrivateKey, ciphertext []byte) ([]byte, error) {
+	key, err := sm2Private(priv)
+	if err != nil {
+		return nil, err
+	}
+	return key.Decrypt(ciphertext)
+}
+
+// KeySize returns the SM4 key size, 16.
+func (GM) KeySize() int {
+	return sm4.KeySize
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext with SM4-GCM and returns nonce || ciphertext.
+func (GM) Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts a ciphertext of Seal.
+func (GM) Open(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrCiphertext
+	}
+	n := aead.NonceSize()
+	plaintext, err := aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+	if err != nil {
+		return nil, ErrCiphertext
+	}
+	return plaintext, nil
+}
+
+// Hash returns the SM3 digest of data.
+func (GM) Hash(data []byte) []byte {
+	return sm3.Sm3Sum(data)
+}
+
+// NewHash returns a new SM3 hash.
+func (GM) NewHash() hash.Hash {
+	return sm3.New()
+}
+
+// SharedKey derives length bytes with the SM3 KDF from the x-coordinate of
+// d·peer. It has no key confirmation; use it with authenticated peer keys.
+func (GM) SharedKey(priv crypto.PrivateKey, peer crypto.PublicKey, length int) ([]byte, error) {
+	key, err := sm2Private(priv)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := sm2Public(peer)
+	if err != nil {
+		return nil, err
+	}
+	z, err := key.SharedSecret(pub)
+	if err != nil {
+		return nil, err
+	}
+	return sm3kdf.Derive(z, length)
+}
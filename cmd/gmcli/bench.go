@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/xuperchain/crypto/gm/gmsm/diag"
+)
+
+func bench(args []string) error {
+	fs := newFlagSet("bench")
+	out := fs.String("out", "", "JSON report `file` (default stdout)")
+	duration := fs.Duration("duration", diag.DefaultDuration, "time spent on each benchmark")
+	only := fs.String("only", "", "comma-separated benchmarks to run, of "+strings.Join(diag.Benchmarks(), ", "))
+	info := fs.Bool("info", false, "only report the platform and backends")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	opts := &diag.Options{Duration: *duration, NoBenchmarks: *info}
+	if *only != "" {
+		opts.Benchmarks = splitList(*only)
+	}
+	r, err := diag.Report(opts)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, append(data, '\n'))
+}
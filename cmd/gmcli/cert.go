@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// parseSubject parses a distinguished name such as "CN=node1,O=Example,C=CN".
+// Values cannot contain commas.
+func parseSubject(s string) (pkix.Name, error) {
+	var name pkix.Name
+	if strings.TrimSpace(s) == "" {
+		return name, errors.New("empty subject")
+	}
+	for _, rdn := range strings.Split(s, ",") {
+		kv := strings.SplitN(rdn, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[1]) == "" {
+			return name, fmt.Errorf("malformed subject attribute %q", rdn)
+		}
+		v := strings.TrimSpace(kv[1])
+		switch strings.ToUpper(strings.TrimSpace(kv[0])) {
+		case "C":
+			name.Country = append(name.Country, v)
+		case "ST":
+			name.Province = append(name.Province, v)
+		case "L":
+			name.Locality = append(name.Locality, v)
+		case "O":
+			name.Organization = append(name.Organization, v)
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, v)
+		case "CN":
+			name.CommonName = v
+		default:
+			return name, fmt.Errorf("unsupported subject attribute %q", kv[0])
+		}
+	}
+	return name, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func req(args []string) error {
+	fs := newFlagSet("req")
+	keyFile := fs.String("key", "", "private key `file`")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	subj := fs.String("subj", "", "subject `name`, such as CN=node1,O=Example,C=CN")
+	dns := fs.String("dns", "", "comma-separated DNS `names`")
+	email := fs.String("email", "", "comma-separated email `addresses`")
+	ips := fs.String("ip", "", "comma-separated IP `addresses`")
+	out := fs.String("out", "", "request `file` (default stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	subject, err := parseSubject(*subj)
+	if err != nil {
+		return err
+	}
+	priv, err := loadPrivateKey(*keyFile, *pass)
+	if err != nil {
+		return err
+	}
+	template := &sm2.CertificateRequest{
+		Subject:            subject,
+		SignatureAlgorithm: sm2.SM2WithSM3,
+		DNSNames:           splitList(*dns),
+		EmailAddresses:     splitList(*email),
+	}
+	for _, s := range splitList(*ips) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %q", s)
+		}
+		template.IPAddresses = append(template.IPAddresses, ip)
+	}
+	csrPEM, err := sm2.CreateCertificateRequestToMem(template, priv)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, csrPEM)
+}
+
+// keyUsages are the values of -usage. GM deployments issue separate
+// signing and encryption certificates.
+var keyUsages = map[string]sm2.KeyUsage{
+	"sign": sm2.KeyUsageDigitalSignature | sm2.KeyUsageContentCommitment,
+	"enc":  sm2.KeyUsageKeyEncipherment | sm2.KeyUsageDataEncipherment | sm2.KeyUsageKeyAgreement,
+	"both": sm2.KeyUsageDigitalSignature | sm2.KeyUsageKeyEncipherment | sm2.KeyUsageDataEncipherment,
+}
+
+func cert(args []string) error {
+	fs := newFlagSet("cert")
+	csrFile := fs.String("csr", "", "certificate request `file`")
+	caFile := fs.String("ca", "", "issuer certificate `file`; without it the certificate is self-signed")
+	caKeyFile := fs.String("cakey", "", "private key `file` of the issuer, or of the request when self-signed")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	days := fs.Int("days", 365, "validity in `days`")
+	isCA := fs.Bool("isca", false, "issue a CA certificate")
+	pathLen := fs.Int("pathlen", -1, "maximum number of intermediate CAs below a CA certificate, -1 for no limit")
+	usage := fs.String("usage", "both", "key `usage` of an end-entity certificate: sign, enc or both")
+	out := fs.String("out", "", "certificate `file` (default stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return errors.New("-days must be positive")
+	}
+	ku, ok := keyUsages[*usage]
+	if !ok {
+		return fmt.Errorf("unknown key usage %q, want sign, enc or both", *usage)
+	}
+
+	if *csrFile == "" {
+		return errors.New("no certificate request given")
+	}
+	data, err := readInput(*csrFile)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return errors.New("request is not a PEM CERTIFICATE REQUEST")
+	}
+	csr, err := sm2.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("request signature: %v", err)
+	}
+	pub, err := certificateKey(csr.PublicKey)
+	if err != nil {
+		return err
+	}
+	signer, err := loadPrivateKey(*caKeyFile, *pass)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &sm2.Certificate{
+		SerialNumber:          serial,
+		Subject:               csr.Subject,
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.AddDate(0, 0, *days),
+		SignatureAlgorithm:    sm2.SM2WithSM3,
+		KeyUsage:              ku,
+		BasicConstraintsValid: true,
+		DNSNames:              csr.DNSNames,
+		EmailAddresses:        csr.EmailAddresses,
+		IPAddresses:           csr.IPAddresses,
+	}
+	if *isCA {
+		template.IsCA = true
+		template.KeyUsage = sm2.KeyUsageCertSign | sm2.KeyUsageCRLSign | sm2.KeyUsageDigitalSignature
+		if *pathLen >= 0 {
+			template.MaxPathLen = *pathLen
+			template.MaxPathLenZero = *pathLen == 0
+		} else {
+			template.MaxPathLen = -1
+		}
+	}
+
+	parent := template
+	if *caFile != "" {
+		data, err := readInput(*caFile)
+		if err != nil {
+			return err
+		}
+		if parent, err = sm2.ReadCertificateFromMem(data); err != nil {
+			return err
+		}
+		caPub, err := certificateKey(parent.PublicKey)
+		if err != nil {
+			return err
+		}
+		if caPub.X.Cmp(signer.X) != 0 || caPub.Y.Cmp(signer.Y) != 0 {
+			return errors.New("issuer key does not match the issuer certificate")
+		}
+	} else if pub.X.Cmp(signer.X) != 0 || pub.Y.Cmp(signer.Y) != 0 {
+		return errors.New("self-signed certificate: -cakey does not match the request")
+	}
+	certPEM, err := sm2.CreateCertificateToMem(template, parent, pub, signer)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, certPEM)
+}
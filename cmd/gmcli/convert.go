@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xuperchain/crypto/gm/gmsm/keyconv"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func convert(args []string) error {
+	fs := newFlagSet("convert")
+	in := fs.String("in", "", "input `file` (default stdin)")
+	out := fs.String("out", "", "output `file` (default stdout)")
+	from := fs.String("from", "pkcs8", "input `format`: "+formatList+", or pem or der for certificates and requests")
+	to := fs.String("to", "pkcs8", "output `format`, as for -from")
+	isPub := fs.Bool("pub", false, "convert a public key")
+	pass := fs.String("pass", "", "password `source` of an encrypted input key")
+	passout := fs.String("passout", "", "encrypt the output key with the password from `source`")
+	certsFile := fs.String("certs", "", "PEM certificates `file` to store with a pkcs12 key, its certificate first")
+	compressed := fs.Bool("compressed", false, "write hex and sec1 public keys compressed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	if isEncoding(*from) || isEncoding(*to) {
+		if !isEncoding(*from) || !isEncoding(*to) {
+			return errors.New("pem and der convert to each other only")
+		}
+		return convertEncoding(data, *from, *to, *out)
+	}
+
+	fromFormat, err := keyconv.ParseFormat(*from)
+	if err != nil {
+		return err
+	}
+	toFormat, err := keyconv.ParseFormat(*to)
+	if err != nil {
+		return err
+	}
+	pwd, err := readPassword(*pass)
+	if err != nil {
+		return err
+	}
+	pwdOut, err := readPassword(*passout)
+	if err != nil {
+		return err
+	}
+	opts := &keyconv.Options{Password: pwdOut, Compressed: *compressed}
+	if *certsFile != "" {
+		if opts.Certificates, err = loadCertificates(*certsFile); err != nil {
+			return err
+		}
+	}
+
+	var result []byte
+	if *isPub {
+		pub, err := keyconv.ParsePublicKey(data, fromFormat)
+		if err != nil {
+			return err
+		}
+		result, err = keyconv.MarshalPublicKey(pub, toFormat, opts)
+		if err != nil {
+			return err
+		}
+	} else {
+		priv, err := keyconv.ParsePrivateKey(data, fromFormat, &keyconv.Options{Password: pwd})
+		if err != nil {
+			return err
+		}
+		result, err = keyconv.MarshalPrivateKey(priv, toFormat, opts)
+		if err != nil {
+			return err
+		}
+	}
+	if toFormat == keyconv.FormatHex || toFormat == keyconv.FormatJWK || toFormat == keyconv.FormatJSON {
+		result = append(result, '\n')
+	}
+	return writeOutput(*out, result)
+}
+
+var formatList = func() string {
+	var names []string
+	for f := keyconv.FormatHex; f <= keyconv.FormatPKCS12; f++ {
+		names = append(names, f.String())
+	}
+	return strings.Join(names, ", ")
+}()
+
+func isEncoding(name string) bool {
+	return name == "pem" || name == "der"
+}
+
+// loadCertificates reads the PEM certificates of a file.
+func loadCertificates(name string) ([]*sm2.Certificate, error) {
+	data, err := readInput(name)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*sm2.Certificate
+	for {
+		var block *pem.Block
+		if block, data = pem.Decode(data); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := sm2.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates in %s", name)
+	}
+	return certs, nil
+}
+
+// convertEncoding converts certificates, requests and keys between PEM
+// and DER without changing them.
+func convertEncoding(data []byte, from, to, out string) error {
+	var block *pem.Block
+	if from == "pem" {
+		if block, _ = pem.Decode(data); block == nil {
+			return errors.New("input is not PEM")
+		}
+	} else {
+		var err error
+		if block, err = identifyDER(data); err != nil {
+			return err
+		}
+	}
+	if to == "der" {
+		return writeOutput(out, block.Bytes)
+	}
+	return writeOutput(out, pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes}))
+}
+
+// identifyDER returns a PEM block for DER data of a certificate, request,
+// public key or PKCS #8 private key. An encrypted private key is
+// recognized by not parsing as anything else.
+func identifyDER(der []byte) (*pem.Block, error) {
+	if _, err := sm2.ParseCertificate(der); err == nil {
+		return &pem.Block{Type: "CERTIFICATE", Bytes: der}, nil
+	}
+	if _, err := sm2.ParseCertificateRequest(der); err == nil {
+		return &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}, nil
+	}
+	if _, err := sm2.ParseSm2PublicKey(der); err == nil {
+		return &pem.Block{Type: "PUBLIC KEY", Bytes: der}, nil
+	}
+	if _, err := sm2.ParsePKCS8UnecryptedPrivateKey(der); err == nil {
+		return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+	}
+	if len(der) > 0 && der[0] == 0x30 {
+		return &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}, nil
+	}
+	return nil, errors.New("input is not a DER certificate, request or key")
+}
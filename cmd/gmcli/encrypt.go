@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// ciphertextModes are the values of -mode.
+var ciphertextModes = map[string]sm2.CiphertextMode{
+	"c1c3c2": sm2.C1C3C2,
+	"c1c2c3": sm2.C1C2C3,
+}
+
+func ciphertextMode(name string) (sm2.CiphertextMode, error) {
+	m, ok := ciphertextModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown ciphertext mode %q, want c1c3c2 or c1c2c3", name)
+	}
+	return m, nil
+}
+
+func encrypt(args []string) error {
+	fs := newFlagSet("encrypt")
+	pubFile := fs.String("pub", "", "public key or certificate `file` of the recipient")
+	in := fs.String("in", "", "plaintext `file` (default stdin)")
+	out := fs.String("out", "", "ciphertext `file` (default stdout)")
+	mode := fs.String("mode", "c1c3c2", "ciphertext `order`: c1c3c2 (GB/T 32918) or c1c2c3")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	m, err := ciphertextMode(*mode)
+	if err != nil {
+		return err
+	}
+	pub, err := loadPublicKey(*pubFile)
+	if err != nil {
+		return err
+	}
+	msg, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	ct, err := sm2.EncryptEx(pub, msg, sm2.WithCiphertextMode(m))
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, ct)
+}
+
+func decrypt(args []string) error {
+	fs := newFlagSet("decrypt")
+	keyFile := fs.String("key", "", "private key `file`")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	in := fs.String("in", "", "ciphertext `file` (default stdin)")
+	out := fs.String("out", "", "plaintext `file` (default stdout)")
+	mode := fs.String("mode", "c1c3c2", "ciphertext `order`: c1c3c2 (GB/T 32918) or c1c2c3")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	m, err := ciphertextMode(*mode)
+	if err != nil {
+		return err
+	}
+	priv, err := loadPrivateKey(*keyFile, *pass)
+	if err != nil {
+		return err
+	}
+	ct, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	msg, err := sm2.DecryptEx(priv, ct, sm2.WithCiphertextMode(m))
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, msg)
+}
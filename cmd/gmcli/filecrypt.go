@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2age"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4stream"
+)
+
+// copyBufferSize is the size of the reads of encrypt-file and
+// decrypt-file, a multiple of the chunk size of sm4stream.
+const copyBufferSize = 16 * sm4stream.ChunkSize
+
+// partSuffix is appended to the name of an output file while it is being
+// written. The file only gets its name once complete, so that an
+// interrupted or failed run never leaves an output that looks finished.
+const partSuffix = ".part"
+
+// errPartMismatch and errPartLong are returned when the part file of a
+// resumed decryption is not a prefix of the plaintext of the ciphertext.
+var (
+	errPartMismatch = errors.New("part file does not match the plaintext")
+	errPartLong     = errors.New("part file is longer than the plaintext")
+)
+
+func encryptFile(args []string) error {
+	fs := newFlagSet("encrypt-file")
+	pubFiles := fs.String("pub", "", "comma-separated public key or certificate `files` of the recipients")
+	in := fs.String("in", "", "plaintext `file` (default stdin)")
+	out := fs.String("out", "", "sm2age `file` (default stdout); written as file"+partSuffix+" until complete")
+	showProgress := fs.Bool("progress", false, "report progress on stderr")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var recipients []sm2age.Recipient
+	for _, name := range splitList(*pubFiles) {
+		pub, err := loadPublicKey(name)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		r, err := sm2age.NewSM2Recipient(pub)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 {
+		return errors.New("no recipients given; use -pub")
+	}
+
+	src, total, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := createOutput(*out, false)
+	if err != nil {
+		return err
+	}
+	p := newProgress("encrypt-file", src, total, *showProgress)
+
+	err = func() error {
+		w, err := sm2age.Encrypt(dst, recipients...)
+		if err != nil {
+			return err
+		}
+		if _, err := io.CopyBuffer(w, p, make([]byte, copyBufferSize)); err != nil {
+			return err
+		}
+		return w.Close()
+	}()
+	p.done()
+	if err != nil {
+		dst.abort(false)
+		return err
+	}
+	return dst.commit()
+}
+
+func decryptFile(args []string) error {
+	fs := newFlagSet("decrypt-file")
+	keyFile := fs.String("key", "", "private key `file` of a recipient")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	in := fs.String("in", "", "sm2age `file` (default stdin)")
+	out := fs.String("out", "", "plaintext `file` (default stdout); written as file"+partSuffix+" until complete")
+	resume := fs.Bool("resume", false, "continue an interrupted decryption into the "+partSuffix+" file of -out")
+	showProgress := fs.Bool("progress", false, "report progress on stderr")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *resume && (*out == "" || *out == "-") {
+		return errors.New("-resume needs an -out file")
+	}
+	priv, err := loadPrivateKey(*keyFile, *pass)
+	if err != nil {
+		return err
+	}
+	id, err := sm2age.NewSM2Identity(priv)
+	if err != nil {
+		return err
+	}
+
+	src, total, err := openInput(*in)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := createOutput(*out, *resume)
+	if err != nil {
+		return err
+	}
+	p := newProgress("decrypt-file", src, total, *showProgress)
+
+	err = func() error {
+		r, err := sm2age.Decrypt(p, id)
+		if err != nil {
+			return err
+		}
+		if err := dst.checkKept(r); err != nil {
+			return err
+		}
+		_, err = io.CopyBuffer(dst, r, make([]byte, copyBufferSize))
+		return err
+	}()
+	p.done()
+	if err != nil {
+		// What was written is authentic; it is kept for -resume when the
+		// ciphertext was cut short, as by an interrupted transfer, and a
+		// failed resumption leaves it as it found it.
+		dst.abort(*resume || err == sm4stream.ErrTruncated)
+		switch err {
+		case sm4stream.ErrTruncated:
+			return errors.New("ciphertext is truncated; the output is incomplete")
+		case sm4stream.ErrCorrupted:
+			return errors.New("ciphertext is corrupted or was modified; the output is incomplete")
+		case errPartMismatch:
+			return fmt.Errorf("%s%s was not decrypted from this ciphertext; remove it to start over", *out, partSuffix)
+		case errPartLong:
+			return fmt.Errorf("%s%s is longer than the plaintext; remove it to start over", *out, partSuffix)
+		}
+		return err
+	}
+	return dst.commit()
+}
+
+// openInput opens the file name, or stdin for "" or "-", and returns its
+// size, or -1 if it is not a regular file.
+func openInput(name string) (io.ReadCloser, int64, error) {
+	f := os.Stdin
+	if name != "" && name != "-" {
+		var err error
+		if f, err = os.Open(name); err != nil {
+			return nil, 0, err
+		}
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return f, -1, nil
+	}
+	return f, fi.Size(), nil
+}
+
+// output is the destination of encrypt-file and decrypt-file: stdout, or
+// the part file of the output file.
+type output struct {
+	*os.File
+	name string
+	// kept is the number of bytes already in a resumed part file.
+	kept int64
+}
+
+// createOutput creates the part file of name, or returns stdout for "" or
+// "-". With resume, the chunks of an existing part file are kept but for
+// the last one, which is decrypted again since it may be the final chunk
+// of the stream; checkKept then compares them with the plaintext.
+func createOutput(name string, resume bool) (*output, error) {
+	if name == "" || name == "-" {
+		return &output{File: os.Stdout}, nil
+	}
+	part := name + partSuffix
+	if !resume {
+		f, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &output{File: f, name: name}, nil
+	}
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var skip int64
+	if fi.Size() > 0 {
+		skip = (fi.Size() - 1) / sm4stream.ChunkSize
+	}
+	keep := skip * sm4stream.ChunkSize
+	if err := f.Truncate(keep); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &output{File: f, name: name, kept: keep}, nil
+}
+
+// checkKept decrypts the plaintext of the bytes kept in a resumed part file
+// from r and checks that the part file holds the same, so that a part file
+// left by another ciphertext is not completed with this one. It leaves the
+// part file positioned after the kept bytes.
+func (o *output) checkKept(r io.Reader) error {
+	want := make([]byte, copyBufferSize)
+	got := make([]byte, copyBufferSize)
+	for n := o.kept; n > 0; {
+		m := int64(len(want))
+		if n < m {
+			m = n
+		}
+		if _, err := io.ReadFull(r, want[:m]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return errPartLong
+			}
+			return err
+		}
+		if _, err := io.ReadFull(o.File, got[:m]); err != nil {
+			return err
+		}
+		if !bytes.Equal(want[:m], got[:m]) {
+			return errPartMismatch
+		}
+		n -= m
+	}
+	return nil
+}
+
+// commit gives a complete part file its name.
+func (o *output) commit() error {
+	if o.name == "" {
+		return nil
+	}
+	if err := o.Sync(); err != nil {
+		o.Close()
+		return err
+	}
+	if err := o.Close(); err != nil {
+		return err
+	}
+	return os.Rename(o.name+partSuffix, o.name)
+}
+
+// abort closes the part file, and removes it unless keep is set.
+func (o *output) abort(keep bool) {
+	if o.name == "" {
+		return
+	}
+	o.Close()
+	if !keep {
+		os.Remove(o.name + partSuffix)
+	}
+}
+
+// progress counts the bytes read from an input and reports them on stderr
+// at most once a second.
+type progress struct {
+	r       io.Reader
+	command string
+	enabled bool
+	total   int64
+	n       int64
+	start   time.Time
+	last    time.Time
+}
+
+func newProgress(command string, r io.Reader, total int64, enabled bool) *progress {
+	now := time.Now()
+	return &progress{r: r, command: command, enabled: enabled, total: total, start: now, last: now}
+}
+
+func (p *progress) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if p.enabled && time.Since(p.last) >= time.Second {
+		p.last = time.Now()
+		p.report()
+	}
+	return n, err
+}
+
+// done reports the final count.
+func (p *progress) done() {
+	if p.enabled {
+		p.report()
+	}
+}
+
+func (p *progress) report() {
+	rate := float64(p.n) / time.Since(p.start).Seconds()
+	if p.total >= 0 {
+		percent := 100.0
+		if p.total > 0 {
+			percent = 100 * float64(p.n) / float64(p.total)
+		}
+		fmt.Fprintf(os.Stderr, "gmcli %s: %s of %s (%.1f%%), %s/s\n",
+			p.command, formatSize(float64(p.n)), formatSize(float64(p.total)), percent, formatSize(rate))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "gmcli %s: %s, %s/s\n", p.command, formatSize(float64(p.n)), formatSize(rate))
+}
+
+// formatSize formats a number of bytes with a binary prefix.
+func formatSize(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	i := -1
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", n, units[i])
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/compat/openssl"
+	"github.com/xuperchain/crypto/gm/gmsm/interop"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// inspectTypes are the values of -type, besides auto.
+var inspectTypes = []string{"cert", "csr", "sig", "ct"}
+
+func inspect(args []string) error {
+	fs := newFlagSet("inspect")
+	in := fs.String("in", "", "PEM, hex, base64 or DER input `file` (default stdin)")
+	out := fs.String("out", "", "output `file` (default stdout)")
+	typ := fs.String("type", "auto", "input `type`: auto, "+strings.Join(inspectTypes, ", "))
+	mode := fs.String("mode", "", "ciphertext `order` of a raw ciphertext, c1c3c2 or c1c2c3 (default detected with -key, else c1c3c2)")
+	pubFile := fs.String("pub", "", "public key or certificate `file` of the signer, to find the digest and UID a signature verifies under")
+	msgFile := fs.String("msg", "", "signed message `file` of a signature")
+	uid := fs.String("uid", "", "also try the user `ID` when checking a signature")
+	keyFile := fs.String("key", "", "private key `file` to decrypt a ciphertext with, to find its order")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	der, encoding, err := decodeInspectInput(data)
+	if err != nil {
+		return err
+	}
+
+	kind := *typ
+	if kind == "auto" {
+		if kind = identifyInspect(der); kind == "" {
+			return errors.New("cannot identify input; give -type")
+		}
+	}
+	var pub *sm2.PublicKey
+	if *pubFile != "" {
+		if pub, err = loadPublicKey(*pubFile); err != nil {
+			return err
+		}
+	}
+
+	p := new(printer)
+	p.printf(0, "Input: %s, %d bytes", encoding, len(der))
+	switch kind {
+	case "cert":
+		c, err := sm2.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		inspectCertificate(p, c, pub)
+	case "csr":
+		csr, err := sm2.ParseCertificateRequest(der)
+		if err != nil {
+			return err
+		}
+		inspectRequest(p, csr)
+	case "sig":
+		var msg []byte
+		if *msgFile != "" {
+			if msg, err = readInput(*msgFile); err != nil {
+				return err
+			}
+		}
+		if err := inspectSignature(p, der, pub, msg, *uid); err != nil {
+			return err
+		}
+	case "ct":
+		var priv *sm2.PrivateKey
+		if *keyFile != "" {
+			if priv, err = loadPrivateKey(*keyFile, *pass); err != nil {
+				return err
+			}
+		}
+		if err := inspectCiphertext(p, der, *mode, priv); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown type %q, want auto, %s", kind, strings.Join(inspectTypes, ", "))
+	}
+	return writeOutput(*out, p.Bytes())
+}
+
+// printer writes indented lines of text.
+type printer struct {
+	bytes.Buffer
+}
+
+func (p *printer) printf(indent int, format string, args ...interface{}) {
+	p.WriteString(strings.Repeat("    ", indent))
+	fmt.Fprintf(p, format, args...)
+	p.WriteByte('\n')
+}
+
+// decodeInspectInput returns the bytes of a PEM block, of hex or base64
+// text, or data itself, with a description of the encoding.
+func decodeInspectInput(data []byte) ([]byte, string, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, "", errors.New("empty input")
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes, "PEM " + block.Type, nil
+	}
+	if strings.IndexFunc(string(data), isBinary) < 0 {
+		text := strings.Join(strings.Fields(string(data)), "")
+		if b, err := hex.DecodeString(strings.TrimPrefix(text, "0x")); err == nil {
+			return b, "hex", nil
+		}
+		if b, err := base64.StdEncoding.DecodeString(text); err == nil {
+			return b, "base64", nil
+		}
+	}
+	return data, "binary", nil
+}
+
+// isBinary reports whether r cannot occur in hex or base64 text.
+func isBinary(r rune) bool {
+	return r < 0x20 && r != '\n' && r != '\r' && r != '\t' || r >= 0x7f
+}
+
+// identifyInspect returns the type of der, or "" if it is none of them.
+// Signatures of 64 and 65 bytes are r || s and r || s || v; a ciphertext
+// holds at least C1 and C3, 96 bytes.
+func identifyInspect(der []byte) string {
+	if _, err := sm2.ParseCertificate(der); err == nil {
+		return "cert"
+	}
+	if _, err := sm2.ParseCertificateRequest(der); err == nil {
+		return "csr"
+	}
+	if len(der) == 64 || len(der) == 65 {
+		return "sig"
+	}
+	if len(der) > 0 && der[0] == 0x30 {
+		if _, err := openssl.ParseCiphertext(der); err == nil {
+			return "ct"
+		}
+		if _, err := (interop.Lenient{}).Signature(der); err == nil {
+			return "sig"
+		}
+	}
+	if _, err := (interop.Lenient{}).Ciphertext(der); err == nil {
+		return "ct"
+	}
+	return ""
+}
+
+func inspectCertificate(p *printer, c *sm2.Certificate, issuerKey *sm2.PublicKey) {
+	p.printf(0, "Certificate:")
+	p.printf(1, "Version: %d", c.Version)
+	p.printf(1, "Serial: %x", c.SerialNumber)
+	p.printf(1, "Issuer: %s", c.Issuer)
+	p.printf(1, "Subject: %s", c.Subject)
+	p.printf(1, "Not before: %s", c.NotBefore.UTC().Format(time.RFC3339))
+	p.printf(1, "Not after: %s", c.NotAfter.UTC().Format(time.RFC3339))
+	printPublicKey(p, 1, c.PublicKey)
+	if c.KeyUsage != 0 {
+		p.printf(1, "Key usage: %s", keyUsageString(c.KeyUsage))
+	}
+	if len(c.ExtKeyUsage) > 0 || len(c.UnknownExtKeyUsage) > 0 {
+		var names []string
+		for _, u := range c.ExtKeyUsage {
+			names = append(names, extKeyUsageString(u))
+		}
+		for _, oid := range c.UnknownExtKeyUsage {
+			names = append(names, oid.String())
+		}
+		p.printf(1, "Extended key usage: %s", strings.Join(names, ", "))
+	}
+	if c.BasicConstraintsValid {
+		pathLen := "unlimited"
+		if c.MaxPathLen > 0 || c.MaxPathLenZero {
+			pathLen = fmt.Sprint(c.MaxPathLen)
+		}
+		p.printf(1, "Basic constraints: CA %t, path length %s", c.IsCA, pathLen)
+	}
+	printNames(p, 1, c.DNSNames, c.EmailAddresses, c.IPAddresses)
+	if len(c.SubjectKeyId) > 0 {
+		p.printf(1, "Subject key ID: %x", c.SubjectKeyId)
+	}
+	if len(c.AuthorityKeyId) > 0 {
+		p.printf(1, "Authority key ID: %x", c.AuthorityKeyId)
+	}
+	printExtensions(p, 1, c.Extensions)
+
+	if issuerKey == nil && bytes.Equal(c.RawIssuer, c.RawSubject) {
+		issuerKey, _ = certificateKey(c.PublicKey)
+	}
+	printSignedBy(p, 1, c.SignatureAlgorithm, c.Signature, c.RawTBSCertificate, issuerKey,
+		"give the issuer certificate with -pub to check it")
+}
+
+func inspectRequest(p *printer, csr *sm2.CertificateRequest) {
+	p.printf(0, "Certificate request:")
+	p.printf(1, "Version: %d", csr.Version)
+	p.printf(1, "Subject: %s", csr.Subject)
+	printPublicKey(p, 1, csr.PublicKey)
+	printNames(p, 1, csr.DNSNames, csr.EmailAddresses, csr.IPAddresses)
+	printExtensions(p, 1, csr.Extensions)
+	pub, _ := certificateKey(csr.PublicKey)
+	printSignedBy(p, 1, csr.SignatureAlgorithm, csr.Signature, csr.RawTBSCertificateRequest, pub, "")
+}
+
+func printPublicKey(p *printer, indent int, key interface{}) {
+	pub, err := certificateKey(key)
+	if err != nil {
+		p.printf(indent, "Public key: %T, not SM2", key)
+		return
+	}
+	p.printf(indent, "Public key: SM2")
+	p.printf(indent+1, "X: %064x", pub.X)
+	p.printf(indent+1, "Y: %064x", pub.Y)
+}
+
+func printNames(p *printer, indent int, dns, email []string, ips []net.IP) {
+	if len(dns) > 0 {
+		p.printf(indent, "DNS names: %s", strings.Join(dns, ", "))
+	}
+	if len(email) > 0 {
+		p.printf(indent, "Email addresses: %s", strings.Join(email, ", "))
+	}
+	if len(ips) > 0 {
+		var addrs []string
+		for _, ip := range ips {
+			addrs = append(addrs, ip.String())
+		}
+		p.printf(indent, "IP addresses: %s", strings.Join(addrs, ", "))
+	}
+}
+
+// extensionNames names the extensions of RFC 5280 that GM certificates
+// commonly carry.
+var extensionNames = map[string]string{
+	"2.5.29.14":         "subject key identifier",
+	"2.5.29.15":         "key usage",
+	"2.5.29.17":         "subject alternative name",
+	"2.5.29.19":         "basic constraints",
+	"2.5.29.30":         "name constraints",
+	"2.5.29.31":         "CRL distribution points",
+	"2.5.29.32":         "certificate policies",
+	"2.5.29.35":         "authority key identifier",
+	"2.5.29.37":         "extended key usage",
+	"1.3.6.1.5.5.7.1.1": "authority information access",
+}
+
+func printExtensions(p *printer, indent int, exts []pkix.Extension) {
+	if len(exts) == 0 {
+		return
+	}
+	p.printf(indent, "Extensions:")
+	for _, ext := range exts {
+		id := ext.Id.String()
+		if name, ok := extensionNames[id]; ok {
+			id += " (" + name + ")"
+		}
+		if ext.Critical {
+			id += ", critical"
+		}
+		p.printf(indent+1, "%s", id)
+	}
+}
+
+var keyUsageNames = []string{
+	"digitalSignature", "contentCommitment", "keyEncipherment", "dataEncipherment",
+	"keyAgreement", "keyCertSign", "cRLSign", "encipherOnly", "decipherOnly",
+}
+
+func keyUsageString(u sm2.KeyUsage) string {
+	var names []string
+	for i, name := range keyUsageNames {
+		if u&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+var extKeyUsageNames = map[sm2.ExtKeyUsage]string{
+	sm2.ExtKeyUsageAny:             "any",
+	sm2.ExtKeyUsageServerAuth:      "serverAuth",
+	sm2.ExtKeyUsageClientAuth:      "clientAuth",
+	sm2.ExtKeyUsageCodeSigning:     "codeSigning",
+	sm2.ExtKeyUsageEmailProtection: "emailProtection",
+	sm2.ExtKeyUsageTimeStamping:    "timeStamping",
+	sm2.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+func extKeyUsageString(u sm2.ExtKeyUsage) string {
+	if name, ok := extKeyUsageNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("usage %d", u)
+}
+
+// printSignedBy decodes the SM2 signature of a certificate or request and,
+// if the signer key pub is known, checks it under both digests in use: the
+// SM3(ZA || TBS) of GM/T 0015 and OpenSSL, and the bare SM3(TBS) with which
+// the sm2 package signs and verifies certificates. Peers that disagree on
+// the digest reject each other's certificates.
+func printSignedBy(p *printer, indent int, algo sm2.SignatureAlgorithm, sig, tbs []byte, pub *sm2.PublicKey, noKey string) {
+	p.printf(indent, "Signature algorithm: %v", algo)
+	if algo != sm2.SM2WithSM3 {
+		p.printf(indent+1, "Value: %s", shortHex(sig))
+		p.printf(indent+1, "Not an SM2 signature; not checked")
+		return
+	}
+	var s sm2.Signature
+	if err := s.DecodeFormat(sm2.FormatDER, sig); err != nil {
+		p.printf(indent+1, "Value: %s", shortHex(sig))
+		p.printf(indent+1, "Malformed DER signature: %v", err)
+		return
+	}
+	p.printf(indent+1, "r: %064x", s.R)
+	p.printf(indent+1, "s: %064x", s.S)
+	if pub == nil {
+		if noKey != "" {
+			p.printf(indent+1, "Not checked: %s", noKey)
+		}
+		return
+	}
+	za, err := sm2.ZA(pub, nil)
+	if err != nil {
+		p.printf(indent+1, "Not checked: %v", err)
+		return
+	}
+	withZA := sm3.New()
+	withZA.Write(za)
+	withZA.Write(tbs)
+	printCheck(p, indent+1, "SM3(ZA || TBS), default UID (GM/T 0015, OpenSSL)", sm2.Verify(pub, withZA.Sum(nil), s.R, s.S))
+	printCheck(p, indent+1, "SM3(TBS) without ZA (sm2 package)", sm2.Verify(pub, sm3.Sm3Sum(tbs), s.R, s.S))
+}
+
+func printCheck(p *printer, indent int, what string, ok bool) {
+	result := "fails"
+	if ok {
+		result = "verifies"
+	}
+	p.printf(indent, "%s: %s", what, result)
+}
+
+func inspectSignature(p *printer, data []byte, pub *sm2.PublicKey, msg []byte, uid string) error {
+	var (
+		sig      sm2.Signature
+		encoding string
+	)
+	switch len(data) {
+	case 64:
+		encoding = "raw r || s"
+	case 65:
+		encoding = "raw r || s || v"
+	default:
+		encoding = "DER SEQUENCE { r INTEGER, s INTEGER }"
+	}
+	if err := sig.Decode(data); err != nil {
+		lenient, lerr := (interop.Lenient{}).Signature(data)
+		if lerr != nil {
+			return fmt.Errorf("not an SM2 signature: %v", err)
+		}
+		sig = *lenient
+		encoding = "BER SEQUENCE { r INTEGER, s INTEGER }, not DER; sm2 rejects it, interop.Lenient accepts it"
+	}
+	p.printf(0, "Signature:")
+	p.printf(1, "Encoding: %s", encoding)
+	p.printf(1, "r: %064x", sig.R)
+	p.printf(1, "s: %064x", sig.S)
+	if len(data) == 65 {
+		p.printf(1, "v: %d", sig.V)
+	}
+	if !sig.IsCanonical() {
+		p.printf(1, "r or s out of range; no key verifies it")
+		return nil
+	}
+	if pub == nil || msg == nil {
+		p.printf(1, "Digest: not checked; give -pub and -msg to find the UID it was made under")
+		return nil
+	}
+
+	type candidate struct {
+		what string
+		ok   bool
+	}
+	candidates := []candidate{
+		{"SM3(ZA || M), default UID " + string(sm2.DefaultUID), sm2.Sm2Verify(pub, msg, nil, sig.R, sig.S)},
+		{"SM3(ZA || M), empty UID", sm2.Sm2Verify(pub, msg, []byte{}, sig.R, sig.S)},
+	}
+	if uid != "" && uid != string(sm2.DefaultUID) {
+		candidates = append(candidates, candidate{"SM3(ZA || M), UID " + uid, sm2.Sm2Verify(pub, msg, []byte(uid), sig.R, sig.S)})
+	}
+	candidates = append(candidates, candidate{"SM3(M) without ZA", sm2.Verify(pub, sm3.Sm3Sum(msg), sig.R, sig.S)})
+	for _, c := range candidates {
+		printCheck(p, 1, c.what, c.ok)
+	}
+	return nil
+}
+
+// c1Size and c3Size are the sizes of C1, 04 || x1 || y1, and of C3.
+const (
+	c1Size = 65
+	c3Size = sm3.Size
+)
+
+func inspectCiphertext(p *printer, data []byte, modeName string, priv *sm2.PrivateKey) error {
+	p.printf(0, "SM2 ciphertext:")
+	if len(data) > 0 && data[0] == 0x30 {
+		return inspectCiphertextASN1(p, data, priv)
+	}
+
+	prefixed := len(data) >= c1Size+c3Size && data[0] == 4 && onCurve(data[1:c1Size])
+	if !prefixed && !(len(data) >= c1Size-1+c3Size && onCurve(data[:c1Size-1])) {
+		if len(data) >= c1Size && data[0] == 4 {
+			x, y := new(big.Int).SetBytes(data[1:33]), new(big.Int).SetBytes(data[33:65])
+			p.printf(1, "C1 %064x, %064x is not on the curve", x, y)
+		}
+		return errors.New("not an SM2 ciphertext: C1 is not a point of the curve")
+	}
+	ct := data
+	if !prefixed {
+		ct = append([]byte{4}, data...)
+	}
+
+	var detected []string
+	if priv != nil {
+		for _, name := range []string{"c1c3c2", "c1c2c3"} {
+			pt, err := sm2.DecryptEx(priv, ct, sm2.WithCiphertextMode(ciphertextModes[name]))
+			if err == nil {
+				detected = append(detected, name)
+				p.printf(1, "Decrypts as %s: %d bytes of plaintext", name, len(pt))
+			}
+		}
+		if len(detected) == 0 {
+			p.printf(1, "Decrypts in neither order with the key: wrong key or corrupted ciphertext")
+		}
+	}
+	how := "given with -mode"
+	if modeName == "" {
+		switch {
+		case len(detected) == 1:
+			modeName, how = detected[0], "found by decrypting"
+		default:
+			modeName, how = "c1c3c2", "assumed; the order cannot be told without -key"
+		}
+	}
+	mode, err := ciphertextMode(modeName)
+	if err != nil {
+		return err
+	}
+
+	p.printf(1, "Encoding: raw %s, %s", strings.ToUpper(modeName), how)
+	off := 0
+	if prefixed {
+		p.printf(1, "[0:1] 04 prefix of C1")
+		off = 1
+	} else {
+		p.printf(1, "C1 lacks the 04 prefix; sm2 rejects it, interop.Lenient accepts it")
+	}
+	n := len(data)
+	x := new(big.Int).SetBytes(data[off : off+32])
+	y := new(big.Int).SetBytes(data[off+32 : off+64])
+	p.printf(1, "[%d:%d] C1 x: %064x", off, off+32, x)
+	p.printf(1, "[%d:%d] C1 y: %064x", off+32, off+64, y)
+	c2Len := n - off - 64 - c3Size
+	if mode == sm2.C1C3C2 {
+		p.printf(1, "[%d:%d] C3: %x", off+64, off+64+c3Size, data[off+64:off+64+c3Size])
+		p.printf(1, "[%d:%d] C2: %s", off+64+c3Size, n, shortHex(data[off+64+c3Size:]))
+	} else {
+		p.printf(1, "[%d:%d] C2: %s", off+64, n-c3Size, shortHex(data[off+64:n-c3Size]))
+		p.printf(1, "[%d:%d] C3: %x", n-c3Size, n, data[n-c3Size:])
+	}
+	p.printf(1, "Plaintext length: %d bytes", c2Len)
+	return nil
+}
+
+func inspectCiphertextASN1(p *printer, der []byte, priv *sm2.PrivateKey) error {
+	ct, err := openssl.ParseCiphertext(der)
+	encoding := "ASN.1 SEQUENCE { x INTEGER, y INTEGER, C3 OCTET STRING, C2 OCTET STRING } (GM/T 0009)"
+	if err != nil {
+		if ct, err = (interop.Lenient{}).Ciphertext(der); err != nil {
+			return fmt.Errorf("not an SM2 ciphertext: %v", err)
+		}
+		encoding += ", not DER; interop.Lenient accepts it"
+	}
+	p.printf(1, "Encoding: %s", encoding)
+	p.printf(1, "C1 x: %x", ct[1:33])
+	p.printf(1, "C1 y: %x", ct[33:c1Size])
+	p.printf(1, "C3: %x", ct[c1Size:c1Size+c3Size])
+	p.printf(1, "C2: %s", shortHex(ct[c1Size+c3Size:]))
+	p.printf(1, "Plaintext length: %d bytes", len(ct)-c1Size-c3Size)
+	if priv != nil {
+		if pt, err := sm2.Decrypt(priv, ct); err == nil {
+			p.printf(1, "Decrypts: %d bytes of plaintext", len(pt))
+		} else {
+			p.printf(1, "Does not decrypt with the key: wrong key or corrupted ciphertext")
+		}
+	}
+	return nil
+}
+
+// onCurve reports whether b is x || y of a point of the curve.
+func onCurve(b []byte) bool {
+	x, y := new(big.Int).SetBytes(b[:32]), new(big.Int).SetBytes(b[32:64])
+	return sm2.P256Sm2().IsOnCurve(x, y)
+}
+
+// shortHex returns the hex of b, cut after 32 bytes.
+func shortHex(b []byte) string {
+	if len(b) <= 32 {
+		return hex.EncodeToString(b)
+	}
+	return fmt.Sprintf("%x... (%d bytes)", b[:32], len(b))
+}
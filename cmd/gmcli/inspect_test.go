@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The inputs in testdata share the SM2 key of key.pem, made with OpenSSL.
+// cert.pem and csr.pem were made with the sm2 package, so they verify
+// without ZA only; sig.der and ct.bin were computed with an independent
+// implementation, the signature under the default UID.
+func TestInspect(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name   string
+		args   []string
+		golden string
+	}{
+		{"cert", []string{"-in", "testdata/cert.pem"}, "testdata/inspect_cert.txt"},
+		{"csr", []string{"-in", "testdata/csr.pem"}, "testdata/inspect_csr.txt"},
+		{"sig", []string{"-in", "testdata/sig.der", "-pub", "testdata/cert.pem", "-msg", "testdata/msg.txt"}, "testdata/inspect_sig.txt"},
+		{"ct", []string{"-in", "testdata/ct.bin", "-key", "testdata/key.pem"}, "testdata/inspect_ct.txt"},
+	}
+	for _, tt := range tests {
+		out := path(tt.name + ".txt")
+		if err := run(append([]string{"inspect", "-out", out}, tt.args...)...); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got, want := string(readFile(t, out)), string(readFile(t, tt.golden)); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, want)
+		}
+	}
+}
+
+// The order of a ciphertext cannot be told without the key, and the input
+// may be hex.
+func TestInspectCiphertextHex(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+	in, out := path("ct.hex"), path("out.txt")
+	writeFile(t, in, []byte(hex.EncodeToString(readFile(t, "testdata/ct.bin"))+"\n"))
+
+	if err := run("inspect", "-in", in, "-out", out); err != nil {
+		t.Fatal(err)
+	}
+	got := string(readFile(t, out))
+	want := strings.Replace(string(readFile(t, "testdata/inspect_ct.txt")), "binary", "hex", 1)
+	want = strings.Replace(want, "    Decrypts as c1c3c2: 14 bytes of plaintext\n", "", 1)
+	want = strings.Replace(want, "found by decrypting", "assumed; the order cannot be told without -key", 1)
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if err := run("inspect", "-in", in, "-out", out, "-type", "cert"); err == nil {
+		t.Fatal("parsed a ciphertext as a certificate")
+	}
+	writeFile(t, in, []byte("not a certificate"))
+	if err := run("inspect", "-in", in, "-out", out); err == nil {
+		t.Fatal("identified text as an input")
+	}
+}
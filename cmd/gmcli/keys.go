@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/xuperchain/crypto/gm/gmsm/keyconv"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func keygen(args []string) error {
+	fs := newFlagSet("keygen")
+	out := fs.String("out", "", "private key `file` (default stdout)")
+	pubOut := fs.String("pubout", "", "also write the public key to `file`")
+	pass := fs.String("pass", "", "encrypt the private key with the password from `source`")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	pwd, err := readPassword(*pass)
+	if err != nil {
+		return err
+	}
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		return err
+	}
+	keyPEM, err := sm2.WritePrivateKeytoMem(priv, pwd)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(*out, keyPEM); err != nil {
+		return err
+	}
+	if *pubOut == "" {
+		return nil
+	}
+	pubPEM, err := sm2.WritePublicKeytoMem(&priv.PublicKey, nil)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*pubOut, pubPEM)
+}
+
+func pubkey(args []string) error {
+	fs := newFlagSet("pubkey")
+	in := fs.String("in", "", "private key or certificate `file` (default stdin)")
+	out := fs.String("out", "", "public key `file` (default stdout)")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var pub *sm2.PublicKey
+	if block, _ := pem.Decode(data); block != nil && (block.Type == "PRIVATE KEY" || block.Type == "ENCRYPTED PRIVATE KEY") {
+		priv, err := parsePrivateKey(data, *pass)
+		if err != nil {
+			return err
+		}
+		pub = &priv.PublicKey
+	} else if pub, err = parsePublicKey(data); err != nil {
+		return err
+	}
+	pubPEM, err := sm2.WritePublicKeytoMem(pub, nil)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, pubPEM)
+}
+
+// loadPrivateKey reads a PKCS #8 PEM private key, encrypted when pass is
+// set.
+func loadPrivateKey(name, pass string) (*sm2.PrivateKey, error) {
+	if name == "" {
+		return nil, errors.New("no private key given")
+	}
+	data, err := readInput(name)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKey(data, pass)
+}
+
+func parsePrivateKey(data []byte, pass string) (*sm2.PrivateKey, error) {
+	pwd, err := readPassword(pass)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); block != nil && block.Type == "ENCRYPTED PRIVATE KEY" && pwd == nil {
+		return nil, errors.New("private key is encrypted; give -pass")
+	}
+	priv, err := keyconv.ParsePrivateKey(data, keyconv.FormatPKCS8, &keyconv.Options{Password: pwd})
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse private key: %v", err)
+	}
+	return priv, nil
+}
+
+// loadPublicKey reads a PEM public key, or the public key of a PEM
+// certificate.
+func loadPublicKey(name string) (*sm2.PublicKey, error) {
+	if name == "" {
+		return nil, errors.New("no public key given")
+	}
+	data, err := readInput(name)
+	if err != nil {
+		return nil, err
+	}
+	return parsePublicKey(data)
+}
+
+func parsePublicKey(data []byte) (*sm2.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key is not PEM")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return sm2.ParseSm2PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		c, err := sm2.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return certificateKey(c.PublicKey)
+	}
+	return nil, fmt.Errorf("unexpected PEM type %q for a public key", block.Type)
+}
+
+// certificateKey returns the SM2 key of a certificate or request, which
+// sm2 parses as *ecdsa.PublicKey on the SM2 curve.
+func certificateKey(pub interface{}) (*sm2.PublicKey, error) {
+	switch pub := pub.(type) {
+	case *sm2.PublicKey:
+		return pub, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}, nil
+		}
+	}
+	return nil, errors.New("not an SM2 public key")
+}
@@ -0,0 +1,157 @@
+// Command gmcli performs SM2, SM3 and SM4 operations from the command line,
+// so that GM keys, signatures, ciphertexts and certificates can be handled
+// in scripts without writing Go.
+//
+// Usage:
+//
+//	gmcli <command> [flags]
+//
+// Run "gmcli help" for the list of commands and "gmcli <command> -h" for
+// the flags of one. Input is read from -in or stdin and output written to
+// -out or stdout. Private keys are PKCS #8 PEM; passwords of encrypted
+// keys are given with -pass as pass:<password>, env:<variable> or
+// file:<path>, as for OpenSSL.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"keygen":       {"generate an SM2 private key", keygen},
+	"pubkey":       {"extract the public key of a private key or certificate", pubkey},
+	"digest":       {"compute the SM3 hash, or the SM2 digest e with -pub", digest},
+	"sign":         {"sign a file, or an SM2 digest with -digest", sign},
+	"verify":       {"verify a signature of a file or digest", verify},
+	"encrypt":      {"encrypt to an SM2 public key", encrypt},
+	"decrypt":      {"decrypt with an SM2 private key", decrypt},
+	"encrypt-file": {"encrypt a stream of any size to SM2 public keys (sm2age)", encryptFile},
+	"decrypt-file": {"decrypt an sm2age stream, detecting truncation; resumable", decryptFile},
+	"req":          {"create a certificate signing request", req},
+	"cert":         {"issue a certificate from a request", cert},
+	"convert":      {"convert keys between formats, or certificates between PEM and DER", convert},
+	"inspect":      {"describe a certificate, request, signature or ciphertext, to debug interoperability", inspect},
+	"bench":        {"report the backends in use and SM2, SM3 and SM4 throughput as JSON", bench},
+}
+
+// errVerification is returned by verify for a signature that does not
+// verify, which exits with status 1 like any other failure.
+var errVerification = errors.New("verification failure")
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gmcli: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if err == flag.ErrHelp {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "gmcli %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gmcli <command> [flags]\n\ncommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s  %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet returns the flag set of a command, which reports errors
+// instead of exiting so that main prints them uniformly.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gmcli "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseFlags parses args and rejects positional arguments, which no
+// command takes.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+// readInput reads the file name, or stdin for "" or "-".
+func readInput(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// writeOutput writes data to the file name, or stdout for "" or "-".
+// Files are created with mode 0600 since they may hold private keys.
+func writeOutput(name string, data []byte) error {
+	if name == "" || name == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0600)
+}
+
+// readPassword resolves a -pass argument. An empty argument means no
+// password.
+func readPassword(arg string) ([]byte, error) {
+	if arg == "" {
+		return nil, nil
+	}
+	i := strings.IndexByte(arg, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("password source %q is not pass:, env: or file:", arg)
+	}
+	switch src, val := arg[:i], arg[i+1:]; src {
+	case "pass":
+		return []byte(val), nil
+	case "env":
+		v, ok := os.LookupEnv(val)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", val)
+		}
+		return []byte(v), nil
+	case "file":
+		f, err := os.Open(val)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		b, err := ioutil.ReadAll(io.LimitReader(f, 4096))
+		if err != nil {
+			return nil, err
+		}
+		// Only the first line is the password, as for OpenSSL.
+		line := strings.SplitN(string(b), "\n", 2)[0]
+		return []byte(strings.TrimSuffix(line, "\r")), nil
+	}
+	return nil, fmt.Errorf("password source %q is not pass:, env: or file:", arg)
+}
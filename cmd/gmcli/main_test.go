@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4stream"
+)
+
+// run runs the command args[0] with the flags args[1:].
+func run(args ...string) error {
+	return commands[args[0]].run(args[1:])
+}
+
+// tempDir returns a temporary directory and a function joining names to it.
+func tempDir(t *testing.T) (string, func(name string) string) {
+	dir, err := ioutil.TempDir("", "gmcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func(name string) string { return filepath.Join(dir, name) }
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestKeygen(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		pass string
+		typ  string
+	}{
+		{"plain", "", "PRIVATE KEY"},
+		{"encrypted", "pass:secret", "ENCRYPTED PRIVATE KEY"},
+	}
+	for _, tt := range tests {
+		key, pub := path(tt.name+".pem"), path(tt.name+".pub")
+		if err := run("keygen", "-out", key, "-pubout", pub, "-pass", tt.pass); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if block, _ := pem.Decode(readFile(t, key)); block == nil || block.Type != tt.typ {
+			t.Fatalf("%s: private key is not a PEM %s", tt.name, tt.typ)
+		}
+		if fi, err := os.Stat(key); err != nil || fi.Mode().Perm() != 0600 {
+			t.Fatalf("%s: private key file mode %v, %v", tt.name, fi.Mode(), err)
+		}
+		priv, err := loadPrivateKey(key, tt.pass)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		// pubkey extracts the same public key as keygen -pubout.
+		extracted := path(tt.name + ".extracted")
+		if err := run("pubkey", "-in", key, "-pass", tt.pass, "-out", extracted); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.Equal(readFile(t, extracted), readFile(t, pub)) {
+			t.Fatalf("%s: pubkey differs from keygen -pubout", tt.name)
+		}
+		got, err := loadPublicKey(pub)
+		if err != nil || got.X.Cmp(priv.X) != 0 || got.Y.Cmp(priv.Y) != 0 {
+			t.Fatalf("%s: public key does not match the private key", tt.name)
+		}
+	}
+
+	if _, err := loadPrivateKey(path("encrypted.pem"), ""); err == nil {
+		t.Fatal("loaded an encrypted key without a password")
+	}
+	if _, err := loadPrivateKey(path("encrypted.pem"), "pass:wrong"); err == nil {
+		t.Fatal("loaded an encrypted key with a wrong password")
+	}
+	if err := run("keygen", "-out", path("x.pem"), "-pass", "secret"); err == nil {
+		t.Fatal("accepted a password without a source")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+	key, pub := path("key.pem"), path("key.pub")
+	if err := run("keygen", "-out", key, "-pubout", pub); err != nil {
+		t.Fatal(err)
+	}
+	msg := path("msg")
+	writeFile(t, msg, []byte("hello, gmcli\n"))
+
+	tests := []struct {
+		name      string
+		signArgs  []string
+		checkArgs []string
+		err       error
+	}{
+		{"der", nil, nil, nil},
+		{"raw", []string{"-format", "raw"}, []string{"-format", "raw"}, nil},
+		{"hex", []string{"-hex"}, []string{"-hex"}, nil},
+		{"uid", []string{"-uid", "alice"}, []string{"-uid", "alice"}, nil},
+		{"wrong uid", []string{"-uid", "alice"}, nil, errVerification},
+		{"certificate", nil, []string{"-pub", "testdata/cert.pem"}, errVerification},
+	}
+	for _, tt := range tests {
+		sig := path(tt.name + ".sig")
+		args := append([]string{"sign", "-key", key, "-in", msg, "-out", sig}, tt.signArgs...)
+		if err := run(args...); err != nil {
+			t.Fatalf("%s: sign: %v", tt.name, err)
+		}
+		args = append([]string{"verify", "-pub", pub, "-sig", sig, "-in", msg}, tt.checkArgs...)
+		if err := run(args...); err != tt.err {
+			t.Errorf("%s: verify: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	// A raw signature does not parse as DER.
+	if err := run("verify", "-pub", pub, "-sig", path("raw.sig"), "-in", msg); err == nil || err == errVerification {
+		t.Fatalf("raw signature as DER: got %v, want a parse error", err)
+	}
+	other := path("other")
+	writeFile(t, other, []byte("hello, gmcli!\n"))
+	if err := run("verify", "-pub", pub, "-sig", path("der.sig"), "-in", other); err != errVerification {
+		t.Fatalf("other message: got %v", err)
+	}
+	if err := run("sign", "-key", key, "-in", msg, "-format", "pem"); err == nil {
+		t.Fatal("accepted an unknown signature format")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+	key, pub := path("key.pem"), path("key.pub")
+	if err := run("keygen", "-out", key, "-pubout", pub); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("attack at dawn")
+	in := path("plaintext")
+	writeFile(t, in, plaintext)
+
+	for _, mode := range []string{"c1c3c2", "c1c2c3"} {
+		ct, out := path(mode+".ct"), path(mode+".out")
+		if err := run("encrypt", "-pub", pub, "-in", in, "-out", ct, "-mode", mode); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if n := len(readFile(t, ct)); n != 65+32+len(plaintext) {
+			t.Fatalf("%s: ciphertext of %d bytes", mode, n)
+		}
+		if err := run("decrypt", "-key", key, "-in", ct, "-out", out, "-mode", mode); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if got := readFile(t, out); !bytes.Equal(got, plaintext) {
+			t.Fatalf("%s: decrypted %q", mode, got)
+		}
+	}
+
+	if err := run("decrypt", "-key", key, "-in", path("c1c3c2.ct"), "-out", path("x"), "-mode", "c1c2c3"); err == nil {
+		t.Fatal("decrypted C1C3C2 as C1C2C3")
+	}
+	if err := run("decrypt", "-key", "testdata/key.pem", "-in", path("c1c3c2.ct"), "-out", path("x")); err == nil {
+		t.Fatal("decrypted with another key")
+	}
+	if err := run("encrypt", "-pub", pub, "-in", in, "-mode", "c2c1c3"); err == nil {
+		t.Fatal("accepted an unknown ciphertext mode")
+	}
+}
+
+func TestReqCert(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+	caKey, key := path("ca.pem"), path("key.pem")
+	for _, k := range []string{caKey, key} {
+		if err := run("keygen", "-out", k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	caCSR, caCert := path("ca.csr"), path("ca.crt")
+	if err := run("req", "-key", caKey, "-subj", "CN=Test CA,O=Example,C=CN", "-out", caCSR); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("cert", "-csr", caCSR, "-cakey", caKey, "-isca", "-pathlen", "0", "-out", caCert); err != nil {
+		t.Fatal(err)
+	}
+	csr, leaf := path("node.csr"), path("node.crt")
+	if err := run("req", "-key", key, "-subj", "CN=node1,O=Example", "-dns", "node1.example.com", "-ip", "10.0.0.1", "-out", csr); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("cert", "-csr", csr, "-ca", caCert, "-cakey", caKey, "-usage", "sign", "-days", "30", "-out", leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := sm2.ReadCertificateFromMem(readFile(t, caCert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := sm2.ReadCertificateFromMem(readFile(t, leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.IsCA || ca.MaxPathLen != 0 || !ca.MaxPathLenZero || ca.Subject.CommonName != "Test CA" {
+		t.Fatalf("CA certificate: IsCA %v, MaxPathLen %d, subject %v", ca.IsCA, ca.MaxPathLen, ca.Subject)
+	}
+	if err := ca.CheckSignatureFrom(ca); err != nil {
+		t.Fatalf("CA certificate is not self-signed: %v", err)
+	}
+	if err := c.CheckSignatureFrom(ca); err != nil {
+		t.Fatalf("certificate is not signed by the CA: %v", err)
+	}
+	if c.IsCA || c.KeyUsage != keyUsages["sign"] || c.Issuer.CommonName != "Test CA" ||
+		len(c.DNSNames) != 1 || c.DNSNames[0] != "node1.example.com" ||
+		len(c.IPAddresses) != 1 || c.IPAddresses[0].String() != "10.0.0.1" {
+		t.Fatalf("certificate: %+v", c)
+	}
+	if days := c.NotAfter.Sub(c.NotBefore).Hours() / 24; days < 30 || days > 31 {
+		t.Fatalf("certificate valid for %.1f days", days)
+	}
+	if pub, err := loadPublicKey(leaf); err != nil {
+		t.Fatal(err)
+	} else if priv, _ := loadPrivateKey(key, ""); pub.X.Cmp(priv.X) != 0 {
+		t.Fatal("certificate key does not match the request")
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"self-signed with another key", []string{"cert", "-csr", csr, "-cakey", caKey}},
+		{"issuer key mismatch", []string{"cert", "-csr", csr, "-ca", caCert, "-cakey", key}},
+		{"no request", []string{"cert", "-cakey", caKey}},
+		{"not a request", []string{"cert", "-csr", caCert, "-cakey", caKey}},
+		{"days", []string{"cert", "-csr", caCSR, "-cakey", caKey, "-days", "0"}},
+		{"usage", []string{"cert", "-csr", caCSR, "-cakey", caKey, "-usage", "auth"}},
+		{"subject", []string{"req", "-key", key, "-subj", "CN"}},
+		{"subject attribute", []string{"req", "-key", key, "-subj", "E=a@example.com"}},
+		{"ip", []string{"req", "-key", key, "-subj", "CN=node1", "-ip", "10.0.0"}},
+		{"argument", []string{"req", "-key", key, "-subj", "CN=node1", "extra"}},
+	}
+	for _, tt := range tests {
+		if err := run(tt.args...); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestParseSubject(t *testing.T) {
+	got, err := parseSubject(" CN = node1 , O=Example,OU=A,OU=B,C=CN,ST=Beijing,L=Haidian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := pkix.Name{
+		CommonName:         "node1",
+		Organization:       []string{"Example"},
+		OrganizationalUnit: []string{"A", "B"},
+		Country:            []string{"CN"},
+		Province:           []string{"Beijing"},
+		Locality:           []string{"Haidian"},
+	}
+	if got.String() != want.String() {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for _, s := range []string{"", "CN=", "CN=a,,O=b", "X=1"} {
+		if _, err := parseSubject(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestReadPassword(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFile(t, path("pass"), []byte("from file\r\nsecond line\n"))
+	os.Setenv("GMCLI_TEST_PASS", "from env")
+	defer os.Unsetenv("GMCLI_TEST_PASS")
+
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"pass:a:b", "a:b"},
+		{"env:GMCLI_TEST_PASS", "from env"},
+		{"file:" + path("pass"), "from file"},
+	}
+	for _, tt := range tests {
+		got, err := readPassword(tt.arg)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%q: got %q, %v, want %q", tt.arg, got, err, tt.want)
+		}
+	}
+	if got, err := readPassword(""); got != nil || err != nil {
+		t.Errorf("empty: got %q, %v", got, err)
+	}
+	for _, arg := range []string{"secret", "env:GMCLI_TEST_UNSET", "file:" + path("missing"), "stdin:x"} {
+		if _, err := readPassword(arg); err == nil {
+			t.Errorf("%q: no error", arg)
+		}
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	dir, path := tempDir(t)
+	defer os.RemoveAll(dir)
+	key, pub := path("key.pem"), path("key.pub")
+	if err := run("keygen", "-out", key, "-pubout", pub); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := make([]byte, 3*sm4stream.ChunkSize+100)
+	rand.Read(plaintext)
+	in, ct, out := path("plaintext"), path("ct"), path("out")
+	part := out + partSuffix
+	writeFile(t, in, plaintext)
+	if err := run("encrypt-file", "-pub", pub+",testdata/cert.pem", "-in", in, "-out", ct); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(ct + partSuffix); !os.IsNotExist(err) {
+		t.Fatalf("encrypt-file left its part file: %v", err)
+	}
+	ciphertext := readFile(t, ct)
+
+	decrypt := func(in string, resume bool) error {
+		args := []string{"decrypt-file", "-key", key, "-in", in, "-out", out}
+		if resume {
+			args = append(args, "-resume")
+		}
+		return run(args...)
+	}
+	checkOutput := func(name string) {
+		t.Helper()
+		if got := readFile(t, out); !bytes.Equal(got, plaintext) {
+			t.Fatalf("%s: decrypted %d bytes, want %d", name, len(got), len(plaintext))
+		}
+		if _, err := os.Stat(part); !os.IsNotExist(err) {
+			t.Fatalf("%s: part file left: %v", name, err)
+		}
+		os.Remove(out)
+	}
+	checkNoOutput := func(name string) {
+		t.Helper()
+		if _, err := os.Stat(out); !os.IsNotExist(err) {
+			t.Fatalf("%s: output created: %v", name, err)
+		}
+	}
+
+	if err := decrypt(ct, false); err != nil {
+		t.Fatal(err)
+	}
+	checkOutput("round trip")
+
+	// A truncated ciphertext leaves the authenticated chunks in the part
+	// file, and resuming with the whole ciphertext completes it.
+	truncated := path("truncated")
+	writeFile(t, truncated, ciphertext[:len(ciphertext)/2])
+	if err := decrypt(truncated, false); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("truncated: got %v", err)
+	}
+	checkNoOutput("truncated")
+	kept := readFile(t, part)
+	if len(kept) == 0 || len(kept)%sm4stream.ChunkSize != 0 || !bytes.HasPrefix(plaintext, kept) {
+		t.Fatalf("truncated: part file of %d bytes", len(kept))
+	}
+	// The last kept chunk is decrypted again, so a part file that ends in
+	// the middle of a chunk resumes as well.
+	f, _ := os.OpenFile(part, os.O_WRONLY|os.O_APPEND, 0600)
+	f.Write(plaintext[len(kept) : len(kept)+10])
+	f.Close()
+	if err := decrypt(ct, true); err != nil {
+		t.Fatal(err)
+	}
+	checkOutput("resumed")
+
+	// A modified ciphertext removes the part file.
+	corrupted := append([]byte(nil), ciphertext...)
+	corrupted[len(corrupted)-2*sm4stream.ChunkSize] ^= 1
+	writeFile(t, path("corrupted"), corrupted)
+	if err := decrypt(path("corrupted"), false); err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Fatalf("corrupted: got %v", err)
+	}
+	checkNoOutput("corrupted")
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Fatalf("corrupted: part file kept: %v", err)
+	}
+
+	// A part file is only resumed if it holds the plaintext of the
+	// ciphertext: neither a longer one nor one of another file is
+	// completed.
+	tests := []struct {
+		name string
+		part []byte
+		want string
+	}{
+		{"longer part file", append(append([]byte(nil), plaintext...), make([]byte, 2*sm4stream.ChunkSize)...), "longer than the plaintext"},
+		{"other part file", make([]byte, 2*sm4stream.ChunkSize+1), "not decrypted from this ciphertext"},
+	}
+	for _, tt := range tests {
+		writeFile(t, part, tt.part)
+		if err := decrypt(ct, true); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: got %v", tt.name, err)
+		}
+		checkNoOutput(tt.name)
+		os.Remove(part)
+	}
+
+	if err := run("decrypt-file", "-key", key, "-in", ct, "-resume"); err == nil {
+		t.Fatal("resumed to stdout")
+	}
+	if err := run("encrypt-file", "-in", in, "-out", ct); err == nil {
+		t.Fatal("encrypted to no recipient")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// signatureFormats are the values of -format.
+var signatureFormats = map[string]sm2.SignatureFormat{
+	"der": sm2.FormatDER,
+	"raw": sm2.FormatRaw,
+}
+
+func signatureFormat(name string) (sm2.SignatureFormat, error) {
+	f, ok := signatureFormats[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown signature format %q, want der or raw", name)
+	}
+	return f, nil
+}
+
+// readDigest reads a hex SM2 digest e = SM3(ZA || M).
+func readDigest(name string) ([]byte, error) {
+	data, err := readInput(name)
+	if err != nil {
+		return nil, err
+	}
+	e, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(e) != sm3.Size {
+		return nil, fmt.Errorf("digest must be %d hex-encoded bytes", sm3.Size)
+	}
+	return e, nil
+}
+
+func digest(args []string) error {
+	fs := newFlagSet("digest")
+	in := fs.String("in", "", "input `file` (default stdin)")
+	pubFile := fs.String("pub", "", "compute SM3(ZA || M) for the public key or certificate in `file`")
+	uid := fs.String("uid", string(sm2.DefaultUID), "user `ID` hashed into ZA")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	msg, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	if *pubFile == "" {
+		fmt.Println(hex.EncodeToString(sm3.Sm3Sum(msg)))
+		return nil
+	}
+	pub, err := loadPublicKey(*pubFile)
+	if err != nil {
+		return err
+	}
+	za, err := sm2.ZA(pub, []byte(*uid))
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(sm3.Sm3Sum(append(za, msg...))))
+	return nil
+}
+
+func sign(args []string) error {
+	fs := newFlagSet("sign")
+	keyFile := fs.String("key", "", "private key `file`")
+	pass := fs.String("pass", "", "password `source` of an encrypted private key")
+	in := fs.String("in", "", "input `file` (default stdin)")
+	out := fs.String("out", "", "signature `file` (default stdout)")
+	isDigest := fs.Bool("digest", false, "the input is a hex SM2 digest e, as printed by gmcli digest -pub")
+	uid := fs.String("uid", string(sm2.DefaultUID), "user `ID` hashed into ZA")
+	format := fs.String("format", "der", "signature `format`: der or raw (r || s)")
+	hexOut := fs.Bool("hex", false, "hex-encode the signature")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := signatureFormat(*format)
+	if err != nil {
+		return err
+	}
+	priv, err := loadPrivateKey(*keyFile, *pass)
+	if err != nil {
+		return err
+	}
+
+	var der []byte
+	if *isDigest {
+		e, err := readDigest(*in)
+		if err != nil {
+			return err
+		}
+		der, err = priv.Sign(nil, e, sm3.CryptoHash)
+		if err != nil {
+			return err
+		}
+	} else {
+		msg, err := readInput(*in)
+		if err != nil {
+			return err
+		}
+		der, err = sm2.SignEx(priv, msg, sm2.WithUID([]byte(*uid)))
+		if err != nil {
+			return err
+		}
+	}
+
+	var sig sm2.Signature
+	if err := sig.Decode(der); err != nil {
+		return err
+	}
+	enc, err := sig.Encode(f)
+	if err != nil {
+		return err
+	}
+	if *hexOut {
+		enc = []byte(hex.EncodeToString(enc) + "\n")
+	}
+	return writeOutput(*out, enc)
+}
+
+func verify(args []string) error {
+	fs := newFlagSet("verify")
+	pubFile := fs.String("pub", "", "public key or certificate `file`")
+	sigFile := fs.String("sig", "", "signature `file`")
+	in := fs.String("in", "", "input `file` (default stdin)")
+	isDigest := fs.Bool("digest", false, "the input is a hex SM2 digest e, as printed by gmcli digest -pub")
+	uid := fs.String("uid", string(sm2.DefaultUID), "user `ID` hashed into ZA")
+	format := fs.String("format", "der", "signature `format`: der or raw (r || s)")
+	hexIn := fs.Bool("hex", false, "the signature is hex-encoded")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	f, err := signatureFormat(*format)
+	if err != nil {
+		return err
+	}
+	if *sigFile == "" {
+		return errors.New("no signature given")
+	}
+	pub, err := loadPublicKey(*pubFile)
+	if err != nil {
+		return err
+	}
+	enc, err := readInput(*sigFile)
+	if err != nil {
+		return err
+	}
+	if *hexIn {
+		if enc, err = hex.DecodeString(string(bytes.TrimSpace(enc))); err != nil {
+			return errors.New("signature is not hex")
+		}
+	}
+	var sig sm2.Signature
+	if err := sig.DecodeFormat(f, enc); err != nil {
+		return fmt.Errorf("cannot parse signature: %v", err)
+	}
+
+	var ok bool
+	if *isDigest {
+		e, err := readDigest(*in)
+		if err != nil {
+			return err
+		}
+		ok = sm2.Verify(pub, e, sig.R, sig.S)
+	} else {
+		msg, err := readInput(*in)
+		if err != nil {
+			return err
+		}
+		ok = sm2.VerifySignature(pub, msg, &sig, sm2.WithUID([]byte(*uid)))
+	}
+	if !ok {
+		return errVerification
+	}
+	fmt.Fprintln(os.Stdout, "Verified OK")
+	return nil
+}
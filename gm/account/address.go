@@ -9,6 +9,7 @@ import (
 
 	"github.com/xuperchain/crypto/common/utils"
 	"github.com/xuperchain/crypto/gm/base58"
+	"github.com/xuperchain/crypto/gm/bech32"
 	"github.com/xuperchain/crypto/gm/config"
 
 	gmHash "github.com/xuperchain/crypto/gm/hash"
@@ -188,3 +189,103 @@ func CheckAddressFormat(address string) (bool, uint8) {
 
 	return false, 0
 }
+
+var (
+	InvalidPublicKeyError = errors.New("invalid public key")
+	InvalidAddressError   = errors.New("invalid address")
+)
+
+// addressHashLen is the length of RIPEMD160(SM3(publicKey)).
+const addressHashLen = 20
+
+// publicKeyHash returns RIPEMD160(SM3(publicKey)) over the uncompressed public key.
+func publicKeyHash(pub *ecdsa.PublicKey) ([]byte, error) {
+	if pub == nil || pub.Curve == nil || pub.X == nil || pub.Y == nil || !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, InvalidPublicKeyError
+	}
+	data := elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+	return gmHash.HashUsingRipemd160(gmHash.HashUsingSM3(data)), nil
+}
+
+// AddressFromPublicKey returns the base58check address of the public key:
+// base58(version || RIPEMD160(SM3(publicKey)) || SM3(version || hash)[:4]).
+// With the version set to the cryptography flag, e.g. config.Gm, it is the
+// same address as GetAddressFromPublicKey returns.
+// 使用指定的版本号为公钥生成base58check地址
+func AddressFromPublicKey(pub *ecdsa.PublicKey, version byte) (string, error) {
+	hash, err := publicKeyHash(pub)
+	if err != nil {
+		return "", err
+	}
+
+	payload := utils.BytesCombine([]byte{version}, hash)
+	checkCode := gmHash.HashUsingSM3(payload)
+
+	return base58.Encode(utils.BytesCombine(payload, checkCode[:4])), nil
+}
+
+// AddressFromPublicKeyBech32 returns the Bech32 address of the public key,
+// which encodes RIPEMD160(SM3(publicKey)) under the human-readable part hrp,
+// e.g. "xc1..." for hrp "xc".
+// 为公钥生成bech32地址，hrp用于区分不同的链
+func AddressFromPublicKeyBech32(pub *ecdsa.PublicKey, hrp string) (string, error) {
+	hash, err := publicKeyHash(pub)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32.Encode(hrp, hash)
+}
+
+// DecodeAddress validates a base58check address and returns its version and
+// public key hash.
+// 校验base58check地址的格式和校验码，返回版本号和公钥哈希
+func DecodeAddress(address string) (version byte, hash []byte, err error) {
+	slice := base58.Decode(address)
+	if len(slice) != 1+addressHashLen+4 {
+		return 0, nil, InvalidAddressError
+	}
+
+	payload := slice[:len(slice)-4]
+	checkCode := gmHash.HashUsingSM3(payload)
+	if !utils.BytesCompare(checkCode[:4], slice[len(slice)-4:]) {
+		return 0, nil, InvalidAddressError
+	}
+
+	return payload[0], payload[1:], nil
+}
+
+// DecodeBech32Address validates a Bech32 address and returns its
+// human-readable part and public key hash.
+// 校验bech32地址的格式和校验码，返回hrp和公钥哈希
+func DecodeBech32Address(address string) (hrp string, hash []byte, err error) {
+	hrp, hash, err = bech32.Decode(address)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(hash) != addressHashLen {
+		return "", nil, InvalidAddressError
+	}
+
+	return hrp, hash, nil
+}
+
+// VerifyAddress checks that the address, in either base58check or Bech32
+// form, belongs to the public key.
+// 验证base58check或bech32地址是否和指定的公钥match
+func VerifyAddress(address string, pub *ecdsa.PublicKey) bool {
+	want, err := publicKeyHash(pub)
+	if err != nil {
+		return false
+	}
+
+	_, hash, err := DecodeAddress(address)
+	if err != nil {
+		_, hash, err = DecodeBech32Address(address)
+		if err != nil {
+			return false
+		}
+	}
+
+	return utils.BytesCompare(hash, want)
+}
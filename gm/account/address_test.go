@@ -0,0 +1,81 @@
+package account
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/config"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// The public key of the private key 1 is the base point.
+func basePointKey() *ecdsa.PublicKey {
+	curve := sm2.P256Sm2()
+	return &ecdsa.PublicKey{Curve: curve, X: curve.Params().Gx, Y: curve.Params().Gy}
+}
+
+func TestAddressFromPublicKey(t *testing.T) {
+	pub := basePointKey()
+	const wantHash = "70cd2c2f5cdfd2f105beaa89634b6e0dba3c9371"
+
+	tests := []struct {
+		version byte
+		want    string
+	}{
+		{config.Gm, "yxeSLxrEvwYAHKfLxdWGg1CZa6jvpgm6g"},
+		{0x35, "NWCQetAYT8aDsPS5bMcm14tKfJErADYua3"},
+	}
+	for _, tt := range tests {
+		addr, err := AddressFromPublicKey(pub, tt.version)
+		if err != nil || addr != tt.want {
+			t.Fatalf("version %#x: got %q, %v, want %q", tt.version, addr, err, tt.want)
+		}
+		version, hash, err := DecodeAddress(addr)
+		if err != nil || version != tt.version || hex.EncodeToString(hash) != wantHash {
+			t.Fatalf("DecodeAddress(%q) = %#x, %x, %v", addr, version, hash, err)
+		}
+		if !VerifyAddress(addr, pub) {
+			t.Fatalf("VerifyAddress(%q) = false", addr)
+		}
+	}
+	if legacy, _ := GetAddressFromPublicKey(pub); legacy != tests[0].want {
+		t.Fatalf("GetAddressFromPublicKey = %q, want %q", legacy, tests[0].want)
+	}
+
+	tampered := []byte(tests[0].want)
+	tampered[5]++
+	if _, _, err := DecodeAddress(string(tampered)); err != InvalidAddressError {
+		t.Fatalf("tampered address: got %v", err)
+	}
+	if VerifyAddress(string(tampered), pub) {
+		t.Fatal("tampered address verifies")
+	}
+}
+
+func TestAddressFromPublicKeyBech32(t *testing.T) {
+	pub := basePointKey()
+	const want = "xc1wrxjct6umlf0zpd742ykxjmwpkareym3v84uv8"
+	addr, err := AddressFromPublicKeyBech32(pub, "xc")
+	if err != nil || addr != want {
+		t.Fatalf("got %q, %v, want %q", addr, err, want)
+	}
+	hrp, hash, err := DecodeBech32Address(addr)
+	if err != nil || hrp != "xc" || hex.EncodeToString(hash) != "70cd2c2f5cdfd2f105beaa89634b6e0dba3c9371" {
+		t.Fatalf("DecodeBech32Address = %q, %x, %v", hrp, hash, err)
+	}
+	if !VerifyAddress(addr, pub) {
+		t.Fatal("VerifyAddress = false")
+	}
+
+	other, _ := sm2.GenerateKey()
+	if VerifyAddress(addr, &ecdsa.PublicKey{Curve: other.Curve, X: other.X, Y: other.Y}) {
+		t.Fatal("address verifies under another key")
+	}
+	if VerifyAddress(want[:len(want)-1]+"9", pub) {
+		t.Fatal("tampered address verifies")
+	}
+	if _, err := AddressFromPublicKeyBech32(&ecdsa.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.X}, "xc"); err != InvalidPublicKeyError {
+		t.Fatalf("off-curve key: got %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package account
+
+import (
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuperchain/crypto/gm/config"
+	"github.com/xuperchain/crypto/gm/gmsm/scryptsm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// 加密私钥文件(keystore)的格式，参考以太坊的Web3 Secret Storage:
+//
+//	{
+//		"version": 1,
+//		"address": "<GetAddressFromPublicKey>",
+//		"crypto": {
+//			"cipher": "sm4-gcm",
+//			"ciphertext": "<hex>",
+//			"cipherparams": {"nonce": "<hex>"},
+//			"kdf": "scrypt-sm3",
+//			"kdfparams": {"n": 262144, "r": 8, "p": 1, "dklen": 16, "salt": "<hex>"}
+//		}
+//	}
+//
+// 私钥D以32字节大端序编码，使用scrypt-SM3从支付密码派生的密钥进行SM4-GCM加密，
+// 地址作为附加认证数据，因此地址被篡改时也无法解密。
+const (
+	// KeystoreVersion is the version of the keystore format written by EncryptKey.
+	KeystoreVersion = 1
+
+	keystoreCipher = "sm4-gcm"
+	keystoreKDF    = "scrypt-sm3"
+
+	// 私钥文件的文件名
+	KeystoreFilename = "keystore"
+)
+
+// scrypt参数，标准参数大约需要数百毫秒，轻量参数适用于移动端等资源受限的环境
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+
+	LightScryptN = 1 << 12
+	LightScryptP = 6
+
+	keystoreScryptR = 8
+	keystoreSaltLen = 32
+
+	// 解密时允许的最大scrypt参数，防止恶意文件消耗过多的内存和时间
+	maxKeystoreScryptN = 1 << 20
+	maxKeystoreScryptP = 16
+)
+
+var (
+	// 支付密码错误或者文件被篡改
+	ErrKeystorePassword = errors.New("could not decrypt key with given password")
+	// 不支持的keystore版本、加密算法或者参数
+	ErrKeystoreFormat = errors.New("unsupported keystore format")
+)
+
+type keystoreJSON struct {
+	Version int            `json:"version"`
+	Address string         `json:"address"`
+	Crypto  keystoreCrypto `json:"crypto"`
+}
+
+type keystoreCrypto struct {
+	Cipher       string               `json:"cipher"`
+	CipherText   string               `json:"ciphertext"`
+	CipherParams keystoreCipherParams `json:"cipherparams"`
+	KDF          string               `json:"kdf"`
+	KDFParams    keystoreKDFParams    `json:"kdfparams"`
+}
+
+type keystoreCipherParams struct {
+	Nonce string `json:"nonce"`
+}
+
+type keystoreKDFParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+func keystoreAEAD(password string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scryptsm3.Key([]byte(password), salt, n, r, p, sm4.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptKey 使用支付密码加密SM2私钥，返回keystore格式的json
+// scryptN和scryptP一般使用StandardScryptN、StandardScryptP或者LightScryptN、LightScryptP
+func EncryptKey(privateKey *ecdsa.PrivateKey, password string, scryptN, scryptP int) ([]byte, error) {
+	if privateKey == nil || privateKey.Curve == nil || privateKey.Params().Name != config.CurveGm {
+		return nil, ErrCryptographyNotSupported
+	}
+	n := privateKey.Params().N
+	if privateKey.D == nil || privateKey.D.Sign() <= 0 || privateKey.D.Cmp(n) >= 0 {
+		return nil, KeyParamNotMatchError
+	}
+
+	address, err := GetAddressFromPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, keystoreSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := keystoreAEAD(password, salt, scryptN, keystoreScryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	d := make([]byte, 32)
+	dBytes := privateKey.D.Bytes()
+	copy(d[32-len(dBytes):], dBytes)
+	cipherText := aead.Seal(nil, nonce, d, []byte(address))
+	for i := range d {
+		d[i] = 0
+	}
+
+	key := keystoreJSON{
+		Version: KeystoreVersion,
+		Address: address,
+		Crypto: keystoreCrypto{
+			Cipher:       keystoreCipher,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: keystoreCipherParams{Nonce: hex.EncodeToString(nonce)},
+			KDF:          keystoreKDF,
+			KDFParams: keystoreKDFParams{
+				N:     scryptN,
+				R:     keystoreScryptR,
+				P:     scryptP,
+				DKLen: sm4.KeySize,
+				Salt:  hex.EncodeToString(salt),
+			},
+		},
+	}
+
+	return json.MarshalIndent(key, "", "  ")
+}
+
+// DecryptKey 使用支付密码解密keystore格式的json，返回SM2私钥
+func DecryptKey(keyJSON []byte, password string) (*ecdsa.PrivateKey, error) {
+	key := new(keystoreJSON)
+	if err := json.Unmarshal(keyJSON, key); err != nil {
+		return nil, err
+	}
+
+	c := key.Crypto
+	kp := c.KDFParams
+	if key.Version != KeystoreVersion || c.Cipher != keystoreCipher || c.KDF != keystoreKDF ||
+		kp.DKLen != sm4.KeySize || kp.N > maxKeystoreScryptN || kp.P > maxKeystoreScryptP || kp.R != keystoreScryptR {
+		return nil, ErrKeystoreFormat
+	}
+
+	salt, err := hex.DecodeString(kp.Salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(c.CipherParams.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := keystoreAEAD(password, salt, kp.N, kp.R, kp.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrKeystoreFormat
+	}
+	d, err := aead.Open(nil, nonce, cipherText, []byte(key.Address))
+	if err != nil {
+		return nil, ErrKeystorePassword
+	}
+
+	curve := sm2.P256Sm2()
+	privateKey := new(ecdsa.PrivateKey)
+	privateKey.Curve = curve
+	privateKey.D = new(big.Int).SetBytes(d)
+	for i := range d {
+		d[i] = 0
+	}
+	if privateKey.D.Sign() <= 0 || privateKey.D.Cmp(curve.Params().N) >= 0 {
+		return nil, KeyParamNotMatchError
+	}
+	privateKey.X, privateKey.Y = curve.ScalarBaseMult(privateKey.D.Bytes())
+
+	// 地址已经作为附加认证数据，这里再确认地址和私钥是匹配的
+	if ok, _ := VerifyAddressUsingPublicKey(key.Address, &privateKey.PublicKey); !ok {
+		return nil, KeyParamNotMatchError
+	}
+
+	return privateKey, nil
+}
+
+// StoreKey 使用支付密码加密私钥，并保存到filename指定的文件中
+// 文件只对当前用户可读写；如果文件已存在，将被覆盖
+func StoreKey(filename string, privateKey *ecdsa.PrivateKey, password string) error {
+	keyJSON, err := EncryptKey(privateKey, password, StandardScryptN, StandardScryptP)
+	if err != nil {
+		return err
+	}
+
+	return writeKeyFile(filename, keyJSON)
+}
+
+// LoadKey 从filename指定的keystore文件中读取并使用支付密码解密私钥
+func LoadKey(filename string, password string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := readFileUsingFilename(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptKey(keyJSON, password)
+}
+
+// ChangeKeyPassword 修改keystore文件的支付密码
+// 私钥使用新的salt和nonce重新加密，新文件写入完成后才替换旧文件，
+// 因此中途失败不会导致私钥丢失
+func ChangeKeyPassword(filename string, oldPassword, newPassword string) error {
+	keyJSON, err := readFileUsingFilename(filename)
+	if err != nil {
+		return err
+	}
+
+	key := new(keystoreJSON)
+	if err := json.Unmarshal(keyJSON, key); err != nil {
+		return err
+	}
+	privateKey, err := DecryptKey(keyJSON, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	// 沿用原文件的scrypt参数
+	newKeyJSON, err := EncryptKey(privateKey, newPassword, key.Crypto.KDFParams.N, key.Crypto.KDFParams.P)
+	if err != nil {
+		return err
+	}
+
+	return writeKeyFile(filename, newKeyJSON)
+}
+
+// ExportNewAccountWithPassword 与ExportNewAccount相同，但是私钥使用支付密码加密后
+// 保存为keystore文件，不再以明文保存private.key
+func ExportNewAccountWithPassword(path string, privateKey *ecdsa.PrivateKey, password string) error {
+	jsonPublicKey, err := GetEcdsaPublicKeyJsonFormat(privateKey)
+	if err != nil {
+		return err
+	}
+	address, err := GetAddressFromPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return err
+	}
+	//如果path不是以/结尾的，自动拼上
+	if strings.LastIndex(path, "/") != len([]rune(path))-1 {
+		path = path + "/"
+	}
+	err = StoreKey(path+KeystoreFilename, privateKey, password)
+	if err != nil {
+		return err
+	}
+
+	err = writeFileUsingFilename(path+"public.key", []byte(jsonPublicKey))
+	if err != nil {
+		return err
+	}
+
+	return writeFileUsingFilename(path+"address", []byte(address))
+}
+
+// writeKeyFile 先写入同目录下的临时文件，再重命名为filename，保证文件内容完整
+func writeKeyFile(filename string, content []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
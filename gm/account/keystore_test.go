@@ -0,0 +1,120 @@
+package account
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// testKeystore is the keystore of the private key 1 under the password
+// "correct horse", computed with an independent implementation.
+const testKeystore = `{
+  "version": 1,
+  "address": "yxeSLxrEvwYAHKfLxdWGg1CZa6jvpgm6g",
+  "crypto": {
+    "cipher": "sm4-gcm",
+    "ciphertext": "fa33ee397b37f162bd596dcada6b41296ccb955903d506a18af37ee33a7de98e5c8a805d4a064711d6d2a34d48862d0b",
+    "cipherparams": {"nonce": "a0a1a2a3a4a5a6a7a8a9aaab"},
+    "kdf": "scrypt-sm3",
+    "kdfparams": {"n": 1024, "r": 8, "p": 1, "dklen": 16, "salt": "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"}
+  }
+}`
+
+func TestDecryptKey(t *testing.T) {
+	priv, err := DecryptKey([]byte(testKeystore), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := sm2.P256Sm2().Params()
+	if priv.D.Int64() != 1 || priv.X.Cmp(g.Gx) != 0 || priv.Y.Cmp(g.Gy) != 0 {
+		t.Fatalf("got D = %v", priv.D)
+	}
+
+	for _, password := range []string{"", "correct horse ", "Correct horse"} {
+		if _, err := DecryptKey([]byte(testKeystore), password); err != ErrKeystorePassword {
+			t.Errorf("password %q: got %v, want ErrKeystorePassword", password, err)
+		}
+	}
+}
+
+// modifyKeystore returns testKeystore after applying f to its decoding.
+func modifyKeystore(t *testing.T, f func(k *keystoreJSON)) []byte {
+	k := new(keystoreJSON)
+	if err := json.Unmarshal([]byte(testKeystore), k); err != nil {
+		t.Fatal(err)
+	}
+	f(k)
+	b, err := json.Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecryptKeyTampered(t *testing.T) {
+	// The address is authenticated, so changing it is a decryption failure.
+	other, _ := ecdsa.GenerateKey(sm2.P256Sm2(), rand.Reader)
+	otherAddress, _ := GetAddressFromPublicKey(&other.PublicKey)
+	tests := []struct {
+		name string
+		f    func(k *keystoreJSON)
+		err  error
+	}{
+		{"address", func(k *keystoreJSON) { k.Address = otherAddress }, ErrKeystorePassword},
+		{"ciphertext", func(k *keystoreJSON) { k.Crypto.CipherText = "0" + k.Crypto.CipherText[1:] }, ErrKeystorePassword},
+		{"version", func(k *keystoreJSON) { k.Version = 2 }, ErrKeystoreFormat},
+		{"cipher", func(k *keystoreJSON) { k.Crypto.Cipher = "aes-128-gcm" }, ErrKeystoreFormat},
+		{"scrypt n", func(k *keystoreJSON) { k.Crypto.KDFParams.N = 1 << 21 }, ErrKeystoreFormat},
+		{"scrypt r", func(k *keystoreJSON) { k.Crypto.KDFParams.R = 1 }, ErrKeystoreFormat},
+		{"nonce", func(k *keystoreJSON) { k.Crypto.CipherParams.Nonce = "a0a1" }, ErrKeystoreFormat},
+	}
+	for _, tt := range tests {
+		if _, err := DecryptKey(modifyKeystore(t, tt.f), "correct horse"); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if _, err := DecryptKey(modifyKeystore(t, func(k *keystoreJSON) { k.Crypto.KDFParams.P = 0 }), "correct horse"); err == nil {
+		t.Error("scrypt p = 0 accepted")
+	}
+}
+
+func TestChangeKeyPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	priv, _ := ecdsa.GenerateKey(sm2.P256Sm2(), rand.Reader)
+	keyJSON, err := EncryptKey(priv, "old", 1<<10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, KeystoreFilename)
+	if err := writeKeyFile(filename, keyJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ChangeKeyPassword(filename, "wrong", "new"); err != ErrKeystorePassword {
+		t.Fatalf("wrong old password: got %v", err)
+	}
+	if err := ChangeKeyPassword(filename, "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(filename, "old"); err != ErrKeystorePassword {
+		t.Fatalf("old password after change: got %v", err)
+	}
+	got, err := LoadKey(filename, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Cmp(priv.D) != 0 {
+		t.Fatal("changing the password changed the key")
+	}
+}
@@ -0,0 +1,206 @@
+package account
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/sign"
+)
+
+var (
+	// 账户未解锁，或者已被自动锁定
+	ErrAccountLocked = errors.New("account is locked")
+	// 签名请求不满足解锁时指定的使用策略
+	ErrPolicyViolation = errors.New("signing request violates the key usage policy")
+)
+
+// KeyPolicy 限制已解锁的私钥的使用方式
+type KeyPolicy struct {
+	// 解锁期间最多可以签名的次数，达到后自动锁定；0表示不限制
+	MaxSignatures int
+	// 如果不为nil，每次签名前使用待签名的消息调用，返回false时拒绝签名
+	Allow func(msg []byte) bool
+}
+
+type unlockedKey struct {
+	key    *ecdsa.PrivateKey
+	policy KeyPolicy
+	used   int
+	timer  *time.Timer
+}
+
+// Manager 在内存中保存已解锁的私钥，供服务端程序代替全局的私钥变量使用。
+// 私钥按地址索引，只能通过Manager签名而不会被返回给调用者；
+// 私钥在超时、达到使用次数或者调用Lock时被锁定，其内容随即被清零。
+// Manager可以被多个goroutine同时使用。
+type Manager struct {
+	mu   sync.Mutex
+	keys map[string]*unlockedKey
+}
+
+// NewManager 创建一个空的账户管理器
+func NewManager() *Manager {
+	return &Manager{keys: make(map[string]*unlockedKey)}
+}
+
+// Unlock 使用支付密码解密keystore格式的json，并在ttl时间内保持解锁，返回账户地址
+// ttl为0表示直到调用Lock才锁定；policy为nil表示不限制使用
+func (m *Manager) Unlock(keyJSON []byte, password string, ttl time.Duration, policy *KeyPolicy) (string, error) {
+	privateKey, err := DecryptKey(keyJSON, password)
+	if err != nil {
+		return "", err
+	}
+	address, err := m.add(privateKey, ttl, policy)
+	if err != nil {
+		zeroKey(privateKey)
+	}
+
+	return address, err
+}
+
+// UnlockKey 与Unlock相同，但是直接使用给定的私钥
+// Manager保存私钥的副本，调用者应自行清理传入的私钥
+func (m *Manager) UnlockKey(privateKey *ecdsa.PrivateKey, ttl time.Duration, policy *KeyPolicy) (string, error) {
+	if privateKey == nil || privateKey.D == nil || privateKey.X == nil || privateKey.Y == nil {
+		return "", KeyParamNotMatchError
+	}
+	keyCopy := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: privateKey.Curve,
+			X:     new(big.Int).Set(privateKey.X),
+			Y:     new(big.Int).Set(privateKey.Y),
+		},
+		D: new(big.Int).Set(privateKey.D),
+	}
+	address, err := m.add(keyCopy, ttl, policy)
+	if err != nil {
+		zeroKey(keyCopy)
+	}
+
+	return address, err
+}
+
+func (m *Manager) add(privateKey *ecdsa.PrivateKey, ttl time.Duration, policy *KeyPolicy) (string, error) {
+	address, err := GetAddressFromPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	k := &unlockedKey{key: privateKey}
+	if policy != nil {
+		k.policy = *policy
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 重复解锁同一个账户时，替换原有的私钥、策略和超时时间
+	m.lock(address)
+	if ttl > 0 {
+		k.timer = time.AfterFunc(ttl, func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			// 账户可能已被重新解锁，此时只锁定本次解锁的私钥
+			if m.keys[address] == k {
+				m.lock(address)
+			}
+		})
+	}
+	m.keys[address] = k
+
+	return address, nil
+}
+
+// Sign 使用地址对应的已解锁私钥对消息签名
+func (m *Manager) Sign(address string, msg []byte) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.keys[address]
+	if !ok {
+		return nil, ErrAccountLocked
+	}
+	if k.policy.Allow != nil && !k.policy.Allow(msg) {
+		return nil, ErrPolicyViolation
+	}
+
+	signature, err := sign.SignECDSA(k.key, msg)
+	if err != nil {
+		return nil, err
+	}
+	k.used++
+	if k.policy.MaxSignatures > 0 && k.used >= k.policy.MaxSignatures {
+		m.lock(address)
+	}
+
+	return signature, nil
+}
+
+// IsUnlocked 返回地址对应的账户是否处于解锁状态
+func (m *Manager) IsUnlocked(address string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.keys[address]
+	return ok
+}
+
+// Accounts 返回所有已解锁账户的地址
+func (m *Manager) Accounts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	addresses := make([]string, 0, len(m.keys))
+	for address := range m.keys {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// Lock 锁定地址对应的账户，并清零其私钥；账户未解锁时不做任何操作
+func (m *Manager) Lock(address string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lock(address)
+}
+
+// LockAll 锁定所有账户，并清零其私钥，一般在程序退出前调用
+func (m *Manager) LockAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for address := range m.keys {
+		m.lock(address)
+	}
+}
+
+// lock 要求调用者持有m.mu
+func (m *Manager) lock(address string) {
+	k, ok := m.keys[address]
+	if !ok {
+		return
+	}
+	if k.timer != nil {
+		k.timer.Stop()
+	}
+	zeroKey(k.key)
+	delete(m.keys, address)
+}
+
+// zeroKey 清零私钥D所占用的内存
+func zeroKey(privateKey *ecdsa.PrivateKey) {
+	if privateKey.D == nil {
+		return
+	}
+	words := privateKey.D.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	privateKey.D.SetInt64(0)
+}
@@ -0,0 +1,123 @@
+package account
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/sign"
+)
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	priv, err := ecdsa.GenerateKey(sm2.P256Sm2(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+// waitLocked waits for the timer of address to lock it.
+func waitLocked(m *Manager, address string) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if !m.IsUnlocked(address) {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestManagerExpiry(t *testing.T) {
+	m := NewManager()
+	address, err := m.UnlockKey(newTestKey(t), 20*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.mu.Lock()
+	held := m.keys[address].key
+	m.mu.Unlock()
+
+	if _, err := m.Sign(address, []byte("msg")); err != nil {
+		t.Fatalf("sign before expiry: %v", err)
+	}
+	if !waitLocked(m, address) {
+		t.Fatal("account still unlocked after its ttl")
+	}
+	if held.D.Sign() != 0 {
+		t.Fatal("expired key not zeroized")
+	}
+	if _, err := m.Sign(address, []byte("msg")); err != ErrAccountLocked {
+		t.Fatalf("sign after expiry: got %v, want ErrAccountLocked", err)
+	}
+}
+
+// Unlocking an account again replaces its timer, so the timer of the first
+// unlock does not lock the second.
+func TestManagerRelock(t *testing.T) {
+	m := NewManager()
+	priv := newTestKey(t)
+	address, _ := m.UnlockKey(priv, 10*time.Millisecond, nil)
+	if _, err := m.UnlockKey(priv, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !m.IsUnlocked(address) {
+		t.Fatal("the replaced timer locked the account")
+	}
+	if priv.D.Sign() == 0 {
+		t.Fatal("UnlockKey zeroized the caller's key")
+	}
+
+	m.Lock(address)
+	if m.IsUnlocked(address) || len(m.Accounts()) != 0 {
+		t.Fatal("Lock left the account unlocked")
+	}
+}
+
+func TestManagerPolicy(t *testing.T) {
+	m := NewManager()
+	priv := newTestKey(t)
+	policy := &KeyPolicy{
+		MaxSignatures: 2,
+		Allow:         func(msg []byte) bool { return !bytes.HasPrefix(msg, []byte("transfer")) },
+	}
+	address, _ := m.UnlockKey(priv, 0, policy)
+
+	if _, err := m.Sign(address, []byte("transfer 100")); err != ErrPolicyViolation {
+		t.Fatalf("disallowed message: got %v, want ErrPolicyViolation", err)
+	}
+	for i := 0; i < 2; i++ {
+		sig, err := m.Sign(address, []byte("vote"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := sign.VerifyECDSA(&priv.PublicKey, sig, []byte("vote")); !ok {
+			t.Fatal("signature does not verify")
+		}
+	}
+	if _, err := m.Sign(address, []byte("vote")); err != ErrAccountLocked {
+		t.Fatalf("after MaxSignatures: got %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestManagerUnlock(t *testing.T) {
+	m := NewManager()
+	if _, err := m.Unlock([]byte(testKeystore), "wrong", 0, nil); err != ErrKeystorePassword {
+		t.Fatalf("wrong password: got %v", err)
+	}
+	address, err := m.Unlock([]byte(testKeystore), "correct horse", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "yxeSLxrEvwYAHKfLxdWGg1CZa6jvpgm6g" || !m.IsUnlocked(address) {
+		t.Fatalf("unlocked %q", address)
+	}
+	m.LockAll()
+	if m.IsUnlocked(address) {
+		t.Fatal("LockAll left the account unlocked")
+	}
+}
@@ -0,0 +1,161 @@
+// Package bech32 implements the Bech32 encoding of BIP173.
+//
+// References:
+//
+//	[BIP173]: BIP0173 - Base32 address format for native v0-16 witness outputs
+//	https://github.com/bitcoin/bips/blob/master/bip-0173.mediawiki
+package bech32
+
+import (
+	"errors"
+	"strings"
+)
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// The length limit of an encoded string, 90 characters as in BIP173.
+const maxLength = 90
+
+var (
+	ErrInvalidLength    = errors.New("bech32: invalid length")
+	ErrInvalidCharacter = errors.New("bech32: invalid character")
+	ErrMixedCase        = errors.New("bech32: mixed case")
+	ErrInvalidChecksum  = errors.New("bech32: invalid checksum")
+	ErrInvalidPadding   = errors.New("bech32: invalid padding")
+)
+
+var generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	b := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]>>5)
+	}
+	b = append(b, 0)
+	for i := 0; i < len(hrp); i++ {
+		b = append(b, hrp[i]&31)
+	}
+	return b
+}
+
+func checksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ 1
+	sum := make([]byte, 6)
+	for i := range sum {
+		sum[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+	return sum
+}
+
+// convertBits regroups data from fromBits-bit groups into toBits-bit groups.
+// When pad is false, the leftover bits must be fewer than fromBits and zero.
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, v := range data {
+		if uint32(v)>>fromBits != 0 {
+			return nil, ErrInvalidCharacter
+		}
+		acc = acc<<fromBits | uint32(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, ErrInvalidPadding
+	}
+	return out, nil
+}
+
+// Encode encodes data as a lowercase Bech32 string with the human-readable
+// part hrp.
+func Encode(hrp string, data []byte) (string, error) {
+	values, err := convertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	if len(hrp) < 1 || len(hrp)+1+len(values)+6 > maxLength {
+		return "", ErrInvalidLength
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", ErrInvalidCharacter
+		}
+	}
+	if strings.ToLower(hrp) != hrp {
+		return "", ErrMixedCase
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(hrp) + 1 + len(values) + 6)
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range append(values, checksum(hrp, values)...) {
+		sb.WriteByte(charset[v])
+	}
+	return sb.String(), nil
+}
+
+// Decode decodes a Bech32 string and returns its human-readable part, in
+// lowercase, and its data.
+func Decode(s string) (string, []byte, error) {
+	if len(s) < 8 || len(s) > maxLength {
+		return "", nil, ErrInvalidLength
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, ErrInvalidCharacter
+		}
+	}
+	lower := strings.ToLower(s)
+	if lower != s && strings.ToUpper(s) != s {
+		return "", nil, ErrMixedCase
+	}
+
+	sep := strings.LastIndexByte(lower, '1')
+	if sep < 1 || sep+7 > len(lower) {
+		return "", nil, ErrInvalidLength
+	}
+	hrp := lower[:sep]
+	values := make([]byte, 0, len(lower)-sep-1)
+	for i := sep + 1; i < len(lower); i++ {
+		v := strings.IndexByte(charset, lower[i])
+		if v < 0 {
+			return "", nil, ErrInvalidCharacter
+		}
+		values = append(values, byte(v))
+	}
+	if polymod(append(hrpExpand(hrp), values...)) != 1 {
+		return "", nil, ErrInvalidChecksum
+	}
+
+	data, err := convertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
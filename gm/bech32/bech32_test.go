@@ -0,0 +1,114 @@
+package bech32
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// The valid and invalid checksums of BIP173.
+var validChecksums = []string{
+	"A12UEL5L",
+	"a12uel5l",
+	"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+	"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+	"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	"?1ezyfcl",
+}
+
+var invalidChecksums = []struct {
+	s   string
+	err error
+}{
+	{"\x201nwldj5", ErrInvalidCharacter},
+	{"\x7f1axkwrx", ErrInvalidCharacter},
+	{"\x801eym55h", ErrInvalidCharacter},
+	{"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx", ErrInvalidLength},
+	{"pzry9x0s0muk", ErrInvalidLength},
+	{"1pzry9x0s0muk", ErrInvalidLength},
+	{"x1b4n0q5v", ErrInvalidCharacter},
+	{"li1dgmt3", ErrInvalidLength},
+	{"de1lg7wt\xff", ErrInvalidCharacter},
+	{"A1G7SGD8", ErrInvalidChecksum},
+	{"10a06t8", ErrInvalidLength},
+	{"1qzzfhee", ErrInvalidLength},
+	{"a12UEL5L", ErrMixedCase},
+}
+
+func TestChecksum(t *testing.T) {
+	for _, s := range validChecksums {
+		hrp, _, err := Decode(s)
+		// Some vectors only test the checksum and do not hold whole bytes.
+		if err != nil && err != ErrInvalidPadding {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if err == nil && hrp != strings.ToLower(s[:strings.LastIndexByte(s, '1')]) {
+			t.Errorf("%q: hrp %q", s, hrp)
+		}
+	}
+	for _, tt := range invalidChecksums {
+		if _, _, err := Decode(tt.s); err != tt.err {
+			t.Errorf("%q: got %v, want %v", tt.s, err, tt.err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	data := make([]byte, 20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	tests := []struct {
+		hrp  string
+		data []byte
+		want string
+	}{
+		{"a", nil, "a12uel5l"},
+		{"test", []byte{0xff}, "test1lu0zy72x"},
+		{"xc", data, "xc1qqqsyqcyq5rqwzqfpg9scrgwpugpzysn2tzgk7"},
+	}
+	for _, tt := range tests {
+		s, err := Encode(tt.hrp, tt.data)
+		if err != nil || s != tt.want {
+			t.Errorf("Encode(%q, %x) = %q, %v, want %q", tt.hrp, tt.data, s, err, tt.want)
+			continue
+		}
+		for _, in := range []string{s, strings.ToUpper(s)} {
+			hrp, got, err := Decode(in)
+			if err != nil || hrp != tt.hrp || !bytes.Equal(got, tt.data) {
+				t.Errorf("Decode(%q) = %q, %x, %v", in, hrp, got, err)
+			}
+		}
+	}
+
+	if _, err := Encode("XC", data); err != ErrMixedCase {
+		t.Errorf("uppercase hrp: got %v", err)
+	}
+	if _, err := Encode("", data); err != ErrInvalidLength {
+		t.Errorf("empty hrp: got %v", err)
+	}
+	if _, err := Encode("xc", make([]byte, 60)); err != ErrInvalidLength {
+		t.Errorf("too long: got %v", err)
+	}
+}
+
+// Bech32 detects every substitution of a single character.
+func TestTamper(t *testing.T) {
+	s := "xc1qqqsyqcyq5rqwzqfpg9scrgwpugpzysn2tzgk7"
+	for i := strings.LastIndexByte(s, '1') + 1; i < len(s); i++ {
+		for _, c := range charset {
+			if byte(c) == s[i] {
+				continue
+			}
+			tampered := s[:i] + string(c) + s[i+1:]
+			if _, _, err := Decode(tampered); err != ErrInvalidChecksum {
+				t.Fatalf("%q: got %v, want ErrInvalidChecksum", tampered, err)
+			}
+		}
+	}
+	if _, _, err := Decode("xd" + s[2:]); err != ErrInvalidChecksum {
+		t.Fatalf("changed hrp: got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package audit reports operations on private keys to a pluggable Hook, so
+// that compliance logging does not have to wrap every call site.
+//
+// Once a Hook is installed with SetHook, sm2 reports every key generation,
+// signature, decryption and private key export, and the keys of the kms,
+// p11, sdf and piv packages every signature and decryption. Chain is a Hook
+// writing a tamper-evident trail: each record carries the SM3 hash of its
+// predecessor, so VerifyChain detects records that were altered, removed
+// or reordered.
+package audit
+
+import (
+	"encoding/hex"
+	"math/big"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Operation is the kind of an audited key operation.
+type Operation string
+
+const (
+	OpGenerateKey Operation = "generate-key"
+	OpSign        Operation = "sign"
+	OpDecrypt     Operation = "decrypt"
+	OpExportKey   Operation = "export-key"
+)
+
+// The backends holding the keys.
+const (
+	BackendSoftware = "software"
+	BackendKMS      = "kms"
+	BackendPKCS11   = "pkcs11"
+	BackendSDF      = "sdf"
+	BackendPIV      = "piv"
+)
+
+// Event describes one key operation.
+type Event struct {
+	Op      Operation
+	Backend string
+	// KeyID identifies the key, see KeyID; it is empty if the operation
+	// failed before the key was known.
+	KeyID string
+	// Caller is the function, file and line that called into the crypto
+	// packages.
+	Caller string
+	Time   time.Time
+	// Err is the result of the operation, nil on success.
+	Err error
+}
+
+// Hook receives the events. It is called synchronously by the operation,
+// from any goroutine, so it must be fast and safe for concurrent use.
+type Hook interface {
+	Audit(e *Event)
+}
+
+// HookFunc adapts a function to a Hook.
+type HookFunc func(e *Event)
+
+func (f HookFunc) Audit(e *Event) { f(e) }
+
+// hookHolder wraps the hook so that atomic.Value always stores one concrete
+// type.
+type hookHolder struct {
+	h Hook
+}
+
+var hook atomic.Value
+
+// SetHook installs h as the hook of all key operations; nil removes it.
+func SetHook(h Hook) {
+	hook.Store(hookHolder{h})
+}
+
+// Enabled reports whether a hook is installed.
+func Enabled() bool {
+	h, _ := hook.Load().(hookHolder)
+	return h.h != nil
+}
+
+// Record reports an operation on the key with public point (x, y) to the
+// hook. It is called by the packages implementing the operations, and costs
+// nothing when no hook is installed.
+func Record(op Operation, backend string, x, y *big.Int, err error) {
+	h, _ := hook.Load().(hookHolder)
+	if h.h == nil {
+		return
+	}
+	h.h.Audit(&Event{
+		Op:      op,
+		Backend: backend,
+		KeyID:   KeyID(x, y),
+		Caller:  caller(),
+		Time:    time.Now(),
+		Err:     err,
+	})
+}
+
+// KeyID is the hex SM3 hash of the uncompressed public point, the same
+// for a key whatever backend holds it, or "" if x or y is nil.
+func KeyID(x, y *big.Int) string {
+	if x == nil || y == nil {
+		return ""
+	}
+	point := make([]byte, 65)
+	point[0] = 4
+	xBytes, yBytes := x.Bytes(), y.Bytes()
+	copy(point[33-len(xBytes):33], xBytes)
+	copy(point[65-len(yBytes):], yBytes)
+	return hex.EncodeToString(sm3.Sm3Sum(point))
+}
+
+// cryptoPrefix is the import path prefix of the packages whose frames are
+// skipped to find the caller.
+const cryptoPrefix = "github.com/xuperchain/crypto/gm/gmsm/"
+
+// caller returns the first frame outside this module's gmsm packages, or
+// the outermost frame if they call each other only.
+func caller() string {
+	pc := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(3, pc)])
+	var last runtime.Frame
+	for {
+		f, more := frames.Next()
+		if f.Function != "" {
+			last = f
+		}
+		// Tests of the packages count as callers.
+		if !strings.HasPrefix(f.Function, cryptoPrefix) || strings.HasSuffix(f.File, "_test.go") {
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	if last.Function == "" {
+		return ""
+	}
+	return last.Function + " " + last.File + ":" + strconv.Itoa(last.Line)
+}
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	var events []*Event
+	SetHook(HookFunc(func(e *Event) { events = append(events, e) }))
+	defer SetHook(nil)
+
+	x, y := big.NewInt(1), big.NewInt(2)
+	Record(OpSign, BackendSoftware, x, y, nil)
+	Record(OpDecrypt, BackendKMS, nil, nil, errors.New("denied"))
+	if len(events) != 2 {
+		t.Fatalf("%d events", len(events))
+	}
+	e := events[0]
+	if e.Op != OpSign || e.Backend != BackendSoftware || e.KeyID != KeyID(x, y) || len(e.KeyID) != 64 || e.Err != nil {
+		t.Fatalf("event %+v", e)
+	}
+	if !strings.Contains(e.Caller, "TestRecord") || !strings.Contains(e.Caller, "audit_test.go:") {
+		t.Fatalf("caller %q", e.Caller)
+	}
+	if events[1].KeyID != "" || events[1].Err == nil {
+		t.Fatalf("event %+v", events[1])
+	}
+
+	SetHook(nil)
+	if Enabled() {
+		t.Fatal("the hook was not removed")
+	}
+	Record(OpSign, BackendSoftware, x, y, nil)
+	if len(events) != 2 {
+		t.Fatal("an event was recorded without a hook")
+	}
+}
+
+func writeTrail(t *testing.T, c *Chain, n int) {
+	for i := 0; i < n; i++ {
+		var err error
+		if i%2 == 1 {
+			err = errors.New("decryption failed")
+		}
+		c.Audit(&Event{
+			Op:      OpDecrypt,
+			Backend: BackendSoftware,
+			KeyID:   KeyID(big.NewInt(int64(i)), big.NewInt(1)),
+			Caller:  "main.main",
+			Time:    time.Unix(int64(i), 0),
+			Err:     err,
+		})
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewChain(&buf, 0, nil)
+	writeTrail(t, c, 4)
+	seq, head := c.Head()
+	if seq != 4 {
+		t.Fatalf("head at %d", seq)
+	}
+	trail := buf.String()
+
+	gotSeq, got, err := VerifyChain(strings.NewReader(trail), 0, nil)
+	if err != nil || gotSeq != 4 || !bytes.Equal(got, head) {
+		t.Fatalf("VerifyChain returned %d, %x, %v", gotSeq, got, err)
+	}
+
+	// The trail continues across chains.
+	c2 := NewChain(&buf, seq, head)
+	writeTrail(t, c2, 2)
+	if gotSeq, _, err := VerifyChain(&buf, 0, nil); err != nil || gotSeq != 6 {
+		t.Fatalf("VerifyChain returned %d, %v", gotSeq, err)
+	}
+
+	lines := strings.SplitAfter(trail, "\n")
+	for name, tampered := range map[string]string{
+		"altered":   strings.Replace(trail, `"result":"decryption failed"`, `"result":"ok"`, 1),
+		"removed":   lines[0] + lines[2] + lines[3],
+		"reordered": lines[1] + lines[0] + lines[2] + lines[3],
+	} {
+		if _, _, err := VerifyChain(strings.NewReader(tampered), 0, nil); err != ErrChain {
+			t.Errorf("%s entry: expected ErrChain, got %v", name, err)
+		}
+	}
+	// Truncation is only visible against the anchored head.
+	if _, got, err := VerifyChain(strings.NewReader(lines[0]+lines[1]), 0, nil); err != nil || bytes.Equal(got, head) {
+		t.Fatal("a truncated trail matches the head")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestChainWriteError(t *testing.T) {
+	c := NewChain(failingWriter{}, 0, nil)
+	c.Audit(&Event{Op: OpSign})
+	if c.Err() == nil {
+		t.Fatal("the write error was lost")
+	}
+	if seq, _ := c.Head(); seq != 0 {
+		t.Fatal("the head moved past a failed write")
+	}
+}
@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+var ErrChain = errors.New("audit: hash chain broken")
+
+// Entry is a line of the trail written by Chain.
+type Entry struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Op      Operation `json:"op"`
+	Backend string    `json:"backend"`
+	KeyID   string    `json:"keyId"`
+	Caller  string    `json:"caller"`
+	// Result is "ok" or the error of the operation.
+	Result string `json:"result"`
+	// Prev is the hex hash of the previous entry.
+	Prev string `json:"prev"`
+	// Hash is the hex SM3 hash of the previous hash and the JSON encoding
+	// of this entry without Hash.
+	Hash string `json:"hash,omitempty"`
+}
+
+func (e *Entry) hash(prev []byte) ([]byte, error) {
+	c := *e
+	c.Hash = ""
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return nil, err
+	}
+	h := sm3.New()
+	h.Write(prev)
+	h.Write(b)
+	return h.Sum(nil), nil
+}
+
+// Chain is a Hook writing the events as JSON lines of Entry, each chained
+// to the previous one by its hash. Anchor the trail by keeping the last
+// hash, see Head, somewhere the writer of the trail cannot change, e.g.
+// signed or in another system.
+type Chain struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seq  uint64
+	prev []byte
+	err  error
+}
+
+var _ Hook = (*Chain)(nil)
+
+// NewChain returns a Chain writing to w. prev is the hash of the last
+// entry already in the trail, nil to start a new one, and seq its
+// sequence number.
+func NewChain(w io.Writer, seq uint64, prev []byte) *Chain {
+	if prev == nil {
+		prev = make([]byte, sm3.Size)
+	}
+	return &Chain{w: w, seq: seq, prev: prev}
+}
+
+// Audit appends an entry for e. A Hook cannot fail the operation, so write
+// errors are kept for Err, and the chain stops writing after one.
+func (c *Chain) Audit(e *Event) {
+	result := "ok"
+	if e.Err != nil {
+		result = e.Err.Error()
+	}
+	entry := &Entry{
+		Time:    e.Time.UTC(),
+		Op:      e.Op,
+		Backend: e.Backend,
+		KeyID:   e.KeyID,
+		Caller:  e.Caller,
+		Result:  result,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	entry.Seq = c.seq + 1
+	entry.Prev = hex.EncodeToString(c.prev)
+	h, err := entry.hash(c.prev)
+	if err != nil {
+		c.err = err
+		return
+	}
+	entry.Hash = hex.EncodeToString(h)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		c.err = err
+		return
+	}
+	if _, err := c.w.Write(append(line, '\n')); err != nil {
+		c.err = err
+		return
+	}
+	c.seq, c.prev = entry.Seq, h
+}
+
+// Head returns the sequence number and hash of the last entry.
+func (c *Chain) Head() (uint64, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq, append([]byte(nil), c.prev...)
+}
+
+// Err returns the first write error.
+func (c *Chain) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// VerifyChain checks the trail in r, which must follow the entry with
+// sequence number seq and hash prev, as given to NewChain, and returns the
+// sequence number and hash of its last entry for comparison with an
+// anchored Head. It returns ErrChain if an entry was altered, removed,
+// inserted or reordered.
+func VerifyChain(r io.Reader, seq uint64, prev []byte) (uint64, []byte, error) {
+	if prev == nil {
+		prev = make([]byte, sm3.Size)
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return seq, prev, ErrChain
+		}
+		h, err := e.hash(prev)
+		if err != nil {
+			return seq, prev, err
+		}
+		if e.Seq != seq+1 || e.Prev != hex.EncodeToString(prev) || e.Hash != hex.EncodeToString(h) {
+			return seq, prev, ErrChain
+		}
+		seq, prev = e.Seq, h
+	}
+	return seq, prev, s.Err()
+}
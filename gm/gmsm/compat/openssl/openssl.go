@@ -0,0 +1,297 @@
+// Package openssl converts SM2 keys, signatures and ciphertexts to and from
+// the forms of OpenSSL 3.x, and carries golden vectors made with it, so that
+// interoperability can be asserted without OpenSSL at hand.
+//
+// OpenSSL differs from the defaults of the sm2 package in two ways:
+//
+//   - its ciphertexts are the DER SEQUENCE { x INTEGER, y INTEGER,
+//     hash OCTET STRING, ciphertext OCTET STRING } of GM/T 0009, in C1C3C2
+//     order, not the raw 04 || x || y || C3 || C2 of sm2.Encrypt;
+//   - it signs and verifies with the empty distinguishing ID unless one is
+//     set with -pkeyopt distid:, where the sm2 package uses sm2.DefaultUID.
+//
+// Keys are PKCS #8 and SubjectPublicKeyInfo with id-ecPublicKey and the SM2
+// curve OID, and signatures DER, as in the sm2 package. The parsers of this
+// package are strict and validate points; for ciphertexts and signatures of
+// other libraries see the interop package.
+package openssl
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+var (
+	oidPublicKeyEC = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidCurveSM2    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+)
+
+var (
+	errPrivateKey = errors.New("openssl: malformed SM2 private key")
+	errPublicKey  = errors.New("openssl: malformed SM2 public key")
+	errCiphertext = errors.New("openssl: malformed SM2 ciphertext")
+)
+
+// c1Size and c3Size are the sizes of 04 || x || y and of the SM3 hash in a
+// ciphertext of sm2.Encrypt.
+const (
+	c1Size = 65
+	c3Size = 32
+)
+
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// ecPrivateKey is the ECPrivateKey of RFC 5915 as OpenSSL writes it inside
+// PKCS #8: the curve is left to the outer AlgorithmIdentifier.
+type ecPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+type subjectPublicKeyInfo struct {
+	Algo      pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+type ciphertext struct {
+	X, Y       *big.Int
+	Hash       []byte
+	Ciphertext []byte
+}
+
+// algorithm returns the AlgorithmIdentifier of SM2 keys.
+func algorithm() pkix.AlgorithmIdentifier {
+	curve, _ := asn1.Marshal(oidCurveSM2)
+	return pkix.AlgorithmIdentifier{
+		Algorithm:  oidPublicKeyEC,
+		Parameters: asn1.RawValue{FullBytes: curve},
+	}
+}
+
+// isSM2 reports whether algo identifies an SM2 key.
+func isSM2(algo pkix.AlgorithmIdentifier) bool {
+	var curve asn1.ObjectIdentifier
+	if !algo.Algorithm.Equal(oidPublicKeyEC) {
+		return false
+	}
+	rest, err := asn1.Unmarshal(algo.Parameters.FullBytes, &curve)
+	return err == nil && len(rest) == 0 && curve.Equal(oidCurveSM2)
+}
+
+// unwrap returns the DER contents of data, which is DER or a PEM block of
+// the given type.
+func unwrap(data []byte, typ string) ([]byte, bool) {
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes, block.Type == typ
+	}
+	return data, true
+}
+
+// ParsePrivateKey parses an unencrypted PKCS #8 SM2 private key, PEM
+// ("PRIVATE KEY") or DER, as written by openssl genpkey -algorithm SM2 or
+// by sm2.MarshalSm2UnecryptedPrivateKey, which drops the leading zeros of
+// the scalar. The public key, if present, must match the private scalar.
+func ParsePrivateKey(data []byte) (*sm2.PrivateKey, error) {
+	der, ok := unwrap(data, "PRIVATE KEY")
+	if !ok {
+		return nil, errPrivateKey
+	}
+	var info pkcs8
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, errPrivateKey
+	}
+	if info.Version != 0 || !isSM2(info.Algo) {
+		return nil, errPrivateKey
+	}
+	var key ecPrivateKey
+	if rest, err := asn1.Unmarshal(info.PrivateKey, &key); err != nil || len(rest) != 0 {
+		return nil, errPrivateKey
+	}
+	if key.Version != 1 || len(key.PrivateKey) > 32 ||
+		(key.Curve != nil && !key.Curve.Equal(oidCurveSM2)) {
+		return nil, errPrivateKey
+	}
+	curve := sm2.P256Sm2()
+	d := new(big.Int).SetBytes(key.PrivateKey)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errPrivateKey
+	}
+	priv := &sm2.PrivateKey{D: d}
+	priv.Curve = curve
+	scalar := make([]byte, 32)
+	fillBytes(scalar, d)
+	priv.X, priv.Y = curve.ScalarBaseMult(scalar)
+	if key.PublicKey.BitLength != 0 &&
+		!bytes.Equal(key.PublicKey.RightAlign(), elliptic.Marshal(curve, priv.X, priv.Y)) {
+		return nil, errPrivateKey
+	}
+	return priv, nil
+}
+
+// MarshalPrivateKey returns priv as the PEM PKCS #8 of OpenSSL, byte for
+// byte what openssl genpkey writes for the same key.
+func MarshalPrivateKey(priv *sm2.PrivateKey) ([]byte, error) {
+	n := sm2.P256Sm2().Params().N
+	if priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(n) >= 0 {
+		return nil, errPrivateKey
+	}
+	if err := priv.PublicKey.Validate(); err != nil {
+		return nil, err
+	}
+	d := make([]byte, 32)
+	fillBytes(d, priv.D)
+	key, err := asn1.Marshal(ecPrivateKey{
+		Version:    1,
+		PrivateKey: d,
+		PublicKey:  asn1.BitString{Bytes: encodePoint(&priv.PublicKey), BitLength: 8 * c1Size},
+	})
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(pkcs8{Algo: algorithm(), PrivateKey: key})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePublicKey parses an SM2 SubjectPublicKeyInfo, PEM ("PUBLIC KEY") or
+// DER, as written by openssl pkey -pubout. The point must be uncompressed
+// and on the curve.
+func ParsePublicKey(data []byte) (*sm2.PublicKey, error) {
+	der, ok := unwrap(data, "PUBLIC KEY")
+	if !ok {
+		return nil, errPublicKey
+	}
+	var info subjectPublicKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, errPublicKey
+	}
+	if !isSM2(info.Algo) || info.PublicKey.BitLength != 8*c1Size {
+		return nil, errPublicKey
+	}
+	curve := sm2.P256Sm2()
+	x, y := elliptic.Unmarshal(curve, info.PublicKey.Bytes)
+	if x == nil {
+		return nil, errPublicKey
+	}
+	return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// MarshalPublicKey returns pub as the PEM SubjectPublicKeyInfo of OpenSSL.
+func MarshalPublicKey(pub *sm2.PublicKey) ([]byte, error) {
+	if err := pub.Validate(); err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algo:      algorithm(),
+		PublicKey: asn1.BitString{Bytes: encodePoint(pub), BitLength: 8 * c1Size},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// fillBytes writes x to buf big-endian, zero-padded; x must fit.
+func fillBytes(buf []byte, x *big.Int) {
+	b := x.Bytes()
+	copy(buf[len(buf)-len(b):], b)
+}
+
+func encodePoint(pub *sm2.PublicKey) []byte {
+	return elliptic.Marshal(sm2.P256Sm2(), pub.X, pub.Y)
+}
+
+// ParseCiphertext converts the DER ciphertext of openssl pkeyutl -encrypt to
+// the C1C3C2 ciphertext of sm2.Decrypt. The DER must be canonical and C1 on
+// the curve.
+func ParseCiphertext(der []byte) ([]byte, error) {
+	var c ciphertext
+	if rest, err := asn1.Unmarshal(der, &c); err != nil || len(rest) != 0 {
+		return nil, errCiphertext
+	}
+	if canon, err := asn1.Marshal(c); err != nil || !bytes.Equal(canon, der) {
+		return nil, errCiphertext
+	}
+	curve := sm2.P256Sm2()
+	p := curve.Params().P
+	if c.X.Sign() < 0 || c.Y.Sign() < 0 || c.X.Cmp(p) >= 0 || c.Y.Cmp(p) >= 0 ||
+		!curve.IsOnCurve(c.X, c.Y) || len(c.Hash) != c3Size || len(c.Ciphertext) == 0 {
+		return nil, errCiphertext
+	}
+	out := make([]byte, c1Size, c1Size+c3Size+len(c.Ciphertext))
+	out[0] = 4
+	fillBytes(out[1:33], c.X)
+	fillBytes(out[33:65], c.Y)
+	out = append(out, c.Hash...)
+	return append(out, c.Ciphertext...), nil
+}
+
+// MarshalCiphertext converts a C1C3C2 ciphertext of sm2.Encrypt to the DER
+// form of OpenSSL. OpenSSL does not encrypt empty messages, and nor is a
+// ciphertext without C2 accepted here.
+func MarshalCiphertext(ct []byte) ([]byte, error) {
+	if len(ct) <= c1Size+c3Size || ct[0] != 4 {
+		return nil, errCiphertext
+	}
+	return asn1.Marshal(ciphertext{
+		X:          new(big.Int).SetBytes(ct[1:33]),
+		Y:          new(big.Int).SetBytes(ct[33:65]),
+		Hash:       ct[c1Size : c1Size+c3Size],
+		Ciphertext: ct[c1Size+c3Size:],
+	})
+}
+
+// Encrypt encrypts msg to pub as openssl pkeyutl -encrypt does.
+func Encrypt(pub *sm2.PublicKey, msg []byte) ([]byte, error) {
+	ct, err := sm2.Encrypt(pub, msg)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalCiphertext(ct)
+}
+
+// Decrypt decrypts a ciphertext of openssl pkeyutl -encrypt.
+func Decrypt(priv *sm2.PrivateKey, der []byte) ([]byte, error) {
+	ct, err := ParseCiphertext(der)
+	if err != nil {
+		return nil, err
+	}
+	return sm2.Decrypt(priv, ct)
+}
+
+// uidOption returns the sm2 option of the OpenSSL distinguishing ID distID.
+// Unlike the sm2 package, a nil distID is the empty ID, as in OpenSSL.
+func uidOption(distID []byte) sm2.Option {
+	if len(distID) == 0 {
+		return sm2.WithEmptyUID()
+	}
+	return sm2.WithUID(distID)
+}
+
+// Sign signs msg as openssl pkeyutl -sign -rawin -digest sm3 does, with
+// -pkeyopt distid:distID if distID is not empty. Pass sm2.DefaultUID for
+// peers that follow GB/T 35276 rather than the OpenSSL default.
+func Sign(priv *sm2.PrivateKey, msg, distID []byte) ([]byte, error) {
+	return sm2.SignEx(priv, msg, uidOption(distID))
+}
+
+// Verify verifies a DER signature of msg as openssl pkeyutl -verify -rawin
+// -digest sm3 does, with the distinguishing ID of Sign.
+func Verify(pub *sm2.PublicKey, msg, sig, distID []byte) bool {
+	return sm2.VerifyEx(pub, msg, sig, uidOption(distID), sm2.WithCanonicalSig())
+}
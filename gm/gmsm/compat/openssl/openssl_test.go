@@ -0,0 +1,155 @@
+package openssl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestVectors(t *testing.T) {
+	for i, v := range Vectors {
+		if err := Check(v); err != nil {
+			t.Errorf("vector %d: %v", i, err)
+		}
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	v := Vectors[0]
+	priv, err := ParsePrivateKey([]byte(v.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePrivateKey([]byte(v.PublicKey)); err == nil {
+		t.Error("public key PEM parsed as a private key")
+	}
+	if _, err := ParsePublicKey([]byte(v.PrivateKey)); err == nil {
+		t.Error("private key PEM parsed as a public key")
+	}
+
+	der, err := sm2.MarshalSm2UnecryptedPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := ParsePrivateKey(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pem, _ := MarshalPrivateKey(converted); string(pem) != v.PrivateKey {
+		t.Error("sm2 package encoding does not convert to the OpenSSL PEM")
+	}
+
+	other, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := *priv
+	mismatched.PublicKey = other.PublicKey
+	pem, err := MarshalPrivateKey(&mismatched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePrivateKey(pem); err == nil {
+		t.Error("private key with a foreign public key parsed")
+	}
+
+	ct := append([]byte(nil), v.Ciphertext...)
+	for name, data := range map[string][]byte{
+		"trailing": append(append([]byte(nil), ct...), 0),
+		"short":    ct[:len(ct)-1],
+		"raw":      mustRaw(t, ct),
+	} {
+		if _, err := ParseCiphertext(data); err == nil {
+			t.Errorf("%s ciphertext parsed", name)
+		}
+	}
+	if _, err := MarshalCiphertext(make([]byte, 97)); err == nil {
+		t.Error("ciphertext without C2 marshalled")
+	}
+}
+
+func mustRaw(t *testing.T, der []byte) []byte {
+	ct, err := ParseCiphertext(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ct
+}
+
+// TestOpenSSLCommand checks fresh keys, signatures and ciphertexts of this
+// package against the openssl command, when one with SM2 is installed.
+func TestOpenSSLCommand(t *testing.T) {
+	path, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+	dir, err := ioutil.TempDir("", "openssl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	run := func(args ...string) ([]byte, error) {
+		return exec.Command(path, args...).CombinedOutput()
+	}
+	write := func(name string, data []byte) string {
+		name = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(name, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPEM, err := MarshalPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM, err := MarshalPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privFile := write("priv.pem", privPEM)
+	pubFile := write("pub.pem", pubPEM)
+	if out, err := run("pkey", "-in", privFile, "-pubout"); err != nil {
+		t.Skipf("openssl without SM2: %s", out)
+	} else if !bytes.Equal(out, pubPEM) {
+		t.Fatalf("openssl derives public key\n%s", out)
+	}
+	if out, err := run("pkey", "-in", privFile); err != nil || !bytes.Equal(out, privPEM) {
+		t.Fatalf("openssl re-encodes private key\n%s", out)
+	}
+
+	msg := []byte("from go to openssl")
+	msgFile := write("msg", msg)
+	for _, id := range []string{"", "1234567812345678", "alice@example"} {
+		sig, err := Sign(priv, msg, []byte(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		args := []string{"pkeyutl", "-verify", "-rawin", "-digest", "sm3",
+			"-pubin", "-inkey", pubFile, "-in", msgFile, "-sigfile", write("sig", sig)}
+		if id != "" {
+			args = append(args, "-pkeyopt", "distid:"+id)
+		}
+		if out, err := run(args...); err != nil {
+			t.Errorf("openssl rejects signature under ID %q: %s", id, out)
+		}
+	}
+
+	ct, err := Encrypt(&priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := run("pkeyutl", "-decrypt", "-inkey", privFile, "-in", write("ct", ct))
+	if err != nil || !bytes.Equal(out, msg) {
+		t.Errorf("openssl decrypts %q: %v", out, err)
+	}
+}
@@ -0,0 +1,226 @@
+// Package container wraps SM2 ciphertexts, SM2 signatures and SM4
+// ciphertexts in a versioned format, so that the format of the body can
+// change, e.g. to a compressed C1 or another AEAD, without breaking data
+// that is already deployed.
+//
+// A container is a 5-byte header followed by the body:
+//
+//	magic "GM" (2 bytes) || version (1) || algorithm (1) || flags (1) || body
+//
+// Blobs written before the header was introduced, i.e. the bare output of
+// sm2.Encrypt (which starts with 04) and bare DER signatures (which start
+// with 30), cannot start with the magic. Parse reports them as Legacy, and
+// DecryptSM2 and VerifySM2 accept them as such.
+package container
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// HeaderSize is the size of the header.
+const HeaderSize = 5
+
+var magic = [2]byte{'G', 'M'}
+
+const (
+	// Legacy is the version Parse reports for blobs without a header.
+	Legacy = 0
+	// Version1 is the current version.
+	Version1 = 1
+)
+
+// Algorithm identifies the contents of a container.
+type Algorithm byte
+
+const (
+	// AlgSM2Encrypt is an SM2 ciphertext of GB/T 32918.4.
+	AlgSM2Encrypt Algorithm = 1
+	// AlgSM2Signature is an SM2 signature over SM3(ZA || M), DER encoded.
+	AlgSM2Signature Algorithm = 2
+	// AlgSM4GCM is nonce (12 bytes) || SM4-GCM ciphertext and tag.
+	AlgSM4GCM Algorithm = 3
+)
+
+// Flags modify the encoding of the body.
+type Flags byte
+
+const (
+	// FlagC1C2C3 marks an SM2 ciphertext in the C1C2C3 order instead of
+	// C1C3C2.
+	FlagC1C2C3 Flags = 1 << iota
+	// FlagCompressedC1 is reserved for SM2 ciphertexts with a compressed
+	// C1; version 1 readers reject it.
+	FlagCompressedC1
+)
+
+var (
+	// ErrTruncated is returned for a blob shorter than the header.
+	ErrTruncated = errors.New("container: truncated header")
+	// ErrVersion is returned for a version newer than this package.
+	ErrVersion = errors.New("container: unsupported version")
+	// ErrAlgorithm is returned when a container holds another algorithm
+	// than the one the caller expects.
+	ErrAlgorithm = errors.New("container: unexpected algorithm")
+	// ErrFlags is returned for flags the algorithm does not support.
+	ErrFlags = errors.New("container: unsupported flags")
+	// ErrLegacy is returned for a blob without a header where the
+	// algorithm has no legacy format.
+	ErrLegacy = errors.New("container: missing header")
+	// ErrDecrypt is returned when an SM4 ciphertext fails authentication.
+	ErrDecrypt = errors.New("container: message authentication failed")
+)
+
+// Header is the header of a container.
+type Header struct {
+	Version   byte
+	Algorithm Algorithm
+	Flags     Flags
+}
+
+// Append appends the encoding of h to dst.
+func (h Header) Append(dst []byte) []byte {
+	return append(dst, magic[0], magic[1], h.Version, byte(h.Algorithm), byte(h.Flags))
+}
+
+// Parse splits blob into its header and body. A blob without the magic is
+// returned whole, with a zero Header whose Version is Legacy, for the caller
+// to handle in the format it used before containers.
+func Parse(blob []byte) (Header, []byte, error) {
+	if len(blob) < len(magic) || blob[0] != magic[0] || blob[1] != magic[1] {
+		return Header{Version: Legacy}, blob, nil
+	}
+	if len(blob) < HeaderSize {
+		return Header{}, nil, ErrTruncated
+	}
+	h := Header{Version: blob[2], Algorithm: Algorithm(blob[3]), Flags: Flags(blob[4])}
+	if h.Version != Version1 {
+		return Header{}, nil, ErrVersion
+	}
+	return h, blob[HeaderSize:], nil
+}
+
+// open parses blob as a container of alg with at most the given flags.
+func open(blob []byte, alg Algorithm, flags Flags) (Header, []byte, error) {
+	h, body, err := Parse(blob)
+	if err != nil || h.Version == Legacy {
+		return h, body, err
+	}
+	if h.Algorithm != alg {
+		return Header{}, nil, ErrAlgorithm
+	}
+	if h.Flags&^flags != 0 {
+		return Header{}, nil, ErrFlags
+	}
+	return h, body, nil
+}
+
+// EncryptSM2 encrypts msg to pub with sm2.EncryptEx and returns the C1C3C2
+// ciphertext in a container. opts are passed to sm2.EncryptEx, except for
+// the ciphertext mode.
+func EncryptSM2(pub *sm2.PublicKey, msg []byte, opts ...sm2.Option) ([]byte, error) {
+	ct, err := sm2.EncryptEx(pub, msg, withMode(opts, sm2.C1C3C2)...)
+	if err != nil {
+		return nil, err
+	}
+	h := Header{Version: Version1, Algorithm: AlgSM2Encrypt}
+	return append(h.Append(make([]byte, 0, HeaderSize+len(ct))), ct...), nil
+}
+
+// DecryptSM2 decrypts a container of EncryptSM2, or a bare C1C3C2
+// ciphertext of sm2.Encrypt. opts are passed to sm2.DecryptEx, except for
+// the ciphertext mode, which comes from the flags.
+func DecryptSM2(priv *sm2.PrivateKey, blob []byte, opts ...sm2.Option) ([]byte, error) {
+	h, body, err := open(blob, AlgSM2Encrypt, FlagC1C2C3)
+	if err != nil {
+		return nil, err
+	}
+	mode := sm2.C1C3C2
+	if h.Flags&FlagC1C2C3 != 0 {
+		mode = sm2.C1C2C3
+	}
+	return sm2.DecryptEx(priv, body, withMode(opts, mode)...)
+}
+
+// withMode returns a copy of opts that ends with the ciphertext mode.
+func withMode(opts []sm2.Option, mode sm2.CiphertextMode) []sm2.Option {
+	return append(append([]sm2.Option(nil), opts...), sm2.WithCiphertextMode(mode))
+}
+
+// SignSM2 signs msg with sm2.SignEx and returns the signature in a
+// container.
+func SignSM2(priv *sm2.PrivateKey, msg []byte, opts ...sm2.Option) ([]byte, error) {
+	sig, err := sm2.SignEx(priv, msg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h := Header{Version: Version1, Algorithm: AlgSM2Signature}
+	return append(h.Append(make([]byte, 0, HeaderSize+len(sig))), sig...), nil
+}
+
+// VerifySM2 checks a signature of SignSM2, or a bare DER signature, with
+// sm2.CheckSignature.
+func VerifySM2(pub *sm2.PublicKey, msg, blob []byte, opts ...sm2.Option) error {
+	_, body, err := open(blob, AlgSM2Signature, 0)
+	if err != nil {
+		return err
+	}
+	return sm2.CheckSignature(pub, msg, body, opts...)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealSM4 encrypts and authenticates plaintext and additionalData with
+// SM4-GCM under a random nonce. The header is authenticated as well.
+func SealSM4(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	h := Header{Version: Version1, Algorithm: AlgSM4GCM}
+	out := h.Append(make([]byte, 0, HeaderSize+aead.NonceSize()+len(plaintext)+aead.Overhead()))
+	nonce := out[HeaderSize : HeaderSize+aead.NonceSize()]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out = out[:HeaderSize+aead.NonceSize()]
+	ad := append(h.Append(nil), additionalData...)
+	return aead.Seal(out, nonce, plaintext, ad), nil
+}
+
+// OpenSM4 decrypts a container of SealSM4. SM4 ciphertexts have no legacy
+// format, so a blob without a header returns ErrLegacy.
+func OpenSM4(key, blob, additionalData []byte) ([]byte, error) {
+	h, body, err := open(blob, AlgSM4GCM, 0)
+	if err != nil {
+		return nil, err
+	}
+	if h.Version == Legacy {
+		return nil, ErrLegacy
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	n := aead.NonceSize()
+	ad := append(h.Append(nil), additionalData...)
+	plaintext, err := aead.Open(nil, body[:n], body[n:], ad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
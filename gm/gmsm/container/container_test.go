@@ -0,0 +1,118 @@
+package container
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestSM2Encrypt(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("attack at dawn")
+	blob, err := EncryptSM2(&priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _, err := Parse(blob)
+	if err != nil || h != (Header{Version1, AlgSM2Encrypt, 0}) {
+		t.Fatalf("header %+v, %v", h, err)
+	}
+	if pt, err := DecryptSM2(priv, blob); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("DecryptSM2 returned %q, %v", pt, err)
+	}
+
+	// Ciphertexts of sm2.Encrypt from before containers.
+	legacy, err := sm2.Encrypt(&priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _, _ := Parse(legacy); h.Version != Legacy {
+		t.Fatalf("bare ciphertext parsed as version %d", h.Version)
+	}
+	if pt, err := DecryptSM2(priv, legacy); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("DecryptSM2 of a legacy ciphertext returned %q, %v", pt, err)
+	}
+
+	// C1C2C3 bodies are routed by the flag.
+	ct, err := sm2.EncryptEx(&priv.PublicKey, msg, sm2.WithCiphertextMode(sm2.C1C2C3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob = append(Header{Version1, AlgSM2Encrypt, FlagC1C2C3}.Append(nil), ct...)
+	if pt, err := DecryptSM2(priv, blob); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("DecryptSM2 of C1C2C3 returned %q, %v", pt, err)
+	}
+
+	blob[4] = byte(FlagCompressedC1)
+	if _, err := DecryptSM2(priv, blob); err != ErrFlags {
+		t.Fatalf("expected ErrFlags, got %v", err)
+	}
+	blob[3] = byte(AlgSM2Signature)
+	if _, err := DecryptSM2(priv, blob); err != ErrAlgorithm {
+		t.Fatalf("expected ErrAlgorithm, got %v", err)
+	}
+	blob[2] = 2
+	if _, err := DecryptSM2(priv, blob); err != ErrVersion {
+		t.Fatalf("expected ErrVersion, got %v", err)
+	}
+	if _, err := DecryptSM2(priv, blob[:4]); err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+}
+
+func TestSM2Signature(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("message")
+	blob, err := SignSM2(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySM2(&priv.PublicKey, msg, blob); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySM2(&priv.PublicKey, []byte("other"), blob); err == nil {
+		t.Fatal("signature of another message accepted")
+	}
+
+	legacy, err := sm2.SignEx(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySM2(&priv.PublicKey, msg, legacy); err != nil {
+		t.Fatalf("legacy signature rejected: %v", err)
+	}
+	blob[4] = byte(FlagC1C2C3)
+	if err := VerifySM2(&priv.PublicKey, msg, blob); err != ErrFlags {
+		t.Fatalf("expected ErrFlags, got %v", err)
+	}
+}
+
+func TestSM4(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 16)
+	blob, err := SealSM4(key, []byte("secret"), []byte("context"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := OpenSM4(key, blob, []byte("context")); err != nil || string(pt) != "secret" {
+		t.Fatalf("OpenSM4 returned %q, %v", pt, err)
+	}
+	if _, err := OpenSM4(key, blob, []byte("other")); err != ErrDecrypt {
+		t.Fatalf("expected ErrDecrypt, got %v", err)
+	}
+	// The header is authenticated: flags cannot be changed unnoticed once
+	// a later version accepts them.
+	blob[4] = 1
+	if _, err := OpenSM4(key, blob, []byte("context")); err != ErrFlags {
+		t.Fatalf("expected ErrFlags, got %v", err)
+	}
+	if _, err := OpenSM4(key, blob[HeaderSize:], nil); err != ErrLegacy {
+		t.Fatalf("expected ErrLegacy, got %v", err)
+	}
+}
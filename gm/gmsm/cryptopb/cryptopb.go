@@ -0,0 +1,402 @@
+// Package cryptopb defines protobuf messages for SM2 public keys,
+// signatures, ciphertexts, signed envelopes and certificate references, so
+// that services exchange them in one layout instead of each inventing its
+// own. The messages are in crypto.proto; the Go types here have the names
+// protoc-gen-go would give them and encode and decode the protobuf wire
+// format without a protobuf runtime.
+//
+// Marshal writes fields in field number order and omits empty ones, as
+// proto3 does, so equal messages encode to equal bytes. Unmarshal accepts
+// any valid encoding of the message, as written by any protobuf library,
+// and skips unknown fields. Neither checks that the values make sense; the
+// FromProto functions do.
+package cryptopb
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"errors"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/signenvelope"
+)
+
+var (
+	// ErrMalformed is returned by Unmarshal for bytes that are not a
+	// protobuf encoding of the message.
+	ErrMalformed = errors.New("cryptopb: malformed message")
+	// ErrInvalid is returned by the FromProto functions for a well-formed
+	// message whose values are not a valid SM2 artifact.
+	ErrInvalid = errors.New("cryptopb: invalid value")
+)
+
+// Curve identifies the elliptic curve of a PublicKey.
+type Curve int32
+
+const (
+	Curve_CURVE_UNSPECIFIED Curve = 0
+	Curve_CURVE_SM2P256V1   Curve = 1
+)
+
+const (
+	pointSize = 65
+	hashSize  = 32
+)
+
+// PublicKey is an SM2 public key.
+type PublicKey struct {
+	Curve Curve
+	Point []byte
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *PublicKey) Marshal() []byte {
+	var b []byte
+	b = appendUint(b, 1, uint64(m.Curve))
+	return appendBytes(b, 2, m.Point)
+}
+
+// Unmarshal sets m from a protobuf encoding.
+func (m *PublicKey) Unmarshal(data []byte) error {
+	var out PublicKey
+	err := decode(data, func(field int, v value) (err error) {
+		switch field {
+		case 1:
+			var c uint32
+			c, err = v.uint32()
+			out.Curve = Curve(c)
+		case 2:
+			out.Point, err = v.bytes()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// PublicKeyToProto returns pub as a PublicKey message.
+func PublicKeyToProto(pub *sm2.PublicKey) (*PublicKey, error) {
+	if err := pub.Validate(); err != nil {
+		return nil, err
+	}
+	return &PublicKey{
+		Curve: Curve_CURVE_SM2P256V1,
+		Point: elliptic.Marshal(sm2.P256Sm2(), pub.X, pub.Y),
+	}, nil
+}
+
+// PublicKeyFromProto returns the public key of m, which must be an
+// uncompressed point on the SM2 curve.
+func PublicKeyFromProto(m *PublicKey) (*sm2.PublicKey, error) {
+	if m.Curve != Curve_CURVE_SM2P256V1 || len(m.Point) != pointSize {
+		return nil, ErrInvalid
+	}
+	curve := sm2.P256Sm2()
+	x, y := elliptic.Unmarshal(curve, m.Point)
+	if x == nil {
+		return nil, ErrInvalid
+	}
+	return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Signature is an SM2 signature.
+type Signature struct {
+	R []byte
+	S []byte
+	V uint32
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *Signature) Marshal() []byte {
+	var b []byte
+	b = appendBytes(b, 1, m.R)
+	b = appendBytes(b, 2, m.S)
+	return appendUint(b, 3, uint64(m.V))
+}
+
+// Unmarshal sets m from a protobuf encoding.
+func (m *Signature) Unmarshal(data []byte) error {
+	var out Signature
+	err := decode(data, func(field int, v value) (err error) {
+		switch field {
+		case 1:
+			out.R, err = v.bytes()
+		case 2:
+			out.S, err = v.bytes()
+		case 3:
+			out.V, err = v.uint32()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// SignatureToProto returns sig as a Signature message, with r and s padded
+// to 32 bytes. They must lie in [1, n-1].
+func SignatureToProto(sig *sm2.Signature) (*Signature, error) {
+	raw, err := sig.Encode(sm2.FormatRaw)
+	if err != nil {
+		return nil, err
+	}
+	if sig.V > 1 {
+		return nil, ErrInvalid
+	}
+	return &Signature{R: raw[:32], S: raw[32:], V: uint32(sig.V)}, nil
+}
+
+// SignatureFromProto returns the signature of m, whose r and s must be
+// exactly 32 bytes and lie in [1, n-1], and v be 0 or 1.
+func SignatureFromProto(m *Signature) (*sm2.Signature, error) {
+	if len(m.R) != 32 || len(m.S) != 32 || m.V > 1 {
+		return nil, ErrInvalid
+	}
+	var sig sm2.Signature
+	if err := sig.DecodeFormat(sm2.FormatRaw, append(append([]byte(nil), m.R...), m.S...)); err != nil {
+		return nil, ErrInvalid
+	}
+	sig.V = uint(m.V)
+	return &sig, nil
+}
+
+// Ciphertext is an SM2 ciphertext.
+type Ciphertext struct {
+	C1 []byte
+	C3 []byte
+	C2 []byte
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *Ciphertext) Marshal() []byte {
+	var b []byte
+	b = appendBytes(b, 1, m.C1)
+	b = appendBytes(b, 2, m.C3)
+	return appendBytes(b, 3, m.C2)
+}
+
+// Unmarshal sets m from a protobuf encoding.
+func (m *Ciphertext) Unmarshal(data []byte) error {
+	var out Ciphertext
+	err := decode(data, func(field int, v value) (err error) {
+		switch field {
+		case 1:
+			out.C1, err = v.bytes()
+		case 2:
+			out.C3, err = v.bytes()
+		case 3:
+			out.C2, err = v.bytes()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// CiphertextToProto splits ct, a ciphertext of sm2.EncryptEx in the given
+// mode, into a Ciphertext message.
+func CiphertextToProto(ct []byte, mode sm2.CiphertextMode) (*Ciphertext, error) {
+	if len(ct) < pointSize+hashSize || ct[0] != 4 {
+		return nil, ErrInvalid
+	}
+	m := &Ciphertext{C1: append([]byte(nil), ct[:pointSize]...)}
+	rest := ct[pointSize:]
+	switch mode {
+	case sm2.C1C3C2:
+		m.C3 = append([]byte(nil), rest[:hashSize]...)
+		m.C2 = append([]byte(nil), rest[hashSize:]...)
+	case sm2.C1C2C3:
+		m.C2 = append([]byte(nil), rest[:len(rest)-hashSize]...)
+		m.C3 = append([]byte(nil), rest[len(rest)-hashSize:]...)
+	default:
+		return nil, ErrInvalid
+	}
+	return m, nil
+}
+
+// CiphertextFromProto joins m into a ciphertext for sm2.DecryptEx in the
+// given mode. C1 must be an uncompressed point on the SM2 curve.
+func CiphertextFromProto(m *Ciphertext, mode sm2.CiphertextMode) ([]byte, error) {
+	if len(m.C1) != pointSize || len(m.C3) != hashSize {
+		return nil, ErrInvalid
+	}
+	if x, _ := elliptic.Unmarshal(sm2.P256Sm2(), m.C1); x == nil {
+		return nil, ErrInvalid
+	}
+	ct := make([]byte, 0, pointSize+hashSize+len(m.C2))
+	ct = append(ct, m.C1...)
+	switch mode {
+	case sm2.C1C3C2:
+		ct = append(append(ct, m.C3...), m.C2...)
+	case sm2.C1C2C3:
+		ct = append(append(ct, m.C2...), m.C3...)
+	default:
+		return nil, ErrInvalid
+	}
+	return ct, nil
+}
+
+// Envelope is a signed envelope of package signenvelope. Its encoding is
+// that of signenvelope.Envelope.Marshal.
+type Envelope struct {
+	Algorithm string
+	PublicKey []byte
+	Uid       []byte
+	Message   []byte
+	Signature []byte
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *Envelope) Marshal() []byte {
+	var b []byte
+	b = appendBytes(b, 1, []byte(m.Algorithm))
+	b = appendBytes(b, 2, m.PublicKey)
+	b = appendBytes(b, 3, m.Uid)
+	b = appendBytes(b, 4, m.Message)
+	return appendBytes(b, 5, m.Signature)
+}
+
+// Unmarshal sets m from a protobuf encoding. Unlike signenvelope.Unmarshal
+// it accepts any field order; the signature covers the fields, not these
+// bytes.
+func (m *Envelope) Unmarshal(data []byte) error {
+	var out Envelope
+	err := decode(data, func(field int, v value) (err error) {
+		switch field {
+		case 1:
+			var b []byte
+			b, err = v.bytes()
+			out.Algorithm = string(b)
+		case 2:
+			out.PublicKey, err = v.bytes()
+		case 3:
+			out.Uid, err = v.bytes()
+		case 4:
+			out.Message, err = v.bytes()
+		case 5:
+			out.Signature, err = v.bytes()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// EnvelopeToProto returns e as an Envelope message.
+func EnvelopeToProto(e *signenvelope.Envelope) *Envelope {
+	return &Envelope{
+		Algorithm: e.Algorithm,
+		PublicKey: append([]byte(nil), e.PublicKey...),
+		Uid:       append([]byte(nil), e.UID...),
+		Message:   append([]byte(nil), e.Message...),
+		Signature: append([]byte(nil), e.Signature...),
+	}
+}
+
+// EnvelopeFromProto returns the envelope of m. It does not verify the
+// signature; call Verify on the result.
+func EnvelopeFromProto(m *Envelope) *signenvelope.Envelope {
+	return &signenvelope.Envelope{
+		Algorithm: m.Algorithm,
+		PublicKey: append([]byte(nil), m.PublicKey...),
+		UID:       append([]byte(nil), m.Uid...),
+		Message:   append([]byte(nil), m.Message...),
+		Signature: append([]byte(nil), m.Signature...),
+	}
+}
+
+// CertificateRef names a certificate by its SM3 fingerprint, its issuer and
+// serial number, or its subject key identifier.
+type CertificateRef struct {
+	Sm3Fingerprint []byte
+	Issuer         []byte
+	SerialNumber   []byte
+	SubjectKeyId   []byte
+}
+
+// Marshal returns the protobuf encoding of m.
+func (m *CertificateRef) Marshal() []byte {
+	var b []byte
+	b = appendBytes(b, 1, m.Sm3Fingerprint)
+	b = appendBytes(b, 2, m.Issuer)
+	b = appendBytes(b, 3, m.SerialNumber)
+	return appendBytes(b, 4, m.SubjectKeyId)
+}
+
+// Unmarshal sets m from a protobuf encoding.
+func (m *CertificateRef) Unmarshal(data []byte) error {
+	var out CertificateRef
+	err := decode(data, func(field int, v value) (err error) {
+		switch field {
+		case 1:
+			out.Sm3Fingerprint, err = v.bytes()
+		case 2:
+			out.Issuer, err = v.bytes()
+		case 3:
+			out.SerialNumber, err = v.bytes()
+		case 4:
+			out.SubjectKeyId, err = v.bytes()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	*m = out
+	return nil
+}
+
+// CertificateRefToProto returns a reference to cert with all its fields set.
+// There is no CertificateRefFromProto, as a reference does not carry the
+// certificate; use Matches to find it.
+func CertificateRefToProto(cert *sm2.Certificate) *CertificateRef {
+	m := &CertificateRef{
+		Sm3Fingerprint: sm3.Sm3Sum(cert.Raw),
+		Issuer:         append([]byte(nil), cert.RawIssuer...),
+		SubjectKeyId:   append([]byte(nil), cert.SubjectKeyId...),
+	}
+	if cert.SerialNumber != nil {
+		m.SerialNumber = cert.SerialNumber.Bytes()
+	}
+	return m
+}
+
+// Matches reports whether cert is the certificate m refers to: every field
+// set in m must match it, the issuer and serial number together, and at
+// least one must be set.
+func (m *CertificateRef) Matches(cert *sm2.Certificate) bool {
+	matched := false
+	if len(m.Sm3Fingerprint) != 0 {
+		if !bytes.Equal(m.Sm3Fingerprint, sm3.Sm3Sum(cert.Raw)) {
+			return false
+		}
+		matched = true
+	}
+	if len(m.Issuer) != 0 || len(m.SerialNumber) != 0 {
+		if cert.SerialNumber == nil || cert.SerialNumber.Sign() < 0 ||
+			!bytes.Equal(m.Issuer, cert.RawIssuer) ||
+			!bytes.Equal(m.SerialNumber, cert.SerialNumber.Bytes()) {
+			return false
+		}
+		matched = true
+	}
+	if len(m.SubjectKeyId) != 0 {
+		if !bytes.Equal(m.SubjectKeyId, cert.SubjectKeyId) {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
@@ -0,0 +1,253 @@
+package cryptopb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/signenvelope"
+)
+
+func TestPublicKey(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := PublicKeyToProto(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := m.Marshal()
+	want := append([]byte{0x08, 0x01, 0x12, 0x41}, m.Point...)
+	if !bytes.Equal(enc, want) {
+		t.Fatalf("got %x", enc)
+	}
+	var dec PublicKey
+	if err := dec.Unmarshal(enc); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := PublicKeyFromProto(&dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+		t.Fatal("round trip changed the key")
+	}
+
+	for name, bad := range map[string]*PublicKey{
+		"unspecified curve": {Point: m.Point},
+		"compressed":        {Curve: Curve_CURVE_SM2P256V1, Point: m.Point[:33]},
+		"off curve":         {Curve: Curve_CURVE_SM2P256V1, Point: append(append([]byte(nil), m.Point[:64]...), m.Point[64]^1)},
+	} {
+		if _, err := PublicKeyFromProto(bad); err != ErrInvalid {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("cryptopb")
+	sig, err := sm2.SignSignature(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := SignatureToProto(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := m.Marshal()
+	var dec Signature
+	if err := dec.Unmarshal(enc); err != nil {
+		t.Fatal(err)
+	}
+	back, err := SignatureFromProto(&dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !back.Equal(sig) || back.V != sig.V || !sm2.VerifySignature(&priv.PublicKey, msg, back) {
+		t.Fatal("round trip changed the signature")
+	}
+
+	short, err := SignatureToProto(&sm2.Signature{R: big.NewInt(1), S: big.NewInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]byte{0x0a, 0x20}, short.R...), append([]byte{0x12, 0x20}, short.S...)...)
+	if len(short.R) != 32 || !bytes.Equal(short.Marshal(), want) {
+		t.Fatalf("got %x", short.Marshal())
+	}
+	for name, bad := range map[string]*Signature{
+		"unpadded": {R: []byte{1}, S: short.S},
+		"zero":     {R: make([]byte, 32), S: short.S},
+		"v = 2":    {R: short.R, S: short.S, V: 2},
+	} {
+		if _, err := SignatureFromProto(bad); err != ErrInvalid {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestCiphertext(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("cryptopb ciphertext")
+	for _, mode := range []sm2.CiphertextMode{sm2.C1C3C2, sm2.C1C2C3} {
+		ct, err := sm2.EncryptEx(&priv.PublicKey, msg, sm2.WithCiphertextMode(mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := CiphertextToProto(ct, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.C2) != len(msg) {
+			t.Fatalf("C2 is %d bytes", len(m.C2))
+		}
+		var dec Ciphertext
+		if err := dec.Unmarshal(m.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		// A ciphertext written in one mode can be read in the other.
+		other := sm2.C1C2C3
+		if mode == other {
+			other = sm2.C1C3C2
+		}
+		joined, err := CiphertextFromProto(&dec, other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := sm2.DecryptEx(priv, joined, sm2.WithCiphertextMode(other))
+		if err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("mode %d: got %q, %v", mode, pt, err)
+		}
+	}
+	if _, err := CiphertextToProto(make([]byte, 96), sm2.C1C3C2); err != ErrInvalid {
+		t.Errorf("short ciphertext: got %v", err)
+	}
+	if _, err := CiphertextFromProto(&Ciphertext{C1: make([]byte, 65), C3: make([]byte, 32)}, sm2.C1C3C2); err != ErrInvalid {
+		t.Errorf("C1 off curve: got %v", err)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := signenvelope.Sign(priv, nil, []byte("enveloped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := EnvelopeToProto(e)
+	if !bytes.Equal(m.Marshal(), e.Marshal()) {
+		t.Fatal("encoding differs from signenvelope")
+	}
+	var dec Envelope
+	if err := dec.Unmarshal(e.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnvelopeFromProto(&dec).Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateRef(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &sm2.Certificate{
+		SerialNumber:       big.NewInt(0x1234),
+		Subject:            pkix.Name{CommonName: "cryptopb"},
+		NotBefore:          time.Unix(1000, 0),
+		NotAfter:           time.Unix(100000, 0),
+		SubjectKeyId:       []byte{1, 2, 3, 4},
+		SignatureAlgorithm: sm2.SM2WithSM3,
+	}
+	der, err := sm2.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := sm2.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := CertificateRefToProto(cert)
+	var dec CertificateRef
+	if err := dec.Unmarshal(m.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+	if !dec.Matches(cert) {
+		t.Fatal("reference does not match its certificate")
+	}
+	for name, ref := range map[string]*CertificateRef{
+		"fingerprint":       {Sm3Fingerprint: m.Sm3Fingerprint},
+		"issuer and serial": {Issuer: m.Issuer, SerialNumber: m.SerialNumber},
+		"key id":            {SubjectKeyId: m.SubjectKeyId},
+	} {
+		if !ref.Matches(cert) {
+			t.Errorf("%s: no match", name)
+		}
+	}
+	for name, ref := range map[string]*CertificateRef{
+		"empty":       {},
+		"serial only": {SerialNumber: m.SerialNumber},
+		"other key":   {Sm3Fingerprint: m.Sm3Fingerprint, SubjectKeyId: []byte{5}},
+	} {
+		if ref.Matches(cert) {
+			t.Errorf("%s: matches", name)
+		}
+	}
+}
+
+func TestUnmarshalWire(t *testing.T) {
+	point := make([]byte, 65)
+	point[0] = 4
+	// Out of field order, with an unknown varint, fixed32, fixed64 and bytes
+	// field, and the curve repeated, as other encoders may write.
+	var b []byte
+	b = appendBytes(b, 2, point)
+	b = appendUint(b, 9, 300)
+	b = append(b, 10<<3|wireFixed32, 1, 2, 3, 4)
+	b = append(b, 11<<3|wireFixed64, 1, 2, 3, 4, 5, 6, 7, 8)
+	b = appendBytes(b, 12, []byte("unknown"))
+	b = appendUint(b, 1, 7)
+	b = appendUint(b, 1, 1)
+	var m PublicKey
+	if err := m.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if m.Curve != Curve_CURVE_SM2P256V1 || !bytes.Equal(m.Point, point) {
+		t.Fatalf("got %+v", m)
+	}
+	if !bytes.Equal(m.Marshal(), append([]byte{0x08, 0x01, 0x12, 0x41}, point...)) {
+		t.Fatal("not re-encoded canonically")
+	}
+
+	m = PublicKey{Curve: 5}
+	for name, data := range map[string][]byte{
+		"truncated length": {0x12, 0x41, 4},
+		"truncated varint": {0x08, 0x80},
+		"field 0":          {0x00, 0x01},
+		"group":            {1<<3 | 3},
+		"wrong wire type":  {0x0a, 0x01, 0x01},
+		"curve overflow":   {0x08, 0x80, 0x80, 0x80, 0x80, 0x10},
+	} {
+		if err := m.Unmarshal(data); err != ErrMalformed {
+			t.Errorf("%s: got %v", name, err)
+		}
+		if m.Curve != 5 {
+			t.Errorf("%s: message modified on error", name)
+		}
+	}
+}
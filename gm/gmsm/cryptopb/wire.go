@@ -0,0 +1,106 @@
+package cryptopb
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendVarint(b []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(b, tmp[:n]...)
+}
+
+// appendBytes appends a length-delimited field, omitting it when empty as
+// proto3 does.
+func appendBytes(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendVarint(b, uint64(field<<3|wireBytes))
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendUint appends a varint field, omitting it when zero.
+func appendUint(b []byte, field int, x uint64) []byte {
+	if x == 0 {
+		return b
+	}
+	b = appendVarint(b, uint64(field<<3|wireVarint))
+	return appendVarint(b, x)
+}
+
+// value is a decoded field: x for varints, b for length-delimited fields.
+type value struct {
+	wire int
+	x    uint64
+	b    []byte
+}
+
+// bytes returns a copy of a length-delimited value.
+func (v value) bytes() ([]byte, error) {
+	if v.wire != wireBytes {
+		return nil, ErrMalformed
+	}
+	return append([]byte(nil), v.b...), nil
+}
+
+// uint32 returns a varint value that fits 32 bits, as uint32 and enum fields
+// must.
+func (v value) uint32() (uint32, error) {
+	if v.wire != wireVarint || v.x > math.MaxUint32 {
+		return 0, ErrMalformed
+	}
+	return uint32(v.x), nil
+}
+
+// decode calls f with each field of data in the order they appear, so that
+// a repeated field ends with its last value as protobuf requires. Fields f
+// does not know are skipped by it; only their encoding is checked here.
+func decode(data []byte, f func(field int, v value) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 || key>>3 == 0 || key>>3 > math.MaxInt32 {
+			return ErrMalformed
+		}
+		data = data[n:]
+		v := value{wire: int(key & 7)}
+		switch v.wire {
+		case wireVarint:
+			if v.x, n = binary.Uvarint(data); n <= 0 {
+				return ErrMalformed
+			}
+		case wireFixed64, wireFixed32:
+			n = 8
+			if v.wire == wireFixed32 {
+				n = 4
+			}
+			if len(data) < n {
+				return ErrMalformed
+			}
+		case wireBytes:
+			l, m := binary.Uvarint(data)
+			if m <= 0 || l > uint64(len(data)-m) {
+				return ErrMalformed
+			}
+			v.b = data[m : m+int(l)]
+			n = m + int(l)
+		default:
+			return ErrMalformed
+		}
+		data = data[n:]
+		if err := f(int(key>>3), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
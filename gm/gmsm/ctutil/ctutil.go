@@ -0,0 +1,127 @@
+// Package ctutil extends crypto/subtle with constant-time helpers for
+// secret byte strings and big-endian integers of a fixed size.
+//
+// The running time of the functions depends on the lengths of their
+// arguments only, never on their contents. Lengths are assumed public;
+// functions that take several integers require them to have the same
+// length and panic otherwise. Selectors are ints that must be 0 or 1, as
+// in crypto/subtle.
+package ctutil
+
+import (
+	"crypto/subtle"
+	"math/big"
+	"math/bits"
+)
+
+func checkLen(a, b []byte) {
+	if len(a) != len(b) {
+		panic("ctutil: length mismatch")
+	}
+}
+
+// Equal returns 1 if a and b have equal contents and 0 otherwise. Unlike
+// bytes.Equal, it takes the same time for any a and b of the same length.
+func Equal(a, b []byte) int {
+	return subtle.ConstantTimeCompare(a, b)
+}
+
+// Copy copies src to dst if v is 1 and leaves dst unchanged if v is 0.
+func Copy(v int, dst, src []byte) {
+	checkLen(dst, src)
+	subtle.ConstantTimeCopy(v, dst, src)
+}
+
+// Select sets dst to x if v is 1 and to y if v is 0. dst may alias x or y.
+func Select(v int, dst, x, y []byte) {
+	checkLen(dst, x)
+	checkLen(dst, y)
+	mask := byte(-v)
+	for i := range dst {
+		dst[i] = y[i] ^ (mask & (x[i] ^ y[i]))
+	}
+}
+
+// Less returns 1 if a < b and 0 otherwise, for big-endian unsigned
+// integers a and b of the same length.
+func Less(a, b []byte) int {
+	checkLen(a, b)
+	var borrow uint32
+	for i := len(a) - 1; i >= 0; i-- {
+		// borrow is 1 if a[i:] < b[i:] so far.
+		d := uint32(a[i]) - uint32(b[i]) - borrow
+		borrow = (d >> 8) & 1
+	}
+	return int(borrow)
+}
+
+// Sub sets dst = a - b mod 2^(8·len(a)) and returns the borrow, 1 if a < b.
+// dst may alias a or b.
+func Sub(dst, a, b []byte) int {
+	checkLen(dst, a)
+	checkLen(a, b)
+	var borrow uint32
+	for i := len(a) - 1; i >= 0; i-- {
+		d := uint32(a[i]) - uint32(b[i]) - borrow
+		dst[i] = byte(d)
+		borrow = (d >> 8) & 1
+	}
+	return int(borrow)
+}
+
+// ReduceOnce sets x = x - m if x ≥ m, for x < 2m, so that x ends up in
+// [0, m).
+func ReduceOnce(x, m []byte) {
+	t := make([]byte, len(x))
+	borrow := Sub(t, x, m)
+	Select(borrow, x, x, t)
+}
+
+// Reduce returns x mod m as len(m) bytes, for a big-endian integer x of
+// any length and a modulus m whose first byte is not zero.
+func Reduce(x, m []byte) []byte {
+	if len(m) == 0 || m[0] == 0 {
+		panic("ctutil: modulus with a leading zero byte")
+	}
+	// r holds 2r + bit < 2m, one byte more than m.
+	r := make([]byte, len(m)+1)
+	mm := append([]byte{0}, m...)
+	for _, b := range x {
+		for j := 7; j >= 0; j-- {
+			shiftLeft(r, uint(b>>uint(j))&1)
+			ReduceOnce(r, mm)
+		}
+	}
+	return r[1:]
+}
+
+// shiftLeft sets r = 2r + bit.
+func shiftLeft(r []byte, bit uint) {
+	carry := byte(bit)
+	for i := len(r) - 1; i >= 0; i-- {
+		c := r[i] >> 7
+		r[i] = r[i]<<1 | carry
+		carry = c
+	}
+}
+
+// FixedBytes returns x, which must not be negative, as a big-endian
+// integer of size bytes, truncated to its low size bytes if longer. Unlike
+// big.Int.Bytes followed by padding, it does not branch on the number of
+// leading zero bytes of x, only on its number of words, which is the same
+// for all but a 2^-(word size) fraction of the values of a size.
+func FixedBytes(x *big.Int, size int) []byte {
+	out := make([]byte, size)
+	words := x.Bits()
+	const wordBytes = bits.UintSize / 8
+	for i, w := range words {
+		for j := 0; j < wordBytes; j++ {
+			k := size - 1 - (i*wordBytes + j)
+			if k < 0 {
+				return out
+			}
+			out[k] = byte(w >> uint(8*j))
+		}
+	}
+	return out
+}
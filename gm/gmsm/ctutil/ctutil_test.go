@@ -0,0 +1,115 @@
+package ctutil
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func randBytes(r *rand.Rand, n int) []byte {
+	b := make([]byte, n)
+	r.Read(b)
+	// Favor equal and nearly equal prefixes.
+	if r.Intn(4) == 0 {
+		for i := 0; i < n/2; i++ {
+			b[i] = 0xff
+		}
+	}
+	return b
+}
+
+func TestEqualSelectCopy(t *testing.T) {
+	a, b := []byte("secret mac"), []byte("secret mad")
+	if Equal(a, a) != 1 || Equal(a, b) != 0 || Equal(a, a[:3]) != 0 {
+		t.Fatal("Equal")
+	}
+	dst := make([]byte, len(a))
+	Select(1, dst, a, b)
+	if !bytes.Equal(dst, a) {
+		t.Fatal("Select(1)")
+	}
+	Select(0, dst, a, b)
+	if !bytes.Equal(dst, b) {
+		t.Fatal("Select(0)")
+	}
+	Copy(0, dst, a)
+	if !bytes.Equal(dst, b) {
+		t.Fatal("Copy(0)")
+	}
+	Copy(1, dst, a)
+	if !bytes.Equal(dst, a) {
+		t.Fatal("Copy(1)")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic on a length mismatch")
+		}
+	}()
+	Select(1, dst, a, b[:2])
+}
+
+func TestArithmetic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		n := 1 + r.Intn(40)
+		a, b := randBytes(r, n), randBytes(r, n)
+		if r.Intn(8) == 0 {
+			b = append([]byte(nil), a...)
+		}
+		x, y := new(big.Int).SetBytes(a), new(big.Int).SetBytes(b)
+
+		want := 0
+		if x.Cmp(y) < 0 {
+			want = 1
+		}
+		if got := Less(a, b); got != want {
+			t.Fatalf("Less(%x, %x) = %d", a, b, got)
+		}
+
+		d := make([]byte, n)
+		if borrow := Sub(d, a, b); borrow != want {
+			t.Fatalf("Sub(%x, %x) borrow %d", a, b, borrow)
+		}
+		mod := new(big.Int).Lsh(big.NewInt(1), uint(8*n))
+		diff := new(big.Int).Sub(x, y)
+		if diff.Mod(diff, mod); !bytes.Equal(d, FixedBytes(diff, n)) {
+			t.Fatalf("Sub(%x, %x) = %x", a, b, d)
+		}
+
+		m := randBytes(r, 1+r.Intn(33))
+		m[0] |= 1
+		got := Reduce(a, m)
+		if exp := new(big.Int).Mod(x, new(big.Int).SetBytes(m)); !bytes.Equal(got, FixedBytes(exp, len(m))) {
+			t.Fatalf("Reduce(%x, %x) = %x, want %x", a, m, got, exp)
+		}
+	}
+}
+
+func TestReduceOnce(t *testing.T) {
+	m := []byte{0x80, 0x01}
+	for _, c := range []struct{ x, want []byte }{
+		{[]byte{0x00, 0x05}, []byte{0x00, 0x05}},
+		{[]byte{0x80, 0x00}, []byte{0x80, 0x00}},
+		{[]byte{0x80, 0x01}, []byte{0x00, 0x00}},
+		{[]byte{0xff, 0xff}, []byte{0x7f, 0xfe}},
+	} {
+		x := append([]byte(nil), c.x...)
+		if ReduceOnce(x, m); !bytes.Equal(x, c.want) {
+			t.Fatalf("ReduceOnce(%x) = %x, want %x", c.x, x, c.want)
+		}
+	}
+}
+
+func TestFixedBytes(t *testing.T) {
+	x, _ := new(big.Int).SetString("0102030405060708090a", 16)
+	if got := FixedBytes(x, 12); !bytes.Equal(got, []byte{0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}) {
+		t.Fatalf("padded: %x", got)
+	}
+	if got := FixedBytes(x, 3); !bytes.Equal(got, []byte{8, 9, 10}) {
+		t.Fatalf("truncated: %x", got)
+	}
+	if got := FixedBytes(new(big.Int), 2); !bytes.Equal(got, []byte{0, 0}) {
+		t.Fatalf("zero: %x", got)
+	}
+}
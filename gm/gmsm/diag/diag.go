@@ -0,0 +1,385 @@
+// Package diag reports how this build of gmsm runs on the current machine:
+// the CPU features it detects, the assembly or Go implementation each
+// algorithm uses, and the throughput of SM2, SM3 and SM4. The report
+// marshals to JSON, so that the reports of the nodes of a fleet can be
+// compared and a node that silently fell back to the pure Go code stands
+// out:
+//
+//	r, err := diag.Report(nil)
+//	...
+//	json.NewEncoder(os.Stdout).Encode(r)
+//
+// Benchmarks are timed loops run one after another on one goroutine, so
+// they measure a single core and should be run on an otherwise idle node.
+package diag
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/cpu"
+
+	"github.com/xuperchain/crypto/gm/gmsm/hwrng"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+	"github.com/xuperchain/crypto/gm/gmsm/sm9/bn256"
+)
+
+// DefaultDuration is the time spent on each benchmark when Options do not
+// set one.
+const DefaultDuration = 500 * time.Millisecond
+
+// ErrUnknownBenchmark is returned for a benchmark name not in Benchmarks.
+var ErrUnknownBenchmark = errors.New("diag: unknown benchmark")
+
+// Options select what Report measures. A nil *Options runs every benchmark
+// for DefaultDuration.
+type Options struct {
+	// Duration is the time spent on each benchmark.
+	Duration time.Duration
+	// Benchmarks are the names of the benchmarks to run, all of them if
+	// empty.
+	Benchmarks []string
+	// NoBenchmarks only reports the platform and backends.
+	NoBenchmarks bool
+}
+
+// Result is the report of a machine.
+type Result struct {
+	GoVersion  string `json:"go_version"`
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	NumCPU     int    `json:"num_cpu"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	// CPUFeatures are the features relevant to gmsm that the CPU has, by
+	// their lower-case names.
+	CPUFeatures []string    `json:"cpu_features"`
+	Backends    []Backend   `json:"backends"`
+	Benchmarks  []Benchmark `json:"benchmarks,omitempty"`
+	// Warnings describe a backend that does not match the CPU, such as an
+	// assembly path left unused on a CPU that supports it.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Backend describes the implementation of one algorithm.
+type Backend struct {
+	Name           string `json:"name"`
+	Implementation string `json:"implementation"`
+	// Accelerated reports whether the implementation uses instructions
+	// beyond those of the generic Go code.
+	Accelerated bool `json:"accelerated"`
+}
+
+// Benchmark is the throughput of one operation.
+type Benchmark struct {
+	Name      string  `json:"name"`
+	Ops       int     `json:"ops"`
+	NsPerOp   float64 `json:"ns_per_op"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	// MBPerSec is set for the operations on bulk data.
+	MBPerSec float64 `json:"mb_per_sec,omitempty"`
+}
+
+// Report detects the platform and backends and runs the benchmarks.
+func Report(opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	r := &Result{
+		GoVersion:   runtime.Version(),
+		GOOS:        runtime.GOOS,
+		GOARCH:      runtime.GOARCH,
+		NumCPU:      runtime.NumCPU(),
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		CPUFeatures: cpuFeatures(),
+	}
+	r.Backends, r.Warnings = backends()
+	if opts.NoBenchmarks {
+		return r, nil
+	}
+
+	d := opts.Duration
+	if d <= 0 {
+		d = DefaultDuration
+	}
+	names := opts.Benchmarks
+	if len(names) == 0 {
+		names = Benchmarks()
+	}
+	for _, name := range names {
+		if _, ok := benchmarks[name]; !ok {
+			return nil, fmt.Errorf("%v %q", ErrUnknownBenchmark, name)
+		}
+	}
+	for _, name := range names {
+		b, err := run(name, d)
+		if err != nil {
+			return nil, fmt.Errorf("diag: %s: %v", name, err)
+		}
+		r.Benchmarks = append(r.Benchmarks, b)
+	}
+	return r, nil
+}
+
+// cpuFeatures lists the features that the backends of gmsm use or that
+// tell the fallbacks apart.
+func cpuFeatures() []string {
+	var fs []string
+	add := func(name string, has bool) {
+		if has {
+			fs = append(fs, name)
+		}
+	}
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		add("aes", cpu.X86.HasAES)
+		add("avx", cpu.X86.HasAVX)
+		add("avx2", cpu.X86.HasAVX2)
+		add("bmi2", cpu.X86.HasBMI2)
+		add("adx", cpu.X86.HasADX)
+		add("pclmulqdq", cpu.X86.HasPCLMULQDQ)
+		add("rdrand", cpu.X86.HasRDRAND)
+		add("rdseed", cpu.X86.HasRDSEED)
+		add("gfni", sm4.GFNI())
+	case "arm64":
+		add("aes", cpu.ARM64.HasAES)
+		add("pmull", cpu.ARM64.HasPMULL)
+		add("sm3", cpu.ARM64.HasSM3)
+		add("sm4", cpu.ARM64.HasSM4)
+	}
+	return fs
+}
+
+// backends describes the implementation of each algorithm, and warns of
+// those that do not fit the CPU.
+func backends() ([]Backend, []string) {
+	var (
+		bs       []Backend
+		warnings []string
+	)
+	amd64 := runtime.GOARCH == "amd64"
+
+	t := sm2.CurveTuning()
+	b := Backend{Name: "sm2", Implementation: fmt.Sprintf("go, %d-bit window", t.Window)}
+	if t.TwoWay {
+		b.Implementation = fmt.Sprintf("avx2 two-way, %d-bit window", t.Window)
+		b.Accelerated = true
+		if !cpu.X86.HasAVX2 {
+			warnings = append(warnings, "sm2: built for AVX2 but the CPU lacks it; rebuild with the sm2serial tag")
+		}
+	} else if amd64 && cpu.X86.HasAVX2 {
+		warnings = append(warnings, "sm2: the CPU has AVX2 but this build computes serially (sm2serial tag)")
+	}
+	bs = append(bs, b)
+
+	bs = append(bs, Backend{Name: "sm3", Implementation: "go"})
+
+	b = Backend{Name: "sm4", Implementation: "go"}
+	switch {
+	case sm4.GFNI():
+		b = Backend{Name: "sm4", Implementation: "avx gfni", Accelerated: true}
+	case sm4.AESNI():
+		b = Backend{Name: "sm4", Implementation: "avx aes-ni", Accelerated: true}
+	case sm4.SM4E():
+		b = Backend{Name: "sm4", Implementation: "arm64 sm4e", Accelerated: true}
+	}
+	bs = append(bs, b)
+
+	b = Backend{Name: "sm9", Implementation: "go"}
+	if bn256.BMI2() {
+		b = Backend{Name: "sm9", Implementation: "amd64 bmi2", Accelerated: true}
+	} else if amd64 && cpu.X86.HasBMI2 {
+		warnings = append(warnings, "sm9: the CPU has BMI2 but this build does not use it (generic tag)")
+	}
+	bs = append(bs, b)
+
+	var sources []string
+	if _, err := hwrng.RDRAND(); err == nil {
+		sources = append(sources, "rdrand")
+	}
+	if _, err := hwrng.RDSEED(); err == nil {
+		sources = append(sources, "rdseed")
+	}
+	b = Backend{Name: "hwrng", Implementation: "none"}
+	if len(sources) > 0 {
+		b = Backend{Name: "hwrng", Implementation: strings.Join(sources, ", "), Accelerated: true}
+	}
+	bs = append(bs, b)
+
+	return bs, warnings
+}
+
+// bulkSize is the size of the buffer processed per operation by the bulk
+// benchmarks.
+const bulkSize = 8 << 10
+
+// benchmark prepares an operation, returning it with the bytes it
+// processes, or 0 for operations that are not on bulk data.
+type benchmark func() (op func() error, bytes int, err error)
+
+var benchmarks = map[string]benchmark{
+	"sm2-sign":    sm2Sign,
+	"sm2-verify":  sm2Verify,
+	"sm2-encrypt": sm2Encrypt,
+	"sm2-decrypt": sm2Decrypt,
+	"sm3":         sm3Hash,
+	"sm4-ctr":     sm4CTR,
+	"sm4-gcm":     sm4GCM,
+}
+
+// Benchmarks returns the names of the benchmarks, sorted.
+func Benchmarks() []string {
+	var names []string
+	for name := range benchmarks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run times the benchmark name for about d, and at least one operation.
+func run(name string, d time.Duration) (Benchmark, error) {
+	op, size, err := benchmarks[name]()
+	if err != nil {
+		return Benchmark{}, err
+	}
+	// a first call outside the timing warms up tables and caches
+	if err := op(); err != nil {
+		return Benchmark{}, err
+	}
+	var (
+		n       int
+		elapsed time.Duration
+	)
+	start := time.Now()
+	for elapsed < d {
+		if err := op(); err != nil {
+			return Benchmark{}, err
+		}
+		n++
+		elapsed = time.Since(start)
+	}
+	b := Benchmark{
+		Name:      name,
+		Ops:       n,
+		NsPerOp:   float64(elapsed.Nanoseconds()) / float64(n),
+		OpsPerSec: float64(n) / elapsed.Seconds(),
+	}
+	if size > 0 {
+		b.MBPerSec = float64(n) * float64(size) / 1e6 / elapsed.Seconds()
+	}
+	return b, nil
+}
+
+// message is what the SM2 benchmarks sign and encrypt, the size of a
+// digest or of a symmetric key.
+var message = make([]byte, 32)
+
+func sm2Sign() (func() error, int, error) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	return func() error {
+		_, _, err := sm2.Sm2Sign(priv, message, nil)
+		return err
+	}, 0, nil
+}
+
+func sm2Verify() (func() error, int, error) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	r, s, err := sm2.Sm2Sign(priv, message, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func() error {
+		if !sm2.Sm2Verify(&priv.PublicKey, message, nil, r, s) {
+			return errors.New("signature does not verify")
+		}
+		return nil
+	}, 0, nil
+}
+
+func sm2Encrypt() (func() error, int, error) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	return func() error {
+		_, err := sm2.Encrypt(&priv.PublicKey, message)
+		return err
+	}, 0, nil
+}
+
+func sm2Decrypt() (func() error, int, error) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	ct, err := sm2.Encrypt(&priv.PublicKey, message)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func() error {
+		_, err := sm2.Decrypt(priv, ct)
+		return err
+	}, 0, nil
+}
+
+func sm3Hash() (func() error, int, error) {
+	buf := make([]byte, bulkSize)
+	h := sm3.New()
+	return func() error {
+		h.Reset()
+		h.Write(buf)
+		h.Sum(nil)
+		return nil
+	}, bulkSize, nil
+}
+
+func sm4CTR() (func() error, int, error) {
+	key := make([]byte, sm4.BlockSize)
+	iv := make([]byte, sm4.BlockSize)
+	s, err := sm4.NewCTR(key, iv)
+	if err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, bulkSize)
+	return func() error {
+		s.XORKeyStream(buf, buf)
+		return nil
+	}, bulkSize, nil
+}
+
+func sm4GCM() (func() error, int, error) {
+	key := make([]byte, sm4.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, 0, err
+	}
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, 0, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	buf := make([]byte, bulkSize, bulkSize+aead.Overhead())
+	return func() error {
+		// the nonce repeats, which is harmless for a benchmark of random
+		// keys whose output is discarded
+		aead.Seal(buf[:0], nonce, buf, nil)
+		return nil
+	}, bulkSize, nil
+}
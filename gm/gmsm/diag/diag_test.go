@@ -0,0 +1,52 @@
+package diag
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	r, err := Report(&Options{Duration: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.GOARCH != runtime.GOARCH || r.NumCPU < 1 {
+		t.Errorf("platform %s/%s with %d CPUs", r.GOOS, r.GOARCH, r.NumCPU)
+	}
+	if len(r.Backends) == 0 {
+		t.Error("no backends")
+	}
+	if len(r.Benchmarks) != len(Benchmarks()) {
+		t.Fatalf("ran %d of %d benchmarks", len(r.Benchmarks), len(Benchmarks()))
+	}
+	for _, b := range r.Benchmarks {
+		if b.Ops < 1 || b.NsPerOp <= 0 || b.OpsPerSec <= 0 {
+			t.Errorf("%s: %+v", b.Name, b)
+		}
+	}
+	if _, err := json.Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReportOptions(t *testing.T) {
+	r, err := Report(&Options{NoBenchmarks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Benchmarks) != 0 {
+		t.Error("benchmarks ran")
+	}
+	r, err = Report(&Options{Duration: time.Millisecond, Benchmarks: []string{"sm3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Benchmarks) != 1 || r.Benchmarks[0].MBPerSec <= 0 {
+		t.Errorf("sm3: %+v", r.Benchmarks)
+	}
+	if _, err := Report(&Options{Benchmarks: []string{"md5"}}); err == nil {
+		t.Error("unknown benchmark accepted")
+	}
+}
@@ -0,0 +1,263 @@
+// Package dnssec signs and validates DNS resource record sets with the
+// SM2SM3 algorithm of RFC 9563, and computes DS records with the SM3 digest
+// type.
+//
+// The package works on the wire form of DNS data, so that it can be used
+// with any DNS server or library: records are given as owner names in
+// presentation form, types, classes, TTLs and RDATA in wire form. RDATA
+// must already be canonical, as RFC 4034, section 6.2 defines it; in
+// particular domain names inside the RDATA of the types listed there must
+// be lowercase and uncompressed. Owner and signer names are canonicalized
+// here; escaped characters in names are not supported.
+//
+// As RFC 9563 specifies, DNSKEY public keys are the 64 bytes x || y of the
+// SM2 point, and signatures the 64 bytes r || s. Signatures are made with
+// the SM2 default user ID, sm2.DefaultUID.
+package dnssec
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"strings"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Algorithm and digest type numbers of RFC 9563.
+const (
+	AlgorithmSM2SM3 = 17
+	DigestSM3       = 6
+)
+
+// Record types and the class used here.
+const (
+	TypeDS     = 43
+	TypeRRSIG  = 46
+	TypeDNSKEY = 48
+
+	ClassINET = 1
+)
+
+// DNSKEY flags of RFC 4034, section 2.1.1, and RFC 3757.
+const (
+	FlagZone        = 0x0100
+	FlagSecureEntry = 0x0001
+)
+
+const (
+	dnskeyProtocol   = 3
+	publicKeySize    = 64
+	signatureSize    = 64
+	maxNameWireBytes = 255
+)
+
+var (
+	// ErrName is returned for owner and signer names that are not valid
+	// domain names.
+	ErrName = errors.New("dnssec: invalid domain name")
+	// ErrMalformed is returned for RDATA that cannot be parsed.
+	ErrMalformed = errors.New("dnssec: malformed RDATA")
+	// ErrAlgorithm is returned for keys and signatures of another
+	// algorithm.
+	ErrAlgorithm = errors.New("dnssec: unsupported algorithm")
+	// ErrKey is returned when a DNSKEY is not a zone key able to make the
+	// RRSIG it is checked against.
+	ErrKey = errors.New("dnssec: key does not match signature")
+	// ErrRRset is returned for an empty RRset, or one whose records differ
+	// in owner, class or type, or do not match the RRSIG.
+	ErrRRset = errors.New("dnssec: invalid RRset")
+	// ErrValidity is returned when the current time is outside the validity
+	// period of a signature.
+	ErrValidity = errors.New("dnssec: signature expired or not yet valid")
+	// ErrSignature is returned for a signature that does not verify.
+	ErrSignature = errors.New("dnssec: invalid signature")
+)
+
+// nameWire returns name in canonical wire form: lowercase, uncompressed,
+// ending with the root label. A final dot is optional.
+func nameWire(name string) ([]byte, error) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	var b []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 || strings.Contains(label, "\\") {
+				return nil, ErrName
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	b = append(b, 0)
+	if len(b) > maxNameWireBytes {
+		return nil, ErrName
+	}
+	return b, nil
+}
+
+// labels returns the label count of the RRSIG Labels field: the labels of
+// name, not counting the root or a leading wildcard label.
+func labels(name string) int {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return 0
+	}
+	n := strings.Count(name, ".") + 1
+	if name == "*" || strings.HasPrefix(name, "*.") {
+		n--
+	}
+	return n
+}
+
+// DNSKEY is a DNSKEY record.
+type DNSKEY struct {
+	Name      string
+	TTL       uint32
+	Flags     uint16
+	Algorithm uint8
+	PublicKey []byte
+}
+
+// NewDNSKEY returns the SM2SM3 DNSKEY of pub owned by the zone name, with
+// the given flags, which should include FlagZone.
+func NewDNSKEY(name string, ttl uint32, flags uint16, pub *sm2.PublicKey) (*DNSKEY, error) {
+	if _, err := nameWire(name); err != nil {
+		return nil, err
+	}
+	if err := pub.Validate(); err != nil {
+		return nil, err
+	}
+	point := elliptic.Marshal(sm2.P256Sm2(), pub.X, pub.Y)
+	return &DNSKEY{Name: name, TTL: ttl, Flags: flags, Algorithm: AlgorithmSM2SM3, PublicKey: point[1:]}, nil
+}
+
+// ParseDNSKEY parses the RDATA of a DNSKEY record owned by name.
+func ParseDNSKEY(name string, ttl uint32, rdata []byte) (*DNSKEY, error) {
+	if len(rdata) < 4 || rdata[2] != dnskeyProtocol {
+		return nil, ErrMalformed
+	}
+	return &DNSKEY{
+		Name:      name,
+		TTL:       ttl,
+		Flags:     binary.BigEndian.Uint16(rdata),
+		Algorithm: rdata[3],
+		PublicKey: append([]byte(nil), rdata[4:]...),
+	}, nil
+}
+
+// RDATA returns the RDATA of k in wire form.
+func (k *DNSKEY) RDATA() []byte {
+	b := []byte{byte(k.Flags >> 8), byte(k.Flags), dnskeyProtocol, k.Algorithm}
+	return append(b, k.PublicKey...)
+}
+
+// RR returns k as a record.
+func (k *DNSKEY) RR() RR {
+	return RR{Name: k.Name, Type: TypeDNSKEY, Class: ClassINET, TTL: k.TTL, RDATA: k.RDATA()}
+}
+
+// KeyTag returns the key tag of k, as RFC 4034, appendix B computes it.
+func (k *DNSKEY) KeyTag() uint16 {
+	var ac uint32
+	for i, b := range k.RDATA() {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac)
+}
+
+// SM2PublicKey returns the public key of an SM2SM3 DNSKEY.
+func (k *DNSKEY) SM2PublicKey() (*sm2.PublicKey, error) {
+	if k.Algorithm != AlgorithmSM2SM3 {
+		return nil, ErrAlgorithm
+	}
+	if len(k.PublicKey) != publicKeySize {
+		return nil, ErrMalformed
+	}
+	curve := sm2.P256Sm2()
+	x, y := elliptic.Unmarshal(curve, append([]byte{4}, k.PublicKey...))
+	if x == nil {
+		return nil, ErrMalformed
+	}
+	return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// DS is a DS record.
+type DS struct {
+	Name       string
+	TTL        uint32
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+// DS returns the DS record of k with the SM3 digest type, for the parent
+// zone to publish.
+func (k *DNSKEY) DS() (*DS, error) {
+	return k.ds(DigestSM3, sm3.New())
+}
+
+func (k *DNSKEY) ds(digestType uint8, h hash.Hash) (*DS, error) {
+	owner, err := nameWire(k.Name)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(owner)
+	h.Write(k.RDATA())
+	return &DS{
+		Name:       k.Name,
+		TTL:        k.TTL,
+		KeyTag:     k.KeyTag(),
+		Algorithm:  k.Algorithm,
+		DigestType: digestType,
+		Digest:     h.Sum(nil),
+	}, nil
+}
+
+// ParseDS parses the RDATA of a DS record owned by name.
+func ParseDS(name string, ttl uint32, rdata []byte) (*DS, error) {
+	if len(rdata) < 5 {
+		return nil, ErrMalformed
+	}
+	return &DS{
+		Name:       name,
+		TTL:        ttl,
+		KeyTag:     binary.BigEndian.Uint16(rdata),
+		Algorithm:  rdata[2],
+		DigestType: rdata[3],
+		Digest:     append([]byte(nil), rdata[4:]...),
+	}, nil
+}
+
+// RDATA returns the RDATA of ds in wire form.
+func (ds *DS) RDATA() []byte {
+	b := []byte{byte(ds.KeyTag >> 8), byte(ds.KeyTag), ds.Algorithm, ds.DigestType}
+	return append(b, ds.Digest...)
+}
+
+// RR returns ds as a record.
+func (ds *DS) RR() RR {
+	return RR{Name: ds.Name, Type: TypeDS, Class: ClassINET, TTL: ds.TTL, RDATA: ds.RDATA()}
+}
+
+// Matches tells whether ds is an SM3 DS record of k, the check a validator
+// makes to follow a delegation.
+func (ds *DS) Matches(k *DNSKEY) bool {
+	if ds.DigestType != DigestSM3 {
+		return false
+	}
+	want, err := k.DS()
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSuffix(ds.Name, "."), strings.TrimSuffix(k.Name, ".")) &&
+		ds.KeyTag == want.KeyTag && ds.Algorithm == want.Algorithm && bytes.Equal(ds.Digest, want.Digest)
+}
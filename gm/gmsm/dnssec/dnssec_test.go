@@ -0,0 +1,177 @@
+package dnssec
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// TestRFC4034 checks the key tag and DS digest input against the example of
+// RFC 4034, section 5.4, with its RSA/SHA-1 key and SHA-1 digest.
+func TestRFC4034(t *testing.T) {
+	pub, _ := base64.StdEncoding.DecodeString("AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/" +
+		"2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLU" +
+		"Uh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw==")
+	k := &DNSKEY{Name: "dskey.example.com.", TTL: 86400, Flags: FlagZone, Algorithm: 5, PublicKey: pub}
+	if tag := k.KeyTag(); tag != 60485 {
+		t.Fatalf("key tag %d", tag)
+	}
+	ds, err := k.ds(1, sha1.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(ds.Digest); got != "2bb183af5f22588179a53b0a98631fad1a292118" {
+		t.Fatalf("digest %s", got)
+	}
+	if !bytes.Equal(ds.RDATA()[:4], []byte{0xec, 0x45, 5, 1}) {
+		t.Fatalf("DS RDATA %x", ds.RDATA())
+	}
+}
+
+func newZoneKey(t *testing.T, zone string) (*DNSKEY, *sm2.PrivateKey) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewDNSKEY(zone, 3600, FlagZone|FlagSecureEntry, &priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k, priv
+}
+
+func aRecords(name string, addrs ...byte) []RR {
+	var rrset []RR
+	for _, a := range addrs {
+		rrset = append(rrset, RR{Name: name, Type: 1, Class: ClassINET, TTL: 300, RDATA: []byte{192, 0, 2, a}})
+	}
+	return rrset
+}
+
+func TestSignVerify(t *testing.T) {
+	k, priv := newZoneKey(t, "Example.COM.")
+	now := time.Now()
+	rrset := aRecords("www.example.com.", 2, 1, 3)
+	sig, err := Sign(rrset, k, priv, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Labels != 3 || sig.KeyTag != k.KeyTag() || len(sig.Signature) != 64 {
+		t.Fatalf("unexpected RRSIG %+v", sig)
+	}
+
+	// The signature survives the wire, and the order, case and duplicates
+	// of records do not matter.
+	rdata, err := sig.RDATA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseRRSIG(sig.Name, sig.TTL, rdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseDNSKEY(k.Name, k.TTL, k.RDATA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.Verify(key, aRecords("WWW.example.com", 3, 1, 2, 1), now); err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := newZoneKey(t, "example.com.")
+	for name, c := range map[string]struct {
+		key   *DNSKEY
+		rrset []RR
+		now   time.Time
+		err   error
+	}{
+		"changed":   {k, aRecords("www.example.com.", 1, 2, 4), now, ErrSignature},
+		"missing":   {k, aRecords("www.example.com.", 1, 2), now, ErrSignature},
+		"renamed":   {k, aRecords("ftp.example.com.", 1, 2, 3), now, ErrSignature},
+		"expired":   {k, rrset, now.Add(2 * time.Hour), ErrValidity},
+		"early":     {k, rrset, now.Add(-2 * time.Hour), ErrValidity},
+		"other key": {other, rrset, now, ErrKey},
+		"mixed":     {k, append(aRecords("www.example.com.", 1), aRecords("example.com.", 2)...), now, ErrRRset},
+		"empty":     {k, nil, now, ErrRRset},
+	} {
+		if err := sig.Verify(c.key, c.rrset, c.now); err != c.err {
+			t.Errorf("%s: got %v, want %v", name, err, c.err)
+		}
+	}
+
+	if _, err := Sign(aRecords("www.example.net.", 1), k, priv, now, now); err != ErrRRset {
+		t.Fatalf("signed out of zone: %v", err)
+	}
+	notZone := *k
+	notZone.Flags = 0
+	if _, err := Sign(rrset, &notZone, priv, now, now); err != ErrKey {
+		t.Fatalf("signed with a non-zone key: %v", err)
+	}
+}
+
+func TestWildcard(t *testing.T) {
+	k, priv := newZoneKey(t, "example.com.")
+	now := time.Now()
+	sig, err := Sign(aRecords("*.example.com.", 7), k, priv, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Labels != 2 {
+		t.Fatalf("labels %d", sig.Labels)
+	}
+	// An answer synthesized from the wildcard verifies with its signature.
+	if err := sig.Verify(k, aRecords("a.b.example.com.", 7), now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDS(t *testing.T) {
+	k, _ := newZoneKey(t, "example.com.")
+	ds, err := k.DS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.DigestType != DigestSM3 || ds.Algorithm != AlgorithmSM2SM3 || len(ds.Digest) != 32 {
+		t.Fatalf("unexpected DS %+v", ds)
+	}
+	parsed, err := ParseDS("EXAMPLE.com", ds.TTL, ds.RDATA())
+	if err != nil || !parsed.Matches(k) {
+		t.Fatalf("DS does not match its key: %v", err)
+	}
+	other, _ := newZoneKey(t, "example.com.")
+	if parsed.Matches(other) {
+		t.Fatal("DS matches another key")
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	k, _ := newZoneKey(t, "example.com.")
+	short := *k
+	short.PublicKey = short.PublicKey[:63]
+	if _, err := short.SM2PublicKey(); err != ErrMalformed {
+		t.Fatalf("short key: %v", err)
+	}
+	offCurve := *k
+	offCurve.PublicKey = append([]byte(nil), k.PublicKey...)
+	offCurve.PublicKey[63] ^= 1
+	if _, err := offCurve.SM2PublicKey(); err != ErrMalformed {
+		t.Fatalf("point off the curve: %v", err)
+	}
+	for _, rdata := range [][]byte{
+		make([]byte, 18),
+		append(make([]byte, 18), 3, 'c', 'o'),
+		append(make([]byte, 18), 64),
+	} {
+		if _, err := ParseRRSIG("example.com.", 0, rdata); err != ErrMalformed {
+			t.Errorf("%x: got %v", rdata, err)
+		}
+	}
+	if _, err := NewDNSKEY("a..b", 0, FlagZone, &sm2.PublicKey{}); err != ErrName {
+		t.Fatalf("empty label: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package dnssec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// RR is a resource record with its RDATA in canonical wire form.
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	RDATA []byte
+}
+
+// RRSIG is an RRSIG record.
+type RRSIG struct {
+	Name        string
+	TTL         uint32
+	TypeCovered uint16
+	Algorithm   uint8
+	Labels      uint8
+	OrigTTL     uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+// ParseRRSIG parses the RDATA of an RRSIG record owned by name.
+func ParseRRSIG(name string, ttl uint32, rdata []byte) (*RRSIG, error) {
+	if len(rdata) < 19 {
+		return nil, ErrMalformed
+	}
+	sig := &RRSIG{
+		Name:        name,
+		TTL:         ttl,
+		TypeCovered: binary.BigEndian.Uint16(rdata),
+		Algorithm:   rdata[2],
+		Labels:      rdata[3],
+		OrigTTL:     binary.BigEndian.Uint32(rdata[4:]),
+		Expiration:  binary.BigEndian.Uint32(rdata[8:]),
+		Inception:   binary.BigEndian.Uint32(rdata[12:]),
+		KeyTag:      binary.BigEndian.Uint16(rdata[16:]),
+	}
+	// The signer name is uncompressed, so it ends at its root label.
+	var labels []string
+	i := 18
+	for {
+		if i >= len(rdata) {
+			return nil, ErrMalformed
+		}
+		n := int(rdata[i])
+		i++
+		if n == 0 {
+			break
+		}
+		if n > 63 || i+n > len(rdata) {
+			return nil, ErrMalformed
+		}
+		labels = append(labels, string(rdata[i:i+n]))
+		i += n
+	}
+	sig.SignerName = strings.Join(labels, ".") + "."
+	if _, err := nameWire(sig.SignerName); err != nil {
+		return nil, ErrMalformed
+	}
+	sig.Signature = append([]byte(nil), rdata[i:]...)
+	return sig, nil
+}
+
+// rdataPrefix returns the RDATA of sig without the signature, with the
+// signer name in canonical form.
+func (sig *RRSIG) rdataPrefix() ([]byte, error) {
+	signer, err := nameWire(sig.SignerName)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 18, 18+len(signer))
+	binary.BigEndian.PutUint16(b, sig.TypeCovered)
+	b[2], b[3] = sig.Algorithm, sig.Labels
+	binary.BigEndian.PutUint32(b[4:], sig.OrigTTL)
+	binary.BigEndian.PutUint32(b[8:], sig.Expiration)
+	binary.BigEndian.PutUint32(b[12:], sig.Inception)
+	binary.BigEndian.PutUint16(b[16:], sig.KeyTag)
+	return append(b, signer...), nil
+}
+
+// RDATA returns the RDATA of sig in wire form.
+func (sig *RRSIG) RDATA() ([]byte, error) {
+	b, err := sig.rdataPrefix()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, sig.Signature...), nil
+}
+
+// checkRRset checks that rrset is non-empty and its records share owner,
+// class and type, and returns the canonical owner.
+func checkRRset(rrset []RR) ([]byte, error) {
+	if len(rrset) == 0 || rrset[0].Type == TypeRRSIG {
+		return nil, ErrRRset
+	}
+	owner, err := nameWire(rrset[0].Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range rrset[1:] {
+		name, err := nameWire(rr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(name, owner) || rr.Type != rrset[0].Type || rr.Class != rrset[0].Class {
+			return nil, ErrRRset
+		}
+	}
+	return owner, nil
+}
+
+// isSubdomain tells whether the canonical wire name child is parent or below
+// it.
+func isSubdomain(child, parent []byte) bool {
+	for {
+		if bytes.Equal(child, parent) {
+			return true
+		}
+		if child[0] == 0 {
+			return false
+		}
+		child = child[1+int(child[0]):]
+	}
+}
+
+// signedData returns the data sig covers for rrset, as RFC 4034, section
+// 3.1.8.1 defines it: the RRSIG RDATA without the signature and the records
+// in canonical form and order, with duplicates removed.
+func (sig *RRSIG) signedData(rrset []RR, owner []byte) ([]byte, error) {
+	b, err := sig.rdataPrefix()
+	if err != nil {
+		return nil, err
+	}
+	// A record expanded from a wildcard is signed as the wildcard.
+	if n := labels(rrset[0].Name); n > int(sig.Labels) {
+		for i := 0; i < n-int(sig.Labels); i++ {
+			owner = owner[1+int(owner[0]):]
+		}
+		owner = append([]byte{1, '*'}, owner...)
+	}
+	rdatas := make([][]byte, len(rrset))
+	for i, rr := range rrset {
+		rdatas[i] = rr.RDATA
+	}
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+	var fixed [10]byte
+	binary.BigEndian.PutUint16(fixed[0:], rrset[0].Type)
+	binary.BigEndian.PutUint16(fixed[2:], rrset[0].Class)
+	binary.BigEndian.PutUint32(fixed[4:], sig.OrigTTL)
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		if len(rdata) > 0xffff {
+			return nil, ErrRRset
+		}
+		binary.BigEndian.PutUint16(fixed[8:], uint16(len(rdata)))
+		b = append(b, owner...)
+		b = append(b, fixed[:]...)
+		b = append(b, rdata...)
+	}
+	return b, nil
+}
+
+// Sign returns the RRSIG of rrset made with priv, the key of the zone key k,
+// valid from inception to expiration. The original TTL is that of the
+// first record.
+func Sign(rrset []RR, k *DNSKEY, priv *sm2.PrivateKey, inception, expiration time.Time) (*RRSIG, error) {
+	owner, err := checkRRset(rrset)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := k.SM2PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 || k.Flags&FlagZone == 0 {
+		return nil, ErrKey
+	}
+	signer, err := nameWire(k.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !isSubdomain(owner, signer) {
+		return nil, ErrRRset
+	}
+	sig := &RRSIG{
+		Name:        rrset[0].Name,
+		TTL:         rrset[0].TTL,
+		TypeCovered: rrset[0].Type,
+		Algorithm:   AlgorithmSM2SM3,
+		Labels:      uint8(labels(rrset[0].Name)),
+		OrigTTL:     rrset[0].TTL,
+		Expiration:  uint32(expiration.Unix()),
+		Inception:   uint32(inception.Unix()),
+		KeyTag:      k.KeyTag(),
+		SignerName:  k.Name,
+	}
+	data, err := sig.signedData(rrset, owner)
+	if err != nil {
+		return nil, err
+	}
+	s, err := sm2.SignSignature(priv, data)
+	if err != nil {
+		return nil, err
+	}
+	if sig.Signature, err = s.Encode(sm2.FormatRaw); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Verify checks sig over rrset with the zone key k at the time now, making
+// the checks of RFC 4035, section 5.3.1 that concern the RRSIG, the RRset
+// and the key. Whether k is trusted is for the caller to decide, for
+// instance with DS.Matches.
+func (sig *RRSIG) Verify(k *DNSKEY, rrset []RR, now time.Time) error {
+	owner, err := checkRRset(rrset)
+	if err != nil {
+		return err
+	}
+	if sig.Algorithm != AlgorithmSM2SM3 || k.Algorithm != AlgorithmSM2SM3 {
+		return ErrAlgorithm
+	}
+	signer, err := nameWire(sig.SignerName)
+	if err != nil {
+		return err
+	}
+	keyOwner, err := nameWire(k.Name)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(signer, keyOwner) || k.Flags&FlagZone == 0 || k.KeyTag() != sig.KeyTag {
+		return ErrKey
+	}
+	if rrset[0].Type != sig.TypeCovered || !isSubdomain(owner, signer) ||
+		int(sig.Labels) > labels(rrset[0].Name) {
+		return ErrRRset
+	}
+	// Times are compared in serial number arithmetic, RFC 1982.
+	t := uint32(now.Unix())
+	if int32(t-sig.Inception) < 0 || int32(sig.Expiration-t) < 0 {
+		return ErrValidity
+	}
+	pub, err := k.SM2PublicKey()
+	if err != nil {
+		return err
+	}
+	var s sm2.Signature
+	if len(sig.Signature) != signatureSize || s.DecodeFormat(sm2.FormatRaw, sig.Signature) != nil {
+		return ErrSignature
+	}
+	data, err := sig.signedData(rrset, owner)
+	if err != nil {
+		return err
+	}
+	if !sm2.VerifySignature(pub, data, &s) {
+		return ErrSignature
+	}
+	return nil
+}
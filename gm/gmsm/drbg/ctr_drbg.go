@@ -0,0 +1,230 @@
+package drbg
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+const (
+	// ctrKeyLen and ctrSeedLen are the key and seed lengths of CTR_DRBG
+	// with SM4: a 128-bit key and a seed of key plus one block, 256 bits.
+	ctrKeyLen  = sm4.KeySize
+	ctrSeedLen = sm4.KeySize + sm4.BlockSize
+)
+
+// CTRDRBG is an SM4 based CTR_DRBG with derivation function. It is safe for
+// concurrent use.
+type CTRDRBG struct {
+	mu sync.Mutex
+
+	entropy              io.Reader
+	block                cipher.Block
+	v                    [sm4.BlockSize]byte
+	reseedCounter        uint64
+	lastReseed           time.Time
+	reseedInterval       uint64
+	reseedTime           time.Duration
+	predictionResistance bool
+}
+
+// NewCTRDRBG instantiates a CTR_DRBG from the entropy source, which is
+// crypto/rand.Reader if nil. personalization is an optional string that
+// separates this instance from others seeded by the same source.
+// With predictionResistance set, every Read reseeds from the entropy source.
+func NewCTRDRBG(entropy io.Reader, personalization []byte, predictionResistance bool) (*CTRDRBG, error) {
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+	if len(personalization) > MaxBytesPerRequest {
+		return nil, ErrTooLong
+	}
+
+	d := &CTRDRBG{
+		entropy:              entropy,
+		reseedInterval:       DefaultReseedInterval,
+		reseedTime:           DefaultReseedTime,
+		predictionResistance: predictionResistance,
+	}
+
+	seed := make([]byte, entropyLen+nonceLen)
+	if _, err := io.ReadFull(entropy, seed); err != nil {
+		return nil, ErrEntropy
+	}
+	var seedMaterial [ctrSeedLen]byte
+	blockCipherDF(seedMaterial[:], append(seed, personalization...))
+	d.block = newSM4(make([]byte, ctrKeyLen))
+	d.update(seedMaterial[:])
+	d.reseedCounter = 1
+	d.lastReseed = time.Now()
+
+	return d, nil
+}
+
+func newSM4(key []byte) cipher.Block {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	return block
+}
+
+// SetReseedInterval changes how many generate requests and how much time may
+// pass between two reseeds. Values that are not positive keep the current setting.
+func (d *CTRDRBG) SetReseedInterval(requests uint64, period time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if requests > 0 {
+		d.reseedInterval = requests
+	}
+	if period > 0 {
+		d.reseedTime = period
+	}
+}
+
+// Reseed mixes fresh entropy and the optional additional input into the state.
+func (d *CTRDRBG) Reseed(additional []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.reseed(additional)
+}
+
+func (d *CTRDRBG) reseed(additional []byte) error {
+	if len(additional) > MaxBytesPerRequest {
+		return ErrTooLong
+	}
+
+	entropy := make([]byte, entropyLen, entropyLen+len(additional))
+	if _, err := io.ReadFull(d.entropy, entropy); err != nil {
+		return ErrEntropy
+	}
+
+	var seedMaterial [ctrSeedLen]byte
+	blockCipherDF(seedMaterial[:], append(entropy, additional...))
+	d.update(seedMaterial[:])
+	d.reseedCounter = 1
+	d.lastReseed = time.Now()
+
+	return nil
+}
+
+// Read fills p with pseudorandom bytes, splitting large requests into several
+// generate calls. It implements io.Reader.
+func (d *CTRDRBG) Read(p []byte) (int, error) {
+	return d.ReadWithAdditionalInput(p, nil)
+}
+
+// ReadWithAdditionalInput is Read with additional input mixed into each
+// generate call.
+func (d *CTRDRBG) ReadWithAdditionalInput(p, additional []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		end := n + MaxBytesPerRequest
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := d.generate(p[n:end], additional); err != nil {
+			return n, err
+		}
+		n = end
+	}
+	return n, nil
+}
+
+func (d *CTRDRBG) generate(out, additional []byte) error {
+	if len(additional) > MaxBytesPerRequest {
+		return ErrTooLong
+	}
+
+	if d.predictionResistance || d.reseedCounter > d.reseedInterval || time.Since(d.lastReseed) > d.reseedTime {
+		if err := d.reseed(additional); err != nil {
+			return err
+		}
+		additional = nil
+	}
+
+	var input [ctrSeedLen]byte
+	if len(additional) > 0 {
+		blockCipherDF(input[:], additional)
+		d.update(input[:])
+	}
+
+	var block [sm4.BlockSize]byte
+	for len(out) > 0 {
+		addMod(d.v[:], []byte{1})
+		d.block.Encrypt(block[:], d.v[:])
+		out = out[copy(out, block[:]):]
+	}
+
+	d.update(input[:])
+	d.reseedCounter++
+
+	return nil
+}
+
+// update is CTR_DRBG_Update: it derives a new key and V from the current
+// ones and the seedlen bytes of provided data.
+func (d *CTRDRBG) update(provided []byte) {
+	var temp [ctrSeedLen]byte
+	for i := 0; i < ctrSeedLen; i += sm4.BlockSize {
+		addMod(d.v[:], []byte{1})
+		d.block.Encrypt(temp[i:], d.v[:])
+	}
+	for i := range temp {
+		temp[i] ^= provided[i]
+	}
+	d.block = newSM4(temp[:ctrKeyLen])
+	copy(d.v[:], temp[ctrKeyLen:])
+}
+
+// blockCipherDF is the block cipher derivation function, it fills out with
+// len(out) bytes derived from input.
+func blockCipherDF(out, input []byte) {
+	// S = L || N || input || 0x80, zero padded to a multiple of the block.
+	s := make([]byte, 8, 8+len(input)+sm4.BlockSize)
+	binary.BigEndian.PutUint32(s[0:], uint32(len(input)))
+	binary.BigEndian.PutUint32(s[4:], uint32(len(out)))
+	s = append(s, input...)
+	s = append(s, 0x80)
+	for len(s)%sm4.BlockSize != 0 {
+		s = append(s, 0)
+	}
+
+	var key [ctrKeyLen]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	bcc := newSM4(key[:])
+	var temp [ctrSeedLen]byte
+	for i := 0; i < ctrSeedLen; i += sm4.BlockSize {
+		var iv [sm4.BlockSize]byte
+		binary.BigEndian.PutUint32(iv[:], uint32(i/sm4.BlockSize))
+		// BCC(K, IV || S), a CBC-MAC with a zero IV.
+		var chain [sm4.BlockSize]byte
+		bcc.Encrypt(chain[:], iv[:])
+		for j := 0; j < len(s); j += sm4.BlockSize {
+			for k := range chain {
+				chain[k] ^= s[j+k]
+			}
+			bcc.Encrypt(chain[:], chain[:])
+		}
+		copy(temp[i:], chain[:])
+	}
+
+	block := newSM4(temp[:ctrKeyLen])
+	x := temp[ctrKeyLen:]
+	for len(out) > 0 {
+		block.Encrypt(x, x)
+		out = out[copy(out, x):]
+	}
+}
@@ -0,0 +1,113 @@
+package drbg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCTRDRBGKnownAnswer(t *testing.T) {
+	seed := make([]byte, 256)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	d, err := NewCTRDRBG(bytes.NewReader(seed), []byte("personalization"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]byte, 64)
+	d.Read(out)
+	if got := hex.EncodeToString(out); got != "70d46f0fded893cfe503da9bd09e0fe68f8f7beb4552f4c764e7f2c146398b68fccc4be781647740fe826981e3c2a0ab73b8ad6a80c03007f53866eed786e60a" {
+		t.Fatalf("first output %s", got)
+	}
+	d.ReadWithAdditionalInput(out, []byte("additional"))
+	if got := hex.EncodeToString(out); got != "f67cbb9e1a183fba3ff7152fc247999e5b3832d4ca3e3dd92e4539f80e8d43c8c803fa0d7b717719b64ec12728d841e071bfcb2544a63d51565df5ff98783741" {
+		t.Fatalf("output with additional input %s", got)
+	}
+}
+
+func TestCTRDRBGDeterministic(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x5a}, 1024)
+
+	d1, err := NewCTRDRBG(bytes.NewReader(seed), []byte("test"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := NewCTRDRBG(bytes.NewReader(seed), []byte("test"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d3, err := NewCTRDRBG(bytes.NewReader(seed), []byte("other"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out1 := make([]byte, 100)
+	out2 := make([]byte, 100)
+	out3 := make([]byte, 100)
+	d1.Read(out1)
+	d2.Read(out2)
+	d3.Read(out3)
+	if !bytes.Equal(out1, out2) {
+		t.Fatal("same seed must give the same output")
+	}
+	if bytes.Equal(out1, out3) {
+		t.Fatal("different personalization strings must give different outputs")
+	}
+	d1.Read(out2)
+	if bytes.Equal(out1, out2) {
+		t.Fatal("consecutive outputs must differ")
+	}
+
+	// Requests larger than MaxBytesPerRequest are split.
+	big := make([]byte, 3*MaxBytesPerRequest+5)
+	if n, err := d1.Read(big); err != nil || n != len(big) {
+		t.Fatalf("Read returned %d, %v", n, err)
+	}
+}
+
+func TestCTRDRBGReseedInterval(t *testing.T) {
+	// Enough entropy to instantiate and reseed once.
+	d, err := NewCTRDRBG(bytes.NewReader(make([]byte, entropyLen+nonceLen+entropyLen)), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetReseedInterval(2, 0)
+
+	out := make([]byte, 16)
+	for i := 0; i < 4; i++ {
+		if _, err := d.Read(out); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	// The fifth request needs a second reseed, which finds no entropy.
+	if _, err := d.Read(out); err != ErrEntropy {
+		t.Fatalf("expected ErrEntropy after the reseed interval, got %v", err)
+	}
+}
+
+func TestCTRDRBGEntropyExhausted(t *testing.T) {
+	d, err := NewCTRDRBG(bytes.NewReader(make([]byte, entropyLen+nonceLen)), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Read(make([]byte, 16)); err != ErrEntropy {
+		t.Fatalf("expected ErrEntropy with prediction resistance, got %v", err)
+	}
+	if _, err := NewCTRDRBG(bytes.NewReader(make([]byte, entropyLen)), nil, false); err != ErrEntropy {
+		t.Fatalf("expected ErrEntropy for a short seed, got %v", err)
+	}
+}
+
+func BenchmarkCTRDRBG(b *testing.B) {
+	d, err := NewCTRDRBG(nil, nil, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		d.Read(buf)
+	}
+}
@@ -0,0 +1,178 @@
+package drbg
+
+import (
+	"crypto/cipher"
+	"errors"
+	"hash"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+const (
+	// NumPools is the number of entropy pools of Fortuna.
+	NumPools = 32
+	// MaxEventSize is the largest data of a single entropy event.
+	MaxEventSize = 32
+
+	// minPoolSize is the number of bytes pool 0 must hold before a reseed.
+	minPoolSize = 64
+	// minReseedInterval is the minimum time between two reseeds, which
+	// limits how fast an attacker who injects events can drain the pools.
+	minReseedInterval = 100 * time.Millisecond
+	// maxGenerate is the largest output of the generator between two
+	// rekeyings.
+	maxGenerate = 1 << 20
+)
+
+var (
+	ErrNotSeeded = errors.New("drbg: fortuna has not collected enough entropy yet")
+	ErrEvent     = errors.New("drbg: entropy event must be 1 to 32 bytes")
+)
+
+// Fortuna is the Fortuna generator of Ferguson and Schneier with SM3 pools
+// and an SM4-CTR generator. It is safe for concurrent use.
+//
+// Entropy events from any number of sources, such as the OS, a hardware
+// jitter source and application events, are spread over 32 SM3 pools. The
+// generator is reseeded from pool 0 as soon as it holds 64 bytes, and at
+// most every 100ms; pool i joins every 2^i-th reseed. Even if a source
+// degrades, or an attacker controls all but one of them, the pools that
+// are reseeded rarely eventually accumulate enough entropy from the
+// remaining sources to recover a secure state.
+//
+// Unlike HashDRBG and CTRDRBG, Fortuna does not read entropy itself: feed
+// it with AddRandomEvent, or Poll for sources that implement io.Reader,
+// for as long as it runs.
+type Fortuna struct {
+	mu sync.Mutex
+
+	pools       [NumPools]hash.Hash
+	pool0Size   int
+	nextPool    [256]uint8
+	reseedCount uint64
+	lastReseed  time.Time
+	minInterval time.Duration
+
+	key     [sm4.KeySize]byte
+	counter [sm4.BlockSize]byte
+	block   cipher.Block
+}
+
+// NewFortuna returns an unseeded Fortuna generator. Read fails with
+// ErrNotSeeded until the first reseed.
+func NewFortuna() *Fortuna {
+	f := &Fortuna{minInterval: minReseedInterval}
+	for i := range f.pools {
+		f.pools[i] = sm3.New()
+	}
+	return f
+}
+
+// AddRandomEvent adds an entropy event of 1 to 32 bytes from the numbered
+// source. The events of each source go to the pools in turn.
+func (f *Fortuna) AddRandomEvent(source uint8, data []byte) error {
+	if len(data) == 0 || len(data) > MaxEventSize {
+		return ErrEvent
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.nextPool[source]
+	f.nextPool[source] = (i + 1) % NumPools
+	f.pools[i].Write([]byte{source, byte(len(data))})
+	f.pools[i].Write(data)
+	if i == 0 {
+		f.pool0Size += 2 + len(data)
+	}
+	return nil
+}
+
+// Poll reads n bytes from r and adds them as events of the numbered source.
+// The bytes read before an error are still added, and a failing source
+// does not affect the events of the others.
+func (f *Fortuna) Poll(source uint8, r io.Reader, n int) error {
+	var buf [MaxEventSize]byte
+	for n > 0 {
+		size := n
+		if size > MaxEventSize {
+			size = MaxEventSize
+		}
+		m, err := io.ReadFull(r, buf[:size])
+		if m > 0 {
+			f.AddRandomEvent(source, buf[:m])
+		}
+		if err != nil {
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
+
+// Read fills p with pseudorandom bytes, reseeding first if the pools allow.
+// It implements io.Reader.
+func (f *Fortuna) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.pool0Size >= minPoolSize && (f.reseedCount == 0 || time.Since(f.lastReseed) >= f.minInterval) {
+		f.reseedFromPools()
+	}
+	if f.reseedCount == 0 {
+		return 0, ErrNotSeeded
+	}
+
+	n := 0
+	for n < len(p) {
+		end := n + maxGenerate
+		if end > len(p) {
+			end = len(p)
+		}
+		f.generate(p[n:end])
+		n = end
+	}
+	return n, nil
+}
+
+// reseedFromPools drains pool i into the generator key on every 2^i-th
+// reseed.
+func (f *Fortuna) reseedFromPools() {
+	f.reseedCount++
+	f.lastReseed = time.Now()
+
+	h := sm3.New()
+	h.Write(f.key[:])
+	for i := range f.pools {
+		if f.reseedCount%(1<<uint(i)) != 0 {
+			break
+		}
+		h.Write(f.pools[i].Sum(nil))
+		f.pools[i].Reset()
+	}
+	f.pool0Size = 0
+
+	copy(f.key[:], h.Sum(nil))
+	f.block = newSM4(f.key[:])
+	addMod(f.counter[:], []byte{1})
+}
+
+// generate fills out, at most maxGenerate bytes, with SM4-CTR keystream and
+// then rekeys the generator so that earlier output cannot be recovered from
+// the state.
+func (f *Fortuna) generate(out []byte) {
+	var block [sm4.BlockSize]byte
+	for len(out) > 0 {
+		f.block.Encrypt(block[:], f.counter[:])
+		addMod(f.counter[:], []byte{1})
+		out = out[copy(out, block[:]):]
+	}
+
+	f.block.Encrypt(f.key[:], f.counter[:])
+	addMod(f.counter[:], []byte{1})
+	f.block = newSM4(f.key[:])
+}
@@ -0,0 +1,172 @@
+package drbg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+// feed adds n events of 32 bytes from the source, so that every pool gets
+// one event once n reaches NumPools.
+func feed(t *testing.T, f *Fortuna, source uint8, n int) {
+	for i := 0; i < n; i++ {
+		if err := f.AddRandomEvent(source, bytes.Repeat([]byte{byte(i)}, MaxEventSize)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFortunaSeeding(t *testing.T) {
+	f := NewFortuna()
+	out := make([]byte, 32)
+	if _, err := f.Read(out); err != ErrNotSeeded {
+		t.Fatalf("expected ErrNotSeeded, got %v", err)
+	}
+
+	// One event of 34 bytes in pool 0 is not enough, two are.
+	feed(t, f, 0, NumPools)
+	if _, err := f.Read(out); err != ErrNotSeeded {
+		t.Fatalf("expected ErrNotSeeded, got %v", err)
+	}
+	feed(t, f, 1, 1)
+	if n, err := f.Read(out); err != nil || n != len(out) {
+		t.Fatalf("Read returned %d, %v", n, err)
+	}
+	if f.reseedCount != 1 {
+		t.Fatalf("reseed count %d", f.reseedCount)
+	}
+
+	for _, data := range [][]byte{nil, make([]byte, MaxEventSize+1)} {
+		if err := f.AddRandomEvent(0, data); err != ErrEvent {
+			t.Fatalf("expected ErrEvent for %d bytes, got %v", len(data), err)
+		}
+	}
+}
+
+func TestFortunaDeterministic(t *testing.T) {
+	newSeeded := func(source uint8) *Fortuna {
+		f := NewFortuna()
+		feed(t, f, source, 2*NumPools)
+		return f
+	}
+	f1, f2, f3 := newSeeded(0), newSeeded(0), newSeeded(1)
+
+	out1 := make([]byte, 100)
+	out2 := make([]byte, 100)
+	out3 := make([]byte, 100)
+	f1.Read(out1)
+	f2.Read(out2)
+	f3.Read(out3)
+	if !bytes.Equal(out1, out2) {
+		t.Fatal("same events must give the same output")
+	}
+	if bytes.Equal(out1, out3) {
+		t.Fatal("events of different sources must give different outputs")
+	}
+	f1.Read(out2)
+	if bytes.Equal(out1, out2) {
+		t.Fatal("consecutive outputs must differ")
+	}
+
+	// Requests larger than the generator limit are split.
+	big := make([]byte, 2*maxGenerate+5)
+	if n, err := f1.Read(big); err != nil || n != len(big) {
+		t.Fatalf("Read returned %d, %v", n, err)
+	}
+}
+
+// TestFortunaKnownAnswer pins the output after the first reseed from pool 0
+// and the rekeying between two reads. The expected outputs were computed
+// with an independent implementation over hashlib's SM3 and OpenSSL's SM4.
+func TestFortunaKnownAnswer(t *testing.T) {
+	f := NewFortuna()
+	feed(t, f, 0, 2*NumPools)
+
+	for i, want := range []string{
+		"89564a1f0438d3358f346ef1f4375fc5709118e6c1ea9b3d2d7f53dc48b9f6489c7081986984645ba0530865f391a0fa733cab447ad1ac63fcbdf58c54e0c0a6f5d0f6466a7af5e6653027ee7176e7e9abc514261332fa7ceb36d35ee438ac133f1641f0",
+		"552015777fe65ace3399d3770143dbfe7278fb3d758987a72c834f827d70b4e282a137e6977b12f06eb2538d859a3f3cc205147936b04fa6ec459689d5194ecead164961c77dafe23ca1d072a8b136b9292117fa76587d506ef002050fb95b7008e56339",
+	} {
+		out := make([]byte, 100)
+		if _, err := f.Read(out); err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(out); got != want {
+			t.Fatalf("read %d:\ngot  %s\nwant %s", i, got, want)
+		}
+	}
+}
+
+func TestFortunaReseedSchedule(t *testing.T) {
+	f := NewFortuna()
+	out := make([]byte, 16)
+
+	// Without the minimum interval passing, pool 0 keeps filling up.
+	feed(t, f, 0, 2*NumPools)
+	f.Read(out)
+	feed(t, f, 0, 2*NumPools)
+	f.Read(out)
+	if f.reseedCount != 1 {
+		t.Fatalf("reseeded %d times within the minimum interval", f.reseedCount)
+	}
+
+	f.minInterval = 0
+	for i := 2; i <= 8; i++ {
+		feed(t, f, 0, 2*NumPools)
+		f.Read(out)
+	}
+	if f.reseedCount != 8 {
+		t.Fatalf("reseed count %d", f.reseedCount)
+	}
+	// The eighth reseed drained pools 0 to 3, leaving pool 4 with 16
+	// rounds of two events.
+	empty := NewFortuna().pools[0].Sum(nil)
+	for i := 0; i < 4; i++ {
+		if !bytes.Equal(f.pools[i].Sum(nil), empty) {
+			t.Fatalf("pool %d not drained", i)
+		}
+	}
+	if bytes.Equal(f.pools[4].Sum(nil), empty) {
+		t.Fatal("pool 4 drained too early")
+	}
+}
+
+type brokenReader struct{}
+
+func (brokenReader) Read(p []byte) (int, error) { return 0, errors.New("source failed") }
+
+func TestFortunaPoll(t *testing.T) {
+	f := NewFortuna()
+	if err := f.Poll(0, brokenReader{}, 64); err == nil {
+		t.Fatal("expected the error of the failing source")
+	}
+	if err := f.Poll(1, io.LimitReader(rand.Reader, 40), 64); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	// The 40 bytes read were still added, to pools 0 and 1.
+	if f.pool0Size != 2+MaxEventSize {
+		t.Fatalf("pool 0 holds %d bytes", f.pool0Size)
+	}
+
+	// The remaining source keeps the generator going.
+	if err := f.Poll(2, rand.Reader, 2*NumPools*MaxEventSize); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Read(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkFortuna(b *testing.B) {
+	f := NewFortuna()
+	if err := f.Poll(0, rand.Reader, 2*NumPools*MaxEventSize); err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		f.Read(buf)
+	}
+}
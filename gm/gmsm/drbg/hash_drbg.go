@@ -0,0 +1,228 @@
+// Package drbg implements deterministic random bit generators built on the
+// GM algorithms, following GM/T 0105-2021 (which profiles NIST SP 800-90A):
+// Hash_DRBG with SM3 and CTR_DRBG with SM4. It also implements the Fortuna
+// pooled generator for appliances that collect entropy from several
+// sources. All of them implement io.Reader, so they can be passed wherever
+// the randomized APIs take a random source.
+package drbg
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	// hashSeedLen is the seed length of Hash_DRBG with SM3, 440 bits.
+	hashSeedLen = 55
+
+	// DefaultReseedInterval is the maximum number of generate requests, of
+	// at most MaxBytesPerRequest bytes each, between two reseeds, as required
+	// by GM/T 0105 for the first security level. Read makes one request per
+	// MaxBytesPerRequest bytes of output.
+	DefaultReseedInterval = 1 << 20
+	// DefaultReseedTime is the maximum time between two reseeds.
+	DefaultReseedTime = 10 * time.Minute
+	// MaxBytesPerRequest is the largest output produced by a single generate call.
+	MaxBytesPerRequest = 1 << 16
+
+	entropyLen = 32
+	nonceLen   = 16
+)
+
+var (
+	ErrEntropy = errors.New("drbg: failed to read from the entropy source")
+	ErrTooLong = errors.New("drbg: personalization or additional input too long")
+)
+
+// HashDRBG is an SM3 based Hash_DRBG. It is safe for concurrent use.
+type HashDRBG struct {
+	mu sync.Mutex
+
+	entropy              io.Reader
+	v, c                 [hashSeedLen]byte
+	reseedCounter        uint64
+	lastReseed           time.Time
+	reseedInterval       uint64
+	reseedTime           time.Duration
+	predictionResistance bool
+}
+
+// NewHashDRBG instantiates a Hash_DRBG from the entropy source, which is
+// crypto/rand.Reader if nil. personalization is an optional string that
+// separates this instance from others seeded by the same source.
+// With predictionResistance set, every Read reseeds from the entropy source.
+func NewHashDRBG(entropy io.Reader, personalization []byte, predictionResistance bool) (*HashDRBG, error) {
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+	if len(personalization) > MaxBytesPerRequest {
+		return nil, ErrTooLong
+	}
+
+	d := &HashDRBG{
+		entropy:              entropy,
+		reseedInterval:       DefaultReseedInterval,
+		reseedTime:           DefaultReseedTime,
+		predictionResistance: predictionResistance,
+	}
+
+	seed := make([]byte, entropyLen+nonceLen)
+	if _, err := io.ReadFull(entropy, seed); err != nil {
+		return nil, ErrEntropy
+	}
+	seedMaterial := append(seed, personalization...)
+	hashDF(d.v[:], seedMaterial)
+	hashDF(d.c[:], append([]byte{0x00}, d.v[:]...))
+	d.reseedCounter = 1
+	d.lastReseed = time.Now()
+
+	return d, nil
+}
+
+// SetReseedInterval changes how many generate requests and how much time may
+// pass between two reseeds. Values that are not positive keep the current setting.
+func (d *HashDRBG) SetReseedInterval(requests uint64, period time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if requests > 0 {
+		d.reseedInterval = requests
+	}
+	if period > 0 {
+		d.reseedTime = period
+	}
+}
+
+// Reseed mixes fresh entropy and the optional additional input into the state.
+func (d *HashDRBG) Reseed(additional []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.reseed(additional)
+}
+
+func (d *HashDRBG) reseed(additional []byte) error {
+	if len(additional) > MaxBytesPerRequest {
+		return ErrTooLong
+	}
+
+	entropy := make([]byte, entropyLen)
+	if _, err := io.ReadFull(d.entropy, entropy); err != nil {
+		return ErrEntropy
+	}
+
+	seedMaterial := make([]byte, 0, 1+hashSeedLen+entropyLen+len(additional))
+	seedMaterial = append(seedMaterial, 0x01)
+	seedMaterial = append(seedMaterial, d.v[:]...)
+	seedMaterial = append(seedMaterial, entropy...)
+	seedMaterial = append(seedMaterial, additional...)
+	hashDF(d.v[:], seedMaterial)
+	hashDF(d.c[:], append([]byte{0x00}, d.v[:]...))
+	d.reseedCounter = 1
+	d.lastReseed = time.Now()
+
+	return nil
+}
+
+// Read fills p with pseudorandom bytes, splitting large requests into several
+// generate calls. It implements io.Reader.
+func (d *HashDRBG) Read(p []byte) (int, error) {
+	return d.ReadWithAdditionalInput(p, nil)
+}
+
+// ReadWithAdditionalInput is Read with additional input mixed into each
+// generate call.
+func (d *HashDRBG) ReadWithAdditionalInput(p, additional []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		end := n + MaxBytesPerRequest
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := d.generate(p[n:end], additional); err != nil {
+			return n, err
+		}
+		n = end
+	}
+	return n, nil
+}
+
+func (d *HashDRBG) generate(out, additional []byte) error {
+	if len(additional) > MaxBytesPerRequest {
+		return ErrTooLong
+	}
+
+	if d.predictionResistance || d.reseedCounter > d.reseedInterval || time.Since(d.lastReseed) > d.reseedTime {
+		if err := d.reseed(additional); err != nil {
+			return err
+		}
+		additional = nil
+	}
+
+	if len(additional) > 0 {
+		h := sm3.New()
+		h.Write([]byte{0x02})
+		h.Write(d.v[:])
+		h.Write(additional)
+		addMod(d.v[:], h.Sum(nil))
+	}
+
+	// Hashgen
+	var data [hashSeedLen]byte
+	copy(data[:], d.v[:])
+	for len(out) > 0 {
+		block := sm3.Sm3Sum(data[:])
+		out = out[copy(out, block):]
+		addMod(data[:], []byte{1})
+	}
+
+	h := sm3.New()
+	h.Write([]byte{0x03})
+	h.Write(d.v[:])
+	addMod(d.v[:], h.Sum(nil))
+	addMod(d.v[:], d.c[:])
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], d.reseedCounter)
+	addMod(d.v[:], counter[:])
+	d.reseedCounter++
+
+	return nil
+}
+
+// hashDF is the hash derivation function, it fills out with len(out) bytes
+// derived from input.
+func hashDF(out, input []byte) {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(out)*8))
+	prefix[0] = 1
+
+	for len(out) > 0 {
+		h := sm3.New()
+		h.Write(prefix[:])
+		h.Write(input)
+		out = out[copy(out, h.Sum(nil)):]
+		prefix[0]++
+	}
+}
+
+// addMod sets v = (v + x) mod 2^(8*len(v)), both being big-endian.
+func addMod(v, x []byte) {
+	var carry uint16
+	for i, j := len(v)-1, len(x)-1; i >= 0; i, j = i-1, j-1 {
+		sum := uint16(v[i]) + carry
+		if j >= 0 {
+			sum += uint16(x[j])
+		}
+		v[i] = byte(sum)
+		carry = sum >> 8
+	}
+}
@@ -0,0 +1,80 @@
+package drbg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// TestHashDRBGKnownAnswer follows the SP 800-90A test flow (instantiate,
+// generate, reseed, generate) with seedlen 440. The expected outputs were
+// computed with an independent implementation of SP 800-90A section 10.1.1
+// over hashlib's SM3.
+func TestHashDRBGKnownAnswer(t *testing.T) {
+	entropy := make([]byte, 0, entropyLen+nonceLen+entropyLen)
+	for i := 0; i < entropyLen+nonceLen; i++ {
+		entropy = append(entropy, byte(i))
+	}
+	for i := 0; i < entropyLen; i++ {
+		entropy = append(entropy, byte(0x80+i))
+	}
+
+	d, err := NewHashDRBG(bytes.NewReader(entropy), []byte("personalization"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, 64)
+	if _, err := d.Read(out); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := hex.DecodeString("4ea563b95851e9340545b90202f857e476a33a64b56a775e3048bd6c139535a5ef09651533eb1a5569f7bffa32bf9566abc18e85e44ccbd65c2cbdd0ac5e2a03")
+	if !bytes.Equal(out, want) {
+		t.Fatalf("first generate:\ngot  %x\nwant %x", out, want)
+	}
+
+	if err := d.Reseed([]byte("reseed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadWithAdditionalInput(out, []byte("additional")); err != nil {
+		t.Fatal(err)
+	}
+	want, _ = hex.DecodeString("64181aeaea9395e9be19b01797abf6e572dee6e450d140f920e1039ca10b063d206b8f091ade42d40f486d00cb0e9ff60ede192c83057bf4eaa12201dddaf9d1")
+	if !bytes.Equal(out, want) {
+		t.Fatalf("generate after reseed:\ngot  %x\nwant %x", out, want)
+	}
+}
+
+func TestHashDRBGDeterministic(t *testing.T) {
+	seed := bytes.Repeat([]byte{0x5a}, 1024)
+
+	d1, err := NewHashDRBG(bytes.NewReader(seed), []byte("test"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := NewHashDRBG(bytes.NewReader(seed), []byte("test"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out1 := make([]byte, 100)
+	out2 := make([]byte, 100)
+	d1.Read(out1)
+	d2.Read(out2)
+	if !bytes.Equal(out1, out2) {
+		t.Fatal("same seed must give the same output")
+	}
+	d1.Read(out2)
+	if bytes.Equal(out1, out2) {
+		t.Fatal("consecutive outputs must differ")
+	}
+}
+
+func TestHashDRBGEntropyExhausted(t *testing.T) {
+	d, err := NewHashDRBG(bytes.NewReader(make([]byte, entropyLen+nonceLen)), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Read(make([]byte, 16)); err != ErrEntropy {
+		t.Fatalf("expected ErrEntropy with prediction resistance, got %v", err)
+	}
+}
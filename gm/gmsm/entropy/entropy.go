@@ -0,0 +1,239 @@
+// Package entropy wraps a raw entropy source, such as a hardware noise
+// source, with the continuous health tests of NIST SP 800-90B and SM3
+// conditioning.
+//
+// A stuck or degraded hardware RNG does not fail loudly; it keeps returning
+// bytes, and an SM2 signer fed by it eventually reuses a nonce, which
+// reveals the private key. Source tests every raw byte it reads:
+//
+//   - The repetition count test fails when one value repeats more often in
+//     a row than the claimed min-entropy makes plausible.
+//   - The adaptive proportion test fails when the first value of a window
+//     of 512 samples occurs too often within it.
+//
+// Both cutoffs are set for a false positive probability of 2^-20 per
+// sample, as SP 800-90B recommends. A Source also tests 1024 samples when
+// it is created. After a failure it returns the failure from every Read
+// until Reset, and calls the OnFailure callback so the deployment can raise
+// an alarm.
+//
+// Read outputs full-entropy bytes: each 32-byte block is the SM3 digest of
+// enough raw bytes to hold 64 bits more min-entropy than the block, the
+// margin SP 800-90B requires of a vetted conditioning function. Use the
+// output to seed a DRBG, e.g. drbg.NewCTRDRBG, rather than directly.
+package entropy
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	// windowSize is the window of the adaptive proportion test for
+	// non-binary samples.
+	windowSize = 512
+	// startupSamples is the number of samples tested when a Source is
+	// created or reset.
+	startupSamples = 1024
+	// alphaLog2 is -log2 of the false positive probability of the tests.
+	alphaLog2 = 20
+	// conditioningMargin is the min-entropy in bits beyond the output size
+	// the conditioning input must hold for full-entropy output.
+	conditioningMargin = 64
+)
+
+var (
+	// ErrRepetitionCount is the failure of the repetition count test.
+	ErrRepetitionCount = errors.New("entropy: repetition count test failed")
+	// ErrAdaptiveProportion is the failure of the adaptive proportion test.
+	ErrAdaptiveProportion = errors.New("entropy: adaptive proportion test failed")
+	// ErrMinEntropy is returned by New for a claimed min-entropy outside
+	// (0, 8] bits per byte.
+	ErrMinEntropy = errors.New("entropy: min-entropy must be in (0, 8] bits per byte")
+)
+
+// Config configures a Source.
+type Config struct {
+	// MinEntropy is the min-entropy of one raw byte in bits, as assessed
+	// for the noise source; it sets both the test cutoffs and how many raw
+	// bytes are conditioned into each output block. Overestimating it
+	// weakens both.
+	MinEntropy float64
+	// OnFailure, if set, is called with the error of a failed health test.
+	// It is called once per failure, without locks held.
+	OnFailure func(err error)
+}
+
+// Source is a health-tested, conditioned entropy source. It is safe for
+// concurrent use.
+type Source struct {
+	mu sync.Mutex
+
+	raw       io.Reader
+	onFailure func(error)
+	rctCutoff int
+	aptCutoff int
+	// rawPerBlock is the number of raw bytes conditioned into each output
+	// block.
+	rawPerBlock int
+
+	// repetition count test state
+	last   byte
+	repeat int
+	// adaptive proportion test state
+	aptFirst byte
+	aptCount int
+	aptIndex int
+
+	err error
+}
+
+// New returns a Source reading raw samples, one per byte, from raw. It runs
+// the startup tests and returns their failure, if any.
+func New(raw io.Reader, cfg Config) (*Source, error) {
+	h := cfg.MinEntropy
+	if !(h > 0 && h <= 8) {
+		return nil, ErrMinEntropy
+	}
+	s := &Source{
+		raw:         raw,
+		onFailure:   cfg.OnFailure,
+		rctCutoff:   1 + int(math.Ceil(alphaLog2/h)),
+		aptCutoff:   1 + critBinom(windowSize, math.Exp2(-h), alphaLog2),
+		rawPerBlock: int(math.Ceil((8*sm3.Size + conditioningMargin) / h)),
+	}
+	if err := s.Reset(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// critBinom returns the smallest k such that P(X <= k) >= 1 - 2^-alphaLog2
+// for X binomially distributed with n trials of probability p.
+func critBinom(n int, p float64, alphaLog2 float64) int {
+	target := 1 - math.Exp2(-alphaLog2)
+	lgN, _ := math.Lgamma(float64(n + 1))
+	cdf := 0.0
+	for k := 0; k < n; k++ {
+		lgK, _ := math.Lgamma(float64(k + 1))
+		lgNK, _ := math.Lgamma(float64(n - k + 1))
+		cdf += math.Exp(lgN - lgK - lgNK + float64(k)*math.Log(p) + float64(n-k)*math.Log1p(-p))
+		if cdf >= target {
+			return k
+		}
+	}
+	return n
+}
+
+// Reset clears a failure and restarts the health tests, running the
+// startup tests again.
+func (s *Source) Reset() error {
+	s.mu.Lock()
+	s.err = nil
+	s.repeat, s.aptIndex = 0, 0
+	buf := make([]byte, startupSamples)
+	err := s.readRaw(buf)
+	s.mu.Unlock()
+
+	return s.report(err)
+}
+
+// report calls the failure callback for health test failures; it is
+// called without s.mu held.
+func (s *Source) report(err error) error {
+	if (err == ErrRepetitionCount || err == ErrAdaptiveProportion) && s.onFailure != nil {
+		s.onFailure(err)
+	}
+	return err
+}
+
+// Err returns the health test failure of the source, or nil if it is
+// healthy.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// ReadRaw fills p with health-tested but unconditioned samples.
+func (s *Source) ReadRaw(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		defer s.mu.Unlock()
+		return 0, s.err
+	}
+	err := s.readRaw(p)
+	s.mu.Unlock()
+
+	if err != nil {
+		return 0, s.report(err)
+	}
+	return len(p), nil
+}
+
+// Read fills p with conditioned, full-entropy bytes. It implements
+// io.Reader; on error, it returns no output.
+func (s *Source) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.err != nil {
+		defer s.mu.Unlock()
+		return 0, s.err
+	}
+	raw := make([]byte, s.rawPerBlock)
+	n := 0
+	var err error
+	for n < len(p) {
+		if err = s.readRaw(raw); err != nil {
+			break
+		}
+		n += copy(p[n:], sm3.Sm3Sum(raw))
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return 0, s.report(err)
+	}
+	return n, nil
+}
+
+// readRaw reads and tests len(p) samples.
+func (s *Source) readRaw(p []byte) error {
+	if _, err := io.ReadFull(s.raw, p); err != nil {
+		return err
+	}
+	for _, b := range p {
+		if err := s.test(b); err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// test runs both health tests on the next sample.
+func (s *Source) test(b byte) error {
+	if s.repeat > 0 && b == s.last {
+		s.repeat++
+		if s.repeat >= s.rctCutoff {
+			return ErrRepetitionCount
+		}
+	} else {
+		s.last, s.repeat = b, 1
+	}
+
+	if s.aptIndex == 0 {
+		s.aptFirst, s.aptCount = b, 1
+	} else if b == s.aptFirst {
+		s.aptCount++
+		if s.aptCount >= s.aptCutoff {
+			return ErrAdaptiveProportion
+		}
+	}
+	s.aptIndex = (s.aptIndex + 1) % windowSize
+	return nil
+}
@@ -0,0 +1,144 @@
+package entropy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// The cutoffs of SP 800-90B section 4.4 for a false positive probability of
+// 2^-20 and a window of 512 samples.
+func TestCutoffs(t *testing.T) {
+	for _, c := range []struct {
+		h        float64
+		rct, apt int
+	}{
+		{0.5, 41, 410},
+		{1, 21, 311},
+		{2, 11, 177},
+		{4, 6, 62},
+		{8, 4, 13},
+	} {
+		s, err := New(rand.Reader, Config{MinEntropy: c.h})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.rctCutoff != c.rct || s.aptCutoff != c.apt {
+			t.Errorf("H = %v: cutoffs %d, %d; want %d, %d", c.h, s.rctCutoff, s.aptCutoff, c.rct, c.apt)
+		}
+	}
+	for _, h := range []float64{0, -1, 8.5} {
+		if _, err := New(rand.Reader, Config{MinEntropy: h}); err != ErrMinEntropy {
+			t.Errorf("H = %v: expected ErrMinEntropy, got %v", h, err)
+		}
+	}
+}
+
+func TestHealthySource(t *testing.T) {
+	s, err := New(rand.Reader, Config{MinEntropy: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, 1<<16)
+	if n, err := s.Read(out); err != nil || n != len(out) {
+		t.Fatalf("Read returned %d, %v", n, err)
+	}
+	if _, err := s.ReadRaw(out); err != nil {
+		t.Fatal(err)
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+}
+
+// stuckReader returns random bytes until it gets stuck on one value.
+type stuckReader struct {
+	good int
+}
+
+func (r *stuckReader) Read(p []byte) (int, error) {
+	for i := range p {
+		if r.good > 0 {
+			var b [1]byte
+			rand.Read(b[:])
+			p[i] = b[0]
+			r.good--
+		} else {
+			p[i] = 0x42
+		}
+	}
+	return len(p), nil
+}
+
+func TestRepetitionCount(t *testing.T) {
+	if _, err := New(bytes.NewReader(make([]byte, startupSamples)), Config{MinEntropy: 8}); err != ErrRepetitionCount {
+		t.Fatalf("expected the startup tests to fail, got %v", err)
+	}
+
+	var failures []error
+	s, err := New(&stuckReader{good: startupSamples + 10}, Config{
+		MinEntropy: 8,
+		OnFailure:  func(err error) { failures = append(failures, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Read(make([]byte, 64)); err != ErrRepetitionCount || n != 0 {
+		t.Fatalf("Read of a stuck source returned %d, %v", n, err)
+	}
+	if _, err := s.Read(make([]byte, 64)); err != ErrRepetitionCount {
+		t.Fatalf("failure not latched: %v", err)
+	}
+	if _, err := s.ReadRaw(make([]byte, 1)); err != ErrRepetitionCount {
+		t.Fatalf("failure not latched for raw reads: %v", err)
+	}
+	if len(failures) != 1 || failures[0] != ErrRepetitionCount {
+		t.Fatalf("OnFailure called with %v", failures)
+	}
+	if err := s.Reset(); err != ErrRepetitionCount {
+		t.Fatalf("Reset of a stuck source returned %v", err)
+	}
+}
+
+// biasedReader returns 0 for every fourth sample and otherwise distinct
+// values, so that no value repeats in a row.
+type biasedReader struct {
+	i int
+}
+
+func (r *biasedReader) Read(p []byte) (int, error) {
+	for j := range p {
+		if r.i%4 == 0 {
+			p[j] = 0
+		} else {
+			p[j] = byte(1 + r.i%255)
+		}
+		r.i++
+	}
+	return len(p), nil
+}
+
+func TestAdaptiveProportion(t *testing.T) {
+	_, err := New(&biasedReader{}, Config{MinEntropy: 4})
+	if err != ErrAdaptiveProportion {
+		t.Fatalf("expected ErrAdaptiveProportion, got %v", err)
+	}
+	// A source with the claimed min-entropy of 1 bit tolerates the bias.
+	if _, err := New(&biasedReader{}, Config{MinEntropy: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadError(t *testing.T) {
+	s, err := New(io.LimitReader(rand.Reader, startupSamples+10), Config{MinEntropy: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(make([]byte, 32)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if s.Err() != nil {
+		t.Fatal("a read error must not latch a health test failure")
+	}
+}
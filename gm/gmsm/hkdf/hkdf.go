@@ -0,0 +1,62 @@
+// Package hkdf implements the HMAC-based Extract-and-Expand Key Derivation
+// Function (HKDF) as defined in RFC 5869, for use with the SM3 hash.
+//
+// HKDF is a cryptographic key derivation function (KDF) with the goal of
+// expanding limited input keying material into one or more cryptographically
+// strong secret keys.
+package hkdf
+
+import (
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+var ErrKeyLength = errors.New("hkdf: requested key length out of range")
+
+// Extract generates a pseudorandom key for use with Expand from an input secret
+// and an optional independent salt.
+//
+// Only use this function if you need to reuse the extracted key with multiple
+// Expand invocations and different context values. Most common scenarios,
+// including the generation of multiple keys, should use New instead.
+func Extract(hash func() hash.Hash, secret, salt []byte) []byte {
+	return hkdf.Extract(hash, secret, salt)
+}
+
+// Expand returns a Reader, from which keys can be read, using the given
+// pseudorandom key and optional context info, skipping the extraction step.
+//
+// The pseudorandomKey should have been generated by Extract, or be a uniformly
+// random or pseudorandom cryptographically strong key. See RFC 5869, Section
+// 3.3. Most common scenarios will want to use New instead.
+func Expand(hash func() hash.Hash, pseudorandomKey, info []byte) io.Reader {
+	return hkdf.Expand(hash, pseudorandomKey, info)
+}
+
+// New returns a Reader, from which keys can be read, using the given hash,
+// secret, salt and context info. Salt and info can be nil.
+//
+// e.g. hkdf.New(sm3.New, secret, salt, info)
+func New(hash func() hash.Hash, secret, salt, info []byte) io.Reader {
+	return hkdf.New(hash, secret, salt, info)
+}
+
+// Key derives a key of the given length from secret using HKDF-SM3.
+// The output is limited to 255 SM3 blocks, i.e. 8160 bytes.
+func Key(secret, salt, info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > 255*sm3.New().Size() {
+		return nil, ErrKeyLength
+	}
+
+	key := make([]byte, length)
+	if _, err := io.ReadFull(New(sm3.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
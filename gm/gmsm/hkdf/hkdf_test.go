@@ -0,0 +1,42 @@
+package hkdf
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func TestKeyMatchesNew(t *testing.T) {
+	secret := []byte("input keying material")
+	salt := []byte("salt")
+	info := []byte("context")
+
+	key, err := Key(secret, salt, info, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]byte, 100)
+	if _, err := io.ReadFull(New(sm3.New, secret, salt, info), out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, out) {
+		t.Fatalf("Key and New disagree:\n%x\n%x", key, out)
+	}
+
+	prk := Extract(sm3.New, secret, salt)
+	if _, err := io.ReadFull(Expand(sm3.New, prk, info), out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, out) {
+		t.Fatalf("Extract+Expand and Key disagree:\n%x\n%x", key, out)
+	}
+}
+
+func TestKeyLength(t *testing.T) {
+	if _, err := Key([]byte("secret"), nil, nil, 255*32+1); err != ErrKeyLength {
+		t.Fatalf("expected ErrKeyLength, got %v", err)
+	}
+}
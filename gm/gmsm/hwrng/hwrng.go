@@ -0,0 +1,233 @@
+// Package hwrng reads the hardware random number generators of the host,
+// the RDRAND and RDSEED instructions of x86 CPUs and the /dev/hwrng device
+// of Linux, for signers that do not trust the entropy pool of the OS.
+//
+// Hardware output is never used raw. Mixer health-tests every source with
+// the entropy package, XORs their conditioned outputs, and drops a source
+// that keeps failing; NewDRBG then uses the Mixer as the entropy input of
+// an SM4 CTR_DRBG. Which sources are available is detected at run time,
+// and the OS source is only used as a fallback when none is:
+//
+//	d, err := hwrng.NewDRBG(rand.Reader, []byte("signer-01"))
+//	...
+//	sm2.SetRandSource(d)
+package hwrng
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/drbg"
+	"github.com/xuperchain/crypto/gm/gmsm/entropy"
+)
+
+const (
+	// DevicePath is the hardware RNG device of Linux.
+	DevicePath = "/dev/hwrng"
+
+	// minEntropy is the min-entropy per byte assumed for the hardware
+	// sources. They claim full entropy; half of it leaves a margin for a
+	// degraded source that the health tests do not catch.
+	minEntropy = 4
+	// rdrandRetries and rdseedRetries bound the retries of an instruction
+	// that returns no value. RDRAND only fails if the CPU is broken; RDSEED
+	// fails when its entropy is drained faster than it is produced.
+	rdrandRetries = 10
+	rdseedRetries = 1000
+)
+
+var (
+	ErrUnsupported = errors.New("hwrng: instruction not supported by the CPU")
+	ErrHardware    = errors.New("hwrng: hardware RNG returned no value")
+	ErrNoSource    = errors.New("hwrng: no healthy entropy source")
+)
+
+// instReader reads from RDRAND or RDSEED.
+type instReader struct {
+	next    func() (uint64, bool)
+	retries int
+}
+
+// RDRAND returns a reader of the RDRAND instruction.
+func RDRAND() (io.Reader, error) {
+	if !hasRDRAND {
+		return nil, ErrUnsupported
+	}
+	return &instReader{next: rdrand64, retries: rdrandRetries}, nil
+}
+
+// RDSEED returns a reader of the RDSEED instruction.
+func RDSEED() (io.Reader, error) {
+	if !hasRDSEED {
+		return nil, ErrUnsupported
+	}
+	return &instReader{next: rdseed64, retries: rdseedRetries}, nil
+}
+
+func (r *instReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		v, err := r.word()
+		if err != nil {
+			return n, err
+		}
+		for i := 0; i < 8 && n < len(p); i++ {
+			p[n] = byte(v >> (8 * uint(i)))
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *instReader) word() (uint64, error) {
+	for i := 0; i < r.retries; i++ {
+		// Some AMD CPUs return all ones, with success, after a resume
+		// from suspend.
+		if v, ok := r.next(); ok && v != ^uint64(0) {
+			return v, nil
+		}
+	}
+	return 0, ErrHardware
+}
+
+// OpenDevice opens a hardware RNG device, usually DevicePath.
+func OpenDevice(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// source is a health-tested input of a Mixer.
+type source struct {
+	name string
+	s    *entropy.Source
+}
+
+// Mixer combines the available hardware sources. It implements io.Reader
+// and is safe for concurrent use.
+type Mixer struct {
+	mu       sync.Mutex
+	sources  []source
+	fallback io.Reader
+	closers  []io.Closer
+}
+
+// NewMixer detects the hardware sources: RDSEED, or RDRAND if the CPU lacks
+// it, and DevicePath if it can be opened. fallback, e.g. crypto/rand.Reader,
+// is used only if no hardware source is available or healthy; with a nil
+// fallback, NewMixer fails with ErrNoSource in that case.
+func NewMixer(fallback io.Reader) (*Mixer, error) {
+	m := &Mixer{fallback: fallback}
+	if r, err := RDSEED(); err == nil {
+		m.add("rdseed", r)
+	} else if r, err := RDRAND(); err == nil {
+		m.add("rdrand", r)
+	}
+	if dev, err := OpenDevice(DevicePath); err == nil {
+		if m.add("hwrng", dev) {
+			m.closers = append(m.closers, dev)
+		} else {
+			dev.Close()
+		}
+	}
+	if len(m.sources) == 0 && fallback == nil {
+		return nil, ErrNoSource
+	}
+	return m, nil
+}
+
+// add adds r if it passes the startup health tests.
+func (m *Mixer) add(name string, r io.Reader) bool {
+	s, err := entropy.New(r, entropy.Config{MinEntropy: minEntropy})
+	if err != nil {
+		return false
+	}
+	m.sources = append(m.sources, source{name, s})
+	return true
+}
+
+// Sources returns the names of the hardware sources in use.
+func (m *Mixer) Sources() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, len(m.sources))
+	for i, s := range m.sources {
+		names[i] = s.name
+	}
+	return names
+}
+
+// Read fills p with the XOR of the outputs of all healthy sources. A source
+// that fails a health test is reset once, which reruns the startup tests,
+// and dropped if it fails again.
+func (m *Mixer) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range p {
+		p[i] = 0
+	}
+	buf := make([]byte, len(p))
+	healthy := m.sources[:0]
+	for _, s := range m.sources {
+		if !readSource(s.s, buf) {
+			continue
+		}
+		for i := range p {
+			p[i] ^= buf[i]
+		}
+		healthy = append(healthy, s)
+	}
+	m.sources = healthy
+
+	if len(m.sources) > 0 {
+		return len(p), nil
+	}
+	if m.fallback == nil {
+		return 0, ErrNoSource
+	}
+	return io.ReadFull(m.fallback, p)
+}
+
+func readSource(s *entropy.Source, buf []byte) bool {
+	if _, err := s.Read(buf); err == nil {
+		return true
+	}
+	if s.Reset() != nil {
+		return false
+	}
+	_, err := s.Read(buf)
+	return err == nil
+}
+
+// Close closes the hardware RNG device, if open.
+func (m *Mixer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	for _, c := range m.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	m.closers = nil
+	return err
+}
+
+// NewDRBG returns an SM4 CTR_DRBG seeded and reseeded from a Mixer of the
+// hardware sources, see NewMixer. The Mixer stays open for the lifetime of
+// the DRBG.
+func NewDRBG(fallback io.Reader, personalization []byte) (*drbg.CTRDRBG, error) {
+	m, err := NewMixer(fallback)
+	if err != nil {
+		return nil, err
+	}
+	d, err := drbg.NewCTRDRBG(m, personalization, false)
+	if err != nil {
+		m.Close()
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,28 @@
+//go:build amd64
+// +build amd64
+
+package hwrng
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// rdrand64 and rdseed64 execute RDRAND and RDSEED once; ok reports
+// whether the instruction returned a value.
+func rdrand64() (v uint64, ok bool)
+
+func rdseed64() (v uint64, ok bool)
+
+var hasRDRAND, hasRDSEED = detect()
+
+func detect() (rdrand, rdseed bool) {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return false, false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	rdrand = ecx1&(1<<30) != 0
+	if maxID >= 7 {
+		_, ebx7, _, _ := cpuid(7, 0)
+		rdseed = ebx7&(1<<18) != 0
+	}
+	return rdrand, rdseed
+}
@@ -0,0 +1,26 @@
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func rdrand64() (v uint64, ok bool)
+TEXT ·rdrand64(SB), NOSPLIT, $0-9
+	BYTE $0x48; BYTE $0x0f; BYTE $0xc7; BYTE $0xf0 // rdrand rax
+	SETCS ok+8(FP)
+	MOVQ AX, v+0(FP)
+	RET
+
+// func rdseed64() (v uint64, ok bool)
+TEXT ·rdseed64(SB), NOSPLIT, $0-9
+	BYTE $0x48; BYTE $0x0f; BYTE $0xc7; BYTE $0xf8 // rdseed rax
+	SETCS ok+8(FP)
+	MOVQ AX, v+0(FP)
+	RET
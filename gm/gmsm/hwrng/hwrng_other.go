@@ -0,0 +1,10 @@
+//go:build !amd64
+// +build !amd64
+
+package hwrng
+
+const hasRDRAND, hasRDSEED = false, false
+
+func rdrand64() (uint64, bool) { return 0, false }
+
+func rdseed64() (uint64, bool) { return 0, false }
@@ -0,0 +1,120 @@
+package hwrng
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func testInstruction(t *testing.T, open func() (io.Reader, error)) {
+	r, err := open()
+	if err == ErrUnsupported {
+		t.Skip("not supported by the CPU")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := make([]byte, 61)
+	b := make([]byte, 61)
+	if _, err := r.Read(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) || bytes.Equal(a, make([]byte, len(a))) {
+		t.Fatal("the instruction returns constant output")
+	}
+}
+
+func TestRDRAND(t *testing.T) {
+	testInstruction(t, func() (io.Reader, error) { return RDRAND() })
+}
+
+func TestRDSEED(t *testing.T) {
+	testInstruction(t, func() (io.Reader, error) { return RDSEED() })
+}
+
+func TestInstructionFailure(t *testing.T) {
+	calls := 0
+	r := &instReader{next: func() (uint64, bool) { calls++; return ^uint64(0), true }, retries: 3}
+	if _, err := r.Read(make([]byte, 8)); err != ErrHardware {
+		t.Fatalf("expected ErrHardware, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("%d tries, want 3", calls)
+	}
+}
+
+// stuckReader returns random bytes until it gets stuck on one value.
+type stuckReader struct {
+	good int
+}
+
+func (r *stuckReader) Read(p []byte) (int, error) {
+	for i := range p {
+		if r.good > 0 {
+			var b [1]byte
+			rand.Read(b[:])
+			p[i] = b[0]
+			r.good--
+		} else {
+			p[i] = 0x42
+		}
+	}
+	return len(p), nil
+}
+
+func TestMixerDropsFailedSource(t *testing.T) {
+	m := &Mixer{}
+	if !m.add("stuck", &stuckReader{good: 2000}) || !m.add("good", rand.Reader) {
+		t.Fatal("startup tests failed")
+	}
+	if m.add("dead", &stuckReader{}) {
+		t.Fatal("a stuck source passed the startup tests")
+	}
+
+	out := make([]byte, 64)
+	for i := 0; i < 20; i++ {
+		if _, err := m.Read(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := m.Sources(); len(names) != 1 || names[0] != "good" {
+		t.Fatalf("sources %v after the stuck one failed", names)
+	}
+
+	m.sources = nil
+	if _, err := m.Read(out); err != ErrNoSource {
+		t.Fatalf("expected ErrNoSource, got %v", err)
+	}
+	m.fallback = bytes.NewReader(bytes.Repeat([]byte{7}, 64))
+	if _, err := m.Read(out); err != nil || !bytes.Equal(out, bytes.Repeat([]byte{7}, 64)) {
+		t.Fatalf("fallback not used: %v", err)
+	}
+}
+
+func TestNewDRBG(t *testing.T) {
+	d, err := NewDRBG(rand.Reader, []byte("hwrng test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, 64)
+	if _, err := d.Read(out); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Reseed(nil); err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewMixer(nil)
+	if err == ErrNoSource {
+		t.Log("no hardware RNG")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	t.Logf("hardware sources: %v", m.Sources())
+}
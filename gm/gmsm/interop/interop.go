@@ -0,0 +1,220 @@
+// Package interop decodes SM2 ciphertexts and signatures as produced by
+// other GM libraries, some Java and C ones in particular, which the strict
+// parsers of the sm2 package reject:
+//
+//   - ciphertexts without the 0x04 prefix of C1;
+//   - ciphertexts in the ASN.1 form of GM/T 0009, whose x and y INTEGERs
+//     drop leading zeros or lack the 0x00 that keeps them positive;
+//   - signatures with non-minimal lengths, redundant leading zeros or
+//     missing sign bytes in r and s, or trailing data.
+//
+// The leniency only applies to encodings: points must lie on the curve and
+// r and s in [1, n-1]. Decoded values are returned in the canonical forms of
+// the sm2 package, so that they are stored and forwarded strictly. Use this
+// package at the boundary with such peers only; the sm2 parsers stay strict.
+package interop
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/ctutil"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+var errBER = errors.New("interop: malformed BER element")
+
+var (
+	// ErrCiphertext is returned for a ciphertext in none of the tolerated
+	// forms.
+	ErrCiphertext = errors.New("interop: unrecognized SM2 ciphertext")
+	// ErrSignature is returned for a signature in none of the tolerated
+	// forms.
+	ErrSignature = errors.New("interop: unrecognized SM2 signature")
+)
+
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagSequence    = 0x30
+)
+
+// Lenient is a decoder of foreign ciphertexts and signatures. Its zero value
+// reads raw ciphertexts in the C1C3C2 order.
+type Lenient struct {
+	// Mode is the order of the parts of raw ciphertexts. The ASN.1 form
+	// always holds C3 before C2.
+	Mode sm2.CiphertextMode
+}
+
+// Ciphertext returns data as the canonical C1C3C2 ciphertext of
+// sm2.Encrypt, 04 || x1 || y1 || C3 || C2. data may be raw, with or without
+// the 0x04 prefix, in the order of l.Mode, or in the ASN.1 form.
+func (l Lenient) Ciphertext(data []byte) ([]byte, error) {
+	if len(data) > 0 && data[0] == tagSequence {
+		if ct, err := parseCiphertextASN1(data); err == nil {
+			return ct, nil
+		}
+	}
+	var body []byte
+	switch {
+	case len(data) >= 97 && data[0] == 4 && onCurve(data[1:65]):
+		body = data[1:]
+	case len(data) >= 96 && onCurve(data[:64]):
+		body = data
+	default:
+		return nil, ErrCiphertext
+	}
+	out := make([]byte, 0, 1+len(body))
+	out = append(out, 4)
+	out = append(out, body[:64]...)
+	switch l.Mode {
+	case sm2.C1C3C2:
+		out = append(out, body[64:]...)
+	case sm2.C1C2C3:
+		out = append(out, body[len(body)-32:]...)
+		out = append(out, body[64:len(body)-32]...)
+	default:
+		return nil, ErrCiphertext
+	}
+	return out, nil
+}
+
+// Decrypt decrypts a foreign ciphertext with sm2.Decrypt.
+func (l Lenient) Decrypt(priv *sm2.PrivateKey, data []byte) ([]byte, error) {
+	ct, err := l.Ciphertext(data)
+	if err != nil {
+		return nil, err
+	}
+	return sm2.Decrypt(priv, ct)
+}
+
+// Signature decodes a foreign signature. data is BER-ish DER, as described
+// in the package comment, or r || s of 64 bytes.
+func (l Lenient) Signature(data []byte) (*sm2.Signature, error) {
+	if len(data) > 0 && data[0] == tagSequence {
+		if sig, err := parseSignatureBER(data); err == nil {
+			return sig, nil
+		}
+	}
+	sig := new(sm2.Signature)
+	if err := sig.DecodeFormat(sm2.FormatRaw, data); err != nil {
+		return nil, ErrSignature
+	}
+	return sig, nil
+}
+
+// Verify verifies a foreign signature of msg by pub under uid, DefaultUID
+// if nil.
+func (l Lenient) Verify(pub *sm2.PublicKey, msg, uid, data []byte) bool {
+	sig, err := l.Signature(data)
+	if err != nil {
+		return false
+	}
+	return sm2.Sm2Verify(pub, msg, uid, sig.R, sig.S)
+}
+
+func parseSignatureBER(data []byte) (*sm2.Signature, error) {
+	// Trailing data after the SEQUENCE is ignored.
+	seq, _, err := readTLV(data, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	r, rest, err := readTLV(seq, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	s, rest, err := readTLV(rest, tagInteger)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrSignature
+	}
+	sig := &sm2.Signature{R: new(big.Int).SetBytes(r), S: new(big.Int).SetBytes(s)}
+	if !sig.IsCanonical() {
+		return nil, ErrSignature
+	}
+	return sig, nil
+}
+
+// parseCiphertextASN1 parses
+//
+//	SM2Cipher ::= SEQUENCE {
+//		XCoordinate INTEGER,
+//		YCoordinate INTEGER,
+//		HASH        OCTET STRING SIZE(32),
+//		CipherText  OCTET STRING }
+func parseCiphertextASN1(data []byte) ([]byte, error) {
+	seq, rest, err := readTLV(data, tagSequence)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrCiphertext
+	}
+	x, seq, err := readTLV(seq, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	y, seq, err := readTLV(seq, tagInteger)
+	if err != nil {
+		return nil, err
+	}
+	hash, seq, err := readTLV(seq, tagOctetString)
+	if err != nil || len(hash) != 32 {
+		return nil, ErrCiphertext
+	}
+	c2, seq, err := readTLV(seq, tagOctetString)
+	if err != nil || len(seq) != 0 {
+		return nil, ErrCiphertext
+	}
+	xx, yy := new(big.Int).SetBytes(x), new(big.Int).SetBytes(y)
+	if xx.BitLen() > 256 || yy.BitLen() > 256 {
+		return nil, ErrCiphertext
+	}
+	out := make([]byte, 0, 97+len(c2))
+	out = append(out, 4)
+	out = append(out, ctutil.FixedBytes(xx, 32)...)
+	out = append(out, ctutil.FixedBytes(yy, 32)...)
+	if !onCurve(out[1:65]) {
+		return nil, ErrCiphertext
+	}
+	out = append(out, hash...)
+	return append(out, c2...), nil
+}
+
+// readTLV reads an element with the given tag off b, returning its contents
+// and what follows. Lengths may be in the long form where the short one
+// would do, and have leading zeros; indefinite lengths are rejected.
+func readTLV(b []byte, tag byte) (contents, rest []byte, err error) {
+	if len(b) < 2 || b[0] != tag {
+		return nil, nil, errBER
+	}
+	n, b := int(b[1]), b[2:]
+	if n == 0x80 {
+		return nil, nil, errBER
+	}
+	if n > 0x80 {
+		size := n & 0x7f
+		if size > len(b) {
+			return nil, nil, errBER
+		}
+		n = 0
+		for _, c := range b[:size] {
+			if n > 1<<23 {
+				return nil, nil, errBER
+			}
+			n = n<<8 | int(c)
+		}
+		b = b[size:]
+	}
+	if n > len(b) {
+		return nil, nil, errBER
+	}
+	return b[:n], b[n:], nil
+}
+
+// onCurve reports whether the 64 bytes x || y are a point of the curve.
+func onCurve(xy []byte) bool {
+	p := &sm2.PublicKey{
+		Curve: sm2.P256Sm2(),
+		X:     new(big.Int).SetBytes(xy[:32]),
+		Y:     new(big.Int).SetBytes(xy[32:64]),
+	}
+	return p.Validate() == nil
+}
@@ -0,0 +1,120 @@
+package interop
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// derInt encodes x as an INTEGER of its minimal unsigned bytes, without the
+// 0x00 that DER needs when the top bit is set, as some libraries do.
+func derInt(x *big.Int) []byte {
+	b := x.Bytes()
+	return append([]byte{tagInteger, byte(len(b))}, b...)
+}
+
+func TestCiphertext(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("interop message")
+	ct, err := sm2.Encrypt(&priv.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2c3, err := sm2.EncryptEx(&priv.PublicKey, msg, sm2.WithCiphertextMode(sm2.C1C2C3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, y := new(big.Int).SetBytes(ct[1:33]), new(big.Int).SetBytes(ct[33:65])
+	var body []byte
+	body = append(body, derInt(x)...)
+	body = append(body, derInt(y)...)
+	body = append(body, tagOctetString, 32)
+	body = append(body, ct[65:97]...)
+	body = append(body, tagOctetString, byte(len(ct)-97))
+	body = append(body, ct[97:]...)
+	seq := append([]byte{tagSequence, byte(len(body))}, body...)
+
+	for name, c := range map[string]struct {
+		l    Lenient
+		data []byte
+	}{
+		"canonical":          {Lenient{}, ct},
+		"no prefix":          {Lenient{}, ct[1:]},
+		"C1C2C3":             {Lenient{Mode: sm2.C1C2C3}, c2c3},
+		"C1C2C3 no prefix":   {Lenient{Mode: sm2.C1C2C3}, c2c3[1:]},
+		"ASN.1 unsigned int": {Lenient{}, seq},
+	} {
+		pt, err := c.l.Decrypt(priv, c.data)
+		if err != nil || !bytes.Equal(pt, msg) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	bad := append([]byte(nil), ct...)
+	bad[1] ^= 1
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"short":     ct[1:90],
+		"off curve": bad,
+	} {
+		if _, err := (Lenient{}).Ciphertext(data); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	msg := []byte("interop message")
+	sig, err := sm2.SignSignature(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der := sig.Bytes()
+	raw, _ := sig.Encode(sm2.FormatRaw)
+
+	// Redundant zeros in r, long-form lengths and trailing data.
+	r := append([]byte{tagInteger, 0x81, byte(len(sig.R.Bytes()) + 2), 0, 0}, sig.R.Bytes()...)
+	s := derInt(sig.S)
+	ber := append([]byte{tagSequence, 0x82, 0, byte(len(r) + len(s))}, r...)
+	ber = append(append(ber, s...), 0, 0)
+
+	for name, data := range map[string][]byte{
+		"DER":      der,
+		"raw":      raw,
+		"BER":      ber,
+		"unsigned": append(append([]byte{tagSequence, byte(len(derInt(sig.R)) + len(s))}, derInt(sig.R)...), s...),
+	} {
+		if !(Lenient{}).Verify(pub, msg, nil, data) {
+			t.Errorf("%s: does not verify", name)
+		}
+	}
+	if _, err := asn1.Unmarshal(ber, new(struct{ R, S *big.Int })); err == nil {
+		t.Error("encoding/asn1 accepts the BER test vector")
+	}
+
+	n := sm2.P256Sm2().Params().N
+	for name, data := range map[string][]byte{
+		"empty":      nil,
+		"truncated":  der[:len(der)-1],
+		"indefinite": append([]byte{tagSequence, 0x80}, der[2:]...),
+		"r = 0":      {tagSequence, 6, tagInteger, 1, 0, tagInteger, 1, 1},
+		"s = n":      append(append([]byte{tagSequence, byte(3 + 2 + len(n.Bytes()))}, tagInteger, 1, 1), derInt(n)...),
+		"three ints": append(append([]byte{tagSequence, byte(len(der) + 1)}, der[2:]...), tagInteger, 1, 1),
+	} {
+		if _, err := (Lenient{}).Signature(data); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
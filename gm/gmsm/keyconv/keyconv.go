@@ -0,0 +1,452 @@
+// Package keyconv converts SM2 private and public keys between the formats
+// they are found in:
+//
+//   - FormatHex: the private scalar D as 64 hex digits, or the SEC 1 point
+//     of a public key in hex;
+//   - FormatPKCS8: PEM PKCS #8 ("PRIVATE KEY", or "ENCRYPTED PRIVATE KEY"
+//     with a password) and SubjectPublicKeyInfo ("PUBLIC KEY");
+//   - FormatSEC1: the RFC 5915 ECPrivateKey ("EC PRIVATE KEY"), or the raw
+//     SEC 1 point of a public key;
+//   - FormatJWK: a JSON Web Key of type "EC" with curve "SM2";
+//   - FormatJSON: the key files of the account package, private.key and
+//     public.key;
+//   - FormatKeystore: the password-encrypted keystore of the account
+//     package, for private keys only;
+//   - FormatPKCS12: a PKCS #12 file with the private key and its
+//     certificates, for private keys only.
+//
+// Parsers accept PEM or DER where the format has both, validate the point
+// and check that a stored public key matches the private scalar.
+//
+// Password-encrypted PKCS #8 and PKCS #12 keys are written with PBES2,
+// PBKDF2 with HMAC-SM3 and SM4-CBC, and PKCS #12 files are authenticated
+// with HMAC-SM3. When reading, the SHA-1 and SHA-256 PRFs and AES-CBC of
+// OpenSSL and of sm2.MarshalSm2EcryptedPrivateKey are accepted as well.
+package keyconv
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/xuperchain/crypto/gm/account"
+	"github.com/xuperchain/crypto/gm/gmsm/compat/openssl"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// Format is a key format.
+type Format int
+
+const (
+	FormatHex Format = iota
+	FormatPKCS8
+	FormatSEC1
+	FormatJWK
+	FormatJSON
+	FormatKeystore
+	FormatPKCS12
+)
+
+var formatNames = []string{"hex", "pkcs8", "sec1", "jwk", "json", "keystore", "pkcs12"}
+
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+	return formatNames[f]
+}
+
+// ParseFormat returns the format of the given name, as returned by
+// Format.String.
+func ParseFormat(name string) (Format, error) {
+	for i, n := range formatNames {
+		if strings.EqualFold(name, n) {
+			return Format(i), nil
+		}
+	}
+	return 0, fmt.Errorf("keyconv: unknown format %q", name)
+}
+
+var (
+	// ErrUnsupported is returned for a format that cannot hold the key,
+	// such as a public key in a keystore, or for algorithms this package
+	// does not implement.
+	ErrUnsupported = errors.New("keyconv: unsupported format or algorithm")
+	// ErrMalformed is returned for data that is not a valid SM2 key in the
+	// format.
+	ErrMalformed = errors.New("keyconv: malformed key")
+	// ErrPassword is returned when a password is needed but not given, or
+	// does not decrypt the key.
+	ErrPassword = errors.New("keyconv: missing or incorrect password")
+)
+
+// Options are the options of the conversion functions. A nil *Options is
+// the zero value.
+type Options struct {
+	// Password encrypts and decrypts PKCS #8, keystore and PKCS #12 keys.
+	// A nil password writes unencrypted PKCS #8.
+	Password []byte
+	// Certificates are written with the key to PKCS #12, the certificate
+	// of the key first.
+	Certificates []*sm2.Certificate
+	// Compressed writes public keys in FormatHex and FormatSEC1 as
+	// compressed points.
+	Compressed bool
+	// ScryptN and ScryptP are the scrypt parameters of keystores, by
+	// default account.StandardScryptN and account.StandardScryptP.
+	ScryptN, ScryptP int
+}
+
+func (o *Options) password() []byte {
+	if o == nil {
+		return nil
+	}
+	return o.Password
+}
+
+// MarshalPrivateKey returns priv in format f.
+func MarshalPrivateKey(priv *sm2.PrivateKey, f Format, opts *Options) ([]byte, error) {
+	if err := checkPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	switch f {
+	case FormatHex:
+		return []byte(hex.EncodeToString(scalarBytes(priv.D))), nil
+	case FormatPKCS8:
+		if opts.Password == nil {
+			return openssl.MarshalPrivateKey(priv)
+		}
+		der, err := encryptPKCS8(priv, opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+	case FormatSEC1:
+		der, err := marshalECPrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	case FormatJWK:
+		return json.Marshal(jwk{
+			Kty: "EC",
+			Crv: "SM2",
+			X:   base64.RawURLEncoding.EncodeToString(scalarBytes(priv.X)),
+			Y:   base64.RawURLEncoding.EncodeToString(scalarBytes(priv.Y)),
+			D:   base64.RawURLEncoding.EncodeToString(scalarBytes(priv.D)),
+		})
+	case FormatJSON:
+		s, err := account.GetEcdsaPrivateKeyJsonFormat(toECDSA(priv))
+		return []byte(s), err
+	case FormatKeystore:
+		if opts.Password == nil {
+			return nil, ErrPassword
+		}
+		n, p := opts.ScryptN, opts.ScryptP
+		if n == 0 {
+			n, p = account.StandardScryptN, account.StandardScryptP
+		}
+		return account.EncryptKey(toECDSA(priv), string(opts.Password), n, p)
+	case FormatPKCS12:
+		return EncodePKCS12(priv, opts.Certificates, opts.Password)
+	}
+	return nil, ErrUnsupported
+}
+
+// ParsePrivateKey parses a private key in format f.
+func ParsePrivateKey(data []byte, f Format, opts *Options) (*sm2.PrivateKey, error) {
+	switch f {
+	case FormatHex:
+		s := strings.TrimPrefix(strings.TrimSpace(string(data)), "0x")
+		d, err := hex.DecodeString(s)
+		if err != nil || len(d) == 0 || len(d) > 32 {
+			return nil, ErrMalformed
+		}
+		return newPrivateKey(d)
+	case FormatPKCS8:
+		return parsePKCS8(data, opts.password())
+	case FormatSEC1:
+		der := data
+		if block, _ := pem.Decode(data); block != nil {
+			if block.Type != "EC PRIVATE KEY" {
+				return nil, ErrMalformed
+			}
+			der = block.Bytes
+		}
+		return parseECPrivateKey(der)
+	case FormatJWK:
+		var k jwk
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, ErrMalformed
+		}
+		if k.D == "" {
+			return nil, ErrMalformed
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		d, err := base64.RawURLEncoding.DecodeString(k.D)
+		if err != nil || len(d) != 32 {
+			return nil, ErrMalformed
+		}
+		return withPublicKey(d, pub)
+	case FormatJSON:
+		key, err := account.GetEcdsaPrivateKeyFromJson(data)
+		if err != nil || key.D == nil || key.X == nil || key.Y == nil || key.D.BitLen() > 256 {
+			return nil, ErrMalformed
+		}
+		return withPublicKey(scalarBytes(key.D), &sm2.PublicKey{Curve: key.Curve, X: key.X, Y: key.Y})
+	case FormatKeystore:
+		if opts.password() == nil {
+			return nil, ErrPassword
+		}
+		key, err := account.DecryptKey(data, string(opts.Password))
+		if err == account.ErrKeystorePassword {
+			return nil, ErrPassword
+		}
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		return newPrivateKey(scalarBytes(key.D))
+	case FormatPKCS12:
+		priv, _, err := DecodePKCS12(data, opts.password())
+		return priv, err
+	}
+	return nil, ErrUnsupported
+}
+
+// MarshalPublicKey returns pub in format f.
+func MarshalPublicKey(pub *sm2.PublicKey, f Format, opts *Options) ([]byte, error) {
+	if pub == nil || pub.Validate() != nil {
+		return nil, ErrMalformed
+	}
+	compressed := opts != nil && opts.Compressed
+	switch f {
+	case FormatHex:
+		return []byte(hex.EncodeToString(encodePoint(pub, compressed))), nil
+	case FormatPKCS8:
+		return openssl.MarshalPublicKey(pub)
+	case FormatSEC1:
+		return encodePoint(pub, compressed), nil
+	case FormatJWK:
+		return json.Marshal(jwk{
+			Kty: "EC",
+			Crv: "SM2",
+			X:   base64.RawURLEncoding.EncodeToString(scalarBytes(pub.X)),
+			Y:   base64.RawURLEncoding.EncodeToString(scalarBytes(pub.Y)),
+		})
+	case FormatJSON:
+		s, err := account.GetEcdsaPublicKeyJsonFormatFromPublicKey(&ecdsa.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y})
+		return []byte(s), err
+	}
+	return nil, ErrUnsupported
+}
+
+// ParsePublicKey parses a public key in format f.
+func ParsePublicKey(data []byte, f Format) (*sm2.PublicKey, error) {
+	var pub *sm2.PublicKey
+	switch f {
+	case FormatHex:
+		b, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		return decodePoint(b)
+	case FormatPKCS8:
+		p, err := openssl.ParsePublicKey(data)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		pub = p
+	case FormatSEC1:
+		return decodePoint(data)
+	case FormatJWK:
+		var k jwk
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, ErrMalformed
+		}
+		return k.publicKey()
+	case FormatJSON:
+		p, err := account.GetEcdsaPublicKeyFromJson(data)
+		if err != nil || p.X == nil || p.Y == nil {
+			return nil, ErrMalformed
+		}
+		pub = &sm2.PublicKey{Curve: p.Curve, X: p.X, Y: p.Y}
+	default:
+		return nil, ErrUnsupported
+	}
+	if pub.Validate() != nil {
+		return nil, ErrMalformed
+	}
+	return pub, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	D   string `json:"d,omitempty"`
+}
+
+func (k *jwk) publicKey() (*sm2.PublicKey, error) {
+	if k.Kty != "EC" || k.Crv != "SM2" {
+		return nil, ErrUnsupported
+	}
+	x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+	y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+	if err1 != nil || err2 != nil || len(x) != 32 || len(y) != 32 {
+		return nil, ErrMalformed
+	}
+	pub := &sm2.PublicKey{Curve: sm2.P256Sm2(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if pub.Validate() != nil {
+		return nil, ErrMalformed
+	}
+	return pub, nil
+}
+
+// ecPrivateKey is the ECPrivateKey of RFC 5915.
+type ecPrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+var oidCurveSM2 = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301}
+
+func marshalECPrivateKey(priv *sm2.PrivateKey) ([]byte, error) {
+	point := encodePoint(&priv.PublicKey, false)
+	return asn1.Marshal(ecPrivateKey{
+		Version:    1,
+		PrivateKey: scalarBytes(priv.D),
+		Curve:      oidCurveSM2,
+		PublicKey:  asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+}
+
+func parseECPrivateKey(der []byte) (*sm2.PrivateKey, error) {
+	var key ecPrivateKey
+	if rest, err := asn1.Unmarshal(der, &key); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	if key.Version != 1 || len(key.PrivateKey) > 32 || key.Curve != nil && !key.Curve.Equal(oidCurveSM2) {
+		return nil, ErrMalformed
+	}
+	if key.PublicKey.BitLength == 0 {
+		return newPrivateKey(key.PrivateKey)
+	}
+	pub, err := decodePoint(key.PublicKey.RightAlign())
+	if err != nil {
+		return nil, err
+	}
+	return withPublicKey(key.PrivateKey, pub)
+}
+
+// scalarBytes returns x as 32 bytes big-endian.
+func scalarBytes(x *big.Int) []byte {
+	b := x.Bytes()
+	out := make([]byte, 32)
+	copy(out[32-len(b):], b)
+	return out
+}
+
+func encodePoint(pub *sm2.PublicKey, compressed bool) []byte {
+	if compressed {
+		// sm2.Compress prefixes the parity of Y, SEC 1 2 or 3.
+		b := sm2.Compress(pub)
+		b[0] += 2
+		return b
+	}
+	return elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+}
+
+// decodePoint parses an uncompressed or compressed SEC 1 point.
+func decodePoint(b []byte) (*sm2.PublicKey, error) {
+	curve := sm2.P256Sm2()
+	switch {
+	case len(b) == 65 && b[0] == 4:
+		x, y := elliptic.Unmarshal(curve, b)
+		if x == nil {
+			return nil, ErrMalformed
+		}
+		return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case len(b) == 33 && (b[0] == 2 || b[0] == 3):
+		c := append([]byte(nil), b...)
+		c[0] -= 2
+		pub, err := sm2.Decompress(c)
+		if err != nil || pub.Validate() != nil {
+			return nil, ErrMalformed
+		}
+		return pub, nil
+	}
+	return nil, ErrMalformed
+}
+
+// newPrivateKey returns the key of the big-endian scalar d.
+func newPrivateKey(d []byte) (*sm2.PrivateKey, error) {
+	curve := sm2.P256Sm2()
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrMalformed
+	}
+	priv := &sm2.PrivateKey{D: k}
+	priv.Curve = curve
+	priv.X, priv.Y = curve.ScalarBaseMult(scalarBytes(k))
+	return priv, nil
+}
+
+// withPublicKey returns the key of the scalar d, which must match pub.
+func withPublicKey(d []byte, pub *sm2.PublicKey) (*sm2.PrivateKey, error) {
+	priv, err := newPrivateKey(d)
+	if err != nil {
+		return nil, err
+	}
+	if pub.Curve != priv.Curve || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+		return nil, ErrMalformed
+	}
+	return priv, nil
+}
+
+// checkPrivateKey checks that priv is an SM2 key whose public key matches
+// its scalar.
+func checkPrivateKey(priv *sm2.PrivateKey) error {
+	if priv == nil || priv.D == nil || priv.Curve != sm2.P256Sm2() || priv.X == nil || priv.Y == nil || priv.D.BitLen() > 256 {
+		return ErrMalformed
+	}
+	_, err := withPublicKey(priv.D.Bytes(), &priv.PublicKey)
+	return err
+}
+
+// sm2PublicKey returns the SM2 key of a certificate, which sm2 parses as
+// *ecdsa.PublicKey on the SM2 curve.
+func sm2PublicKey(pub interface{}) (*sm2.PublicKey, bool) {
+	switch pub := pub.(type) {
+	case *sm2.PublicKey:
+		return pub, true
+	case *ecdsa.PublicKey:
+		if pub.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}, true
+		}
+	}
+	return nil, false
+}
+
+func toECDSA(priv *sm2.PrivateKey) *ecdsa.PrivateKey {
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: priv.Curve, X: priv.X, Y: priv.Y},
+		D:         priv.D,
+	}
+}
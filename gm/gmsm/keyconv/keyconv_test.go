@@ -0,0 +1,241 @@
+package keyconv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/account"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// opensslPKCS12 was made by OpenSSL 3.0.17 with openssl pkcs12 -export
+// -keypbe SM4-CBC -certpbe SM4-CBC -macalg sm3 -passout pass:pw: an
+// encrypted certificate SafeContents and PBKDF2 with HMAC-SHA256.
+const (
+	opensslPKCS12 = `
+MIID+QIBAzCCA7AGCSqGSIb3DQEHAaCCA6EEggOdMIIDmTCCAlEGCSqGSIb3DQEHBqCCAkIwggI+
+AgEAMIICNwYJKoZIhvcNAQcBMFYGCSqGSIb3DQEFDTBJMCkGCSqGSIb3DQEFDDAcBAia2euR/jZa
+IgICCAAwDAYIKoZIhvcNAgkFADAcBggqgRzPVQFoAgQQ4Nm69TLZNlV8+6QZdu6RGoCCAdA3uQ8+
+6A2bKAd1AcTIEwNgwNPQuz8fCeQO5kMKZZcQL2v8RcxhKd3YUlJ3TTXap2R9z5u7+yZLA/l176g8
+wewXYe2vX2vXyjeQTNZi1nAkR7WnpoAToNQOi3EUBNh+/Hk35fAXbZMed8/5P1ALnBKcpAfydGuT
+z7xeiQujIGq3N5tiVNBvGIjlJBh99xso6TNJcSMCWEvrjY22a8s+Rv5EbeLkAE92rgsfC5Ae/Now
+5TvYvb03E54ZmKKNLsmFHy9qHuC+QmkPuvbAAoB7eZwGp+078inCWuC7phb+WESRcPpckMey/afJ
+gWz2dlwPj64Ztwedtk2rasA0snbHZZLg9MtMXLrLv1P3G3mu/2g5cqs1oCaVhXoT9rqb/zSOaFja
+asVl+01f9ECyclV/OM58BqUCVqyRxeYE1oJ3aBjUitKWsx3lDl/S25TyDDVh32DVQWagScZZyDVk
+oTjRVnQXhDqFi3P3JSwNz7b4/jVjieAPmeGGg4njrF0iPnL4n7Tee4AWuHa/qn4+AnExv6GJG1aL
+H1DuRC0et4xayfQ9ACLoBSM7iOoNDOwnTfJMW/Og3ox3OgAlUShpiFAjTOiJ0+8zTFGZs2Q+zMVO
+V17NTjCCAUAGCSqGSIb3DQEHAaCCATEEggEtMIIBKTCCASUGCyqGSIb3DQEMCgECoIHuMIHrMFYG
+CSqGSIb3DQEFDTBJMCkGCSqGSIb3DQEFDDAcBAijz2lfuz626wICCAAwDAYIKoZIhvcNAgkFADAc
+BggqgRzPVQFoAgQQoX95oOlCoSumpYL5ig4+MQSBkBa2wCfzGhWQmQ1hOFfec0kbJZ73j+BR6YSC
+X7pYmloWE08nJ4dfhhwyQpQP7yXtEUWgi54oHkkscLynxuN9aHJmWK6Pb0UUPTdAaaKPsW9oIv/k
+Bo0sNlrlHus1zeV4m2HxK4lHzyVAfrCIP23RR7dXPpAesna0RpcQVdLQlNYdG7cdnGkepBexn6gp
+LJou/DElMCMGCSqGSIb3DQEJFTEWBBRI7Yd3b50JoHJDkNkWVuPeyJ9P0jBAMDAwDAYIKoEcz1UB
+gxEFAAQgNUE6xj+c9aRbocR8rCDJXJgc45lsnlD6WreJUnl3k0IECJnh2eCmWhi4AgIIAA==`
+	opensslPKCS12Key = "a70accade58e7f3b782a9cb5de19fa0514df94aa5af5b0664782ab2e1d34b68f"
+)
+
+var password = []byte("correct horse")
+
+func newKey(t *testing.T) *sm2.PrivateKey {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	priv := newKey(t)
+	light := &Options{Password: password, ScryptN: account.LightScryptN, ScryptP: account.LightScryptP}
+	for _, tc := range []struct {
+		f    Format
+		opts *Options
+	}{
+		{FormatHex, nil},
+		{FormatPKCS8, nil},
+		{FormatPKCS8, &Options{Password: password}},
+		{FormatSEC1, nil},
+		{FormatJWK, nil},
+		{FormatJSON, nil},
+		{FormatKeystore, light},
+		{FormatPKCS12, light},
+	} {
+		data, err := MarshalPrivateKey(priv, tc.f, tc.opts)
+		if err != nil {
+			t.Fatalf("%v: %v", tc.f, err)
+		}
+		got, err := ParsePrivateKey(data, tc.f, tc.opts)
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", tc.f, err, data)
+		}
+		if got.D.Cmp(priv.D) != 0 || got.X.Cmp(priv.X) != 0 || got.Y.Cmp(priv.Y) != 0 {
+			t.Fatalf("%v: key changed", tc.f)
+		}
+		if tc.opts != nil {
+			if _, err := ParsePrivateKey(data, tc.f, &Options{Password: []byte("wrong")}); err != ErrPassword {
+				t.Errorf("%v: wrong password: %v", tc.f, err)
+			}
+			if _, err := ParsePrivateKey(data, tc.f, nil); err != ErrPassword {
+				t.Errorf("%v: no password: %v", tc.f, err)
+			}
+		}
+	}
+}
+
+func TestPublicKeyRoundTrip(t *testing.T) {
+	pub := &newKey(t).PublicKey
+	for _, f := range []Format{FormatHex, FormatPKCS8, FormatSEC1, FormatJWK, FormatJSON} {
+		for _, compressed := range []bool{false, true} {
+			data, err := MarshalPublicKey(pub, f, &Options{Compressed: compressed})
+			if err != nil {
+				t.Fatalf("%v: %v", f, err)
+			}
+			got, err := ParsePublicKey(data, f)
+			if err != nil {
+				t.Fatalf("%v: %v\n%s", f, err, data)
+			}
+			if got.X.Cmp(pub.X) != 0 || got.Y.Cmp(pub.Y) != 0 {
+				t.Fatalf("%v: key changed", f)
+			}
+		}
+	}
+	if data, _ := MarshalPublicKey(pub, FormatSEC1, &Options{Compressed: true}); len(data) != 33 {
+		t.Errorf("compressed SEC 1 point is %d bytes", len(data))
+	}
+	for _, f := range []Format{FormatKeystore, FormatPKCS12} {
+		if _, err := MarshalPublicKey(pub, f, nil); err != ErrUnsupported {
+			t.Errorf("%v: %v", f, err)
+		}
+	}
+}
+
+func TestHexPrivateKey(t *testing.T) {
+	priv, err := ParsePrivateKey([]byte(" 0x"+opensslPKCS12Key+"\n"), FormatHex, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(priv.D.Bytes()) != opensslPKCS12Key {
+		t.Fatal("wrong scalar")
+	}
+	n := sm2.P256Sm2().Params().N
+	for _, bad := range []string{"", "00", "zz", hex.EncodeToString(n.Bytes()), opensslPKCS12Key + "00"} {
+		if _, err := ParsePrivateKey([]byte(bad), FormatHex, nil); err != ErrMalformed {
+			t.Errorf("%q: %v", bad, err)
+		}
+	}
+}
+
+func TestMismatchedPublicKey(t *testing.T) {
+	priv, other := newKey(t), newKey(t)
+	data, err := MarshalPrivateKey(priv, FormatJWK, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var k map[string]string
+	json.Unmarshal(data, &k)
+	k["x"] = base64.RawURLEncoding.EncodeToString(scalarBytes(other.X))
+	k["y"] = base64.RawURLEncoding.EncodeToString(scalarBytes(other.Y))
+	data, _ = json.Marshal(k)
+	if _, err := ParsePrivateKey(data, FormatJWK, nil); err != ErrMalformed {
+		t.Errorf("JWK: %v", err)
+	}
+
+	der, err := marshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der = bytes.Replace(der, encodePoint(&priv.PublicKey, false), encodePoint(&other.PublicKey, false), 1)
+	if _, err := ParsePrivateKey(der, FormatSEC1, nil); err != ErrMalformed {
+		t.Errorf("SEC 1: %v", err)
+	}
+}
+
+func TestSM2EncryptedPKCS8(t *testing.T) {
+	priv := newKey(t)
+	data, err := sm2.WritePrivateKeytoMem(priv, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParsePrivateKey(data, FormatPKCS8, &Options{Password: password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Cmp(priv.D) != 0 {
+		t.Fatal("key changed")
+	}
+}
+
+func TestPKCS12Certificates(t *testing.T) {
+	priv := newKey(t)
+	template := &sm2.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: "node1"},
+		NotBefore:          time.Now(),
+		NotAfter:           time.Now().Add(time.Hour),
+		SignatureAlgorithm: sm2.SM2WithSM3,
+	}
+	der, err := sm2.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := sm2.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EncodePKCS12(newKey(t), []*sm2.Certificate{leaf}, password); err == nil {
+		t.Fatal("certificate of another key accepted")
+	}
+	p12, err := EncodePKCS12(priv, []*sm2.Certificate{leaf}, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, certs, err := DecodePKCS12(p12, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Cmp(priv.D) != 0 || len(certs) != 1 || !bytes.Equal(certs[0].Raw, der) {
+		t.Fatal("PKCS #12 contents changed")
+	}
+	p12[len(p12)/2] ^= 1
+	if _, _, err := DecodePKCS12(p12, password); err == nil {
+		t.Error("modified PKCS #12 accepted")
+	}
+}
+
+func TestOpenSSLPKCS12(t *testing.T) {
+	p12, err := base64.StdEncoding.DecodeString(opensslPKCS12[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, certs, err := DecodePKCS12(p12, []byte("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(scalarBytes(priv.D)) != opensslPKCS12Key {
+		t.Fatal("wrong key")
+	}
+	if len(certs) != 1 || certs[0].Subject.CommonName != "o" {
+		t.Fatalf("got %d certificates", len(certs))
+	}
+	if _, _, err := DecodePKCS12(p12, []byte("wrong")); err != ErrPassword {
+		t.Errorf("wrong password: %v", err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for f := FormatHex; f <= FormatPKCS12; f++ {
+		if got, err := ParseFormat(f.String()); err != nil || got != f {
+			t.Errorf("%v: %v, %v", f, got, err)
+		}
+	}
+	if _, err := ParseFormat("pem"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,252 @@
+package keyconv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"github.com/xuperchain/crypto/gm/gmsm/compat/openssl"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSM3    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401, 2}
+
+	oidSM4CBC    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 104, 2}
+	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const (
+	// pbeIterations is the PBKDF2 and PKCS #12 MAC iteration count of
+	// written keys, that of OpenSSL.
+	pbeIterations = 2048
+	// maxIterations bounds the iteration counts of parsed keys.
+	maxIterations = 1 << 22
+	pbeSaltSize   = 16
+)
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+func rawParams(v interface{}) (asn1.RawValue, error) {
+	der, err := asn1.Marshal(v)
+	return asn1.RawValue{FullBytes: der}, err
+}
+
+// pbes2Encrypt encrypts plaintext with PBES2, PBKDF2 with HMAC-SM3 and
+// SM4-CBC, and returns the algorithm identifier and the ciphertext.
+func pbes2Encrypt(password, plaintext []byte) (pkix.AlgorithmIdentifier, []byte, error) {
+	var algo pkix.AlgorithmIdentifier
+	salt := make([]byte, pbeSaltSize)
+	iv := make([]byte, sm4.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return algo, nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return algo, nil, err
+	}
+	kdf, err := rawParams(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbeIterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSM3, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return algo, nil, err
+	}
+	ivParams, err := rawParams(iv)
+	if err != nil {
+		return algo, nil, err
+	}
+	params, err := rawParams(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: kdf},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidSM4CBC, Parameters: ivParams},
+	})
+	if err != nil {
+		return algo, nil, err
+	}
+	key := pbkdf2.Key(password, salt, pbeIterations, sm4.KeySize, sm3.New)
+	ct, err := sm4.CBCEncrypt(key, iv, plaintext)
+	if err != nil {
+		return algo, nil, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: params}, ct, nil
+}
+
+// pbes2Decrypt decrypts ciphertext encrypted with PBES2 and PBKDF2, with
+// the PRFs and ciphers of the package documentation.
+func pbes2Decrypt(algo pkix.AlgorithmIdentifier, password, ciphertext []byte) ([]byte, error) {
+	if !algo.Algorithm.Equal(oidPBES2) {
+		return nil, ErrUnsupported
+	}
+	var params pbes2Params
+	if rest, err := asn1.Unmarshal(algo.Parameters.FullBytes, &params); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, ErrUnsupported
+	}
+	var kdf pbkdf2Params
+	if rest, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	if kdf.IterationCount <= 0 || kdf.IterationCount > maxIterations {
+		return nil, ErrUnsupported
+	}
+	var prf func() hash.Hash
+	switch {
+	case kdf.PRF.Algorithm == nil, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSM3):
+		prf = sm3.New
+	default:
+		return nil, ErrUnsupported
+	}
+
+	scheme := params.EncryptionScheme
+	var newCipher func([]byte) (cipher.Block, error)
+	var keySize int
+	switch {
+	case scheme.Algorithm.Equal(oidSM4CBC):
+		newCipher, keySize = sm4.NewCipher, sm4.KeySize
+	case scheme.Algorithm.Equal(oidAES128CBC):
+		newCipher, keySize = aes.NewCipher, 16
+	case scheme.Algorithm.Equal(oidAES256CBC):
+		newCipher, keySize = aes.NewCipher, 32
+	default:
+		return nil, ErrUnsupported
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keySize {
+		return nil, ErrMalformed
+	}
+	var iv []byte
+	if rest, err := asn1.Unmarshal(scheme.Parameters.FullBytes, &iv); err != nil || len(rest) != 0 || len(iv) != 16 {
+		return nil, ErrMalformed
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%16 != 0 {
+		return nil, ErrMalformed
+	}
+
+	block, err := newCipher(pbkdf2.Key(password, kdf.Salt, kdf.IterationCount, keySize, prf))
+	if err != nil {
+		return nil, err
+	}
+	pt := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(pt, ciphertext)
+	// A wrong password shows as bad padding or, with a 1/256 chance of
+	// valid padding, as a plaintext that does not parse.
+	pt, err = sm4.PKCS7Unpad(pt)
+	if err != nil {
+		return nil, ErrPassword
+	}
+	return pt, nil
+}
+
+// pkcs8DER returns the unencrypted PKCS #8 DER of priv.
+func pkcs8DER(priv *sm2.PrivateKey) ([]byte, error) {
+	p, err := openssl.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(p)
+	return block.Bytes, nil
+}
+
+// encryptPKCS8 returns priv as a DER EncryptedPrivateKeyInfo.
+func encryptPKCS8(priv *sm2.PrivateKey, password []byte) ([]byte, error) {
+	der, err := pkcs8DER(priv)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(der)
+	algo, ct, err := pbes2Encrypt(password, der)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: algo, EncryptedData: ct})
+}
+
+// decryptPKCS8 decrypts a DER EncryptedPrivateKeyInfo.
+func decryptPKCS8(der, password []byte) (*sm2.PrivateKey, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	pt, err := pbes2Decrypt(info.Algorithm, password, info.EncryptedData)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(pt)
+	priv, err := openssl.ParsePrivateKey(pt)
+	if err != nil {
+		return nil, ErrPassword
+	}
+	return priv, nil
+}
+
+// parsePKCS8 parses a PEM or DER PKCS #8 key, encrypted or not.
+func parsePKCS8(data, password []byte) (*sm2.PrivateKey, error) {
+	der, encrypted := data, false
+	if block, _ := pem.Decode(data); block != nil {
+		switch block.Type {
+		case "PRIVATE KEY":
+		case "ENCRYPTED PRIVATE KEY":
+			encrypted = true
+		default:
+			return nil, ErrMalformed
+		}
+		der = block.Bytes
+	} else if _, err := openssl.ParsePrivateKey(der); err != nil {
+		// DER is tried as an encrypted key when it is not a plain one.
+		encrypted = true
+	}
+	if !encrypted {
+		priv, err := openssl.ParsePrivateKey(der)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		return priv, nil
+	}
+	if password == nil {
+		return nil, ErrPassword
+	}
+	return decryptPKCS8(der, password)
+}
+
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
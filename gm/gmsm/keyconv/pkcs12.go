@@ -0,0 +1,415 @@
+package keyconv
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"io"
+	"unicode/utf8"
+
+	"github.com/xuperchain/crypto/gm/gmsm/compat/openssl"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// PKCS #12 (RFC 7292) is implemented for one private key and its
+// certificates. The key is kept in a pkcs8ShroudedKeyBag and the
+// certificates, which are public, in an unencrypted SafeContents.
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSM3    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401}
+)
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+// contentInfo is a PKCS #7 ContentInfo; Content is the [0] EXPLICIT
+// element itself, since encoding/asn1 does not apply tags to RawValue
+// when marshaling.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID     asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data asn1.RawValue
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue
+}
+
+// explicit returns der wrapped in [0] EXPLICIT.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// unwrapExplicit returns the element inside the [0] EXPLICIT v.
+func unwrapExplicit(v asn1.RawValue, out interface{}) error {
+	if v.Class != asn1.ClassContextSpecific || v.Tag != 0 || !v.IsCompound {
+		return ErrMalformed
+	}
+	if rest, err := asn1.Unmarshal(v.Bytes, out); err != nil || len(rest) != 0 {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// dataContentInfo returns a ContentInfo of type data holding content.
+func dataContentInfo(content []byte) (contentInfo, error) {
+	octets, err := asn1.Marshal(content)
+	return contentInfo{ContentType: oidData, Content: explicit(octets)}, err
+}
+
+// bmpPassword returns the password as the NUL-terminated BMPString the
+// PKCS #12 key derivation takes.
+func bmpPassword(password []byte) ([]byte, error) {
+	if !utf8.Valid(password) {
+		return nil, ErrUnsupported
+	}
+	var out []byte
+	for _, r := range bytes.Runes(password) {
+		if r > 0xffff {
+			return nil, ErrUnsupported
+		}
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return append(out, 0, 0), nil
+}
+
+// pkcs12KDF is the key derivation of RFC 7292, appendix B.2, with id 3
+// for MAC keys.
+func pkcs12KDF(h func() hash.Hash, id byte, password, salt []byte, iter, size int) []byte {
+	d := h()
+	u, v := d.Size(), d.BlockSize()
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+	diversifier := bytes.Repeat([]byte{id}, v)
+	I := append(fill(salt), fill(password)...)
+	var out []byte
+	for len(out) < size {
+		d.Reset()
+		d.Write(diversifier)
+		d.Write(I)
+		a := d.Sum(nil)
+		for i := 1; i < iter; i++ {
+			d.Reset()
+			d.Write(a)
+			a = d.Sum(a[:0])
+		}
+		out = append(out, a...)
+		if len(out) >= size {
+			break
+		}
+		// I_j = (I_j + B + 1) mod 2^(8v) for each v-byte block I_j.
+		b := make([]byte, v)
+		for i := range b {
+			b[i] = a[i%u]
+		}
+		for j := 0; j < len(I); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(I[j+k]) + int(b[k])
+				I[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// pkcs12MAC computes the HMAC of content keyed with the PKCS #12 MAC key.
+func pkcs12MAC(h func() hash.Hash, password, salt []byte, iter int, content []byte) []byte {
+	key := pkcs12KDF(h, 3, password, salt, iter, h().Size())
+	m := hmac.New(h, key)
+	m.Write(content)
+	return m.Sum(nil)
+}
+
+// EncodePKCS12 returns a PKCS #12 file holding priv and certs, the
+// certificate of priv first, protected by password.
+func EncodePKCS12(priv *sm2.PrivateKey, certs []*sm2.Certificate, password []byte) ([]byte, error) {
+	if err := checkPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	if password == nil {
+		return nil, ErrPassword
+	}
+	bmp, err := bmpPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []pkcs12Attribute
+	var authSafe []contentInfo
+	if len(certs) > 0 {
+		pub, ok := sm2PublicKey(certs[0].PublicKey)
+		if !ok || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+			return nil, errors.New("keyconv: the first certificate is not that of the key")
+		}
+		id, err := asn1.Marshal(sm3.Sm3Sum(certs[0].Raw))
+		if err != nil {
+			return nil, err
+		}
+		attrs = []pkcs12Attribute{{ID: oidLocalKeyID, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: id}}}
+
+		var bags []safeBag
+		for i, c := range certs {
+			der, err := asn1.Marshal(c.Raw)
+			if err != nil {
+				return nil, err
+			}
+			bag, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: explicit(der)})
+			if err != nil {
+				return nil, err
+			}
+			b := safeBag{ID: oidCertBag, Value: explicit(bag)}
+			if i == 0 {
+				b.Attributes = attrs
+			}
+			bags = append(bags, b)
+		}
+		ci, err := safeContents(bags)
+		if err != nil {
+			return nil, err
+		}
+		authSafe = append(authSafe, ci)
+	}
+
+	key, err := encryptPKCS8(priv, password)
+	if err != nil {
+		return nil, err
+	}
+	ci, err := safeContents([]safeBag{{ID: oidPKCS8ShroudedKeyBag, Value: explicit(key), Attributes: attrs}})
+	if err != nil {
+		return nil, err
+	}
+	authSafe = append(authSafe, ci)
+
+	content, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, pbeSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	mac := pkcs12MAC(sm3.New, bmp, salt, pbeIterations, content)
+	outer, err := dataContentInfo(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pfx{
+		Version:  3,
+		AuthSafe: outer,
+		MacData: macData{
+			Mac:        digestInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSM3, Parameters: asn1.NullRawValue}, Digest: mac},
+			MacSalt:    salt,
+			Iterations: pbeIterations,
+		},
+	})
+}
+
+func safeContents(bags []safeBag) (contentInfo, error) {
+	der, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return dataContentInfo(der)
+}
+
+// DecodePKCS12 parses a PKCS #12 file holding one SM2 private key, and
+// returns the key and the certificates, the certificate of the key first
+// when present. The MAC is required and may use SM3, SHA-256 or SHA-1.
+func DecodePKCS12(data, password []byte) (*sm2.PrivateKey, []*sm2.Certificate, error) {
+	if password == nil {
+		return nil, nil, ErrPassword
+	}
+	bmp, err := bmpPassword(password)
+	if err != nil {
+		return nil, nil, err
+	}
+	var p pfx
+	if rest, err := asn1.Unmarshal(data, &p); err != nil || len(rest) != 0 {
+		return nil, nil, ErrMalformed
+	}
+	if p.Version != 3 || !p.AuthSafe.ContentType.Equal(oidData) {
+		return nil, nil, ErrMalformed
+	}
+	var content []byte
+	if err := unwrapExplicit(p.AuthSafe.Content, &content); err != nil {
+		return nil, nil, err
+	}
+
+	var h func() hash.Hash
+	switch alg := p.MacData.Mac.Algorithm.Algorithm; {
+	case alg == nil:
+		return nil, nil, ErrUnsupported
+	case alg.Equal(oidSM3):
+		h = sm3.New
+	case alg.Equal(oidSHA256):
+		h = sha256.New
+	case alg.Equal(oidSHA1):
+		h = sha1.New
+	default:
+		return nil, nil, ErrUnsupported
+	}
+	if p.MacData.Iterations <= 0 || p.MacData.Iterations > maxIterations {
+		return nil, nil, ErrUnsupported
+	}
+	if !hmac.Equal(pkcs12MAC(h, bmp, p.MacData.MacSalt, p.MacData.Iterations, content), p.MacData.Mac.Digest) {
+		return nil, nil, ErrPassword
+	}
+
+	var authSafe []contentInfo
+	if rest, err := asn1.Unmarshal(content, &authSafe); err != nil || len(rest) != 0 {
+		return nil, nil, ErrMalformed
+	}
+	var priv *sm2.PrivateKey
+	var certs []*sm2.Certificate
+	for _, ci := range authSafe {
+		bags, err := openSafeContents(ci, password)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag), bag.ID.Equal(oidKeyBag):
+				if priv != nil {
+					return nil, nil, ErrUnsupported
+				}
+				var der asn1.RawValue
+				if err := unwrapExplicit(bag.Value, &der); err != nil {
+					return nil, nil, err
+				}
+				if bag.ID.Equal(oidKeyBag) {
+					priv, err = openssl.ParsePrivateKey(der.FullBytes)
+				} else {
+					priv, err = decryptPKCS8(der.FullBytes, password)
+				}
+				if err != nil {
+					return nil, nil, err
+				}
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				if err := unwrapExplicit(bag.Value, &cb); err != nil {
+					return nil, nil, err
+				}
+				var der []byte
+				if !cb.ID.Equal(oidCertTypeX509) {
+					continue
+				}
+				if err := unwrapExplicit(cb.Data, &der); err != nil {
+					return nil, nil, err
+				}
+				c, err := sm2.ParseCertificate(der)
+				if err != nil {
+					return nil, nil, ErrMalformed
+				}
+				certs = append(certs, c)
+			}
+		}
+	}
+	if priv == nil {
+		return nil, nil, ErrMalformed
+	}
+	for i, c := range certs {
+		if pub, ok := sm2PublicKey(c.PublicKey); ok && pub.X.Cmp(priv.X) == 0 && pub.Y.Cmp(priv.Y) == 0 {
+			certs[0], certs[i] = certs[i], certs[0]
+			break
+		}
+	}
+	return priv, certs, nil
+}
+
+// openSafeContents returns the bags of an element of the
+// AuthenticatedSafe, decrypting it if needed.
+func openSafeContents(ci contentInfo, password []byte) ([]safeBag, error) {
+	var der []byte
+	switch {
+	case ci.ContentType.Equal(oidData):
+		if err := unwrapExplicit(ci.Content, &der); err != nil {
+			return nil, err
+		}
+	case ci.ContentType.Equal(oidEncryptedData):
+		var ed encryptedData
+		if err := unwrapExplicit(ci.Content, &ed); err != nil {
+			return nil, err
+		}
+		eci := ed.EncryptedContentInfo
+		// encryptedContent is [0] IMPLICIT OCTET STRING, primitive in DER.
+		ct := eci.EncryptedContent
+		if !eci.ContentType.Equal(oidData) || ct.Class != asn1.ClassContextSpecific || ct.Tag != 0 || ct.IsCompound {
+			return nil, ErrUnsupported
+		}
+		var err error
+		if der, err = pbes2Decrypt(eci.ContentEncryptionAlgorithm, password, ct.Bytes); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+	var bags []safeBag
+	if rest, err := asn1.Unmarshal(der, &bags); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	return bags, nil
+}
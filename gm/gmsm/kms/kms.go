@@ -0,0 +1,174 @@
+// Package kms delegates SM2 signing and decryption to a key management
+// service, such as the KMS of a cloud provider or a self-hosted one, so
+// that the private keys stay in the service.
+//
+// Signer is the interface of a KMS. Remote implements it over a
+// Transport, with retries of transient failures; HTTPTransport is a
+// Transport speaking JSON over HTTP with HMAC-SM3 request signing, and a
+// gRPC client connection is one with a one-line adapter:
+//
+//	type grpcTransport struct{ *grpc.ClientConn }
+//
+//	func (t grpcTransport) Invoke(ctx context.Context, method string, req, resp interface{}) error {
+//		return t.ClientConn.Invoke(ctx, "/kms.KMS/"+method, req, resp)
+//	}
+//
+// Providers with their own APIs are reached by implementing Signer over
+// their SDKs. Cache wraps any Signer to keep public keys and certificates
+// locally, and NewKey exposes a KMS key as an sm2.OpaqueSigner.
+package kms
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+var (
+	ErrDigestSize   = errors.New("kms: SM2 signing takes an SM3 digest")
+	ErrPublicKey    = errors.New("kms: the key is not an SM2 key")
+	ErrKeyAgreement = errors.New("kms: key agreement is not supported")
+)
+
+// Signer is a key management service holding SM2 keys.
+type Signer interface {
+	// Sign signs the SM3 digest e = SM3(ZA || M) with the key and returns
+	// the ASN.1 signature.
+	Sign(ctx context.Context, keyID string, digest []byte) ([]byte, error)
+	// Decrypt decrypts a ciphertext of sm2.Encrypt to the key.
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+	// PublicKey returns the public key of the key.
+	PublicKey(ctx context.Context, keyID string) (*sm2.PublicKey, error)
+	// Certificate returns the certificate of the key.
+	Certificate(ctx context.Context, keyID string) (*sm2.Certificate, error)
+}
+
+// Key is a KMS key as an sm2.OpaqueSigner. Its operations use
+// context.Background; bound them with the timeout of the transport.
+type Key struct {
+	s     Signer
+	keyID string
+	pub   *sm2.PublicKey
+}
+
+var _ sm2.OpaqueSigner = (*Key)(nil)
+
+// NewKey fetches the public key of keyID and returns the key.
+func NewKey(ctx context.Context, s Signer, keyID string) (*Key, error) {
+	pub, err := s.PublicKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{s: s, keyID: keyID, pub: pub}, nil
+}
+
+// Public returns the *sm2.PublicKey of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs the SM3 digest in the KMS; rand and opts are ignored.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := k.sign(digest)
+	audit.Record(audit.OpSign, audit.BackendKMS, k.pub.X, k.pub.Y, err)
+	return sig, err
+}
+
+func (k *Key) sign(digest []byte) ([]byte, error) {
+	if len(digest) != sm3.Size {
+		return nil, ErrDigestSize
+	}
+	return k.s.Sign(context.Background(), k.keyID, digest)
+}
+
+// Decrypt decrypts the ciphertext in the KMS.
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	pt, err := k.decrypt(ciphertext)
+	audit.Record(audit.OpDecrypt, audit.BackendKMS, k.pub.X, k.pub.Y, err)
+	return pt, err
+}
+
+func (k *Key) decrypt(ciphertext []byte) ([]byte, error) {
+	return k.s.Decrypt(context.Background(), k.keyID, ciphertext)
+}
+
+// SharedSecret always fails: KMS APIs do not export key agreement secrets.
+func (k *Key) SharedSecret(peer *sm2.PublicKey) ([]byte, error) {
+	return nil, ErrKeyAgreement
+}
+
+// cache is a Signer that keeps the public keys and certificates of another.
+type cache struct {
+	Signer
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	pubs  map[string]cachedPub
+	certs map[string]cachedCert
+}
+
+type cachedPub struct {
+	pub     *sm2.PublicKey
+	expires time.Time
+}
+
+type cachedCert struct {
+	cert    *sm2.Certificate
+	expires time.Time
+}
+
+// Cache returns a Signer that caches the public keys and certificates of s
+// for ttl. Failures are not cached.
+func Cache(s Signer, ttl time.Duration) Signer {
+	return &cache{
+		Signer: s,
+		ttl:    ttl,
+		now:    time.Now,
+		pubs:   map[string]cachedPub{},
+		certs:  map[string]cachedCert{},
+	}
+}
+
+func (c *cache) PublicKey(ctx context.Context, keyID string) (*sm2.PublicKey, error) {
+	c.mu.Lock()
+	e, ok := c.pubs[keyID]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.pub, nil
+	}
+
+	pub, err := c.Signer.PublicKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.pubs[keyID] = cachedPub{pub, c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return pub, nil
+}
+
+func (c *cache) Certificate(ctx context.Context, keyID string) (*sm2.Certificate, error) {
+	c.mu.Lock()
+	e, ok := c.certs[keyID]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.cert, nil
+	}
+
+	cert, err := c.Signer.Certificate(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.certs[keyID] = cachedCert{cert, c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return cert, nil
+}
@@ -0,0 +1,252 @@
+package kms
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// server is a self-hosted KMS with one key.
+type server struct {
+	t        *testing.T
+	priv     *sm2.PrivateKey
+	cert     []byte
+	calls    map[string]*int32
+	failures int32
+}
+
+func newServer(t *testing.T) *server {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &sm2.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: "kms key"},
+		NotBefore:          time.Unix(1000, 0),
+		NotAfter:           time.Unix(100000, 0),
+		SignatureAlgorithm: sm2.SM2WithSM3,
+	}
+	cert, err := sm2.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{t: t, priv: priv, cert: cert, calls: map[string]*int32{}}
+	for _, m := range []string{MethodSign, MethodDecrypt, MethodGetPublicKey, MethodGetCertificate} {
+		s.calls[m] = new(int32)
+	}
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, body, err := VerifyRequest(r, func(id string) ([]byte, bool) {
+		return []byte("secret"), id == "access"
+	}, time.Minute)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if atomic.AddInt32(&s.failures, -1) >= 0 {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	method := r.URL.Path[len("/v1/"):]
+	if s.calls[method] == nil {
+		http.NotFound(w, r)
+		return
+	}
+	atomic.AddInt32(s.calls[method], 1)
+	var resp interface{}
+	switch method {
+	case MethodSign:
+		var req SignRequest
+		json.Unmarshal(body, &req)
+		sig, err := s.priv.Sign(nil, req.Digest, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp = SignResponse{sig}
+	case MethodDecrypt:
+		var req DecryptRequest
+		json.Unmarshal(body, &req)
+		pt, err := sm2.Decrypt(s.priv, req.Ciphertext)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp = DecryptResponse{pt}
+	case MethodGetPublicKey:
+		der, err := sm2.MarshalPKIXPublicKey(&s.priv.PublicKey)
+		if err != nil {
+			s.t.Error(err)
+		}
+		resp = PublicKeyResponse{der}
+	case MethodGetCertificate:
+		resp = CertificateResponse{s.cert}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newRemote(t *testing.T, s *server) (*Remote, func()) {
+	ts := httptest.NewServer(s)
+	r := NewRemote(&HTTPTransport{Endpoint: ts.URL, AccessKeyID: "access", Secret: []byte("secret")})
+	r.Backoff = time.Millisecond
+	return r, ts.Close
+}
+
+func TestRemote(t *testing.T) {
+	s := newServer(t)
+	r, done := newRemote(t, s)
+	defer done()
+	ctx := context.Background()
+
+	key, err := NewKey(ctx, r, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(*sm2.PublicKey)
+	if pub.X.Cmp(s.priv.X) != 0 || pub.Y.Cmp(s.priv.Y) != 0 {
+		t.Fatal("wrong public key")
+	}
+
+	digest := sm3.Sm3Sum([]byte("message"))
+	sig, err := key.Sign(nil, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Verify(digest, sig) {
+		t.Fatal("the signature does not verify")
+	}
+	if _, err := key.Sign(nil, []byte("message"), nil); err != ErrDigestSize {
+		t.Fatalf("expected ErrDigestSize, got %v", err)
+	}
+
+	ct, err := sm2.Encrypt(pub, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := key.Decrypt(ct)
+	if err != nil || string(pt) != "secret" {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+
+	cert, err := r.Certificate(ctx, "key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "kms key" {
+		t.Fatalf("certificate of %q", cert.Subject.CommonName)
+	}
+}
+
+func TestRemoteRetries(t *testing.T) {
+	s := newServer(t)
+	r, done := newRemote(t, s)
+	defer done()
+	ctx := context.Background()
+	digest := sm3.Sm3Sum([]byte("message"))
+
+	s.failures = 3
+	if _, err := r.Sign(ctx, "key-1", digest); err != nil {
+		t.Fatalf("three failures must be retried: %v", err)
+	}
+	s.failures = 4
+	err := func() error { _, err := r.Sign(ctx, "key-1", digest); return err }()
+	if e, ok := err.(*HTTPError); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected HTTP 503 after the retries, got %v", err)
+	}
+
+	// Authentication failures are not retried.
+	s.failures = 0
+	r.Transport.(*HTTPTransport).Secret = []byte("wrong")
+	before := atomic.LoadInt32(&s.failures)
+	err = func() error { _, err := r.Sign(ctx, "key-1", digest); return err }()
+	if e, ok := err.(*HTTPError); !ok || e.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected HTTP 401, got %v", err)
+	}
+	if atomic.LoadInt32(&s.failures) != before {
+		t.Fatal("an authentication failure was retried")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := r.Sign(cancelled, "key-1", digest); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	s := newServer(t)
+	r, done := newRemote(t, s)
+	defer done()
+	ctx := context.Background()
+
+	now := time.Unix(0, 0)
+	c := Cache(r, time.Minute).(*cache)
+	c.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := c.PublicKey(ctx, "key-1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Certificate(ctx, "key-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *s.calls[MethodGetPublicKey] != 1 || *s.calls[MethodGetCertificate] != 1 {
+		t.Fatal("the cache does not hold")
+	}
+	now = now.Add(2 * time.Minute)
+	c.PublicKey(ctx, "key-1")
+	c.Certificate(ctx, "key-1")
+	if *s.calls[MethodGetPublicKey] != 2 || *s.calls[MethodGetCertificate] != 2 {
+		t.Fatal("the cache does not expire")
+	}
+
+	// Operations on the key go to the KMS.
+	if _, err := c.Sign(ctx, "key-1", sm3.Sm3Sum(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if *s.calls[MethodSign] != 1 {
+		t.Fatal("Sign was not forwarded")
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	var got []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, body, err := VerifyRequest(r, func(id string) ([]byte, bool) { return []byte("secret"), true }, time.Minute)
+		if err != nil || id != "access" {
+			http.Error(w, "denied", http.StatusUnauthorized)
+			return
+		}
+		got = body
+		w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	tr := &HTTPTransport{Endpoint: ts.URL + "/", AccessKeyID: "access", Secret: []byte("secret")}
+	if err := tr.Invoke(context.Background(), MethodSign, &SignRequest{KeyID: "k"}, &SignResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"keyId":"k","digest":null}` {
+		t.Fatalf("body %s", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/Sign", nil)
+	req.Header.Set(HeaderDate, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	if _, _, err := VerifyRequest(req, func(string) ([]byte, bool) { return nil, true }, time.Minute); err != ErrRequestSignature {
+		t.Fatalf("expected ErrRequestSignature for a stale date, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// The methods of the KMS protocol and their messages. []byte fields are
+// base64 in JSON.
+const (
+	MethodSign           = "Sign"
+	MethodDecrypt        = "Decrypt"
+	MethodGetPublicKey   = "GetPublicKey"
+	MethodGetCertificate = "GetCertificate"
+)
+
+type SignRequest struct {
+	KeyID  string `json:"keyId"`
+	Digest []byte `json:"digest"`
+}
+
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+type DecryptRequest struct {
+	KeyID      string `json:"keyId"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type DecryptResponse struct {
+	Plaintext []byte `json:"plaintext"`
+}
+
+type KeyRequest struct {
+	KeyID string `json:"keyId"`
+}
+
+type PublicKeyResponse struct {
+	// PublicKey is the PKIX DER encoding of the key.
+	PublicKey []byte `json:"publicKey"`
+}
+
+type CertificateResponse struct {
+	// Certificate is the DER encoding of the certificate.
+	Certificate []byte `json:"certificate"`
+}
+
+// Transport sends a request of the KMS protocol and decodes the response.
+// Errors with a Temporary method that returns true are retried.
+type Transport interface {
+	Invoke(ctx context.Context, method string, req, resp interface{}) error
+}
+
+// Remote is a Signer speaking the KMS protocol over a Transport.
+type Remote struct {
+	Transport Transport
+	// MaxRetries is the number of retries of a temporary failure.
+	MaxRetries int
+	// Backoff is the delay before the first retry; it doubles with every
+	// retry.
+	Backoff time.Duration
+}
+
+// NewRemote returns a Remote that retries three times, after 100ms, 200ms
+// and 400ms.
+func NewRemote(t Transport) *Remote {
+	return &Remote{Transport: t, MaxRetries: 3, Backoff: 100 * time.Millisecond}
+}
+
+var _ Signer = (*Remote)(nil)
+
+func (r *Remote) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	backoff := r.Backoff
+	for i := 0; ; i++ {
+		err := r.Transport.Invoke(ctx, method, req, resp)
+		if err == nil || i >= r.MaxRetries || !isTemporary(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func isTemporary(err error) bool {
+	t, ok := err.(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
+
+func (r *Remote) Sign(ctx context.Context, keyID string, digest []byte) ([]byte, error) {
+	if len(digest) != sm3.Size {
+		return nil, ErrDigestSize
+	}
+	var resp SignResponse
+	if err := r.invoke(ctx, MethodSign, &SignRequest{keyID, digest}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+func (r *Remote) Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	var resp DecryptResponse
+	if err := r.invoke(ctx, MethodDecrypt, &DecryptRequest{keyID, ciphertext}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (r *Remote) PublicKey(ctx context.Context, keyID string) (*sm2.PublicKey, error) {
+	var resp PublicKeyResponse
+	if err := r.invoke(ctx, MethodGetPublicKey, &KeyRequest{keyID}, &resp); err != nil {
+		return nil, err
+	}
+	pub, err := sm2.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	// ParsePKIXPublicKey returns SM2 keys as *ecdsa.PublicKey.
+	switch pub := pub.(type) {
+	case *sm2.PublicKey:
+		return pub, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}, nil
+		}
+	}
+	return nil, ErrPublicKey
+}
+
+func (r *Remote) Certificate(ctx context.Context, keyID string) (*sm2.Certificate, error) {
+	var resp CertificateResponse
+	if err := r.invoke(ctx, MethodGetCertificate, &KeyRequest{keyID}, &resp); err != nil {
+		return nil, err
+	}
+	return sm2.ParseCertificate(resp.Certificate)
+}
+
+// HTTPError is a non-200 response of an HTTP KMS.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("kms: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed when retried.
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// networkError is a failure to reach the KMS; signing and decryption are
+// idempotent, so it is retried.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string   { return "kms: " + e.err.Error() }
+func (e *networkError) Temporary() bool { return true }
+
+// The headers of the request signature.
+const (
+	HeaderDate      = "X-Kms-Date"
+	HeaderAccessKey = "X-Kms-Access-Key"
+	HeaderSignature = "X-Kms-Signature"
+)
+
+// HTTPTransport posts the JSON request of a method to Endpoint/v1/<method>
+// and signs it with HMAC-SM3 under Secret: the X-Kms-Signature header is
+// the hex HMAC of the four lines, each ending in a newline,
+//
+//	POST
+//	<path>
+//	<X-Kms-Date>
+//	<hex SM3 of the body>
+//
+// with the date in RFC 3339 format. Servers check it with VerifyRequest.
+type HTTPTransport struct {
+	// Endpoint is the base URL, e.g. https://kms.example.com.
+	Endpoint    string
+	AccessKeyID string
+	Secret      []byte
+	// Client is the HTTP client, http.DefaultClient if nil; set its Timeout.
+	Client *http.Client
+}
+
+func (t *HTTPTransport) Invoke(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	path := "/v1/" + method
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimRight(t.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	date := time.Now().UTC().Format(time.RFC3339)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(HeaderDate, date)
+	httpReq.Header.Set(HeaderAccessKey, t.AccessKeyID)
+	httpReq.Header.Set(HeaderSignature, hex.EncodeToString(requestMAC(t.Secret, path, date, body)))
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &networkError{err}
+	}
+	defer httpResp.Body.Close()
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return &networkError{err}
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return &HTTPError{httpResp.StatusCode, strings.TrimSpace(string(respBody))}
+	}
+	return json.Unmarshal(respBody, resp)
+}
+
+func requestMAC(secret []byte, path, date string, body []byte) []byte {
+	mac := hmac.New(sm3.New, secret)
+	mac.Write([]byte("POST\n" + path + "\n" + date + "\n" + hex.EncodeToString(sm3.Sm3Sum(body)) + "\n"))
+	return mac.Sum(nil)
+}
+
+var ErrRequestSignature = errors.New("kms: invalid request signature")
+
+// VerifyRequest checks the signature of a request of HTTPTransport for a
+// self-hosted KMS and returns its access key ID and body. secret looks up
+// the secret of an access key ID; the date must be within maxSkew of now.
+func VerifyRequest(r *http.Request, secret func(accessKeyID string) ([]byte, bool), maxSkew time.Duration) (string, []byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	date := r.Header.Get(HeaderDate)
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return "", nil, ErrRequestSignature
+	}
+	if d := time.Since(t); d > maxSkew || d < -maxSkew {
+		return "", nil, ErrRequestSignature
+	}
+	accessKeyID := r.Header.Get(HeaderAccessKey)
+	key, ok := secret(accessKeyID)
+	if !ok {
+		return "", nil, ErrRequestSignature
+	}
+	sig, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil || r.Method != http.MethodPost || !hmac.Equal(sig, requestMAC(key, r.URL.Path, date, body)) {
+		return "", nil, ErrRequestSignature
+	}
+	return accessKeyID, body, nil
+}
@@ -0,0 +1,177 @@
+package openpgp
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// oidSM2 is the DER contents of the OID of the SM2 curve,
+// 1.2.156.10197.1.301, as public key packets carry it.
+var oidSM2 = []byte{0x2a, 0x81, 0x1c, 0xcf, 0x55, 0x01, 0x82, 0x2d}
+
+const pointSize = 65
+
+// PublicKey is a version 4 SM2 public key packet.
+type PublicKey struct {
+	CreationTime time.Time
+	Key          *sm2.PublicKey
+	// IsSubkey tells whether the key is serialized as a public subkey
+	// packet rather than a primary key.
+	IsSubkey bool
+}
+
+// NewPublicKey returns the key packet of pub created at the given time,
+// which is part of the fingerprint.
+func NewPublicKey(pub *sm2.PublicKey, created time.Time) (*PublicKey, error) {
+	if err := pub.Validate(); err != nil {
+		return nil, err
+	}
+	return &PublicKey{CreationTime: created.Truncate(time.Second), Key: pub}, nil
+}
+
+// body returns the packet body, which the fingerprint and key signatures
+// cover.
+func (pk *PublicKey) body() []byte {
+	b := []byte{4, 0, 0, 0, 0, PubKeyAlgoSM2, byte(len(oidSM2))}
+	binary.BigEndian.PutUint32(b[1:5], uint32(pk.CreationTime.Unix()))
+	b = append(b, oidSM2...)
+	return appendMPI(b, elliptic.Marshal(sm2.P256Sm2(), pk.Key.X, pk.Key.Y))
+}
+
+// hashPrefix returns the bytes a key signature hashes for pk: 0x99, the
+// two-byte body length and the body.
+func (pk *PublicKey) hashPrefix() []byte {
+	body := pk.body()
+	return append([]byte{0x99, byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+// userIDPrefix returns the bytes a certification of userID and pk hashes:
+// the hash prefix of pk, 0xb4, the four-byte length of userID and userID.
+func (pk *PublicKey) userIDPrefix(userID string) []byte {
+	b := append(pk.hashPrefix(), 0xb4, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(b)-4:], uint32(len(userID)))
+	return append(b, userID...)
+}
+
+// Fingerprint returns the version 4 fingerprint of pk, the SHA-1 hash of
+// its packet as RFC 4880, section 12.2 defines it.
+func (pk *PublicKey) Fingerprint() [20]byte {
+	return sha1.Sum(pk.hashPrefix())
+}
+
+// KeyID returns the low 64 bits of the fingerprint.
+func (pk *PublicKey) KeyID() uint64 {
+	fp := pk.Fingerprint()
+	return binary.BigEndian.Uint64(fp[12:])
+}
+
+// Serialize writes the public key or public subkey packet of pk.
+func (pk *PublicKey) Serialize(w io.Writer) error {
+	tag := byte(tagPublicKey)
+	if pk.IsSubkey {
+		tag = tagPublicSubkey
+	}
+	return writePacket(w, tag, pk.body())
+}
+
+// parsePublicKey parses a key packet body, returning errNotSM2 for keys of
+// other algorithms and versions.
+func parsePublicKey(body []byte) (*PublicKey, error) {
+	if len(body) < 7 || body[0] != 4 || body[5] != PubKeyAlgoSM2 {
+		return nil, errNotSM2
+	}
+	created := time.Unix(int64(binary.BigEndian.Uint32(body[1:5])), 0)
+	n := int(body[6])
+	if len(body)-7 < n || !bytes.Equal(body[7:7+n], oidSM2) {
+		return nil, ErrMalformed
+	}
+	point, rest, err := readMPI(body[7+n:])
+	if err != nil || len(rest) != 0 || len(point) != pointSize {
+		return nil, ErrMalformed
+	}
+	curve := sm2.P256Sm2()
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, ErrMalformed
+	}
+	return &PublicKey{CreationTime: created, Key: &sm2.PublicKey{Curve: curve, X: x, Y: y}}, nil
+}
+
+var errNotSM2 = errors.New("openpgp: not an SM2 key")
+
+// ReadPublicKeys reads a sequence of packets, such as a transferable public
+// key, and returns its SM2 primary keys and subkeys in order. Other packets
+// and keys of other algorithms are skipped. Self-signatures are not
+// verified: check the fingerprint of a key through a trusted channel before
+// relying on it.
+func ReadPublicKeys(r io.Reader) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	for {
+		p, err := readPacket(r)
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.tag != tagPublicKey && p.tag != tagPublicSubkey {
+			continue
+		}
+		pk, err := parsePublicKey(p.body)
+		if err == errNotSM2 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		pk.IsSubkey = p.tag == tagPublicSubkey
+		keys = append(keys, pk)
+	}
+}
+
+// PrivateKey is an SM2 private key with its public key packet.
+type PrivateKey struct {
+	PublicKey
+	PrivateKey *sm2.PrivateKey
+}
+
+// NewPrivateKey returns the key of priv created at the given time.
+func NewPrivateKey(priv *sm2.PrivateKey, created time.Time) (*PrivateKey, error) {
+	pk, err := NewPublicKey(&priv.PublicKey, created)
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateKey{PublicKey: *pk, PrivateKey: priv}, nil
+}
+
+// SerializePublic writes the transferable public key of k: its public key
+// packet, a user ID packet and a positive certification of the two made
+// with k, which declares the key fit for signing and encryption and SM4,
+// SM3 and no compression as preferred algorithms.
+func (k *PrivateKey) SerializePublic(w io.Writer, userID string, now time.Time) error {
+	pk := k.PublicKey
+	pk.IsSubkey = false
+	if err := pk.Serialize(w); err != nil {
+		return err
+	}
+	if err := writePacket(w, tagUserID, []byte(userID)); err != nil {
+		return err
+	}
+	var subpackets []byte
+	subpackets = appendSubpacket(subpackets, subpacketKeyFlags, []byte{keyFlagsAll})
+	subpackets = appendSubpacket(subpackets, subpacketPreferredCipher, []byte{CipherSM4})
+	subpackets = appendSubpacket(subpackets, subpacketPreferredHash, []byte{HashSM3})
+	subpackets = appendSubpacket(subpackets, subpacketPreferredCompression, []byte{0})
+	sig, err := k.sign(SigTypePositiveCert, subpackets, bytes.NewReader(pk.userIDPrefix(userID)), now)
+	if err != nil {
+		return err
+	}
+	return sig.Serialize(w)
+}
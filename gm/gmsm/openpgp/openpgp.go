@@ -0,0 +1,398 @@
+// Package openpgp implements the OpenPGP packets of RFC 4880 that carry SM2
+// keys, SM3 signatures and SM4-encrypted messages, with the algorithm
+// numbers and conventions of RNP and the GnuPG GM branches:
+//
+//   - SM2 is public key algorithm 99, SM3 hash algorithm 105 and SM4
+//     symmetric algorithm 105, all from the private and experimental range;
+//   - an SM2 key packet is laid out as an ECDSA one, with the OID of the SM2
+//     curve and the uncompressed point;
+//   - an SM2 signature hashes ZA under sm2.DefaultUID before the data and
+//     trailer, and carries r and s as two MPIs;
+//   - an SM2 public-key encrypted session key is one MPI of the DER
+//     ciphertext of GM/T 0009, as compat/openssl encodes it, followed by
+//     the hash algorithm number, 105.
+//
+// Messages are encrypted as symmetrically encrypted integrity protected
+// data with SM4, optionally signed inside. Decryption also reads compressed
+// packets and partial body lengths, as other implementations write them.
+// Everything is handled in memory; there is no ASCII armor.
+package openpgp
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/compat/openssl"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// Algorithm numbers.
+const (
+	PubKeyAlgoSM2 = 99
+	HashSM3       = 105
+	CipherSM4     = 105
+
+	cipherAES128 = 7
+	cipherAES192 = 8
+	cipherAES256 = 9
+)
+
+var (
+	// ErrMalformed is returned for packets that cannot be parsed.
+	ErrMalformed = errors.New("openpgp: malformed packet")
+	// ErrUnsupported is returned for valid packets this package does not
+	// implement, such as other algorithms or versions.
+	ErrUnsupported = errors.New("openpgp: unsupported packet")
+	// ErrSignature is returned for a signature that does not verify.
+	ErrSignature = errors.New("openpgp: invalid signature")
+	// ErrKeyIncorrect is returned by Decrypt when no session key is
+	// encrypted to the given keys.
+	ErrKeyIncorrect = errors.New("openpgp: no session key for the given keys")
+	// ErrDecryption is returned for a message that fails its integrity
+	// check or whose session key does not decrypt.
+	ErrDecryption = errors.New("openpgp: decryption failed")
+)
+
+// newBlock returns the block cipher of a symmetric algorithm number. AES is
+// accepted for messages from peers that do not prefer SM4.
+func newBlock(algo byte, key []byte) (cipher.Block, error) {
+	size := map[byte]int{CipherSM4: sm4.KeySize, cipherAES128: 16, cipherAES192: 24, cipherAES256: 32}[algo]
+	if size == 0 {
+		return nil, ErrUnsupported
+	}
+	if len(key) != size {
+		return nil, ErrDecryption
+	}
+	if algo == CipherSM4 {
+		return sm4.NewCipher(key)
+	}
+	return aes.NewCipher(key)
+}
+
+// Message is a decrypted message.
+type Message struct {
+	FileName string
+	ModTime  time.Time
+	Data     []byte
+	// Signature is the signature inside the message, nil if it was not
+	// signed. It is not verified by Decrypt; call Verify.
+	Signature *Signature
+}
+
+// Verify checks the signature of m against pk, which must be the key that
+// Signature.IssuerKeyID names.
+func (m *Message) Verify(pk *PublicKey) error {
+	if m.Signature == nil {
+		return ErrSignature
+	}
+	return m.Signature.Verify(pk, bytes.NewReader(m.Data))
+}
+
+// Encrypt writes data as a message encrypted with SM4 to recipients, signed
+// with signer unless it is nil.
+func Encrypt(w io.Writer, data []byte, recipients []*PublicKey, signer *PrivateKey) error {
+	return encrypt(w, data, recipients, signer, CipherSM4, sm4.KeySize)
+}
+
+func encrypt(w io.Writer, data []byte, recipients []*PublicKey, signer *PrivateKey, algo byte, keySize int) error {
+	if len(recipients) == 0 {
+		return errors.New("openpgp: no recipients")
+	}
+	now := time.Now()
+	var inner []byte
+	if signer != nil {
+		ops := []byte{3, SigTypeBinary, HashSM3, PubKeyAlgoSM2, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+		binary.BigEndian.PutUint64(ops[4:12], signer.KeyID())
+		inner = appendPacket(inner, tagOnePassSignature, ops)
+	}
+	literal := []byte{'b', 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(literal[2:], uint32(now.Unix()))
+	inner = appendPacket(inner, tagLiteral, append(literal, data...))
+	if signer != nil {
+		sig, err := signer.sign(SigTypeBinary, nil, bytes.NewReader(data), now)
+		if err != nil {
+			return err
+		}
+		inner = appendPacket(inner, tagSignature, sig.body())
+	}
+
+	sessionKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, sessionKey); err != nil {
+		return err
+	}
+	defer zeroize(sessionKey)
+	for _, pk := range recipients {
+		body, err := encryptSessionKey(pk, algo, sessionKey)
+		if err != nil {
+			return err
+		}
+		if err := writePacket(w, tagPKESK, body); err != nil {
+			return err
+		}
+	}
+	body, err := sealSEIPD(algo, sessionKey, inner)
+	if err != nil {
+		return err
+	}
+	return writePacket(w, tagSEIPD, body)
+}
+
+// encryptSessionKey returns a PKESK body encrypting algo || key || checksum
+// to pk.
+func encryptSessionKey(pk *PublicKey, algo byte, key []byte) ([]byte, error) {
+	m := append([]byte{algo}, key...)
+	sum := checksum(key)
+	m = append(m, byte(sum>>8), byte(sum))
+	defer zeroize(m)
+	der, err := openssl.Encrypt(pk.Key, m)
+	if err != nil {
+		return nil, err
+	}
+	b := []byte{3, 0, 0, 0, 0, 0, 0, 0, 0, PubKeyAlgoSM2}
+	binary.BigEndian.PutUint64(b[1:9], pk.KeyID())
+	return appendMPI(b, append(der, HashSM3)), nil
+}
+
+func checksum(key []byte) uint16 {
+	var sum uint16
+	for _, b := range key {
+		sum += uint16(b)
+	}
+	return sum
+}
+
+// decryptSessionKey returns the symmetric algorithm and session key of a
+// PKESK body for k, or ErrKeyIncorrect if it is not for k.
+func decryptSessionKey(body []byte, k *PrivateKey) (byte, []byte, error) {
+	if len(body) < 10 || body[0] != 3 || body[9] != PubKeyAlgoSM2 {
+		return 0, nil, ErrKeyIncorrect
+	}
+	// Key ID 0 is a wildcard recipient, tried with every key.
+	if id := binary.BigEndian.Uint64(body[1:9]); id != 0 && id != k.KeyID() {
+		return 0, nil, ErrKeyIncorrect
+	}
+	ct, rest, err := readMPI(body[10:])
+	if err != nil || len(rest) != 0 || len(ct) < 2 {
+		return 0, nil, ErrMalformed
+	}
+	if ct[len(ct)-1] != HashSM3 {
+		return 0, nil, ErrUnsupported
+	}
+	m, err := openssl.Decrypt(k.PrivateKey, ct[:len(ct)-1])
+	if err != nil {
+		return 0, nil, ErrKeyIncorrect
+	}
+	defer zeroize(m)
+	if len(m) < 4 {
+		return 0, nil, ErrDecryption
+	}
+	key := m[1 : len(m)-2]
+	sum := checksum(key)
+	if m[len(m)-2] != byte(sum>>8) || m[len(m)-1] != byte(sum) {
+		return 0, nil, ErrDecryption
+	}
+	return m[0], append([]byte(nil), key...), nil
+}
+
+// mdcHeader is the header of the modification detection code packet, which
+// is hashed with the plaintext.
+var mdcHeader = []byte{0xc0 | tagMDC, sha1.Size}
+
+// sealSEIPD returns the body of a version 1 SEIPD packet: the random prefix
+// with its two-byte repetition, plaintext and MDC, encrypted in CFB mode
+// with a zero IV.
+func sealSEIPD(algo byte, key, plaintext []byte) ([]byte, error) {
+	block, err := newBlock(algo, key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	buf := make([]byte, bs+2, bs+2+len(plaintext)+len(mdcHeader)+sha1.Size)
+	if _, err := io.ReadFull(rand.Reader, buf[:bs]); err != nil {
+		return nil, err
+	}
+	buf[bs], buf[bs+1] = buf[bs-2], buf[bs-1]
+	buf = append(append(buf, plaintext...), mdcHeader...)
+	mdc := sha1.Sum(buf)
+	buf = append(buf, mdc[:]...)
+	out := make([]byte, 1+len(buf))
+	out[0] = 1
+	cipher.NewCFBEncrypter(block, make([]byte, bs)).XORKeyStream(out[1:], buf)
+	return out, nil
+}
+
+// openSEIPD decrypts a version 1 SEIPD body and checks its MDC, returning
+// the plaintext packets.
+func openSEIPD(algo byte, key, body []byte) ([]byte, error) {
+	block, err := newBlock(algo, key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	if len(body) < 1+bs+2+len(mdcHeader)+sha1.Size || body[0] != 1 {
+		return nil, ErrMalformed
+	}
+	buf := make([]byte, len(body)-1)
+	cipher.NewCFBDecrypter(block, make([]byte, bs)).XORKeyStream(buf, body[1:])
+	// The quick check of the prefix is not used to reject early, which
+	// would give an oracle; the MDC decides.
+	n := len(buf) - sha1.Size
+	mdc := sha1.Sum(buf[:n])
+	if subtle.ConstantTimeCompare(mdc[:], buf[n:]) != 1 ||
+		!bytes.Equal(buf[n-len(mdcHeader):n], mdcHeader) {
+		return nil, ErrDecryption
+	}
+	return buf[bs+2 : n-len(mdcHeader)], nil
+}
+
+// Decrypt reads an encrypted message and decrypts it with the first of keys
+// that a session key is encrypted to. Messages without integrity protection
+// are rejected.
+func Decrypt(r io.Reader, keys ...*PrivateKey) (*Message, error) {
+	var algo byte
+	var sessionKey []byte
+	for {
+		p, err := readPacket(r)
+		if err == io.EOF {
+			return nil, ErrMalformed
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch p.tag {
+		case tagPKESK:
+			if sessionKey != nil {
+				continue
+			}
+			for _, k := range keys {
+				a, key, err := decryptSessionKey(p.body, k)
+				if err == ErrKeyIncorrect {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				algo, sessionKey = a, key
+				break
+			}
+		case tagMarker:
+		case tagSEIPD:
+			if sessionKey == nil {
+				return nil, ErrKeyIncorrect
+			}
+			defer zeroize(sessionKey)
+			plaintext, err := openSEIPD(algo, sessionKey, p.body)
+			if err != nil {
+				return nil, err
+			}
+			return readMessage(plaintext, 0)
+		case tagSED:
+			return nil, ErrUnsupported
+		default:
+			return nil, ErrMalformed
+		}
+	}
+}
+
+// readMessage parses the packets of a decrypted message: an optional
+// one-pass signature, a literal data packet and its signature, possibly
+// inside a compressed packet.
+func readMessage(data []byte, depth int) (*Message, error) {
+	m := &Message{}
+	var onePass, literal bool
+	r := bytes.NewReader(data)
+	for {
+		p, err := readPacket(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch p.tag {
+		case tagCompressed:
+			if depth > 0 || onePass || literal || r.Len() != 0 {
+				return nil, ErrMalformed
+			}
+			inner, err := decompress(p.body)
+			if err != nil {
+				return nil, err
+			}
+			return readMessage(inner, depth+1)
+		case tagOnePassSignature:
+			if onePass || literal {
+				return nil, ErrMalformed
+			}
+			onePass = true
+		case tagLiteral:
+			if literal || len(p.body) < 6 || len(p.body) < 6+int(p.body[1]) {
+				return nil, ErrMalformed
+			}
+			literal = true
+			n := int(p.body[1])
+			m.FileName = string(p.body[2 : 2+n])
+			m.ModTime = time.Unix(int64(binary.BigEndian.Uint32(p.body[2+n:])), 0)
+			m.Data = p.body[6+n:]
+		case tagSignature:
+			if !literal || m.Signature != nil {
+				return nil, ErrMalformed
+			}
+			if m.Signature, err = parseSignature(p.body); err != nil {
+				return nil, err
+			}
+		case tagMarker:
+		default:
+			return nil, ErrMalformed
+		}
+	}
+	if !literal || onePass != (m.Signature != nil) {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
+
+func decompress(body []byte) ([]byte, error) {
+	if len(body) < 1 {
+		return nil, ErrMalformed
+	}
+	var r io.Reader
+	switch src := bytes.NewReader(body[1:]); body[0] {
+	case 0:
+		r = src
+	case 1:
+		r = flate.NewReader(src)
+	case 2:
+		zr, err := zlib.NewReader(src)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		r = zr
+	case 3:
+		r = bzip2.NewReader(src)
+	default:
+		return nil, ErrUnsupported
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return out, nil
+}
+
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
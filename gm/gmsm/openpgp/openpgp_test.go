@@ -0,0 +1,235 @@
+package openpgp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func newKey(t *testing.T) *PrivateKey {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewPrivateKey(priv, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alice, bob, eve := newKey(t), newKey(t), newKey(t)
+	data := bytes.Repeat([]byte("signed and sealed "), 1000)
+	for _, signer := range []*PrivateKey{nil, alice} {
+		var buf bytes.Buffer
+		if err := Encrypt(&buf, data, []*PublicKey{&alice.PublicKey, &bob.PublicKey}, signer); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []*PrivateKey{alice, bob} {
+			m, err := Decrypt(bytes.NewReader(buf.Bytes()), eve, k)
+			if err != nil || !bytes.Equal(m.Data, data) {
+				t.Fatalf("signed %v: %v", signer != nil, err)
+			}
+			err = m.Verify(&alice.PublicKey)
+			if signer == nil && err != ErrSignature || signer != nil && err != nil {
+				t.Fatalf("signed %v: Verify returned %v", signer != nil, err)
+			}
+			if signer != nil {
+				if m.Signature.IssuerKeyID != alice.KeyID() {
+					t.Fatal("wrong issuer")
+				}
+				if m.Verify(&bob.PublicKey) != ErrSignature {
+					t.Fatal("verified with the wrong key")
+				}
+			}
+		}
+		if _, err := Decrypt(bytes.NewReader(buf.Bytes()), eve); err != ErrKeyIncorrect {
+			t.Fatalf("got %v for a stranger", err)
+		}
+	}
+	if err := Encrypt(ioutil.Discard, data, nil, alice); err == nil {
+		t.Fatal("encrypted to no recipients")
+	}
+}
+
+func TestTamper(t *testing.T) {
+	alice := newKey(t)
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, []byte("release manifest"), []*PublicKey{&alice.PublicKey}, alice); err != nil {
+		t.Fatal(err)
+	}
+	msg := buf.Bytes()
+	for _, i := range []int{len(msg) - 1, len(msg) - 30, len(msg) - 60} {
+		bad := append([]byte(nil), msg...)
+		bad[i] ^= 1
+		if m, err := Decrypt(bytes.NewReader(bad), alice); err == nil {
+			t.Fatalf("byte %d: decrypted to %q", i, m.Data)
+		}
+	}
+	if _, err := Decrypt(bytes.NewReader(msg[:len(msg)-1]), alice); err == nil {
+		t.Fatal("truncated message decrypted")
+	}
+}
+
+func TestSignDetached(t *testing.T) {
+	k := newKey(t)
+	msg := []byte("artifact contents")
+	var buf bytes.Buffer
+	if err := k.SignDetached(&buf, bytes.NewReader(msg)); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ReadSignature(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.IssuerKeyID != k.KeyID() || sig.SigType != SigTypeBinary {
+		t.Fatalf("unexpected signature %+v", sig)
+	}
+	if err := sig.Verify(&k.PublicKey, bytes.NewReader(msg)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.Verify(&k.PublicKey, bytes.NewReader(msg[1:])); err != ErrSignature {
+		t.Fatalf("got %v for another message", err)
+	}
+	if err := sig.Verify(&newKey(t).PublicKey, bytes.NewReader(msg)); err != ErrSignature {
+		t.Fatalf("got %v for another key", err)
+	}
+}
+
+func TestSerializePublic(t *testing.T) {
+	k := newKey(t)
+	const uid = "Alice <alice@example.com>"
+	var buf bytes.Buffer
+	if err := k.SerializePublic(&buf, uid, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ReadPublicKeys(bytes.NewReader(buf.Bytes()))
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("got %d keys, %v", len(keys), err)
+	}
+	if keys[0].Fingerprint() != k.Fingerprint() || keys[0].IsSubkey {
+		t.Fatal("key changed in transit")
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	for _, tag := range []byte{tagPublicKey, tagUserID} {
+		if p, err := readPacket(r); err != nil || p.tag != tag {
+			t.Fatalf("expected packet %d, got %v", tag, err)
+		}
+	}
+	sig, err := ReadSignature(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.VerifyUserID(keys[0], uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.VerifyUserID(keys[0], "Mallory"); err != ErrSignature {
+		t.Fatalf("got %v for another user ID", err)
+	}
+	if err := sig.Verify(keys[0], bytes.NewReader(nil)); err != ErrUnsupported {
+		t.Fatalf("certification verified as a binary signature: %v", err)
+	}
+}
+
+func TestReadPacketFormats(t *testing.T) {
+	body := bytes.Repeat([]byte{0xaa}, 1000)
+	for name, encoded := range map[string][]byte{
+		"new":               appendPacket(nil, tagLiteral, body),
+		"old two":           append([]byte{0x80 | tagLiteral<<2 | 1, 0x03, 0xe8}, body...),
+		"old indeterminate": append([]byte{0x80 | tagLiteral<<2 | 3}, body...),
+		// 512 + 256 + 232 bytes as partial, partial and final lengths.
+		"partial": append(append(append(append(append([]byte{0xc0 | tagLiteral, 0xe9},
+			body[:512]...), 0xe8), body[512:768]...), 0xc0, 40), body[768:]...),
+	} {
+		p, err := readPacket(bytes.NewReader(encoded))
+		if err != nil || p.tag != tagLiteral || !bytes.Equal(p.body, body) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, bad := range [][]byte{{0x40}, {0xc0 | tagLiteral}, {0xc0 | tagLiteral, 5, 0}, {0xc0 | tagLiteral, 0xe9, 0}} {
+		if _, err := readPacket(bytes.NewReader(bad)); err != ErrMalformed {
+			t.Errorf("%x: got %v", bad, err)
+		}
+	}
+}
+
+func TestReadCompressedMessage(t *testing.T) {
+	literal := appendPacket(nil, tagLiteral, append([]byte{'b', 5, 'a', '.', 't', 'x', 't', 0, 0, 0, 1}, "hello"...))
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(literal)
+	zw.Close()
+	m, err := readMessage(appendPacket(nil, tagCompressed, append([]byte{2}, z.Bytes()...)), 0)
+	if err != nil || string(m.Data) != "hello" || m.FileName != "a.txt" || m.ModTime.Unix() != 1 {
+		t.Fatalf("got %+v, %v", m, err)
+	}
+	if _, err := readMessage(append(literal, literal...), 0); err != ErrMalformed {
+		t.Fatalf("two literal packets: got %v", err)
+	}
+}
+
+// TestGnuPG checks the packets against gpg, which parses SM2 packets but has
+// no SM2, SM3 or SM4: it decrypts an AES message given the session key and
+// lists the key and signature packets.
+func TestGnuPG(t *testing.T) {
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		t.Skip("gpg not found")
+	}
+	dir, err := ioutil.TempDir("", "openpgp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	run := func(args ...string) []byte {
+		cmd := exec.Command(gpg, append([]string{"--homedir", dir, "--batch"}, args...)...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("gpg %v: %v", args, err)
+		}
+		return out
+	}
+
+	k := newKey(t)
+	var key bytes.Buffer
+	if err := k.SerializePublic(&key, "Alice <alice@example.com>", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "key")
+	ioutil.WriteFile(path, key.Bytes(), 0600)
+	fingerprint := k.Fingerprint()
+	fp := strings.ToUpper(hex.EncodeToString(fingerprint[:]))
+	if out := run("--list-packets", path); !bytes.Contains(out, []byte("issuer fpr v4 "+fp)) ||
+		!bytes.Contains(out, []byte("version 4, algo 99, created 1700000000")) {
+		t.Fatalf("unexpected packets\n%s", out)
+	}
+
+	// Build the message as encrypt does, with a session key known here.
+	sessionKey := bytes.Repeat([]byte{0x42}, 32)
+	pkesk, err := encryptSessionKey(&k.PublicKey, cipherAES256, sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("gpg interop "), 1000)
+	literal := appendPacket(nil, tagLiteral, append([]byte{'b', 0, 0, 0, 0, 0}, data...))
+	seipd, err := sealSEIPD(cipherAES256, sessionKey, literal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(dir, "msg")
+	ioutil.WriteFile(path, appendPacket(appendPacket(nil, tagPKESK, pkesk), tagSEIPD, seipd), 0600)
+	if out := run("--override-session-key", "9:"+hex.EncodeToString(sessionKey), "--decrypt", path); !bytes.Equal(out, data) {
+		t.Fatalf("gpg decrypted %d bytes", len(out))
+	}
+}
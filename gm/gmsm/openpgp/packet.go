@@ -0,0 +1,160 @@
+package openpgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Packet tags of RFC 4880, section 4.3.
+const (
+	tagPKESK            = 1
+	tagSignature        = 2
+	tagOnePassSignature = 4
+	tagPublicKey        = 6
+	tagCompressed       = 8
+	tagSED              = 9
+	tagMarker           = 10
+	tagLiteral          = 11
+	tagUserID           = 13
+	tagPublicSubkey     = 14
+	tagSEIPD            = 18
+	tagMDC              = 19
+)
+
+type packet struct {
+	tag  byte
+	body []byte
+}
+
+// appendPacket appends a new-format packet with a definite length.
+func appendPacket(b []byte, tag byte, body []byte) []byte {
+	b = append(b, 0xc0|tag)
+	n := len(body)
+	switch {
+	case n < 192:
+		b = append(b, byte(n))
+	case n < 8384:
+		n -= 192
+		b = append(b, byte(n>>8)+192, byte(n))
+	default:
+		b = append(b, 0xff, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, body...)
+}
+
+func writePacket(w io.Writer, tag byte, body []byte) error {
+	_, err := w.Write(appendPacket(nil, tag, body))
+	return err
+}
+
+func readByte(r io.Reader) (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+// readPacket reads a packet in either format, joining partial body lengths.
+// It returns io.EOF only at the start of a packet.
+func readPacket(r io.Reader) (*packet, error) {
+	h, err := readByte(r)
+	if err != nil {
+		return nil, err
+	}
+	if h&0x80 == 0 {
+		return nil, ErrMalformed
+	}
+	var body bytes.Buffer
+	read := func(n int64) error {
+		if m, err := io.CopyN(&body, r, n); m != n {
+			if err == nil || err == io.EOF {
+				err = ErrMalformed
+			}
+			return err
+		}
+		return nil
+	}
+	if h&0x40 == 0 {
+		// Old format: the tag in bits 5-2, the length type in bits 1-0.
+		p := &packet{tag: (h >> 2) & 0x0f}
+		size := [...]int{1, 2, 4, 0}[h&3]
+		if size == 0 {
+			if _, err := io.Copy(&body, r); err != nil {
+				return nil, err
+			}
+			p.body = body.Bytes()
+			return p, nil
+		}
+		var l [4]byte
+		if _, err := io.ReadFull(r, l[4-size:]); err != nil {
+			return nil, ErrMalformed
+		}
+		if err := read(int64(binary.BigEndian.Uint32(l[:]))); err != nil {
+			return nil, err
+		}
+		p.body = body.Bytes()
+		return p, nil
+	}
+	p := &packet{tag: h & 0x3f}
+	for {
+		b0, err := readByte(r)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		switch {
+		case b0 < 192:
+			err = read(int64(b0))
+		case b0 < 224:
+			b1, e := readByte(r)
+			if e != nil {
+				return nil, ErrMalformed
+			}
+			err = read(int64(b0-192)<<8 + int64(b1) + 192)
+		case b0 == 255:
+			var l [4]byte
+			if _, e := io.ReadFull(r, l[:]); e != nil {
+				return nil, ErrMalformed
+			}
+			err = read(int64(binary.BigEndian.Uint32(l[:])))
+		default:
+			// A partial body length, followed by more of the body.
+			if err := read(1 << (b0 & 0x1f)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.body = body.Bytes()
+		return p, nil
+	}
+}
+
+// appendMPI appends x, big-endian, as a multiprecision integer.
+func appendMPI(b, x []byte) []byte {
+	for len(x) > 0 && x[0] == 0 {
+		x = x[1:]
+	}
+	bits := len(x) * 8
+	if len(x) > 0 {
+		for top := x[0]; top&0x80 == 0; top <<= 1 {
+			bits--
+		}
+	}
+	b = append(b, byte(bits>>8), byte(bits))
+	return append(b, x...)
+}
+
+// readMPI returns the value of the multiprecision integer at the start of
+// b and what follows it.
+func readMPI(b []byte) (x, rest []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, ErrMalformed
+	}
+	n := (int(b[0])<<8 | int(b[1]) + 7) / 8
+	if len(b)-2 < n {
+		return nil, nil, ErrMalformed
+	}
+	return b[2 : 2+n], b[2+n:], nil
+}
@@ -0,0 +1,279 @@
+package openpgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Signature types of RFC 4880, section 5.2.1.
+const (
+	SigTypeBinary       = 0x00
+	SigTypePositiveCert = 0x13
+)
+
+// Signature subpacket types of RFC 4880, section 5.2.3.1, and of RFC 4880bis
+// for the issuer fingerprint.
+const (
+	subpacketCreationTime         = 2
+	subpacketPreferredCipher      = 11
+	subpacketIssuer               = 16
+	subpacketPreferredHash        = 21
+	subpacketPreferredCompression = 22
+	subpacketKeyFlags             = 27
+	subpacketIssuerFingerprint    = 33
+
+	// keyFlagsAll marks a key for certification, signing and encryption of
+	// communications and storage.
+	keyFlagsAll = 0x0f
+)
+
+// Signature is a version 4 SM2 signature packet.
+type Signature struct {
+	SigType           byte
+	CreationTime      time.Time
+	IssuerKeyID       uint64
+	IssuerFingerprint []byte
+	R, S              *big.Int
+
+	hashed   []byte // hashed subpacket area
+	unhashed []byte // unhashed subpacket area
+	hashTag  [2]byte
+}
+
+func appendSubpacket(b []byte, typ byte, data []byte) []byte {
+	// Subpackets written here are short enough for a one-byte length.
+	b = append(b, byte(len(data)+1), typ)
+	return append(b, data...)
+}
+
+// newHash returns SM3 primed with ZA of pub under sm2.DefaultUID, which RNP
+// hashes ahead of the data of every SM2 signature.
+func newHash(pub *sm2.PublicKey) (hash.Hash, error) {
+	za, err := sm2.ZA(pub, sm2.DefaultUID)
+	if err != nil {
+		return nil, err
+	}
+	h := sm3.New()
+	h.Write(za)
+	return h, nil
+}
+
+// trailer returns the bytes hashed after the data: the signature fields up
+// to the hashed subpackets and the final trailer of RFC 4880, section 5.2.4.
+func (sig *Signature) trailer() []byte {
+	b := []byte{4, sig.SigType, PubKeyAlgoSM2, HashSM3, byte(len(sig.hashed) >> 8), byte(len(sig.hashed))}
+	b = append(b, sig.hashed...)
+	n := len(b)
+	return append(b, 4, 0xff, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// digest hashes data and the trailer of sig for pub.
+func (sig *Signature) digest(pub *sm2.PublicKey, data io.Reader) ([]byte, error) {
+	h, err := newHash(pub)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, data); err != nil {
+		return nil, err
+	}
+	h.Write(sig.trailer())
+	return h.Sum(nil), nil
+}
+
+// sign returns a signature of the given type over data, with the creation
+// time and issuer fingerprint added to the hashed subpackets.
+func (k *PrivateKey) sign(sigType byte, subpackets []byte, data io.Reader, now time.Time) (*Signature, error) {
+	fp := k.Fingerprint()
+	var created [4]byte
+	binary.BigEndian.PutUint32(created[:], uint32(now.Unix()))
+	var issuer [8]byte
+	binary.BigEndian.PutUint64(issuer[:], k.KeyID())
+
+	sig := &Signature{
+		SigType:           sigType,
+		CreationTime:      now.Truncate(time.Second),
+		IssuerKeyID:       k.KeyID(),
+		IssuerFingerprint: append([]byte{4}, fp[:]...),
+	}
+	sig.hashed = appendSubpacket(nil, subpacketCreationTime, created[:])
+	sig.hashed = appendSubpacket(sig.hashed, subpacketIssuerFingerprint, sig.IssuerFingerprint)
+	sig.hashed = append(sig.hashed, subpackets...)
+	sig.unhashed = appendSubpacket(nil, subpacketIssuer, issuer[:])
+
+	digest, err := sig.digest(k.Key, data)
+	if err != nil {
+		return nil, err
+	}
+	der, err := k.PrivateKey.Sign(nil, digest, sm3.CryptoHash)
+	if err != nil {
+		return nil, err
+	}
+	if sig.R, sig.S, err = sm2.SignDataToSignDigit(der); err != nil {
+		return nil, err
+	}
+	copy(sig.hashTag[:], digest)
+	return sig, nil
+}
+
+// SignDetached writes a binary signature packet of message made with k.
+func (k *PrivateKey) SignDetached(w io.Writer, message io.Reader) error {
+	sig, err := k.sign(SigTypeBinary, nil, message, time.Now())
+	if err != nil {
+		return err
+	}
+	return sig.Serialize(w)
+}
+
+func (sig *Signature) body() []byte {
+	b := []byte{4, sig.SigType, PubKeyAlgoSM2, HashSM3, byte(len(sig.hashed) >> 8), byte(len(sig.hashed))}
+	b = append(b, sig.hashed...)
+	b = append(b, byte(len(sig.unhashed)>>8), byte(len(sig.unhashed)))
+	b = append(b, sig.unhashed...)
+	b = append(b, sig.hashTag[:]...)
+	b = appendMPI(b, sig.R.Bytes())
+	return appendMPI(b, sig.S.Bytes())
+}
+
+// Serialize writes the signature packet.
+func (sig *Signature) Serialize(w io.Writer) error {
+	return writePacket(w, tagSignature, sig.body())
+}
+
+// ReadSignature reads a signature packet.
+func ReadSignature(r io.Reader) (*Signature, error) {
+	p, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.tag != tagSignature {
+		return nil, ErrMalformed
+	}
+	return parseSignature(p.body)
+}
+
+func parseSignature(body []byte) (*Signature, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return nil, ErrMalformed
+	}
+	if body[2] != PubKeyAlgoSM2 || body[3] != HashSM3 {
+		return nil, ErrUnsupported
+	}
+	sig := &Signature{SigType: body[1]}
+	n := int(binary.BigEndian.Uint16(body[4:6]))
+	body = body[6:]
+	if len(body) < n+2 {
+		return nil, ErrMalformed
+	}
+	sig.hashed, body = body[:n], body[n:]
+	n = int(binary.BigEndian.Uint16(body))
+	body = body[2:]
+	if len(body) < n+2 {
+		return nil, ErrMalformed
+	}
+	sig.unhashed, body = body[:n], body[n:]
+	copy(sig.hashTag[:], body)
+	r, rest, err := readMPI(body[2:])
+	if err != nil {
+		return nil, err
+	}
+	s, rest, err := readMPI(rest)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	sig.R, sig.S = new(big.Int).SetBytes(r), new(big.Int).SetBytes(s)
+	if err := sig.parseSubpackets(sig.hashed, true); err != nil {
+		return nil, err
+	}
+	if err := sig.parseSubpackets(sig.unhashed, false); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// parseSubpackets reads the subpackets of an area. A hashed subpacket
+// marked critical that is not understood here makes the signature invalid,
+// as RFC 4880 requires; the issuer is only trusted as a hint.
+func (sig *Signature) parseSubpackets(area []byte, hashed bool) error {
+	for len(area) > 0 {
+		var n int
+		switch b0 := int(area[0]); {
+		case b0 < 192:
+			n, area = b0, area[1:]
+		case b0 < 255:
+			if len(area) < 2 {
+				return ErrMalformed
+			}
+			n, area = (b0-192)<<8+int(area[1])+192, area[2:]
+		default:
+			if len(area) < 5 {
+				return ErrMalformed
+			}
+			n, area = int(binary.BigEndian.Uint32(area[1:5])), area[5:]
+		}
+		if n < 1 || n > len(area) {
+			return ErrMalformed
+		}
+		typ, data := area[0], area[1:n]
+		area = area[n:]
+		critical := typ&0x80 != 0
+		switch typ &^ 0x80 {
+		case subpacketCreationTime:
+			if len(data) != 4 {
+				return ErrMalformed
+			}
+			if hashed {
+				sig.CreationTime = time.Unix(int64(binary.BigEndian.Uint32(data)), 0)
+			}
+		case subpacketIssuer:
+			if len(data) != 8 {
+				return ErrMalformed
+			}
+			sig.IssuerKeyID = binary.BigEndian.Uint64(data)
+		case subpacketIssuerFingerprint:
+			sig.IssuerFingerprint = append([]byte(nil), data...)
+		case subpacketKeyFlags, subpacketPreferredCipher, subpacketPreferredHash, subpacketPreferredCompression:
+		default:
+			if critical && hashed {
+				return ErrUnsupported
+			}
+		}
+	}
+	return nil
+}
+
+// Verify checks that sig is a binary signature of message by pk.
+func (sig *Signature) Verify(pk *PublicKey, message io.Reader) error {
+	if sig.SigType != SigTypeBinary {
+		return ErrUnsupported
+	}
+	return sig.verify(pk, message)
+}
+
+func (sig *Signature) verify(pk *PublicKey, data io.Reader) error {
+	digest, err := sig.digest(pk.Key, data)
+	if err != nil {
+		return err
+	}
+	// The hash tag only speeds up rejection; SM2 verification decides.
+	if digest[0] != sig.hashTag[0] || digest[1] != sig.hashTag[1] ||
+		!sm2.Verify(pk.Key, digest, sig.R, sig.S) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// VerifyUserID checks that sig is a certification of userID and pk made by
+// pk itself, such as SerializePublic writes.
+func (sig *Signature) VerifyUserID(pk *PublicKey, userID string) error {
+	if sig.SigType < 0x10 || sig.SigType > SigTypePositiveCert {
+		return ErrUnsupported
+	}
+	return sig.verify(pk, bytes.NewReader(pk.userIDPrefix(userID)))
+}
@@ -0,0 +1,127 @@
+package p11
+
+// The constants of PKCS#11 v2.40 that the package uses.
+const (
+	CKF_RW_SESSION     = 0x00000002
+	CKF_SERIAL_SESSION = 0x00000004
+
+	CKU_USER = 1
+
+	CKO_PUBLIC_KEY  = 2
+	CKO_PRIVATE_KEY = 3
+	CKO_SECRET_KEY  = 4
+
+	CKK_GENERIC_SECRET = 0x10
+
+	CKA_CLASS       = 0x000
+	CKA_TOKEN       = 0x001
+	CKA_VALUE       = 0x011
+	CKA_LABEL       = 0x003
+	CKA_KEY_TYPE    = 0x100
+	CKA_ID          = 0x102
+	CKA_SENSITIVE   = 0x103
+	CKA_EXTRACTABLE = 0x162
+	CKA_VALUE_LEN   = 0x161
+	CKA_EC_POINT    = 0x181
+
+	CKD_NULL = 1
+)
+
+// SessionHandle and ObjectHandle are the CK_SESSION_HANDLE and
+// CK_OBJECT_HANDLE of the module.
+type (
+	SessionHandle uint
+	ObjectHandle  uint
+)
+
+// Attribute is a CK_ATTRIBUTE. Integer values are encoded by NewAttribute.
+type Attribute struct {
+	Type  uint
+	Value []byte
+}
+
+// Mechanism is a CK_MECHANISM. Parameter is nil, a []byte, *GCMParams or
+// *ECDHParams; the binding converts the latter two to the C structures.
+type Mechanism struct {
+	Mechanism uint
+	Parameter interface{}
+}
+
+// GCMParams are the CK_GCM_PARAMS of an AEAD mechanism.
+type GCMParams struct {
+	IV      []byte
+	AAD     []byte
+	TagBits int
+}
+
+// ECDHParams are the CK_ECDH1_DERIVE_PARAMS of a key agreement mechanism.
+type ECDHParams struct {
+	KDF        uint
+	SharedData []byte
+	// PublicData is the uncompressed public key of the peer.
+	PublicData []byte
+}
+
+// TokenInfo is the part of CK_TOKEN_INFO used to select a token.
+type TokenInfo struct {
+	Label          string
+	ManufacturerID string
+	Model          string
+	SerialNumber   string
+}
+
+// Module is the part of a PKCS#11 library that the package uses. The
+// methods mirror the C_ functions of the same names, except that
+// FindObjects returns the handles of at most max objects, and are called
+// after C_Initialize. A Module is normally a thin adapter over a cgo
+// binding such as github.com/miekg/pkcs11, which this repository does not
+// vendor.
+type Module interface {
+	GetSlotList(tokenPresent bool) ([]uint, error)
+	GetTokenInfo(slot uint) (TokenInfo, error)
+	OpenSession(slot uint, flags uint) (SessionHandle, error)
+	CloseSession(sh SessionHandle) error
+	Login(sh SessionHandle, userType uint, pin string) error
+	Logout(sh SessionHandle) error
+
+	FindObjectsInit(sh SessionHandle, template []*Attribute) error
+	FindObjects(sh SessionHandle, max int) ([]ObjectHandle, error)
+	FindObjectsFinal(sh SessionHandle) error
+	GetAttributeValue(sh SessionHandle, o ObjectHandle, template []*Attribute) ([]*Attribute, error)
+	DestroyObject(sh SessionHandle, o ObjectHandle) error
+
+	SignInit(sh SessionHandle, m []*Mechanism, key ObjectHandle) error
+	Sign(sh SessionHandle, data []byte) ([]byte, error)
+	EncryptInit(sh SessionHandle, m []*Mechanism, key ObjectHandle) error
+	Encrypt(sh SessionHandle, data []byte) ([]byte, error)
+	DecryptInit(sh SessionHandle, m []*Mechanism, key ObjectHandle) error
+	Decrypt(sh SessionHandle, data []byte) ([]byte, error)
+	DeriveKey(sh SessionHandle, m []*Mechanism, base ObjectHandle, template []*Attribute) (ObjectHandle, error)
+}
+
+// NewAttribute returns an attribute with a value of type []byte, string,
+// bool or uint, the latter encoded as a little-endian CK_ULONG of 8 bytes;
+// bindings for other platforms re-encode it.
+func NewAttribute(typ uint, value interface{}) *Attribute {
+	a := &Attribute{Type: typ}
+	switch v := value.(type) {
+	case []byte:
+		a.Value = v
+	case string:
+		a.Value = []byte(v)
+	case bool:
+		if v {
+			a.Value = []byte{1}
+		} else {
+			a.Value = []byte{0}
+		}
+	case uint:
+		a.Value = make([]byte, 8)
+		for i := range a.Value {
+			a.Value[i] = byte(uint64(v) >> (8 * uint(i)))
+		}
+	default:
+		panic("p11: unsupported attribute value type")
+	}
+	return a
+}
@@ -0,0 +1,403 @@
+// Package p11 uses SM2 and SM4 keys held by an HSM through PKCS#11, so
+// that production keys never leave the HSM.
+//
+// Open finds a token by label, opens a session and logs in. Session.SM2Key
+// returns an HSM-resident SM2 key as an sm2.OpaqueSigner, which signs,
+// decrypts and computes key agreement secrets on the token, and
+// Session.SM4AEAD returns an SM4-GCM cipher.AEAD whose key stays on it.
+//
+// PKCS#11 does not assign mechanisms to SM2 and SM4; every vendor defines
+// its own, so they are part of the Config. The package talks to the
+// library through the Module interface, which a small adapter implements
+// over the cgo binding of the deployment.
+package p11
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+var (
+	ErrTokenNotFound = errors.New("p11: token not found")
+	ErrKeyNotFound   = errors.New("p11: key not found")
+	ErrAmbiguousKey  = errors.New("p11: several keys match the label")
+	ErrPublicKey     = errors.New("p11: invalid public key on the token")
+	ErrDigestSize    = errors.New("p11: SM2 signing takes an SM3 digest")
+	ErrSignature     = errors.New("p11: invalid signature from the token")
+	ErrPeerKey       = errors.New("p11: invalid peer public key")
+	ErrOpen          = errors.New("p11: message authentication failed")
+)
+
+// Mechanisms are the vendor-defined PKCS#11 values for SM2 and SM4.
+type Mechanisms struct {
+	// KeyTypeSM2 and KeyTypeSM4 are the CKA_KEY_TYPE of the keys.
+	KeyTypeSM2 uint
+	KeyTypeSM4 uint
+	// SM2Sign signs a 32-byte digest and returns r || s.
+	SM2Sign uint
+	// SM2Decrypt decrypts the ciphertext format of sm2.Encrypt.
+	SM2Decrypt uint
+	// SM2Derive derives the x-coordinate of d·P with ECDHParams.
+	SM2Derive uint
+	// SM4GCM is SM4 in GCM mode with GCMParams.
+	SM4GCM uint
+}
+
+// Config selects the token and its mechanisms.
+type Config struct {
+	// TokenLabel is the label of the token; it may be empty if the module
+	// has a single token.
+	TokenLabel string
+	// PIN is the user PIN; if empty, the session is not logged in, e.g.
+	// because the token uses a protected authentication path.
+	PIN        string
+	Mechanisms Mechanisms
+}
+
+// Token is a token present in a slot.
+type Token struct {
+	Slot uint
+	TokenInfo
+}
+
+// Tokens lists the tokens present in the slots of the module.
+func Tokens(m Module) ([]Token, error) {
+	slots, err := m.GetSlotList(true)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]Token, 0, len(slots))
+	for _, slot := range slots {
+		info, err := m.GetTokenInfo(slot)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, Token{slot, info})
+	}
+	return tokens, nil
+}
+
+// Session is a logged-in session with a token. PKCS#11 sessions run one
+// operation at a time, so the keys of a Session serialize their operations;
+// open several sessions for parallelism.
+type Session struct {
+	mu    sync.Mutex
+	m     Module
+	h     SessionHandle
+	mechs Mechanisms
+	login bool
+}
+
+// Open opens a read-write session with the token of the configuration and
+// logs in.
+func Open(m Module, cfg Config) (*Session, error) {
+	tokens, err := Tokens(m)
+	if err != nil {
+		return nil, err
+	}
+	var token *Token
+	for i := range tokens {
+		if tokens[i].Label == cfg.TokenLabel || (cfg.TokenLabel == "" && len(tokens) == 1) {
+			token = &tokens[i]
+			break
+		}
+	}
+	if token == nil {
+		return nil, ErrTokenNotFound
+	}
+
+	h, err := m.OpenSession(token.Slot, CKF_SERIAL_SESSION|CKF_RW_SESSION)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{m: m, h: h, mechs: cfg.Mechanisms}
+	if cfg.PIN != "" {
+		if err := m.Login(h, CKU_USER, cfg.PIN); err != nil {
+			m.CloseSession(h)
+			return nil, err
+		}
+		s.login = true
+	}
+	return s, nil
+}
+
+// Close logs out and closes the session.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.login {
+		s.m.Logout(s.h)
+		s.login = false
+	}
+	return s.m.CloseSession(s.h)
+}
+
+// find returns the single object matching the template.
+func (s *Session) find(template []*Attribute) (ObjectHandle, error) {
+	if err := s.m.FindObjectsInit(s.h, template); err != nil {
+		return 0, err
+	}
+	objs, err := s.m.FindObjects(s.h, 2)
+	s.m.FindObjectsFinal(s.h)
+	if err != nil {
+		return 0, err
+	}
+	switch len(objs) {
+	case 0:
+		return 0, ErrKeyNotFound
+	case 1:
+		return objs[0], nil
+	default:
+		return 0, ErrAmbiguousKey
+	}
+}
+
+// SM2Key returns the SM2 key pair with the label. The public key is read
+// from the CKA_EC_POINT of the public key object.
+func (s *Session) SM2Key(label string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	priv, err := s.find([]*Attribute{
+		NewAttribute(CKA_CLASS, uint(CKO_PRIVATE_KEY)),
+		NewAttribute(CKA_KEY_TYPE, s.mechs.KeyTypeSM2),
+		NewAttribute(CKA_LABEL, label),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pubObj, err := s.find([]*Attribute{
+		NewAttribute(CKA_CLASS, uint(CKO_PUBLIC_KEY)),
+		NewAttribute(CKA_KEY_TYPE, s.mechs.KeyTypeSM2),
+		NewAttribute(CKA_LABEL, label),
+	})
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := s.m.GetAttributeValue(s.h, pubObj, []*Attribute{{Type: CKA_EC_POINT}})
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) != 1 {
+		return nil, ErrPublicKey
+	}
+	pub, err := parseECPoint(attrs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{s: s, obj: priv, pub: pub}, nil
+}
+
+// parseECPoint parses a CKA_EC_POINT, a DER OCTET STRING holding the
+// uncompressed point, or the bare point as some tokens return it.
+func parseECPoint(b []byte) (*sm2.PublicKey, error) {
+	var point []byte
+	if rest, err := asn1.Unmarshal(b, &point); err != nil || len(rest) != 0 {
+		point = b
+	}
+	if len(point) != 65 || point[0] != 4 {
+		return nil, ErrPublicKey
+	}
+	curve := sm2.P256Sm2()
+	x := new(big.Int).SetBytes(point[1:33])
+	y := new(big.Int).SetBytes(point[33:])
+	if !curve.IsOnCurve(x, y) {
+		return nil, ErrPublicKey
+	}
+	return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Key is an SM2 private key held by a token. It implements
+// sm2.OpaqueSigner.
+type Key struct {
+	s   *Session
+	obj ObjectHandle
+	pub *sm2.PublicKey
+}
+
+var _ sm2.OpaqueSigner = (*Key)(nil)
+
+// Public returns the *sm2.PublicKey of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs the SM3 digest e = SM3(ZA || M) on the token and returns the
+// ASN.1 signature. The token draws the nonce; rand and opts are ignored.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := k.sign(digest)
+	audit.Record(audit.OpSign, audit.BackendPKCS11, k.pub.X, k.pub.Y, err)
+	return sig, err
+}
+
+func (k *Key) sign(digest []byte) ([]byte, error) {
+	if len(digest) != sm3.Size {
+		return nil, ErrDigestSize
+	}
+
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	m := k.s.m
+	if err := m.SignInit(k.s.h, []*Mechanism{{Mechanism: k.s.mechs.SM2Sign}}, k.obj); err != nil {
+		return nil, err
+	}
+	rs, err := m.Sign(k.s.h, digest)
+	if err != nil {
+		return nil, err
+	}
+	var sig sm2.Signature
+	if err := sig.DecodeFormat(sm2.FormatRaw, rs); err != nil {
+		return nil, ErrSignature
+	}
+	return sig.Encode(sm2.FormatDER)
+}
+
+// Decrypt decrypts a ciphertext of sm2.Encrypt on the token.
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	pt, err := k.decrypt(ciphertext)
+	audit.Record(audit.OpDecrypt, audit.BackendPKCS11, k.pub.X, k.pub.Y, err)
+	return pt, err
+}
+
+func (k *Key) decrypt(ciphertext []byte) ([]byte, error) {
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	m := k.s.m
+	if err := m.DecryptInit(k.s.h, []*Mechanism{{Mechanism: k.s.mechs.SM2Decrypt}}, k.obj); err != nil {
+		return nil, err
+	}
+	return m.Decrypt(k.s.h, ciphertext)
+}
+
+// SharedSecret derives the x-coordinate of d·peer on the token as a
+// temporary, extractable secret, reads it and destroys the object.
+func (k *Key) SharedSecret(peer *sm2.PublicKey) ([]byte, error) {
+	if peer == nil || peer.X == nil || peer.Y == nil || !sm2.P256Sm2().IsOnCurve(peer.X, peer.Y) {
+		return nil, ErrPeerKey
+	}
+	point := make([]byte, 65)
+	point[0] = 4
+	xBytes, yBytes := peer.X.Bytes(), peer.Y.Bytes()
+	copy(point[33-len(xBytes):33], xBytes)
+	copy(point[65-len(yBytes):], yBytes)
+
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	m := k.s.m
+	mech := []*Mechanism{{
+		Mechanism: k.s.mechs.SM2Derive,
+		Parameter: &ECDHParams{KDF: CKD_NULL, PublicData: point},
+	}}
+	obj, err := m.DeriveKey(k.s.h, mech, k.obj, []*Attribute{
+		NewAttribute(CKA_CLASS, uint(CKO_SECRET_KEY)),
+		NewAttribute(CKA_KEY_TYPE, uint(CKK_GENERIC_SECRET)),
+		NewAttribute(CKA_TOKEN, false),
+		NewAttribute(CKA_SENSITIVE, false),
+		NewAttribute(CKA_EXTRACTABLE, true),
+		NewAttribute(CKA_VALUE_LEN, uint(32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer m.DestroyObject(k.s.h, obj)
+
+	attrs, err := m.GetAttributeValue(k.s.h, obj, []*Attribute{{Type: CKA_VALUE}})
+	if err != nil {
+		return nil, err
+	}
+	if len(attrs) != 1 || len(attrs[0].Value) != 32 {
+		return nil, errors.New("p11: unexpected derived secret")
+	}
+	return attrs[0].Value, nil
+}
+
+// aead is SM4-GCM with a key held by a token.
+type aead struct {
+	s   *Session
+	obj ObjectHandle
+}
+
+// SM4AEAD returns SM4-GCM, with 12-byte nonces and 16-byte tags, under the
+// secret key with the label. cipher.AEAD cannot return errors from Seal,
+// so Seal panics if the token fails; Open returns ErrOpen.
+func (s *Session) SM4AEAD(label string) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.find([]*Attribute{
+		NewAttribute(CKA_CLASS, uint(CKO_SECRET_KEY)),
+		NewAttribute(CKA_KEY_TYPE, s.mechs.KeyTypeSM4),
+		NewAttribute(CKA_LABEL, label),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &aead{s: s, obj: obj}, nil
+}
+
+func (a *aead) NonceSize() int { return gcmNonceSize }
+
+func (a *aead) Overhead() int { return gcmTagSize }
+
+func (a *aead) mechanism(nonce, additionalData []byte) []*Mechanism {
+	if len(nonce) != gcmNonceSize {
+		panic("p11: incorrect nonce length given to GCM")
+	}
+	return []*Mechanism{{
+		Mechanism: a.s.mechs.SM4GCM,
+		Parameter: &GCMParams{IV: nonce, AAD: additionalData, TagBits: 8 * gcmTagSize},
+	}}
+}
+
+func (a *aead) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	mech := a.mechanism(nonce, additionalData)
+
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	if err := a.s.m.EncryptInit(a.s.h, mech, a.obj); err != nil {
+		panic("p11: " + err.Error())
+	}
+	out, err := a.s.m.Encrypt(a.s.h, plaintext)
+	if err != nil {
+		panic("p11: " + err.Error())
+	}
+	return append(dst, out...)
+}
+
+func (a *aead) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	mech := a.mechanism(nonce, additionalData)
+	if len(ciphertext) < gcmTagSize {
+		return nil, ErrOpen
+	}
+
+	a.s.mu.Lock()
+	defer a.s.mu.Unlock()
+
+	if err := a.s.m.DecryptInit(a.s.h, mech, a.obj); err != nil {
+		return nil, err
+	}
+	out, err := a.s.m.Decrypt(a.s.h, ciphertext)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	return append(dst, out...), nil
+}
@@ -0,0 +1,349 @@
+package p11
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+var testMechs = Mechanisms{
+	KeyTypeSM2: 0x80000001,
+	KeyTypeSM4: 0x80000002,
+	SM2Sign:    0x80000101,
+	SM2Decrypt: 0x80000102,
+	SM2Derive:  0x80000103,
+	SM4GCM:     0x80000104,
+}
+
+// fakeModule is a software token with one SM2 key pair and one SM4 key.
+type fakeModule struct {
+	label    string
+	pin      string
+	loggedIn bool
+	closed   bool
+	sm2Key   *sm2.PrivateKey
+	sm4Key   []byte
+	derived  map[ObjectHandle][]byte
+	found    []ObjectHandle
+	op       *Mechanism
+	opKey    ObjectHandle
+}
+
+const (
+	objPriv ObjectHandle = iota + 1
+	objPub
+	objSM4
+	objDerived
+)
+
+func newFakeModule(t *testing.T) *fakeModule {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, sm4.KeySize)
+	rand.Read(key)
+	return &fakeModule{label: "hsm", pin: "1234", sm2Key: priv, sm4Key: key, derived: map[ObjectHandle][]byte{}}
+}
+
+var errFake = errors.New("fake: CKR_GENERAL_ERROR")
+
+func (f *fakeModule) GetSlotList(bool) ([]uint, error) { return []uint{7}, nil }
+
+func (f *fakeModule) GetTokenInfo(slot uint) (TokenInfo, error) {
+	return TokenInfo{Label: f.label, Model: "fake"}, nil
+}
+
+func (f *fakeModule) OpenSession(slot uint, flags uint) (SessionHandle, error) {
+	if slot != 7 || flags&CKF_SERIAL_SESSION == 0 {
+		return 0, errFake
+	}
+	return 1, nil
+}
+
+func (f *fakeModule) CloseSession(SessionHandle) error { f.closed = true; return nil }
+
+func (f *fakeModule) Login(sh SessionHandle, userType uint, pin string) error {
+	if userType != CKU_USER || pin != f.pin {
+		return errors.New("fake: CKR_PIN_INCORRECT")
+	}
+	f.loggedIn = true
+	return nil
+}
+
+func (f *fakeModule) Logout(SessionHandle) error { f.loggedIn = false; return nil }
+
+func attr(template []*Attribute, typ uint) []byte {
+	for _, a := range template {
+		if a.Type == typ {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+func (f *fakeModule) FindObjectsInit(sh SessionHandle, template []*Attribute) error {
+	f.found = nil
+	if !f.loggedIn || string(attr(template, CKA_LABEL)) != "key" {
+		return nil
+	}
+	class, keyType := attr(template, CKA_CLASS), attr(template, CKA_KEY_TYPE)
+	switch {
+	case bytes.Equal(class, NewAttribute(0, uint(CKO_PRIVATE_KEY)).Value) && bytes.Equal(keyType, NewAttribute(0, testMechs.KeyTypeSM2).Value):
+		f.found = []ObjectHandle{objPriv}
+	case bytes.Equal(class, NewAttribute(0, uint(CKO_PUBLIC_KEY)).Value) && bytes.Equal(keyType, NewAttribute(0, testMechs.KeyTypeSM2).Value):
+		f.found = []ObjectHandle{objPub}
+	case bytes.Equal(class, NewAttribute(0, uint(CKO_SECRET_KEY)).Value) && bytes.Equal(keyType, NewAttribute(0, testMechs.KeyTypeSM4).Value):
+		f.found = []ObjectHandle{objSM4}
+	}
+	return nil
+}
+
+func (f *fakeModule) FindObjects(sh SessionHandle, max int) ([]ObjectHandle, error) {
+	if len(f.found) > max {
+		return f.found[:max], nil
+	}
+	return f.found, nil
+}
+
+func (f *fakeModule) FindObjectsFinal(SessionHandle) error { f.found = nil; return nil }
+
+func (f *fakeModule) GetAttributeValue(sh SessionHandle, o ObjectHandle, template []*Attribute) ([]*Attribute, error) {
+	if len(template) != 1 {
+		return nil, errFake
+	}
+	switch {
+	case o == objPub && template[0].Type == CKA_EC_POINT:
+		pub := f.sm2Key.PublicKey
+		point := append([]byte{4}, append(pad32(pub.X.Bytes()), pad32(pub.Y.Bytes())...)...)
+		der, _ := asn1.Marshal(point)
+		return []*Attribute{{Type: CKA_EC_POINT, Value: der}}, nil
+	case f.derived[o] != nil && template[0].Type == CKA_VALUE:
+		return []*Attribute{{Type: CKA_VALUE, Value: f.derived[o]}}, nil
+	}
+	return nil, errors.New("fake: CKR_ATTRIBUTE_SENSITIVE")
+}
+
+func pad32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func (f *fakeModule) DestroyObject(sh SessionHandle, o ObjectHandle) error {
+	delete(f.derived, o)
+	return nil
+}
+
+func (f *fakeModule) init(m []*Mechanism, key ObjectHandle) error {
+	if len(m) != 1 {
+		return errFake
+	}
+	f.op, f.opKey = m[0], key
+	return nil
+}
+
+func (f *fakeModule) SignInit(sh SessionHandle, m []*Mechanism, key ObjectHandle) error {
+	return f.init(m, key)
+}
+
+func (f *fakeModule) Sign(sh SessionHandle, data []byte) ([]byte, error) {
+	if f.op.Mechanism != testMechs.SM2Sign || f.opKey != objPriv {
+		return nil, errFake
+	}
+	der, err := f.sm2Key.Sign(nil, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := sm2.SignDataToSignDigit(der)
+	if err != nil {
+		return nil, err
+	}
+	return append(pad32(r.Bytes()), pad32(s.Bytes())...), nil
+}
+
+func (f *fakeModule) gcm() (cipher.AEAD, *GCMParams, error) {
+	p, ok := f.op.Parameter.(*GCMParams)
+	if f.op.Mechanism != testMechs.SM4GCM || f.opKey != objSM4 || !ok || p.TagBits != 128 {
+		return nil, nil, errFake
+	}
+	block, err := sm4.NewCipher(f.sm4Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, p, err
+}
+
+func (f *fakeModule) EncryptInit(sh SessionHandle, m []*Mechanism, key ObjectHandle) error {
+	return f.init(m, key)
+}
+
+func (f *fakeModule) Encrypt(sh SessionHandle, data []byte) ([]byte, error) {
+	aead, p, err := f.gcm()
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, p.IV, data, p.AAD), nil
+}
+
+func (f *fakeModule) DecryptInit(sh SessionHandle, m []*Mechanism, key ObjectHandle) error {
+	return f.init(m, key)
+}
+
+func (f *fakeModule) Decrypt(sh SessionHandle, data []byte) ([]byte, error) {
+	if f.op.Mechanism == testMechs.SM2Decrypt && f.opKey == objPriv {
+		return f.sm2Key.Decrypt(data)
+	}
+	aead, p, err := f.gcm()
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, p.IV, data, p.AAD)
+}
+
+func (f *fakeModule) DeriveKey(sh SessionHandle, m []*Mechanism, base ObjectHandle, template []*Attribute) (ObjectHandle, error) {
+	p, ok := m[0].Parameter.(*ECDHParams)
+	if m[0].Mechanism != testMechs.SM2Derive || base != objPriv || !ok || p.KDF != CKD_NULL {
+		return 0, errFake
+	}
+	peer, err := parseECPoint(p.PublicData)
+	if err != nil {
+		return 0, err
+	}
+	secret, err := f.sm2Key.SharedSecret(peer)
+	if err != nil {
+		return 0, err
+	}
+	f.derived[objDerived] = secret
+	return objDerived, nil
+}
+
+func openFake(t *testing.T) (*fakeModule, *Session) {
+	f := newFakeModule(t)
+	s, err := Open(f, Config{TokenLabel: "hsm", PIN: "1234", Mechanisms: testMechs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func TestOpen(t *testing.T) {
+	f := newFakeModule(t)
+	tokens, err := Tokens(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Slot != 7 || tokens[0].Label != "hsm" {
+		t.Fatalf("tokens %+v", tokens)
+	}
+	if _, err := Open(f, Config{TokenLabel: "other", PIN: "1234"}); err != ErrTokenNotFound {
+		t.Fatalf("expected ErrTokenNotFound, got %v", err)
+	}
+	if _, err := Open(f, Config{PIN: "0000"}); err == nil || f.loggedIn {
+		t.Fatal("expected the login to fail")
+	}
+	if !f.closed {
+		t.Fatal("the session of a failed login was not closed")
+	}
+
+	s, err := Open(f, Config{PIN: "1234", Mechanisms: testMechs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SM2Key("missing"); err != ErrKeyNotFound {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+	if err := s.Close(); err != nil || f.loggedIn {
+		t.Fatal("Close did not log out")
+	}
+}
+
+func TestSM2Key(t *testing.T) {
+	f, s := openFake(t)
+	defer s.Close()
+
+	key, err := s.SM2Key("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(*sm2.PublicKey)
+	if pub.X.Cmp(f.sm2Key.X) != 0 || pub.Y.Cmp(f.sm2Key.Y) != 0 {
+		t.Fatal("wrong public key")
+	}
+
+	digest := sm3.Sm3Sum([]byte("message"))
+	sig, err := key.Sign(nil, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Verify(digest, sig) {
+		t.Fatal("the signature of the token does not verify")
+	}
+	if _, err := key.Sign(nil, []byte("message"), nil); err != ErrDigestSize {
+		t.Fatalf("expected ErrDigestSize, got %v", err)
+	}
+
+	ct, err := sm2.Encrypt(pub, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := key.Decrypt(ct)
+	if err != nil || string(pt) != "secret" {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+
+	peer, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	z1, err := key.SharedSecret(&peer.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z2, err := peer.SharedSecret(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(z1, z2) {
+		t.Fatal("the shared secrets differ")
+	}
+	if len(f.derived) != 0 {
+		t.Fatal("the derived secret was not destroyed")
+	}
+}
+
+func TestSM4AEAD(t *testing.T) {
+	f, s := openFake(t)
+	defer s.Close()
+
+	aead, err := s.SM4AEAD("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	ct := aead.Seal([]byte("prefix"), nonce, []byte("plaintext"), []byte("ad"))
+	if len(ct) != len("prefix")+len("plaintext")+aead.Overhead() {
+		t.Fatalf("ciphertext length %d", len(ct))
+	}
+
+	block, _ := sm4.NewCipher(f.sm4Key)
+	soft, _ := cipher.NewGCM(block)
+	if want := soft.Seal([]byte("prefix"), nonce, []byte("plaintext"), []byte("ad")); !bytes.Equal(ct, want) {
+		t.Fatal("the token output is not SM4-GCM")
+	}
+
+	pt, err := aead.Open(nil, nonce, ct[len("prefix"):], []byte("ad"))
+	if err != nil || string(pt) != "plaintext" {
+		t.Fatalf("Open returned %q, %v", pt, err)
+	}
+	if _, err := aead.Open(nil, nonce, ct[len("prefix"):], []byte("other")); err != ErrOpen {
+		t.Fatalf("expected ErrOpen, got %v", err)
+	}
+}
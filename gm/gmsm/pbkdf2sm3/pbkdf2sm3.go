@@ -0,0 +1,19 @@
+// Package pbkdf2sm3 implements the key derivation function PBKDF2 as defined
+// in RFC 8018 (PKCS #5 v2.1), using HMAC-SM3 as the pseudorandom function.
+//
+// It is meant for deriving SM4 keys from passwords. A password-based key
+// should be derived with a random salt of at least 8 bytes and an iteration
+// count that is as high as the application can tolerate.
+package pbkdf2sm3
+
+import (
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Key derives a key of keyLen bytes from the password, salt and iteration
+// count using PBKDF2 with HMAC-SM3.
+func Key(password, salt []byte, iter, keyLen int) []byte {
+	return pbkdf2.Key(password, salt, iter, keyLen, sm3.New)
+}
@@ -0,0 +1,31 @@
+package pbkdf2sm3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The RFC 6070 inputs with HMAC-SM3; the expected keys were computed with
+// hashlib.pbkdf2_hmac("sm3", ...) and checked against "openssl kdf PBKDF2".
+var keyTests = []struct {
+	password, salt string
+	iter           int
+	key            string
+}{
+	{"password", "salt", 1, "4612f922a1fdcefaf4312fc6f8f3322b489cbf24f2ea361b44c2bd8fa2c6dcb0"},
+	{"password", "salt", 2, "fee723a2bc966e11dffb66133f4e8df577383c78ade30e3298edbd3e54ed85b7"},
+	{"password", "salt", 4096, "b6e8f2074c87432b78f62e5ced980fdff89e86af2f693dab1638e2b3683045dd"},
+	{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, "3b6282ac8519f059e465abff0ea37b0dbfe6c672a76e6b805312d53900db630732ccc1a88fa5512a"},
+	{"pass\x00word", "sa\x00lt", 4096, "5f936b2e356f06e2bb3932165821261c"},
+}
+
+func TestKey(t *testing.T) {
+	for i, tt := range keyTests {
+		want, _ := hex.DecodeString(tt.key)
+		got := Key([]byte(tt.password), []byte(tt.salt), tt.iter, len(want))
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d: got %x, want %x", i, got, want)
+		}
+	}
+}
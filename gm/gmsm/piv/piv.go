@@ -0,0 +1,337 @@
+// Package piv uses SM2 keys on PIV smartcards, such as employee badges,
+// through the PIV card application of NIST SP 800-73-4.
+//
+// PIV assigns no algorithm identifier to SM2. Cards that support it keep
+// SM2 keys in the retired key management slots, 82 to 95, under a
+// vendor-defined identifier, which is passed to Open. Card.Certificate
+// reads the certificate of a slot, and Card.Key returns the key as an
+// sm2.OpaqueSigner that signs SM3 digests and computes key agreement
+// secrets on the card.
+//
+// The package talks to the card through the Transmitter interface, i.e.
+// SCardTransmit of PC/SC, which the Card of github.com/ebfe/scard, on
+// pcsclite or WinSCard, implements as is.
+package piv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+var (
+	ErrAlgorithm     = errors.New("piv: no SM2 algorithm identifier")
+	ErrSlot          = errors.New("piv: not a retired key management slot")
+	ErrResponse      = errors.New("piv: malformed response from the card")
+	ErrNoCertificate = errors.New("piv: no certificate in the slot")
+	ErrPublicKey     = errors.New("piv: the certificate does not hold an SM2 key")
+	ErrDigestSize    = errors.New("piv: SM2 signing takes an SM3 digest")
+	ErrPeerKey       = errors.New("piv: invalid peer public key")
+	ErrDecrypt       = errors.New("piv: PIV cards do not decrypt SM2 ciphertexts")
+)
+
+// Transmitter sends a command APDU to the card and returns the response
+// APDU, including the status word.
+type Transmitter interface {
+	Transmit(command []byte) ([]byte, error)
+}
+
+// StatusError is a status word other than 9000 returned by the card.
+type StatusError uint16
+
+func (e StatusError) Error() string {
+	switch {
+	case e == 0x6982:
+		return "piv: security status not satisfied, verify the PIN first"
+	case e == 0x6983:
+		return "piv: the PIN is blocked"
+	case e&0xfff0 == 0x63c0:
+		return fmt.Sprintf("piv: wrong PIN, %d retries left", e&0xf)
+	case e == 0x6a82:
+		return "piv: data object or application not found"
+	}
+	return fmt.Sprintf("piv: card returned status %04X", uint16(e))
+}
+
+// Retries returns the number of PIN retries left after a failed VERIFY.
+func (e StatusError) Retries() (int, bool) {
+	if e&0xfff0 == 0x63c0 {
+		return int(e & 0xf), true
+	}
+	return 0, false
+}
+
+// Slot is a key slot of the card.
+type Slot byte
+
+// RetiredSlot returns the retired key management slot n, from 1 to 20.
+func RetiredSlot(n int) Slot {
+	return Slot(0x81 + n)
+}
+
+// object returns the tag of the certificate data object of the slot.
+func (s Slot) object() ([]byte, error) {
+	if s < 0x82 || s > 0x95 {
+		return nil, ErrSlot
+	}
+	return []byte{0x5f, 0xc1, 0x0d + byte(s-0x82)}, nil
+}
+
+var aidPIV = []byte{0xa0, 0x00, 0x00, 0x03, 0x08}
+
+// Card is a PIV card with the PIV application selected.
+type Card struct {
+	mu  sync.Mutex
+	t   Transmitter
+	alg byte
+}
+
+// Open selects the PIV application on the card. alg is the algorithm
+// identifier of SM2 keys on the card, as documented by its vendor.
+func Open(t Transmitter, alg byte) (*Card, error) {
+	if alg == 0 {
+		return nil, ErrAlgorithm
+	}
+	c := &Card{t: t, alg: alg}
+	if _, err := c.transmit(0x00, 0xa4, 0x04, 0x00, aidPIV); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// transmit sends a command, chaining it over several APDUs if the data
+// is longer than 255 bytes, and returns the data of the response.
+func (c *Card) transmit(cla, ins, p1, p2 byte, data []byte) ([]byte, error) {
+	for len(data) > 0xff {
+		apdu := append([]byte{cla | 0x10, ins, p1, p2, 0xff}, data[:0xff]...)
+		if _, err := c.send(apdu); err != nil {
+			return nil, err
+		}
+		data = data[0xff:]
+	}
+	apdu := []byte{cla, ins, p1, p2}
+	if len(data) > 0 {
+		apdu = append(append(apdu, byte(len(data))), data...)
+	}
+	// Le = 00 accepts up to 256 bytes; longer responses come in parts.
+	return c.send(append(apdu, 0x00))
+}
+
+// send sends one APDU and collects the parts of the response announced by
+// status 61xx with GET RESPONSE.
+func (c *Card) send(apdu []byte) ([]byte, error) {
+	var out []byte
+	for {
+		resp, err := c.t.Transmit(apdu)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) < 2 {
+			return nil, ErrResponse
+		}
+		sw := binary.BigEndian.Uint16(resp[len(resp)-2:])
+		out = append(out, resp[:len(resp)-2]...)
+		switch {
+		case sw == 0x9000:
+			return out, nil
+		case sw>>8 == 0x61:
+			apdu = []byte{0x00, 0xc0, 0x00, 0x00, byte(sw)}
+		default:
+			return nil, StatusError(sw)
+		}
+	}
+}
+
+// VerifyPIN verifies the PIV application PIN, which signing and key
+// agreement need. A wrong PIN returns a StatusError with Retries.
+func (c *Card) VerifyPIN(pin string) error {
+	if len(pin) < 6 || len(pin) > 8 {
+		return errors.New("piv: the PIN must be 6 to 8 characters long")
+	}
+	data := bytes.Repeat([]byte{0xff}, 8)
+	copy(data, pin)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.transmit(0x00, 0x20, 0x00, 0x80, data)
+	return err
+}
+
+// Certificate reads the certificate of the slot.
+func (c *Card) Certificate(slot Slot) (*sm2.Certificate, error) {
+	tag, err := slot.object()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	resp, err := c.transmit(0x00, 0xcb, 0x3f, 0xff, appendTLV(nil, 0x5c, tag))
+	c.mu.Unlock()
+	if err == StatusError(0x6a82) {
+		return nil, ErrNoCertificate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj, _, ok := parseTLV(resp, 0x53)
+	if !ok {
+		return nil, ErrResponse
+	}
+	var der []byte
+	var info byte
+	for len(obj) > 0 {
+		tag := obj[0]
+		value, rest, ok := parseTLV(obj, tag)
+		if !ok {
+			return nil, ErrResponse
+		}
+		switch tag {
+		case 0x70:
+			der = value
+		case 0x71:
+			if len(value) > 0 {
+				info = value[0]
+			}
+		}
+		obj = rest
+	}
+	if len(der) == 0 {
+		return nil, ErrNoCertificate
+	}
+	// CertInfo 01 marks a gzip compressed certificate.
+	if info&0x01 != 0 {
+		r, err := gzip.NewReader(bytes.NewReader(der))
+		if err != nil {
+			return nil, err
+		}
+		if der, err = ioutil.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	return sm2.ParseCertificate(der)
+}
+
+// Key returns the SM2 key of the slot, whose public key is read from its
+// certificate.
+func (c *Card) Key(slot Slot) (*Key, error) {
+	cert, err := c.Certificate(slot)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := sm2PublicKey(cert.PublicKey)
+	if !ok {
+		return nil, ErrPublicKey
+	}
+	return &Key{c: c, slot: slot, pub: pub}, nil
+}
+
+// generalAuthenticate sends GENERAL AUTHENTICATE with the dynamic
+// authentication template {82 (response requested), tag value} and
+// returns the value of the response.
+func (c *Card) generalAuthenticate(slot Slot, tag byte, value []byte) ([]byte, error) {
+	template := appendTLV(appendTLV(nil, 0x82, nil), tag, value)
+
+	c.mu.Lock()
+	resp, err := c.transmit(0x00, 0x87, c.alg, byte(slot), appendTLV(nil, 0x7c, template))
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	template, _, ok := parseTLV(resp, 0x7c)
+	if !ok {
+		return nil, ErrResponse
+	}
+	out, _, ok := parseTLV(template, 0x82)
+	if !ok {
+		return nil, ErrResponse
+	}
+	return out, nil
+}
+
+// Key is an SM2 key in a slot of the card.
+type Key struct {
+	c    *Card
+	slot Slot
+	pub  *sm2.PublicKey
+}
+
+var _ sm2.OpaqueSigner = (*Key)(nil)
+
+// Public returns the *sm2.PublicKey of the key.
+func (k *Key) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs the SM3 digest e = SM3(ZA || M) on the card and returns the
+// ASN.1 signature. The card draws the nonce; rand and opts are ignored.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := k.sign(digest)
+	audit.Record(audit.OpSign, audit.BackendPIV, k.pub.X, k.pub.Y, err)
+	return sig, err
+}
+
+func (k *Key) sign(digest []byte) ([]byte, error) {
+	if len(digest) != sm3.Size {
+		return nil, ErrDigestSize
+	}
+	sig, err := k.c.generalAuthenticate(k.slot, 0x81, digest)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := sm2.SignDataToSignDigit(sig); err != nil {
+		return nil, ErrResponse
+	}
+	return sig, nil
+}
+
+// Decrypt always fails: PIV cards only offer key agreement with the keys
+// of the key management slots.
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	return nil, ErrDecrypt
+}
+
+// SharedSecret computes the x-coordinate of d·peer on the card.
+func (k *Key) SharedSecret(peer *sm2.PublicKey) ([]byte, error) {
+	if peer == nil || peer.X == nil || peer.Y == nil || !sm2.P256Sm2().IsOnCurve(peer.X, peer.Y) {
+		return nil, ErrPeerKey
+	}
+	point := make([]byte, 65)
+	point[0] = 4
+	x, y := peer.X.Bytes(), peer.Y.Bytes()
+	copy(point[33-len(x):33], x)
+	copy(point[65-len(y):], y)
+	secret, err := k.c.generalAuthenticate(k.slot, 0x85, point)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) != 32 {
+		return nil, ErrResponse
+	}
+	return secret, nil
+}
+
+// sm2PublicKey returns the SM2 key of a certificate, which sm2 parses as
+// *ecdsa.PublicKey on the SM2 curve.
+func sm2PublicKey(pub interface{}) (*sm2.PublicKey, bool) {
+	switch pub := pub.(type) {
+	case *sm2.PublicKey:
+		return pub, true
+	case *ecdsa.PublicKey:
+		if pub.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,253 @@
+package piv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const testAlg = 0x30
+
+// fakeCard is a PIV application with an SM2 key in the first retired
+// slot.
+type fakeCard struct {
+	t        *testing.T
+	priv     *sm2.PrivateKey
+	cert     []byte
+	compress bool
+	verified bool
+	retries  int
+	pending  []byte
+	chained  []byte
+	commands int
+}
+
+func newFakeCard(t *testing.T) *fakeCard {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &sm2.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: "Employee 42"},
+		NotBefore:          time.Unix(1000, 0),
+		NotAfter:           time.Unix(100000, 0),
+		SignatureAlgorithm: sm2.SM2WithSM3,
+	}
+	cert, err := sm2.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeCard{t: t, priv: priv, cert: cert, retries: 3}
+}
+
+func sw(data []byte, sw uint16) []byte {
+	return append(append([]byte(nil), data...), byte(sw>>8), byte(sw))
+}
+
+// respond returns the first 256 bytes of resp and keeps the rest for GET
+// RESPONSE.
+func (c *fakeCard) respond(resp []byte) []byte {
+	if len(resp) <= 256 {
+		return sw(resp, 0x9000)
+	}
+	c.pending = resp[256:]
+	n := len(c.pending)
+	if n > 256 {
+		n = 0
+	}
+	return sw(resp[:256], 0x6100|uint16(n))
+}
+
+func (c *fakeCard) Transmit(apdu []byte) ([]byte, error) {
+	c.commands++
+	cla, ins, p1, p2 := apdu[0], apdu[1], apdu[2], apdu[3]
+	var data []byte
+	if len(apdu) > 5 {
+		data = apdu[5 : 5+int(apdu[4])]
+	}
+	if cla&0x10 != 0 {
+		c.chained = append(c.chained, data...)
+		return sw(nil, 0x9000), nil
+	}
+	data = append(c.chained, data...)
+	c.chained = nil
+
+	switch ins {
+	case 0xa4:
+		if !bytes.Equal(data, aidPIV) {
+			return sw(nil, 0x6a82), nil
+		}
+		return sw(nil, 0x9000), nil
+	case 0xc0:
+		resp := c.pending
+		c.pending = nil
+		return c.respond(resp), nil
+	case 0x20:
+		if c.retries == 0 {
+			return sw(nil, 0x6983), nil
+		}
+		if !bytes.Equal(data, []byte("123456\xff\xff")) {
+			c.retries--
+			return sw(nil, 0x63c0|uint16(c.retries)), nil
+		}
+		c.verified, c.retries = true, 3
+		return sw(nil, 0x9000), nil
+	case 0xcb:
+		tag, _, _ := parseTLV(data, 0x5c)
+		if !bytes.Equal(tag, []byte{0x5f, 0xc1, 0x0d}) {
+			return sw(nil, 0x6a82), nil
+		}
+		cert, info := c.cert, byte(0)
+		if c.compress {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			w.Write(cert)
+			w.Close()
+			cert, info = buf.Bytes(), 1
+		}
+		obj := appendTLV(appendTLV(appendTLV(nil, 0x70, cert), 0x71, []byte{info}), 0xfe, nil)
+		return c.respond(appendTLV(nil, 0x53, obj)), nil
+	case 0x87:
+		if !c.verified {
+			return sw(nil, 0x6982), nil
+		}
+		if p1 != testAlg || p2 != 0x82 {
+			return sw(nil, 0x6a86), nil
+		}
+		template, _, ok := parseTLV(data, 0x7c)
+		if !ok {
+			return sw(nil, 0x6a80), nil
+		}
+		_, rest, _ := parseTLV(template, 0x82)
+		var out []byte
+		switch rest[0] {
+		case 0x81:
+			digest, _, _ := parseTLV(rest, 0x81)
+			sig, err := c.priv.Sign(nil, digest, nil)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+			out = sig
+		case 0x85:
+			point, _, _ := parseTLV(rest, 0x85)
+			peer := &sm2.PublicKey{Curve: sm2.P256Sm2(), X: new(big.Int).SetBytes(point[1:33]), Y: new(big.Int).SetBytes(point[33:])}
+			secret, err := c.priv.SharedSecret(peer)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+			out = secret
+		}
+		return c.respond(appendTLV(nil, 0x7c, appendTLV(nil, 0x82, out))), nil
+	}
+	return sw(nil, 0x6d00), nil
+}
+
+func TestCard(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		fc := newFakeCard(t)
+		fc.compress = compress
+		card, err := Open(fc, testAlg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := card.Certificate(RetiredSlot(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cert.Subject.CommonName != "Employee 42" {
+			t.Fatalf("certificate of %q", cert.Subject.CommonName)
+		}
+		if _, err := card.Certificate(RetiredSlot(2)); err != ErrNoCertificate {
+			t.Fatalf("expected ErrNoCertificate, got %v", err)
+		}
+		if _, err := card.Certificate(0x9a); err != ErrSlot {
+			t.Fatalf("expected ErrSlot, got %v", err)
+		}
+
+		key, err := card.Key(RetiredSlot(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := key.Public().(*sm2.PublicKey)
+		if pub.X.Cmp(fc.priv.X) != 0 || pub.Y.Cmp(fc.priv.Y) != 0 {
+			t.Fatal("wrong public key")
+		}
+		digest := sm3.Sm3Sum([]byte("message"))
+		if _, err := key.Sign(nil, digest, nil); err != StatusError(0x6982) {
+			t.Fatalf("expected a security status error before the PIN, got %v", err)
+		}
+
+		if err := card.VerifyPIN("123456"); err != nil {
+			t.Fatal(err)
+		}
+		sig, err := key.Sign(nil, digest, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pub.Verify(digest, sig) {
+			t.Fatal("the signature does not verify")
+		}
+		if _, err := key.Sign(nil, []byte("message"), nil); err != ErrDigestSize {
+			t.Fatalf("expected ErrDigestSize, got %v", err)
+		}
+
+		peer, err := sm2.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret, err := key.SharedSecret(&peer.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := peer.SharedSecret(pub)
+		if !bytes.Equal(secret, want) {
+			t.Fatal("wrong shared secret")
+		}
+		if _, err := key.Decrypt([]byte("ciphertext")); err != ErrDecrypt {
+			t.Fatalf("expected ErrDecrypt, got %v", err)
+		}
+	}
+}
+
+func TestVerifyPIN(t *testing.T) {
+	card, err := Open(newFakeCard(t), testAlg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = card.VerifyPIN("654321")
+	if n, ok := err.(StatusError).Retries(); !ok || n != 2 {
+		t.Fatalf("expected 2 retries left, got %v", err)
+	}
+	card.VerifyPIN("654321")
+	card.VerifyPIN("654321")
+	if err := card.VerifyPIN("123456"); err != StatusError(0x6983) {
+		t.Fatalf("expected a blocked PIN, got %v", err)
+	}
+	if _, err := Open(newFakeCard(t), 0); err != ErrAlgorithm {
+		t.Fatalf("expected ErrAlgorithm, got %v", err)
+	}
+}
+
+func TestCommandChaining(t *testing.T) {
+	fc := newFakeCard(t)
+	card, err := Open(fc, testAlg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.commands = 0
+	long := bytes.Repeat([]byte{1}, 600)
+	if _, err := card.transmit(0x00, 0xa4, 0x04, 0x00, long); err != StatusError(0x6a82) {
+		t.Fatalf("expected the fake card to see the whole data, got %v", err)
+	}
+	if fc.commands != 3 {
+		t.Fatalf("%d APDUs for 600 bytes", fc.commands)
+	}
+}
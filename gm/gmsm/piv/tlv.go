@@ -0,0 +1,44 @@
+package piv
+
+// appendTLV appends a BER-TLV with a one-byte tag to b.
+func appendTLV(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+	switch n := len(value); {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n <= 0xff:
+		b = append(b, 0x81, byte(n))
+	default:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	}
+	return append(b, value...)
+}
+
+// parseTLV parses a BER-TLV with a one-byte tag at the start of b and
+// returns its value and the bytes that follow it.
+func parseTLV(b []byte, tag byte) (value, rest []byte, ok bool) {
+	if len(b) < 2 || b[0] != tag {
+		return nil, nil, false
+	}
+	n, b := int(b[1]), b[2:]
+	switch n {
+	case 0x81:
+		if len(b) < 1 {
+			return nil, nil, false
+		}
+		n, b = int(b[0]), b[1:]
+	case 0x82:
+		if len(b) < 2 {
+			return nil, nil, false
+		}
+		n, b = int(b[0])<<8|int(b[1]), b[2:]
+	default:
+		if n >= 0x80 {
+			return nil, nil, false
+		}
+	}
+	if len(b) < n {
+		return nil, nil, false
+	}
+	return b[:n], b[n:], true
+}
@@ -0,0 +1,202 @@
+// Package policy enforces usage constraints on SM2 keys in the library
+// rather than by convention: a Key wraps any sm2.OpaqueSigner, software
+// or hardware, and refuses the operations its Policy does not allow, such
+// as signing with an encryption key, with a typed *Error.
+package policy
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// Operation is an operation on a private key.
+type Operation int
+
+const (
+	OpSign Operation = 1 << iota
+	OpDecrypt
+	OpKeyAgreement
+)
+
+func (op Operation) String() string {
+	switch op {
+	case OpSign:
+		return "sign"
+	case OpDecrypt:
+		return "decrypt"
+	case OpKeyAgreement:
+		return "key agreement"
+	}
+	return fmt.Sprintf("Operation(%d)", int(op))
+}
+
+// Request is an operation submitted to Policy.Approve.
+type Request struct {
+	Op  Operation
+	Key crypto.PublicKey
+	// Data is the digest to sign or the ciphertext to decrypt.
+	Data []byte
+	// Peer is the peer key of a key agreement.
+	Peer *sm2.PublicKey
+}
+
+// Policy is the set of operations a key allows.
+type Policy struct {
+	// Usage is the OR of the allowed operations, e.g. OpSign for a
+	// signing key and OpDecrypt|OpKeyAgreement for an encryption key.
+	Usage Operation
+	// MaxOperations is the number of operations the key allows over its
+	// lifetime, or 0 for no limit. Refused operations do not count.
+	MaxOperations uint64
+	// NotBefore and NotAfter are the validity window; a zero time leaves
+	// that side open.
+	NotBefore, NotAfter time.Time
+	// Approve, if not nil, is called for every operation the other
+	// constraints allow, e.g. to ask a second operator or a rate limiter;
+	// an error refuses the operation.
+	Approve func(r *Request) error
+}
+
+// Violation is the constraint an operation violated.
+type Violation int
+
+const (
+	ViolationUsage Violation = iota + 1
+	ViolationExhausted
+	ViolationNotYetValid
+	ViolationExpired
+	ViolationNotApproved
+)
+
+var violationText = map[Violation]string{
+	ViolationUsage:       "not allowed by the key usage",
+	ViolationExhausted:   "the key has reached its maximum number of operations",
+	ViolationNotYetValid: "the key is not valid yet",
+	ViolationExpired:     "the key has expired",
+	ViolationNotApproved: "not approved",
+}
+
+// Error is the error of an operation refused by the policy.
+type Error struct {
+	Op        Operation
+	Violation Violation
+	// Err is the error of Policy.Approve for ViolationNotApproved.
+	Err error
+}
+
+func (e *Error) Error() string {
+	s := "policy: " + e.Op.String() + ": " + violationText[e.Violation]
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Key is a key restricted by a Policy.
+type Key struct {
+	k sm2.OpaqueSigner
+	p Policy
+
+	mu  sync.Mutex
+	ops uint64
+	now func() time.Time
+}
+
+var _ sm2.OpaqueSigner = (*Key)(nil)
+
+// Wrap returns k restricted by p. Callers must only keep the returned Key,
+// since k itself is not restricted.
+func Wrap(k sm2.OpaqueSigner, p Policy) *Key {
+	return &Key{k: k, p: p, now: time.Now}
+}
+
+// Policy returns the policy of the key.
+func (k *Key) Policy() Policy {
+	return k.p
+}
+
+// Operations returns the number of operations the policy allowed so far.
+func (k *Key) Operations() uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.ops
+}
+
+// check checks r against the policy and counts it if it is allowed.
+func (k *Key) check(r *Request) error {
+	if k.p.Usage&r.Op == 0 {
+		return &Error{Op: r.Op, Violation: ViolationUsage}
+	}
+	now := k.now()
+	if !k.p.NotBefore.IsZero() && now.Before(k.p.NotBefore) {
+		return &Error{Op: r.Op, Violation: ViolationNotYetValid}
+	}
+	if !k.p.NotAfter.IsZero() && now.After(k.p.NotAfter) {
+		return &Error{Op: r.Op, Violation: ViolationExpired}
+	}
+
+	// The count is reserved before the approval so that concurrent
+	// operations cannot exceed it, and released if it is refused.
+	k.mu.Lock()
+	if k.p.MaxOperations != 0 && k.ops >= k.p.MaxOperations {
+		k.mu.Unlock()
+		return &Error{Op: r.Op, Violation: ViolationExhausted}
+	}
+	k.ops++
+	k.mu.Unlock()
+
+	if k.p.Approve != nil {
+		r.Key = k.k.Public()
+		if err := k.p.Approve(r); err != nil {
+			k.mu.Lock()
+			k.ops--
+			k.mu.Unlock()
+			return &Error{Op: r.Op, Violation: ViolationNotApproved, Err: err}
+		}
+	}
+	return nil
+}
+
+// Public returns the public key of the wrapped key. It is not an operation
+// on the private key, so the policy does not restrict or count it.
+func (k *Key) Public() crypto.PublicKey {
+	return k.k.Public()
+}
+
+// Sign signs digest with the wrapped key if the policy allows OpSign. It
+// returns an *Error with ViolationUsage if the usage lacks OpSign,
+// ViolationNotYetValid or ViolationExpired outside the validity window,
+// ViolationExhausted once MaxOperations were allowed, and
+// ViolationNotApproved if Policy.Approve refuses it. An allowed operation
+// counts even if the wrapped key then fails.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if err := k.check(&Request{Op: OpSign, Data: digest}); err != nil {
+		return nil, err
+	}
+	return k.k.Sign(rand, digest, opts)
+}
+
+// Decrypt decrypts ciphertext with the wrapped key if the policy allows
+// OpDecrypt, and otherwise returns an *Error with the same violations as
+// Sign.
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	if err := k.check(&Request{Op: OpDecrypt, Data: ciphertext}); err != nil {
+		return nil, err
+	}
+	return k.k.Decrypt(ciphertext)
+}
+
+// SharedSecret computes the shared secret with peer if the policy allows
+// OpKeyAgreement, and otherwise returns an *Error with the same violations
+// as Sign. Policy.Approve sees the peer key in Request.Peer.
+func (k *Key) SharedSecret(peer *sm2.PublicKey) ([]byte, error) {
+	if err := k.check(&Request{Op: OpKeyAgreement, Peer: peer}); err != nil {
+		return nil, err
+	}
+	return k.k.SharedSecret(peer)
+}
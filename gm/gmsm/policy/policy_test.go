@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func violation(err error) Violation {
+	if e, ok := err.(*Error); ok {
+		return e.Violation
+	}
+	return 0
+}
+
+func TestUsage(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sm3.Sm3Sum([]byte("message"))
+	ct, err := sm2.Encrypt(&priv.PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := Wrap(priv, Policy{Usage: OpDecrypt | OpKeyAgreement})
+	if _, err := enc.Sign(nil, digest, nil); violation(err) != ViolationUsage {
+		t.Fatalf("expected ViolationUsage, got %v", err)
+	}
+	if pt, err := enc.Decrypt(ct); err != nil || string(pt) != "secret" {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+	if _, err := enc.SharedSecret(&priv.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+
+	sig := Wrap(priv, Policy{Usage: OpSign})
+	s, err := sig.Sign(nil, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Verify(digest, s) {
+		t.Fatal("the signature does not verify")
+	}
+	_, err = sig.Decrypt(ct)
+	if violation(err) != ViolationUsage {
+		t.Fatalf("expected ViolationUsage, got %v", err)
+	}
+	if err.Error() != "policy: decrypt: not allowed by the key usage" {
+		t.Fatalf("error %q", err)
+	}
+	if _, err := sig.SharedSecret(&priv.PublicKey); violation(err) != ViolationUsage {
+		t.Fatalf("expected ViolationUsage, got %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sm3.Sm3Sum([]byte("message"))
+
+	now := time.Unix(1000, 0)
+	k := Wrap(priv, Policy{
+		Usage:         OpSign,
+		MaxOperations: 2,
+		NotBefore:     time.Unix(500, 0),
+		NotAfter:      time.Unix(2000, 0),
+	})
+	k.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		if _, err := k.Sign(nil, digest, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := k.Sign(nil, digest, nil); violation(err) != ViolationExhausted {
+		t.Fatalf("expected ViolationExhausted, got %v", err)
+	}
+	if k.Operations() != 2 {
+		t.Fatalf("%d operations", k.Operations())
+	}
+
+	k = Wrap(priv, Policy{Usage: OpSign, NotBefore: time.Unix(500, 0), NotAfter: time.Unix(2000, 0)})
+	k.now = func() time.Time { return now }
+	now = time.Unix(499, 0)
+	if _, err := k.Sign(nil, digest, nil); violation(err) != ViolationNotYetValid {
+		t.Fatalf("expected ViolationNotYetValid, got %v", err)
+	}
+	now = time.Unix(2001, 0)
+	if _, err := k.Sign(nil, digest, nil); violation(err) != ViolationExpired {
+		t.Fatalf("expected ViolationExpired, got %v", err)
+	}
+	if k.Operations() != 0 {
+		t.Fatal("refused operations were counted")
+	}
+}
+
+func TestApprove(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied := errors.New("operator declined")
+	var got *Request
+	k := Wrap(priv, Policy{
+		Usage:         OpSign,
+		MaxOperations: 1,
+		Approve: func(r *Request) error {
+			got = r
+			if string(r.Data) != string(sm3.Sm3Sum([]byte("approved"))) {
+				return denied
+			}
+			return nil
+		},
+	})
+
+	_, err = k.Sign(nil, sm3.Sm3Sum([]byte("other")), nil)
+	if e, ok := err.(*Error); !ok || e.Violation != ViolationNotApproved || e.Err != denied || e.Op != OpSign {
+		t.Fatalf("expected ViolationNotApproved, got %v", err)
+	}
+	if got.Key.(*sm2.PublicKey) != &priv.PublicKey {
+		t.Fatal("the request does not name the key")
+	}
+	// The refused operation does not use up the only one allowed.
+	if _, err := k.Sign(nil, sm3.Sm3Sum([]byte("approved")), nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package testrand provides a deterministic source of randomness for tests.
+//
+// The stream of New(seed) is fixed and will not change: block i, counting
+// from 0, is
+//
+//	SM3(seed || uint64(i))
+//
+// with i encoded big-endian, and the stream is the concatenation of the
+// blocks. Reads of any size consume it in order, so the output depends
+// only on the seed and the total number of bytes read before.
+//
+// Fixture makes the randomized functions of the sm2 package, such as
+// GenerateKey, Sm2Sign and Encrypt, draw from such a stream, so that tests
+// can compare keys, signatures and ciphertexts with golden values. The
+// values stay stable as long as the functions consume the same amount of
+// randomness. Never use this package outside of tests.
+package testrand
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Reader is a deterministic stream of bytes. It is safe for concurrent use,
+// but concurrent reads make the split of the stream between the readers
+// unpredictable.
+type Reader struct {
+	mu      sync.Mutex
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+// New returns the stream of the seed.
+func New(seed []byte) *Reader {
+	return &Reader{seed: append([]byte(nil), seed...)}
+}
+
+// Read fills p with the next bytes of the stream. It never fails.
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			r.buf = r.block()
+		}
+		m := copy(p[n:], r.buf)
+		r.buf = r.buf[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (r *Reader) block() []byte {
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], r.counter)
+	r.counter++
+	return sm3.Sm3Sum(append(append([]byte(nil), r.seed...), ctr[:]...))
+}
+
+// Fixture sets the source of randomness of the sm2 package to the stream of
+// the seed and returns a function that restores the previous source:
+//
+//	defer testrand.Fixture([]byte("golden"))()
+//
+// Tests that use it must not run in parallel with other tests of
+// randomized sm2 functions.
+func Fixture(seed []byte) (restore func()) {
+	prev := sm2.RandSource()
+	sm2.SetRandSource(New(seed))
+	return func() { sm2.SetRandSource(prev) }
+}
+
+var _ io.Reader = (*Reader)(nil)
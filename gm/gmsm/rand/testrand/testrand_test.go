@@ -0,0 +1,78 @@
+package testrand
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func TestStream(t *testing.T) {
+	seed := []byte("seed")
+	out := make([]byte, 80)
+	New(seed).Read(out)
+
+	// The stream is SM3(seed || counter) for counters 0, 1, 2.
+	var want []byte
+	for i := byte(0); i < 3; i++ {
+		want = append(want, sm3.Sm3Sum(append([]byte("seed"), 0, 0, 0, 0, 0, 0, 0, i))...)
+	}
+	if !bytes.Equal(out, want[:80]) {
+		t.Fatalf("stream %x", out)
+	}
+
+	// Reads of any size consume the same stream.
+	r := New(seed)
+	var got []byte
+	for _, n := range []int{1, 31, 5, 0, 43} {
+		chunk := make([]byte, n)
+		r.Read(chunk)
+		got = append(got, chunk...)
+	}
+	if !bytes.Equal(got, out) {
+		t.Fatal("the stream depends on the read sizes")
+	}
+
+	if got := hex.EncodeToString(out[:32]); got != "8f417a9c0e813eb2684a0b3cdff28a7bfbb182820eb71fcbdd108420482bbb72" {
+		t.Fatalf("first block %s", got)
+	}
+}
+
+func TestFixture(t *testing.T) {
+	msg := []byte("golden")
+	run := func() (string, string, string) {
+		defer Fixture([]byte("fixture"))()
+		priv, err := sm2.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := priv.Sign(nil, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, err := sm2.Encrypt(&priv.PublicKey, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hex.EncodeToString(priv.D.Bytes()), hex.EncodeToString(sig), hex.EncodeToString(ct)
+	}
+	// Golden values: a change to how GenerateKey, Sign or Encrypt consume
+	// randomness shows up here.
+	for i := 0; i < 2; i++ {
+		d, sig, ct := run()
+		if d != "f1d9a50dfc8c9e205b0c61c9ff26c93dcf7054ccfb6253f0f2f871edcc99eea5" {
+			t.Fatalf("private key %s", d)
+		}
+		if sig != "304502205fb67083e00c199952ec981c22854230046cfc4c37d751f87f075bcbc5ad3f77022100f98834237f7f75cab5261779712e2bdc7a92e91e49445e1d99e3fbc5291bf949" {
+			t.Fatalf("signature %s", sig)
+		}
+		if ct != "0425806071521e0ccac5f875da0e573a1e85ee44122d891f7f43c6109081ee21e231fc5548af79f55df918fdb74b9f957f4a7fc61dc3d31dc5e8741a1a3003fd444653ccf85177e303f8687fefed2d4f3cbea6caa8380d1d75775f0bed55af691339599bb2825d" {
+			t.Fatalf("ciphertext %s", ct)
+		}
+	}
+	if _, ok := sm2.RandSource().(*Reader); ok {
+		t.Fatal("the source was not restored")
+	}
+}
@@ -0,0 +1,52 @@
+package scryptsm3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The expected keys were computed with an independent Python implementation
+// of RFC 7914 driven by hashlib's SM3, which reproduces the RFC 7914 test
+// vectors when driven by SHA-256 instead.
+var keyTests = []struct {
+	password, salt string
+	N, r, p        int
+	key            string
+}{
+	{"", "", 16, 1, 1, "3d1dd85d2dee936a94ad3bbfc668ef44d787a4c60c0e16a95be5dbee8e938b50d6f551c4b4132dec98c5352da8861aa5c516fc6f9e227cab564db08fc5f11efd"},
+	{"password", "NaCl", 1024, 8, 16, "5387318f2708cfe3d24ed96ab658c4c3e2858f83da554d0ab6b1d057a39f5d77a5eb3b268a162fb5bc055af4b95351bc62b9c33339ba37e156b7df742e8ecc41"},
+}
+
+func TestKey(t *testing.T) {
+	for i, tt := range keyTests {
+		want, _ := hex.DecodeString(tt.key)
+		got, err := Key([]byte(tt.password), []byte(tt.salt), tt.N, tt.r, tt.p, len(want))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d: got %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestKeyInvalidParams(t *testing.T) {
+	tests := []struct {
+		N, r, p, keyLen int
+		err             error
+	}{
+		{1, 1, 1, 16, ErrInvalidN},
+		{12, 1, 1, 16, ErrInvalidN},
+		{16, 0, 1, 16, ErrInvalidParams},
+		{16, 1, 0, 16, ErrInvalidParams},
+		{16, -1, 1, 16, ErrInvalidParams},
+		{16, 1, 1, -1, ErrInvalidParams},
+		{16, 1 << 15, 1 << 15, 16, ErrParamsTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := Key([]byte("password"), []byte("salt"), tt.N, tt.r, tt.p, tt.keyLen); err != tt.err {
+			t.Errorf("Key(N=%d, r=%d, p=%d, keyLen=%d): got %v, want %v", tt.N, tt.r, tt.p, tt.keyLen, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package sdf
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"math/big"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// Mock is a Device implemented in Go with the sm2 and sm4 packages. It
+// follows the access rules of a card: internal private keys can only be
+// used in a session that obtained the access right, and session keys are
+// bound to the session that created them. Its key material is in memory;
+// use it for tests and development only.
+type Mock struct {
+	mu       sync.Mutex
+	pairs    map[uint]*mockPair
+	keks     map[uint]cipher.Block
+	sessions map[SessionHandle]*mockSession
+	next     uintptr
+}
+
+type mockPair struct {
+	sign, enc *sm2.PrivateKey
+	password  []byte
+}
+
+type mockSession struct {
+	rights map[uint]bool
+	keys   map[KeyHandle]cipher.Block
+}
+
+// NewMock returns a device without keys.
+func NewMock() *Mock {
+	return &Mock{
+		pairs:    map[uint]*mockPair{},
+		keks:     map[uint]cipher.Block{},
+		sessions: map[SessionHandle]*mockSession{},
+	}
+}
+
+// AddKeyPair generates the signing and encryption key pairs at the index,
+// protected by the password.
+func (m *Mock) AddKeyPair(index uint, password []byte) error {
+	sign, err := sm2.GenerateKey()
+	if err != nil {
+		return err
+	}
+	enc, err := sm2.GenerateKey()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pairs[index] = &mockPair{sign, enc, append([]byte(nil), password...)}
+	return nil
+}
+
+// AddKEK sets the SM4 key encryption key at the index.
+func (m *Mock) AddKEK(index uint, key []byte) error {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keks[index] = block
+	return nil
+}
+
+func (m *Mock) Close() error { return nil }
+
+func (m *Mock) OpenSession() (SessionHandle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.next++
+	h := SessionHandle(m.next)
+	m.sessions[h] = &mockSession{rights: map[uint]bool{}, keys: map[KeyHandle]cipher.Block{}}
+	return h, nil
+}
+
+func (m *Mock) CloseSession(s SessionHandle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions[s] == nil {
+		return SDR_OPENSESSION
+	}
+	delete(m.sessions, s)
+	return nil
+}
+
+// session returns the session and the key pair at the index; with
+// needRight, the session must hold the access right to it.
+func (m *Mock) session(s SessionHandle, index uint, needRight bool) (*mockSession, *mockPair, error) {
+	sess := m.sessions[s]
+	if sess == nil {
+		return nil, nil, SDR_OPENSESSION
+	}
+	pair := m.pairs[index]
+	if pair == nil {
+		return nil, nil, SDR_KEYNOTEXIST
+	}
+	if needRight && !sess.rights[index] {
+		return nil, nil, SDR_PARDENY
+	}
+	return sess, pair, nil
+}
+
+func (m *Mock) GetPrivateKeyAccessRight(s SessionHandle, index uint, password []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, pair, err := m.session(s, index, false)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(password, pair.password) != 1 {
+		return SDR_PARDENY
+	}
+	sess.rights[index] = true
+	return nil
+}
+
+func (m *Mock) ReleasePrivateKeyAccessRight(s SessionHandle, index uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess, _, err := m.session(s, index, false)
+	if err != nil {
+		return err
+	}
+	delete(sess.rights, index)
+	return nil
+}
+
+func (m *Mock) ExportSignPublicKey_ECC(s SessionHandle, index uint) (*ECCrefPublicKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, pair, err := m.session(s, index, false)
+	if err != nil {
+		return nil, err
+	}
+	return PublicKeyToRef(&pair.sign.PublicKey), nil
+}
+
+func (m *Mock) ExportEncPublicKey_ECC(s SessionHandle, index uint) (*ECCrefPublicKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, pair, err := m.session(s, index, false)
+	if err != nil {
+		return nil, err
+	}
+	return PublicKeyToRef(&pair.enc.PublicKey), nil
+}
+
+func (m *Mock) InternalSign_ECC(s SessionHandle, index uint, data []byte) (*ECCSignature, error) {
+	m.mu.Lock()
+	_, pair, err := m.session(s, index, true)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return mockSign(pair.sign, data)
+}
+
+func mockSign(priv *sm2.PrivateKey, digest []byte) (*ECCSignature, error) {
+	der, err := priv.Sign(nil, digest, nil)
+	if err != nil {
+		return nil, SDR_SIGNERR
+	}
+	return SignatureFromASN1(der)
+}
+
+func mockVerify(pub *sm2.PublicKey, digest []byte, sig *ECCSignature) error {
+	r := new(big.Int).SetBytes(sig.R[:])
+	s := new(big.Int).SetBytes(sig.S[:])
+	if !sm2.Verify(pub, digest, r, s) {
+		return SDR_VERIFYERR
+	}
+	return nil
+}
+
+func (m *Mock) InternalVerify_ECC(s SessionHandle, index uint, data []byte, sig *ECCSignature) error {
+	m.mu.Lock()
+	_, pair, err := m.session(s, index, false)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return mockVerify(&pair.sign.PublicKey, data, sig)
+}
+
+func (m *Mock) InternalDecrypt_ECC(s SessionHandle, index uint, alg uint, c *ECCCipher) ([]byte, error) {
+	if alg != SGD_SM2_3 {
+		return nil, SDR_ALGNOTSUPPORT
+	}
+	m.mu.Lock()
+	_, pair, err := m.session(s, index, true)
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	pt, err := sm2.Decrypt(pair.enc, CipherToBytes(c))
+	if err != nil {
+		return nil, SDR_SKOPERR
+	}
+	return pt, nil
+}
+
+func (m *Mock) ExternalSign_ECC(s SessionHandle, alg uint, priv *ECCrefPrivateKey, data []byte) (*ECCSignature, error) {
+	if alg != SGD_SM2_1 {
+		return nil, SDR_ALGNOTSUPPORT
+	}
+	if err := m.checkSession(s); err != nil {
+		return nil, err
+	}
+	curve := sm2.P256Sm2()
+	d := new(big.Int).SetBytes(priv.K[:])
+	if priv.Bits != 256 || d.Sign() <= 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, SDR_KEYERR
+	}
+	key := &sm2.PrivateKey{PublicKey: sm2.PublicKey{Curve: curve}, D: d}
+	key.X, key.Y = curve.ScalarBaseMult(d.Bytes())
+	return mockSign(key, data)
+}
+
+func (m *Mock) ExternalVerify_ECC(s SessionHandle, alg uint, pub *ECCrefPublicKey, data []byte, sig *ECCSignature) error {
+	if alg != SGD_SM2_1 {
+		return SDR_ALGNOTSUPPORT
+	}
+	if err := m.checkSession(s); err != nil {
+		return err
+	}
+	key, err := PublicKeyFromRef(pub)
+	if err != nil {
+		return err
+	}
+	return mockVerify(key, data, sig)
+}
+
+func (m *Mock) ExternalEncrypt_ECC(s SessionHandle, alg uint, pub *ECCrefPublicKey, data []byte) (*ECCCipher, error) {
+	if alg != SGD_SM2_3 {
+		return nil, SDR_ALGNOTSUPPORT
+	}
+	if err := m.checkSession(s); err != nil {
+		return nil, err
+	}
+	key, err := PublicKeyFromRef(pub)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := sm2.Encrypt(key, data)
+	if err != nil {
+		return nil, SDR_PKOPERR
+	}
+	return CipherFromBytes(ct)
+}
+
+func (m *Mock) checkSession(s SessionHandle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sessions[s] == nil {
+		return SDR_OPENSESSION
+	}
+	return nil
+}
+
+// The mock encrypts session keys under a key encryption key with SM4-ECB.
+func (m *Mock) GenerateKeyWithKEK(s SessionHandle, bits uint, alg uint, kekIndex uint) ([]byte, KeyHandle, error) {
+	if bits != 8*sm4.KeySize || alg != SGD_SM4_ECB {
+		return nil, 0, SDR_ALGNOTSUPPORT
+	}
+	key := make([]byte, sm4.KeySize)
+	if _, err := sm2.RandSource().Read(key); err != nil {
+		return nil, 0, SDR_HARDFAIL
+	}
+
+	m.mu.Lock()
+	kek := m.keks[kekIndex]
+	m.mu.Unlock()
+	if kek == nil {
+		return nil, 0, SDR_KEYNOTEXIST
+	}
+	wrapped := make([]byte, len(key))
+	kek.Encrypt(wrapped, key)
+	h, err := m.addKey(s, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return wrapped, h, nil
+}
+
+func (m *Mock) ImportKeyWithKEK(s SessionHandle, alg uint, kekIndex uint, wrapped []byte) (KeyHandle, error) {
+	if alg != SGD_SM4_ECB {
+		return 0, SDR_ALGNOTSUPPORT
+	}
+	if len(wrapped) != sm4.KeySize {
+		return 0, SDR_KEYERR
+	}
+
+	m.mu.Lock()
+	kek := m.keks[kekIndex]
+	m.mu.Unlock()
+	if kek == nil {
+		return 0, SDR_KEYNOTEXIST
+	}
+	key := make([]byte, sm4.KeySize)
+	kek.Decrypt(key, wrapped)
+	return m.addKey(s, key)
+}
+
+func (m *Mock) addKey(s SessionHandle, key []byte) (KeyHandle, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return 0, SDR_KEYERR
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess := m.sessions[s]
+	if sess == nil {
+		return 0, SDR_OPENSESSION
+	}
+	m.next++
+	h := KeyHandle(m.next)
+	sess.keys[h] = block
+	return h, nil
+}
+
+func (m *Mock) DestroyKey(s SessionHandle, k KeyHandle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess := m.sessions[s]
+	if sess == nil {
+		return SDR_OPENSESSION
+	}
+	if sess.keys[k] == nil {
+		return SDR_KEYNOTEXIST
+	}
+	delete(sess.keys, k)
+	return nil
+}
+
+func (m *Mock) key(s SessionHandle, k KeyHandle) (cipher.Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sess := m.sessions[s]
+	if sess == nil {
+		return nil, SDR_OPENSESSION
+	}
+	block := sess.keys[k]
+	if block == nil {
+		return nil, SDR_KEYNOTEXIST
+	}
+	return block, nil
+}
+
+func (m *Mock) Encrypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error) {
+	return m.crypt(s, k, alg, iv, data, true)
+}
+
+func (m *Mock) Decrypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error) {
+	return m.crypt(s, k, alg, iv, data, false)
+}
+
+func (m *Mock) crypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte, encrypt bool) ([]byte, error) {
+	block, err := m.key(s, k)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%sm4.BlockSize != 0 {
+		return nil, SDR_SYMOPERR
+	}
+	out := make([]byte, len(data))
+	switch alg {
+	case SGD_SM4_ECB:
+		for i := 0; i < len(data); i += sm4.BlockSize {
+			if encrypt {
+				block.Encrypt(out[i:], data[i:])
+			} else {
+				block.Decrypt(out[i:], data[i:])
+			}
+		}
+	case SGD_SM4_CBC:
+		if len(iv) != sm4.BlockSize {
+			return nil, SDR_SYMOPERR
+		}
+		if encrypt {
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+		} else {
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+		}
+	default:
+		return nil, SDR_ALGNOTSUPPORT
+	}
+	return out, nil
+}
+
+var _ Device = (*Mock)(nil)
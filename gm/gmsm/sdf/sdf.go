@@ -0,0 +1,221 @@
+// Package sdf binds the SDF interface of GM/T 0018, the API of Chinese
+// cryptographic cards, so that the SM2 and SM4 keys stored in a card are
+// used without leaving it.
+//
+// Load opens the vendor library with dlopen and returns it as a Device;
+// NewMock returns a pure-Go Device for tests and development machines.
+// On a Device, OpenSession returns a Session whose Key method exposes the
+// internal SM2 key pair at an index as an sm2.OpaqueSigner, and whose
+// ImportSM4Key and GenerateSM4Key methods return handles to SM4 session
+// keys protected by an internal key encryption key. The External methods
+// use the card for operations on keys held by the caller.
+//
+// The card stores a signing and an encryption key pair at every index;
+// Key signs with the former and decrypts with the latter.
+package sdf
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// Algorithm identifiers of GM/T 0006.
+const (
+	SGD_SM4_ECB = 0x00000401
+	SGD_SM4_CBC = 0x00000402
+	SGD_SM2_1   = 0x00020200 // signature
+	SGD_SM2_2   = 0x00020400 // key exchange
+	SGD_SM2_3   = 0x00020800 // encryption
+	SGD_SM3     = 0x00000001
+)
+
+// ECCrefMaxLen is the size of the coordinate and scalar fields of the SDF
+// structures; values are stored big-endian and right-aligned.
+const ECCrefMaxLen = 64
+
+// Error is an SDR_ error code returned by a device.
+type Error uint32
+
+// The error codes of GM/T 0018.
+const (
+	SDR_OK               Error = 0
+	SDR_BASE             Error = 0x01000000
+	SDR_UNKNOWERR        Error = SDR_BASE + 0x01
+	SDR_NOTSUPPORT       Error = SDR_BASE + 0x02
+	SDR_COMMFAIL         Error = SDR_BASE + 0x03
+	SDR_HARDFAIL         Error = SDR_BASE + 0x04
+	SDR_OPENDEVICE       Error = SDR_BASE + 0x05
+	SDR_OPENSESSION      Error = SDR_BASE + 0x06
+	SDR_PARDENY          Error = SDR_BASE + 0x07
+	SDR_KEYNOTEXIST      Error = SDR_BASE + 0x08
+	SDR_ALGNOTSUPPORT    Error = SDR_BASE + 0x09
+	SDR_ALGMODNOTSUPPORT Error = SDR_BASE + 0x0A
+	SDR_PKOPERR          Error = SDR_BASE + 0x0B
+	SDR_SKOPERR          Error = SDR_BASE + 0x0C
+	SDR_SIGNERR          Error = SDR_BASE + 0x0D
+	SDR_VERIFYERR        Error = SDR_BASE + 0x0E
+	SDR_SYMOPERR         Error = SDR_BASE + 0x0F
+	SDR_STEPERR          Error = SDR_BASE + 0x10
+	SDR_FILESIZEERR      Error = SDR_BASE + 0x11
+	SDR_FILENOEXIST      Error = SDR_BASE + 0x12
+	SDR_FILEOFSERR       Error = SDR_BASE + 0x13
+	SDR_KEYTYPEERR       Error = SDR_BASE + 0x14
+	SDR_KEYERR           Error = SDR_BASE + 0x15
+)
+
+var errorNames = map[Error]string{
+	SDR_UNKNOWERR:        "unknown error",
+	SDR_NOTSUPPORT:       "function not supported",
+	SDR_COMMFAIL:         "communication with the device failed",
+	SDR_HARDFAIL:         "hardware error",
+	SDR_OPENDEVICE:       "failed to open the device",
+	SDR_OPENSESSION:      "failed to open a session",
+	SDR_PARDENY:          "no access right to the private key",
+	SDR_KEYNOTEXIST:      "key does not exist",
+	SDR_ALGNOTSUPPORT:    "algorithm not supported",
+	SDR_ALGMODNOTSUPPORT: "algorithm mode not supported",
+	SDR_PKOPERR:          "public key operation failed",
+	SDR_SKOPERR:          "private key operation failed",
+	SDR_SIGNERR:          "signing failed",
+	SDR_VERIFYERR:        "signature verification failed",
+	SDR_SYMOPERR:         "symmetric operation failed",
+	SDR_STEPERR:          "wrong order of multi-step operation",
+	SDR_FILESIZEERR:      "file size exceeded",
+	SDR_FILENOEXIST:      "file does not exist",
+	SDR_FILEOFSERR:       "file offset error",
+	SDR_KEYTYPEERR:       "wrong key type",
+	SDR_KEYERR:           "key error",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return "sdf: " + name
+	}
+	return fmt.Sprintf("sdf: error 0x%08x", uint32(e))
+}
+
+// SessionHandle and KeyHandle are the opaque handles of a device.
+type (
+	SessionHandle uintptr
+	KeyHandle     uintptr
+)
+
+// ECCrefPublicKey is an SM2 public key.
+type ECCrefPublicKey struct {
+	Bits uint
+	X, Y [ECCrefMaxLen]byte
+}
+
+// ECCrefPrivateKey is an SM2 private key.
+type ECCrefPrivateKey struct {
+	Bits uint
+	K    [ECCrefMaxLen]byte
+}
+
+// ECCSignature is an SM2 signature.
+type ECCSignature struct {
+	R, S [ECCrefMaxLen]byte
+}
+
+// ECCCipher is an SM2 ciphertext: the point C1, the hash C3 and the
+// masked message C2.
+type ECCCipher struct {
+	X, Y [ECCrefMaxLen]byte
+	M    [32]byte
+	C    []byte
+}
+
+// Device is a cryptographic card. Its methods are the SDF_ functions of
+// the same names, on Go types; the device handle is implicit.
+type Device interface {
+	Close() error
+	OpenSession() (SessionHandle, error)
+	CloseSession(s SessionHandle) error
+
+	GetPrivateKeyAccessRight(s SessionHandle, index uint, password []byte) error
+	ReleasePrivateKeyAccessRight(s SessionHandle, index uint) error
+	ExportSignPublicKey_ECC(s SessionHandle, index uint) (*ECCrefPublicKey, error)
+	ExportEncPublicKey_ECC(s SessionHandle, index uint) (*ECCrefPublicKey, error)
+
+	InternalSign_ECC(s SessionHandle, index uint, data []byte) (*ECCSignature, error)
+	InternalVerify_ECC(s SessionHandle, index uint, data []byte, sig *ECCSignature) error
+	InternalDecrypt_ECC(s SessionHandle, index uint, alg uint, c *ECCCipher) ([]byte, error)
+	ExternalSign_ECC(s SessionHandle, alg uint, priv *ECCrefPrivateKey, data []byte) (*ECCSignature, error)
+	ExternalVerify_ECC(s SessionHandle, alg uint, pub *ECCrefPublicKey, data []byte, sig *ECCSignature) error
+	ExternalEncrypt_ECC(s SessionHandle, alg uint, pub *ECCrefPublicKey, data []byte) (*ECCCipher, error)
+
+	GenerateKeyWithKEK(s SessionHandle, bits uint, alg uint, kekIndex uint) ([]byte, KeyHandle, error)
+	ImportKeyWithKEK(s SessionHandle, alg uint, kekIndex uint, key []byte) (KeyHandle, error)
+	DestroyKey(s SessionHandle, k KeyHandle) error
+	Encrypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error)
+	Decrypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error)
+}
+
+// refBytes right-aligns x in an SDF field.
+func refBytes(x *big.Int) [ECCrefMaxLen]byte {
+	var out [ECCrefMaxLen]byte
+	b := x.Bytes()
+	copy(out[ECCrefMaxLen-len(b):], b)
+	return out
+}
+
+// PublicKeyToRef and PublicKeyFromRef convert SM2 public keys.
+func PublicKeyToRef(pub *sm2.PublicKey) *ECCrefPublicKey {
+	return &ECCrefPublicKey{Bits: 256, X: refBytes(pub.X), Y: refBytes(pub.Y)}
+}
+
+func PublicKeyFromRef(ref *ECCrefPublicKey) (*sm2.PublicKey, error) {
+	curve := sm2.P256Sm2()
+	x := new(big.Int).SetBytes(ref.X[:])
+	y := new(big.Int).SetBytes(ref.Y[:])
+	if ref.Bits != 256 || !curve.IsOnCurve(x, y) {
+		return nil, SDR_KEYERR
+	}
+	return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// PrivateKeyToRef converts an SM2 private key.
+func PrivateKeyToRef(priv *sm2.PrivateKey) *ECCrefPrivateKey {
+	return &ECCrefPrivateKey{Bits: 256, K: refBytes(priv.D)}
+}
+
+// SignatureToASN1 and SignatureFromASN1 convert between ECCSignature and
+// the ASN.1 signatures of the sm2 package.
+func SignatureToASN1(sig *ECCSignature) ([]byte, error) {
+	return sm2.SignDigitToSignData(new(big.Int).SetBytes(sig.R[:]), new(big.Int).SetBytes(sig.S[:]))
+}
+
+func SignatureFromASN1(der []byte) (*ECCSignature, error) {
+	r, s, err := sm2.SignDataToSignDigit(der)
+	if err != nil {
+		return nil, err
+	}
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.BitLen() > 256 || s.BitLen() > 256 {
+		return nil, SDR_VERIFYERR
+	}
+	return &ECCSignature{R: refBytes(r), S: refBytes(s)}, nil
+}
+
+// CipherToBytes and CipherFromBytes convert between ECCCipher and the
+// ciphertexts of sm2.Encrypt, 0x04 || C1 || C3 || C2.
+func CipherToBytes(c *ECCCipher) []byte {
+	out := make([]byte, 0, 97+len(c.C))
+	out = append(out, 4)
+	out = append(out, c.X[ECCrefMaxLen-32:]...)
+	out = append(out, c.Y[ECCrefMaxLen-32:]...)
+	out = append(out, c.M[:]...)
+	return append(out, c.C...)
+}
+
+func CipherFromBytes(b []byte) (*ECCCipher, error) {
+	if len(b) < 98 || b[0] != 4 {
+		return nil, SDR_PKOPERR
+	}
+	c := &ECCCipher{C: append([]byte(nil), b[97:]...)}
+	copy(c.X[ECCrefMaxLen-32:], b[1:33])
+	copy(c.Y[ECCrefMaxLen-32:], b[33:65])
+	copy(c.M[:], b[65:97])
+	return c, nil
+}
@@ -0,0 +1,476 @@
+//go:build cgo
+// +build cgo
+
+package sdf
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+#define ECCref_MAX_LEN 64
+
+typedef struct {
+	unsigned int bits;
+	unsigned char x[ECCref_MAX_LEN];
+	unsigned char y[ECCref_MAX_LEN];
+} ECCrefPublicKey;
+
+typedef struct {
+	unsigned int bits;
+	unsigned char K[ECCref_MAX_LEN];
+} ECCrefPrivateKey;
+
+typedef struct {
+	unsigned char r[ECCref_MAX_LEN];
+	unsigned char s[ECCref_MAX_LEN];
+} ECCSignature;
+
+// C holds L bytes; libraries that declare it as C[136] share the layout.
+typedef struct {
+	unsigned char x[ECCref_MAX_LEN];
+	unsigned char y[ECCref_MAX_LEN];
+	unsigned char M[32];
+	unsigned int L;
+	unsigned char C[1];
+} ECCCipher;
+
+#define H(h) ((void *)(uintptr_t)(h))
+
+static int sdf_OpenDevice(void *f, uintptr_t *dev) {
+	void *h = NULL;
+	int rv = ((int (*)(void **))f)(&h);
+	*dev = (uintptr_t)h;
+	return rv;
+}
+
+static int sdf_CloseDevice(void *f, uintptr_t dev) {
+	return ((int (*)(void *))f)(H(dev));
+}
+
+static int sdf_OpenSession(void *f, uintptr_t dev, uintptr_t *s) {
+	void *h = NULL;
+	int rv = ((int (*)(void *, void **))f)(H(dev), &h);
+	*s = (uintptr_t)h;
+	return rv;
+}
+
+static int sdf_CloseSession(void *f, uintptr_t s) {
+	return ((int (*)(void *))f)(H(s));
+}
+
+static int sdf_GetPrivateKeyAccessRight(void *f, uintptr_t s, unsigned int index, unsigned char *pwd, unsigned int len) {
+	return ((int (*)(void *, unsigned int, unsigned char *, unsigned int))f)(H(s), index, pwd, len);
+}
+
+static int sdf_ReleasePrivateKeyAccessRight(void *f, uintptr_t s, unsigned int index) {
+	return ((int (*)(void *, unsigned int))f)(H(s), index);
+}
+
+static int sdf_ExportPublicKey_ECC(void *f, uintptr_t s, unsigned int index, ECCrefPublicKey *pub) {
+	return ((int (*)(void *, unsigned int, ECCrefPublicKey *))f)(H(s), index, pub);
+}
+
+static int sdf_InternalSign_ECC(void *f, uintptr_t s, unsigned int index, unsigned char *data, unsigned int len, ECCSignature *sig) {
+	return ((int (*)(void *, unsigned int, unsigned char *, unsigned int, ECCSignature *))f)(H(s), index, data, len, sig);
+}
+
+static int sdf_InternalDecrypt_ECC(void *f, uintptr_t s, unsigned int index, unsigned int alg, ECCCipher *c, unsigned char *out, unsigned int *outLen) {
+	return ((int (*)(void *, unsigned int, unsigned int, ECCCipher *, unsigned char *, unsigned int *))f)(H(s), index, alg, c, out, outLen);
+}
+
+static int sdf_ExternalSign_ECC(void *f, uintptr_t s, unsigned int alg, ECCrefPrivateKey *priv, unsigned char *data, unsigned int len, ECCSignature *sig) {
+	return ((int (*)(void *, unsigned int, ECCrefPrivateKey *, unsigned char *, unsigned int, ECCSignature *))f)(H(s), alg, priv, data, len, sig);
+}
+
+static int sdf_ExternalVerify_ECC(void *f, uintptr_t s, unsigned int alg, ECCrefPublicKey *pub, unsigned char *data, unsigned int len, ECCSignature *sig) {
+	return ((int (*)(void *, unsigned int, ECCrefPublicKey *, unsigned char *, unsigned int, ECCSignature *))f)(H(s), alg, pub, data, len, sig);
+}
+
+static int sdf_ExternalEncrypt_ECC(void *f, uintptr_t s, unsigned int alg, ECCrefPublicKey *pub, unsigned char *data, unsigned int len, ECCCipher *c) {
+	return ((int (*)(void *, unsigned int, ECCrefPublicKey *, unsigned char *, unsigned int, ECCCipher *))f)(H(s), alg, pub, data, len, c);
+}
+
+static int sdf_GenerateKeyWithKEK(void *f, uintptr_t s, unsigned int bits, unsigned int alg, unsigned int kek, unsigned char *key, unsigned int *keyLen, uintptr_t *k) {
+	void *h = NULL;
+	int rv = ((int (*)(void *, unsigned int, unsigned int, unsigned int, unsigned char *, unsigned int *, void **))f)(H(s), bits, alg, kek, key, keyLen, &h);
+	*k = (uintptr_t)h;
+	return rv;
+}
+
+static int sdf_ImportKeyWithKEK(void *f, uintptr_t s, unsigned int alg, unsigned int kek, unsigned char *key, unsigned int keyLen, uintptr_t *k) {
+	void *h = NULL;
+	int rv = ((int (*)(void *, unsigned int, unsigned int, unsigned char *, unsigned int, void **))f)(H(s), alg, kek, key, keyLen, &h);
+	*k = (uintptr_t)h;
+	return rv;
+}
+
+static int sdf_DestroyKey(void *f, uintptr_t s, uintptr_t k) {
+	return ((int (*)(void *, void *))f)(H(s), H(k));
+}
+
+static int sdf_Crypt(void *f, uintptr_t s, uintptr_t k, unsigned int alg, unsigned char *iv, unsigned char *in, unsigned int inLen, unsigned char *out, unsigned int *outLen) {
+	return ((int (*)(void *, void *, unsigned int, unsigned char *, unsigned char *, unsigned int, unsigned char *, unsigned int *))f)(H(s), H(k), alg, iv, in, inLen, out, outLen);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// maxPlaintext bounds the SM2 plaintexts of InternalDecrypt_ECC and
+// ExternalEncrypt_ECC.
+const maxPlaintext = 1 << 16
+
+var symbols = []string{
+	"SDF_OpenDevice", "SDF_CloseDevice", "SDF_OpenSession", "SDF_CloseSession",
+	"SDF_GetPrivateKeyAccessRight", "SDF_ReleasePrivateKeyAccessRight",
+	"SDF_ExportSignPublicKey_ECC", "SDF_ExportEncPublicKey_ECC",
+	"SDF_InternalSign_ECC", "SDF_InternalVerify_ECC", "SDF_InternalDecrypt_ECC",
+	"SDF_ExternalSign_ECC", "SDF_ExternalVerify_ECC", "SDF_ExternalEncrypt_ECC",
+	"SDF_GenerateKeyWithKEK", "SDF_ImportKeyWithKEK", "SDF_DestroyKey",
+	"SDF_Encrypt", "SDF_Decrypt",
+}
+
+// library is a Device backed by a vendor library.
+type library struct {
+	mu  sync.Mutex
+	lib unsafe.Pointer
+	dev C.uintptr_t
+	fn  map[string]unsafe.Pointer
+}
+
+// Load opens the SDF library at path, e.g. the libswsds.so of the card
+// vendor, and opens its device. Functions the library lacks fail with
+// SDR_NOTSUPPORT.
+func Load(path string) (Device, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	lib := C.dlopen(cpath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if lib == nil {
+		return nil, errors.New("sdf: " + C.GoString(C.dlerror()))
+	}
+
+	l := &library{lib: lib, fn: map[string]unsafe.Pointer{}}
+	for _, name := range symbols {
+		cname := C.CString(name)
+		if f := C.dlsym(lib, cname); f != nil {
+			l.fn[name] = f
+		}
+		C.free(unsafe.Pointer(cname))
+	}
+	for _, name := range symbols[:4] {
+		if l.fn[name] == nil {
+			C.dlclose(lib)
+			return nil, errors.New("sdf: " + path + " does not export " + name)
+		}
+	}
+	if err := check(C.sdf_OpenDevice(l.fn["SDF_OpenDevice"], &l.dev)); err != nil {
+		C.dlclose(lib)
+		return nil, err
+	}
+	return l, nil
+}
+
+func check(rv C.int) error {
+	if rv != 0 {
+		return Error(uint32(rv))
+	}
+	return nil
+}
+
+// f returns the function, or nil and SDR_NOTSUPPORT.
+func (l *library) f(name string) (unsafe.Pointer, error) {
+	if f := l.fn[name]; f != nil {
+		return f, nil
+	}
+	return nil, SDR_NOTSUPPORT
+}
+
+// ptr returns a pointer to the first byte of b, or nil if it is empty.
+func ptr(b []byte) *C.uchar {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.uchar)(unsafe.Pointer(&b[0]))
+}
+
+func (l *library) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lib == nil {
+		return nil
+	}
+	err := check(C.sdf_CloseDevice(l.fn["SDF_CloseDevice"], l.dev))
+	C.dlclose(l.lib)
+	l.lib = nil
+	return err
+}
+
+func (l *library) OpenSession() (SessionHandle, error) {
+	var s C.uintptr_t
+	if err := check(C.sdf_OpenSession(l.fn["SDF_OpenSession"], l.dev, &s)); err != nil {
+		return 0, err
+	}
+	return SessionHandle(s), nil
+}
+
+func (l *library) CloseSession(s SessionHandle) error {
+	return check(C.sdf_CloseSession(l.fn["SDF_CloseSession"], C.uintptr_t(s)))
+}
+
+func (l *library) GetPrivateKeyAccessRight(s SessionHandle, index uint, password []byte) error {
+	f, err := l.f("SDF_GetPrivateKeyAccessRight")
+	if err != nil {
+		return err
+	}
+	return check(C.sdf_GetPrivateKeyAccessRight(f, C.uintptr_t(s), C.uint(index), ptr(password), C.uint(len(password))))
+}
+
+func (l *library) ReleasePrivateKeyAccessRight(s SessionHandle, index uint) error {
+	f, err := l.f("SDF_ReleasePrivateKeyAccessRight")
+	if err != nil {
+		return err
+	}
+	return check(C.sdf_ReleasePrivateKeyAccessRight(f, C.uintptr_t(s), C.uint(index)))
+}
+
+func (l *library) exportPublicKey(name string, s SessionHandle, index uint) (*ECCrefPublicKey, error) {
+	f, err := l.f(name)
+	if err != nil {
+		return nil, err
+	}
+	var pub C.ECCrefPublicKey
+	if err := check(C.sdf_ExportPublicKey_ECC(f, C.uintptr_t(s), C.uint(index), &pub)); err != nil {
+		return nil, err
+	}
+	return publicKeyFromC(&pub), nil
+}
+
+func (l *library) ExportSignPublicKey_ECC(s SessionHandle, index uint) (*ECCrefPublicKey, error) {
+	return l.exportPublicKey("SDF_ExportSignPublicKey_ECC", s, index)
+}
+
+func (l *library) ExportEncPublicKey_ECC(s SessionHandle, index uint) (*ECCrefPublicKey, error) {
+	return l.exportPublicKey("SDF_ExportEncPublicKey_ECC", s, index)
+}
+
+func (l *library) InternalSign_ECC(s SessionHandle, index uint, data []byte) (*ECCSignature, error) {
+	f, err := l.f("SDF_InternalSign_ECC")
+	if err != nil {
+		return nil, err
+	}
+	var sig C.ECCSignature
+	if err := check(C.sdf_InternalSign_ECC(f, C.uintptr_t(s), C.uint(index), ptr(data), C.uint(len(data)), &sig)); err != nil {
+		return nil, err
+	}
+	return signatureFromC(&sig), nil
+}
+
+func (l *library) InternalVerify_ECC(s SessionHandle, index uint, data []byte, sig *ECCSignature) error {
+	f, err := l.f("SDF_InternalVerify_ECC")
+	if err != nil {
+		return err
+	}
+	csig := signatureToC(sig)
+	// SDF_InternalVerify_ECC has the signature of SDF_InternalSign_ECC.
+	return check(C.sdf_InternalSign_ECC(f, C.uintptr_t(s), C.uint(index), ptr(data), C.uint(len(data)), &csig))
+}
+
+func (l *library) InternalDecrypt_ECC(s SessionHandle, index uint, alg uint, c *ECCCipher) ([]byte, error) {
+	f, err := l.f("SDF_InternalDecrypt_ECC")
+	if err != nil {
+		return nil, err
+	}
+	if len(c.C) > maxPlaintext {
+		return nil, SDR_SKOPERR
+	}
+	cc := cipherToC(c)
+	defer C.free(unsafe.Pointer(cc))
+	out := make([]byte, len(c.C)+1)
+	outLen := C.uint(len(out))
+	if err := check(C.sdf_InternalDecrypt_ECC(f, C.uintptr_t(s), C.uint(index), C.uint(alg), cc, ptr(out), &outLen)); err != nil {
+		return nil, err
+	}
+	return out[:outLen], nil
+}
+
+func (l *library) ExternalSign_ECC(s SessionHandle, alg uint, priv *ECCrefPrivateKey, data []byte) (*ECCSignature, error) {
+	f, err := l.f("SDF_ExternalSign_ECC")
+	if err != nil {
+		return nil, err
+	}
+	var cpriv C.ECCrefPrivateKey
+	cpriv.bits = C.uint(priv.Bits)
+	for i, b := range priv.K {
+		cpriv.K[i] = C.uchar(b)
+	}
+	var sig C.ECCSignature
+	err = check(C.sdf_ExternalSign_ECC(f, C.uintptr_t(s), C.uint(alg), &cpriv, ptr(data), C.uint(len(data)), &sig))
+	for i := range cpriv.K {
+		cpriv.K[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signatureFromC(&sig), nil
+}
+
+func (l *library) ExternalVerify_ECC(s SessionHandle, alg uint, pub *ECCrefPublicKey, data []byte, sig *ECCSignature) error {
+	f, err := l.f("SDF_ExternalVerify_ECC")
+	if err != nil {
+		return err
+	}
+	cpub := publicKeyToC(pub)
+	csig := signatureToC(sig)
+	return check(C.sdf_ExternalVerify_ECC(f, C.uintptr_t(s), C.uint(alg), &cpub, ptr(data), C.uint(len(data)), &csig))
+}
+
+func (l *library) ExternalEncrypt_ECC(s SessionHandle, alg uint, pub *ECCrefPublicKey, data []byte) (*ECCCipher, error) {
+	f, err := l.f("SDF_ExternalEncrypt_ECC")
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxPlaintext {
+		return nil, SDR_PKOPERR
+	}
+	cpub := publicKeyToC(pub)
+	cc := (*C.ECCCipher)(C.calloc(1, C.size_t(C.sizeof_ECCCipher+len(data))))
+	defer C.free(unsafe.Pointer(cc))
+	if err := check(C.sdf_ExternalEncrypt_ECC(f, C.uintptr_t(s), C.uint(alg), &cpub, ptr(data), C.uint(len(data)), cc)); err != nil {
+		return nil, err
+	}
+	return cipherFromC(cc)
+}
+
+func (l *library) GenerateKeyWithKEK(s SessionHandle, bits uint, alg uint, kekIndex uint) ([]byte, KeyHandle, error) {
+	f, err := l.f("SDF_GenerateKeyWithKEK")
+	if err != nil {
+		return nil, 0, err
+	}
+	// The wrapped key is at most a few blocks longer than the key.
+	wrapped := make([]byte, bits/8+4*sm4.BlockSize)
+	wrappedLen := C.uint(len(wrapped))
+	var k C.uintptr_t
+	if err := check(C.sdf_GenerateKeyWithKEK(f, C.uintptr_t(s), C.uint(bits), C.uint(alg), C.uint(kekIndex), ptr(wrapped), &wrappedLen, &k)); err != nil {
+		return nil, 0, err
+	}
+	return wrapped[:wrappedLen], KeyHandle(k), nil
+}
+
+func (l *library) ImportKeyWithKEK(s SessionHandle, alg uint, kekIndex uint, key []byte) (KeyHandle, error) {
+	f, err := l.f("SDF_ImportKeyWithKEK")
+	if err != nil {
+		return 0, err
+	}
+	var k C.uintptr_t
+	if err := check(C.sdf_ImportKeyWithKEK(f, C.uintptr_t(s), C.uint(alg), C.uint(kekIndex), ptr(key), C.uint(len(key)), &k)); err != nil {
+		return 0, err
+	}
+	return KeyHandle(k), nil
+}
+
+func (l *library) DestroyKey(s SessionHandle, k KeyHandle) error {
+	f, err := l.f("SDF_DestroyKey")
+	if err != nil {
+		return err
+	}
+	return check(C.sdf_DestroyKey(f, C.uintptr_t(s), C.uintptr_t(k)))
+}
+
+func (l *library) crypt(name string, s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error) {
+	f, err := l.f(name)
+	if err != nil {
+		return nil, err
+	}
+	var civ [sm4.BlockSize]byte
+	copy(civ[:], iv)
+	out := make([]byte, len(data)+sm4.BlockSize)
+	outLen := C.uint(len(out))
+	if err := check(C.sdf_Crypt(f, C.uintptr_t(s), C.uintptr_t(k), C.uint(alg), ptr(civ[:]), ptr(data), C.uint(len(data)), ptr(out), &outLen)); err != nil {
+		return nil, err
+	}
+	return out[:outLen], nil
+}
+
+func (l *library) Encrypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error) {
+	return l.crypt("SDF_Encrypt", s, k, alg, iv, data)
+}
+
+func (l *library) Decrypt(s SessionHandle, k KeyHandle, alg uint, iv, data []byte) ([]byte, error) {
+	return l.crypt("SDF_Decrypt", s, k, alg, iv, data)
+}
+
+func publicKeyFromC(c *C.ECCrefPublicKey) *ECCrefPublicKey {
+	pub := &ECCrefPublicKey{Bits: uint(c.bits)}
+	for i := range pub.X {
+		pub.X[i] = byte(c.x[i])
+		pub.Y[i] = byte(c.y[i])
+	}
+	return pub
+}
+
+func publicKeyToC(pub *ECCrefPublicKey) C.ECCrefPublicKey {
+	var c C.ECCrefPublicKey
+	c.bits = C.uint(pub.Bits)
+	for i := range pub.X {
+		c.x[i] = C.uchar(pub.X[i])
+		c.y[i] = C.uchar(pub.Y[i])
+	}
+	return c
+}
+
+func signatureFromC(c *C.ECCSignature) *ECCSignature {
+	sig := &ECCSignature{}
+	for i := range sig.R {
+		sig.R[i] = byte(c.r[i])
+		sig.S[i] = byte(c.s[i])
+	}
+	return sig
+}
+
+func signatureToC(sig *ECCSignature) C.ECCSignature {
+	var c C.ECCSignature
+	for i := range sig.R {
+		c.r[i] = C.uchar(sig.R[i])
+		c.s[i] = C.uchar(sig.S[i])
+	}
+	return c
+}
+
+// cipherToC allocates a C ECCCipher; the caller frees it.
+func cipherToC(c *ECCCipher) *C.ECCCipher {
+	cc := (*C.ECCCipher)(C.calloc(1, C.size_t(C.sizeof_ECCCipher+len(c.C))))
+	for i := range c.X {
+		cc.x[i] = C.uchar(c.X[i])
+		cc.y[i] = C.uchar(c.Y[i])
+	}
+	for i := range c.M {
+		cc.M[i] = C.uchar(c.M[i])
+	}
+	cc.L = C.uint(len(c.C))
+	copy((*[maxPlaintext]byte)(unsafe.Pointer(&cc.C[0]))[:len(c.C):len(c.C)], c.C)
+	return cc
+}
+
+func cipherFromC(cc *C.ECCCipher) (*ECCCipher, error) {
+	if cc.L > maxPlaintext {
+		return nil, SDR_PKOPERR
+	}
+	c := &ECCCipher{C: C.GoBytes(unsafe.Pointer(&cc.C[0]), C.int(cc.L))}
+	for i := range c.X {
+		c.X[i] = byte(cc.x[i])
+		c.Y[i] = byte(cc.y[i])
+	}
+	for i := range c.M {
+		c.M[i] = byte(cc.M[i])
+	}
+	return c, nil
+}
@@ -0,0 +1,81 @@
+//go:build cgo
+// +build cgo
+
+package sdf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	dir, err := ioutil.TempDir("", "sdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lib := filepath.Join(dir, "libfakesdf.so")
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", lib, "testdata/fakesdf.c").CombinedOutput(); err != nil {
+		t.Skipf("cannot build the stub library: %v\n%s", err, out)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.so")); err == nil {
+		t.Fatal("expected an error for a missing library")
+	}
+	d, err := Load(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	s, err := d.OpenSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := d.ExportSignPublicKey_ECC(s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.Bits != 256 || pub.X[63] != 0x11 || pub.Y[63] != 0x22 {
+		t.Fatalf("public key %+v", pub)
+	}
+	if _, err := d.ExportSignPublicKey_ECC(s, 2); err != SDR_KEYNOTEXIST {
+		t.Fatalf("expected SDR_KEYNOTEXIST, got %v", err)
+	}
+	if _, err := d.ExportEncPublicKey_ECC(s, 1); err != SDR_NOTSUPPORT {
+		t.Fatalf("expected SDR_NOTSUPPORT for a missing function, got %v", err)
+	}
+
+	digest := bytes.Repeat([]byte{0x5c}, 32)
+	sig, err := d.InternalSign_ECC(s, 9, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig.R[32:], digest) || sig.S[63] != 9 {
+		t.Fatalf("signature %+v", sig)
+	}
+
+	msg := []byte("a message longer than one byte")
+	c, err := d.ExternalEncrypt_ECC(s, SGD_SM2_3, pub, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.C) != len(msg) || c.X != pub.X || c.M[31] != 0xab {
+		t.Fatalf("ciphertext %+v", c)
+	}
+	pt, err := d.InternalDecrypt_ECC(s, 1, SGD_SM2_3, c)
+	if err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("InternalDecrypt_ECC returned %q, %v", pt, err)
+	}
+	if err := d.CloseSession(s); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package sdf
+
+import "errors"
+
+// Load needs cgo to open the SDF library.
+func Load(path string) (Device, error) {
+	return nil, errors.New("sdf: Load requires cgo")
+}
@@ -0,0 +1,208 @@
+package sdf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+func newMock(t *testing.T) *Mock {
+	m := NewMock()
+	if err := m.AddKeyPair(1, []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddKEK(3, bytes.Repeat([]byte{7}, sm4.KeySize)); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestKey(t *testing.T) {
+	m := newMock(t)
+	s, err := OpenSession(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Key(1, []byte("wrong")); err != SDR_PARDENY {
+		t.Fatalf("expected SDR_PARDENY, got %v", err)
+	}
+	if _, err := s.Key(2, []byte("password")); err != SDR_KEYNOTEXIST {
+		t.Fatalf("expected SDR_KEYNOTEXIST, got %v", err)
+	}
+	key, err := s.Key(1, []byte("password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(*sm2.PublicKey)
+	if pub.X.Cmp(m.pairs[1].sign.X) != 0 || key.EncryptionPublicKey().X.Cmp(m.pairs[1].enc.X) != 0 {
+		t.Fatal("wrong public keys")
+	}
+
+	digest := sm3.Sm3Sum([]byte("message"))
+	sig, err := key.Sign(nil, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Verify(digest, sig) {
+		t.Fatal("the signature does not verify")
+	}
+	if err := key.Verify(digest, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Verify(sm3.Sm3Sum([]byte("other")), sig); err != SDR_VERIFYERR {
+		t.Fatalf("expected SDR_VERIFYERR, got %v", err)
+	}
+	if _, err := key.Sign(nil, []byte("message"), nil); err != ErrDigestSize {
+		t.Fatalf("expected ErrDigestSize, got %v", err)
+	}
+
+	ct, err := sm2.Encrypt(key.EncryptionPublicKey(), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := key.Decrypt(ct)
+	if err != nil || string(pt) != "secret" {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+	if _, err := key.SharedSecret(pub); err != ErrKeyAgreement {
+		t.Fatalf("expected ErrKeyAgreement, got %v", err)
+	}
+
+	// Another session has no access right.
+	other, err := OpenSession(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.InternalSign_ECC(other.h, 1, digest); err != SDR_PARDENY {
+		t.Fatalf("expected SDR_PARDENY, got %v", err)
+	}
+	other.Close()
+
+	s.Close()
+	if _, err := key.Sign(nil, digest, nil); err != ErrSessionClosed {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestExternal(t *testing.T) {
+	s, err := OpenSession(newMock(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sm3.Sm3Sum([]byte("message"))
+	sig, err := s.ExternalSign(priv, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Verify(digest, sig) {
+		t.Fatal("the signature does not verify")
+	}
+	if err := s.ExternalVerify(&priv.PublicKey, digest, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ExternalVerify(&priv.PublicKey, digest, []byte("junk")); err != SDR_VERIFYERR {
+		t.Fatalf("expected SDR_VERIFYERR, got %v", err)
+	}
+
+	ct, err := s.ExternalEncrypt(&priv.PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := sm2.Decrypt(priv, ct)
+	if err != nil || string(pt) != "secret" {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+}
+
+func TestSM4Key(t *testing.T) {
+	s, err := OpenSession(newMock(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	key, wrapped, err := s.GenerateSM4Key(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := bytes.Repeat([]byte{1}, sm4.BlockSize)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3)
+	ct, err := key.Encrypt(SGD_SM4_CBC, iv, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(ct, data) {
+		t.Fatal("Encrypt returned the plaintext")
+	}
+
+	imported, err := s.ImportSM4Key(3, wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := imported.Decrypt(SGD_SM4_CBC, iv, ct)
+	if err != nil || !bytes.Equal(pt, data) {
+		t.Fatalf("Decrypt returned %x, %v", pt, err)
+	}
+	ecb, err := key.Encrypt(SGD_SM4_ECB, nil, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ecb[:16], ecb[16:32]) {
+		t.Fatal("SGD_SM4_ECB is not ECB")
+	}
+
+	if _, err := key.Encrypt(SGD_SM4_CBC, iv, data[1:]); err != ErrBlockAlignment {
+		t.Fatalf("expected ErrBlockAlignment, got %v", err)
+	}
+	if _, err := s.ImportSM4Key(4, wrapped); err != SDR_KEYNOTEXIST {
+		t.Fatalf("expected SDR_KEYNOTEXIST, got %v", err)
+	}
+	if err := key.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.Encrypt(SGD_SM4_ECB, nil, data); err != SDR_KEYNOTEXIST {
+		t.Fatalf("expected SDR_KEYNOTEXIST after Destroy, got %v", err)
+	}
+}
+
+func TestConversions(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := PublicKeyFromRef(PublicKeyToRef(&priv.PublicKey))
+	if err != nil || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+		t.Fatal("public key round trip failed")
+	}
+	bad := PublicKeyToRef(&priv.PublicKey)
+	bad.Y[63] ^= 1
+	if _, err := PublicKeyFromRef(bad); err != SDR_KEYERR {
+		t.Fatalf("expected SDR_KEYERR, got %v", err)
+	}
+
+	ct, err := sm2.Encrypt(&priv.PublicKey, []byte("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := CipherFromBytes(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(CipherToBytes(c), ct) {
+		t.Fatal("ciphertext round trip failed")
+	}
+	if SDR_PARDENY.Error() != "sdf: no access right to the private key" || Error(0x42).Error() != "sdf: error 0x00000042" {
+		t.Fatal("wrong error strings")
+	}
+}
@@ -0,0 +1,314 @@
+package sdf
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+var (
+	ErrDigestSize     = errors.New("sdf: SM2 signing takes an SM3 digest")
+	ErrKeyAgreement   = errors.New("sdf: raw SM2 key agreement is not part of the SDF interface")
+	ErrSessionClosed  = errors.New("sdf: session closed")
+	ErrBlockAlignment = errors.New("sdf: input not a multiple of the block size")
+)
+
+// Session is a session with a device. SDF sessions run one operation at a
+// time, so the keys of a Session serialize their operations; open several
+// sessions for parallelism.
+type Session struct {
+	mu     sync.Mutex
+	d      Device
+	h      SessionHandle
+	rights []uint
+	closed bool
+}
+
+// OpenSession opens a session with the device.
+func OpenSession(d Device) (*Session, error) {
+	h, err := d.OpenSession()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{d: d, h: h}, nil
+}
+
+// Close releases the private key access rights obtained by Key and closes
+// the session.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	for _, index := range s.rights {
+		s.d.ReleasePrivateKeyAccessRight(s.h, index)
+	}
+	s.rights, s.closed = nil, true
+	return s.d.CloseSession(s.h)
+}
+
+// Key obtains the access right to the private keys at the index with the
+// password and returns them as an sm2.OpaqueSigner.
+func (s *Session) Key(index uint, password []byte) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	signRef, err := s.d.ExportSignPublicKey_ECC(s.h, index)
+	if err != nil {
+		return nil, err
+	}
+	signPub, err := PublicKeyFromRef(signRef)
+	if err != nil {
+		return nil, err
+	}
+	encRef, err := s.d.ExportEncPublicKey_ECC(s.h, index)
+	if err != nil {
+		return nil, err
+	}
+	encPub, err := PublicKeyFromRef(encRef)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.d.GetPrivateKeyAccessRight(s.h, index, password); err != nil {
+		return nil, err
+	}
+	s.rights = append(s.rights, index)
+	return &Key{s: s, index: index, signPub: signPub, encPub: encPub}, nil
+}
+
+// ExternalSign signs the SM3 digest with a private key of the caller and
+// returns the ASN.1 signature.
+func (s *Session) ExternalSign(priv *sm2.PrivateKey, digest []byte) ([]byte, error) {
+	if len(digest) != sm3.Size {
+		return nil, ErrDigestSize
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	sig, err := s.d.ExternalSign_ECC(s.h, SGD_SM2_1, PrivateKeyToRef(priv), digest)
+	if err != nil {
+		return nil, err
+	}
+	return SignatureToASN1(sig)
+}
+
+// ExternalVerify verifies the ASN.1 signature of the SM3 digest under pub.
+// It returns nil for a valid signature.
+func (s *Session) ExternalVerify(pub *sm2.PublicKey, digest, sig []byte) error {
+	ref, err := SignatureFromASN1(sig)
+	if err != nil {
+		return SDR_VERIFYERR
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSessionClosed
+	}
+	return s.d.ExternalVerify_ECC(s.h, SGD_SM2_1, PublicKeyToRef(pub), digest, ref)
+}
+
+// ExternalEncrypt encrypts data to pub in the format of sm2.Encrypt.
+func (s *Session) ExternalEncrypt(pub *sm2.PublicKey, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	c, err := s.d.ExternalEncrypt_ECC(s.h, SGD_SM2_3, PublicKeyToRef(pub), data)
+	if err != nil {
+		return nil, err
+	}
+	return CipherToBytes(c), nil
+}
+
+// Key is an SM2 key pair stored in a device. It implements
+// sm2.OpaqueSigner.
+type Key struct {
+	s       *Session
+	index   uint
+	signPub *sm2.PublicKey
+	encPub  *sm2.PublicKey
+}
+
+var _ sm2.OpaqueSigner = (*Key)(nil)
+
+// Public returns the *sm2.PublicKey of the signing key pair.
+func (k *Key) Public() crypto.PublicKey {
+	return k.signPub
+}
+
+// EncryptionPublicKey returns the public key of the encryption key pair,
+// which ciphertexts for Decrypt must be encrypted to.
+func (k *Key) EncryptionPublicKey() *sm2.PublicKey {
+	return k.encPub
+}
+
+// Sign signs the SM3 digest e = SM3(ZA || M) in the device and returns the
+// ASN.1 signature. The device draws the nonce; rand and opts are ignored.
+func (k *Key) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	sig, err := k.sign(digest)
+	audit.Record(audit.OpSign, audit.BackendSDF, k.signPub.X, k.signPub.Y, err)
+	return sig, err
+}
+
+func (k *Key) sign(digest []byte) ([]byte, error) {
+	if len(digest) != sm3.Size {
+		return nil, ErrDigestSize
+	}
+
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	if k.s.closed {
+		return nil, ErrSessionClosed
+	}
+	sig, err := k.s.d.InternalSign_ECC(k.s.h, k.index, digest)
+	if err != nil {
+		return nil, err
+	}
+	return SignatureToASN1(sig)
+}
+
+// Verify verifies the ASN.1 signature of the SM3 digest in the device. It
+// returns nil for a valid signature.
+func (k *Key) Verify(digest, sig []byte) error {
+	ref, err := SignatureFromASN1(sig)
+	if err != nil {
+		return SDR_VERIFYERR
+	}
+
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	if k.s.closed {
+		return ErrSessionClosed
+	}
+	return k.s.d.InternalVerify_ECC(k.s.h, k.index, digest, ref)
+}
+
+// Decrypt decrypts, in the device, a ciphertext of sm2.Encrypt to the
+// encryption public key.
+func (k *Key) Decrypt(ciphertext []byte) ([]byte, error) {
+	pt, err := k.decrypt(ciphertext)
+	audit.Record(audit.OpDecrypt, audit.BackendSDF, k.encPub.X, k.encPub.Y, err)
+	return pt, err
+}
+
+func (k *Key) decrypt(ciphertext []byte) ([]byte, error) {
+	c, err := CipherFromBytes(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	if k.s.closed {
+		return nil, ErrSessionClosed
+	}
+	return k.s.d.InternalDecrypt_ECC(k.s.h, k.index, SGD_SM2_3, c)
+}
+
+// SharedSecret always fails: the SDF interface only offers the complete
+// SM2 key exchange protocol, whose secret never leaves the device.
+func (k *Key) SharedSecret(peer *sm2.PublicKey) ([]byte, error) {
+	return nil, ErrKeyAgreement
+}
+
+// SM4Key is an SM4 session key held by a device.
+type SM4Key struct {
+	s *Session
+	h KeyHandle
+}
+
+// GenerateSM4Key generates an SM4 key in the device and returns its handle
+// and the key encrypted under the internal key encryption key kekIndex,
+// for later use with ImportSM4Key.
+func (s *Session) GenerateSM4Key(kekIndex uint) (*SM4Key, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, nil, ErrSessionClosed
+	}
+	wrapped, h, err := s.d.GenerateKeyWithKEK(s.h, 8*sm4.KeySize, SGD_SM4_ECB, kekIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &SM4Key{s: s, h: h}, wrapped, nil
+}
+
+// ImportSM4Key imports an SM4 key encrypted under the internal key
+// encryption key kekIndex.
+func (s *Session) ImportSM4Key(kekIndex uint, wrapped []byte) (*SM4Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	h, err := s.d.ImportKeyWithKEK(s.h, SGD_SM4_ECB, kekIndex, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return &SM4Key{s: s, h: h}, nil
+}
+
+// Encrypt encrypts data, a multiple of the block size, with SGD_SM4_ECB or
+// SGD_SM4_CBC; iv is ignored for ECB.
+func (k *SM4Key) Encrypt(alg uint, iv, data []byte) ([]byte, error) {
+	return k.crypt(k.s.d.Encrypt, alg, iv, data)
+}
+
+// Decrypt is the inverse of Encrypt.
+func (k *SM4Key) Decrypt(alg uint, iv, data []byte) ([]byte, error) {
+	return k.crypt(k.s.d.Decrypt, alg, iv, data)
+}
+
+func (k *SM4Key) crypt(f func(SessionHandle, KeyHandle, uint, []byte, []byte) ([]byte, error), alg uint, iv, data []byte) ([]byte, error) {
+	if len(data)%sm4.BlockSize != 0 {
+		return nil, ErrBlockAlignment
+	}
+	if alg == SGD_SM4_CBC && len(iv) != sm4.BlockSize {
+		return nil, SDR_ALGMODNOTSUPPORT
+	}
+	// Devices update the IV in place.
+	iv = append([]byte(nil), iv...)
+
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	if k.s.closed {
+		return nil, ErrSessionClosed
+	}
+	return f(k.s.h, k.h, alg, iv, data)
+}
+
+// Destroy destroys the key in the device.
+func (k *SM4Key) Destroy() error {
+	k.s.mu.Lock()
+	defer k.s.mu.Unlock()
+
+	if k.s.closed {
+		return ErrSessionClosed
+	}
+	return k.s.d.DestroyKey(k.s.h, k.h)
+}
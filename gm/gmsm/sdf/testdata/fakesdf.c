@@ -0,0 +1,49 @@
+// A stub SDF library for the tests of the cgo binding: it checks the
+// calling convention and the structure layouts, not cryptography.
+#include <string.h>
+
+typedef struct { unsigned int bits; unsigned char x[64]; unsigned char y[64]; } ECCrefPublicKey;
+typedef struct { unsigned char r[64]; unsigned char s[64]; } ECCSignature;
+typedef struct { unsigned char x[64]; unsigned char y[64]; unsigned char M[32]; unsigned int L; unsigned char C[1]; } ECCCipher;
+
+static int device, session;
+
+int SDF_OpenDevice(void **h) { *h = &device; return 0; }
+int SDF_CloseDevice(void *h) { return h == &device ? 0 : 0x01000001; }
+int SDF_OpenSession(void *h, void **s) { *s = &session; return h == &device ? 0 : 0x01000001; }
+int SDF_CloseSession(void *s) { return s == &session ? 0 : 0x01000001; }
+
+int SDF_ExportSignPublicKey_ECC(void *s, unsigned int index, ECCrefPublicKey *pub) {
+	if (index != 1) return 0x01000008;
+	pub->bits = 256;
+	memset(pub->x, 0, 64);
+	memset(pub->y, 0, 64);
+	pub->x[63] = 0x11;
+	pub->y[63] = 0x22;
+	return 0;
+}
+
+int SDF_InternalSign_ECC(void *s, unsigned int index, unsigned char *data, unsigned int len, ECCSignature *sig) {
+	memset(sig, 0, sizeof(*sig));
+	memcpy(sig->r + 32, data, len < 32 ? len : 32);
+	sig->s[63] = (unsigned char)index;
+	return 0;
+}
+
+int SDF_ExternalEncrypt_ECC(void *s, unsigned int alg, ECCrefPublicKey *pub, unsigned char *data, unsigned int len, ECCCipher *c) {
+	unsigned int i;
+	memcpy(c->x, pub->x, 64);
+	memcpy(c->y, pub->y, 64);
+	memset(c->M, 0xab, 32);
+	c->L = len;
+	for (i = 0; i < len; i++) c->C[i] = data[i] ^ 0xff;
+	return 0;
+}
+
+int SDF_InternalDecrypt_ECC(void *s, unsigned int index, unsigned int alg, ECCCipher *c, unsigned char *out, unsigned int *outLen) {
+	unsigned int i;
+	if (c->M[0] != 0xab || *outLen < c->L) return 0x0100000C;
+	for (i = 0; i < c->L; i++) out[i] = c->C[i] ^ 0xff;
+	*outLen = c->L;
+	return 0;
+}
@@ -0,0 +1,67 @@
+// Package secmem holds secrets, such as private scalars or SM4 keys, in
+// the manner of memguard: a Buffer lives outside the Go heap, so the
+// garbage collector never copies it, is locked into RAM, so it is never
+// swapped to disk, and is overwritten when destroyed.
+//
+// Keep the bytes of long-lived key material in a Buffer, and destroy the
+// values built from it, e.g. with sm2.PrivateKey.Destroy, after each use.
+package secmem
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+var (
+	ErrUnsupported = errors.New("secmem: locked memory is not supported on this platform")
+	ErrDestroyed   = errors.New("secmem: buffer destroyed")
+)
+
+// Buffer is a fixed-size buffer of locked memory.
+type Buffer struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+// New allocates a zeroed buffer of size bytes and locks it into RAM. It
+// fails if the memory cannot be locked, e.g. beyond RLIMIT_MEMLOCK, rather
+// than return unprotected memory.
+func New(size int) (*Buffer, error) {
+	if size <= 0 {
+		return nil, errors.New("secmem: invalid size")
+	}
+	b, err := alloc(size)
+	if err != nil {
+		return nil, err
+	}
+	buf := &Buffer{b: b}
+	// Memory outside the heap is not freed with the Buffer.
+	runtime.SetFinalizer(buf, (*Buffer).Destroy)
+	return buf, nil
+}
+
+// Bytes returns the memory of the buffer, which is valid until Destroy.
+// Do not copy secrets out of it into ordinary slices.
+func (b *Buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b
+}
+
+// Destroy overwrites, unlocks and frees the memory. Destroying a buffer
+// twice returns ErrDestroyed.
+func (b *Buffer) Destroy() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.b == nil {
+		return ErrDestroyed
+	}
+	for i := range b.b {
+		b.b[i] = 0
+	}
+	err := free(b.b)
+	b.b = nil
+	runtime.SetFinalizer(b, nil)
+	return err
+}
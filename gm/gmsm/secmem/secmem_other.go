@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package secmem
+
+func alloc(size int) ([]byte, error) {
+	return nil, ErrUnsupported
+}
+
+func free(b []byte) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,38 @@
+package secmem
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	buf, err := New(32)
+	if err == ErrUnsupported {
+		t.Skip(err)
+	}
+	if err == syscall.EPERM || err == syscall.ENOMEM || err == syscall.EAGAIN {
+		t.Skipf("cannot lock memory: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if len(b) != 32 {
+		t.Fatalf("%d bytes", len(b))
+	}
+	for i := range b {
+		b[i] = byte(i)
+	}
+	if err := buf.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Bytes() != nil {
+		t.Fatal("the memory is still reachable")
+	}
+	if err := buf.Destroy(); err != ErrDestroyed {
+		t.Fatalf("expected ErrDestroyed, got %v", err)
+	}
+	if _, err := New(0); err == nil {
+		t.Fatal("a zero-sized buffer was allocated")
+	}
+}
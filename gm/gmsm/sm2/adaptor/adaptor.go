@@ -0,0 +1,199 @@
+// Package adaptor implements Schnorr adaptor signatures over the SM2 curve,
+// the building block of scriptless atomic swaps.
+//
+// An adaptor signature, or pre-signature, is a signature encrypted to an
+// adaptor point T = t·G: anyone can check that it becomes a valid signature
+// once adapted with the secret t, and whoever sees both the pre-signature
+// and the adapted signature learns t. In a swap, both parties pre-sign their
+// transactions under the same T; publishing one adapted signature reveals
+// the t that completes the other.
+//
+// Signatures are c || s with c = H(X || R || m) and R = s·G - c·X, where H
+// is a tagged SM3 hash and points are in compressed SEC 1 form. A
+// pre-signature c || ŝ under T has c = H(X || R + T || m) with
+// R = ŝ·G - c·X, and adapts to c || ŝ + t.
+package adaptor
+
+import (
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	// SignatureSize is the size of a signature in bytes.
+	SignatureSize = 64
+	// PresignatureSize is the size of a pre-signature in bytes.
+	PresignatureSize = 64
+	// PointSize is the size of an adaptor point in bytes.
+	PointSize = 33
+	// SecretSize is the size of an adaptor secret in bytes.
+	SecretSize = 32
+)
+
+var (
+	errInvalidKey = errors.New("adaptor: invalid key")
+	errMalformed  = errors.New("adaptor: malformed input")
+	// ErrMismatch is returned by Extract when the signature was not adapted
+	// from the pre-signature with the secret of the adaptor point.
+	ErrMismatch = errors.New("adaptor: secret or signature does not match")
+)
+
+func publicPoint(pub *sm2.PublicKey) (*ecpoint.Point, error) {
+	p, ok := ecpoint.FromPublicKey(pub)
+	if !ok {
+		return nil, errInvalidKey
+	}
+	return p, nil
+}
+
+// challenge returns H(X || R || m).
+func challenge(pub, r *ecpoint.Point, msg []byte) *big.Int {
+	t := sm3.Sm3Sum([]byte("Adaptor/challenge"))
+	h := sm3.New()
+	h.Write(t)
+	h.Write(t)
+	h.Write(pub.Compress())
+	h.Write(r.Compress())
+	h.Write(msg)
+	e := new(big.Int).SetBytes(h.Sum(nil))
+	return e.Mod(e, ecpoint.Order())
+}
+
+// parseSignature splits a signature or pre-signature into c and s.
+func parseSignature(sig []byte) (c, s *big.Int, err error) {
+	if len(sig) != SignatureSize {
+		return nil, nil, errMalformed
+	}
+	if c, err = ecpoint.ParseScalar(sig[:32]); err != nil {
+		return nil, nil, errMalformed
+	}
+	if s, err = ecpoint.ParseScalar(sig[32:]); err != nil {
+		return nil, nil, errMalformed
+	}
+	return c, s, nil
+}
+
+// nonce returns s·G - c·X.
+func nonce(x *ecpoint.Point, c, s *big.Int) *ecpoint.Point {
+	return ecpoint.BaseMul(s).Add(x.Mul(new(big.Int).Sub(ecpoint.Order(), c)))
+}
+
+// GenerateSecret returns a random adaptor secret t and its adaptor point
+// T = t·G.
+func GenerateSecret(rand io.Reader) (secret, adaptorPoint []byte, err error) {
+	t, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ecpoint.ScalarBytes(t), ecpoint.BaseMul(t).Compress(), nil
+}
+
+// Presign returns a pre-signature of msg by priv under adaptorPoint.
+func Presign(rand io.Reader, priv *sm2.PrivateKey, adaptorPoint, msg []byte) ([]byte, error) {
+	if priv == nil || priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(ecpoint.Order()) >= 0 {
+		return nil, errInvalidKey
+	}
+	t, err := ecpoint.Decompress(adaptorPoint, false)
+	if err != nil {
+		return nil, errMalformed
+	}
+	x := ecpoint.BaseMul(priv.D)
+	for {
+		k, err := ecpoint.RandScalar(rand)
+		if err != nil {
+			return nil, err
+		}
+		r := ecpoint.BaseMul(k).Add(t)
+		if r.IsInfinity() {
+			continue
+		}
+		// ŝ = k + c·x
+		c := challenge(x, r, msg)
+		s := new(big.Int).Mul(c, priv.D)
+		s.Add(s, k)
+		s.Mod(s, ecpoint.Order())
+		return append(ecpoint.ScalarBytes(c), ecpoint.ScalarBytes(s)...), nil
+	}
+}
+
+// VerifyPresignature reports whether presig is a valid pre-signature of msg
+// under pub and adaptorPoint, that is, whether adapting it with the secret
+// of adaptorPoint yields a valid signature.
+func VerifyPresignature(pub *sm2.PublicKey, adaptorPoint, msg, presig []byte) bool {
+	x, err := publicPoint(pub)
+	if err != nil {
+		return false
+	}
+	t, err := ecpoint.Decompress(adaptorPoint, false)
+	if err != nil {
+		return false
+	}
+	c, s, err := parseSignature(presig)
+	if err != nil {
+		return false
+	}
+	r := nonce(x, c, s).Add(t)
+	if r.IsInfinity() {
+		return false
+	}
+	return challenge(x, r, msg).Cmp(c) == 0
+}
+
+// Adapt completes a pre-signature with the adaptor secret.
+func Adapt(presig, secret []byte) ([]byte, error) {
+	c, s, err := parseSignature(presig)
+	if err != nil {
+		return nil, err
+	}
+	t, err := ecpoint.ParseScalar(secret)
+	if err != nil {
+		return nil, errMalformed
+	}
+	s.Add(s, t)
+	s.Mod(s, ecpoint.Order())
+	return append(ecpoint.ScalarBytes(c), ecpoint.ScalarBytes(s)...), nil
+}
+
+// Extract returns the adaptor secret from a pre-signature and the signature
+// adapted from it.
+func Extract(sig, presig, adaptorPoint []byte) ([]byte, error) {
+	c1, s1, err := parseSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	c2, s2, err := parseSignature(presig)
+	if err != nil {
+		return nil, err
+	}
+	if c1.Cmp(c2) != 0 {
+		return nil, ErrMismatch
+	}
+	t := new(big.Int).Sub(s1, s2)
+	t.Mod(t, ecpoint.Order())
+	if string(ecpoint.BaseMul(t).Compress()) != string(adaptorPoint) {
+		return nil, ErrMismatch
+	}
+	return ecpoint.ScalarBytes(t), nil
+}
+
+// Verify reports whether sig is a valid signature of msg under pub.
+func Verify(pub *sm2.PublicKey, msg, sig []byte) bool {
+	x, err := publicPoint(pub)
+	if err != nil {
+		return false
+	}
+	c, s, err := parseSignature(sig)
+	if err != nil {
+		return false
+	}
+	r := nonce(x, c, s)
+	if r.IsInfinity() {
+		return false
+	}
+	return challenge(x, r, msg).Cmp(c) == 0
+}
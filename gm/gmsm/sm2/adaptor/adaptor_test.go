@@ -0,0 +1,94 @@
+package adaptor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestAdaptorSignature(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	secret, T, err := GenerateSecret(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("pay 1 coin to bob")
+	pre, err := Presign(rand.Reader, priv, T, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyPresignature(&priv.PublicKey, T, msg, pre) {
+		t.Fatal("pre-signature does not verify")
+	}
+	if Verify(&priv.PublicKey, msg, pre) {
+		t.Fatal("pre-signature verifies as a signature")
+	}
+	_, T2, _ := GenerateSecret(rand.Reader)
+	if VerifyPresignature(&priv.PublicKey, T2, msg, pre) {
+		t.Fatal("pre-signature verifies under another adaptor point")
+	}
+
+	sig, err := Adapt(pre, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(&priv.PublicKey, msg, sig) {
+		t.Fatal("adapted signature does not verify")
+	}
+	got, err := Extract(sig, pre, T)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Fatal("extracted secret does not match")
+	}
+	if _, err := Extract(sig, pre, T2); err != ErrMismatch {
+		t.Fatalf("got %v, want ErrMismatch", err)
+	}
+}
+
+// TestAtomicSwap runs the swap protocol: Alice knows t and Bob only T. Both
+// pre-sign under T; Alice claims Bob's payment by publishing the adapted
+// signature, from which Bob extracts t and claims Alice's payment.
+func TestAtomicSwap(t *testing.T) {
+	alice, _ := sm2.GenerateKey()
+	bob, _ := sm2.GenerateKey()
+	secret, T, _ := GenerateSecret(rand.Reader)
+	toBob := []byte("alice pays bob on chain A")
+	toAlice := []byte("bob pays alice on chain B")
+
+	preA, _ := Presign(rand.Reader, alice, T, toBob)
+	preB, _ := Presign(rand.Reader, bob, T, toAlice)
+	if !VerifyPresignature(&alice.PublicKey, T, toBob, preA) ||
+		!VerifyPresignature(&bob.PublicKey, T, toAlice, preB) {
+		t.Fatal("pre-signature does not verify")
+	}
+
+	sigB, _ := Adapt(preB, secret)
+	if !Verify(&bob.PublicKey, toAlice, sigB) {
+		t.Fatal("Alice's claim does not verify")
+	}
+	learned, err := Extract(sigB, preB, T)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigA, _ := Adapt(preA, learned)
+	if !Verify(&alice.PublicKey, toBob, sigA) {
+		t.Fatal("Bob's claim does not verify")
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	if _, err := Presign(rand.Reader, priv, make([]byte, PointSize), nil); err == nil {
+		t.Fatal("accepted the point at infinity as adaptor point")
+	}
+	if _, err := Adapt(make([]byte, PresignatureSize-1), make([]byte, SecretSize)); err == nil {
+		t.Fatal("accepted a short pre-signature")
+	}
+	if Verify(&priv.PublicKey, nil, bytes.Repeat([]byte{0xff}, SignatureSize)) {
+		t.Fatal("accepted out of range scalars")
+	}
+}
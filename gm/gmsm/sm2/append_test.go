@@ -0,0 +1,76 @@
+package sm2
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+)
+
+func TestAppendEncryptDecrypt(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	msg := []byte("append to a shared buffer")
+	prefix := []byte("prefix")
+
+	for _, dst := range [][]byte{prefix, append(make([]byte, 0, 256), prefix...)} {
+		ct, err := AppendEncrypt(dst, pub, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ct[:len(prefix)], prefix) || len(ct) != len(prefix)+97+len(msg) {
+			t.Fatalf("AppendEncrypt(cap %d) = %x", cap(dst), ct)
+		}
+		if pt, err := Decrypt(priv, ct[len(prefix):]); err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("Decrypt of AppendEncrypt: %q, %v", pt, err)
+		}
+
+		pt, err := AppendDecrypt(dst, priv, ct[len(prefix):])
+		if err != nil || !bytes.Equal(pt, append(append([]byte(nil), prefix...), msg...)) {
+			t.Fatalf("AppendDecrypt(cap %d) = %q, %v", cap(dst), pt, err)
+		}
+	}
+
+	ct, _ := Encrypt(pub, msg)
+	ct[len(ct)-1] ^= 1
+	buf := append(make([]byte, 0, 256), prefix...)
+	if out, err := AppendDecrypt(buf, priv, ct); err == nil || !bytes.Equal(out, prefix) {
+		t.Fatalf("modified ciphertext: %q, %v", out, err)
+	}
+	if !bytes.Equal(buf[:cap(buf)][len(prefix):len(prefix)+len(msg)], make([]byte, len(msg))) {
+		t.Fatal("unauthenticated plaintext left in dst")
+	}
+	if _, err := Decrypt(priv, ct[:96]); err == nil {
+		t.Fatal("short ciphertext decrypted")
+	}
+}
+
+// TestAppendEncryptMemory checks that encrypting into a buffer with room
+// allocates independently of the message length.
+func TestAppendEncryptMemory(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, 1<<20)
+	dst := make([]byte, 0, 97+len(msg))
+	pt := make([]byte, 0, len(msg))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 4; i++ {
+		ct, err := AppendEncrypt(dst, &priv.PublicKey, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := AppendDecrypt(pt, priv, ct); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if perCall := (after.TotalAlloc - before.TotalAlloc) / 8; perCall > 64<<10 {
+		t.Fatalf("%d bytes allocated per call for a 1 MB message", perCall)
+	}
+}
@@ -0,0 +1,61 @@
+package sm2
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func TestAuditHook(t *testing.T) {
+	var ops []audit.Operation
+	var events []*audit.Event
+	audit.SetHook(audit.HookFunc(func(e *audit.Event) {
+		ops = append(ops, e.Op)
+		events = append(events, e)
+	}))
+	defer audit.SetHook(nil)
+
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := priv.Sign(nil, sm3.Sm3Sum([]byte("message")), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Sm2Sign(priv, []byte("message"), nil); err != nil {
+		t.Fatal(err)
+	}
+	ct, err := Encrypt(&priv.PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := priv.Decrypt(ct); err != nil {
+		t.Fatal(err)
+	}
+	ct[len(ct)-1] ^= 1
+	if _, err := Decrypt(priv, ct); err == nil {
+		t.Fatal("a corrupt ciphertext decrypted")
+	}
+	if _, err := MarshalSm2PrivateKey(priv, []byte("password")); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []audit.Operation{audit.OpGenerateKey, audit.OpSign, audit.OpSign, audit.OpDecrypt, audit.OpDecrypt, audit.OpExportKey}
+	if len(ops) != len(want) {
+		t.Fatalf("recorded %v, want %v", ops, want)
+	}
+	id := audit.KeyID(priv.X, priv.Y)
+	for i, e := range events {
+		if e.Op != want[i] || e.KeyID != id || e.Backend != audit.BackendSoftware {
+			t.Fatalf("event %d: %+v", i, e)
+		}
+		if !strings.Contains(e.Caller, "TestAuditHook") {
+			t.Fatalf("event %d: caller %q", i, e.Caller)
+		}
+		if (e.Err != nil) != (i == 4) {
+			t.Fatalf("event %d: result %v", i, e.Err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package sm2
+
+import (
+	"context"
+	"io"
+	"math/big"
+)
+
+// batchRandomizerBits is the size of the random coefficients of a batch. A
+// batch containing an invalid signature passes with probability 2^-128.
+const batchRandomizerBits = 128
+
+// BatchVerifier verifies many SM2 signatures, possibly under different
+// public keys, with one multi-scalar multiplication instead of one
+// verification each.
+//
+// An SM2 signature (r, s) determines the point (x1, y1) = s·G + (r+s)·P only
+// up to the sign of y1, so the batch needs the parity of y1, as returned by
+// Sm2SignWithParity. For the signatures queued with it, the batch checks
+//
+//	Σ a_i·s_i·G + Σ a_i·(r_i+s_i)·P_i - Σ a_i·(x1_i, y1_i) = O
+//
+// for random 128-bit a_i. Signatures queued without parity are verified on
+// their own. If the check fails, every signature is verified on its own to
+// find the invalid ones; the parity is only a hint, so a valid signature
+// with a wrong parity slows the batch down but is not reported.
+type BatchVerifier struct {
+	rand    io.Reader
+	entries []batchEntry
+}
+
+type batchEntry struct {
+	pub    *PublicKey
+	msg    []byte
+	uid    []byte
+	r, s   *big.Int
+	parity uint
+	// hasParity is false for entries queued by AddWithoutParity.
+	hasParity bool
+}
+
+// NewBatchVerifier returns an empty batch that draws its random coefficients
+// from rand, or from RandSource() if rand is nil.
+func NewBatchVerifier(rand io.Reader) *BatchVerifier {
+	return &BatchVerifier{rand: rand}
+}
+
+// Add queues the signature (r, s) of msg by pub for the user uid, with the
+// parity of y1 as returned by Sm2SignWithParity.
+func (b *BatchVerifier) Add(pub *PublicKey, msg, uid []byte, r, s *big.Int, parity uint) {
+	b.entries = append(b.entries, batchEntry{pub, msg, uid, r, s, parity, true})
+}
+
+// AddWithoutParity queues the signature (r, s) of msg by pub for the user
+// uid, as returned by Sm2Sign or decoded from a format without the parity.
+// It is verified on its own rather than in the batch.
+func (b *BatchVerifier) AddWithoutParity(pub *PublicKey, msg, uid []byte, r, s *big.Int) {
+	b.entries = append(b.entries, batchEntry{pub: pub, msg: msg, uid: uid, r: r, s: s})
+}
+
+// AddSignature queues sig as AddWithoutParity does, since V is 0 both for a
+// parity of 0 and for signatures decoded from FormatDER or FormatRaw. Pass
+// the V of a FormatRecoverable signature to Add to have it batched.
+func (b *BatchVerifier) AddSignature(pub *PublicKey, msg, uid []byte, sig *Signature) {
+	b.AddWithoutParity(pub, msg, uid, sig.R, sig.S)
+}
+
+// Len returns the number of queued signatures.
+func (b *BatchVerifier) Len() int {
+	return len(b.entries)
+}
+
+// Verify reports whether all queued signatures are valid. If not, invalid
+// lists the indices, in the order they were queued, of the invalid
+// signatures.
+func (b *BatchVerifier) Verify() (valid bool, invalid []int) {
+	valid, invalid, _ = b.VerifyContext(context.Background())
+	return valid, invalid
+}
+
+// VerifyContext is Verify for large batches: it stops, returning ctx.Err(),
+// once ctx is done. ctx is checked between signatures, so a cancellation
+// takes effect after at most one verification.
+func (b *BatchVerifier) VerifyContext(ctx context.Context) (valid bool, invalid []int, err error) {
+	c := P256Sm2()
+	params := c.Params()
+	n := params.N
+
+	// Terms of the multi-scalar multiplication; the coefficients of repeated
+	// public keys are merged.
+	var xs, ys, ks []*big.Int
+	keys := make(map[string]int)
+	sumS := new(big.Int)
+	for i := range b.entries {
+		if err := ctx.Err(); err != nil {
+			return false, nil, err
+		}
+		e := &b.entries[i]
+		if !e.hasParity {
+			if !e.verify() {
+				invalid = append(invalid, i)
+			}
+			continue
+		}
+		t, rx, ok := e.prepare()
+		if !ok {
+			invalid = append(invalid, i)
+			continue
+		}
+		a, err := b.randomizer()
+		if err != nil {
+			return b.verifyEach(ctx)
+		}
+		sumS.Add(sumS, new(big.Int).Mul(a, e.s))
+
+		at := t.Mul(t, a)
+		key := string(e.pub.X.Bytes()) + "|" + string(e.pub.Y.Bytes())
+		if j, ok := keys[key]; ok {
+			ks[j].Add(ks[j], at)
+		} else {
+			keys[key] = len(xs)
+			xs, ys, ks = append(xs, e.pub.X), append(ys, e.pub.Y), append(ks, at)
+		}
+
+		// -a·R = a·(-R)
+		ry := rx.y
+		xs, ys, ks = append(xs, rx.x), append(ys, ry.Sub(params.P, ry)), append(ks, a)
+	}
+	if len(xs) == 0 {
+		return len(invalid) == 0, invalid, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, nil, err
+	}
+
+	for _, k := range ks {
+		k.Mod(k, n)
+	}
+	x, y := multiScalarMult(xs, ys, ks)
+	if sumS.Mod(sumS, n).Sign() != 0 {
+		gx, gy := c.ScalarBaseMult(sumS.Bytes())
+		x, y = params.Add(x, y, gx, gy)
+	}
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return len(invalid) == 0, invalid, nil
+	}
+	return b.verifyEach(ctx)
+}
+
+type affinePoint struct {
+	x, y *big.Int
+}
+
+// prepare checks the ranges of the entry and returns t = r + s and the point
+// (x1, y1) with x1 = r - e.
+func (e *batchEntry) prepare() (t *big.Int, r1 *affinePoint, ok bool) {
+	c := P256Sm2()
+	params := c.Params()
+	n := params.N
+	if e.pub == nil || e.pub.X == nil || e.pub.Y == nil || !c.IsOnCurve(e.pub.X, e.pub.Y) {
+		return nil, nil, false
+	}
+	if e.r == nil || e.s == nil || e.r.Sign() <= 0 || e.s.Sign() <= 0 || e.r.Cmp(n) >= 0 || e.s.Cmp(n) >= 0 {
+		return nil, nil, false
+	}
+	t = new(big.Int).Add(e.r, e.s)
+	if t.Mod(t, n).Sign() == 0 {
+		return nil, nil, false
+	}
+	za, err := ZA(e.pub, e.uid)
+	if err != nil {
+		return nil, nil, false
+	}
+	h, _ := msgHash(za, e.msg)
+	x1 := new(big.Int).Sub(e.r, h)
+	x1.Mod(x1, n)
+	y1 := liftX(x1, e.parity)
+	if y1 == nil {
+		return nil, nil, false
+	}
+	return t, &affinePoint{x1, y1}, true
+}
+
+// liftX returns the y-coordinate with the given parity of the point with
+// x-coordinate x, or nil if there is no such point.
+func liftX(x *big.Int, parity uint) *big.Int {
+	params := P256Sm2().Params()
+	// y² = x³ - 3x + b
+	y2 := new(big.Int).Mul(x, x)
+	y2.Sub(y2, big.NewInt(3))
+	y2.Mul(y2, x)
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+	// P ≡ 3 mod 4, so a square root is y2^((P+1)/4).
+	e := new(big.Int).Add(params.P, big.NewInt(1))
+	y := new(big.Int).Exp(y2, e.Rsh(e, 2), params.P)
+	if yy := new(big.Int).Mul(y, y); yy.Mod(yy, params.P).Cmp(y2) != 0 {
+		return nil
+	}
+	if y.Bit(0) != parity&1 {
+		y.Sub(params.P, y)
+	}
+	return y
+}
+
+func (b *BatchVerifier) randomizer() (*big.Int, error) {
+	r := b.rand
+	if r == nil {
+		r = RandSource()
+	}
+	buf := make([]byte, batchRandomizerBits/8)
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if a := new(big.Int).SetBytes(buf); a.Sign() != 0 {
+			return a, nil
+		}
+	}
+}
+
+// verify verifies the entry on its own, ignoring its parity.
+func (e *batchEntry) verify() bool {
+	if e.pub == nil || e.pub.X == nil || e.pub.Y == nil || e.r == nil || e.s == nil {
+		return false
+	}
+	return Sm2Verify(e.pub, e.msg, e.uid, e.r, e.s)
+}
+
+// verifyEach verifies every entry on its own.
+func (b *BatchVerifier) verifyEach(ctx context.Context) (valid bool, invalid []int, err error) {
+	for i := range b.entries {
+		if err := ctx.Err(); err != nil {
+			return false, nil, err
+		}
+		if !b.entries[i].verify() {
+			invalid = append(invalid, i)
+		}
+	}
+	return len(invalid) == 0, invalid, nil
+}
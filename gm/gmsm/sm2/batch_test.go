@@ -0,0 +1,123 @@
+package sm2
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func signBatch(t testing.TB, keys, count int) *BatchVerifier {
+	uid := []byte("1234567812345678")
+	privs := make([]*PrivateKey, keys)
+	for i := range privs {
+		privs[i], _ = GenerateKey()
+	}
+	b := NewBatchVerifier(nil)
+	for i := 0; i < count; i++ {
+		priv := privs[i%keys]
+		msg := []byte(fmt.Sprintf("message %d", i))
+		r, s, parity, err := Sm2SignWithParity(priv, msg, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Sm2Verify(&priv.PublicKey, msg, uid, r, s) {
+			t.Fatal("Sm2SignWithParity signature does not verify")
+		}
+		b.Add(&priv.PublicKey, msg, uid, r, s, parity)
+	}
+	return b
+}
+
+func TestBatchVerify(t *testing.T) {
+	for _, keys := range []int{1, 3, 16} {
+		b := signBatch(t, keys, 16)
+		if ok, invalid := b.Verify(); !ok || invalid != nil {
+			t.Fatalf("%d keys: valid batch rejected, invalid = %v", keys, invalid)
+		}
+	}
+	if ok, _ := NewBatchVerifier(nil).Verify(); !ok {
+		t.Fatal("empty batch rejected")
+	}
+}
+
+func TestBatchVerifyInvalid(t *testing.T) {
+	b := signBatch(t, 4, 10)
+	b.entries[3].msg = []byte("forged")
+	b.entries[8].s = new(big.Int).Add(b.entries[8].s, big.NewInt(1))
+	ok, invalid := b.Verify()
+	if ok || !reflect.DeepEqual(invalid, []int{3, 8}) {
+		t.Fatalf("got %v %v, want false [3 8]", ok, invalid)
+	}
+
+	// A wrong parity fails the batch equation, but the signature is valid.
+	b = signBatch(t, 4, 10)
+	b.entries[7].parity ^= 1
+	if ok, invalid := b.Verify(); !ok || invalid != nil {
+		t.Fatalf("got %v %v, want true []", ok, invalid)
+	}
+
+	b = signBatch(t, 2, 4)
+	b.entries[2].r = new(big.Int)
+	ok, invalid = b.Verify()
+	if ok || !reflect.DeepEqual(invalid, []int{2}) {
+		t.Fatalf("got %v %v, want false [2]", ok, invalid)
+	}
+}
+
+func TestBatchVerifyWithoutParity(t *testing.T) {
+	uid := []byte("1234567812345678")
+	priv, _ := GenerateKey()
+	b := signBatch(t, 2, 4)
+	for i := 0; i < 4; i++ {
+		msg := []byte(fmt.Sprintf("plain %d", i))
+		r, s, err := Sm2Sign(priv, msg, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.AddWithoutParity(&priv.PublicKey, msg, uid, r, s)
+	}
+	if ok, invalid := b.Verify(); !ok || invalid != nil {
+		t.Fatalf("valid batch rejected, invalid = %v", invalid)
+	}
+
+	b.entries[5].msg = []byte("forged")
+	b.entries[6].r = nil
+	if ok, invalid := b.Verify(); ok || !reflect.DeepEqual(invalid, []int{5, 6}) {
+		t.Fatalf("got %v %v, want false [5 6]", ok, invalid)
+	}
+}
+
+func TestBatchVerifyContext(t *testing.T) {
+	b := signBatch(t, 2, 4)
+	ok, invalid, err := b.VerifyContext(context.Background())
+	if !ok || invalid != nil || err != nil {
+		t.Fatalf("got %v %v %v, want true [] <nil>", ok, invalid, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, _, err := b.VerifyContext(ctx); ok || err != context.Canceled {
+		t.Fatalf("got %v %v, want false context.Canceled", ok, err)
+	}
+	b.entries[1].msg = []byte("forged")
+	if ok, _, err := b.verifyEach(ctx); ok || err != context.Canceled {
+		t.Fatalf("verifyEach: got %v %v, want false context.Canceled", ok, err)
+	}
+}
+
+func BenchmarkBatchVerify64(b *testing.B) {
+	batch := signBatch(b, 64, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch.Verify()
+	}
+}
+
+func BenchmarkVerify64(b *testing.B) {
+	batch := signBatch(b, 64, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		batch.verifyEach(context.Background())
+	}
+}
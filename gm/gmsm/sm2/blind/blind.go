@@ -0,0 +1,205 @@
+// Package blind implements blind Schnorr signatures over the SM2 curve.
+//
+// A signer holding an SM2 key pair (x, X) signs a message chosen by a
+// requester without learning the message or the resulting signature:
+//
+//	signer:    k random, R = k·G                          --R-->
+//	requester: α, β random, R' = R + α·G + β·X,
+//	           c' = H(X || R' || m), c = c' + β            <--c--
+//	signer:    s = k + c·x                                --s-->
+//	requester: checks s·G = R + c·X, s' = s + α
+//
+// The signature c' || s' verifies with Verify as an ordinary Schnorr
+// signature, R' = s'·G - c'·X, and cannot be linked to the signing session
+// that produced it. SM2 signatures themselves have no efficient blind
+// variant, so the scheme is Schnorr; H is a tagged SM3 hash and points are
+// in compressed SEC 1 form.
+//
+// Blind Schnorr signatures are only secure if the signer runs one session
+// at a time: with many concurrent sessions, the ROS attack forges one more
+// signature than the signer issued. A Signer therefore allows a single open
+// session and discards it when a new one starts.
+package blind
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	// SignatureSize is the size of a signature in bytes.
+	SignatureSize = 64
+	// CommitmentSize is the size of the signer's commitment R in bytes.
+	CommitmentSize = 33
+)
+
+var (
+	errInvalidKey = errors.New("blind: invalid key")
+	errNoSession  = errors.New("blind: no open signing session")
+	errMalformed  = errors.New("blind: malformed protocol message")
+	// ErrInvalidResponse is returned by Unblind for a signer response that
+	// does not match its commitment.
+	ErrInvalidResponse = errors.New("blind: invalid signer response")
+)
+
+func publicPoint(pub *sm2.PublicKey) (*ecpoint.Point, error) {
+	p, ok := ecpoint.FromPublicKey(pub)
+	if !ok {
+		return nil, errInvalidKey
+	}
+	return p, nil
+}
+
+// challenge returns H(X || R || m).
+func challenge(pub, r *ecpoint.Point, msg []byte) *big.Int {
+	t := sm3.Sm3Sum([]byte("BlindSchnorr/challenge"))
+	h := sm3.New()
+	h.Write(t)
+	h.Write(t)
+	h.Write(pub.Compress())
+	h.Write(r.Compress())
+	h.Write(msg)
+	e := new(big.Int).SetBytes(h.Sum(nil))
+	return e.Mod(e, ecpoint.Order())
+}
+
+// Signer is the signing side of the protocol. It is safe for concurrent use,
+// but only the most recent session can be completed.
+type Signer struct {
+	priv *sm2.PrivateKey
+
+	mu sync.Mutex
+	k  *big.Int
+}
+
+// NewSigner returns a signer for priv.
+func NewSigner(priv *sm2.PrivateKey) (*Signer, error) {
+	if priv == nil || priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(ecpoint.Order()) >= 0 {
+		return nil, errInvalidKey
+	}
+	return &Signer{priv: priv}, nil
+}
+
+// Commit starts a signing session and returns the commitment R to send to
+// the requester. An open session is discarded.
+func (s *Signer) Commit(rand io.Reader) ([]byte, error) {
+	k, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.k = k
+	s.mu.Unlock()
+	return ecpoint.BaseMul(k).Compress(), nil
+}
+
+// Respond completes the open session with the requester's blinded
+// challenge and returns the response to send back.
+func (s *Signer) Respond(challenge []byte) ([]byte, error) {
+	c, err := ecpoint.ParseScalar(challenge)
+	if err != nil {
+		return nil, errMalformed
+	}
+	s.mu.Lock()
+	k := s.k
+	s.k = nil
+	s.mu.Unlock()
+	if k == nil {
+		return nil, errNoSession
+	}
+	// s = k + c·x
+	r := new(big.Int).Mul(c, s.priv.D)
+	r.Add(r, k)
+	return ecpoint.ScalarBytes(r.Mod(r, ecpoint.Order())), nil
+}
+
+// Request is the requester's state for one signing session.
+type Request struct {
+	pub   *ecpoint.Point
+	r     *ecpoint.Point
+	c     *big.Int
+	alpha *big.Int
+	sig   []byte
+}
+
+// Blind blinds msg for the signer with public key pub and commitment
+// commitment, and returns the challenge to send to the signer.
+func Blind(rand io.Reader, pub *sm2.PublicKey, commitment, msg []byte) (*Request, []byte, error) {
+	x, err := publicPoint(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := ecpoint.Decompress(commitment, false)
+	if err != nil {
+		return nil, nil, errMalformed
+	}
+	alpha, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	beta, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	// R' = R + α·G + β·X
+	r2 := r.Add(ecpoint.BaseMul(alpha)).Add(x.Mul(beta))
+	if r2.IsInfinity() {
+		// Negligible; a fresh α and β avoid it.
+		return Blind(rand, pub, commitment, msg)
+	}
+	c2 := challenge(x, r2, msg)
+	c := new(big.Int).Add(c2, beta)
+	c.Mod(c, ecpoint.Order())
+	req := &Request{
+		pub:   x,
+		r:     r,
+		c:     c,
+		alpha: alpha,
+		sig:   ecpoint.ScalarBytes(c2),
+	}
+	return req, ecpoint.ScalarBytes(c), nil
+}
+
+// Unblind checks the signer's response and returns the signature of the
+// blinded message.
+func (req *Request) Unblind(response []byte) ([]byte, error) {
+	s, err := ecpoint.ParseScalar(response)
+	if err != nil {
+		return nil, errMalformed
+	}
+	// s·G = R + c·X
+	if !ecpoint.BaseMul(s).Equal(req.r.Add(req.pub.Mul(req.c))) {
+		return nil, ErrInvalidResponse
+	}
+	s.Add(s, req.alpha)
+	s.Mod(s, ecpoint.Order())
+	return append(req.sig[:32:32], ecpoint.ScalarBytes(s)...), nil
+}
+
+// Verify reports whether sig is a valid signature of msg under pub.
+func Verify(pub *sm2.PublicKey, msg, sig []byte) bool {
+	x, err := publicPoint(pub)
+	if err != nil || len(sig) != SignatureSize {
+		return false
+	}
+	c, err := ecpoint.ParseScalar(sig[:32])
+	if err != nil {
+		return false
+	}
+	s, err := ecpoint.ParseScalar(sig[32:])
+	if err != nil {
+		return false
+	}
+	// R = s·G - c·X
+	r := ecpoint.BaseMul(s).Add(x.Mul(new(big.Int).Sub(ecpoint.Order(), c)))
+	if r.IsInfinity() {
+		return false
+	}
+	return challenge(x, r, msg).Cmp(c) == 0
+}
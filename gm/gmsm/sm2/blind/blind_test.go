@@ -0,0 +1,108 @@
+package blind
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func issue(t testing.TB, signer *Signer, pub *sm2.PublicKey, msg []byte) []byte {
+	commitment, err := signer.Commit(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, c, err := Blind(rand.Reader, pub, commitment, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := signer.Respond(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := req.Unblind(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestBlindSign(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	signer, err := NewSigner(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("credential serial 42")
+	sig := issue(t, signer, &priv.PublicKey, msg)
+	if !Verify(&priv.PublicKey, msg, sig) {
+		t.Fatal("signature does not verify")
+	}
+	if Verify(&priv.PublicKey, []byte("credential serial 43"), sig) {
+		t.Fatal("signature verifies for another message")
+	}
+	other, _ := sm2.GenerateKey()
+	if Verify(&other.PublicKey, msg, sig) {
+		t.Fatal("signature verifies under another key")
+	}
+	sig[40] ^= 1
+	if Verify(&priv.PublicKey, msg, sig) {
+		t.Fatal("tampered signature verifies")
+	}
+}
+
+func TestUnlinkable(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	signer, _ := NewSigner(priv)
+	msg := []byte("token")
+	// The same message signed twice gives unrelated signatures.
+	a := issue(t, signer, &priv.PublicKey, msg)
+	b := issue(t, signer, &priv.PublicKey, msg)
+	if string(a[:32]) == string(b[:32]) || string(a[32:]) == string(b[32:]) {
+		t.Fatal("signatures of the same message share a component")
+	}
+}
+
+func TestSingleSession(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	signer, _ := NewSigner(priv)
+	r1, _ := signer.Commit(rand.Reader)
+	req1, c1, _ := Blind(rand.Reader, &priv.PublicKey, r1, []byte("first"))
+	// Starting a second session discards the first.
+	r2, _ := signer.Commit(rand.Reader)
+	_, c2, _ := Blind(rand.Reader, &priv.PublicKey, r2, []byte("second"))
+
+	s, err := signer.Respond(c1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req1.Unblind(s); err != ErrInvalidResponse {
+		t.Fatalf("stale session: got %v, want ErrInvalidResponse", err)
+	}
+	if _, err := signer.Respond(c2); err != errNoSession {
+		t.Fatalf("closed session: got %v, want errNoSession", err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	signer, _ := NewSigner(priv)
+	if _, _, err := Blind(rand.Reader, &priv.PublicKey, make([]byte, CommitmentSize), nil); err == nil {
+		t.Fatal("accepted the point at infinity as commitment")
+	}
+	signer.Commit(rand.Reader)
+	if _, err := signer.Respond(make([]byte, 31)); err == nil {
+		t.Fatal("accepted a short challenge")
+	}
+	if _, err := NewSigner(&sm2.PrivateKey{PublicKey: priv.PublicKey}); err == nil {
+		t.Fatal("accepted a private key without D")
+	}
+}
+
+func BenchmarkIssue(b *testing.B) {
+	priv, _ := sm2.GenerateKey()
+	signer, _ := NewSigner(priv)
+	for i := 0; i < b.N; i++ {
+		issue(b, signer, &priv.PublicKey, []byte("msg"))
+	}
+}
@@ -0,0 +1,266 @@
+package sm2
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/big"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/ctutil"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3kdf"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4stream"
+)
+
+const (
+	// bulkSegment is the amount of keying material each worker derives at
+	// a time, a multiple of the 32-byte SM3 block.
+	bulkSegment = 256 * 1024
+	// kdfMaxLength is the longest message the KDF of GB/T 32918.4 covers.
+	kdfMaxLength = (1<<32 - 1) * 32
+	// hybridKeySize is the size of the SM2 ciphertext of an SM4 key.
+	hybridKeySize = 1 + 96 + sm4.KeySize
+)
+
+var errBulkPublicKey = errors.New("sm2: BulkCipher without private key cannot decrypt")
+
+// BulkCipher encrypts and decrypts large payloads on several goroutines.
+//
+// Encrypt and Decrypt produce and accept the ciphertexts of the functions of
+// the same name: the KDF output for C2 is derived and XORed in segments on
+// the workers, while the caller's goroutine computes C3 over the segments as
+// they are done. C3 is a single SM3 hash of the message and bounds the
+// speedup to about five times.
+//
+// SealHybrid and OpenHybrid encrypt the payload with SM4-GCM instead, in
+// chunks sealed on the workers, under a fresh key encrypted with SM2, and
+// scale with the number of workers.
+//
+// A BulkCipher may be used concurrently.
+type BulkCipher struct {
+	pub     *PublicKey
+	priv    *PrivateKey
+	workers int
+}
+
+// NewBulkCipher returns a BulkCipher for key, a *PrivateKey or a *PublicKey,
+// which can only encrypt, running up to workers goroutines per call, or
+// GOMAXPROCS if workers is not positive.
+func NewBulkCipher(key interface{}, workers int) (*BulkCipher, error) {
+	b := &BulkCipher{workers: workers}
+	switch k := key.(type) {
+	case *PrivateKey:
+		if err := checkPrivateKey(k); err != nil {
+			return nil, err
+		}
+		b.priv, b.pub = k, &k.PublicKey
+	case *PublicKey:
+		if err := checkPublicKey(k); err != nil {
+			return nil, err
+		}
+		b.pub = k
+	default:
+		return nil, errors.New("sm2: NewBulkCipher needs a *PrivateKey or *PublicKey")
+	}
+	if b.workers <= 0 {
+		b.workers = runtime.GOMAXPROCS(0)
+	}
+	return b, nil
+}
+
+// Encrypt is Encrypt for the public key of b.
+func (b *BulkCipher) Encrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	if uint64(len(data)) > kdfMaxLength {
+		return nil, sm3kdf.ErrLength
+	}
+	curve := b.pub.Curve
+	out := make([]byte, 97+len(data))
+	out[0] = 0x04
+	for {
+		k, err := randFieldElement(curve, RandSource())
+		if err != nil {
+			return nil, err
+		}
+		kBytes := k.Bytes()
+		x1, y1 := curve.ScalarBaseMult(kBytes)
+		x2, y2 := curve.ScalarMult(b.pub.X, b.pub.Y, kBytes)
+		zeroize(kBytes)
+		zeroizeInt(k)
+		copy(out[1:33], ctutil.FixedBytes(x1, 32))
+		copy(out[33:65], ctutil.FixedBytes(y1, 32))
+		z := sharedSecret(x2, y2)
+
+		h := sm3.New()
+		h.Write(z[:32])
+		nonzero := b.xorKeyStream(out[97:], data, z, func(lo, hi int) {
+			h.Write(data[lo:hi])
+		})
+		h.Write(z[32:])
+		copy(out[65:97], h.Sum(nil))
+		zeroize(z)
+		if nonzero {
+			return out, nil
+		}
+	}
+}
+
+// Decrypt is Decrypt for the private key of b. It fails if b was created
+// from a public key.
+func (b *BulkCipher) Decrypt(data []byte) ([]byte, error) {
+	if b.priv == nil {
+		return nil, errBulkPublicKey
+	}
+	pt, err := b.decrypt(data)
+	record(audit.OpDecrypt, b.pub, err)
+	return pt, err
+}
+
+func (b *BulkCipher) decrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	if len(data) < 97 {
+		return nil, errors.New("Decrypt: failed to decrypt")
+	}
+	curve := b.priv.Curve
+	x := new(big.Int).SetBytes(data[1:33])
+	y := new(big.Int).SetBytes(data[33:65])
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("Decrypt: failed to decrypt")
+	}
+	d := b.priv.D.Bytes()
+	x2, y2 := curve.ScalarMult(x, y, d)
+	zeroize(d)
+	z := sharedSecret(x2, y2)
+	defer zeroize(z)
+
+	c := data[97:]
+	out := make([]byte, len(c))
+	h := sm3.New()
+	h.Write(z[:32])
+	nonzero := b.xorKeyStream(out, c, z, func(lo, hi int) {
+		h.Write(out[lo:hi])
+	})
+	h.Write(z[32:])
+	if (!nonzero && len(c) > 0) || ctutil.Equal(h.Sum(nil), data[65:97]) != 1 {
+		// The unauthenticated plaintext is not returned.
+		zeroize(out)
+		return nil, errors.New("Decrypt: failed to decrypt")
+	}
+	return out, nil
+}
+
+// xorKeyStream sets dst to src XOR KDF(z, len(src)), one segment per task on
+// the workers, and reports whether the keying material has a nonzero byte.
+// each is called on the caller's goroutine with the bounds of every segment,
+// in order, once it is done.
+func (b *BulkCipher) xorKeyStream(dst, src, z []byte, each func(lo, hi int)) bool {
+	segments := (len(src) + bulkSegment - 1) / bulkSegment
+	ready := make([]chan struct{}, segments)
+	for i := range ready {
+		ready[i] = make(chan struct{})
+	}
+	nonzero := make([]bool, segments)
+
+	workers := b.workers
+	if workers > segments {
+		workers = segments
+	}
+	next := int64(-1)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= segments {
+					return
+				}
+				lo, hi := segmentBounds(i, len(src))
+				sm3kdf.NewReaderAt(z, int64(lo)).Read(dst[lo:hi])
+				var acc byte
+				for j := lo; j < hi; j++ {
+					acc |= dst[j]
+					dst[j] ^= src[j]
+				}
+				nonzero[i] = acc != 0
+				close(ready[i])
+			}
+		}()
+	}
+
+	found := false
+	for i := range ready {
+		<-ready[i]
+		each(segmentBounds(i, len(src)))
+		found = found || nonzero[i]
+	}
+	return found
+}
+
+func segmentBounds(i, length int) (lo, hi int) {
+	lo = i * bulkSegment
+	hi = lo + bulkSegment
+	if hi > length {
+		hi = length
+	}
+	return lo, hi
+}
+
+// SealHybrid encrypts data with SM4-GCM under a fresh key and returns the
+// SM2 ciphertext of the key, 113 bytes, followed by the sm4stream stream of
+// data, whose chunks are sealed on the workers.
+func (b *BulkCipher) SealHybrid(data []byte) ([]byte, error) {
+	key := make([]byte, sm4.KeySize)
+	defer zeroize(key)
+	if _, err := io.ReadFull(RandSource(), key); err != nil {
+		return nil, err
+	}
+	wrapped, err := Encrypt(b.pub, key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newHybridAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := sm4stream.Seal(aead, data, b.workers)
+	if err != nil {
+		return nil, err
+	}
+	return append(wrapped, stream...), nil
+}
+
+// OpenHybrid decrypts the output of SealHybrid, opening its chunks on the
+// workers. It fails if b was created from a public key.
+func (b *BulkCipher) OpenHybrid(data []byte) ([]byte, error) {
+	if b.priv == nil {
+		return nil, errBulkPublicKey
+	}
+	if len(data) < hybridKeySize {
+		return nil, errors.New("Decrypt: failed to decrypt")
+	}
+	key, err := Decrypt(b.priv, data[:hybridKeySize])
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(key)
+	aead, err := newHybridAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return sm4stream.Open(aead, data[hybridKeySize:], b.workers)
+}
+
+func newHybridAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package sm2
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBulkCipher(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulk, err := NewBulkCipher(priv, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 1, 33, bulkSegment, 3*bulkSegment + 5} {
+		msg := make([]byte, n)
+		rand.Read(msg)
+
+		ct, err := bulk.Encrypt(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pt, err := Decrypt(priv, ct); err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("length %d: Decrypt of BulkCipher.Encrypt: %v", n, err)
+		}
+		ct, err = Encrypt(&priv.PublicKey, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pt, err := bulk.Decrypt(ct); err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("length %d: BulkCipher.Decrypt of Encrypt: %v", n, err)
+		}
+		if n > 0 {
+			ct[len(ct)-1] ^= 1
+			if _, err := bulk.Decrypt(ct); err == nil {
+				t.Fatalf("length %d: modified ciphertext decrypted", n)
+			}
+		}
+
+		sealed, err := bulk.SealHybrid(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pt, err := bulk.OpenHybrid(sealed); err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("length %d: hybrid round trip: %v", n, err)
+		}
+		sealed[len(sealed)-1] ^= 1
+		if _, err := bulk.OpenHybrid(sealed); err == nil {
+			t.Fatalf("length %d: modified hybrid ciphertext opened", n)
+		}
+	}
+
+	if _, err := bulk.Decrypt(make([]byte, 96)); err == nil {
+		t.Fatal("short ciphertext decrypted")
+	}
+	public, err := NewBulkCipher(&priv.PublicKey, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := public.Encrypt([]byte("msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := public.Decrypt(ct); err != errBulkPublicKey {
+		t.Fatalf("public BulkCipher decrypted: %v", err)
+	}
+	if _, err := NewBulkCipher(priv.D, 1); err == nil {
+		t.Fatal("BulkCipher from a *big.Int")
+	}
+}
+
+func benchmarkBulk(b *testing.B, encrypt func([]byte) ([]byte, error)) {
+	msg := make([]byte, 16<<20)
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		if _, err := encrypt(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBulkEncrypt(b *testing.B) {
+	priv, _ := GenerateKey()
+	b.Run("Encrypt", func(b *testing.B) {
+		benchmarkBulk(b, func(msg []byte) ([]byte, error) { return Encrypt(&priv.PublicKey, msg) })
+	})
+	bulk, _ := NewBulkCipher(&priv.PublicKey, 0)
+	b.Run("BulkCipher", func(b *testing.B) { benchmarkBulk(b, bulk.Encrypt) })
+	b.Run("SealHybrid", func(b *testing.B) { benchmarkBulk(b, bulk.SealHybrid) })
+}
@@ -0,0 +1,382 @@
+// Package bulletproofs implements aggregated Bulletproofs range proofs on
+// the SM2 curve, proving that Pedersen commitments V_j = v_j·B + γ_j·H hide
+// values in [0, 2^64) without revealing them, e.g. to validate confidential
+// transaction amounts.
+//
+// B is the SM2 base point and H, U and the vectors Gs and Hs are derived by
+// hashing to the curve with SM3, so nobody knows their discrete logarithms
+// with respect to each other. A proof for m values, m a power of two up to
+// MaxAggregation, is A, S, T1, T2 || τx, μ, t̂, a, b || L_1, R_1, ... L_k, R_k
+// with k = log2(64·m) inner-product rounds, points in compressed SEC 1 form
+// and scalars as 32-byte big-endian integers, so it grows logarithmically
+// with m: 688 bytes for one value and 952 bytes for sixteen. The challenges
+// are derived by Fiat-Shamir from an SM3 transcript of the commitments and
+// all prior messages.
+//
+// Verify checks both the polynomial commitment and the inner-product
+// argument with a single multi-scalar multiplication of 2·64·m + 2k + m + 7
+// points. Proving is not constant time: the multi-scalar multiplications of
+// the prover leak timing information about the values and blindings.
+package bulletproofs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+)
+
+const (
+	// BitSize is the number of bits of the proven range [0, 2^BitSize).
+	BitSize = 64
+	// MaxAggregation is the largest number of values one proof may cover.
+	MaxAggregation = 16
+)
+
+var (
+	errMalformed = errors.New("bulletproofs: malformed input")
+	errCount     = errors.New("bulletproofs: number of values must be a power of two no larger than MaxAggregation")
+	errBlinding  = errors.New("bulletproofs: invalid blinding factor")
+)
+
+// proofSize returns the size of a proof for m values.
+func proofSize(m int) int {
+	return 4*33 + 5*32 + 2*33*log2(BitSize*m)
+}
+
+func log2(n int) int {
+	k := 0
+	for 1<<uint(k) < n {
+		k++
+	}
+	return k
+}
+
+func validCount(m int) bool {
+	return m > 0 && m <= MaxAggregation && m&(m-1) == 0
+}
+
+// Commit returns the compressed Pedersen commitment v·B + blinding·H.
+func Commit(v uint64, blinding *big.Int) []byte {
+	g := getGenerators()
+	return msm([]*ecpoint.Point{g.b, g.h}, []*big.Int{new(big.Int).SetUint64(v), blinding}).Compress()
+}
+
+// powers returns 1, x, x², ... x^(n-1) mod the group order.
+func powers(x *big.Int, n int) []*big.Int {
+	v := make([]*big.Int, n)
+	v[0] = big.NewInt(1)
+	for i := 1; i < n; i++ {
+		v[i] = new(big.Int).Mul(v[i-1], x)
+		v[i].Mod(v[i], ecpoint.Order())
+	}
+	return v
+}
+
+// start begins the transcript of a proof for commitments.
+func start(commitments [][]byte) *transcript {
+	tr := newTranscript()
+	var b [8]byte
+	binary.BigEndian.PutUint32(b[:4], BitSize)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(commitments)))
+	tr.append("n,m", b[:])
+	for _, v := range commitments {
+		tr.append("V", v)
+	}
+	return tr
+}
+
+// Prove returns a proof that each of values lies in [0, 2^64), and the
+// commitments to values under the given blinding factors, which the
+// verifier needs along with the proof. The number of values must be a power
+// of two no larger than MaxAggregation; pad with commitments to zero
+// otherwise. The blinding factors must be in [1, n-1] and kept secret.
+func Prove(rand io.Reader, values []uint64, blindings []*big.Int) (proof []byte, commitments [][]byte, err error) {
+	m := len(values)
+	if !validCount(m) {
+		return nil, nil, errCount
+	}
+	if len(blindings) != m {
+		return nil, nil, errors.New("bulletproofs: values and blindings differ in length")
+	}
+	n := ecpoint.Order()
+	for _, gamma := range blindings {
+		if gamma == nil || gamma.Sign() <= 0 || gamma.Cmp(n) >= 0 {
+			return nil, nil, errBlinding
+		}
+	}
+
+	g := getGenerators()
+	size := BitSize * m
+	gs, hs := g.gs[:size], g.hs[:size]
+
+	commitments = make([][]byte, m)
+	for j, v := range values {
+		commitments[j] = Commit(v, blindings[j])
+	}
+	tr := start(commitments)
+
+	// aL holds the bits of the values and aR = aL - 1.
+	aL := make([]*big.Int, size)
+	aR := make([]*big.Int, size)
+	for j, v := range values {
+		for i := 0; i < BitSize; i++ {
+			bit := int64(v >> uint(i) & 1)
+			aL[j*BitSize+i] = big.NewInt(bit)
+			aR[j*BitSize+i] = big.NewInt(bit - 1)
+		}
+	}
+	sL := make([]*big.Int, size)
+	sR := make([]*big.Int, size)
+	for i := range sL {
+		if sL[i], err = ecpoint.RandScalar(rand); err != nil {
+			return nil, nil, err
+		}
+		if sR[i], err = ecpoint.RandScalar(rand); err != nil {
+			return nil, nil, err
+		}
+	}
+	alpha, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	rho, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A = α·H + <aL, Gs> + <aR, Hs>, S = ρ·H + <sL, Gs> + <sR, Hs>
+	points := append(append([]*ecpoint.Point{g.h}, gs...), hs...)
+	a := msm(points, append(append([]*big.Int{alpha}, aL...), aR...))
+	s := msm(points, append(append([]*big.Int{rho}, sL...), sR...))
+	tr.append("A", a.Compress())
+	tr.append("S", s.Compress())
+	y := tr.challenge("y")
+	z := tr.challenge("z")
+
+	// l(X) = (aL - z) + sL·X
+	// r(X) = y^i·(aR + z + sR·X) + z^(2+j)·2^(i mod 64)
+	yPow := powers(y, size)
+	zPow := powers(z, m+2)
+	two := powers(big.NewInt(2), BitSize)
+	l0 := make([]*big.Int, size)
+	r0 := make([]*big.Int, size)
+	r1 := make([]*big.Int, size)
+	for i := range l0 {
+		l0[i] = new(big.Int).Sub(aL[i], z)
+		l0[i].Mod(l0[i], n)
+		r0[i] = new(big.Int).Add(aR[i], z)
+		r0[i].Mul(r0[i], yPow[i])
+		r0[i].Add(r0[i], new(big.Int).Mul(zPow[2+i/BitSize], two[i%BitSize]))
+		r0[i].Mod(r0[i], n)
+		r1[i] = new(big.Int).Mul(yPow[i], sR[i])
+		r1[i].Mod(r1[i], n)
+	}
+
+	// t(X) = <l(X), r(X)> = t0 + t1·X + t2·X²
+	t1 := innerProduct(l0, r1)
+	t1.Add(t1, innerProduct(sL, r0)).Mod(t1, n)
+	t2 := innerProduct(sL, r1)
+	tau1, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	tau2, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	T1 := msm([]*ecpoint.Point{g.b, g.h}, []*big.Int{t1, tau1})
+	T2 := msm([]*ecpoint.Point{g.b, g.h}, []*big.Int{t2, tau2})
+	tr.append("T1", T1.Compress())
+	tr.append("T2", T2.Compress())
+	x := tr.challenge("x")
+
+	// τx = τ2·x² + τ1·x + Σ z^(2+j)·γ_j, μ = α + ρ·x
+	taux := new(big.Int).Mul(tau2, x)
+	taux.Add(taux, tau1).Mul(taux, x)
+	for j, gamma := range blindings {
+		taux.Add(taux, new(big.Int).Mul(zPow[2+j], gamma))
+	}
+	taux.Mod(taux, n)
+	mu := new(big.Int).Mul(rho, x)
+	mu.Add(mu, alpha).Mod(mu, n)
+
+	l := make([]*big.Int, size)
+	r := make([]*big.Int, size)
+	for i := range l {
+		l[i] = new(big.Int).Mul(sL[i], x)
+		l[i].Add(l[i], l0[i]).Mod(l[i], n)
+		r[i] = new(big.Int).Mul(r1[i], x)
+		r[i].Add(r[i], r0[i]).Mod(r[i], n)
+	}
+	that := innerProduct(l, r)
+	tr.append("taux", ecpoint.ScalarBytes(taux))
+	tr.append("mu", ecpoint.ScalarBytes(mu))
+	tr.append("t", ecpoint.ScalarBytes(that))
+	w := tr.challenge("w")
+
+	// Prove <l, r> = t̂ with respect to Gs and H'_i = y^-i·Hs_i.
+	q := msm([]*ecpoint.Point{g.u}, []*big.Int{w})
+	yInv := powers(new(big.Int).ModInverse(y, n), size)
+	ls, rs, aFinal, bFinal := proveInnerProduct(tr, gs, hs, ones(size), yInv, q, l, r)
+
+	proof = make([]byte, 0, proofSize(m))
+	for _, p := range []*ecpoint.Point{a, s, T1, T2} {
+		proof = append(proof, p.Compress()...)
+	}
+	for _, k := range []*big.Int{taux, mu, that, aFinal, bFinal} {
+		proof = append(proof, ecpoint.ScalarBytes(k)...)
+	}
+	for i := range ls {
+		proof = append(proof, ls[i].Compress()...)
+		proof = append(proof, rs[i].Compress()...)
+	}
+	return proof, commitments, nil
+}
+
+// Verify reports whether proof is a valid proof that the values committed
+// to by commitments lie in [0, 2^64).
+func Verify(commitments [][]byte, proof []byte) bool {
+	m := len(commitments)
+	if !validCount(m) || len(proof) != proofSize(m) {
+		return false
+	}
+	size := BitSize * m
+	rounds := log2(size)
+
+	vs := make([]*ecpoint.Point, m)
+	for j, c := range commitments {
+		v, err := ecpoint.Decompress(c, false)
+		if err != nil {
+			return false
+		}
+		vs[j] = v
+	}
+	var pts [4]*ecpoint.Point
+	for i := range pts {
+		p, err := ecpoint.Decompress(proof[33*i:33*(i+1)], false)
+		if err != nil {
+			return false
+		}
+		pts[i] = p
+	}
+	a, s, T1, T2 := pts[0], pts[1], pts[2], pts[3]
+	var scalars [5]*big.Int
+	for i := range scalars {
+		off := 4*33 + 32*i
+		k, err := ecpoint.ParseScalar(proof[off : off+32])
+		if err != nil {
+			return false
+		}
+		scalars[i] = k
+	}
+	taux, mu, that, aFinal, bFinal := scalars[0], scalars[1], scalars[2], scalars[3], scalars[4]
+	ls := make([]*ecpoint.Point, rounds)
+	rs := make([]*ecpoint.Point, rounds)
+	for j := 0; j < rounds; j++ {
+		off := 4*33 + 5*32 + 66*j
+		var err error
+		if ls[j], err = ecpoint.Decompress(proof[off:off+33], false); err != nil {
+			return false
+		}
+		if rs[j], err = ecpoint.Decompress(proof[off+33:off+66], false); err != nil {
+			return false
+		}
+	}
+
+	tr := start(commitments)
+	tr.append("A", a.Compress())
+	tr.append("S", s.Compress())
+	y := tr.challenge("y")
+	z := tr.challenge("z")
+	tr.append("T1", T1.Compress())
+	tr.append("T2", T2.Compress())
+	x := tr.challenge("x")
+	tr.append("taux", ecpoint.ScalarBytes(taux))
+	tr.append("mu", ecpoint.ScalarBytes(mu))
+	tr.append("t", ecpoint.ScalarBytes(that))
+	w := tr.challenge("w")
+	us := make([]*big.Int, rounds)
+	for j := range us {
+		tr.append("L", ls[j].Compress())
+		tr.append("R", rs[j].Compress())
+		us[j] = tr.challenge("u")
+	}
+	// c combines the two verification equations; it is derived after
+	// everything in the proof is fixed.
+	tr.append("a", ecpoint.ScalarBytes(aFinal))
+	tr.append("b", ecpoint.ScalarBytes(bFinal))
+	c := tr.challenge("c")
+
+	n := ecpoint.Order()
+	g := getGenerators()
+	yPow := powers(y, size)
+	yInv := powers(new(big.Int).ModInverse(y, n), size)
+	zPow := powers(z, m+3)
+	two := powers(big.NewInt(2), BitSize)
+	sv := verificationScalars(us, size)
+
+	points := make([]*ecpoint.Point, 0, 2*size+2*rounds+m+6)
+	coeffs := make([]*big.Int, 0, cap(points))
+
+	// Gs_i: a·s_i + z
+	// Hs_i: y^-i·(b·s_(N-1-i) - z^(2+j)·2^(i mod 64)) - z
+	for i := 0; i < size; i++ {
+		k := new(big.Int).Mul(aFinal, sv[i])
+		points, coeffs = append(points, g.gs[i]), append(coeffs, k.Add(k, z))
+	}
+	for i := 0; i < size; i++ {
+		k := new(big.Int).Mul(bFinal, sv[size-1-i])
+		k.Sub(k, new(big.Int).Mul(zPow[2+i/BitSize], two[i%BitSize]))
+		k.Mod(k, n).Mul(k, yInv[i]).Sub(k, z)
+		points, coeffs = append(points, g.hs[i]), append(coeffs, k)
+	}
+
+	// H: μ - c·τx, A: -1, S: -x, U: w·(a·b - t̂)
+	kh := new(big.Int).Mul(c, taux)
+	points, coeffs = append(points, g.h), append(coeffs, kh.Sub(mu, kh))
+	points, coeffs = append(points, a), append(coeffs, big.NewInt(-1))
+	points, coeffs = append(points, s), append(coeffs, new(big.Int).Neg(x))
+	ku := new(big.Int).Mul(aFinal, bFinal)
+	ku.Sub(ku, that).Mul(ku, w)
+	points, coeffs = append(points, g.u), append(coeffs, ku)
+
+	// L_j: -u_j², R_j: -u_j^-2
+	for j, u := range us {
+		u2 := new(big.Int).Mul(u, u)
+		u2.Mod(u2, n)
+		u2Inv := new(big.Int).ModInverse(u2, n)
+		points, coeffs = append(points, ls[j]), append(coeffs, u2.Neg(u2))
+		points, coeffs = append(points, rs[j]), append(coeffs, u2Inv.Neg(u2Inv))
+	}
+
+	// c·(Σ z^(2+j)·V_j + δ·B + x·T1 + x²·T2 - t̂·B - τx·H), with
+	// δ = (z - z²)·Σ y^i - Σ z^(3+j)·(2^64 - 1)
+	for j, v := range vs {
+		k := new(big.Int).Mul(c, zPow[2+j])
+		points, coeffs = append(points, v), append(coeffs, k)
+	}
+	sumY := new(big.Int)
+	for _, yi := range yPow {
+		sumY.Add(sumY, yi)
+	}
+	delta := new(big.Int).Sub(z, zPow[2])
+	delta.Mul(delta, sumY)
+	sumZ := new(big.Int)
+	for j := 0; j < m; j++ {
+		sumZ.Add(sumZ, zPow[3+j])
+	}
+	maxValue := new(big.Int).Lsh(big.NewInt(1), BitSize)
+	maxValue.Sub(maxValue, big.NewInt(1))
+	delta.Sub(delta, sumZ.Mul(sumZ, maxValue))
+	kb := new(big.Int).Sub(delta, that)
+	points, coeffs = append(points, g.b), append(coeffs, kb.Mul(kb, c))
+	k1 := new(big.Int).Mul(c, x)
+	points, coeffs = append(points, T1), append(coeffs, k1)
+	k2 := new(big.Int).Mul(k1, x)
+	points, coeffs = append(points, T2), append(coeffs, k2)
+
+	return msm(points, coeffs).IsInfinity()
+}
@@ -0,0 +1,148 @@
+package bulletproofs
+
+import (
+	"crypto/rand"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+)
+
+func blindings(t testing.TB, m int) []*big.Int {
+	gammas := make([]*big.Int, m)
+	for i := range gammas {
+		var err error
+		if gammas[i], err = ecpoint.RandScalar(rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gammas
+}
+
+func TestProve(t *testing.T) {
+	for _, v := range []uint64{0, 1, 42, 1 << 32, math.MaxUint64} {
+		gammas := blindings(t, 1)
+		proof, commitments, err := Prove(rand.Reader, []uint64{v}, gammas)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(proof) != 688 {
+			t.Fatalf("proof is %d bytes, want 688", len(proof))
+		}
+		if string(commitments[0]) != string(Commit(v, gammas[0])) {
+			t.Fatalf("commitment to %d differs from Commit", v)
+		}
+		if !Verify(commitments, proof) {
+			t.Fatalf("valid proof for %d rejected", v)
+		}
+	}
+}
+
+func TestProveAggregated(t *testing.T) {
+	for _, m := range []int{2, 4, 16} {
+		values := make([]uint64, m)
+		for i := range values {
+			values[i] = uint64(i) * 0x0123456789abcdef
+		}
+		proof, commitments, err := Prove(rand.Reader, values, blindings(t, m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(proof) != proofSize(m) {
+			t.Fatalf("proof for %d values is %d bytes, want %d", m, len(proof), proofSize(m))
+		}
+		if !Verify(commitments, proof) {
+			t.Fatalf("valid proof for %d values rejected", m)
+		}
+
+		// The commitments are bound to their positions.
+		commitments[0], commitments[1] = commitments[1], commitments[0]
+		if Verify(commitments, proof) {
+			t.Fatalf("proof for %d values accepted with swapped commitments", m)
+		}
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	gammas := blindings(t, 2)
+	proof, commitments, err := Prove(rand.Reader, []uint64{7, 1000}, gammas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A commitment to another value, e.g. one shifted out of the range by
+	// 2^64, does not verify with the proof.
+	shifted := new(big.Int).Lsh(big.NewInt(1), BitSize)
+	g := getGenerators()
+	v, _ := ecpoint.Decompress(commitments[0], false)
+	out := msm([]*ecpoint.Point{v, g.b}, []*big.Int{big.NewInt(1), shifted}).Compress()
+	if Verify([][]byte{out, commitments[1]}, proof) {
+		t.Fatal("proof accepted for a commitment to a value out of range")
+	}
+	if Verify([][]byte{Commit(8, gammas[0]), commitments[1]}, proof) {
+		t.Fatal("proof accepted for a commitment to another value")
+	}
+	if Verify(commitments[:1], proof) {
+		t.Fatal("proof accepted for a subset of the commitments")
+	}
+
+	for i := 0; i < len(proof); i += 7 {
+		bad := append([]byte(nil), proof...)
+		bad[i] ^= 1
+		if Verify(commitments, bad) {
+			t.Fatalf("proof with byte %d flipped accepted", i)
+		}
+	}
+	if Verify(commitments, proof[:len(proof)-1]) {
+		t.Fatal("truncated proof accepted")
+	}
+}
+
+func TestProveErrors(t *testing.T) {
+	if _, _, err := Prove(rand.Reader, []uint64{1, 2, 3}, blindings(t, 3)); err == nil {
+		t.Fatal("expected error for 3 values")
+	}
+	if _, _, err := Prove(rand.Reader, nil, nil); err == nil {
+		t.Fatal("expected error for no values")
+	}
+	if _, _, err := Prove(rand.Reader, make([]uint64, 32), blindings(t, 32)); err == nil {
+		t.Fatal("expected error for 32 values")
+	}
+	if _, _, err := Prove(rand.Reader, []uint64{1}, []*big.Int{new(big.Int)}); err == nil {
+		t.Fatal("expected error for a zero blinding")
+	}
+	if _, _, err := Prove(rand.Reader, []uint64{1, 2}, blindings(t, 1)); err == nil {
+		t.Fatal("expected error for missing blindings")
+	}
+}
+
+func BenchmarkProve(b *testing.B) {
+	gammas := blindings(b, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Prove(rand.Reader, []uint64{123456789}, gammas)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	proof, commitments, err := Prove(rand.Reader, []uint64{123456789}, blindings(b, 1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(commitments, proof)
+	}
+}
+
+func BenchmarkVerify16(b *testing.B) {
+	proof, commitments, err := Prove(rand.Reader, make([]uint64, 16), blindings(b, 16))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(commitments, proof)
+	}
+}
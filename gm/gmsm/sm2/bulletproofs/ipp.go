@@ -0,0 +1,119 @@
+package bulletproofs
+
+import (
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+)
+
+// innerProduct returns <a, b> mod n.
+func innerProduct(a, b []*big.Int) *big.Int {
+	n := ecpoint.Order()
+	sum := new(big.Int)
+	t := new(big.Int)
+	for i := range a {
+		sum.Add(sum, t.Mul(a[i], b[i]))
+	}
+	return sum.Mod(sum, n)
+}
+
+// proveInnerProduct proves knowledge of a and b such that
+//
+//	P = <a, G'> + <b, H'> + <a, b>·u
+//
+// for G'_i = gFactors[i]·gs[i] and H'_i = hFactors[i]·hs[i]. The factors
+// let the range proof use H'_i = y^-i·hs[i] without computing these points;
+// they are folded into the generators in the first round. It returns the
+// L and R points of every round and the final a and b.
+func proveInnerProduct(tr *transcript, gs, hs []*ecpoint.Point, gFactors, hFactors []*big.Int, u *ecpoint.Point, a, b []*big.Int) (ls, rs []*ecpoint.Point, aFinal, bFinal *big.Int) {
+	n := ecpoint.Order()
+	for len(a) > 1 {
+		m := len(a) / 2
+		aLo, aHi := a[:m], a[m:]
+		bLo, bHi := b[:m], b[m:]
+		gLo, gHi := gs[:m], gs[m:]
+		hLo, hHi := hs[:m], hs[m:]
+
+		points := make([]*ecpoint.Point, 0, 2*m+1)
+		scalars := make([]*big.Int, 0, 2*m+1)
+		// L = <a_lo, G'_hi> + <b_hi, H'_lo> + <a_lo, b_hi>·u
+		for i := 0; i < m; i++ {
+			points = append(points, gHi[i], hLo[i])
+			scalars = append(scalars,
+				new(big.Int).Mul(aLo[i], gFactors[m+i]),
+				new(big.Int).Mul(bHi[i], hFactors[i]))
+		}
+		l := msm(append(points, u), append(scalars, innerProduct(aLo, bHi)))
+
+		points, scalars = points[:0], scalars[:0]
+		// R = <a_hi, G'_lo> + <b_lo, H'_hi> + <a_hi, b_lo>·u
+		for i := 0; i < m; i++ {
+			points = append(points, gLo[i], hHi[i])
+			scalars = append(scalars,
+				new(big.Int).Mul(aHi[i], gFactors[i]),
+				new(big.Int).Mul(bLo[i], hFactors[m+i]))
+		}
+		r := msm(append(points, u), append(scalars, innerProduct(aHi, bLo)))
+
+		ls, rs = append(ls, l), append(rs, r)
+		tr.append("L", l.Compress())
+		tr.append("R", r.Compress())
+		x := tr.challenge("u")
+		xInv := new(big.Int).ModInverse(x, n)
+
+		// a' = a_lo·x + a_hi·x^-1, b' = b_lo·x^-1 + b_hi·x,
+		// G' = G_lo·x^-1 + G_hi·x, H' = H_lo·x + H_hi·x^-1
+		a2 := make([]*big.Int, m)
+		b2 := make([]*big.Int, m)
+		gs2 := make([]*ecpoint.Point, m)
+		hs2 := make([]*ecpoint.Point, m)
+		for i := 0; i < m; i++ {
+			a2[i] = new(big.Int).Mul(aLo[i], x)
+			a2[i].Add(a2[i], new(big.Int).Mul(aHi[i], xInv)).Mod(a2[i], n)
+			b2[i] = new(big.Int).Mul(bLo[i], xInv)
+			b2[i].Add(b2[i], new(big.Int).Mul(bHi[i], x)).Mod(b2[i], n)
+			gs2[i] = msm([]*ecpoint.Point{gLo[i], gHi[i]}, []*big.Int{
+				new(big.Int).Mul(xInv, gFactors[i]),
+				new(big.Int).Mul(x, gFactors[m+i])})
+			hs2[i] = msm([]*ecpoint.Point{hLo[i], hHi[i]}, []*big.Int{
+				new(big.Int).Mul(x, hFactors[i]),
+				new(big.Int).Mul(xInv, hFactors[m+i])})
+		}
+		a, b, gs, hs = a2, b2, gs2, hs2
+		gFactors, hFactors = ones(m), ones(m)
+	}
+	return ls, rs, a[0], b[0]
+}
+
+func ones(n int) []*big.Int {
+	v := make([]*big.Int, n)
+	for i := range v {
+		v[i] = big.NewInt(1)
+	}
+	return v
+}
+
+// verificationScalars returns s with s_i = Π_j x_j^(±1), the exponent of
+// x_j being +1 if bit lg(n)-1-j of i is set, so that the generators folded
+// by the prover are G = <s, gs> and H = <s^-1, hs>, s^-1 being s reversed.
+func verificationScalars(xs []*big.Int, size int) []*big.Int {
+	n := ecpoint.Order()
+	s := make([]*big.Int, size)
+	s[0] = big.NewInt(1)
+	for _, x := range xs {
+		s[0].Mul(s[0], x)
+	}
+	s[0].ModInverse(s[0], n)
+	lg := len(xs)
+	for i := 1; i < size; i++ {
+		// The highest set bit of i is k, which corresponds to round lg-1-k.
+		k := 0
+		for 1<<uint(k+1) <= i {
+			k++
+		}
+		x := xs[lg-1-k]
+		s[i] = new(big.Int).Mul(s[i-1<<uint(k)], x)
+		s[i].Mul(s[i], x).Mod(s[i], n)
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+package bulletproofs
+
+import (
+	"encoding/binary"
+	"math/big"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// msm returns Σ scalars[i]·points[i]. The points are generators or were
+// checked by ecpoint.Decompress, so an error means a bug in this package.
+func msm(points []*ecpoint.Point, scalars []*big.Int) *ecpoint.Point {
+	ps := make([]*sm2.Point, len(points))
+	for i, p := range points {
+		ps[i] = &sm2.Point{X: p.X, Y: p.Y}
+	}
+	r, err := sm2.MultiScalarMult(ps, scalars)
+	if err != nil {
+		panic("bulletproofs: " + err.Error())
+	}
+	return &ecpoint.Point{X: r.X, Y: r.Y}
+}
+
+// hashToPoint maps label and index to a point with unknown discrete
+// logarithm by try-and-increment.
+func hashToPoint(label string, index uint32) *ecpoint.Point {
+	var b [8]byte
+	binary.BigEndian.PutUint32(b[:4], index)
+	for ctr := uint32(0); ; ctr++ {
+		binary.BigEndian.PutUint32(b[4:], ctr)
+		h := sm3.New()
+		h.Write([]byte("Bulletproofs/generator"))
+		h.Write([]byte(label))
+		h.Write(b[:])
+		if p := ecpoint.LiftX(new(big.Int).SetBytes(h.Sum(nil)), 0); p != nil {
+			return p
+		}
+	}
+}
+
+// generators are the bases of the commitments: the SM2 base point b for
+// values, h for blindings, the vectors gs and hs for the bits of all
+// aggregated values, and u for the inner product.
+type generators struct {
+	b, h, u *ecpoint.Point
+	gs, hs  []*ecpoint.Point
+}
+
+var (
+	gensOnce sync.Once
+	gens     *generators
+)
+
+func getGenerators() *generators {
+	gensOnce.Do(func() {
+		g := &generators{
+			b:  ecpoint.Generator(),
+			h:  hashToPoint("H", 0),
+			u:  hashToPoint("U", 0),
+			gs: make([]*ecpoint.Point, BitSize*MaxAggregation),
+			hs: make([]*ecpoint.Point, BitSize*MaxAggregation),
+		}
+		for i := range g.gs {
+			g.gs[i] = hashToPoint("G", uint32(i))
+			g.hs[i] = hashToPoint("Hs", uint32(i))
+		}
+		gens = g
+	})
+	return gens
+}
+
+// transcript derives the Fiat-Shamir challenges from everything sent so far.
+type transcript struct {
+	state []byte
+}
+
+func newTranscript() *transcript {
+	return &transcript{state: sm3.Sm3Sum([]byte("Bulletproofs/SM2 range proof"))}
+}
+
+func (t *transcript) append(label string, data []byte) {
+	h := sm3.New()
+	h.Write(t.state)
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(label)))
+	h.Write(b[:])
+	h.Write([]byte(label))
+	binary.BigEndian.PutUint32(b[:], uint32(len(data)))
+	h.Write(b[:])
+	h.Write(data)
+	t.state = h.Sum(nil)
+}
+
+// challenge returns a nonzero scalar and updates the state with it.
+func (t *transcript) challenge(label string) *big.Int {
+	for {
+		t.append(label, nil)
+		c := new(big.Int).SetBytes(t.state)
+		if c.Mod(c, ecpoint.Order()).Sign() != 0 {
+			return c
+		}
+	}
+}
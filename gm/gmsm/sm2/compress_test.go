@@ -0,0 +1,87 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		priv, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := Decompress(Compress(&priv.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+			t.Fatal("round trip changed the key")
+		}
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := Compress(&priv.PublicKey)
+	badParity := append([]byte(nil), good...)
+	badParity[0] = 2
+	xIsP := append([]byte{0}, P256Sm2().Params().P.Bytes()...)
+	// x = 2 gives y^2 = 8 - 6 + b, which has no square root mod p.
+	xTwo := make([]byte, 33)
+	xTwo[32] = 2
+
+	for name, in := range map[string][]byte{
+		"nil":        nil,
+		"empty":      {},
+		"parity":     {0},
+		"short":      good[:32],
+		"long":       append(append([]byte(nil), good...), 0),
+		"parity 2":   badParity,
+		"x = p":      xIsP,
+		"not square": xTwo,
+	} {
+		if pub, err := Decompress(in); err == nil {
+			t.Errorf("%s: accepted as (%x, %x)", name, pub.X, pub.Y)
+		}
+	}
+}
+
+// TestDecompressFuzz feeds random input to Decompress, which must either
+// fail or return a point of the curve that compresses back to the input.
+func TestDecompressFuzz(t *testing.T) {
+	n := 2000
+	if testing.Short() {
+		n = 200
+	}
+	buf := make([]byte, 34)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+		in := buf[:i%35]
+		if len(in) > 0 && i%2 == 0 {
+			in[0] &= 1
+		}
+		if fuzzDecompress(in) < 0 {
+			t.Fatalf("Decompress(%x) returned a bad key", in)
+		}
+	}
+}
+
+// fuzzDecompress returns 1 for input Decompress accepts, 0 for input it
+// rejects, and -1 if it accepts input and returns a wrong key.
+func fuzzDecompress(in []byte) int {
+	pub, err := Decompress(in)
+	if err != nil {
+		return 0
+	}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) || !bytes.Equal(Compress(pub), in) {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,98 @@
+package sm2
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentUse runs operations on shared keys from several goroutines;
+// run it with -race. The keys must come out unchanged.
+func TestConcurrentUse(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	d, x, y := new(big.Int).Set(priv.D), new(big.Int).Set(pub.X), new(big.Int).Set(pub.Y)
+	msg := []byte("shared key")
+	ct, err := Encrypt(pub, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := msgHash(mustZA(t, pub), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sig, err := priv.Sign(nil, digest.Bytes(), nil)
+			if err != nil || !pub.Verify(digest.Bytes(), sig) {
+				errs <- err
+			}
+			if err := pub.Precompute(); err != nil {
+				errs <- err
+			}
+			r, s, err := Sm2Sign(priv, msg, nil)
+			if err != nil || !Sm2Verify(pub, msg, nil, r, s) {
+				errs <- err
+			}
+			sig, err = SignEx(priv, msg)
+			if err == nil {
+				err = VerifyStrict(pub, msg, nil, sig)
+			}
+			if err != nil {
+				errs <- err
+			}
+			if _, err := Encrypt(pub, msg); err != nil {
+				errs <- err
+			}
+			if pt, err := Decrypt(priv, ct); err != nil || !bytes.Equal(pt, msg) {
+				errs <- err
+			}
+			if _, err := priv.SharedSecret(pub); err != nil {
+				errs <- err
+			}
+			if d, err := Decompress(Compress(pub)); err != nil || d.X.Cmp(pub.X) != 0 || d.Y.Cmp(pub.Y) != 0 {
+				errs <- ErrInvalidPublicKey
+			}
+			if err := priv.Validate(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent operation failed: %v", err)
+	}
+	if priv.D.Cmp(d) != 0 || pub.X.Cmp(x) != 0 || pub.Y.Cmp(y) != 0 {
+		t.Fatal("a shared key was modified")
+	}
+}
+
+func mustZA(t *testing.T, pub *PublicKey) []byte {
+	za, err := ZA(pub, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return za
+}
+
+func TestFromBigPlainLeavesInput(t *testing.T) {
+	x := new(big.Int).Set(P256Sm2().Params().Gx)
+	var X sm2P256FieldElement
+	sm2P256FromBigPlain(&X, x)
+	if x.Cmp(P256Sm2().Params().Gx) != 0 {
+		t.Fatal("sm2P256FromBigPlain modified its input")
+	}
+	if sm2P256ToBigPlain(&X).Cmp(x) != 0 {
+		t.Fatal("sm2P256FromBigPlain lost limbs")
+	}
+}
@@ -0,0 +1,158 @@
+// Package dlog implements non-interactive Schnorr proofs of knowledge of the
+// discrete logarithm of an SM2 public key, i.e. of its private key.
+//
+// A proof for X = x·G under a context is R || s with R = k·G for a random k,
+// c = H(X || R || context) and s = k + c·x, where H is a tagged SM3 hash and
+// points are in compressed SEC 1 form. It verifies if s·G = R + c·X.
+// Since the proof carries R rather than c, many proofs can be checked at once
+// with one multi-scalar multiplication by BatchVerify.
+//
+// The context binds a proof to its use, e.g. the session identifier and
+// party index of a DKG run or the account being registered, so that a proof
+// of possession cannot be replayed elsewhere.
+package dlog
+
+import (
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// ProofSize is the size of a proof in bytes.
+const ProofSize = 65
+
+var (
+	errInvalidKey = errors.New("dlog: invalid key")
+	errMalformed  = errors.New("dlog: malformed input")
+)
+
+func publicPoint(pub *sm2.PublicKey) (*ecpoint.Point, error) {
+	p, ok := ecpoint.FromPublicKey(pub)
+	if !ok {
+		return nil, errInvalidKey
+	}
+	return p, nil
+}
+
+// challenge returns H(X || R || context).
+func challenge(pub, r *ecpoint.Point, context []byte) *big.Int {
+	t := sm3.Sm3Sum([]byte("DLog/challenge"))
+	h := sm3.New()
+	h.Write(t)
+	h.Write(t)
+	h.Write(pub.Compress())
+	h.Write(r.Compress())
+	h.Write(context)
+	e := new(big.Int).SetBytes(h.Sum(nil))
+	return e.Mod(e, ecpoint.Order())
+}
+
+// parseProof splits a proof into R and s.
+func parseProof(proof []byte) (*ecpoint.Point, *big.Int, error) {
+	if len(proof) != ProofSize {
+		return nil, nil, errMalformed
+	}
+	r, err := ecpoint.Decompress(proof[:33], false)
+	if err != nil {
+		return nil, nil, errMalformed
+	}
+	s := new(big.Int).SetBytes(proof[33:])
+	if s.Cmp(ecpoint.Order()) >= 0 {
+		return nil, nil, errMalformed
+	}
+	return r, s, nil
+}
+
+// Prove returns a proof of knowledge of the private key of priv under
+// context.
+func Prove(rand io.Reader, priv *sm2.PrivateKey, context []byte) ([]byte, error) {
+	if priv == nil || priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(ecpoint.Order()) >= 0 {
+		return nil, errInvalidKey
+	}
+	k, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	r := ecpoint.BaseMul(k)
+	c := challenge(ecpoint.BaseMul(priv.D), r, context)
+	// s = k + c·x
+	s := new(big.Int).Mul(c, priv.D)
+	s.Add(s, k)
+	s.Mod(s, ecpoint.Order())
+	return append(r.Compress(), ecpoint.ScalarBytes(s)...), nil
+}
+
+// Verify reports whether proof is a valid proof of knowledge of the private
+// key of pub under context.
+func Verify(pub *sm2.PublicKey, context, proof []byte) bool {
+	x, err := publicPoint(pub)
+	if err != nil {
+		return false
+	}
+	r, s, err := parseProof(proof)
+	if err != nil {
+		return false
+	}
+	c := challenge(x, r, context)
+	want := r.Add(x.Mul(c))
+	got := ecpoint.BaseMul(s)
+	return got.Equal(want)
+}
+
+// BatchVerify reports whether all proofs are valid, proofs[i] being a proof
+// for pubs[i] under contexts[i]. It checks the random linear combination
+// Σ a_i·(s_i·G - R_i - c_i·X_i) = 0 with 128-bit a_i from rand, or from
+// sm2.RandSource() if rand is nil, which a batch with an invalid proof passes
+// with probability 2^-128. It does not tell which proofs are invalid; call
+// Verify on each proof for that.
+func BatchVerify(rand io.Reader, pubs []*sm2.PublicKey, contexts, proofs [][]byte) (bool, error) {
+	if len(pubs) != len(contexts) || len(pubs) != len(proofs) {
+		return false, errors.New("dlog: batch slices differ in length")
+	}
+	if rand == nil {
+		rand = sm2.RandSource()
+	}
+	n := ecpoint.Order()
+	points := make([]*sm2.Point, 0, 2*len(pubs)+1)
+	scalars := make([]*big.Int, 0, 2*len(pubs)+1)
+	sum := new(big.Int)
+	buf := make([]byte, 16)
+	for i, pub := range pubs {
+		x, err := publicPoint(pub)
+		if err != nil {
+			return false, nil
+		}
+		r, s, err := parseProof(proofs[i])
+		if err != nil {
+			return false, nil
+		}
+		// The first proof needs no randomizer.
+		a := big.NewInt(1)
+		if i > 0 {
+			if _, err := io.ReadFull(rand, buf); err != nil {
+				return false, err
+			}
+			a.SetBytes(buf)
+		}
+		c := challenge(x, r, contexts[i])
+		ac := new(big.Int).Mul(a, c)
+		points = append(points, &sm2.Point{X: r.X, Y: r.Y}, &sm2.Point{X: x.X, Y: x.Y})
+		scalars = append(scalars, new(big.Int).Sub(n, a), ac.Sub(n, ac.Mod(ac, n)))
+		sum.Add(sum, new(big.Int).Mul(a, s))
+	}
+	if len(pubs) == 0 {
+		return true, nil
+	}
+	g := ecpoint.Generator()
+	points = append(points, &sm2.Point{X: g.X, Y: g.Y})
+	scalars = append(scalars, sum.Mod(sum, n))
+	res, err := sm2.MultiScalarMult(points, scalars)
+	if err != nil {
+		return false, err
+	}
+	return res.X.Sign() == 0 && res.Y.Sign() == 0, nil
+}
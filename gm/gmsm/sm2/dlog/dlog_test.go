@@ -0,0 +1,105 @@
+package dlog
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestProve(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := []byte("dkg session 1, party 3")
+	proof, err := Prove(rand.Reader, priv, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof) != ProofSize {
+		t.Fatalf("proof is %d bytes, want %d", len(proof), ProofSize)
+	}
+	if !Verify(&priv.PublicKey, ctx, proof) {
+		t.Fatal("valid proof rejected")
+	}
+
+	if Verify(&priv.PublicKey, []byte("dkg session 1, party 4"), proof) {
+		t.Fatal("proof accepted under another context")
+	}
+	other, _ := sm2.GenerateKey()
+	if Verify(&other.PublicKey, ctx, proof) {
+		t.Fatal("proof accepted for another key")
+	}
+	for i := range proof {
+		bad := append([]byte(nil), proof...)
+		bad[i] ^= 1
+		if Verify(&priv.PublicKey, ctx, bad) {
+			t.Fatalf("proof with byte %d flipped accepted", i)
+		}
+	}
+	if Verify(&priv.PublicKey, ctx, proof[:ProofSize-1]) {
+		t.Fatal("truncated proof accepted")
+	}
+}
+
+func batch(t testing.TB, n int) ([]*sm2.PublicKey, [][]byte, [][]byte) {
+	pubs := make([]*sm2.PublicKey, n)
+	ctxs := make([][]byte, n)
+	proofs := make([][]byte, n)
+	for i := range pubs {
+		priv, err := sm2.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs[i] = &priv.PublicKey
+		ctxs[i] = []byte(fmt.Sprintf("registration %d", i))
+		if proofs[i], err = Prove(rand.Reader, priv, ctxs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return pubs, ctxs, proofs
+}
+
+func TestBatchVerify(t *testing.T) {
+	pubs, ctxs, proofs := batch(t, 20)
+	if ok, err := BatchVerify(nil, pubs, ctxs, proofs); err != nil || !ok {
+		t.Fatalf("valid batch rejected: %v", err)
+	}
+	if ok, err := BatchVerify(nil, nil, nil, nil); err != nil || !ok {
+		t.Fatalf("empty batch rejected: %v", err)
+	}
+
+	// Swapping two proofs keeps every equation well formed but wrong.
+	proofs[3], proofs[7] = proofs[7], proofs[3]
+	if ok, _ := BatchVerify(nil, pubs, ctxs, proofs); ok {
+		t.Fatal("batch with swapped proofs accepted")
+	}
+	proofs[3], proofs[7] = proofs[7], proofs[3]
+
+	ctxs[0] = []byte("other")
+	if ok, _ := BatchVerify(nil, pubs, ctxs, proofs); ok {
+		t.Fatal("batch with a wrong context accepted")
+	}
+
+	if _, err := BatchVerify(nil, pubs, ctxs[1:], proofs); err == nil {
+		t.Fatal("expected error for mismatched slices")
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	pubs, ctxs, proofs := batch(b, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(pubs[0], ctxs[0], proofs[0])
+	}
+}
+
+func BenchmarkBatchVerify64(b *testing.B) {
+	pubs, ctxs, proofs := batch(b, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BatchVerify(nil, pubs, ctxs, proofs)
+	}
+}
@@ -0,0 +1,185 @@
+// Package elgamal implements ElGamal encryption over the SM2 curve.
+//
+// A message point M is encrypted to the public key X = x·G as
+//
+//	(C1, C2) = (k·G, M + k·X)
+//
+// for a random k, and decrypted as M = C2 - x·C1. Unlike the KDF-based SM2
+// encryption, ciphertexts have algebraic structure that mixing and
+// verifiable shuffle protocols rely on:
+//
+//   - Rerandomize adds an encryption of the point at infinity, giving a
+//     fresh ciphertext of the same message that cannot be linked to the
+//     original without the private key.
+//   - Add adds two ciphertexts componentwise, giving an encryption of the
+//     sum of their messages.
+//
+// EncryptInt encrypts an integer m as the point m·G (exponential ElGamal),
+// so that Add of two such ciphertexts encrypts the sum of the integers.
+// DecryptInt recovers m with a baby-step giant-step search, which is only
+// feasible for small m: it takes about √max additions and as much memory.
+//
+// ElGamal is malleable by design, hence only secure against passive
+// attackers; protocols that accept ciphertexts from others must prove that
+// they are well formed.
+package elgamal
+
+import (
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+)
+
+// CiphertextSize is the size of an encoded ciphertext in bytes.
+const CiphertextSize = 66
+
+var (
+	errInvalidKey = errors.New("elgamal: invalid key")
+	errMalformed  = errors.New("elgamal: malformed ciphertext")
+	errPoint      = errors.New("elgamal: message is not a point on the curve")
+	// ErrOutOfRange is returned by DecryptInt if the plaintext is not an
+	// integer in [0, max].
+	ErrOutOfRange = errors.New("elgamal: plaintext out of range")
+)
+
+func publicPoint(pub *sm2.PublicKey) (*ecpoint.Point, error) {
+	p, ok := ecpoint.FromPublicKey(pub)
+	if !ok {
+		return nil, errInvalidKey
+	}
+	return p, nil
+}
+
+// Ciphertext is an ElGamal ciphertext (C1, C2).
+type Ciphertext struct {
+	c1, c2 *ecpoint.Point
+}
+
+// encrypt returns (k·G, m + k·X) for a random k.
+func encrypt(rand io.Reader, pub *sm2.PublicKey, m *ecpoint.Point) (*Ciphertext, error) {
+	x, err := publicPoint(pub)
+	if err != nil {
+		return nil, err
+	}
+	k, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &Ciphertext{ecpoint.BaseMul(k), m.Add(x.Mul(k))}, nil
+}
+
+func decrypt(priv *sm2.PrivateKey, ct *Ciphertext) (*ecpoint.Point, error) {
+	if priv == nil || priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(ecpoint.Order()) >= 0 {
+		return nil, errInvalidKey
+	}
+	// M = C2 - x·C1
+	return ct.c2.Add(ct.c1.Mul(priv.D).Neg()), nil
+}
+
+// Encrypt encrypts the point m, which must be on the curve or the point at
+// infinity (0, 0), to pub.
+func Encrypt(rand io.Reader, pub *sm2.PublicKey, m *sm2.Point) (*Ciphertext, error) {
+	if m == nil {
+		return nil, errPoint
+	}
+	p, ok := ecpoint.FromAffine(m.X, m.Y)
+	if !ok {
+		return nil, errPoint
+	}
+	return encrypt(rand, pub, &ecpoint.Point{X: new(big.Int).Set(p.X), Y: new(big.Int).Set(p.Y)})
+}
+
+// Decrypt returns the point encrypted by ct.
+func Decrypt(priv *sm2.PrivateKey, ct *Ciphertext) (*sm2.Point, error) {
+	m, err := decrypt(priv, ct)
+	if err != nil {
+		return nil, err
+	}
+	return &sm2.Point{X: m.X, Y: m.Y}, nil
+}
+
+// EncryptInt encrypts the integer m as the point m·G to pub.
+func EncryptInt(rand io.Reader, pub *sm2.PublicKey, m uint64) (*Ciphertext, error) {
+	return encrypt(rand, pub, ecpoint.BaseMul(new(big.Int).SetUint64(m)))
+}
+
+// DecryptInt returns the integer encrypted by ct with EncryptInt, or by Add
+// of such ciphertexts, if it is at most max, and ErrOutOfRange otherwise.
+func DecryptInt(priv *sm2.PrivateKey, ct *Ciphertext, max uint64) (uint64, error) {
+	m, err := decrypt(priv, ct)
+	if err != nil {
+		return 0, err
+	}
+	return discreteLog(m, max)
+}
+
+// discreteLog returns v in [0, max] with v·G = m by baby-step giant-step:
+// with s = ⌈√(max+1)⌉, it looks up m - i·s·G for i = 0, 1, ... in a table of
+// j·G for j in [0, s).
+func discreteLog(m *ecpoint.Point, max uint64) (uint64, error) {
+	s := uint64(1)
+	for s*s <= max && s < 1<<32 {
+		s++
+	}
+	g := ecpoint.Generator()
+	table := make(map[string]uint64, s)
+	p := ecpoint.Infinity()
+	for j := uint64(0); j < s; j++ {
+		table[string(p.Compress())] = j
+		p = p.Add(g)
+	}
+	// p = s·G
+	step := p.Neg()
+	for i := uint64(0); i <= max/s; i++ {
+		if j, ok := table[string(m.Compress())]; ok {
+			if v := i*s + j; v <= max {
+				return v, nil
+			}
+			return 0, ErrOutOfRange
+		}
+		m = m.Add(step)
+	}
+	return 0, ErrOutOfRange
+}
+
+// Rerandomize returns a fresh encryption under pub of the message of ct.
+func Rerandomize(rand io.Reader, pub *sm2.PublicKey, ct *Ciphertext) (*Ciphertext, error) {
+	zero, err := encrypt(rand, pub, ecpoint.Infinity())
+	if err != nil {
+		return nil, err
+	}
+	return Add(ct, zero), nil
+}
+
+// Add returns an encryption of the sum of the messages of a and b, which
+// must be encrypted to the same key. The result is as random as the more
+// random of a and b; call Rerandomize on it before publishing it if both
+// were known to others.
+func Add(a, b *Ciphertext) *Ciphertext {
+	return &Ciphertext{a.c1.Add(b.c1), a.c2.Add(b.c2)}
+}
+
+// Marshal returns the encoding C1 || C2 of the ciphertext, both points in
+// compressed SEC 1 form with 33 zero bytes for the point at infinity.
+func (ct *Ciphertext) Marshal() []byte {
+	return append(ct.c1.Compress(), ct.c2.Compress()...)
+}
+
+// Parse parses a ciphertext encoded by Marshal.
+func Parse(b []byte) (*Ciphertext, error) {
+	if len(b) != CiphertextSize {
+		return nil, errMalformed
+	}
+	c1, err := ecpoint.Decompress(b[:33], true)
+	if err != nil {
+		return nil, errMalformed
+	}
+	c2, err := ecpoint.Decompress(b[33:], true)
+	if err != nil {
+		return nil, errMalformed
+	}
+	return &Ciphertext{c1, c2}, nil
+}
@@ -0,0 +1,144 @@
+package elgamal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+)
+
+func TestEncrypt(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := big.NewInt(123456789)
+	x, y := sm2.P256Sm2().ScalarBaseMult(k.Bytes())
+	m := &sm2.Point{X: x, Y: y}
+
+	ct, err := Encrypt(rand.Reader, &priv.PublicKey, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decrypt(priv, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.X.Cmp(x) != 0 || got.Y.Cmp(y) != 0 {
+		t.Fatal("decrypted point differs")
+	}
+
+	ct2, err := Parse(ct.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ct2.Marshal(), ct.Marshal()) {
+		t.Fatal("ciphertext changed by Marshal and Parse")
+	}
+
+	other, _ := sm2.GenerateKey()
+	if got, _ := Decrypt(other, ct); got.X.Cmp(x) == 0 {
+		t.Fatal("ciphertext decrypted with another key")
+	}
+	if _, err := Encrypt(rand.Reader, &priv.PublicKey, &sm2.Point{X: x, Y: new(big.Int).Add(y, big.NewInt(1))}); err == nil {
+		t.Fatal("expected error for a point not on the curve")
+	}
+}
+
+func TestRerandomize(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	ct, err := EncryptInt(rand.Reader, &priv.PublicKey, 77)
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := Rerandomize(rand.Reader, &priv.PublicKey, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(re.Marshal()[:33], ct.Marshal()[:33]) || bytes.Equal(re.Marshal()[33:], ct.Marshal()[33:]) {
+		t.Fatal("rerandomized ciphertext shares a component with the original")
+	}
+	if v, err := DecryptInt(priv, re, 1000); err != nil || v != 77 {
+		t.Fatalf("rerandomized ciphertext decrypts to %d, %v", v, err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	pub := &priv.PublicKey
+	sum, err := EncryptInt(rand.Reader, pub, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := uint64(0)
+	for _, v := range []uint64{1, 1, 500, 65535, 3} {
+		ct, err := EncryptInt(rand.Reader, pub, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum = Add(sum, ct)
+		want += v
+	}
+	if v, err := DecryptInt(priv, sum, 1<<20); err != nil || v != want {
+		t.Fatalf("sum decrypts to %d, %v; want %d", v, err, want)
+	}
+	if _, err := DecryptInt(priv, sum, want-1); err != ErrOutOfRange {
+		t.Fatalf("expected ErrOutOfRange, got %v", err)
+	}
+
+	// Adding a ciphertext to itself doubles both components.
+	one, _ := EncryptInt(rand.Reader, pub, 1)
+	if v, err := DecryptInt(priv, Add(one, one), 10); err != nil || v != 2 {
+		t.Fatalf("doubled ciphertext decrypts to %d, %v", v, err)
+	}
+}
+
+func TestDiscreteLog(t *testing.T) {
+	for _, max := range []uint64{0, 1, 2, 15, 16, 17, 1000} {
+		for _, v := range []uint64{0, 1, max / 2, max} {
+			if v > max {
+				continue
+			}
+			got, err := discreteLog(ecpoint.BaseMul(new(big.Int).SetUint64(v)), max)
+			if err != nil || got != v {
+				t.Fatalf("discreteLog(%d·G, %d) = %d, %v", v, max, got, err)
+			}
+		}
+		if _, err := discreteLog(ecpoint.BaseMul(new(big.Int).SetUint64(max+1)), max); err != ErrOutOfRange {
+			t.Fatalf("discreteLog(%d·G, %d) returned %v", max+1, max, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse(make([]byte, CiphertextSize-1)); err == nil {
+		t.Fatal("expected error for a short ciphertext")
+	}
+	b := make([]byte, CiphertextSize)
+	if _, err := Parse(b); err != nil {
+		t.Fatalf("encryption of infinity with k = 0 rejected: %v", err)
+	}
+	b[1] = 1
+	if _, err := Parse(b); err == nil {
+		t.Fatal("expected error for a malformed point")
+	}
+}
+
+func BenchmarkEncryptInt(b *testing.B) {
+	priv, _ := sm2.GenerateKey()
+	for i := 0; i < b.N; i++ {
+		EncryptInt(rand.Reader, &priv.PublicKey, 42)
+	}
+}
+
+func BenchmarkDecryptInt(b *testing.B) {
+	priv, _ := sm2.GenerateKey()
+	ct, _ := EncryptInt(rand.Reader, &priv.PublicKey, 1<<20-1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DecryptInt(priv, ct, 1<<20)
+	}
+}
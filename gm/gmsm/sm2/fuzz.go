@@ -0,0 +1,21 @@
+//go:build gofuzz
+// +build gofuzz
+
+package sm2
+
+import "bytes"
+
+// Fuzz is the entry point of go-fuzz for Decompress, which must either fail
+// or return a point of the curve that compresses back to data:
+//
+//	go-fuzz-build github.com/xuperchain/crypto/gm/gmsm/sm2
+func Fuzz(data []byte) int {
+	pub, err := Decompress(data)
+	if err != nil {
+		return 0
+	}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) || !bytes.Equal(Compress(pub), data) {
+		panic("sm2: Decompress returned a bad key")
+	}
+	return 1
+}
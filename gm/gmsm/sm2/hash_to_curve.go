@@ -0,0 +1,174 @@
+package sm2
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// HashToCurveSuite is the suite identifier of HashToCurve in the naming
+// scheme of RFC 9380. Protocols should derive their domain separation tags
+// from it, e.g. "MYAPP-V01-CS01-with-" + HashToCurveSuite.
+const HashToCurveSuite = "SM2P256_XMD:SM3_SSWU_RO_"
+
+// The parameters of the suite: Z is the constant of the simplified SWU map
+// chosen by the procedure of RFC 9380 appendix H.2, and L the number of
+// bytes hashed per field element, ⌈(256 + 128) / 8⌉ for 128-bit security.
+const (
+	sswuZ = -9
+	h2cL  = 48
+)
+
+// HashToCurve hashes msg to a point on the curve, as hash_to_curve of RFC
+// 9380 with expand_message_xmd using SM3 and the simplified SWU map, which
+// applies to P256Sm2 directly since its A and B are nonzero. The point has
+// no known discrete logarithm with respect to the base point or any other
+// hashed point, so it may serve as an independent generator, e.g. for
+// Pedersen commitments.
+//
+// dst is the domain separation tag that makes the hash of one protocol
+// independent of all others; it should be unique to the protocol and
+// nonempty. A tag longer than 255 bytes is hashed first, as the RFC
+// specifies.
+//
+// The result is the point at infinity (0, 0) with negligible probability.
+// HashToCurve is not constant time, so msg should not be secret.
+func HashToCurve(msg, dst []byte) *Point {
+	u := hashToField(msg, dst, 2)
+	x0, y0 := mapToCurveSSWU(u[0])
+	x1, y1 := mapToCurveSSWU(u[1])
+	c := P256Sm2()
+	switch {
+	case x0.Cmp(x1) != 0:
+		x, y := c.Add(x0, y0, x1, y1)
+		return &Point{x, y}
+	case y0.Cmp(y1) == 0:
+		x, y := c.Double(x0, y0)
+		return &Point{x, y}
+	}
+	return &Point{new(big.Int), new(big.Int)}
+}
+
+// expandMessageXMD is expand_message_xmd of RFC 9380 section 5.3.1 with
+// the hash function returned by newHash, which is sm3.New for HashToCurve.
+// n must be at most 255 times the hash size.
+func expandMessageXMD(newHash func() hash.Hash, msg, dst []byte, n int) []byte {
+	if len(dst) > 255 {
+		h := newHash()
+		h.Write([]byte("H2C-OVERSIZE-DST-"))
+		h.Write(dst)
+		dst = h.Sum(nil)
+	}
+	dstPrime := append(append([]byte(nil), dst...), byte(len(dst)))
+	h := newHash()
+	size := h.Size()
+	ell := (n + size - 1) / size
+
+	h.Write(make([]byte, h.BlockSize()))
+	h.Write(msg)
+	var lib [2]byte
+	binary.BigEndian.PutUint16(lib[:], uint16(n))
+	h.Write(lib[:])
+	h.Write([]byte{0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h = newHash()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+	out := append(make([]byte, 0, ell*size), bi...)
+	for i := 2; i <= ell; i++ {
+		x := make([]byte, size)
+		for j := range x {
+			x[j] = b0[j] ^ bi[j]
+		}
+		h = newHash()
+		h.Write(x)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		out = append(out, bi...)
+	}
+	return out[:n]
+}
+
+// hashToField returns count elements of the base field derived from msg.
+func hashToField(msg, dst []byte, count int) []*big.Int {
+	p := P256Sm2().Params().P
+	b := expandMessageXMD(sm3.New, msg, dst, count*h2cL)
+	u := make([]*big.Int, count)
+	for i := range u {
+		u[i] = new(big.Int).SetBytes(b[i*h2cL : (i+1)*h2cL])
+		u[i].Mod(u[i], p)
+	}
+	return u
+}
+
+// mapToCurveSSWU is the simplified SWU map of RFC 9380 section 6.6.2.
+func mapToCurveSSWU(u *big.Int) (x, y *big.Int) {
+	params := P256Sm2().Params()
+	p, b := params.P, params.B
+	a := new(big.Int).Sub(p, big.NewInt(3))
+	z := new(big.Int).Add(p, big.NewInt(sswuZ))
+
+	// tv1 = 1 / (Z²·u⁴ + Z·u²), 0 if the denominator is 0
+	zu2 := new(big.Int).Mul(u, u)
+	zu2.Mul(zu2, z).Mod(zu2, p)
+	tv1 := new(big.Int).Mul(zu2, zu2)
+	tv1.Add(tv1, zu2).Mod(tv1, p)
+	if tv1.Sign() != 0 {
+		tv1.ModInverse(tv1, p)
+	}
+
+	// x1 = (-B / A)·(1 + tv1), or B / (Z·A) if tv1 = 0
+	x1 := new(big.Int)
+	if tv1.Sign() == 0 {
+		x1.Mul(z, a).Mod(x1, p)
+		x1.ModInverse(x1, p).Mul(x1, b)
+	} else {
+		x1.ModInverse(a, p)
+		x1.Mul(x1, b).Neg(x1)
+		x1.Mul(x1, tv1.Add(tv1, big.NewInt(1)))
+	}
+	x1.Mod(x1, p)
+
+	x = x1
+	y = curveSqrt(polynomial(x1))
+	if y == nil {
+		// x2 = Z·u²·x1, for which g(x2) is a square.
+		x = new(big.Int).Mul(zu2, x1)
+		x.Mod(x, p)
+		y = curveSqrt(polynomial(x))
+	}
+	if y.Bit(0) != u.Bit(0) {
+		y.Sub(p, y)
+	}
+	return x, y
+}
+
+// polynomial returns x³ - 3x + b.
+func polynomial(x *big.Int) *big.Int {
+	params := P256Sm2().Params()
+	y2 := new(big.Int).Mul(x, x)
+	y2.Sub(y2, big.NewInt(3))
+	y2.Mul(y2, x)
+	y2.Add(y2, params.B)
+	return y2.Mod(y2, params.P)
+}
+
+// curveSqrt returns a square root of a mod P, or nil if a is not a square.
+// P ≡ 3 mod 4, so the root is a^((P+1)/4).
+func curveSqrt(a *big.Int) *big.Int {
+	p := P256Sm2().Params().P
+	e := new(big.Int).Add(p, big.NewInt(1))
+	e.Rsh(e, 2)
+	y := new(big.Int).Exp(a, e, p)
+	if y2 := new(big.Int).Mul(y, y); y2.Mod(y2, p).Cmp(a) != 0 {
+		return nil
+	}
+	return y
+}
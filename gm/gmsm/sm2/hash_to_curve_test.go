@@ -0,0 +1,95 @@
+package sm2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// The expand_message_xmd vectors of RFC 9380 appendix K.1 for SHA-256; the
+// expander is the same for SM3, which has the same output and block size.
+func TestExpandMessageXMD(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	vectors := []struct {
+		msg  string
+		n    int
+		want string
+	}{
+		{"", 0x20, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", 0x20, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+	}
+	for _, v := range vectors {
+		got := hex.EncodeToString(expandMessageXMD(sha256.New, []byte(v.msg), dst, v.n))
+		if got != v.want {
+			t.Errorf("expand_message_xmd(%q, %d) = %s, want %s", v.msg, v.n, got, v.want)
+		}
+	}
+}
+
+func TestHashToCurve(t *testing.T) {
+	c := P256Sm2()
+	dst := []byte("XUPER-V01-CS01-with-" + HashToCurveSuite)
+	seen := make(map[string]bool)
+	for _, msg := range []string{"", "abc", "abcdef0123456789", string(make([]byte, 1000))} {
+		p := HashToCurve([]byte(msg), dst)
+		if !c.IsOnCurve(p.X, p.Y) {
+			t.Fatalf("hash of %q is not on the curve", msg)
+		}
+		q := HashToCurve([]byte(msg), dst)
+		if p.X.Cmp(q.X) != 0 || p.Y.Cmp(q.Y) != 0 {
+			t.Fatalf("hash of %q is not deterministic", msg)
+		}
+		if seen[p.X.String()] {
+			t.Fatalf("hash of %q collides", msg)
+		}
+		seen[p.X.String()] = true
+	}
+
+	// Pinned so that the suite does not change unnoticed.
+	p := HashToCurve([]byte("abc"), dst)
+	if x := hex.EncodeToString(p.X.Bytes()); x != "867a480bfdf9796de711156bf409b69547263de61c5fd31e0910d042911eb550" {
+		t.Fatalf("hash of \"abc\" has x = %s", x)
+	}
+	if y := hex.EncodeToString(p.Y.Bytes()); y != "e9eac1b9416ab0e4594bcf78d050b57c500061df8c38b9472c8d17745d892202" {
+		t.Fatalf("hash of \"abc\" has y = %s", y)
+	}
+	q := HashToCurve([]byte("abc"), []byte("XUPER-V01-CS02-with-"+HashToCurveSuite))
+	if p.X.Cmp(q.X) == 0 {
+		t.Fatal("hashes under different tags are equal")
+	}
+
+	// Tags longer than 255 bytes are hashed rather than rejected.
+	long := make([]byte, 300)
+	if p := HashToCurve([]byte("abc"), long); !c.IsOnCurve(p.X, p.Y) {
+		t.Fatal("hash under a long tag is not on the curve")
+	}
+}
+
+func TestMapToCurveSSWU(t *testing.T) {
+	c := P256Sm2()
+	p := c.Params().P
+	for _, u := range []*big.Int{
+		big.NewInt(0), // the exceptional case tv1 = 0
+		big.NewInt(1),
+		big.NewInt(2),
+		new(big.Int).Sub(p, big.NewInt(1)),
+		new(big.Int).Rsh(p, 1),
+	} {
+		x, y := mapToCurveSSWU(u)
+		if !c.IsOnCurve(x, y) {
+			t.Fatalf("map of %v is not on the curve", u)
+		}
+		if y.Bit(0) != u.Bit(0) {
+			t.Fatalf("map of %v has the wrong sign", u)
+		}
+	}
+}
+
+func BenchmarkHashToCurve(b *testing.B) {
+	dst := []byte("XUPER-V01-CS01-with-" + HashToCurveSuite)
+	msg := []byte("benchmark")
+	for i := 0; i < b.N; i++ {
+		HashToCurve(msg, dst)
+	}
+}
@@ -0,0 +1,148 @@
+package sm2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/xuperchain/crypto/gm/gmsm/ctutil"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Init builds the curve state that P256Sm2 otherwise builds on its first
+// call, and with it the first key generation, signature or encryption.
+// Calling it again has no effect.
+func Init() {
+	P256Sm2()
+}
+
+// Warmup calls Init and builds the comb table of each of keys with
+// Precompute, unless the key already has one, for example from LoadTables.
+// A service that knows the keys it verifies signatures of calls it at
+// startup, so that no request pays for the tables.
+func Warmup(keys ...*PublicKey) error {
+	Init()
+	for _, pub := range keys {
+		if pub.combTable() != nil {
+			continue
+		}
+		if err := pub.Precompute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A table file is the header, a record per key and the SM3 hash of all
+// that precedes it. A record is x || y, 32 bytes each, followed by the comb
+// table, each limb little-endian.
+const (
+	tableMagic      = "SM2COMB1"
+	tableHeaderSize = len(tableMagic) + 4
+	tableRecordSize = 64 + 4*sm2P256CombSize
+)
+
+var errTableFile = errors.New("sm2: malformed or corrupted table file")
+
+// WriteTables builds the comb tables of keys, as Precompute does, and writes
+// them to w in the format LoadTables reads. Keys with a table reuse it.
+func WriteTables(w io.Writer, keys []*PublicKey) error {
+	if err := Warmup(keys...); err != nil {
+		return err
+	}
+	h := sm3.New()
+	bw := bufio.NewWriter(io.MultiWriter(w, h))
+
+	var header [tableHeaderSize]byte
+	copy(header[:], tableMagic)
+	binary.LittleEndian.PutUint32(header[len(tableMagic):], uint32(len(keys)))
+	bw.Write(header[:])
+	var record [tableRecordSize]byte
+	for _, pub := range keys {
+		t, _ := pub.precomputed.Load().(*sm2P256KeyTable)
+		copy(record[:32], ctutil.FixedBytes(t.x, 32))
+		copy(record[32:64], ctutil.FixedBytes(t.y, 32))
+		for i, limb := range t.table {
+			binary.LittleEndian.PutUint32(record[64+4*i:], limb)
+		}
+		bw.Write(record[:])
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(h.Sum(nil))
+	return err
+}
+
+// LoadTables reads a file written by WriteTables, memory-mapping it where
+// the platform allows, and gives each of keys the table the file holds for
+// it, as Precompute would. It returns the number of keys given a table;
+// the others are left as they are.
+//
+// The file is checked against corruption, not forgery: a table that does
+// not match its key makes Verify accept forged signatures, so the file must
+// be as trusted as the list of keys itself.
+func LoadTables(path string, keys []*PublicKey) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() < int64(tableHeaderSize+sm3.Size) {
+		return 0, errTableFile
+	}
+	data, unmap, err := mapFile(f, int(fi.Size()))
+	if err != nil {
+		return 0, err
+	}
+	defer unmap()
+	return loadTables(data, keys)
+}
+
+func loadTables(data []byte, keys []*PublicKey) (int, error) {
+	if len(data) < tableHeaderSize+sm3.Size || string(data[:len(tableMagic)]) != tableMagic {
+		return 0, errTableFile
+	}
+	count := binary.LittleEndian.Uint32(data[len(tableMagic):])
+	body := len(data) - sm3.Size
+	if uint64(body-tableHeaderSize) != uint64(count)*tableRecordSize ||
+		!bytes.Equal(sm3.Sm3Sum(data[:body]), data[body:]) {
+		return 0, errTableFile
+	}
+
+	records := make(map[[64]byte][]byte, count)
+	for off := tableHeaderSize; off < body; off += tableRecordSize {
+		var xy [64]byte
+		copy(xy[:], data[off:off+64])
+		records[xy] = data[off+64 : off+tableRecordSize]
+	}
+
+	loaded := 0
+	for _, pub := range keys {
+		if _, ok := pub.Curve.(sm2P256Curve); !ok || pub.Validate() != nil {
+			continue
+		}
+		var xy [64]byte
+		copy(xy[:32], ctutil.FixedBytes(pub.X, 32))
+		copy(xy[32:], ctutil.FixedBytes(pub.Y, 32))
+		limbs, ok := records[xy]
+		if !ok {
+			continue
+		}
+		t := &sm2P256KeyTable{x: new(big.Int).Set(pub.X), y: new(big.Int).Set(pub.Y)}
+		for i := range t.table {
+			t.table[i] = binary.LittleEndian.Uint32(limbs[4*i:])
+		}
+		pub.precomputed.Store(t)
+		loaded++
+	}
+	return loaded, nil
+}
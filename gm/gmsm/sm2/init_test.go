@@ -0,0 +1,95 @@
+package sm2
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWarmupAndTables(t *testing.T) {
+	Init()
+	Init()
+	var keys []*PublicKey
+	var privs []*PrivateKey
+	for i := 0; i < 3; i++ {
+		priv, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		keys = append(keys, &priv.PublicKey)
+	}
+	if err := Warmup(keys[:2]...); err != nil {
+		t.Fatal(err)
+	}
+	if keys[0].combTable() == nil || keys[2].combTable() != nil {
+		t.Fatal("Warmup did not precompute exactly the keys given")
+	}
+
+	var file bytes.Buffer
+	if err := WriteTables(&file, keys[:2]); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "sm2tables")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tables")
+	if err := ioutil.WriteFile(path, file.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := make([]*PublicKey, len(keys))
+	for i, pub := range keys {
+		fresh[i] = &PublicKey{Curve: pub.Curve, X: new(big.Int).Set(pub.X), Y: new(big.Int).Set(pub.Y)}
+	}
+	n, err := LoadTables(path, fresh)
+	if err != nil || n != 2 {
+		t.Fatalf("loaded %d tables: %v", n, err)
+	}
+	if fresh[2].combTable() != nil {
+		t.Fatal("table loaded for a key not in the file")
+	}
+	for i := 0; i < 2; i++ {
+		if !equalUint32s(fresh[i].combTable(), keys[i].combTable()) {
+			t.Fatalf("key %d: loaded table differs", i)
+		}
+		msg := []byte("warm")
+		r, s, err := Sm2Sign(privs[i], msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Sm2Verify(fresh[i], msg, nil, r, s) {
+			t.Fatalf("key %d: signature fails with loaded table", i)
+		}
+	}
+
+	data := file.Bytes()
+	corrupted := append([]byte(nil), data...)
+	corrupted[100] ^= 1
+	for name, d := range map[string][]byte{
+		"corrupted": corrupted,
+		"truncated": data[:len(data)-1],
+		"empty":     nil,
+	} {
+		if _, err := loadTables(d, fresh); err != errTableFile {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func equalUint32s(a, b []uint32) bool {
+	if len(a) != len(b) || a == nil {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+// Package ecpoint implements the affine point arithmetic, encoding and
+// validation shared by the protocols built on the SM2 curve, so that every
+// point they decode from untrusted input goes through the same checks.
+//
+// Points are in compressed SEC 1 form and scalars are 32 bytes, big endian.
+// The point at infinity is (0, 0) and encodes to 33 zero bytes, which only
+// decode back to it where a protocol allows it.
+package ecpoint
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+const (
+	// Size is the size of a compressed point in bytes.
+	Size = 33
+	// ScalarSize is the size of an encoded scalar in bytes.
+	ScalarSize = 32
+)
+
+var (
+	// ErrInvalidPoint is returned by Decompress for an encoding that is not
+	// of a point on the curve, or of the point at infinity where it is not
+	// allowed.
+	ErrInvalidPoint = errors.New("ecpoint: invalid point encoding")
+	// ErrInvalidScalar is returned by ParseScalar for an encoding that is
+	// not of a scalar less than the order.
+	ErrInvalidScalar = errors.New("ecpoint: invalid scalar encoding")
+)
+
+// Point is a point on the SM2 curve in affine coordinates; (0, 0) is the
+// point at infinity. The functions of this package only return points on
+// the curve.
+type Point struct {
+	X, Y *big.Int
+}
+
+// Curve returns the SM2 curve.
+func Curve() elliptic.Curve { return sm2.P256Sm2() }
+
+// Order returns the order of the base point.
+func Order() *big.Int { return Curve().Params().N }
+
+// Infinity returns the point at infinity.
+func Infinity() *Point { return &Point{new(big.Int), new(big.Int)} }
+
+// Generator returns the base point.
+func Generator() *Point {
+	params := Curve().Params()
+	return &Point{params.Gx, params.Gy}
+}
+
+// IsInfinity reports whether p is the point at infinity.
+func (p *Point) IsInfinity() bool { return p.X.Sign() == 0 && p.Y.Sign() == 0 }
+
+// Equal reports whether p and q are the same point.
+func (p *Point) Equal(q *Point) bool { return p.X.Cmp(q.X) == 0 && p.Y.Cmp(q.Y) == 0 }
+
+// Add returns p + q, including the point at infinity and p = ±q.
+func (p *Point) Add(q *Point) *Point {
+	x, y := Curve().Add(p.X, p.Y, q.X, q.Y)
+	return &Point{x, y}
+}
+
+// Neg returns -p.
+func (p *Point) Neg() *Point {
+	if p.IsInfinity() {
+		return p
+	}
+	return &Point{p.X, new(big.Int).Sub(Curve().Params().P, p.Y)}
+}
+
+// Mul returns k·p. The scalar is reduced first, since the SM2 scalar
+// multiplication does not handle a zero scalar.
+func (p *Point) Mul(k *big.Int) *Point {
+	k = new(big.Int).Mod(k, Order())
+	if k.Sign() == 0 || p.IsInfinity() {
+		return Infinity()
+	}
+	x, y := Curve().ScalarMult(p.X, p.Y, k.Bytes())
+	return &Point{x, y}
+}
+
+// BaseMul returns k·G.
+func BaseMul(k *big.Int) *Point {
+	k = new(big.Int).Mod(k, Order())
+	if k.Sign() == 0 {
+		return Infinity()
+	}
+	x, y := Curve().ScalarBaseMult(k.Bytes())
+	return &Point{x, y}
+}
+
+// Compress returns the compressed encoding of p, or Size zero bytes for the
+// point at infinity.
+func (p *Point) Compress() []byte {
+	b := make([]byte, Size)
+	if p.IsInfinity() {
+		return b
+	}
+	b[0] = 2 + byte(p.Y.Bit(0))
+	x := p.X.Bytes()
+	copy(b[Size-len(x):], x)
+	return b
+}
+
+// LiftX returns the point with x-coordinate x and a y-coordinate of the
+// given parity, or nil if there is none.
+func LiftX(x *big.Int, odd uint) *Point {
+	params := Curve().Params()
+	if x.Sign() < 0 || x.Cmp(params.P) >= 0 {
+		return nil
+	}
+	// y² = x³ - 3x + b
+	y2 := new(big.Int).Mul(x, x)
+	y2.Sub(y2, big.NewInt(3))
+	y2.Mul(y2, x)
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+	y := new(big.Int).ModSqrt(y2, params.P)
+	if y == nil {
+		return nil
+	}
+	if y.Bit(0) != odd {
+		y.Sub(params.P, y)
+	}
+	return &Point{x, y}
+}
+
+// Decompress parses a compressed point. Size zero bytes decode to the point
+// at infinity if allowInfinity is set, and are rejected otherwise. It returns
+// ErrInvalidPoint for any other encoding that is not of a point on the
+// curve.
+func Decompress(b []byte, allowInfinity bool) (*Point, error) {
+	if len(b) != Size {
+		return nil, ErrInvalidPoint
+	}
+	if b[0] == 0 {
+		if !allowInfinity {
+			return nil, ErrInvalidPoint
+		}
+		for _, c := range b[1:] {
+			if c != 0 {
+				return nil, ErrInvalidPoint
+			}
+		}
+		return Infinity(), nil
+	}
+	if b[0] != 2 && b[0] != 3 {
+		return nil, ErrInvalidPoint
+	}
+	p := LiftX(new(big.Int).SetBytes(b[1:]), uint(b[0]&1))
+	if p == nil {
+		return nil, ErrInvalidPoint
+	}
+	return p, nil
+}
+
+// FromAffine returns the point (x, y), or false if x or y is nil or the
+// coordinates are not those of a point on the curve or of the point at
+// infinity (0, 0). Unreduced coordinates are rejected so that every point
+// has a single encoding.
+func FromAffine(x, y *big.Int) (p *Point, ok bool) {
+	if x == nil || y == nil || !inField(x) || !inField(y) {
+		return nil, false
+	}
+	p = &Point{x, y}
+	if !p.IsInfinity() && !Curve().IsOnCurve(x, y) {
+		return nil, false
+	}
+	return p, true
+}
+
+// FromPublicKey returns the point of pub, or false if pub is nil or its
+// coordinates are not those of a point on the curve other than infinity.
+func FromPublicKey(pub *sm2.PublicKey) (p *Point, ok bool) {
+	if pub == nil {
+		return nil, false
+	}
+	p, ok = FromAffine(pub.X, pub.Y)
+	if !ok || p.IsInfinity() {
+		return nil, false
+	}
+	return p, true
+}
+
+// inField reports whether 0 ≤ x < p.
+func inField(x *big.Int) bool {
+	return x.Sign() >= 0 && x.Cmp(Curve().Params().P) < 0
+}
+
+// ScalarBytes returns the ScalarSize-byte encoding of k, which must be
+// reduced.
+func ScalarBytes(k *big.Int) []byte {
+	b := make([]byte, ScalarSize)
+	kb := k.Bytes()
+	copy(b[ScalarSize-len(kb):], kb)
+	return b
+}
+
+// ParseScalar parses an encoded scalar less than the order, or returns
+// ErrInvalidScalar.
+func ParseScalar(b []byte) (*big.Int, error) {
+	if len(b) != ScalarSize {
+		return nil, ErrInvalidScalar
+	}
+	k := new(big.Int).SetBytes(b)
+	if k.Cmp(Order()) >= 0 {
+		return nil, ErrInvalidScalar
+	}
+	return k, nil
+}
+
+// RandScalar returns a uniformly random scalar in [1, n-1], reading 64 more
+// bits than the order has so that the bias of the reduction is negligible.
+func RandScalar(rand io.Reader) (*big.Int, error) {
+	n := Order()
+	b := make([]byte, n.BitLen()/8+8)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(b)
+	k.Mod(k, new(big.Int).Sub(n, big.NewInt(1)))
+	return k.Add(k, big.NewInt(1)), nil
+}
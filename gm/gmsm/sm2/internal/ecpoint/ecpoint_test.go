@@ -0,0 +1,124 @@
+package ecpoint
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestArithmetic(t *testing.T) {
+	g := Generator()
+	if !g.Add(g).Equal(g.Mul(big.NewInt(2))) {
+		t.Error("G + G != 2·G")
+	}
+	if !g.Add(g.Neg()).IsInfinity() {
+		t.Error("G + (-G) is not infinity")
+	}
+	if !Infinity().Add(g).Equal(g) || !g.Add(Infinity()).Equal(g) {
+		t.Error("infinity is not the identity")
+	}
+	if !g.Mul(Order()).IsInfinity() || !BaseMul(new(big.Int)).IsInfinity() {
+		t.Error("n·G or 0·G is not infinity")
+	}
+	if !BaseMul(big.NewInt(7)).Equal(g.Mul(big.NewInt(7))) {
+		t.Error("BaseMul and Mul disagree")
+	}
+	if !Infinity().Neg().IsInfinity() {
+		t.Error("-infinity is not infinity")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	for _, k := range []int64{1, 2, 3, 1000} {
+		p := BaseMul(big.NewInt(k))
+		q, err := Decompress(p.Compress(), false)
+		if err != nil || !q.Equal(p) {
+			t.Errorf("%d·G does not round-trip", k)
+		}
+	}
+
+	inf := make([]byte, Size)
+	if p, err := Decompress(inf, true); err != nil || !p.IsInfinity() {
+		t.Error("infinity rejected where allowed")
+	}
+	if !bytes.Equal(Infinity().Compress(), inf) {
+		t.Error("infinity does not encode to zero bytes")
+	}
+
+	g := Generator().Compress()
+	mutate := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), g...))
+	}
+	notOnCurve := make([]byte, Size)
+	notOnCurve[0], notOnCurve[Size-1] = 2, 2 // x = 2 has no y
+	pBytes := Curve().Params().P.Bytes()
+	for name, b := range map[string][]byte{
+		"infinity":       inf,
+		"infinity tail":  mutate(func(b []byte) []byte { b[0] = 0; return b }),
+		"uncompressed":   mutate(func(b []byte) []byte { b[0] = 4; return b }),
+		"short":          g[:Size-1],
+		"long":           append(append([]byte(nil), g...), 0),
+		"x = p":          append([]byte{2}, pBytes...),
+		"x not on curve": notOnCurve,
+	} {
+		if _, err := Decompress(b, name == "infinity tail"); err != ErrInvalidPoint {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestFromPublicKey(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := FromPublicKey(&priv.PublicKey); !ok || p.X.Cmp(priv.X) != 0 || p.Y.Cmp(priv.Y) != 0 {
+		t.Error("valid key rejected")
+	}
+
+	params := Curve().Params()
+	for name, pub := range map[string]*sm2.PublicKey{
+		"nil":       nil,
+		"nil X":     {Curve: Curve(), Y: priv.Y},
+		"infinity":  {Curve: Curve(), X: new(big.Int), Y: new(big.Int)},
+		"off curve": {Curve: Curve(), X: priv.X, Y: new(big.Int).Add(priv.Y, big.NewInt(1))},
+		"y + p":     {Curve: Curve(), X: priv.X, Y: new(big.Int).Add(priv.Y, params.P)},
+		"negative":  {Curve: Curve(), X: priv.X, Y: new(big.Int).Sub(priv.Y, params.P)},
+	} {
+		if _, ok := FromPublicKey(pub); ok {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestFromAffine(t *testing.T) {
+	g := Generator()
+	if p, ok := FromAffine(g.X, g.Y); !ok || !p.Equal(g) {
+		t.Error("generator rejected")
+	}
+	if p, ok := FromAffine(new(big.Int), new(big.Int)); !ok || !p.IsInfinity() {
+		t.Error("infinity rejected")
+	}
+	if _, ok := FromAffine(g.X, nil); ok {
+		t.Error("nil coordinate accepted")
+	}
+	if _, ok := FromAffine(new(big.Int).Add(g.X, Curve().Params().P), g.Y); ok {
+		t.Error("unreduced coordinate accepted")
+	}
+}
+
+func TestParseScalar(t *testing.T) {
+	k := big.NewInt(12345)
+	if got, err := ParseScalar(ScalarBytes(k)); err != nil || got.Cmp(k) != 0 {
+		t.Error("scalar does not round-trip")
+	}
+	n := Order()
+	if _, err := ParseScalar(ScalarBytes(n)); err != ErrInvalidScalar {
+		t.Error("n accepted")
+	}
+	if _, err := ParseScalar(make([]byte, ScalarSize-1)); err != ErrInvalidScalar {
+		t.Error("short scalar accepted")
+	}
+}
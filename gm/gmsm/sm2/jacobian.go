@@ -0,0 +1,155 @@
+package sm2
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+// JacobianPoint is a point of the SM2 curve in Jacobian coordinates over
+// the field representation of the package, for chains of additions and
+// doublings that would otherwise convert to and from big.Int at each step,
+// as elliptic.CurveParams does. The zero value is the point at infinity.
+//
+// Its methods are not constant time.
+type JacobianPoint struct {
+	p sm2P256JacobianPoint
+}
+
+// NewJacobianPoint returns the point (x, y), or the point at infinity for
+// (0, 0). It returns an error if (x, y) is not on the curve.
+func NewJacobianPoint(x, y *big.Int) (*JacobianPoint, error) {
+	p := new(JacobianPoint)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		p.p.inf = true
+		return p, nil
+	}
+	if !inField(x) || !inField(y) {
+		return nil, errors.New("sm2: point not on the curve")
+	}
+	p.p.fromAffine(x, y)
+	if !p.p.onCurve() {
+		return nil, errors.New("sm2: point not on the curve")
+	}
+	return p, nil
+}
+
+// Affine returns the affine coordinates of p, (0, 0) for the point at
+// infinity.
+func (p *JacobianPoint) Affine() (x, y *big.Int) {
+	if p.IsInfinity() {
+		return new(big.Int), new(big.Int)
+	}
+	return p.p.toAffine()
+}
+
+// IsInfinity reports whether p is the point at infinity. A z-coordinate of
+// zero, as in the zero value, only represents infinity.
+func (p *JacobianPoint) IsInfinity() bool {
+	return p.p.inf || p.p.z == (sm2P256FieldElement{})
+}
+
+// Add sets p = q1 + q2 and returns p. Any of the points may be the same.
+func (p *JacobianPoint) Add(q1, q2 *JacobianPoint) *JacobianPoint {
+	a, b := q1.normalized(), q2.normalized()
+	p.p.add(&a, &b)
+	return p
+}
+
+// Double sets p = 2·q and returns p. p and q may be the same.
+func (p *JacobianPoint) Double(q *JacobianPoint) *JacobianPoint {
+	a := q.normalized()
+	p.p.double(&a)
+	return p
+}
+
+// normalized returns the point of p with the zero value as infinity.
+func (p *JacobianPoint) normalized() sm2P256JacobianPoint {
+	if p.IsInfinity() {
+		return sm2P256JacobianPoint{inf: true}
+	}
+	return p.p
+}
+
+// onCurve reports whether the affine p, with z = 1, satisfies
+// y² = x³ + ax + b.
+func (p *sm2P256JacobianPoint) onCurve() bool {
+	var x3, ax, y2 sm2P256FieldElement
+	sm2P256Square2Way(&x3, &p.x, &y2, &p.y)
+	sm2P256Mul2Way(&x3, &x3, &p.x, &ax, &sm2P256.a, &p.x)
+	sm2P256Add(&x3, &x3, &ax)
+	sm2P256Add(&x3, &x3, &sm2P256.b)
+	sm2P256Sub(&x3, &x3, &y2)
+	return sm2P256IsZero(&x3)
+}
+
+// sm2P256OnePlain is 1 in plain limbs, by which sm2P256Mul takes a value
+// out of the Montgomery domain.
+var sm2P256OnePlain = sm2P256FieldElement{1}
+
+// sm2P256FromBigFast is sm2P256FromBig with a field multiplication instead
+// of a big.Int division, for 0 ≤ a < 2^256.
+func sm2P256FromBigFast(out *sm2P256FieldElement, a *big.Int) {
+	var s sm2Scalar
+	if !s.setBig(a) {
+		sm2P256FromBig(out, a)
+		return
+	}
+	s.toField(out)
+}
+
+// sm2P256ToBigFast is sm2P256ToBig with a field multiplication and word
+// arithmetic instead of big.Int multiplications and a division. It is not
+// constant time.
+func sm2P256ToBigFast(a *sm2P256FieldElement) *big.Int {
+	var plain sm2P256FieldElement
+	sm2P256Mul(&plain, a, &sm2P256OnePlain)
+	w := sm2P256Words(&plain)
+
+	// Subtract the largest multiple of p not above w.
+	for k := len(sm2P256PMultiples) - 1; k > 0; k-- {
+		kp := &sm2P256PMultiples[k]
+		var borrow uint64
+		var d [5]uint64
+		for i := range w {
+			d[i], borrow = bits.Sub64(w[i], kp[i], borrow)
+		}
+		if borrow == 0 {
+			w = d
+			break
+		}
+	}
+	var b [32]byte
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(b[24-8*i:], w[i])
+	}
+	return new(big.Int).SetBytes(b[:])
+}
+
+// inField reports whether 0 ≤ x < p.
+func inField(x *big.Int) bool {
+	return x.Sign() >= 0 && x.Cmp(sm2P256.P) < 0
+}
+
+// Add returns (x1, y1) + (x2, y2), computed in Jacobian coordinates over
+// the field representation of the package instead of with the big.Int
+// formulas of elliptic.CurveParams. (0, 0) is the point at infinity. Points
+// not on the curve are left to elliptic.CurveParams, as before.
+func (curve sm2P256Curve) Add(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	p1, err1 := NewJacobianPoint(x1, y1)
+	p2, err2 := NewJacobianPoint(x2, y2)
+	if err1 != nil || err2 != nil {
+		return curve.CurveParams.Add(x1, y1, x2, y2)
+	}
+	return p1.Add(p1, p2).Affine()
+}
+
+// Double returns 2·(x1, y1), as Add computes sums.
+func (curve sm2P256Curve) Double(x1, y1 *big.Int) (*big.Int, *big.Int) {
+	p, err := NewJacobianPoint(x1, y1)
+	if err != nil {
+		return curve.CurveParams.Double(x1, y1)
+	}
+	return p.Double(p).Affine()
+}
@@ -0,0 +1,83 @@
+package sm2
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCurveAddDouble(t *testing.T) {
+	c := P256Sm2()
+	params := c.Params()
+	x1, y1 := c.ScalarBaseMult([]byte{7})
+	x2, y2 := c.ScalarBaseMult([]byte{11})
+	negY1 := new(big.Int).Sub(params.P, y1)
+	zero := new(big.Int)
+
+	for name, in := range map[string][4]*big.Int{
+		"distinct":      {x1, y1, x2, y2},
+		"equal":         {x1, y1, x1, y1},
+		"opposite":      {x1, y1, x1, negY1},
+		"infinity left": {zero, zero, x2, y2},
+		"infinity both": {zero, zero, zero, zero},
+	} {
+		gx, gy := c.Add(in[0], in[1], in[2], in[3])
+		wx, wy := params.Add(in[0], in[1], in[2], in[3])
+		if gx.Cmp(wx) != 0 || gy.Cmp(wy) != 0 {
+			t.Errorf("%s: Add = (%x, %x), want (%x, %x)", name, gx, gy, wx, wy)
+		}
+	}
+
+	gx, gy := c.Double(x1, y1)
+	wx, wy := params.Double(x1, y1)
+	if gx.Cmp(wx) != 0 || gy.Cmp(wy) != 0 {
+		t.Fatal("Double differs from CurveParams")
+	}
+}
+
+func TestJacobianPoint(t *testing.T) {
+	c := P256Sm2()
+	x, y := c.ScalarBaseMult([]byte{5})
+	p, err := NewJacobianPoint(x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 5·(2·(5G) + 5G) = 75G, staying in Jacobian coordinates.
+	var acc JacobianPoint
+	if !acc.IsInfinity() {
+		t.Fatal("zero value is not infinity")
+	}
+	q := new(JacobianPoint).Double(p)
+	q.Add(q, p)
+	for i := 0; i < 5; i++ {
+		acc.Add(&acc, q)
+	}
+	gx, gy := acc.Affine()
+	wx, wy := c.ScalarBaseMult([]byte{75})
+	if gx.Cmp(wx) != 0 || gy.Cmp(wy) != 0 {
+		t.Fatal("JacobianPoint chain differs from ScalarBaseMult")
+	}
+
+	if _, err := NewJacobianPoint(x, new(big.Int).Add(y, big.NewInt(1))); err == nil {
+		t.Fatal("off-curve point accepted")
+	}
+	if _, err := NewJacobianPoint(new(big.Int).Add(x, c.Params().P), y); err == nil {
+		t.Fatal("unreduced coordinate accepted")
+	}
+}
+
+func BenchmarkCurveAdd(b *testing.B) {
+	c := P256Sm2()
+	x1, y1 := c.ScalarBaseMult([]byte{7})
+	x2, y2 := c.ScalarBaseMult([]byte{11})
+	b.Run("Jacobian", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.Add(x1, y1, x2, y2)
+		}
+	})
+	b.Run("CurveParams", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c.Params().Add(x1, y1, x2, y2)
+		}
+	})
+}
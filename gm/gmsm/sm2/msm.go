@@ -0,0 +1,314 @@
+package sm2
+
+import (
+	"errors"
+	"math/big"
+)
+
+// sm2P256JacobianPoint is a point in Jacobian coordinates. The point at
+// infinity is tracked by inf rather than by z, since z may hold any of
+// several representations of zero.
+type sm2P256JacobianPoint struct {
+	x, y, z sm2P256FieldElement
+	inf     bool
+}
+
+// sm2P256LimbOffsets are the bit offsets of the limbs of a field element.
+var sm2P256LimbOffsets = [9]uint{0, 29, 57, 86, 114, 143, 171, 200, 228}
+
+// sm2P256PMultiples holds k·P for the k that a field element with unreduced
+// limbs can reach, as little-endian words.
+var sm2P256PMultiples [][5]uint64
+
+func initSm2P256PMultiples() {
+	kp := new(big.Int)
+	bound := new(big.Int).Lsh(big.NewInt(1), 228+33)
+	for kp.Cmp(bound) < 0 {
+		var w [5]uint64
+		for i, b := range kp.Bits() {
+			// big.Word is 32 bits on some platforms.
+			if bitsPerWord := 32 << (^uint(0) >> 63); bitsPerWord == 64 {
+				w[i] = uint64(b)
+			} else {
+				w[i/2] |= uint64(b) << (32 * uint(i%2))
+			}
+		}
+		sm2P256PMultiples = append(sm2P256PMultiples, w)
+		kp.Add(kp, sm2P256.P)
+	}
+}
+
+// sm2P256IsZero reports whether a represents zero. The limbs of a field
+// element are not fully reduced, so a represents zero if its value is any
+// multiple of P.
+func sm2P256IsZero(a *sm2P256FieldElement) bool {
+	w := sm2P256Words(a)
+	for i := range sm2P256PMultiples {
+		if w == sm2P256PMultiples[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// sm2P256Words returns the value of the limbs of a, not reduced mod p, as
+// little-endian words.
+func sm2P256Words(a *sm2P256FieldElement) (w [5]uint64) {
+	for i, limb := range a {
+		off := sm2P256LimbOffsets[i]
+		j, s := off/64, off%64
+		lo := uint64(limb) << s
+		var hi uint64
+		if s > 32 {
+			hi = uint64(limb) >> (64 - s)
+		}
+		old := w[j]
+		w[j] += lo
+		if w[j] < old {
+			hi++
+		}
+		for k := int(j) + 1; hi != 0 && k < len(w); k++ {
+			old = w[k]
+			w[k] += hi
+			if w[k] < old {
+				hi = 1
+			} else {
+				hi = 0
+			}
+		}
+	}
+	return w
+}
+
+func (p *sm2P256JacobianPoint) fromAffine(x, y *big.Int) {
+	sm2P256FromBigFast(&p.x, x)
+	sm2P256FromBigFast(&p.y, y)
+	p.z = sm2P256Factor[1]
+	p.inf = false
+}
+
+func (p *sm2P256JacobianPoint) toAffine() (x, y *big.Int) {
+	if p.inf {
+		return new(big.Int), new(big.Int)
+	}
+	var zInv, zz, xa, ya sm2P256FieldElement
+	z := sm2P256ToBigFast(&p.z)
+	sm2P256FromBigFast(&zInv, z.ModInverse(z, sm2P256.P))
+	sm2P256Square(&zz, &zInv)
+	sm2P256Mul2Way(&xa, &p.x, &zz, &zz, &zz, &zInv)
+	sm2P256Mul(&ya, &p.y, &zz)
+	return sm2P256ToBigFast(&xa), sm2P256ToBigFast(&ya)
+}
+
+func (p *sm2P256JacobianPoint) double(q *sm2P256JacobianPoint) {
+	if q.inf {
+		p.inf = true
+		return
+	}
+	// The curve has prime order, so no finite point doubles to infinity.
+	sm2P256PointDouble(&p.x, &p.y, &p.z, &q.x, &q.y, &q.z)
+	p.inf = false
+}
+
+// add sets p = q1 + q2. Unlike sm2P256PointAdd it handles equal and
+// opposite points, which inputs chosen by an attacker can produce.
+func (p *sm2P256JacobianPoint) add(q1, q2 *sm2P256JacobianPoint) {
+	switch {
+	case q1.inf:
+		*p = *q2
+		return
+	case q2.inf:
+		*p = *q1
+		return
+	}
+	var x, y, z sm2P256FieldElement
+	sm2P256PointAdd(&q1.x, &q1.y, &q1.z, &q2.x, &q2.y, &q2.z, &x, &y, &z)
+	if sm2P256IsZero(&z) {
+		// The x-coordinates are equal. The formulas then give x = dy², so
+		// the points are equal if x is zero and opposite otherwise.
+		if sm2P256IsZero(&x) {
+			p.double(q1)
+		} else {
+			p.inf = true
+		}
+		return
+	}
+	p.x, p.y, p.z, p.inf = x, y, z, false
+}
+
+// Point is a point on the SM2 curve in affine coordinates. (0, 0) is the
+// point at infinity.
+type Point struct {
+	X, Y *big.Int
+}
+
+// pippengerThreshold is the number of points from which MultiScalarMult
+// uses buckets instead of interleaved windows.
+const pippengerThreshold = 128
+
+var (
+	errMSMLength = errors.New("sm2: MultiScalarMult called with mismatched slices")
+	errMSMPoint  = errors.New("sm2: MultiScalarMult called with a point not on the curve")
+	errMSMScalar = errors.New("sm2: MultiScalarMult called with a nil scalar")
+)
+
+// MultiScalarMult returns Σ scalars[i]·points[i]. It is much faster than
+// separate scalar multiplications for many points, but is not constant time
+// and must not be used with secret scalars. Scalars are reduced modulo the
+// order of the curve. It returns an error if the slices differ in length, a
+// scalar is nil or a point is not on the curve, so that points decoded from
+// untrusted input can be passed as they are.
+func MultiScalarMult(points []*Point, scalars []*big.Int) (*Point, error) {
+	if len(points) != len(scalars) {
+		return nil, errMSMLength
+	}
+	c := P256Sm2()
+	xs := make([]*big.Int, 0, len(points))
+	ys := make([]*big.Int, 0, len(points))
+	ks := make([]*big.Int, 0, len(points))
+	for i, p := range points {
+		if scalars[i] == nil {
+			return nil, errMSMScalar
+		}
+		if p == nil || p.X == nil || p.Y == nil {
+			return nil, errMSMPoint
+		}
+		if p.X.Sign() == 0 && p.Y.Sign() == 0 {
+			continue
+		}
+		if !inField(p.X) || !inField(p.Y) || !c.IsOnCurve(p.X, p.Y) {
+			return nil, errMSMPoint
+		}
+		xs, ys, ks = append(xs, p.X), append(ys, p.Y), append(ks, scalars[i])
+	}
+	x, y := multiScalarMult(xs, ys, ks)
+	return &Point{x, y}, nil
+}
+
+// multiScalarMult returns Σ scalars[i]·(xs[i], ys[i]) for points on the
+// curve other than the point at infinity.
+func multiScalarMult(xs, ys, scalars []*big.Int) (x, y *big.Int) {
+	digits := make([][32]byte, len(xs))
+	n := sm2P256.N
+	for i, k := range scalars {
+		if k.Sign() < 0 || k.Cmp(n) >= 0 {
+			k = new(big.Int).Mod(k, n)
+		}
+		kb := k.Bytes()
+		copy(digits[i][32-len(kb):], kb)
+	}
+	var acc sm2P256JacobianPoint
+	if len(xs) < pippengerThreshold {
+		acc = strausMult(xs, ys, digits)
+	} else {
+		acc = pippengerMult(xs, ys, digits)
+	}
+	return acc.toAffine()
+}
+
+// strausMult computes the sum with interleaved fixed windows of four bits
+// over tables of the first 15 multiples of each point. The scalars are
+// big-endian.
+func strausMult(xs, ys []*big.Int, digits [][32]byte) sm2P256JacobianPoint {
+	tables := make([][16]sm2P256JacobianPoint, len(xs))
+	for i := range xs {
+		t := &tables[i]
+		t[0].inf = true
+		t[1].fromAffine(xs[i], ys[i])
+		for j := 2; j < 16; j++ {
+			t[j].add(&t[j-1], &t[1])
+		}
+	}
+
+	var acc, tmp sm2P256JacobianPoint
+	acc.inf = true
+	for w := 0; w < 64; w++ {
+		if w != 0 {
+			for j := 0; j < 4; j++ {
+				acc.double(&acc)
+			}
+		}
+		for i := range tables {
+			d := digits[i][w/2]
+			if w&1 == 0 {
+				d >>= 4
+			} else {
+				d &= 15
+			}
+			if d != 0 {
+				tmp.add(&acc, &tables[i][d])
+				acc = tmp
+			}
+		}
+	}
+	return acc
+}
+
+// pippengerWindow returns the window size in bits for n points, roughly
+// log2(n) - 2, which balances bucket accumulation against bucket summation.
+func pippengerWindow(n int) uint {
+	c := uint(1)
+	for 1<<(c+3) <= n {
+		c++
+	}
+	if c < 3 {
+		c = 3
+	}
+	if c > 12 {
+		c = 12
+	}
+	return c
+}
+
+// window returns the c bits of the big-endian 256-bit scalar k starting at
+// bit offset off.
+func window(k *[32]byte, off, c uint) uint {
+	var w uint
+	for i := uint(0); i < c && off+i < 256; i++ {
+		bit := off + i
+		w |= uint(k[31-bit/8]>>(bit%8)&1) << i
+	}
+	return w
+}
+
+// pippengerMult computes the sum with Pippenger's bucket method: for each
+// window of c bits, every point is added to the bucket of its digit, and
+// the buckets are combined as Σ j·B_j with two running sums.
+func pippengerMult(xs, ys []*big.Int, digits [][32]byte) sm2P256JacobianPoint {
+	c := pippengerWindow(len(xs))
+	points := make([]sm2P256JacobianPoint, len(xs))
+	for i := range xs {
+		points[i].fromAffine(xs[i], ys[i])
+	}
+	buckets := make([]sm2P256JacobianPoint, 1<<c)
+
+	var acc, tmp, running, sum sm2P256JacobianPoint
+	acc.inf = true
+	windows := (256 + c - 1) / c
+	for w := int(windows) - 1; w >= 0; w-- {
+		if !acc.inf {
+			for j := uint(0); j < c; j++ {
+				acc.double(&acc)
+			}
+		}
+		for j := range buckets {
+			buckets[j].inf = true
+		}
+		for i := range points {
+			if d := window(&digits[i], uint(w)*c, c); d != 0 {
+				tmp.add(&buckets[d], &points[i])
+				buckets[d] = tmp
+			}
+		}
+		running.inf, sum.inf = true, true
+		for j := len(buckets) - 1; j > 0; j-- {
+			tmp.add(&running, &buckets[j])
+			running = tmp
+			tmp.add(&sum, &running)
+			sum = tmp
+		}
+		tmp.add(&acc, &sum)
+		acc = tmp
+	}
+	return acc
+}
@@ -0,0 +1,176 @@
+package sm2
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestMultiScalarMult(t *testing.T) {
+	c := P256Sm2()
+	n := c.Params().N
+	for _, size := range []int{1, 2, 5, pippengerThreshold + 3} {
+		xs := make([]*big.Int, size)
+		ys := make([]*big.Int, size)
+		ks := make([]*big.Int, size)
+		wx, wy := new(big.Int), new(big.Int)
+		for i := range xs {
+			d, _ := rand.Int(rand.Reader, n)
+			xs[i], ys[i] = c.ScalarBaseMult(d.Bytes())
+			ks[i], _ = rand.Int(rand.Reader, n)
+			px, py := c.ScalarMult(xs[i], ys[i], ks[i].Bytes())
+			wx, wy = c.Params().Add(wx, wy, px, py)
+		}
+		points := make([]*Point, size)
+		for i := range points {
+			points[i] = &Point{xs[i], ys[i]}
+		}
+		p, err := MultiScalarMult(points, ks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.X.Cmp(wx) != 0 || p.Y.Cmp(wy) != 0 {
+			t.Fatalf("%d points: wrong result", size)
+		}
+	}
+}
+
+// TestMultiScalarMultDegenerate checks the sums that go through equal and
+// opposite points.
+func TestMultiScalarMultDegenerate(t *testing.T) {
+	c := P256Sm2()
+	params := c.Params()
+	gx, gy := params.Gx, params.Gy
+	negGy := new(big.Int).Sub(params.P, gy)
+	one, two, three := big.NewInt(1), big.NewInt(2), big.NewInt(3)
+
+	// G + G = 2G
+	x, y := multiScalarMult([]*big.Int{gx, gx}, []*big.Int{gy, gy}, []*big.Int{one, one})
+	wx, wy := c.ScalarBaseMult(two.Bytes())
+	if x.Cmp(wx) != 0 || y.Cmp(wy) != 0 {
+		t.Fatal("G + G != 2G")
+	}
+	// G - G = 0
+	x, y = multiScalarMult([]*big.Int{gx, gx}, []*big.Int{gy, negGy}, []*big.Int{one, one})
+	if x.Sign() != 0 || y.Sign() != 0 {
+		t.Fatal("G - G != 0")
+	}
+	// 3G - 3G + G = G, through the point at infinity.
+	x, y = multiScalarMult([]*big.Int{gx, gx, gx}, []*big.Int{gy, negGy, gy}, []*big.Int{three, three, one})
+	if x.Cmp(gx) != 0 || y.Cmp(gy) != 0 {
+		t.Fatal("3G - 3G + G != G")
+	}
+	// n·G = 0
+	x, y = multiScalarMult([]*big.Int{gx}, []*big.Int{gy}, []*big.Int{new(big.Int).Set(params.N)})
+	if x.Sign() != 0 || y.Sign() != 0 {
+		t.Fatal("n·G != 0")
+	}
+}
+
+// TestMultiScalarMultInvalid checks that caller-supplied input is rejected
+// with an error instead of a panic.
+func TestMultiScalarMultInvalid(t *testing.T) {
+	params := P256Sm2().Params()
+	g := &Point{params.Gx, params.Gy}
+	one := big.NewInt(1)
+
+	offCurve := &Point{params.Gx, new(big.Int).Add(params.Gy, one)}
+	// (x, y + p) satisfies the curve equation modulo p, but is not a point.
+	unreduced := &Point{params.Gx, new(big.Int).Add(params.Gy, params.P)}
+	for name, tt := range map[string]struct {
+		points  []*Point
+		scalars []*big.Int
+	}{
+		"mismatched": {[]*Point{g, g}, []*big.Int{one}},
+		"off curve":  {[]*Point{g, offCurve}, []*big.Int{one, one}},
+		"unreduced":  {[]*Point{unreduced}, []*big.Int{one}},
+		"nil point":  {[]*Point{nil}, []*big.Int{one}},
+		"nil scalar": {[]*Point{g}, []*big.Int{nil}},
+	} {
+		if p, err := MultiScalarMult(tt.points, tt.scalars); err == nil {
+			t.Errorf("%s: got %v, want an error", name, p)
+		}
+	}
+}
+
+func TestIsZero(t *testing.T) {
+	P256Sm2()
+	var a sm2P256FieldElement
+	if !sm2P256IsZero(&a) {
+		t.Fatal("0 is not zero")
+	}
+	// x - x leaves an unreduced representation of zero.
+	sm2P256FromBig(&a, big.NewInt(12345))
+	var z sm2P256FieldElement
+	sm2P256Sub(&z, &a, &a)
+	if !sm2P256IsZero(&z) {
+		t.Fatalf("x - x = %x is not zero", z)
+	}
+	if sm2P256IsZero(&a) {
+		t.Fatal("12345 is zero")
+	}
+	for i := 0; i < 100; i++ {
+		var b, c sm2P256FieldElement
+		k, _ := rand.Int(rand.Reader, sm2P256.P)
+		sm2P256FromBig(&b, k)
+		sm2P256Mul(&c, &b, &a)
+		v := sm2P256ToBigPlain(&c)
+		if sm2P256IsZero(&c) != (v.Mod(v, sm2P256.P).Sign() == 0) {
+			t.Fatal("sm2P256IsZero disagrees with big.Int")
+		}
+	}
+}
+
+func benchmarkMSM(b *testing.B, size int) {
+	c := P256Sm2()
+	n := c.Params().N
+	points := make([]*Point, size)
+	scalars := make([]*big.Int, size)
+	for i := range points {
+		d, _ := rand.Int(rand.Reader, n)
+		x, y := c.ScalarBaseMult(d.Bytes())
+		points[i] = &Point{x, y}
+		scalars[i], _ = rand.Int(rand.Reader, n)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		MultiScalarMult(points, scalars)
+	}
+}
+
+func BenchmarkMultiScalarMult16(b *testing.B)   { benchmarkMSM(b, 16) }
+func BenchmarkMultiScalarMult128(b *testing.B)  { benchmarkMSM(b, 128) }
+func BenchmarkMultiScalarMult1024(b *testing.B) { benchmarkMSM(b, 1024) }
+
+// TestPippengerRepeatedPoints fills buckets with equal and opposite points.
+func TestPippengerRepeatedPoints(t *testing.T) {
+	c := P256Sm2()
+	params := c.Params()
+	negGy := new(big.Int).Sub(params.P, params.Gy)
+	var points []*Point
+	var scalars []*big.Int
+	want := new(big.Int)
+	for i := 0; i < pippengerThreshold; i++ {
+		k := big.NewInt(int64(i % 7))
+		if i%3 == 0 {
+			points = append(points, &Point{params.Gx, negGy})
+			want.Sub(want, k)
+		} else {
+			points = append(points, &Point{params.Gx, params.Gy})
+			want.Add(want, k)
+		}
+		scalars = append(scalars, k)
+	}
+	// The point at infinity is skipped.
+	points = append(points, &Point{new(big.Int), new(big.Int)})
+	scalars = append(scalars, big.NewInt(5))
+
+	p, err := MultiScalarMult(points, scalars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wx, wy := c.ScalarBaseMult(want.Mod(want, params.N).Bytes())
+	if p.X.Cmp(wx) != 0 || p.Y.Cmp(wy) != 0 {
+		t.Fatal("wrong result")
+	}
+}
@@ -0,0 +1,296 @@
+// Package musig implements MuSig2 multi-signatures over the SM2 curve.
+//
+// n signers aggregate their public keys into a single key and jointly
+// produce one 64-byte Schnorr signature under it in two rounds: a nonce
+// round, which does not depend on the message and can be run in advance,
+// and a signing round. The aggregate signature verifies with Verify like a
+// single-signer signature, and reveals neither the number of signers nor
+// their keys.
+//
+// The scheme follows BIP 327 with SM3 as the hash function and points in
+// compressed SEC 1 form. A signature is c || s with
+// c = H("MuSig/challenge", X || R || m) and R = s·G - c·X, each 32 bytes.
+package musig
+
+import (
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	// SignatureSize is the size of an aggregate signature in bytes.
+	SignatureSize = 64
+	// PublicNonceSize is the size of a signer's public nonce in bytes.
+	PublicNonceSize = 66
+)
+
+var (
+	errNoKeys      = errors.New("musig: no public keys")
+	errInvalidKey  = errors.New("musig: invalid public key")
+	errNonce       = errors.New("musig: invalid public nonce")
+	errNonceReused = errors.New("musig: secret nonce already used")
+	errNotSigner   = errors.New("musig: private key is not one of the aggregated keys")
+	errPartial     = errors.New("musig: invalid partial signature")
+)
+
+// taggedHash returns SM3(SM3(tag) || SM3(tag) || data...).
+func taggedHash(tag string, data ...[]byte) []byte {
+	t := sm3.Sm3Sum([]byte(tag))
+	h := sm3.New()
+	h.Write(t)
+	h.Write(t)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+func hashToScalar(tag string, data ...[]byte) *big.Int {
+	e := new(big.Int).SetBytes(taggedHash(tag, data...))
+	return e.Mod(e, ecpoint.Order())
+}
+
+// AggregateKey is the aggregation of the public keys of a signer set.
+type AggregateKey struct {
+	keys   [][]byte
+	coeffs []*big.Int
+	q      *ecpoint.Point
+}
+
+// AggregateKeys aggregates the public keys of the signers. The order of
+// keys matters: all signers must use the same order.
+func AggregateKeys(keys []*sm2.PublicKey) (*AggregateKey, error) {
+	if len(keys) == 0 {
+		return nil, errNoKeys
+	}
+	agg := &AggregateKey{
+		keys:   make([][]byte, len(keys)),
+		coeffs: make([]*big.Int, len(keys)),
+	}
+	var pts []*ecpoint.Point
+	for i, k := range keys {
+		p, ok := ecpoint.FromPublicKey(k)
+		if !ok {
+			return nil, errInvalidKey
+		}
+		pts = append(pts, p)
+		agg.keys[i] = p.Compress()
+	}
+
+	l := taggedHash("KeyAgg list", agg.keys...)
+	// The coefficient of the first key different from the first one is 1,
+	// which saves a scalar multiplication.
+	var second []byte
+	for _, k := range agg.keys {
+		if string(k) != string(agg.keys[0]) {
+			second = k
+			break
+		}
+	}
+	q := ecpoint.Infinity()
+	for i, k := range agg.keys {
+		if second != nil && string(k) == string(second) {
+			agg.coeffs[i] = big.NewInt(1)
+		} else {
+			agg.coeffs[i] = hashToScalar("KeyAgg coefficient", l, k)
+		}
+		q = q.Add(pts[i].Mul(agg.coeffs[i]))
+	}
+	if q.IsInfinity() {
+		return nil, errInvalidKey
+	}
+	agg.q = q
+	return agg, nil
+}
+
+// PublicKey returns the aggregate public key.
+func (k *AggregateKey) PublicKey() *sm2.PublicKey {
+	return &sm2.PublicKey{Curve: ecpoint.Curve(), X: new(big.Int).Set(k.q.X), Y: new(big.Int).Set(k.q.Y)}
+}
+
+// SecretNonce is a signer's secret nonce for one signing session. It must
+// be used for a single signature only; Sign erases it.
+type SecretNonce struct {
+	k1, k2 *big.Int
+	pub    []byte
+}
+
+// NewNonce generates a signer's nonce pair for one signing session and
+// returns the public nonce to send to the other signers. The secret nonce
+// must never be reused or persisted.
+func NewNonce(rand io.Reader) (*SecretNonce, []byte, error) {
+	k1, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	k2, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub := append(ecpoint.BaseMul(k1).Compress(), ecpoint.BaseMul(k2).Compress()...)
+	return &SecretNonce{k1: k1, k2: k2, pub: pub}, pub, nil
+}
+
+func parseNonce(b []byte, allowInfinity bool) (r1, r2 *ecpoint.Point, err error) {
+	if len(b) != PublicNonceSize {
+		return nil, nil, errNonce
+	}
+	if r1, err = ecpoint.Decompress(b[:33], allowInfinity); err != nil {
+		return nil, nil, errNonce
+	}
+	if r2, err = ecpoint.Decompress(b[33:], allowInfinity); err != nil {
+		return nil, nil, errNonce
+	}
+	return r1, r2, nil
+}
+
+// AggregateNonces combines the public nonces of all signers. Any party,
+// including an untrusted coordinator, may compute it.
+func AggregateNonces(nonces [][]byte) ([]byte, error) {
+	if len(nonces) == 0 {
+		return nil, errNonce
+	}
+	r1, r2 := ecpoint.Infinity(), ecpoint.Infinity()
+	for _, b := range nonces {
+		p1, p2, err := parseNonce(b, false)
+		if err != nil {
+			return nil, err
+		}
+		r1, r2 = r1.Add(p1), r2.Add(p2)
+	}
+	return append(r1.Compress(), r2.Compress()...), nil
+}
+
+// Session holds the values shared by the signers of one message.
+type Session struct {
+	key *AggregateKey
+	b   *big.Int
+	r   *ecpoint.Point
+	c   *big.Int
+}
+
+// NewSession starts the signing round for msg with the aggregate of all
+// public nonces.
+func NewSession(key *AggregateKey, aggNonce, msg []byte) (*Session, error) {
+	r1, r2, err := parseNonce(aggNonce, true)
+	if err != nil {
+		return nil, err
+	}
+	q := key.q.Compress()
+	b := hashToScalar("MuSig/noncecoef", aggNonce, q, msg)
+	r := r1.Add(r2.Mul(b))
+	if r.IsInfinity() {
+		// Only possible if the signers' nonces cancel out, which no honest
+		// signer causes; fall back to G as BIP 327 does.
+		r = ecpoint.Generator()
+	}
+	c := hashToScalar("MuSig/challenge", q, r.Compress(), msg)
+	return &Session{key: key, b: b, r: r, c: c}, nil
+}
+
+// Sign returns the partial signature of the signer with private key priv
+// and secret nonce nonce. The nonce is erased.
+func (s *Session) Sign(nonce *SecretNonce, priv *sm2.PrivateKey) ([]byte, error) {
+	if nonce.k1 == nil {
+		return nil, errNonceReused
+	}
+	k1, k2 := nonce.k1, nonce.k2
+	nonce.k1, nonce.k2 = nil, nil
+
+	pub := (&ecpoint.Point{X: priv.X, Y: priv.Y}).Compress()
+	a := s.coefficient(pub)
+	if a == nil {
+		return nil, errNotSigner
+	}
+	// s_i = k1 + b·k2 + c·a_i·x_i
+	n := ecpoint.Order()
+	si := new(big.Int).Mul(s.c, a)
+	si.Mul(si, priv.D)
+	si.Add(si, new(big.Int).Mul(s.b, k2))
+	si.Add(si, k1)
+	si.Mod(si, n)
+	return ecpoint.ScalarBytes(si), nil
+}
+
+// coefficient returns the key aggregation coefficient of the compressed
+// public key pub, or nil if it is not one of the aggregated keys.
+func (s *Session) coefficient(pub []byte) *big.Int {
+	for i, k := range s.key.keys {
+		if string(k) == string(pub) {
+			return s.key.coeffs[i]
+		}
+	}
+	return nil
+}
+
+// VerifyPartial reports whether partial is a valid partial signature of the
+// signer with public key pub and public nonce pubNonce. It identifies
+// signers that make aggregation fail.
+func (s *Session) VerifyPartial(pub *sm2.PublicKey, pubNonce, partial []byte) bool {
+	p, ok := ecpoint.FromPublicKey(pub)
+	if len(partial) != 32 || !ok {
+		return false
+	}
+	si := new(big.Int).SetBytes(partial)
+	if si.Cmp(ecpoint.Order()) >= 0 {
+		return false
+	}
+	r1, r2, err := parseNonce(pubNonce, false)
+	if err != nil {
+		return false
+	}
+	a := s.coefficient(p.Compress())
+	if a == nil {
+		return false
+	}
+	// s_i·G = R_i1 + b·R_i2 + c·a_i·X_i
+	ea := new(big.Int).Mul(s.c, a)
+	want := r1.Add(r2.Mul(s.b)).Add(p.Mul(ea))
+	got := ecpoint.BaseMul(si)
+	return got.Equal(want)
+}
+
+// Aggregate sums the partial signatures of all signers into the 64-byte
+// aggregate signature.
+func (s *Session) Aggregate(partials [][]byte) ([]byte, error) {
+	n := ecpoint.Order()
+	sum := new(big.Int)
+	for _, p := range partials {
+		if len(p) != 32 {
+			return nil, errPartial
+		}
+		si := new(big.Int).SetBytes(p)
+		if si.Cmp(n) >= 0 {
+			return nil, errPartial
+		}
+		sum.Add(sum, si)
+	}
+	sum.Mod(sum, n)
+	return append(ecpoint.ScalarBytes(s.c), ecpoint.ScalarBytes(sum)...), nil
+}
+
+// Verify reports whether sig is a valid aggregate signature of msg under the
+// aggregate public key pub.
+func Verify(pub *sm2.PublicKey, msg, sig []byte) bool {
+	q, ok := ecpoint.FromPublicKey(pub)
+	if len(sig) != SignatureSize || !ok {
+		return false
+	}
+	n := ecpoint.Order()
+	c := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if c.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+	// R = s·G - c·X
+	r := ecpoint.BaseMul(s).Add(q.Mul(new(big.Int).Sub(n, c)))
+	if r.IsInfinity() {
+		return false
+	}
+	return hashToScalar("MuSig/challenge", q.Compress(), r.Compress(), msg).Cmp(c) == 0
+}
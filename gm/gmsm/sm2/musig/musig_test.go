@@ -0,0 +1,159 @@
+package musig
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func generateKeys(t testing.TB, n int) ([]*sm2.PrivateKey, []*sm2.PublicKey) {
+	privs := make([]*sm2.PrivateKey, n)
+	pubs := make([]*sm2.PublicKey, n)
+	for i := range privs {
+		priv, err := sm2.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs[i], pubs[i] = priv, &priv.PublicKey
+	}
+	return privs, pubs
+}
+
+// sign runs both rounds among all signers and returns the aggregate
+// signature together with the session and the signers' round outputs.
+func sign(t testing.TB, privs []*sm2.PrivateKey, key *AggregateKey, msg []byte) ([]byte, *Session, [][]byte, [][]byte) {
+	secrets := make([]*SecretNonce, len(privs))
+	pubNonces := make([][]byte, len(privs))
+	for i := range privs {
+		var err error
+		if secrets[i], pubNonces[i], err = NewNonce(rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aggNonce, err := AggregateNonces(pubNonces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSession(key, aggNonce, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partials := make([][]byte, len(privs))
+	for i, priv := range privs {
+		if partials[i], err = s.Sign(secrets[i], priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig, err := s.Aggregate(partials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig, s, pubNonces, partials
+}
+
+func TestMuSig(t *testing.T) {
+	for _, n := range []int{1, 2, 5} {
+		privs, pubs := generateKeys(t, n)
+		key, err := AggregateKeys(pubs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := []byte("multi-signed message")
+		sig, s, nonces, partials := sign(t, privs, key, msg)
+		if len(sig) != SignatureSize {
+			t.Fatalf("signature is %d bytes", len(sig))
+		}
+		if !Verify(key.PublicKey(), msg, sig) {
+			t.Fatalf("%d signers: signature does not verify", n)
+		}
+		if Verify(key.PublicKey(), []byte("other message"), sig) {
+			t.Fatal("signature verifies for another message")
+		}
+		for i := range privs {
+			if !s.VerifyPartial(pubs[i], nonces[i], partials[i]) {
+				t.Fatalf("partial signature %d does not verify", i)
+			}
+		}
+		if n > 1 && s.VerifyPartial(pubs[0], nonces[0], partials[1]) {
+			t.Fatal("partial signature verifies for another signer")
+		}
+	}
+}
+
+func TestKeyAggregation(t *testing.T) {
+	_, pubs := generateKeys(t, 3)
+	k1, _ := AggregateKeys(pubs)
+	k2, _ := AggregateKeys([]*sm2.PublicKey{pubs[1], pubs[0], pubs[2]})
+	if k1.PublicKey().X.Cmp(k2.PublicKey().X) == 0 {
+		t.Fatal("key order does not affect the aggregate key")
+	}
+
+	// Duplicate keys are allowed.
+	privs, pubs := generateKeys(t, 1)
+	privs = append(privs, privs[0])
+	pubs = append(pubs, pubs[0])
+	key, err := AggregateKeys(pubs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, _, _, _ := sign(t, privs, key, []byte("msg"))
+	if !Verify(key.PublicKey(), []byte("msg"), sig) {
+		t.Fatal("signature with duplicate keys does not verify")
+	}
+
+	if _, err := AggregateKeys(nil); err == nil {
+		t.Fatal("empty key list accepted")
+	}
+}
+
+func TestNonceReuse(t *testing.T) {
+	privs, pubs := generateKeys(t, 2)
+	key, _ := AggregateKeys(pubs)
+	sec, pub1, _ := NewNonce(rand.Reader)
+	_, pub2, _ := NewNonce(rand.Reader)
+	agg, _ := AggregateNonces([][]byte{pub1, pub2})
+	s, _ := NewSession(key, agg, []byte("msg"))
+	if _, err := s.Sign(sec, privs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sign(sec, privs[0]); err != errNonceReused {
+		t.Fatalf("second use of a nonce: %v", err)
+	}
+
+	other, _ := sm2.GenerateKey()
+	sec, _, _ = NewNonce(rand.Reader)
+	if _, err := s.Sign(sec, other); err != errNotSigner {
+		t.Fatalf("signing with a foreign key: %v", err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	privs, pubs := generateKeys(t, 2)
+	key, _ := AggregateKeys(pubs)
+	sig, _, _, _ := sign(t, privs, key, []byte("msg"))
+	for i := range sig {
+		bad := append([]byte(nil), sig...)
+		bad[i] ^= 1
+		if Verify(key.PublicKey(), []byte("msg"), bad) {
+			t.Fatalf("signature with byte %d flipped verifies", i)
+		}
+	}
+	if Verify(key.PublicKey(), []byte("msg"), sig[:63]) {
+		t.Fatal("short signature verifies")
+	}
+	if _, err := AggregateNonces([][]byte{make([]byte, PublicNonceSize)}); err == nil {
+		t.Fatal("nonce at infinity accepted")
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	privs, pubs := generateKeys(b, 3)
+	key, _ := AggregateKeys(pubs)
+	sig, _, _, _ := sign(b, privs, key, []byte("msg"))
+	pub := key.PublicKey()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(pub, []byte("msg"), sig)
+	}
+}
@@ -0,0 +1,41 @@
+package sm2
+
+import (
+	"crypto"
+	"errors"
+	"io"
+)
+
+// OpaqueSigner is an SM2 private key that may not expose its scalar, such as
+// a key held by an HSM. *PrivateKey implements it, so code written against
+// OpaqueSigner works with software and hardware keys alike.
+type OpaqueSigner interface {
+	// Public returns the *PublicKey of the key.
+	Public() crypto.PublicKey
+	// Sign signs the SM3 digest e = SM3(ZA || M) and returns the ASN.1
+	// signature, like PrivateKey.Sign.
+	Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+	// Decrypt decrypts a ciphertext produced by Encrypt.
+	Decrypt(ciphertext []byte) ([]byte, error)
+	// SharedSecret returns the x-coordinate of d·P for the private scalar d
+	// and the peer public key P, the shared secret of SM2 key agreement
+	// before key derivation.
+	SharedSecret(peer *PublicKey) ([]byte, error)
+}
+
+var errInvalidPeer = errors.New("sm2: invalid peer public key")
+
+// SharedSecret returns the 32-byte x-coordinate of priv.D·peer.
+func (priv *PrivateKey) SharedSecret(peer *PublicKey) ([]byte, error) {
+	if peer == nil || peer.X == nil || peer.Y == nil || !priv.Curve.IsOnCurve(peer.X, peer.Y) {
+		return nil, errInvalidPeer
+	}
+	x, y := priv.Curve.ScalarMult(peer.X, peer.Y, priv.D.Bytes())
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errInvalidPeer
+	}
+	out := make([]byte, 32)
+	xBytes := x.Bytes()
+	copy(out[32-len(xBytes):], xBytes)
+	return out, nil
+}
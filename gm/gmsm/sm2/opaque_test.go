@@ -0,0 +1,39 @@
+package sm2
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+var _ OpaqueSigner = (*PrivateKey)(nil)
+
+func TestSharedSecret(t *testing.T) {
+	a, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab, err := a.SharedSecret(&b.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ba, err := b.SharedSecret(&a.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ab) != 32 || !bytes.Equal(ab, ba) {
+		t.Fatal("the shared secrets differ")
+	}
+
+	bad := &PublicKey{Curve: P256Sm2(), X: big.NewInt(1), Y: big.NewInt(2)}
+	if _, err := a.SharedSecret(bad); err == nil {
+		t.Fatal("expected an error for a point off the curve")
+	}
+	if _, err := a.SharedSecret(nil); err == nil {
+		t.Fatal("expected an error for a nil key")
+	}
+}
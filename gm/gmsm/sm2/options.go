@@ -0,0 +1,232 @@
+package sm2
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+)
+
+// DefaultUID is the user ID of GM/T 0009 for signers without one. ZA, and
+// so every signing and verification function of the package, uses it for
+// a nil user ID.
+var DefaultUID = []byte("1234567812345678")
+
+// resolveUID returns the user ID hashed into ZA for uid.
+func resolveUID(uid []byte) []byte {
+	if uid == nil {
+		return DefaultUID
+	}
+	return uid
+}
+
+// VerifyError is the error of CheckSignature. It carries the user ID the
+// signature was checked under, since most interoperability failures come
+// from peers that default the UID differently.
+type VerifyError struct {
+	UID    []byte
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("sm2: %s (UID %q)", e.Reason, e.UID)
+}
+
+// CiphertextMode is the order of the parts of an SM2 ciphertext.
+type CiphertextMode int
+
+const (
+	// C1C3C2 is 04 || x1 || y1 || C3 (hash) || C2 (masked message), the
+	// format of Encrypt and of GB/T 32918.4-2016.
+	C1C3C2 CiphertextMode = iota
+	// C1C2C3 is 04 || x1 || y1 || C2 || C3, the format of the 2012 draft
+	// still used by some peers.
+	C1C2C3
+)
+
+// Option is an option of SignEx, VerifyEx, CheckSignature, EncryptEx and
+// DecryptEx. An option that does not apply to a function is ignored by it.
+type Option func(*options)
+
+type options struct {
+	uid       []byte
+	mode      CiphertextMode
+	rand      io.Reader
+	canonical bool
+	strict    bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.rand == nil {
+		o.rand = RandSource()
+	}
+	return o
+}
+
+// WithUID sets the user ID hashed into ZA by SignEx, VerifyEx and
+// CheckSignature; nil is DefaultUID.
+func WithUID(uid []byte) Option {
+	return func(o *options) { o.uid = uid }
+}
+
+// WithEmptyUID signs or verifies with the empty user ID instead of
+// DefaultUID, for peers that hash no UID into ZA.
+func WithEmptyUID() Option {
+	return func(o *options) { o.uid = []byte{} }
+}
+
+// WithCiphertextMode sets the format of the ciphertexts of EncryptEx and
+// DecryptEx, C1C3C2 by default.
+func WithCiphertextMode(mode CiphertextMode) Option {
+	return func(o *options) { o.mode = mode }
+}
+
+// WithRand sets the source of randomness of SignEx and EncryptEx instead
+// of RandSource().
+func WithRand(rand io.Reader) Option {
+	return func(o *options) { o.rand = rand }
+}
+
+// WithCanonicalSig makes VerifyEx reject signatures that are not exactly
+// the DER encoding SignEx produces, e.g. with trailing data, so that a
+// signature has a single valid encoding.
+func WithCanonicalSig() Option {
+	return func(o *options) { o.canonical = true }
+}
+
+// WithStrictValidation validates the key of the operation, as
+// SetKeyValidation(true) does for all operations.
+func WithStrictValidation() Option {
+	return func(o *options) { o.strict = true }
+}
+
+func (o *options) checkPrivateKey(priv *PrivateKey) error {
+	if o.strict {
+		return priv.Validate()
+	}
+	return checkPrivateKey(priv)
+}
+
+func (o *options) checkPublicKey(pub *PublicKey) error {
+	if o.strict {
+		return pub.Validate()
+	}
+	return checkPublicKey(pub)
+}
+
+var errCiphertextMode = errors.New("sm2: unknown ciphertext mode")
+
+// SignEx signs msg, hashed with ZA of the user ID, and returns the ASN.1
+// signature. It is Sm2Sign with options: WithUID, WithEmptyUID, WithRand
+// and WithStrictValidation.
+func SignEx(priv *PrivateKey, msg []byte, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	sig, err := signEx(priv, msg, o)
+	record(audit.OpSign, &priv.PublicKey, err)
+	return sig, err
+}
+
+func signEx(priv *PrivateKey, msg []byte, o *options) ([]byte, error) {
+	if err := o.checkPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	r, s, _, err := sm2Sign(priv, msg, o.uid, o.rand)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(sm2Signature{r, s})
+}
+
+// VerifyEx verifies an ASN.1 signature of SignEx. It is Sm2Verify with
+// options: WithUID, WithEmptyUID, WithCanonicalSig and
+// WithStrictValidation.
+func VerifyEx(pub *PublicKey, msg, sig []byte, opts ...Option) bool {
+	return CheckSignature(pub, msg, sig, opts...) == nil
+}
+
+// CheckSignature is VerifyEx returning a *VerifyError, which names the
+// resolved user ID, instead of false.
+func CheckSignature(pub *PublicKey, msg, sig []byte, opts ...Option) error {
+	o := newOptions(opts)
+	fail := func(reason string) error {
+		return &VerifyError{UID: resolveUID(o.uid), Reason: reason}
+	}
+	if err := o.checkPublicKey(pub); err != nil {
+		return fail("invalid public key")
+	}
+	var rs sm2Signature
+	rest, err := asn1.Unmarshal(sig, &rs)
+	if err != nil {
+		return fail("malformed signature")
+	}
+	if o.canonical {
+		der, err := asn1.Marshal(rs)
+		if len(rest) != 0 || err != nil || !bytes.Equal(der, sig) {
+			return fail("non-canonical signature encoding")
+		}
+	}
+	if !Sm2Verify(pub, msg, o.uid, rs.R, rs.S) {
+		return fail("signature verification failed")
+	}
+	return nil
+}
+
+// EncryptEx encrypts msg to pub. It is Encrypt with options:
+// WithCiphertextMode, WithRand and WithStrictValidation.
+func EncryptEx(pub *PublicKey, msg []byte, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	if err := o.checkPublicKey(pub); err != nil {
+		return nil, err
+	}
+	if o.mode != C1C3C2 && o.mode != C1C2C3 {
+		return nil, errCiphertextMode
+	}
+	ct, err := encrypt(pub, msg, o.rand)
+	if err != nil || o.mode == C1C3C2 || len(ct) == 0 {
+		return ct, err
+	}
+	// 04 || x1 || y1 is 65 bytes, C3 32; C3 is moved after C2 in place.
+	var c3 [32]byte
+	copy(c3[:], ct[65:97])
+	copy(ct[65:], ct[97:])
+	copy(ct[len(ct)-32:], c3[:])
+	return ct, nil
+}
+
+// DecryptEx decrypts a ciphertext of EncryptEx. It is Decrypt with
+// options: WithCiphertextMode and WithStrictValidation.
+func DecryptEx(priv *PrivateKey, ciphertext []byte, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	pt, err := decryptEx(priv, ciphertext, o)
+	record(audit.OpDecrypt, &priv.PublicKey, err)
+	return pt, err
+}
+
+func decryptEx(priv *PrivateKey, ct []byte, o *options) ([]byte, error) {
+	if err := o.checkPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	switch o.mode {
+	case C1C3C2:
+	case C1C2C3:
+		if len(ct) != 0 {
+			if len(ct) < 97 {
+				return nil, errors.New("Decrypt: failed to decrypt")
+			}
+			c := make([]byte, 0, len(ct))
+			c = append(c, ct[:65]...)
+			c = append(c, ct[len(ct)-32:]...)
+			ct = append(c, ct[65:len(ct)-32]...)
+		}
+	default:
+		return nil, errCiphertextMode
+	}
+	return decrypt(priv, ct)
+}
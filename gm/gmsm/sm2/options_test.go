@@ -0,0 +1,138 @@
+package sm2
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestSignEx(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("message")
+	sig, err := SignEx(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := SignDataToSignDigit(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sm2Verify(&priv.PublicKey, msg, DefaultUID, r, s) {
+		t.Fatal("SignEx does not default to DefaultUID")
+	}
+	if !VerifyEx(&priv.PublicKey, msg, sig) || VerifyEx(&priv.PublicKey, []byte("other"), sig) {
+		t.Fatal("VerifyEx failed")
+	}
+
+	uid := []byte("alice@example.com")
+	sig, err = SignEx(priv, msg, WithUID(uid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyEx(&priv.PublicKey, msg, sig) || !VerifyEx(&priv.PublicKey, msg, sig, WithUID(uid)) {
+		t.Fatal("WithUID is not applied")
+	}
+
+	sig1, _ := SignEx(priv, msg, WithRand(&countingReader{}))
+	sig2, _ := SignEx(priv, msg, WithRand(&countingReader{}))
+	if !bytes.Equal(sig1, sig2) {
+		t.Fatal("WithRand is not applied")
+	}
+
+	padded := append(append([]byte(nil), sig1...), 0)
+	if !VerifyEx(&priv.PublicKey, msg, padded) {
+		t.Fatal("trailing data is rejected without WithCanonicalSig")
+	}
+	if VerifyEx(&priv.PublicKey, msg, padded, WithCanonicalSig()) {
+		t.Fatal("WithCanonicalSig accepted trailing data")
+	}
+	if !VerifyEx(&priv.PublicKey, msg, sig1, WithCanonicalSig()) {
+		t.Fatal("WithCanonicalSig rejected a canonical signature")
+	}
+
+	corrupt := &PrivateKey{PublicKey: priv.PublicKey, D: new(big.Int).Add(priv.D, one)}
+	if _, err := SignEx(corrupt, msg, WithStrictValidation()); err != ErrInvalidPrivateKey {
+		t.Fatalf("expected ErrInvalidPrivateKey, got %v", err)
+	}
+}
+
+func TestEncryptEx(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("secret message")
+
+	ct, err := EncryptEx(&priv.PublicKey, msg, WithRand(&countingReader{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := Decrypt(priv, ct); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("the default mode is not C1C3C2: %q, %v", pt, err)
+	}
+
+	ct2, err := EncryptEx(&priv.PublicKey, msg, WithRand(&countingReader{}), WithCiphertextMode(C1C2C3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append(append([]byte(nil), ct[:65]...), ct[97:]...), ct[65:97]...)
+	if !bytes.Equal(ct2, want) {
+		t.Fatal("wrong C1C2C3 ciphertext")
+	}
+	if pt, err := DecryptEx(priv, ct2, WithCiphertextMode(C1C2C3)); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("DecryptEx returned %q, %v", pt, err)
+	}
+	if _, err := DecryptEx(priv, ct2); err == nil {
+		t.Fatal("a C1C2C3 ciphertext decrypted as C1C3C2")
+	}
+	if _, err := EncryptEx(&priv.PublicKey, msg, WithCiphertextMode(7)); err != errCiphertextMode {
+		t.Fatalf("expected errCiphertextMode, got %v", err)
+	}
+
+	offCurve := &PublicKey{Curve: priv.Curve, X: priv.X, Y: new(big.Int).Add(priv.Y, one)}
+	if _, err := EncryptEx(offCurve, msg, WithStrictValidation()); err != ErrInvalidPublicKey {
+		t.Fatalf("expected ErrInvalidPublicKey, got %v", err)
+	}
+}
+
+func TestDefaultUID(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("message")
+	r, s, err := Sm2Sign(priv, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sm2Verify(&priv.PublicKey, msg, []byte("1234567812345678"), r, s) {
+		t.Fatal("a nil UID is not the default UID")
+	}
+	if Sm2Verify(&priv.PublicKey, msg, []byte{}, r, s) {
+		t.Fatal("an empty UID is the default UID")
+	}
+
+	sig, err := SignEx(priv, msg, WithEmptyUID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, _ = SignDataToSignDigit(sig)
+	if !Sm2Verify(&priv.PublicKey, msg, []byte{}, r, s) || !VerifyEx(&priv.PublicKey, msg, sig, WithEmptyUID()) {
+		t.Fatal("WithEmptyUID does not sign with the empty UID")
+	}
+
+	err = CheckSignature(&priv.PublicKey, msg, sig)
+	e, ok := err.(*VerifyError)
+	if !ok || string(e.UID) != "1234567812345678" {
+		t.Fatalf("expected a VerifyError with the default UID, got %v", err)
+	}
+	if err.Error() != `sm2: signature verification failed (UID "1234567812345678")` {
+		t.Fatalf("error %q", err)
+	}
+	if err := CheckSignature(&priv.PublicKey, msg, sig, WithEmptyUID()); err != nil {
+		t.Fatal(err)
+	}
+}
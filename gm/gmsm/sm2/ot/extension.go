@@ -0,0 +1,261 @@
+package ot
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// Kappa is the number of base OTs behind an extension, its computational
+// security parameter in bits.
+const Kappa = 128
+
+// The extension turns Kappa base OTs, run with the roles reversed, into any
+// number of OTs (Ishai, Kilian, Nissim and Petrank). For m OTs with choice
+// bits r:
+//
+//   - The extension receiver, as base sender, holds key pairs (k0_i, k1_i)
+//     and the extension sender, as base receiver for random bits s_i, holds
+//     k_(s_i)_i, for i < Kappa.
+//   - The receiver sends the columns u_i = G(k0_i) ⊕ G(k1_i) ⊕ r, where G is
+//     SM4 in counter mode keyed with the first 16 bytes of a key, and keeps
+//     t_i = G(k0_i).
+//   - The sender computes q_i = G(k_(s_i)_i) ⊕ s_i·u_i = t_i ⊕ s_i·r. Read
+//     row-wise, q_j = t_j ⊕ r_j·s: the sender's row j equals the receiver's
+//     if r_j = 0 and is offset by the secret s if r_j = 1.
+//   - The sender sends y0_j = x0_j ⊕ H(j, q_j) and y1_j = x1_j ⊕ H(j, q_j ⊕ s),
+//     and the receiver decrypts y_(r_j)_j with H(j, t_j).
+//
+// The counter mode streams and the OT index j continue across batches, so
+// each batch consumes fresh output.
+
+// ExtReceiver is the receiving side of an OT extension.
+type ExtReceiver struct {
+	base  *BaseSender
+	prg   [Kappa][2]cipher.Stream
+	index uint64
+	// rows and choices of the batch awaiting the sender's ciphertexts
+	rows    [][]byte
+	choices []bool
+}
+
+// ExtSender is the sending side of an OT extension.
+type ExtSender struct {
+	s     [Kappa / 8]byte
+	prg   [Kappa]cipher.Stream
+	index uint64
+}
+
+func newPRG(key []byte) cipher.Stream {
+	block, err := sm4.NewCipher(key[:sm4.KeySize])
+	if err != nil {
+		panic(err)
+	}
+	return cipher.NewCTR(block, make([]byte, sm4.BlockSize))
+}
+
+func bit(b []byte, i int) bool { return b[i/8]>>(uint(i)%8)&1 == 1 }
+
+// transpose turns Kappa columns of m bits into m rows of Kappa bits.
+func transpose(cols [][]byte, m int) [][]byte {
+	rows := make([][]byte, m)
+	for j := range rows {
+		rows[j] = make([]byte, Kappa/8)
+	}
+	for i, col := range cols {
+		for j := 0; j < m; j++ {
+			if bit(col, j) {
+				rows[j][i/8] |= 1 << (uint(i) % 8)
+			}
+		}
+	}
+	return rows
+}
+
+// mask returns n bytes of H(j, q): a digest of the index and row, expanded
+// by SM3 in counter mode for messages longer than the digest.
+func mask(j uint64, q []byte, n int) []byte {
+	h := sm3.New()
+	h.Write([]byte("OT/extension"))
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], j)
+	h.Write(b[:])
+	h.Write(q)
+	seed := h.Sum(nil)
+	if n <= sm3.Size {
+		return seed[:n]
+	}
+
+	out := make([]byte, 0, n+sm3.Size)
+	var ctr [4]byte
+	for i := uint32(0); len(out) < n; i++ {
+		binary.BigEndian.PutUint32(ctr[:], i)
+		h := sm3.New()
+		h.Write(seed)
+		h.Write(ctr[:])
+		out = h.Sum(out)
+	}
+	return out[:n]
+}
+
+func xor(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// NewExtReceiver starts an OT extension and returns the setup message of its
+// base OTs for the sender.
+func NewExtReceiver(rand io.Reader) (*ExtReceiver, []byte, error) {
+	base, setup, err := NewBaseSender(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ExtReceiver{base: base}, setup, nil
+}
+
+// NewExtSender joins an OT extension given the receiver's setup message and
+// returns the base OT message for the receiver's Init.
+func NewExtSender(rand io.Reader, setup []byte) (*ExtSender, []byte, error) {
+	s := new(ExtSender)
+	if _, err := io.ReadFull(rand, s.s[:]); err != nil {
+		return nil, nil, err
+	}
+	choices := make([]bool, Kappa)
+	for i := range choices {
+		choices[i] = bit(s.s[:], i)
+	}
+	msg, keys, err := BaseReceive(rand, setup, choices)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, k := range keys {
+		s.prg[i] = newPRG(k)
+	}
+	return s, msg, nil
+}
+
+// Init completes the base OTs with the sender's message.
+func (r *ExtReceiver) Init(msg []byte) error {
+	if r.base == nil {
+		return errState
+	}
+	if len(msg) != Kappa*PointSize {
+		return errMalformed
+	}
+	keys, err := r.base.Keys(msg)
+	if err != nil {
+		return err
+	}
+	for i, k := range keys {
+		r.prg[i][0] = newPRG(k[0])
+		r.prg[i][1] = newPRG(k[1])
+	}
+	r.base = nil
+	return nil
+}
+
+// Extend starts a batch of OTs with the given choice bits and returns the
+// matrix for the sender's Send. Each batch must be completed with Receive
+// before the next one starts.
+func (r *ExtReceiver) Extend(choices []bool) ([]byte, error) {
+	if r.base != nil || r.prg[0][0] == nil || r.rows != nil {
+		return nil, errState
+	}
+	m := len(choices)
+	if m == 0 {
+		return nil, errMalformed
+	}
+	colLen := (m + 7) / 8
+	packed := make([]byte, colLen)
+	for j, c := range choices {
+		if c {
+			packed[j/8] |= 1 << (uint(j) % 8)
+		}
+	}
+
+	u := make([]byte, Kappa*colLen)
+	cols := make([][]byte, Kappa)
+	for i := range cols {
+		cols[i] = make([]byte, colLen)
+		r.prg[i][0].XORKeyStream(cols[i], cols[i])
+		ui := u[i*colLen : (i+1)*colLen]
+		r.prg[i][1].XORKeyStream(ui, ui)
+		xor(ui, ui, cols[i])
+		xor(ui, ui, packed)
+	}
+	r.rows = transpose(cols, m)
+	r.choices = append([]bool(nil), choices...)
+	return u, nil
+}
+
+// Receive returns the chosen message of each OT of the current batch from
+// the sender's ciphertexts.
+func (r *ExtReceiver) Receive(ciphertexts []byte) ([][]byte, error) {
+	if r.rows == nil {
+		return nil, errState
+	}
+	m := len(r.rows)
+	if len(ciphertexts) == 0 || len(ciphertexts)%(2*m) != 0 {
+		return nil, errMalformed
+	}
+	n := len(ciphertexts) / (2 * m)
+	out := make([][]byte, m)
+	for j := range out {
+		y := ciphertexts[2*j*n : (2*j+2)*n]
+		if r.choices[j] {
+			y = y[n:]
+		} else {
+			y = y[:n]
+		}
+		out[j] = make([]byte, n)
+		xor(out[j], y[:n], mask(r.index+uint64(j), r.rows[j], n))
+	}
+	r.index += uint64(m)
+	r.rows, r.choices = nil, nil
+	return out, nil
+}
+
+// Send answers a batch of OTs given the receiver's matrix and the message
+// pairs, one pair per OT of the batch. All messages of a batch must have the
+// same nonzero length. It returns the ciphertexts for the receiver.
+func (s *ExtSender) Send(u []byte, msgs [][2][]byte) ([]byte, error) {
+	m := len(msgs)
+	if m == 0 || len(msgs[0][0]) == 0 {
+		return nil, errMalformed
+	}
+	n := len(msgs[0][0])
+	for _, x := range msgs {
+		if len(x[0]) != n || len(x[1]) != n {
+			return nil, errMalformed
+		}
+	}
+	colLen := (m + 7) / 8
+	if len(u) != Kappa*colLen {
+		return nil, errMalformed
+	}
+
+	cols := make([][]byte, Kappa)
+	for i := range cols {
+		cols[i] = make([]byte, colLen)
+		s.prg[i].XORKeyStream(cols[i], cols[i])
+		if bit(s.s[:], i) {
+			xor(cols[i], cols[i], u[i*colLen:(i+1)*colLen])
+		}
+	}
+	rows := transpose(cols, m)
+
+	out := make([]byte, 2*m*n)
+	qs := make([]byte, Kappa/8)
+	for j, q := range rows {
+		y := out[2*j*n : (2*j+2)*n]
+		xor(y[:n], msgs[j][0], mask(s.index+uint64(j), q, n))
+		xor(qs, q, s.s[:])
+		xor(y[n:], msgs[j][1], mask(s.index+uint64(j), qs, n))
+	}
+	s.index += uint64(m)
+	return out, nil
+}
@@ -0,0 +1,132 @@
+// Package ot implements 1-out-of-2 oblivious transfer: base OTs over the
+// SM2 curve and an IKNP-style OT extension with SM3 and SM4, the building
+// blocks of garbled circuits and private set intersection.
+//
+// In an oblivious transfer the sender holds pairs of messages (x0, x1) and
+// the receiver a choice bit c per pair; the receiver learns x_c and nothing
+// about x_(1-c), the sender learns nothing about c.
+//
+// The base OTs follow the "simplest OT" of Chou and Orlandi, an ECDH
+// exchange in which the receiver's point either is or is not offset by the
+// sender's: with the sender's A = a·G, the receiver sends B = b·G + c·A for
+// each choice c and derives k_c = H(i, A, B, b·A), while the sender derives
+// k0 = H(i, A, B, a·B) and k1 = H(i, A, B, a·(B - A)), one of which the
+// receiver cannot compute. H is SM3 and points are in compressed SEC 1
+// form. Base OTs cost a few scalar multiplications each, so they are only
+// used to set up the extension, which then produces any number of OTs from
+// 128 of them with symmetric primitives alone.
+//
+// The protocols are secure against semi-honest parties, which follow the
+// protocol but try to learn more from what they see. A malicious receiver
+// of the extension can learn more than one message of some pairs by sending
+// an inconsistent matrix; protocols facing malicious parties need the
+// consistency check of Keller, Orsini and Scholl on top.
+package ot
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// KeySize is the size of the keys output by the base OTs in bytes.
+const KeySize = 32
+
+// PointSize is the size of a compressed point in bytes; the setup message
+// of a base OT sender is one point and the receiver's message one point per
+// OT.
+const PointSize = ecpoint.Size
+
+var (
+	errMalformed = errors.New("ot: malformed protocol message")
+	errState     = errors.New("ot: protocol step out of order")
+)
+
+// baseKey returns H(i, A, B, P).
+func baseKey(i int, a, b []byte, p *ecpoint.Point) []byte {
+	h := sm3.New()
+	h.Write([]byte("OT/base"))
+	var idx [8]byte
+	binary.BigEndian.PutUint64(idx[:], uint64(i))
+	h.Write(idx[:])
+	h.Write(a)
+	h.Write(b)
+	h.Write(p.Compress())
+	return h.Sum(nil)
+}
+
+// BaseSender is the sending side of a batch of base OTs.
+type BaseSender struct {
+	a     *big.Int
+	setup []byte
+	// negAA is -a·A.
+	negAA *ecpoint.Point
+}
+
+// NewBaseSender starts a batch of base OTs and returns the setup message
+// for the receiver.
+func NewBaseSender(rand io.Reader) (*BaseSender, []byte, error) {
+	a, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	pa := ecpoint.BaseMul(a)
+	s := &BaseSender{a: a, setup: pa.Compress(), negAA: pa.Mul(a).Neg()}
+	return s, s.setup, nil
+}
+
+// Keys returns the key pairs (k0, k1) of the OTs given the receiver's
+// message, one pair per OT; the receiver knows exactly one key of each
+// pair. Use them to encrypt the actual messages, e.g. x_c XOR k_c.
+func (s *BaseSender) Keys(msg []byte) ([][2][]byte, error) {
+	if len(msg) == 0 || len(msg)%PointSize != 0 {
+		return nil, errMalformed
+	}
+	keys := make([][2][]byte, len(msg)/PointSize)
+	for i := range keys {
+		enc := msg[i*PointSize : (i+1)*PointSize]
+		b, err := ecpoint.Decompress(enc, false)
+		if err != nil {
+			return nil, errMalformed
+		}
+		ab := b.Mul(s.a)
+		keys[i][0] = baseKey(i, s.setup, enc, ab)
+		keys[i][1] = baseKey(i, s.setup, enc, ab.Add(s.negAA))
+	}
+	return keys, nil
+}
+
+// BaseReceive runs the receiving side of a batch of base OTs on the
+// sender's setup message. It returns the message for the sender and the
+// key k_c of each OT for the corresponding choice c.
+func BaseReceive(rand io.Reader, setup []byte, choices []bool) (msg []byte, keys [][]byte, err error) {
+	pa, err := ecpoint.Decompress(setup, false)
+	if err != nil {
+		return nil, nil, errMalformed
+	}
+	msg = make([]byte, 0, len(choices)*PointSize)
+	keys = make([][]byte, len(choices))
+	for i, c := range choices {
+		b, err := ecpoint.RandScalar(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		// B = b·G + c·A
+		pb := ecpoint.BaseMul(b)
+		if c {
+			pb = pb.Add(pa)
+		}
+		if pb.IsInfinity() {
+			// b = -a, which happens with negligible probability.
+			return nil, nil, errors.New("ot: degenerate base OT")
+		}
+		enc := pb.Compress()
+		msg = append(msg, enc...)
+		keys[i] = baseKey(i, setup, enc, pa.Mul(b))
+	}
+	return msg, keys, nil
+}
@@ -0,0 +1,170 @@
+package ot
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+)
+
+func TestBaseOT(t *testing.T) {
+	sender, setup, err := NewBaseSender(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	choices := []bool{false, true, true, false, true}
+	msg, keys, err := BaseReceive(rand.Reader, setup, choices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := sender.Keys(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != len(choices) {
+		t.Fatalf("got %d key pairs, want %d", len(pairs), len(choices))
+	}
+	for i, c := range choices {
+		chosen, other := pairs[i][0], pairs[i][1]
+		if c {
+			chosen, other = other, chosen
+		}
+		if !bytes.Equal(keys[i], chosen) {
+			t.Fatalf("OT %d: receiver key differs from the chosen sender key", i)
+		}
+		if bytes.Equal(keys[i], other) {
+			t.Fatalf("OT %d: receiver key equals the other sender key", i)
+		}
+	}
+
+	if _, _, err := BaseReceive(rand.Reader, setup[1:], choices); err == nil {
+		t.Fatal("expected error for a malformed setup")
+	}
+	if _, err := sender.Keys(msg[1:]); err == nil {
+		t.Fatal("expected error for a malformed message")
+	}
+}
+
+// setup runs the base OTs of an extension.
+func setup(t testing.TB) (*ExtReceiver, *ExtSender) {
+	r, msg, err := NewExtReceiver(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, msg, err := NewExtSender(rand.Reader, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Init(msg); err != nil {
+		t.Fatal(err)
+	}
+	return r, s
+}
+
+func batch(m, n int) ([]bool, [][2][]byte) {
+	choices := make([]bool, m)
+	msgs := make([][2][]byte, m)
+	c := make([]byte, m)
+	rand.Read(c)
+	for j := range msgs {
+		choices[j] = c[j]&1 == 1
+		msgs[j][0] = make([]byte, n)
+		msgs[j][1] = make([]byte, n)
+		rand.Read(msgs[j][0])
+		rand.Read(msgs[j][1])
+	}
+	return choices, msgs
+}
+
+func TestExtension(t *testing.T) {
+	r, s := setup(t)
+	// Several batches, of sizes that are and are not multiples of 8, with
+	// messages shorter and longer than an SM3 digest.
+	for _, size := range []struct{ m, n int }{{1000, 16}, {1, 1}, {13, 100}, {4096, 32}} {
+		choices, msgs := batch(size.m, size.n)
+		u, err := r.Extend(choices)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := s.Send(u, msgs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Receive(y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, c := range choices {
+			want, other := msgs[j][0], msgs[j][1]
+			if c {
+				want, other = other, want
+			}
+			if !bytes.Equal(got[j], want) {
+				t.Fatalf("batch of %d: OT %d returned the wrong message", size.m, j)
+			}
+			if bytes.Equal(got[j], other) {
+				t.Fatalf("batch of %d: OT %d returned the other message", size.m, j)
+			}
+		}
+	}
+}
+
+func TestExtensionErrors(t *testing.T) {
+	r, _, err := NewExtReceiver(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Extend([]bool{true}); err == nil {
+		t.Fatal("expected error for Extend before Init")
+	}
+
+	r, s := setup(t)
+	if _, err := r.Receive(make([]byte, 64)); err == nil {
+		t.Fatal("expected error for Receive before Extend")
+	}
+	choices, msgs := batch(16, 8)
+	u, err := r.Extend(choices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Extend(choices); err == nil {
+		t.Fatal("expected error for a second Extend before Receive")
+	}
+	if _, err := s.Send(u, msgs[8:]); err == nil {
+		t.Fatal("expected error for a mismatched number of messages")
+	}
+	msgs[3][1] = msgs[3][1][1:]
+	if _, err := s.Send(u, msgs); err == nil {
+		t.Fatal("expected error for messages of different lengths")
+	}
+	if _, err := r.Receive(make([]byte, 17)); err == nil {
+		t.Fatal("expected error for malformed ciphertexts")
+	}
+	if err := r.Init(make([]byte, Kappa*PointSize)); err == nil {
+		t.Fatal("expected error for a second Init")
+	}
+}
+
+func BenchmarkExtension(b *testing.B) {
+	for _, m := range []int{1 << 10, 1 << 16} {
+		b.Run(fmt.Sprint(m), func(b *testing.B) {
+			r, s := setup(b)
+			choices, msgs := batch(m, 16)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				u, _ := r.Extend(choices)
+				y, _ := s.Send(u, msgs)
+				r.Receive(y)
+			}
+		})
+	}
+}
+
+func BenchmarkBaseOT(b *testing.B) {
+	choices := make([]bool, Kappa)
+	for i := 0; i < b.N; i++ {
+		sender, setup, _ := NewBaseSender(rand.Reader)
+		msg, _, _ := BaseReceive(rand.Reader, setup, choices)
+		sender.Keys(msg)
+	}
+}
@@ -19,7 +19,6 @@ import (
 	"crypto/elliptic"
 	"math/big"
 	"sync"
-	"unsafe"
 )
 
 /**
@@ -86,6 +85,8 @@ func initP256Sm2() {
 	sm2P256FromBig(&sm2P256.gx, sm2P256.Gx)
 	sm2P256FromBig(&sm2P256.gy, sm2P256.Gy)
 	sm2P256FromBig(&sm2P256.b, sm2P256.B)
+	initSm2P256PMultiples()
+	initSm2P256RR()
 }
 
 func P256Sm2() elliptic.Curve {
@@ -266,8 +267,8 @@ func sm2P256SelectAffinePoint(xOut, yOut *sm2P256FieldElement, table []uint32, i
 
 // sm2P256SelectJacobianPoint sets {out_x,out_y,out_z} to the index'th entry of table.
 //
-// On entry: index < 16, table[0] must be zero.
-func sm2P256SelectJacobianPoint(xOut, yOut, zOut *sm2P256FieldElement, table *[16][3]sm2P256FieldElement, index uint32) {
+// On entry: index < 1<<sm2P256Window, table[0] must be zero.
+func sm2P256SelectJacobianPoint(xOut, yOut, zOut *sm2P256FieldElement, table *[1 << sm2P256Window][3]sm2P256FieldElement, index uint32) {
 	for j := range xOut {
 		xOut[j] = table[index][0][j]
 		yOut[j] = table[index][1][j]
@@ -284,6 +285,13 @@ func sm2P256GetBit(scalar *[32]uint8, bit uint) uint32 {
 // little-endian number. Note that the value of scalar must be less than the
 // order of the group.
 func sm2P256ScalarBaseMult(xOut, yOut, zOut *sm2P256FieldElement, scalar *[32]uint8) {
+	sm2P256ScalarMultComb(xOut, yOut, zOut, sm2P256Precomputed[:], scalar)
+}
+
+// sm2P256ScalarMultComb sets {xOut,yOut,zOut} = scalar*P for the point P of
+// table, laid out as sm2P256Precomputed is for G, where scalar is a
+// little-endian number less than the order of the group.
+func sm2P256ScalarMultComb(xOut, yOut, zOut *sm2P256FieldElement, table []uint32, scalar *[32]uint8) {
 	nIsInfinityMask := ^uint32(0)
 	var px, py, tx, ty, tz sm2P256FieldElement
 	var pIsNoninfiniteMask, mask, tableOffset uint32
@@ -312,7 +320,7 @@ func sm2P256ScalarBaseMult(xOut, yOut, zOut *sm2P256FieldElement, scalar *[32]ui
 			bit3 := sm2P256GetBit(scalar, 223-i+j)
 			index := bit0 | (bit1 << 1) | (bit2 << 2) | (bit3 << 3)
 
-			sm2P256SelectAffinePoint(&px, &py, sm2P256Precomputed[tableOffset:], index)
+			sm2P256SelectAffinePoint(&px, &py, table[tableOffset:], index)
 			tableOffset += 30 * 9
 
 			// Since scalar is less than the order of the group, we know that
@@ -340,10 +348,10 @@ func sm2P256ScalarBaseMult(xOut, yOut, zOut *sm2P256FieldElement, scalar *[32]ui
 	}
 }
 
-// sm2P256ScalarBaseMult sets {xOut,yOut,zOut} = scalar*(x,y) where scalar is a
-// little-endian number.
+// sm2P256ScalarMult sets {xOut,yOut,zOut} = scalar*(x,y) where scalar is a
+// little-endian number, with a fixed window of sm2P256Window bits.
 func sm2P256ScalarMult(xOut, yOut, zOut, x, y *sm2P256FieldElement, scalar *[32]uint8) {
-	var precomp [16][3]sm2P256FieldElement
+	var precomp [1 << sm2P256Window][3]sm2P256FieldElement
 	var px, py, pz, tx, ty, tz sm2P256FieldElement
 	var tIsInfinityMask, index, pIsNoninfiniteMask, mask uint32
 
@@ -352,7 +360,7 @@ func sm2P256ScalarMult(xOut, yOut, zOut, x, y *sm2P256FieldElement, scalar *[32]
 	precomp[1][1] = *y
 	precomp[1][2] = sm2P256Factor[1]
 
-	for i := 2; i < 16; i += 2 {
+	for i := 2; i < len(precomp); i += 2 {
 		half_i := i / 2
 		i_plus_1 := i + 1
 		sm2P256PointDouble(&precomp[i][0], &precomp[i][1], &precomp[i][2], &precomp[half_i][0], &precomp[half_i][1], &precomp[half_i][2])
@@ -361,21 +369,20 @@ func sm2P256ScalarMult(xOut, yOut, zOut, x, y *sm2P256FieldElement, scalar *[32]
 
 	// tIsInfinityMask = ^uint32(0)
 
-	// We add in a window of four bits each iteration and do this 64 times.
-
-	for i := 0; i < 64; i++ {
+	// We add in a window of sm2P256Window bits each iteration, from the most
+	// significant one, which may be partial.
+	const windows = (256 + sm2P256Window - 1) / sm2P256Window
+	for i := 0; i < windows; i++ {
 		if i != 0 {
-			sm2P256PointDouble(xOut, yOut, zOut, xOut, yOut, zOut)
-			sm2P256PointDouble(xOut, yOut, zOut, xOut, yOut, zOut)
-			sm2P256PointDouble(xOut, yOut, zOut, xOut, yOut, zOut)
-			sm2P256PointDouble(xOut, yOut, zOut, xOut, yOut, zOut)
+			for j := 0; j < sm2P256Window; j++ {
+				sm2P256PointDouble(xOut, yOut, zOut, xOut, yOut, zOut)
+			}
 		}
 
-		index = uint32(scalar[31-i/2])
-		if (i & 1) == 1 {
-			index &= 15
-		} else {
-			index >>= 4
+		index = 0
+		low := uint((windows - 1 - i) * sm2P256Window)
+		for j := uint(0); j < sm2P256Window && low+j < 256; j++ {
+			index |= sm2P256GetBit(scalar, low+j) << j
 		}
 
 		// See the comments in scalarBaseMult about handling infinities.
@@ -760,57 +767,6 @@ func sm2P256Mul(c, a, b *sm2P256FieldElement) {
 	sm2P256ReduceDegree(c, &tmp)
 }
 
-func sm2P256Mul2Way(c, a1, b1, c2, a2, b2 *sm2P256FieldElement) {
-	var tmp1, tmp2 sm2P256LargeFieldElement
-
-	addr_a1 := &a1[0]
-	addrA1 := uintptr(unsafe.Pointer(addr_a1))
-	addr_b1 := &b1[0]
-	addrB1 := uintptr(unsafe.Pointer(addr_b1))
-	addr_a2 := &a2[0]
-	addrA2 := uintptr(unsafe.Pointer(addr_a2))
-	addr_b2 := &b2[0]
-	addrB2 := uintptr(unsafe.Pointer(addr_b2))
-	addr_tmp1 := &tmp1[0]
-	addrTmp1 := uintptr(unsafe.Pointer(addr_tmp1))
-	addr_tmp2 := &tmp2[0]
-	addrTmp2 := uintptr(unsafe.Pointer(addr_tmp2))
-	_sm2P256Mul2Way1((*uint64)(unsafe.Pointer(addrTmp1)), (*uint32)(unsafe.Pointer(addrA1)),
-		(*uint32)(unsafe.Pointer(addrB1)), (*uint64)(unsafe.Pointer(addrTmp2)),
-		(*uint32)(unsafe.Pointer(addrA2)), (*uint32)(unsafe.Pointer(addrB2)))
-
-	tmp1[8] = uint64(a1[1]) * uint64(b1[7])
-	tmp1[8] += uint64(a1[3]) * uint64(b1[5])
-	tmp1[8] += uint64(a1[5]) * uint64(b1[3])
-	tmp1[8] += uint64(a1[7]) * uint64(b1[1])
-	tmp1[8] <<= 1
-	tmp1[8] += uint64(a1[0]) * uint64(b1[8])
-	tmp1[8] += uint64(a1[2]) * uint64(b1[6])
-	tmp1[8] += uint64(a1[4]) * uint64(b1[4])
-	tmp1[8] += uint64(a1[6]) * uint64(b1[2])
-	tmp1[8] += uint64(a1[8]) * uint64(b1[0])
-
-	tmp2[8] = uint64(a2[1]) * uint64(b2[7])
-	tmp2[8] += uint64(a2[3]) * uint64(b2[5])
-	tmp2[8] += uint64(a2[5]) * uint64(b2[3])
-	tmp2[8] += uint64(a2[7]) * uint64(b2[1])
-	tmp2[8] <<= 1
-	tmp2[8] += uint64(a2[0]) * uint64(b2[8])
-	tmp2[8] += uint64(a2[2]) * uint64(b2[6])
-	tmp2[8] += uint64(a2[4]) * uint64(b2[4])
-	tmp2[8] += uint64(a2[6]) * uint64(b2[2])
-	tmp2[8] += uint64(a2[8]) * uint64(b2[0])
-
-	_sm2P256Mul2Way2((*uint64)(unsafe.Pointer(addrTmp1)), (*uint32)(unsafe.Pointer(addrA1)),
-		(*uint32)(unsafe.Pointer(addrB1)), (*uint64)(unsafe.Pointer(addrTmp2)),
-		(*uint32)(unsafe.Pointer(addrA2)), (*uint32)(unsafe.Pointer(addrB2)))
-
-	sm2P256ReduceDegree2Way(c, c2, &tmp1, &tmp2)
-	// sm2P256ReduceDegree(c, &tmp1)
-	// sm2P256ReduceDegree(c2, &tmp2)
-	// return tmp1, tmp2
-}
-
 func sm2P256Square(b, a *sm2P256FieldElement) {
 
 	var tmp sm2P256LargeFieldElement
@@ -893,25 +849,6 @@ func sm2P256Square(b, a *sm2P256FieldElement) {
 	sm2P256ReduceDegree(b, &tmp)
 }
 
-func sm2P256Square2Way(b, a, b2, a2 *sm2P256FieldElement) {
-	var tmp, tmp2 sm2P256LargeFieldElement
-
-	addr1 := &tmp[0]
-	addrTMP1 := uintptr(unsafe.Pointer(addr1))
-	addr1 = &tmp2[0]
-	addrTMP2 := uintptr(unsafe.Pointer(addr1))
-
-	addr2 := &a[0]
-	addrA := uintptr(unsafe.Pointer(addr2))
-	addr2 = &a2[0]
-	addrA2 := uintptr(unsafe.Pointer(addr2))
-
-	_sm2P256Square2Way((*uint64)(unsafe.Pointer(addrTMP1)), (*uint32)(unsafe.Pointer(addrA)),
-		(*uint64)(unsafe.Pointer(addrTMP2)), (*uint32)(unsafe.Pointer(addrA2)))
-
-	sm2P256ReduceDegree2Way(b, b2, &tmp, &tmp2)
-}
-
 // poisitiveToAllOnes returns:
 //   0xffffffff for 0 < x <= 2**31
 //   0 for x == 0 or x > 2**31.
@@ -1093,49 +1030,6 @@ func sm2P256ReduceDegree(a *sm2P256FieldElement, b *sm2P256LargeFieldElement) {
 	sm2P256ReduceCarry(a, carry)
 }
 
-func sm2P256ReduceDegree2Way(a, a2 *sm2P256FieldElement, b, b2 *sm2P256LargeFieldElement) {
-	var tmp64, tmp642 [10]uint64
-	var carry, carry2 uint32
-
-	addr1 := &tmp64[0]
-	addrTMP1 := uintptr(unsafe.Pointer(addr1))
-	addr1 = &tmp642[0]
-	addrTMP2 := uintptr(unsafe.Pointer(addr1))
-
-	addr1 = &b[0]
-	addrB1 := uintptr(unsafe.Pointer(addr1))
-	addr1 = &b2[0]
-	addrB2 := uintptr(unsafe.Pointer(addr1))
-
-	addr2 := &a[0]
-	addrA := uintptr(unsafe.Pointer(addr2))
-	addr2 = &a2[0]
-	addrA2 := uintptr(unsafe.Pointer(addr2))
-
-	// sm2P256FromLargeElement(&tmp64, b)
-	// sm2P256FromLargeElement(&tmp642, b2)
-	// _sm2P256FromLargeElement_2Way((*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrB1)),
-	// 	(*uint64)(unsafe.Pointer(addrTMP2)), (*uint64)(unsafe.Pointer(addrB2)))
-
-	// _reduceDegree_2wayNew((*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrTMP2)))
-
-	// carry_temp := _sm2P256DivideByR_2way((*uint32)(unsafe.Pointer(addrA)), (*uint32)(unsafe.Pointer(addrA2)),
-	// 	(*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrTMP2)))
-	// carry = sm2P256DivideByR(a, &tmp64)
-	// carry2 = sm2P256DivideByR(a2, &tmp642)
-
-	carry_temp := _sm2ReduceDegree_2way((*uint32)(unsafe.Pointer(addrA)), (*uint32)(unsafe.Pointer(addrA2)),
-		(*uint64)(unsafe.Pointer(addrB1)), (*uint64)(unsafe.Pointer(addrB2)),
-		(*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrTMP2)))
-	carry = uint32(carry_temp)
-	carry2 = uint32(carry_temp >> 32)
-
-	// fmt.Println(carry_temp, carry, carry2)
-	sm2P256ReduceCarry(a, carry)
-	sm2P256ReduceCarry(a2, carry2)
-	// return carry, carry2
-}
-
 func sm2P256DivideByR(a *sm2P256FieldElement, tmp *[10]uint64) (carry uint32) {
 	a[0] = uint32(tmp[4] >> 29)
 	a[0] += uint32(tmp[5]<<28) & bottom29BitsMask
@@ -1283,8 +1177,9 @@ func getBottom28Bits(x *big.Int) uint32 {
 }
 
 // 把a表示成长度为29,28,...,28,29（共9个元素）的数组
-func sm2P256FromBigPlain(X *sm2P256FieldElement, x *big.Int) {
-
+// sm2P256FromBigPlain sets X to the limbs of x, which is left unchanged.
+func sm2P256FromBigPlain(X *sm2P256FieldElement, a *big.Int) {
+	x := new(big.Int).Set(a)
 	X[0] = getBottom29Bits(x)
 	x.Rsh(x, 29)
 
@@ -0,0 +1,82 @@
+//go:build !sm2serial
+// +build !sm2serial
+
+package sm2
+
+// The field operations below run two multiplications or squarings at once
+// with the AVX2 routines of avx_amd64.s. The sm2serial build tag replaces
+// them with serial ones, see p256_serial.go.
+
+const sm2P256TwoWay = true
+
+// sm2P256Mul2Way sets c = a1*b1 and c2 = a2*b2.
+func sm2P256Mul2Way(c, a1, b1, c2, a2, b2 *sm2P256FieldElement) {
+	var tmp1, tmp2 sm2P256LargeFieldElement
+
+	_sm2P256Mul2Way1(&tmp1[0], &a1[0], &b1[0], &tmp2[0], &a2[0], &b2[0])
+
+	tmp1[8] = uint64(a1[1]) * uint64(b1[7])
+	tmp1[8] += uint64(a1[3]) * uint64(b1[5])
+	tmp1[8] += uint64(a1[5]) * uint64(b1[3])
+	tmp1[8] += uint64(a1[7]) * uint64(b1[1])
+	tmp1[8] <<= 1
+	tmp1[8] += uint64(a1[0]) * uint64(b1[8])
+	tmp1[8] += uint64(a1[2]) * uint64(b1[6])
+	tmp1[8] += uint64(a1[4]) * uint64(b1[4])
+	tmp1[8] += uint64(a1[6]) * uint64(b1[2])
+	tmp1[8] += uint64(a1[8]) * uint64(b1[0])
+
+	tmp2[8] = uint64(a2[1]) * uint64(b2[7])
+	tmp2[8] += uint64(a2[3]) * uint64(b2[5])
+	tmp2[8] += uint64(a2[5]) * uint64(b2[3])
+	tmp2[8] += uint64(a2[7]) * uint64(b2[1])
+	tmp2[8] <<= 1
+	tmp2[8] += uint64(a2[0]) * uint64(b2[8])
+	tmp2[8] += uint64(a2[2]) * uint64(b2[6])
+	tmp2[8] += uint64(a2[4]) * uint64(b2[4])
+	tmp2[8] += uint64(a2[6]) * uint64(b2[2])
+	tmp2[8] += uint64(a2[8]) * uint64(b2[0])
+
+	_sm2P256Mul2Way2(&tmp1[0], &a1[0], &b1[0], &tmp2[0], &a2[0], &b2[0])
+
+	sm2P256ReduceDegree2Way(c, c2, &tmp1, &tmp2)
+	// sm2P256ReduceDegree(c, &tmp1)
+	// sm2P256ReduceDegree(c2, &tmp2)
+	// return tmp1, tmp2
+}
+
+// sm2P256Square2Way sets b = a² and b2 = a2².
+func sm2P256Square2Way(b, a, b2, a2 *sm2P256FieldElement) {
+	var tmp, tmp2 sm2P256LargeFieldElement
+
+	_sm2P256Square2Way(&tmp[0], &a[0], &tmp2[0], &a2[0])
+
+	sm2P256ReduceDegree2Way(b, b2, &tmp, &tmp2)
+}
+
+// sm2P256ReduceDegree2Way reduces b into a and b2 into a2.
+func sm2P256ReduceDegree2Way(a, a2 *sm2P256FieldElement, b, b2 *sm2P256LargeFieldElement) {
+	var tmp64, tmp642 [10]uint64
+	var carry, carry2 uint32
+
+	// sm2P256FromLargeElement(&tmp64, b)
+	// sm2P256FromLargeElement(&tmp642, b2)
+	// _sm2P256FromLargeElement_2Way((*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrB1)),
+	// 	(*uint64)(unsafe.Pointer(addrTMP2)), (*uint64)(unsafe.Pointer(addrB2)))
+
+	// _reduceDegree_2wayNew((*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrTMP2)))
+
+	// carry_temp := _sm2P256DivideByR_2way((*uint32)(unsafe.Pointer(addrA)), (*uint32)(unsafe.Pointer(addrA2)),
+	// 	(*uint64)(unsafe.Pointer(addrTMP1)), (*uint64)(unsafe.Pointer(addrTMP2)))
+	// carry = sm2P256DivideByR(a, &tmp64)
+	// carry2 = sm2P256DivideByR(a2, &tmp642)
+
+	carry_temp := _sm2ReduceDegree_2way(&a[0], &a2[0], &b[0], &b2[0], &tmp64[0], &tmp642[0])
+	carry = uint32(carry_temp)
+	carry2 = uint32(carry_temp >> 32)
+
+	// fmt.Println(carry_temp, carry, carry2)
+	sm2P256ReduceCarry(a, carry)
+	sm2P256ReduceCarry(a2, carry2)
+	// return carry, carry2
+}
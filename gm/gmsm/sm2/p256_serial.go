@@ -0,0 +1,27 @@
+//go:build !amd64 || sm2serial
+// +build !amd64 sm2serial
+
+package sm2
+
+// The field operations below compute their two results one after the other,
+// on platforms without the AVX2 routines or with the sm2serial build tag.
+
+const sm2P256TwoWay = false
+
+// sm2P256Mul2Way sets c = a1*b1 and c2 = a2*b2.
+func sm2P256Mul2Way(c, a1, b1, c2, a2, b2 *sm2P256FieldElement) {
+	sm2P256Mul(c, a1, b1)
+	sm2P256Mul(c2, a2, b2)
+}
+
+// sm2P256Square2Way sets b = a² and b2 = a2².
+func sm2P256Square2Way(b, a, b2, a2 *sm2P256FieldElement) {
+	sm2P256Square(b, a)
+	sm2P256Square(b2, a2)
+}
+
+// sm2P256ReduceDegree2Way reduces b into a and b2 into a2.
+func sm2P256ReduceDegree2Way(a, a2 *sm2P256FieldElement, b, b2 *sm2P256LargeFieldElement) {
+	sm2P256ReduceDegree(a, b)
+	sm2P256ReduceDegree(a2, b2)
+}
@@ -21,7 +21,6 @@ import (
 	"crypto/elliptic"
 	"crypto/hmac"
 	"crypto/md5"
-	"crypto/rand"
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
@@ -30,10 +29,13 @@ import (
 	"encoding/pem"
 	"errors"
 	"hash"
+	"io"
 	"io/ioutil"
 	"math/big"
 	"os"
 	"reflect"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
 )
 
 /*
@@ -300,7 +302,9 @@ func MarshalSm2UnecryptedPrivateKey(key *PrivateKey) ([]byte, error) {
 	r.Version = 0
 	r.Algo = algo
 	r.PrivateKey, _ = asn1.Marshal(priv)
-	return asn1.Marshal(r)
+	der, err := asn1.Marshal(r)
+	record(audit.OpExportKey, &key.PublicKey, err)
+	return der, err
 }
 
 func MarshalSm2EcryptedPrivateKey(PrivKey *PrivateKey, pwd []byte) ([]byte, error) {
@@ -311,8 +315,12 @@ func MarshalSm2EcryptedPrivateKey(PrivKey *PrivateKey, pwd []byte) ([]byte, erro
 	iter := 2048
 	salt := make([]byte, 8)
 	iv := make([]byte, 16)
-	rand.Reader.Read(salt)
-	rand.Reader.Read(iv)
+	if _, err := io.ReadFull(RandSource(), salt); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(RandSource(), iv); err != nil {
+		return nil, err
+	}
 	key := pbkdf(pwd, salt, iter, 32, sha1.New) // 默认是SHA1
 	padding := aes.BlockSize - len(der)%aes.BlockSize
 	if padding > 0 {
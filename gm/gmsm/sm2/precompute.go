@@ -0,0 +1,73 @@
+package sm2
+
+import (
+	"math/big"
+)
+
+// sm2P256CombSize is the number of limbs of a comb table: a zero entry, then
+// 15 affine points for the bits at 64k and 15 for those at 64k+32.
+const sm2P256CombSize = 18 + 2*15*18
+
+// sm2P256KeyTable is the comb table of a public key. It keeps the
+// coordinates it was built for, so that a key modified afterwards falls back
+// to the generic scalar multiplication instead of using a stale table.
+type sm2P256KeyTable struct {
+	x, y  *big.Int
+	table [sm2P256CombSize]uint32
+}
+
+// Precompute builds a table of multiples of pub, about 2 KB, with which
+// Verify, Sm2Verify and the functions built on them compute (r+s)·pub as
+// ScalarBaseMult computes s·G, for validators that verify many signatures
+// of a few keys. It takes a few milliseconds and returns an error if pub is
+// not a point of the SM2 curve.
+//
+// Precompute may be called concurrently with the use of pub, and more than
+// once; the table is only used while X and Y keep the values it was built
+// for.
+func (pub *PublicKey) Precompute() error {
+	if err := pub.Validate(); err != nil {
+		return err
+	}
+	if _, ok := pub.Curve.(sm2P256Curve); !ok {
+		return ErrInvalidPublicKey
+	}
+	t := &sm2P256KeyTable{x: new(big.Int).Set(pub.X), y: new(big.Int).Set(pub.Y)}
+	sm2P256BuildComb(&t.table, t.x, t.y)
+	pub.precomputed.Store(t)
+	return nil
+}
+
+// combTable returns the comb table of pub, or nil if there is none for its
+// current coordinates.
+func (pub *PublicKey) combTable() []uint32 {
+	t, ok := pub.precomputed.Load().(*sm2P256KeyTable)
+	if !ok || t.x.Cmp(pub.X) != 0 || t.y.Cmp(pub.Y) != 0 {
+		return nil
+	}
+	return t.table[:]
+}
+
+// sm2P256BuildComb fills table with the entries sm2P256ScalarMultComb reads
+// for the point (x, y): entry i of the first half is Σ 2^(64k)·(x, y) over
+// the bits k of i, and of the second half Σ 2^(64k+32)·(x, y).
+func sm2P256BuildComb(table *[sm2P256CombSize]uint32, x, y *big.Int) {
+	c := P256Sm2()
+	for half := uint(0); half < 2; half++ {
+		for i := uint(1); i < 16; i++ {
+			k := new(big.Int)
+			for bit := uint(0); bit < 4; bit++ {
+				if i>>bit&1 == 1 {
+					k.SetBit(k, int(64*bit+32*half), 1)
+				}
+			}
+			px, py := c.ScalarMult(x, y, k.Bytes())
+			var fx, fy sm2P256FieldElement
+			sm2P256FromBig(&fx, px)
+			sm2P256FromBig(&fy, py)
+			off := 270*half + 18*i
+			copy(table[off:off+9], fx[:])
+			copy(table[off+9:off+18], fy[:])
+		}
+	}
+}
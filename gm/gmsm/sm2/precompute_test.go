@@ -0,0 +1,88 @@
+package sm2
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestBuildCombMatchesBaseTable(t *testing.T) {
+	c := P256Sm2().Params()
+	var table [sm2P256CombSize]uint32
+	sm2P256BuildComb(&table, c.Gx, c.Gy)
+	for i := range table {
+		if table[i] != sm2P256Precomputed[i] {
+			t.Fatalf("limb %d: %#x, want %#x", i, table[i], sm2P256Precomputed[i])
+		}
+	}
+}
+
+func TestPrecompute(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &PublicKey{Curve: priv.Curve, X: priv.X, Y: priv.Y}
+	if err := pub.Precompute(); err != nil {
+		t.Fatal(err)
+	}
+	if pub.combTable() == nil {
+		t.Fatal("no table after Precompute")
+	}
+	for i := 0; i < 16; i++ {
+		msg := []byte{byte(i)}
+		r, s, err := Sm2Sign(priv, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Sm2Verify(pub, msg, nil, r, s) {
+			t.Fatal("valid signature rejected with the table")
+		}
+		if Sm2Verify(pub, msg, nil, s, r) || Sm2Verify(pub, []byte("other"), nil, r, s) {
+			t.Fatal("invalid signature accepted with the table")
+		}
+	}
+
+	// A key modified after Precompute must not use the stale table.
+	other, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub.X, pub.Y = other.X, other.Y
+	if pub.combTable() != nil {
+		t.Fatal("stale table used")
+	}
+	r, s, err := Sm2Sign(other, []byte("msg"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sm2Verify(pub, []byte("msg"), nil, r, s) {
+		t.Fatal("signature of the new key rejected")
+	}
+
+	bad := &PublicKey{Curve: priv.Curve, X: big.NewInt(1), Y: big.NewInt(1)}
+	if bad.Precompute() == nil {
+		t.Fatal("Precompute accepted a point off the curve")
+	}
+}
+
+func BenchmarkSm2VerifyPrecomputed(b *testing.B) {
+	priv, err := GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := priv.PublicKey.Precompute(); err != nil {
+		b.Fatal(err)
+	}
+	msg := []byte("benchmark")
+	r, s, err := Sm2Sign(priv, msg, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Sm2Verify(&priv.PublicKey, msg, nil, r, s) {
+			b.Fatal("invalid")
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package sm2
+
+import (
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+)
+
+// randHolder wraps the source so that atomic.Value always stores one
+// concrete type.
+type randHolder struct {
+	r io.Reader
+}
+
+var randSource atomic.Value
+
+// SetRandSource sets the source of randomness of GenerateKey, Sm2Sign,
+// Encrypt, the certificate helpers and every other function of the package
+// that does not take one explicitly, and of PrivateKey.Sign when called with
+// a nil rand. Use it to inject a certified DRBG, e.g. drbg.NewCTRDRBG, or a
+// deterministic reader in tests. A nil r restores crypto/rand.Reader.
+//
+// The source is shared by all goroutines, so it must be safe for concurrent
+// use if the package is.
+func SetRandSource(r io.Reader) {
+	if r == nil {
+		r = rand.Reader
+	}
+	randSource.Store(randHolder{r})
+}
+
+// RandSource returns the source set by SetRandSource, or crypto/rand.Reader.
+// Packages built on this one use it as their default source.
+func RandSource() io.Reader {
+	if h, ok := randSource.Load().(randHolder); ok {
+		return h.r
+	}
+	return rand.Reader
+}
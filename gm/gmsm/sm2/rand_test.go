@@ -0,0 +1,90 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+// countingReader is a deterministic source of randomness.
+type countingReader struct {
+	n byte
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.n
+		r.n++
+	}
+	return len(p), nil
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) { return 0, errors.New("no entropy") }
+
+func TestSetRandSource(t *testing.T) {
+	defer SetRandSource(nil)
+	msg := []byte("message")
+
+	run := func() (*PrivateKey, []byte, []byte, []byte) {
+		SetRandSource(&countingReader{})
+		priv, err := GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, s, err := Sm2Sign(priv, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := priv.Sign(nil, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, err := Encrypt(&priv.PublicKey, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return priv, append(r.Bytes(), s.Bytes()...), sig, ct
+	}
+	priv1, sm2Sig1, sig1, ct1 := run()
+	priv2, sm2Sig2, sig2, ct2 := run()
+	if priv1.D.Cmp(priv2.D) != 0 {
+		t.Fatal("GenerateKey ignores the random source")
+	}
+	if !bytes.Equal(sm2Sig1, sm2Sig2) {
+		t.Fatal("Sm2Sign ignores the random source")
+	}
+	if !bytes.Equal(sig1, sig2) {
+		t.Fatal("PrivateKey.Sign ignores the random source")
+	}
+	if !bytes.Equal(ct1, ct2) {
+		t.Fatal("Encrypt ignores the random source")
+	}
+
+	// An explicit source takes precedence over the package one.
+	sig3, err := priv1.Sign(rand.Reader, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sig1, sig3) {
+		t.Fatal("PrivateKey.Sign ignores its rand argument")
+	}
+
+	SetRandSource(failingReader{})
+	if _, err := GenerateKey(); err == nil {
+		t.Fatal("expected GenerateKey to fail without entropy")
+	}
+	if _, _, err := Sm2Sign(priv1, msg, nil); err == nil {
+		t.Fatal("expected Sm2Sign to fail without entropy")
+	}
+	if _, err := Encrypt(&priv1.PublicKey, msg); err == nil {
+		t.Fatal("expected Encrypt to fail without entropy")
+	}
+
+	SetRandSource(nil)
+	if RandSource() != rand.Reader {
+		t.Fatal("SetRandSource(nil) does not restore crypto/rand")
+	}
+}
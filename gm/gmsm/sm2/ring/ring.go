@@ -0,0 +1,239 @@
+// Package ring implements linkable ring signatures (LSAG) over the SM2 curve.
+//
+// A ring signature proves that the signer holds the private key of one of a
+// set of SM2 public keys, the ring, without revealing which one. Every
+// signature carries a linkability tag I = x·Hp(scope, P) for the signer's
+// key pair (x, P), where Hp hashes onto the curve. The tag is the same for
+// all signatures by a key under the same scope, whatever the ring and
+// message, and reveals nothing else about the key, so Linked detects
+// double-signing, such as a second vote in the same election, while keeping
+// the signer anonymous.
+//
+// The scheme is the LSAG of Liu, Wei and Wong with the tag base of Monero:
+// with challenges c_i and responses s_i,
+//
+//	L_i = s_i·G + c_i·P_i
+//	R_i = s_i·Hp(scope, P_i) + c_i·I
+//	c_(i+1) = H(ring, scope, I, m, L_i, R_i)
+//
+// and the signature I || c_0 || s_0 || ... || s_(n-1) verifies if the
+// challenges close the ring. SM3 is the hash function and points are in
+// compressed SEC 1 form.
+package ring
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// MaxRingSize is the largest ring accepted by Sign, Verify and Parse.
+const MaxRingSize = 1 << 12
+
+var (
+	errRingSize   = errors.New("ring: invalid ring size")
+	errInvalidKey = errors.New("ring: invalid public key")
+	errNotMember  = errors.New("ring: private key is not a member of the ring")
+	errSignature  = errors.New("ring: malformed signature")
+)
+
+func writeBytes(h io.Writer, b []byte) {
+	var l [4]byte
+	binary.BigEndian.PutUint32(l[:], uint32(len(b)))
+	h.Write(l[:])
+	h.Write(b)
+}
+
+// hashToPoint returns Hp(scope, pub) by try-and-increment, so that nobody
+// knows its discrete logarithm.
+func hashToPoint(scope, pub []byte) *ecpoint.Point {
+	for ctr := uint32(0); ; ctr++ {
+		h := sm3.New()
+		h.Write([]byte("LSAG/keyimage"))
+		writeBytes(h, scope)
+		h.Write(pub)
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], ctr)
+		h.Write(b[:])
+		if p := ecpoint.LiftX(new(big.Int).SetBytes(h.Sum(nil)), 0); p != nil {
+			return p
+		}
+	}
+}
+
+// ringKeys checks the ring and returns the compressed encodings and points
+// of its keys.
+func ringKeys(ring []*sm2.PublicKey) ([][]byte, []*ecpoint.Point, error) {
+	if len(ring) == 0 || len(ring) > MaxRingSize {
+		return nil, nil, errRingSize
+	}
+	enc := make([][]byte, len(ring))
+	pts := make([]*ecpoint.Point, len(ring))
+	for i, k := range ring {
+		p, ok := ecpoint.FromPublicKey(k)
+		if !ok {
+			return nil, nil, errInvalidKey
+		}
+		pts[i] = p
+		enc[i] = pts[i].Compress()
+	}
+	return enc, pts, nil
+}
+
+// challenger computes the challenges of a signature, H(prefix, L, R) with a
+// prefix common to all members.
+type challenger []byte
+
+func newChallenger(keys [][]byte, scope, tag, msg []byte) challenger {
+	h := sm3.New()
+	h.Write([]byte("LSAG/challenge"))
+	for _, k := range keys {
+		h.Write(k)
+	}
+	writeBytes(h, scope)
+	h.Write(tag)
+	writeBytes(h, msg)
+	return h.Sum(nil)
+}
+
+func (c challenger) next(l, r *ecpoint.Point) *big.Int {
+	h := sm3.New()
+	h.Write(c)
+	h.Write(l.Compress())
+	h.Write(r.Compress())
+	e := new(big.Int).SetBytes(h.Sum(nil))
+	return e.Mod(e, ecpoint.Order())
+}
+
+// Signature is a linkable ring signature.
+type Signature struct {
+	// Tag is the linkability tag in compressed form.
+	Tag []byte
+	C   *big.Int
+	S   []*big.Int
+}
+
+// Sign signs msg with priv as an anonymous member of ring, which must
+// contain the public key of priv. Signatures by the same key under the same
+// scope are linked.
+func Sign(rand io.Reader, priv *sm2.PrivateKey, ring []*sm2.PublicKey, scope, msg []byte) (*Signature, error) {
+	keys, pts, err := ringKeys(ring)
+	if err != nil {
+		return nil, err
+	}
+	n := ecpoint.Order()
+	if priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(n) >= 0 {
+		return nil, errInvalidKey
+	}
+	pub := ecpoint.BaseMul(priv.D)
+	self := -1
+	for i, p := range pts {
+		if p.X.Cmp(pub.X) == 0 && p.Y.Cmp(pub.Y) == 0 {
+			self = i
+			break
+		}
+	}
+	if self < 0 {
+		return nil, errNotMember
+	}
+
+	hp := hashToPoint(scope, keys[self])
+	tag := hp.Mul(priv.D)
+	sig := &Signature{Tag: tag.Compress(), S: make([]*big.Int, len(ring))}
+	ch := newChallenger(keys, scope, sig.Tag, msg)
+
+	alpha, err := ecpoint.RandScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	c := ch.next(ecpoint.BaseMul(alpha), hp.Mul(alpha))
+	for j := 1; j < len(ring); j++ {
+		i := (self + j) % len(ring)
+		if i == 0 {
+			sig.C = c
+		}
+		if sig.S[i], err = ecpoint.RandScalar(rand); err != nil {
+			return nil, err
+		}
+		l := ecpoint.BaseMul(sig.S[i]).Add(pts[i].Mul(c))
+		r := hashToPoint(scope, keys[i]).Mul(sig.S[i]).Add(tag.Mul(c))
+		c = ch.next(l, r)
+	}
+	if self == 0 {
+		sig.C = c
+	}
+	// s = α - c·x
+	s := new(big.Int).Mul(c, priv.D)
+	s.Sub(alpha, s)
+	sig.S[self] = s.Mod(s, n)
+	return sig, nil
+}
+
+// Verify reports whether sig is a valid signature of msg under scope by a
+// member of ring.
+func Verify(ring []*sm2.PublicKey, scope, msg []byte, sig *Signature) bool {
+	keys, pts, err := ringKeys(ring)
+	if err != nil || sig == nil || len(sig.S) != len(ring) {
+		return false
+	}
+	n := ecpoint.Order()
+	if sig.C == nil || sig.C.Sign() < 0 || sig.C.Cmp(n) >= 0 {
+		return false
+	}
+	tag, err := ecpoint.Decompress(sig.Tag, false)
+	if err != nil {
+		return false
+	}
+	ch := newChallenger(keys, scope, sig.Tag, msg)
+	c := sig.C
+	for i, s := range sig.S {
+		if s == nil || s.Sign() < 0 || s.Cmp(n) >= 0 {
+			return false
+		}
+		l := ecpoint.BaseMul(s).Add(pts[i].Mul(c))
+		r := hashToPoint(scope, keys[i]).Mul(s).Add(tag.Mul(c))
+		c = ch.next(l, r)
+	}
+	return c.Cmp(sig.C) == 0
+}
+
+// Linked reports whether two valid signatures under the same scope were made
+// with the same private key.
+func Linked(a, b *Signature) bool {
+	return string(a.Tag) == string(b.Tag)
+}
+
+// Marshal returns the encoding Tag || C || S[0] || ... || S[n-1] of the
+// signature, 65 + 32n bytes for a ring of n keys.
+func (sig *Signature) Marshal() []byte {
+	out := make([]byte, 0, 65+32*len(sig.S))
+	out = append(out, sig.Tag...)
+	out = append(out, ecpoint.ScalarBytes(sig.C)...)
+	for _, s := range sig.S {
+		out = append(out, ecpoint.ScalarBytes(s)...)
+	}
+	return out
+}
+
+// Parse parses a signature encoded by Marshal.
+func Parse(b []byte) (*Signature, error) {
+	if len(b) < 65+32 || (len(b)-65)%32 != 0 || (len(b)-65)/32 > MaxRingSize {
+		return nil, errSignature
+	}
+	if _, err := ecpoint.Decompress(b[:33], false); err != nil {
+		return nil, errSignature
+	}
+	sig := &Signature{
+		Tag: append([]byte(nil), b[:33]...),
+		C:   new(big.Int).SetBytes(b[33:65]),
+	}
+	for b = b[65:]; len(b) > 0; b = b[32:] {
+		sig.S = append(sig.S, new(big.Int).SetBytes(b[:32]))
+	}
+	return sig, nil
+}
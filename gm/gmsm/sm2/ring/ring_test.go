@@ -0,0 +1,116 @@
+package ring
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func newRing(t testing.TB, n int) ([]*sm2.PrivateKey, []*sm2.PublicKey) {
+	privs := make([]*sm2.PrivateKey, n)
+	pubs := make([]*sm2.PublicKey, n)
+	for i := range privs {
+		priv, err := sm2.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs[i], pubs[i] = priv, &priv.PublicKey
+	}
+	return privs, pubs
+}
+
+func TestSignVerify(t *testing.T) {
+	privs, ring := newRing(t, 5)
+	scope := []byte("election 2026")
+	msg := []byte("ballot")
+	for i, priv := range privs {
+		sig, err := Sign(rand.Reader, priv, ring, scope, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(ring, scope, msg, sig) {
+			t.Fatalf("signature by member %d does not verify", i)
+		}
+		if Verify(ring, scope, []byte("other ballot"), sig) {
+			t.Fatal("signature verifies for another message")
+		}
+		if Verify(ring, []byte("other scope"), msg, sig) {
+			t.Fatal("signature verifies under another scope")
+		}
+		if Verify(ring[:4], scope, msg, &Signature{sig.Tag, sig.C, sig.S[:4]}) {
+			t.Fatal("signature verifies for another ring")
+		}
+
+		parsed, err := Parse(sig.Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(ring, scope, msg, parsed) {
+			t.Fatal("parsed signature does not verify")
+		}
+	}
+
+	// A ring of one is an ordinary signature.
+	sig, err := Sign(rand.Reader, privs[0], ring[:1], scope, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(ring[:1], scope, msg, sig) {
+		t.Fatal("ring of one does not verify")
+	}
+}
+
+func TestLinked(t *testing.T) {
+	privs, ring := newRing(t, 4)
+	scope := []byte("election 2026")
+
+	a, _ := Sign(rand.Reader, privs[1], ring, scope, []byte("yes"))
+	b, _ := Sign(rand.Reader, privs[1], ring[:3], scope, []byte("no"))
+	c, _ := Sign(rand.Reader, privs[2], ring, scope, []byte("yes"))
+	d, _ := Sign(rand.Reader, privs[1], ring, []byte("election 2027"), []byte("yes"))
+	if !Linked(a, b) {
+		t.Fatal("signatures by the same key are not linked")
+	}
+	if Linked(a, c) {
+		t.Fatal("signatures by different keys are linked")
+	}
+	if Linked(a, d) {
+		t.Fatal("signatures under different scopes are linked")
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	privs, ring := newRing(t, 3)
+	outsider, _ := sm2.GenerateKey()
+	if _, err := Sign(rand.Reader, outsider, ring, nil, nil); err != errNotMember {
+		t.Fatalf("got %v, want errNotMember", err)
+	}
+	if _, err := Sign(rand.Reader, privs[0], nil, nil, nil); err != errRingSize {
+		t.Fatalf("got %v, want errRingSize", err)
+	}
+	bad := append([]*sm2.PublicKey{{Curve: ring[0].Curve, X: ring[0].X, Y: ring[1].Y}}, ring...)
+	if _, err := Sign(rand.Reader, privs[0], bad, nil, nil); err != errInvalidKey {
+		t.Fatalf("got %v, want errInvalidKey", err)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	privs, ring := newRing(t, 2)
+	sig, _ := Sign(rand.Reader, privs[0], ring, nil, []byte("msg"))
+	b := sig.Marshal()
+	for _, m := range [][]byte{nil, b[:65], b[:len(b)-1], append(make([]byte, 33), b[33:]...)} {
+		if _, err := Parse(m); err == nil {
+			t.Fatalf("accepted malformed signature of %d bytes", len(m))
+		}
+	}
+}
+
+func BenchmarkVerify16(b *testing.B) {
+	privs, ring := newRing(b, 16)
+	sig, _ := Sign(rand.Reader, privs[7], ring, nil, []byte("msg"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(ring, nil, []byte("msg"), sig)
+	}
+}
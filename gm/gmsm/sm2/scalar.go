@@ -0,0 +1,364 @@
+package sm2
+
+import (
+	"io"
+	"math/big"
+	"math/bits"
+)
+
+// sm2Scalar is a 256-bit integer as four little-endian 64-bit words. The
+// verification path keeps r, s, e and t in it, with the arithmetic mod n
+// below, rather than in big.Ints, which it used to allocate by the dozen.
+// Signing uses it for k, d, r and s, for which the arithmetic runs in
+// constant time.
+type sm2Scalar [4]uint64
+
+var (
+	// sm2ScalarN is the order n of the base point.
+	sm2ScalarN = sm2Scalar{0x53bbf40939d54123, 0x7203df6b21c6052b, 0xffffffffffffffff, 0xfffffffeffffffff}
+	// sm2ScalarPMinusN is p - n.
+	sm2ScalarPMinusN = sm2Scalar{0xac440bf6c62abedc, 0x8dfc2093de39fad5, 0, 0}
+	// sm2ScalarNMinus2 is n - 2, the exponent of inversion mod n.
+	sm2ScalarNMinus2 = sm2Scalar{0x53bbf40939d54121, 0x7203df6b21c6052b, 0xffffffffffffffff, 0xfffffffeffffffff}
+	// sm2ScalarR is 2^256 mod n, 1 in the Montgomery domain of montMul.
+	sm2ScalarR = sm2Scalar{0xac440bf6c62abedd, 0x8dfc2094de39fad4, 0, 0x0000000100000000}
+	// sm2ScalarRR is 2^512 mod n, by which montMul takes a value into the
+	// Montgomery domain.
+	sm2ScalarRR = sm2Scalar{0x901192af7c114f20, 0x3464504ade6fa2fa, 0x620fc84c3affe0d4, 0x1eb5e412a22b3d3b}
+	// sm2P256PMinus2 is p - 2, the exponent of inversion mod p.
+	sm2P256PMinus2 = sm2Scalar{0xfffffffffffffffd, 0xffffffff00000000, 0xffffffffffffffff, 0xfffffffeffffffff}
+)
+
+// sm2ScalarNInv is -n⁻¹ mod 2^64.
+const sm2ScalarNInv = 0x327f9e8872350975
+
+// sm2P256RR is R² mod p, with R = 2^257, in plain limbs, by which
+// sm2P256Mul takes a value into the Montgomery domain.
+var sm2P256RR sm2P256FieldElement
+
+func initSm2P256RR() {
+	rr := new(big.Int).Lsh(big.NewInt(1), 2*257)
+	sm2P256FromBigPlain(&sm2P256RR, rr.Mod(rr, sm2P256.P))
+}
+
+// setBig sets z to x and reports whether x fits, i.e. 0 ≤ x < 2^256. It
+// does not allocate.
+func (z *sm2Scalar) setBig(x *big.Int) bool {
+	if x.Sign() < 0 || x.BitLen() > 256 {
+		return false
+	}
+	*z = sm2Scalar{}
+	for i, w := range x.Bits() {
+		// big.Word is 32 bits on some platforms.
+		if bitsPerWord := 32 << (^uint(0) >> 63); bitsPerWord == 64 {
+			z[i] = uint64(w)
+		} else {
+			z[i/2] |= uint64(w) << (32 * uint(i%2))
+		}
+	}
+	return true
+}
+
+// setBytes sets z to the big-endian b of at most 32 bytes.
+func (z *sm2Scalar) setBytes(b []byte) {
+	*z = sm2Scalar{}
+	for i, c := range b {
+		j := uint(len(b) - 1 - i)
+		z[j/8] |= uint64(c) << (8 * (j % 8))
+	}
+}
+
+func (z *sm2Scalar) isZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+// sub sets z = a - b and returns the borrow.
+func (z *sm2Scalar) sub(a, b *sm2Scalar) uint64 {
+	var borrow uint64
+	z[0], borrow = bits.Sub64(a[0], b[0], 0)
+	z[1], borrow = bits.Sub64(a[1], b[1], borrow)
+	z[2], borrow = bits.Sub64(a[2], b[2], borrow)
+	z[3], borrow = bits.Sub64(a[3], b[3], borrow)
+	return borrow
+}
+
+// add sets z = a + b and returns the carry.
+func (z *sm2Scalar) add(a, b *sm2Scalar) uint64 {
+	var carry uint64
+	z[0], carry = bits.Add64(a[0], b[0], 0)
+	z[1], carry = bits.Add64(a[1], b[1], carry)
+	z[2], carry = bits.Add64(a[2], b[2], carry)
+	z[3], carry = bits.Add64(a[3], b[3], carry)
+	return carry
+}
+
+// less reports whether z < b.
+func (z *sm2Scalar) less(b *sm2Scalar) bool {
+	var t sm2Scalar
+	return t.sub(z, b) == 1
+}
+
+// choose sets z = a if v is 1 and z = b if v is 0, in constant time.
+func (z *sm2Scalar) choose(v uint64, a, b *sm2Scalar) {
+	mask := -v
+	for i := range z {
+		z[i] = b[i] ^ (mask & (a[i] ^ b[i]))
+	}
+}
+
+// reduce reduces z < 2^256 mod n, for which one subtraction of n suffices.
+func (z *sm2Scalar) reduce() {
+	var t sm2Scalar
+	borrow := t.sub(z, &sm2ScalarN)
+	z.choose(borrow^1, &t, z)
+}
+
+// addMod sets z = a + b mod n for a, b < n.
+func (z *sm2Scalar) addMod(a, b *sm2Scalar) {
+	var sum, t sm2Scalar
+	carry := sum.add(a, b)
+	borrow := t.sub(&sum, &sm2ScalarN)
+	z.choose(carry|(borrow^1), &t, &sum)
+}
+
+// subMod sets z = a - b mod n for a, b < n.
+func (z *sm2Scalar) subMod(a, b *sm2Scalar) {
+	var n sm2Scalar
+	borrow := z.sub(a, b)
+	n.choose(borrow, &sm2ScalarN, &n)
+	z.add(z, &n)
+}
+
+// montMul sets z = a·b·2^-256 mod n for a, b < n, by Montgomery
+// multiplication.
+func (z *sm2Scalar) montMul(a, b *sm2Scalar) {
+	var t [6]uint64
+	for i := 0; i < 4; i++ {
+		var carry, c uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[j], b[i])
+			lo, c = bits.Add64(lo, t[j], 0)
+			hi += c
+			t[j], c = bits.Add64(lo, carry, 0)
+			carry = hi + c
+		}
+		t[4], c = bits.Add64(t[4], carry, 0)
+		t[5] = c
+
+		m := t[0] * sm2ScalarNInv
+		hi, lo := bits.Mul64(m, sm2ScalarN[0])
+		_, c = bits.Add64(lo, t[0], 0)
+		carry = hi + c
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(m, sm2ScalarN[j])
+			lo, c = bits.Add64(lo, t[j], 0)
+			hi += c
+			t[j-1], c = bits.Add64(lo, carry, 0)
+			carry = hi + c
+		}
+		t[3], c = bits.Add64(t[4], carry, 0)
+		t[4] = t[5] + c
+	}
+	res := sm2Scalar{t[0], t[1], t[2], t[3]}
+	var u sm2Scalar
+	borrow := u.sub(&res, &sm2ScalarN)
+	z.choose(t[4]|(borrow^1), &u, &res)
+}
+
+// mulMod sets z = a·b mod n for a, b < n.
+func (z *sm2Scalar) mulMod(a, b *sm2Scalar) {
+	var t sm2Scalar
+	t.montMul(a, b)
+	z.montMul(&t, &sm2ScalarRR)
+}
+
+// invert sets z = a⁻¹ mod n for 0 < a < n, as a^(n-2) by Fermat's little
+// theorem. The exponent is public, so the time taken does not depend on a.
+// invert(0) is 0.
+func (z *sm2Scalar) invert(a *sm2Scalar) {
+	var x, acc sm2Scalar
+	x.montMul(a, &sm2ScalarRR)
+	acc = sm2ScalarR
+	for i := 255; i >= 0; i-- {
+		acc.montMul(&acc, &acc)
+		if sm2ScalarNMinus2[i/64]>>uint(i%64)&1 == 1 {
+			acc.montMul(&acc, &x)
+		}
+	}
+	z.montMul(&acc, &sm2Scalar{1})
+	x = sm2Scalar{}
+}
+
+// sm2P256Invert sets out = a⁻¹ mod p, as a^(p-2), in time independent of
+// a, unlike the big.Int ModInverse of sm2P256PointToAffine.
+func sm2P256Invert(out, a *sm2P256FieldElement) {
+	acc := sm2P256Factor[1]
+	for i := 255; i >= 0; i-- {
+		sm2P256Square(&acc, &acc)
+		if sm2P256PMinus2[i/64]>>uint(i%64)&1 == 1 {
+			sm2P256Mul(&acc, &acc, a)
+		}
+	}
+	*out = acc
+}
+
+// littleEndian returns z as the little-endian scalar of
+// sm2P256ScalarBaseMult and sm2P256ScalarMult.
+func (z *sm2Scalar) littleEndian() (out [32]byte) {
+	for i := range out {
+		out[i] = byte(z[i/8] >> (8 * uint(i%8)))
+	}
+	return
+}
+
+// toField sets out to z mod p in the Montgomery domain.
+func (z *sm2Scalar) toField(out *sm2P256FieldElement) {
+	var plain sm2P256FieldElement
+	for i, off := range sm2P256LimbOffsets {
+		width := uint(29 - i%2)
+		j, s := off/64, off%64
+		v := z[j] >> s
+		if s+width > 64 && j+1 < uint(len(z)) {
+			v |= z[j+1] << (64 - s)
+		}
+		plain[i] = uint32(v) & (1<<width - 1)
+	}
+	sm2P256Mul(out, &plain, &sm2P256RR)
+}
+
+// verifyFast checks e + x1 = r mod n, where (x1, y1) = s·G + (r+s)·pub, for
+// a digest e of at most 32 bytes and a key of the SM2 curve; ok is false if
+// it cannot handle the input, for the caller to fall back to the generic
+// path. It allocates nothing: x1 is compared in Jacobian coordinates, as
+// X = c·Z² for the candidates c ≡ r - e mod n below p, so that no inversion
+// is needed either.
+func verifyFast(pub *PublicKey, digest []byte, r, s *big.Int) (valid, ok bool) {
+	if _, isSM2 := pub.Curve.(sm2P256Curve); !isSM2 || len(digest) > 32 {
+		return false, false
+	}
+	var rr, ss, e, t, px, py sm2Scalar
+	if !px.setBig(pub.X) || !py.setBig(pub.Y) {
+		return false, false
+	}
+	if !rr.setBig(r) || !ss.setBig(s) {
+		return false, true
+	}
+	if rr.isZero() || ss.isZero() || !rr.less(&sm2ScalarN) || !ss.less(&sm2ScalarN) {
+		return false, true
+	}
+	t.addMod(&rr, &ss)
+	if t.isZero() {
+		return false, true
+	}
+	e.setBytes(digest)
+	e.reduce()
+
+	var p1, p2, sum sm2P256JacobianPoint
+	var x, y sm2P256FieldElement
+	sLE, tLE := ss.littleEndian(), t.littleEndian()
+	sm2P256ScalarBaseMult(&p1.x, &p1.y, &p1.z, &sLE)
+	if table := pub.combTable(); table != nil {
+		sm2P256ScalarMultComb(&p2.x, &p2.y, &p2.z, table, &tLE)
+	} else {
+		px.toField(&x)
+		py.toField(&y)
+		sm2P256ScalarMult(&p2.x, &p2.y, &p2.z, &x, &y, &tLE)
+	}
+	sum.add(&p1, &p2)
+	if sum.inf {
+		return false, true
+	}
+
+	// x1 = X/Z² < p, so x1 ≡ r - e mod n means x1 = c or x1 = c + n.
+	var c sm2Scalar
+	c.subMod(&rr, &e)
+	var zz, cf, diff sm2P256FieldElement
+	sm2P256Square(&zz, &sum.z)
+	for {
+		c.toField(&cf)
+		sm2P256Mul(&cf, &cf, &zz)
+		sm2P256Sub(&diff, &sum.x, &cf)
+		if sm2P256IsZero(&diff) {
+			return true, true
+		}
+		if !c.less(&sm2ScalarPMinusN) {
+			return false, true
+		}
+		c.add(&c, &sm2ScalarN)
+	}
+}
+
+// bytes returns z as 32 big-endian bytes.
+func (z *sm2Scalar) bytes() (out [32]byte) {
+	for i := range out {
+		out[31-i] = byte(z[i/8] >> (8 * uint(i%8)))
+	}
+	return
+}
+
+// bigInt returns z as a new big.Int.
+func (z *sm2Scalar) bigInt() *big.Int {
+	b := z.bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// sm2P256BaseMultAffine returns the affine k·G for 0 < k < n, with no
+// arithmetic on k or on the Jacobian point whose time depends on them.
+func sm2P256BaseMultAffine(k *sm2Scalar) (x, y *big.Int) {
+	var xj, yj, zj, zInv, zz, xa, ya sm2P256FieldElement
+	kLE := k.littleEndian()
+	sm2P256ScalarBaseMult(&xj, &yj, &zj, &kLE)
+	zeroize(kLE[:])
+	sm2P256Invert(&zInv, &zj)
+	sm2P256Square(&zz, &zInv)
+	sm2P256Mul(&xa, &xj, &zz)
+	sm2P256Mul(&zz, &zz, &zInv)
+	sm2P256Mul(&ya, &yj, &zz)
+	return sm2P256ToBig(&xa), sm2P256ToBig(&ya)
+}
+
+// signFast signs the digest e with the constant-time arithmetic of
+// sm2Scalar: r = e + x1 mod n, with (x1, y1) = k·G, and
+// s = (1+d)⁻¹·(k - r·d) mod n. It draws k as sm2Sign does, so the two
+// produce the same signature from the same random stream.
+func signFast(priv *PrivateKey, digest []byte, rand io.Reader) (r, s *big.Int, parity uint, err error) {
+	var d, d1, d1Inv, k, e, rr, ss, t sm2Scalar
+	defer func() {
+		d, d1, d1Inv, k, ss = sm2Scalar{}, sm2Scalar{}, sm2Scalar{}, sm2Scalar{}, sm2Scalar{}
+	}()
+	if !d.setBig(priv.D) {
+		return nil, nil, 0, ErrInvalidPrivateKey
+	}
+	d.reduce()
+	d1.addMod(&d, &sm2Scalar{1})
+	if d1.isZero() {
+		return nil, nil, 0, ErrInvalidPrivateKey
+	}
+	d1Inv.invert(&d1)
+	e.setBytes(digest)
+	e.reduce()
+	for {
+		kInt, err := randFieldElement(priv.Curve, rand)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		k.setBig(kInt)
+		zeroizeInt(kInt)
+		x1, y1 := sm2P256BaseMultAffine(&k)
+		var x sm2Scalar
+		x.setBig(x1)
+		x.reduce()
+		rr.addMod(&e, &x)
+		t.addMod(&rr, &k)
+		if rr.isZero() || t.isZero() {
+			continue
+		}
+		ss.mulMod(&rr, &d)
+		ss.subMod(&k, &ss)
+		ss.mulMod(&ss, &d1Inv)
+		if ss.isZero() {
+			continue
+		}
+		return rr.bigInt(), ss.bigInt(), y1.Bit(0), nil
+	}
+}
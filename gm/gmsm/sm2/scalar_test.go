@@ -0,0 +1,244 @@
+package sm2
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestScalarArithmetic(t *testing.T) {
+	n := P256Sm2().Params().N
+	p := P256Sm2().Params().P
+	for i := 0; i < 500; i++ {
+		a, _ := rand.Int(rand.Reader, n)
+		b, _ := rand.Int(rand.Reader, n)
+		var sa, sb, z sm2Scalar
+		if !sa.setBig(a) || !sb.setBig(b) {
+			t.Fatal("setBig failed")
+		}
+		z.setBytes(a.Bytes())
+		if z != sa {
+			t.Fatal("setBytes and setBig disagree")
+		}
+		z.addMod(&sa, &sb)
+		want := new(big.Int).Add(a, b)
+		if want.Mod(want, n); !scalarEquals(&z, want) {
+			t.Fatalf("%x + %x", a, b)
+		}
+		z.subMod(&sa, &sb)
+		want.Sub(a, b)
+		if want.Mod(want, n); !scalarEquals(&z, want) {
+			t.Fatalf("%x - %x", a, b)
+		}
+		if sa.less(&sb) != (a.Cmp(b) < 0) {
+			t.Fatal("less")
+		}
+
+		// 2^256 - 1 - a exceeds n for about half of the a.
+		big256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+		x := new(big.Int).Sub(big256, a)
+		z.setBig(x)
+		z.reduce()
+		if x.Mod(x, n); !scalarEquals(&z, x) {
+			t.Fatal("reduce")
+		}
+
+		c, _ := rand.Int(rand.Reader, big256)
+		var f sm2P256FieldElement
+		z.setBig(c)
+		z.toField(&f)
+		if got := sm2P256ToBig(&f); got.Cmp(c.Mod(c, p)) != 0 {
+			t.Fatalf("toField: got %x, want %x", got, c)
+		}
+	}
+	var z sm2Scalar
+	if z.setBig(big.NewInt(-1)) || z.setBig(new(big.Int).Lsh(big.NewInt(1), 256)) {
+		t.Fatal("setBig accepts a value out of range")
+	}
+}
+
+func TestScalarMulInvert(t *testing.T) {
+	n := P256Sm2().Params().N
+	p := P256Sm2().Params().P
+	for i := 0; i < 200; i++ {
+		a, _ := rand.Int(rand.Reader, n)
+		b, _ := rand.Int(rand.Reader, n)
+		if i == 0 {
+			a.Sub(n, big.NewInt(1))
+			b.Set(a)
+		}
+		var sa, sb, z sm2Scalar
+		sa.setBig(a)
+		sb.setBig(b)
+		z.mulMod(&sa, &sb)
+		want := new(big.Int).Mul(a, b)
+		if want.Mod(want, n); !scalarEquals(&z, want) {
+			t.Fatalf("%x * %x", a, b)
+		}
+		if a.Sign() == 0 {
+			continue
+		}
+		z.invert(&sa)
+		if want.ModInverse(a, n); !scalarEquals(&z, want) {
+			t.Fatalf("1 / %x", a)
+		}
+		if z.bigInt().Cmp(want) != 0 {
+			t.Fatal("bigInt")
+		}
+
+		c, _ := rand.Int(rand.Reader, p)
+		var f, inv sm2P256FieldElement
+		sm2P256FromBig(&f, c)
+		sm2P256Invert(&inv, &f)
+		if got := sm2P256ToBig(&inv); c.Sign() != 0 && got.Cmp(new(big.Int).ModInverse(c, p)) != 0 {
+			t.Fatalf("1 / %x mod p", c)
+		}
+	}
+}
+
+// TestSignFastMatchesGeneric signs with the same random stream on the fast
+// path and on the big.Int path, which a curve that is not sm2P256Curve
+// takes.
+func TestSignFastMatchesGeneric(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	generic := &PrivateKey{
+		PublicKey: PublicKey{Curve: struct{ elliptic.Curve }{P256Sm2()}, X: priv.X, Y: priv.Y},
+		D:         priv.D,
+	}
+	seed := make([]byte, 40*16)
+	for i := 0; i < 16; i++ {
+		rand.Read(seed)
+		msg := []byte{byte(i)}
+		r1, s1, p1, err := sm2Sign(priv, msg, nil, bytes.NewReader(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r2, s2, p2, err := sm2Sign(generic, msg, nil, bytes.NewReader(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r1.Cmp(r2) != 0 || s1.Cmp(s2) != 0 || p1 != p2 {
+			t.Fatalf("fast (%x, %x, %d), generic (%x, %x, %d)", r1, s1, p1, r2, s2, p2)
+		}
+		if !Sm2Verify(&priv.PublicKey, msg, nil, r1, s1) {
+			t.Fatal("signature does not verify")
+		}
+	}
+
+	bad := &PrivateKey{PublicKey: priv.PublicKey, D: new(big.Int).Sub(P256Sm2().Params().N, big.NewInt(1))}
+	if _, _, _, err := sm2Sign(bad, []byte("m"), nil, rand.Reader); err == nil {
+		t.Fatal("signed with d = n-1")
+	}
+}
+
+func scalarEquals(z *sm2Scalar, x *big.Int) bool {
+	var w sm2Scalar
+	w.setBig(x)
+	return *z == w
+}
+
+// TestVerifyFast checks the fast path against the generic one, with digests
+// of every length, above n and with invalid signatures.
+func TestVerifyFast(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	n := pub.Curve.Params().N
+	for i := 0; i < 64; i++ {
+		digest := make([]byte, i%33)
+		rand.Read(digest)
+		if i%4 == 0 && len(digest) == 32 {
+			// A digest above n.
+			copy(digest, []byte{0xff, 0xff, 0xff, 0xff})
+		}
+		r, s, err := signDigest(priv, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases := []struct{ r, s *big.Int }{
+			{r, s},
+			{new(big.Int).Add(r, big.NewInt(1)), s},
+			{r, new(big.Int).Add(s, n)},
+			{s, r},
+			{r, new(big.Int).Sub(n, r)},
+		}
+		for j, c := range cases {
+			valid, ok := verifyFast(pub, digest, c.r, c.s)
+			if !ok {
+				t.Fatal("fast path declined an SM2 key")
+			}
+			if want := verifyGeneric(pub, digest, c.r, c.s); valid != want {
+				t.Fatalf("digest %x, case %d: fast %v, generic %v", digest, j, valid, want)
+			}
+			if j == 0 && !valid {
+				t.Fatal("valid signature rejected")
+			}
+		}
+	}
+	if _, ok := verifyFast(pub, make([]byte, 33), big.NewInt(1), big.NewInt(1)); ok {
+		t.Fatal("fast path took a digest longer than 32 bytes")
+	}
+}
+
+// signDigest signs digest as e, with no ZA.
+func signDigest(priv *PrivateKey, digest []byte) (r, s *big.Int, err error) {
+	c := priv.Curve
+	n := c.Params().N
+	for {
+		k, err := randFieldElement(c, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		x1, _ := c.ScalarBaseMult(k.Bytes())
+		r = new(big.Int).Add(new(big.Int).SetBytes(digest), x1)
+		r.Mod(r, n)
+		s = new(big.Int).Mul(r, priv.D)
+		s.Sub(k, s)
+		s.Mul(s, new(big.Int).ModInverse(new(big.Int).Add(priv.D, one), n))
+		s.Mod(s, n)
+		if r.Sign() != 0 && s.Sign() != 0 {
+			return r, s, nil
+		}
+	}
+}
+
+func TestVerifyFastAllocs(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := make([]byte, 32)
+	r, s, err := signDigest(priv, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := testing.AllocsPerRun(10, func() { verifyFast(&priv.PublicKey, digest, r, s) }); a != 0 {
+		t.Fatalf("%v allocations", a)
+	}
+}
+
+func BenchmarkSm2Verify(b *testing.B) {
+	priv, err := GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := []byte("benchmark")
+	r, s, err := Sm2Sign(priv, msg, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Sm2Verify(&priv.PublicKey, msg, nil, r, s) {
+			b.Fatal("invalid")
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package sm2
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+)
+
+// SignatureFormat is an encoding of an SM2 signature.
+type SignatureFormat int
+
+const (
+	// FormatDER is the ASN.1 DER encoding of SEQUENCE { r, s } of GB/T
+	// 35276, as produced by Sign and SignEx.
+	FormatDER SignatureFormat = iota
+	// FormatRaw is r || s, each 32 bytes big-endian, as used by PKCS #11
+	// tokens and most hardware.
+	FormatRaw
+	// FormatRecoverable is r || s || v, 65 bytes, where v is 0 or 1, the
+	// parity of the y-coordinate of k·G as returned by Sm2SignWithParity,
+	// with which BatchVerifier batches the signature. SM2 has no public key
+	// recovery as ECDSA has, since e depends on the public key through ZA.
+	FormatRecoverable
+)
+
+const (
+	rawSignatureSize         = 64
+	recoverableSignatureSize = 65
+)
+
+var (
+	errSignatureFormat = errors.New("sm2: unknown signature format")
+	errSignatureRange  = errors.New("sm2: signature values out of range")
+	errSignatureParity = errors.New("sm2: invalid signature parity")
+	errSignatureDER    = errors.New("sm2: malformed or non-canonical DER signature")
+)
+
+// Signature is an SM2 signature (r, s), with the parity V of the y-coordinate
+// of k·G for FormatRecoverable.
+type Signature struct {
+	R, S *big.Int
+	V    uint
+}
+
+// checkRange checks that r and s lie in [1, n-1].
+func (sig *Signature) checkRange() error {
+	n := P256Sm2().Params().N
+	if sig.R == nil || sig.S == nil ||
+		sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Cmp(n) >= 0 {
+		return errSignatureRange
+	}
+	return nil
+}
+
+// Encode returns sig in the given format. r and s must lie in [1, n-1], and
+// V must be 0 or 1 for FormatRecoverable.
+func (sig *Signature) Encode(format SignatureFormat) ([]byte, error) {
+	if err := sig.checkRange(); err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatDER:
+		return asn1.Marshal(sm2Signature{sig.R, sig.S})
+	case FormatRaw, FormatRecoverable:
+		out := make([]byte, rawSignatureSize, recoverableSignatureSize)
+		copy(out[32-len(sig.R.Bytes()):32], sig.R.Bytes())
+		copy(out[64-len(sig.S.Bytes()):], sig.S.Bytes())
+		if format == FormatRaw {
+			return out, nil
+		}
+		if sig.V > 1 {
+			return nil, errSignatureParity
+		}
+		return append(out, byte(sig.V)), nil
+	}
+	return nil, errSignatureFormat
+}
+
+// Decode sets sig from data, whose format is told by its length: 64 bytes
+// is FormatRaw, 65 bytes FormatRecoverable and any other length FormatDER.
+// A DER signature is 64 or 65 bytes long only if r or s is shorter than 28
+// bytes, which happens with probability below 2^-40 for signatures of this
+// package; use DecodeFormat where the format is known.
+func (sig *Signature) Decode(data []byte) error {
+	switch len(data) {
+	case rawSignatureSize:
+		return sig.DecodeFormat(FormatRaw, data)
+	case recoverableSignatureSize:
+		return sig.DecodeFormat(FormatRecoverable, data)
+	}
+	return sig.DecodeFormat(FormatDER, data)
+}
+
+// DecodeFormat sets sig from data in the given format. It is strict: DER
+// must be canonical with no trailing data, the fixed formats must have their
+// exact length with r and s zero-padded to 32 bytes, v must be 0 or 1, and r
+// and s must lie in [1, n-1]. V is set to 0 for the formats without it. On
+// error sig is left unchanged.
+func (sig *Signature) DecodeFormat(format SignatureFormat, data []byte) error {
+	var out Signature
+	switch format {
+	case FormatDER:
+		var rs sm2Signature
+		rest, err := asn1.Unmarshal(data, &rs)
+		if err != nil || len(rest) != 0 {
+			return errSignatureDER
+		}
+		if der, err := asn1.Marshal(rs); err != nil || !bytes.Equal(der, data) {
+			return errSignatureDER
+		}
+		out.R, out.S = rs.R, rs.S
+	case FormatRaw, FormatRecoverable:
+		size := rawSignatureSize
+		if format == FormatRecoverable {
+			size = recoverableSignatureSize
+		}
+		if len(data) != size {
+			return errors.New("sm2: invalid signature length")
+		}
+		out.R = new(big.Int).SetBytes(data[:32])
+		out.S = new(big.Int).SetBytes(data[32:64])
+		if format == FormatRecoverable {
+			if data[64] > 1 {
+				return errSignatureParity
+			}
+			out.V = uint(data[64])
+		}
+	default:
+		return errSignatureFormat
+	}
+	if err := out.checkRange(); err != nil {
+		return err
+	}
+	*sig = out
+	return nil
+}
+
+// EthereumVOffset is the V offset of the [R || S || V] signatures of EVM
+// tooling, whose last byte is 27 or 28.
+const EthereumVOffset = 27
+
+// EncodeRecoverable is Encode(FormatRecoverable) with offset added to V, so
+// that it emits the [R || S || V] wire format of EVM tooling with
+// EthereumVOffset. offset+1 must fit in the V byte; EIP-155 values of large
+// chain IDs do not.
+func (sig *Signature) EncodeRecoverable(offset uint) ([]byte, error) {
+	if offset > 0xfe {
+		return nil, errSignatureParity
+	}
+	out, err := sig.Encode(FormatRecoverable)
+	if err != nil {
+		return nil, err
+	}
+	out[64] += byte(offset)
+	return out, nil
+}
+
+// DecodeRecoverable is DecodeFormat(FormatRecoverable) for a V byte of
+// offset or offset+1, as written by EncodeRecoverable. Any other V is
+// rejected, so a signature of one offset does not parse under another.
+func (sig *Signature) DecodeRecoverable(data []byte, offset uint) error {
+	if offset > 0xfe {
+		return errSignatureParity
+	}
+	if len(data) != recoverableSignatureSize {
+		return errors.New("sm2: invalid signature length")
+	}
+	v := uint(data[64])
+	if v < offset || v > offset+1 {
+		return errSignatureParity
+	}
+	buf := make([]byte, recoverableSignatureSize)
+	copy(buf, data)
+	buf[64] = byte(v - offset)
+	return sig.DecodeFormat(FormatRecoverable, buf)
+}
+
+// Bytes returns the DER encoding of (R, S), as Encode(FormatDER) but
+// without checking their range.
+func (sig *Signature) Bytes() []byte {
+	der, _ := asn1.Marshal(sm2Signature{sig.R, sig.S})
+	return der
+}
+
+// Equal reports whether sig and other have the same R and S. V is not
+// compared, as it follows from R and S for a given message and key.
+func (sig *Signature) Equal(other *Signature) bool {
+	return sig.R != nil && sig.S != nil && other.R != nil && other.S != nil &&
+		sig.R.Cmp(other.R) == 0 && sig.S.Cmp(other.S) == 0
+}
+
+// Normalize returns a copy of sig with R and S reduced mod n, under which
+// the verification equations are unchanged. Unlike ECDSA, SM2 has no low-s
+// form to pick: (r, n-s) is not a signature of the same message.
+func (sig *Signature) Normalize() *Signature {
+	n := P256Sm2().Params().N
+	return &Signature{
+		R: new(big.Int).Mod(sig.R, n),
+		S: new(big.Int).Mod(sig.S, n),
+		V: sig.V,
+	}
+}
+
+// IsCanonical reports whether R and S lie in [1, n-1] with r+s not 0 mod n,
+// the only values Sign produces and Verify accepts.
+func (sig *Signature) IsCanonical() bool {
+	if sig.checkRange() != nil {
+		return false
+	}
+	t := new(big.Int).Add(sig.R, sig.S)
+	return t.Cmp(P256Sm2().Params().N) != 0
+}
+
+// SignSignature is SignEx returning a *Signature, with V set, instead of its
+// DER encoding.
+func SignSignature(priv *PrivateKey, msg []byte, opts ...Option) (*Signature, error) {
+	o := newOptions(opts)
+	sig, err := signSignature(priv, msg, o)
+	record(audit.OpSign, &priv.PublicKey, err)
+	return sig, err
+}
+
+func signSignature(priv *PrivateKey, msg []byte, o *options) (*Signature, error) {
+	if err := o.checkPrivateKey(priv); err != nil {
+		return nil, err
+	}
+	r, s, parity, err := sm2Sign(priv, msg, o.uid, o.rand)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{R: r, S: s, V: parity}, nil
+}
+
+// VerifySignature verifies a signature of SignSignature. It takes the
+// options of VerifyEx; WithCanonicalSig has no effect.
+func VerifySignature(pub *PublicKey, msg []byte, sig *Signature, opts ...Option) bool {
+	o := newOptions(opts)
+	if sig == nil || sig.R == nil || sig.S == nil || o.checkPublicKey(pub) != nil {
+		return false
+	}
+	return Sm2Verify(pub, msg, o.uid, sig.R, sig.S)
+}
@@ -0,0 +1,286 @@
+package sm2
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func TestSignatureFormats(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("transfer 100")
+	r, s, parity, err := Sm2SignWithParity(priv, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &Signature{R: r, S: s, V: parity}
+
+	sizes := map[SignatureFormat]int{FormatRaw: 64, FormatRecoverable: 65}
+	for _, format := range []SignatureFormat{FormatDER, FormatRaw, FormatRecoverable} {
+		data, err := sig.Encode(format)
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if size, ok := sizes[format]; ok && len(data) != size {
+			t.Fatalf("format %d: %d bytes", format, len(data))
+		}
+		var got Signature
+		if err := got.Decode(data); err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if got.R.Cmp(r) != 0 || got.S.Cmp(s) != 0 {
+			t.Fatalf("format %d: round trip changed (r, s)", format)
+		}
+		if format == FormatRecoverable && got.V != parity {
+			t.Fatalf("parity %d, want %d", got.V, parity)
+		}
+		if format != FormatRecoverable && got.V != 0 {
+			t.Fatalf("format %d: V = %d", format, got.V)
+		}
+	}
+
+	der, _ := sig.Encode(FormatDER)
+	if !Sm2Verify(&priv.PublicKey, msg, nil, r, s) || !VerifyEx(&priv.PublicKey, msg, der) {
+		t.Fatal("DER encoding does not verify")
+	}
+	rec, _ := sig.Encode(FormatRecoverable)
+	var dec Signature
+	if err := dec.Decode(rec); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBatchVerifier(nil)
+	b.Add(&priv.PublicKey, msg, nil, dec.R, dec.S, dec.V)
+	if ok, _ := b.Verify(); !ok {
+		t.Fatal("recoverable signature fails batch verification")
+	}
+}
+
+func TestSignatureEncodePadding(t *testing.T) {
+	sig := &Signature{R: big.NewInt(1), S: big.NewInt(0x0102)}
+	raw, err := sig.Encode(FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 64)
+	want[31], want[62], want[63] = 1, 1, 2
+	if !bytes.Equal(raw, want) {
+		t.Fatalf("got %x", raw)
+	}
+}
+
+func TestSignatureDecodeStrict(t *testing.T) {
+	n := P256Sm2().Params().N
+	valid := &Signature{R: big.NewInt(5), S: big.NewInt(7), V: 1}
+	raw, _ := valid.Encode(FormatRaw)
+	rec, _ := valid.Encode(FormatRecoverable)
+	der, _ := valid.Encode(FormatDER)
+
+	withN := append([]byte(nil), raw...)
+	copy(withN[:32], n.Bytes())
+	badParity := append([]byte(nil), rec...)
+	badParity[64] = 2
+	nonMinimal := []byte{0x30, 0x07, 0x02, 0x02, 0x00, 0x05, 0x02, 0x01, 0x07}
+
+	for name, c := range map[string]struct {
+		format SignatureFormat
+		data   []byte
+	}{
+		"raw short":         {FormatRaw, raw[:63]},
+		"raw long":          {FormatRaw, rec},
+		"raw zero r":        {FormatRaw, make([]byte, 64)},
+		"raw r = n":         {FormatRaw, withN},
+		"recoverable short": {FormatRecoverable, raw},
+		"recoverable v = 2": {FormatRecoverable, badParity},
+		"der trailing":      {FormatDER, append(append([]byte(nil), der...), 0)},
+		"der non-minimal":   {FormatDER, nonMinimal},
+		"der garbage":       {FormatDER, []byte{1, 2, 3}},
+		"unknown format":    {SignatureFormat(9), der},
+	} {
+		sig := Signature{R: big.NewInt(42)}
+		if err := sig.DecodeFormat(c.format, c.data); err == nil {
+			t.Errorf("%s: accepted", name)
+		} else if sig.R.Int64() != 42 || sig.S != nil {
+			t.Errorf("%s: signature modified on error", name)
+		}
+	}
+
+	for name, sig := range map[string]*Signature{
+		"nil s":  {R: big.NewInt(1)},
+		"zero r": {R: new(big.Int), S: big.NewInt(1)},
+		"s = n":  {R: big.NewInt(1), S: n},
+	} {
+		if _, err := sig.Encode(FormatRaw); err == nil {
+			t.Errorf("%s: encoded", name)
+		}
+	}
+	if _, err := (&Signature{R: big.NewInt(1), S: big.NewInt(1), V: 2}).Encode(FormatRecoverable); err == nil {
+		t.Error("parity 2 encoded")
+	}
+}
+
+func TestSignatureMethods(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	msg := []byte("transfer 200")
+	sig, err := SignSignature(priv, msg, WithUID([]byte("alice")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sig.IsCanonical() {
+		t.Fatal("fresh signature not canonical")
+	}
+	if !VerifySignature(pub, msg, sig, WithUID([]byte("alice"))) {
+		t.Fatal("signature does not verify")
+	}
+	if VerifySignature(pub, msg, sig) {
+		t.Fatal("signature verifies under DefaultUID")
+	}
+	if VerifySignature(pub, msg, &Signature{R: sig.S, S: sig.R}, WithUID([]byte("alice"))) {
+		t.Fatal("swapped (s, r) verifies")
+	}
+	if !VerifyEx(pub, msg, sig.Bytes(), WithUID([]byte("alice")), WithCanonicalSig()) {
+		t.Fatal("Bytes does not verify")
+	}
+	b := NewBatchVerifier(nil)
+	b.AddSignature(pub, msg, []byte("alice"), sig)
+	if ok, _ := b.Verify(); !ok {
+		t.Fatal("batch rejects signature")
+	}
+
+	n := P256Sm2().Params().N
+	shifted := &Signature{R: new(big.Int).Add(sig.R, n), S: new(big.Int).Add(sig.S, n), V: sig.V}
+	if shifted.IsCanonical() || sig.Equal(shifted) {
+		t.Fatal("unreduced signature canonical or equal")
+	}
+	norm := shifted.Normalize()
+	if !norm.Equal(sig) || norm.V != sig.V || shifted.R.Cmp(sig.R) == 0 {
+		t.Fatal("Normalize did not reduce a copy")
+	}
+	if (&Signature{R: big.NewInt(1), S: new(big.Int).Sub(n, big.NewInt(1))}).IsCanonical() {
+		t.Fatal("r+s = n canonical")
+	}
+	if (&Signature{}).Equal(sig) {
+		t.Fatal("empty signature equal")
+	}
+}
+
+func TestPublicKeyVerifyRaw(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := sm3.Sm3Sum([]byte("raw"))
+	der, err := priv.Sign(nil, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sig Signature
+	if err := sig.DecodeFormat(FormatDER, der); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := sig.Encode(FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	if !pub.VerifyRaw(msg, raw) {
+		t.Fatal("raw signature does not verify")
+	}
+	if pub.VerifyRaw(msg, der) || pub.Verify(msg, raw) {
+		t.Fatal("formats are interchangeable")
+	}
+	if pub.VerifyRaw(msg, raw[:63]) || pub.VerifyRaw(msg, append(raw, 0)) {
+		t.Fatal("wrong length accepted")
+	}
+	bad := append([]byte(nil), raw...)
+	bad[63] ^= 1
+	if pub.VerifyRaw(msg, bad) {
+		t.Fatal("modified signature verifies")
+	}
+
+	// r || s with r < 2^248 must keep its leading zero byte.
+	short := &Signature{R: big.NewInt(1), S: sig.S}
+	padded, err := short.Encode(FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if padded[0] != 0 || len(padded) != 64 {
+		t.Fatalf("got %x", padded)
+	}
+	if pub.VerifyRaw(msg, padded[1:]) {
+		t.Fatal("unpadded signature accepted")
+	}
+	zero := make([]byte, 64)
+	if pub.VerifyRaw(msg, zero) {
+		t.Fatal("zero signature accepted")
+	}
+}
+
+func TestSignatureRecoverableOffset(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("bridge transfer")
+	sig, err := SignSignature(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth, err := sig.EncodeRecoverable(EthereumVOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eth) != 65 || uint(eth[64]) != EthereumVOffset+sig.V {
+		t.Fatalf("got V = %d for parity %d", eth[64], sig.V)
+	}
+	rec, _ := sig.Encode(FormatRecoverable)
+	if !bytes.Equal(eth[:64], rec[:64]) {
+		t.Fatal("R || S differ from FormatRecoverable")
+	}
+	if plain, _ := sig.EncodeRecoverable(0); !bytes.Equal(plain, rec) {
+		t.Fatal("offset 0 differs from FormatRecoverable")
+	}
+
+	var dec Signature
+	if err := dec.DecodeRecoverable(eth, EthereumVOffset); err != nil {
+		t.Fatal(err)
+	}
+	if !dec.Equal(sig) || dec.V != sig.V {
+		t.Fatal("round trip changed the signature")
+	}
+	if !VerifySignature(&priv.PublicKey, msg, &dec) {
+		t.Fatal("decoded signature does not verify")
+	}
+	if uint(eth[64]) != EthereumVOffset+sig.V {
+		t.Fatal("DecodeRecoverable modified its input")
+	}
+
+	for name, c := range map[string]struct {
+		data   []byte
+		offset uint
+	}{
+		"wrong offset": {eth, 0},
+		"v below":      {append(append([]byte(nil), eth[:64]...), EthereumVOffset-1), EthereumVOffset},
+		"v above":      {append(append([]byte(nil), eth[:64]...), EthereumVOffset+2), EthereumVOffset},
+		"short":        {eth[:64], EthereumVOffset},
+		"offset 255":   {eth, 255},
+	} {
+		before := Signature{R: big.NewInt(42)}
+		if err := before.DecodeRecoverable(c.data, c.offset); err == nil {
+			t.Errorf("%s: accepted", name)
+		} else if before.R.Int64() != 42 {
+			t.Errorf("%s: signature modified on error", name)
+		}
+	}
+	if _, err := sig.EncodeRecoverable(255); err == nil {
+		t.Fatal("offset 255 encoded")
+	}
+}
@@ -17,20 +17,26 @@ package sm2
 
 // reference to ecdsa
 import (
-	"bytes"
+	"context"
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha512"
 	"encoding/asn1"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"math/big"
+	"sync"
+	"sync/atomic"
 
+	"github.com/xuperchain/crypto/gm/gmsm/audit"
+	"github.com/xuperchain/crypto/gm/gmsm/ctutil"
 	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3kdf"
 	//	"github.com/tjfoc/gmsm/sm3"
 )
 
@@ -38,11 +44,19 @@ const (
 	aesIV = "IV for <SM2> CTR"
 )
 
+// PublicKey is an SM2 public key. The functions of the package only read
+// X and Y, so a PublicKey may be shared by goroutines as long as no one
+// modifies it. Precompute speeds up the verification of its signatures.
 type PublicKey struct {
 	elliptic.Curve
 	X, Y *big.Int
+
+	precomputed atomic.Value // *sm2P256KeyTable, set by Precompute
 }
 
+// PrivateKey is an SM2 private key. Like PublicKey, it is only read by the
+// functions of the package, except for Destroy, and is safe for concurrent
+// use by signing and decryption as long as no one modifies it.
 type PrivateKey struct {
 	PublicKey
 	D *big.Int
@@ -54,6 +68,8 @@ type Signer struct {
 	Entropy []byte
 	AES_key []byte
 	CSPRNG  cipher.StreamReader
+	// Rand is the source of Entropy, RandSource() if nil.
+	Rand io.Reader
 
 	e, k, r, t, s *big.Int
 }
@@ -70,8 +86,12 @@ func (signer *Signer) MakeEntropy() (err error) {
 		entropylen = 32
 	}
 
+	r := signer.Rand
+	if r == nil {
+		r = RandSource()
+	}
 	signer.Entropy = make([]byte, entropylen)
-	_, err = io.ReadFull(rand.Reader, signer.Entropy)
+	_, err = io.ReadFull(r, signer.Entropy)
 	if err != nil {
 		return err
 	}
@@ -143,6 +163,9 @@ func (signer *Signer) MakeSignature() (err error) {
 		d1Inv := new(big.Int).ModInverse(d1, signer.Params().N)
 		signer.s.Mul(signer.s, d1Inv)
 		signer.s.Mod(signer.s, signer.Params().N)
+		zeroizeInt(rD)
+		zeroizeInt(d1)
+		zeroizeInt(d1Inv)
 		if signer.s.Sign() != 0 {
 			return
 		}
@@ -151,6 +174,7 @@ func (signer *Signer) MakeSignature() (err error) {
 
 // sign format = 30 + len(z) + 02 + len(r) + r + 02 + len(s) + s, z being what follows its size, ie 02+len(r)+r+02+len(s)+s
 func (signer *Signer) Sign() ([]byte, error) {
+	defer signer.Destroy()
 
 	err := signer.MakeEntropy()
 	if err != nil {
@@ -182,12 +206,29 @@ func (priv *PrivateKey) Public() crypto.PublicKey {
 	return &priv.PublicKey
 }
 
+// Sign signs the digest msg with priv. If opts is not nil, opts.HashFunc()
+// tells which hash produced msg: sm3.CryptoHash (or Hash SM3 of this package)
+// for an SM3 digest, in which case msg must be exactly sm3.Size bytes long.
+// The nonce is derived from the key, msg and entropy from rand, or from
+// RandSource() if rand is nil.
 func (priv *PrivateKey) Sign(rand io.Reader, msg []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts != nil && opts.HashFunc() == sm3.CryptoHash && len(msg) != sm3.Size {
+		err := errors.New("sm2: message length does not match the SM3 digest size")
+		record(audit.OpSign, &priv.PublicKey, err)
+		return nil, err
+	}
+	if err := checkPrivateKey(priv); err != nil {
+		record(audit.OpSign, &priv.PublicKey, err)
+		return nil, err
+	}
 	signer := Signer{
 		PrivateKey: *priv,
 		Msg:        msg,
+		Rand:       rand,
 	}
-	return signer.Sign()
+	sig, err := signer.Sign()
+	record(audit.OpSign, &priv.PublicKey, err)
+	return sig, err
 }
 
 func (priv *PrivateKey) Decrypt(data []byte) ([]byte, error) {
@@ -204,45 +245,23 @@ func (pub *PublicKey) Verify(msg []byte, sign []byte) bool {
 	return Verify(pub, msg, sm2Sign.R, sm2Sign.S)
 }
 
+// VerifyRaw is Verify for a FormatRaw signature: r || s, each exactly 32
+// bytes big-endian with leading zeros kept. Signatures of any other length,
+// or with r or s outside [1, n-1], are rejected rather than padded.
+func (pub *PublicKey) VerifyRaw(msg []byte, sign []byte) bool {
+	var sig Signature
+	if sig.DecodeFormat(FormatRaw, sign) != nil {
+		return false
+	}
+	return Verify(pub, msg, sig.R, sig.S)
+}
+
 func (pub *PublicKey) Encrypt(data []byte) ([]byte, error) {
 	return Encrypt(pub, data)
 }
 
 var one = new(big.Int).SetInt64(1)
 
-func intToBytes(x int) []byte {
-	var buf = make([]byte, 4)
-
-	binary.BigEndian.PutUint32(buf, uint32(x))
-	return buf
-}
-
-func kdf(x, y []byte, length int) ([]byte, bool) {
-	var c []byte
-
-	ct := 1
-	h := sm3.New()
-	x = append(x, y...)
-	for i, j := 0, (length+31)/32; i < j; i++ {
-		h.Reset()
-		h.Write(x)
-		h.Write(intToBytes(ct))
-		hash := h.Sum(nil)
-		if i+1 == j && length%32 != 0 {
-			c = append(c, hash[:length%32]...)
-		} else {
-			c = append(c, hash...)
-		}
-		ct++
-	}
-	for i := 0; i < length; i++ {
-		if c[i] != 0 {
-			return c, true
-		}
-	}
-	return c, false
-}
-
 func randFieldElement(c elliptic.Curve, rand io.Reader) (k *big.Int, err error) {
 	params := c.Params()
 	b := make([]byte, params.BitSize/8+8)
@@ -250,16 +269,37 @@ func randFieldElement(c elliptic.Curve, rand io.Reader) (k *big.Int, err error)
 	if err != nil {
 		return
 	}
-	k = new(big.Int).SetBytes(b)
 	n := new(big.Int).Sub(params.N, one)
-	k.Mod(k, n)
+	kBytes := ctutil.Reduce(b, ctutil.FixedBytes(n, (n.BitLen()+7)/8))
+	zeroize(b)
+	k = new(big.Int).SetBytes(kBytes)
+	zeroize(kBytes)
 	k.Add(k, one)
 	return
 }
 
+// record reports an operation on a key of this package to the audit hook.
+func record(op audit.Operation, pub *PublicKey, err error) {
+	if pub == nil {
+		audit.Record(op, audit.BackendSoftware, nil, nil, err)
+		return
+	}
+	audit.Record(op, audit.BackendSoftware, pub.X, pub.Y, err)
+}
+
 func GenerateKey() (*PrivateKey, error) {
+	priv, err := generateKey()
+	if err != nil {
+		record(audit.OpGenerateKey, nil, err)
+		return nil, err
+	}
+	record(audit.OpGenerateKey, &priv.PublicKey, nil)
+	return priv, nil
+}
+
+func generateKey() (*PrivateKey, error) {
 	c := P256Sm2()
-	k, err := randFieldElement(c, rand.Reader)
+	k, err := randFieldElement(c, RandSource())
 	if err != nil {
 		return nil, err
 	}
@@ -270,9 +310,119 @@ func GenerateKey() (*PrivateKey, error) {
 	return priv, nil
 }
 
+// seedKeyTag separates the hash inputs of GenerateKeyFromSeed from other
+// uses of SM3 over the same seed.
+const seedKeyTag = "SM2 key from seed"
+
+// GenerateKeyFromSeed deterministically derives a private key from seed,
+// which must hold at least 128 bits of entropy. Candidates
+// d = SM3(tag || ctr || seed) for ctr = 0, 1, ... are tried until one lies in
+// [1, n-2], so the key is uniform without any modular bias. The same seed
+// always yields the same key.
+func GenerateKeyFromSeed(seed []byte) (*PrivateKey, error) {
+	priv, err := generateKeyFromSeed(seed)
+	if err != nil {
+		record(audit.OpGenerateKey, nil, err)
+		return nil, err
+	}
+	record(audit.OpGenerateKey, &priv.PublicKey, nil)
+	return priv, nil
+}
+
+func generateKeyFromSeed(seed []byte) (*PrivateKey, error) {
+	if len(seed) < 16 {
+		return nil, errors.New("sm2: seed must be at least 16 bytes")
+	}
+	c := P256Sm2()
+	nMinus1 := ctutil.FixedBytes(new(big.Int).Sub(c.Params().N, one), 32)
+	var ctr [4]byte
+	for i := uint32(0); ; i++ {
+		binary.BigEndian.PutUint32(ctr[:], i)
+		h := sm3.New()
+		h.Write([]byte(seedKeyTag))
+		h.Write(ctr[:])
+		h.Write(seed)
+		b := h.Sum(nil)
+		if !scalarInRange(b, nMinus1) {
+			continue
+		}
+		k := new(big.Int).SetBytes(b)
+		zeroize(b)
+		priv := new(PrivateKey)
+		priv.PublicKey.Curve = c
+		priv.D = k
+		priv.PublicKey.X, priv.PublicKey.Y = c.ScalarBaseMult(k.Bytes())
+		return priv, nil
+	}
+}
+
+// GenerateKeyContext generates a key like GenerateKey, but draws
+// d from RandSource() by rejection sampling: 256-bit candidates are read
+// until one lies in [1, n-2]. It returns ctx.Err() once ctx is done, which
+// is checked before each read, so that a slow or blocking entropy source,
+// such as a hardware generator, cannot hold up a caller past its deadline.
+func GenerateKeyContext(ctx context.Context) (*PrivateKey, error) {
+	priv, err := generateKeyContext(ctx, RandSource())
+	if err != nil {
+		record(audit.OpGenerateKey, nil, err)
+		return nil, err
+	}
+	record(audit.OpGenerateKey, &priv.PublicKey, nil)
+	return priv, nil
+}
+
+func generateKeyContext(ctx context.Context, rand io.Reader) (*PrivateKey, error) {
+	c := P256Sm2()
+	nMinus1 := ctutil.FixedBytes(new(big.Int).Sub(c.Params().N, one), 32)
+	buf := make([]byte, 32)
+	defer zeroize(buf)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(rand, buf); err != nil {
+			return nil, err
+		}
+		if !scalarInRange(buf, nMinus1) {
+			continue
+		}
+		k := new(big.Int).SetBytes(buf)
+		priv := new(PrivateKey)
+		priv.PublicKey.Curve = c
+		priv.D = k
+		priv.PublicKey.X, priv.PublicKey.Y = c.ScalarBaseMult(k.Bytes())
+		return priv, nil
+	}
+}
+
+// scalarInRange reports whether the big-endian b lies in [1, n-2], given
+// nMinus1 = n-1 of the same length, in time independent of b.
+func scalarInRange(b, nMinus1 []byte) bool {
+	zero := make([]byte, len(b))
+	return ctutil.Less(b, nMinus1)&^ctutil.Equal(b, zero) == 1
+}
+
 var errZeroParam = errors.New("zero parameter")
 
+// Verify verifies the signature (r, s) of the digest hash = SM3(ZA || M).
 func Verify(pub *PublicKey, hash []byte, r, s *big.Int) bool {
+	if checkPublicKey(pub) != nil {
+		return false
+	}
+	return verifyDigest(pub, hash, r, s)
+}
+
+// verifyDigest verifies (r, s) for the digest without checking pub.
+func verifyDigest(pub *PublicKey, hash []byte, r, s *big.Int) bool {
+	if valid, ok := verifyFast(pub, hash, r, s); ok {
+		return valid
+	}
+	return verifyGeneric(pub, hash, r, s)
+}
+
+// verifyGeneric verifies with the operations of pub.Curve, for other curves
+// and for inputs verifyFast does not take.
+func verifyGeneric(pub *PublicKey, hash []byte, r, s *big.Int) bool {
 	c := pub.Curve
 	N := c.Params().N
 
@@ -293,6 +443,7 @@ func Verify(pub *PublicKey, hash []byte, r, s *big.Int) bool {
 	var x *big.Int
 	x1, y1 := c.ScalarBaseMult(s.Bytes())
 	x2, y2 := c.ScalarMult(pub.X, pub.Y, t.Bytes())
+	// For P256Sm2 this is sm2P256Curve.Add, not the big.Int formulas.
 	x, _ = c.Add(x1, y1, x2, y2)
 
 	e := new(big.Int).SetBytes(hash)
@@ -301,29 +452,58 @@ func Verify(pub *PublicKey, hash []byte, r, s *big.Int) bool {
 	return x.Cmp(r) == 0
 }
 
+// Sm2Sign signs msg, hashed with ZA of the user ID uid. A nil uid is
+// DefaultUID, as in other implementations; pass []byte{} to sign with an
+// empty user ID.
 func Sm2Sign(priv *PrivateKey, msg, uid []byte) (r, s *big.Int, err error) {
+	r, s, _, err = Sm2SignWithParity(priv, msg, uid)
+	return
+}
+
+// Sm2SignWithParity is like Sm2Sign but also returns the parity of the
+// y-coordinate of the point k·G behind r, with which BatchVerifier.Add
+// batches the signature.
+func Sm2SignWithParity(priv *PrivateKey, msg, uid []byte) (r, s *big.Int, parity uint, err error) {
+	defer func() { record(audit.OpSign, &priv.PublicKey, err) }()
+	if err = checkPrivateKey(priv); err != nil {
+		return nil, nil, 0, err
+	}
+	return sm2Sign(priv, msg, uid, RandSource())
+}
+
+func sm2Sign(priv *PrivateKey, msg, uid []byte, rand io.Reader) (r, s *big.Int, parity uint, err error) {
 	za, err := ZA(&priv.PublicKey, uid)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
+	}
+	if _, ok := priv.Curve.(sm2P256Curve); ok {
+		var e [32]byte
+		return signFast(priv, msgDigest(&e, za, msg), rand)
 	}
 	e, err := msgHash(za, msg)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 	c := priv.PublicKey.Curve
 	N := c.Params().N
 	if N.Sign() == 0 {
-		return nil, nil, errZeroParam
+		return nil, nil, 0, errZeroParam
 	}
 	var k *big.Int
+	defer func() { zeroizeInt(k) }()
 	for { // 调整算法细节以实现SM2
 		for {
-			k, err = randFieldElement(c, rand.Reader)
+			zeroizeInt(k)
+			k, err = randFieldElement(c, rand)
 			if err != nil {
 				r = nil
 				return
 			}
-			r, _ = priv.Curve.ScalarBaseMult(k.Bytes())
+			var y1 *big.Int
+			kBytes := k.Bytes()
+			r, y1 = priv.Curve.ScalarBaseMult(kBytes)
+			zeroize(kBytes)
+			parity = y1.Bit(0)
 			r.Add(r, e)
 			r.Mod(r, N)
 			if r.Sign() != 0 {
@@ -339,6 +519,9 @@ func Sm2Sign(priv *PrivateKey, msg, uid []byte) (r, s *big.Int, err error) {
 		d1Inv := new(big.Int).ModInverse(d1, N)
 		s.Mul(s, d1Inv)
 		s.Mod(s, N)
+		zeroizeInt(rD)
+		zeroizeInt(d1)
+		zeroizeInt(d1Inv)
 		if s.Sign() != 0 {
 			break
 		}
@@ -346,37 +529,27 @@ func Sm2Sign(priv *PrivateKey, msg, uid []byte) (r, s *big.Int, err error) {
 	return
 }
 
+// Sm2Verify verifies the signature (r, s) of msg under the user ID uid,
+// DefaultUID if nil, as for Sm2Sign. CheckSignature reports the UID it
+// verified under on failure.
 func Sm2Verify(pub *PublicKey, msg, uid []byte, r, s *big.Int) bool {
-	c := pub.Curve
-	N := c.Params().N
-	one := new(big.Int).SetInt64(1)
-	if r.Cmp(one) < 0 || s.Cmp(one) < 0 {
-		return false
-	}
-	if r.Cmp(N) >= 0 || s.Cmp(N) >= 0 {
+	if checkPublicKey(pub) != nil {
 		return false
 	}
 	za, err := ZA(pub, uid)
 	if err != nil {
 		return false
 	}
-	e, err := msgHash(za, msg)
-	if err != nil {
-		return false
-	}
-	t := new(big.Int).Add(r, s)
-	t.Mod(t, N)
-	if t.Sign() == 0 {
-		return false
-	}
-	var x *big.Int
-	x1, y1 := c.ScalarBaseMult(s.Bytes())
-	x2, y2 := c.ScalarMult(pub.X, pub.Y, t.Bytes())
-	x, _ = c.Add(x1, y1, x2, y2)
+	var e [32]byte
+	return verifyDigest(pub, msgDigest(&e, za, msg), r, s)
+}
 
-	x.Add(x, e)
-	x.Mod(x, N)
-	return x.Cmp(r) == 0
+// msgDigest sets out to SM3(za || msg) and returns it as a slice.
+func msgDigest(out *[32]byte, za, msg []byte) []byte {
+	h := sm3.New()
+	h.Write(za)
+	h.Write(msg)
+	return h.Sum(out[:0])
 }
 
 func msgHash(za, msg []byte) (*big.Int, error) {
@@ -387,11 +560,14 @@ func msgHash(za, msg []byte) (*big.Int, error) {
 }
 
 // ZA = H256(ENTLA || IDA || a || b || xG || yG || xA || yA)
+//
+// A nil uid is DefaultUID; []byte{} is the empty user ID.
 func ZA(pub *PublicKey, uid []byte) ([]byte, error) {
 	za := sm3.New()
+	uid = resolveUID(uid)
 	uidLen := len(uid)
 	if uidLen >= 8192 {
-		return []byte{}, errors.New("SM2: uid too large")
+		return []byte{}, fmt.Errorf("SM2: uid of %d bytes too large", uidLen)
 	}
 	Entla := uint16(8 * uidLen)
 	za.Write([]byte{byte((Entla >> 8) & 0xFF)})
@@ -412,6 +588,12 @@ func ZA(pub *PublicKey, uid []byte) ([]byte, error) {
 	return za.Sum(nil)[:32], nil
 }
 
+func concat(x, y []byte) []byte {
+	z := make([]byte, 0, len(x)+len(y))
+	z = append(z, x...)
+	return append(z, y...)
+}
+
 // 32byte
 func zeroByteSlice() []byte {
 	return []byte{
@@ -434,6 +616,35 @@ func zeroByteSlice() []byte {
  *  CipherText
  */
 func Encrypt(pub *PublicKey, data []byte) ([]byte, error) {
+	if err := checkPublicKey(pub); err != nil {
+		return nil, err
+	}
+	return encrypt(pub, data, RandSource())
+}
+
+// AppendEncrypt is Encrypt appending the ciphertext to dst, which it reuses
+// if it has room, so that encrypting many messages into one buffer only
+// allocates for the curve arithmetic. dst must not overlap data. On error
+// dst is returned unchanged.
+func AppendEncrypt(dst []byte, pub *PublicKey, data []byte) ([]byte, error) {
+	if err := checkPublicKey(pub); err != nil {
+		return dst, err
+	}
+	return appendEncrypt(dst, pub, data, RandSource())
+}
+
+func encrypt(pub *PublicKey, data []byte, rand io.Reader) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	ct, err := appendEncrypt(nil, pub, data, rand)
+	if err != nil {
+		return nil, err
+	}
+	return ct, nil
+}
+
+func appendEncrypt(dst []byte, pub *PublicKey, data []byte, rand io.Reader) ([]byte, error) {
 	/*
 		PB为公钥，M为明文，len为M的长度
 		1. 产生随机数k，k的值大于等于1小于等于n-1
@@ -445,89 +656,146 @@ func Encrypt(pub *PublicKey, data []byte) ([]byte, error) {
 		7. 密文C=C1||C2||C3
 	*/
 	if len(data) == 0 {
-		return []byte{}, nil
+		return dst, nil
 	}
-	length := len(data)
+	ret, out := sliceForAppend(dst, 97+len(data))
+	curve := pub.Curve
 	for {
-		c := []byte{}
-		curve := pub.Curve
-		k, err := randFieldElement(curve, rand.Reader)
+		k, err := randFieldElement(curve, rand)
 		if err != nil {
-			return nil, err
+			return dst, err
 		}
-		x1, y1 := curve.ScalarBaseMult(k.Bytes())
-		x2, y2 := curve.ScalarMult(pub.X, pub.Y, k.Bytes())
-		x1Buf := x1.Bytes()
-		y1Buf := y1.Bytes()
-		x2Buf := x2.Bytes()
-		y2Buf := y2.Bytes()
-		if n := len(x1Buf); n < 32 {
-			x1Buf = append(zeroByteSlice()[:32-n], x1Buf...)
-		}
-		if n := len(y1Buf); n < 32 {
-			y1Buf = append(zeroByteSlice()[:32-n], y1Buf...)
-		}
-		if n := len(x2Buf); n < 32 {
-			x2Buf = append(zeroByteSlice()[:32-n], x2Buf...)
-		}
-		if n := len(y2Buf); n < 32 {
-			y2Buf = append(zeroByteSlice()[:32-n], y2Buf...)
-		}
-		c = append(c, x1Buf...) // x分量
-		c = append(c, y1Buf...) // y分量
-		tm := []byte{}
-		tm = append(tm, x2Buf...)
-		tm = append(tm, data...)
-		tm = append(tm, y2Buf...)
-		h := sm3.Sm3Sum(tm)
-		c = append(c, h...)
-		ct, ok := kdf(x2Buf, y2Buf, length) // 密文
-		if !ok {
+		kBytes := k.Bytes()
+		x1, y1 := curve.ScalarBaseMult(kBytes)
+		x2, y2 := curve.ScalarMult(pub.X, pub.Y, kBytes)
+		// k and the shared point give away the message.
+		zeroize(kBytes)
+		zeroizeInt(k)
+		z := sharedSecret(x2, y2)
+
+		out[0] = 0x04
+		copy(out[1:33], ctutil.FixedBytes(x1, 32))  // x分量
+		copy(out[33:65], ctutil.FixedBytes(y1, 32)) // y分量
+		c3Sum(out[65:97], z, data)
+		err = sm3kdf.XORKeyStream(out[97:], data, z) // 密文
+		zeroize(z)
+		if err == sm3kdf.ErrAllZero {
 			continue
 		}
-		c = append(c, ct...)
-		for i := 0; i < length; i++ {
-			c[96+i] ^= data[i]
+		if err != nil {
+			return dst, err
 		}
-		return append([]byte{0x04}, c...), nil
+		return ret, nil
 	}
 }
 
 func Decrypt(priv *PrivateKey, data []byte) ([]byte, error) {
+	var pt []byte
+	err := checkPrivateKey(priv)
+	if err == nil {
+		pt, err = decrypt(priv, data)
+	}
+	record(audit.OpDecrypt, &priv.PublicKey, err)
+	return pt, err
+}
+
+// AppendDecrypt is Decrypt appending the plaintext to dst, which it reuses
+// if it has room. dst must not overlap data. On error dst is returned
+// unchanged, and no unauthenticated plaintext is left in its array.
+func AppendDecrypt(dst []byte, priv *PrivateKey, data []byte) ([]byte, error) {
+	out := dst
+	err := checkPrivateKey(priv)
+	if err == nil {
+		out, err = appendDecrypt(dst, priv, data)
+	}
+	record(audit.OpDecrypt, &priv.PublicKey, err)
+	return out, err
+}
+
+func decrypt(priv *PrivateKey, data []byte) ([]byte, error) {
+	pt, err := appendDecrypt(nil, priv, data)
+	if err != nil {
+		return nil, err
+	}
+	if pt == nil {
+		pt = []byte{}
+	}
+	return pt, nil
+}
+
+func appendDecrypt(dst []byte, priv *PrivateKey, data []byte) ([]byte, error) {
 	if len(data) == 0 {
-		return []byte{}, nil
+		return dst, nil
+	}
+	if len(data) < 97 {
+		return dst, errors.New("Decrypt: failed to decrypt")
 	}
 	data = data[1:]
 	length := len(data) - 96
 	curve := priv.Curve
 	x := new(big.Int).SetBytes(data[:32])
 	y := new(big.Int).SetBytes(data[32:64])
-	x2, y2 := curve.ScalarMult(x, y, priv.D.Bytes())
-	x2Buf := x2.Bytes()
-	y2Buf := y2.Bytes()
-	if n := len(x2Buf); n < 32 {
-		x2Buf = append(zeroByteSlice()[:32-n], x2Buf...)
-	}
-	if n := len(y2Buf); n < 32 {
-		y2Buf = append(zeroByteSlice()[:32-n], y2Buf...)
-	}
-	c, ok := kdf(x2Buf, y2Buf, length)
-	if !ok {
-		return nil, errors.New("Decrypt: failed to decrypt")
-	}
-	for i := 0; i < length; i++ {
-		c[i] ^= data[i+96]
-	}
-	tm := []byte{}
-	tm = append(tm, x2Buf...)
-	tm = append(tm, c...)
-	tm = append(tm, y2Buf...)
-	h := sm3.Sm3Sum(tm)
-	// TODO: 检查bytes.Compare函数和bytes.Equal哪个更加高效
-	if bytes.Compare(h, data[64:96]) != 0 {
-		return c, errors.New("Decrypt: failed to decrypt")
-	}
-	return c, nil
+	d := priv.D.Bytes()
+	x2, y2 := curve.ScalarMult(x, y, d)
+	zeroize(d)
+	z := sharedSecret(x2, y2)
+	defer zeroize(z)
+
+	ret, out := sliceForAppend(dst, length)
+	if err := sm3kdf.XORKeyStream(out, data[96:], z); err != nil {
+		zeroize(out)
+		return dst, errors.New("Decrypt: failed to decrypt")
+	}
+	var h [sm3.Size]byte
+	c3Sum(h[:], z, out)
+	if ctutil.Equal(h[:], data[64:96]) != 1 {
+		// The unauthenticated plaintext is not returned.
+		zeroize(out)
+		return dst, errors.New("Decrypt: failed to decrypt")
+	}
+	return ret, nil
+}
+
+// sharedSecret returns x2 || y2, 64 bytes, and wipes x2 and y2.
+func sharedSecret(x2, y2 *big.Int) []byte {
+	x2Buf := ctutil.FixedBytes(x2, 32)
+	y2Buf := ctutil.FixedBytes(y2, 32)
+	z := concat(x2Buf, y2Buf)
+	zeroizeInt(x2)
+	zeroizeInt(y2)
+	zeroize(x2Buf)
+	zeroize(y2Buf)
+	return z
+}
+
+// sm3Pool holds the hash states of c3Sum.
+var sm3Pool = sync.Pool{
+	New: func() interface{} { return sm3.New() },
+}
+
+// c3Sum sets out to C3 = SM3(x2 || msg || y2), where z is x2 || y2.
+func c3Sum(out, z, msg []byte) {
+	h := sm3Pool.Get().(hash.Hash)
+	h.Write(z[:32])
+	h.Write(msg)
+	h.Write(z[32:])
+	h.Sum(out[:0])
+	// Reset wipes x2 or y2 from the buffer of the hash.
+	h.Reset()
+	sm3Pool.Put(h)
+}
+
+// sliceForAppend extends in by n bytes, reusing its array if it has room,
+// and returns the extended slice and its last n bytes.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
 }
 
 type zr struct {
@@ -547,23 +815,33 @@ func getLastBit(a *big.Int) uint {
 	return a.Bit(0)
 }
 
+// Compress returns the parity of Y followed by the 32-byte X. It writes to
+// a new buffer only.
 func Compress(a *PublicKey) []byte {
-	buf := []byte{}
-	yp := getLastBit(a.Y)
-	buf = append(buf, a.X.Bytes()...)
-	if n := len(a.X.Bytes()); n < 32 {
-		buf = append(zeroByteSlice()[:(32-n)], buf...)
-	}
-	buf = append([]byte{byte(yp)}, buf...)
+	buf := make([]byte, 33)
+	buf[0] = byte(getLastBit(a.Y))
+	x := a.X.Bytes()
+	copy(buf[33-len(x):], x)
 	return buf
 }
 
-func Decompress(a []byte) *PublicKey {
+var errCompressedKey = errors.New("sm2: invalid compressed public key")
+
+// Decompress parses the output of Compress. It returns an error, and never
+// panics, for input that is not 33 bytes, has a parity byte other than 0 or
+// 1, or has an x-coordinate that is not that of a point of the curve.
+func Decompress(a []byte) (*PublicKey, error) {
+	if len(a) != 33 || a[0] > 1 {
+		return nil, errCompressedKey
+	}
 	var aa, xx, xx3 sm2P256FieldElement
 
 	P256Sm2()
 	x := new(big.Int).SetBytes(a[1:])
 	curve := sm2P256
+	if x.Cmp(curve.P) >= 0 {
+		return nil, errCompressedKey
+	}
 	sm2P256FromBig(&xx, x)
 	sm2P256Square(&xx3, &xx)       // x3 = x ^ 2
 	sm2P256Mul(&xx3, &xx3, &xx)    // x3 = x ^ 2 * x
@@ -573,20 +851,37 @@ func Decompress(a []byte) *PublicKey {
 
 	y2 := sm2P256ToBig(&xx3)
 	y := new(big.Int).ModSqrt(y2, sm2P256.P)
+	if y == nil {
+		return nil, errCompressedKey
+	}
 	if getLastBit(y) != uint(a[0]) {
+		if y.Sign() == 0 {
+			return nil, errCompressedKey
+		}
 		y.Sub(sm2P256.P, y)
 	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errCompressedKey
+	}
 	return &PublicKey{
 		Curve: P256Sm2(),
 		X:     x,
 		Y:     y,
-	}
+	}, nil
 }
 
+// SignDigitToSignData returns the DER encoding of (r, s).
+//
+// Deprecated: use Signature.Encode, which supports other formats and checks
+// the range of r and s.
 func SignDigitToSignData(r, s *big.Int) ([]byte, error) {
 	return asn1.Marshal(sm2Signature{r, s})
 }
 
+// SignDataToSignDigit parses a DER signature.
+//
+// Deprecated: use Signature.Decode or Signature.DecodeFormat, which reject
+// non-canonical encodings.
 func SignDataToSignDigit(sign []byte) (*big.Int, *big.Int, error) {
 	var sm2Sign sm2Signature
 
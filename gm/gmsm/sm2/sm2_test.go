@@ -16,6 +16,8 @@ limitations under the License.
 package sm2
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -27,6 +29,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
 )
 
 func TestSm2(t *testing.T) {
@@ -208,6 +212,86 @@ func TestGenerateKey(t *testing.T) {
 	}
 }
 
+func TestGenerateKeyFromSeed(t *testing.T) {
+	seed := []byte("000102030405060708090a0b0c0d0e0f")
+	priv, err := GenerateKeyFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := new(big.Int).SetString("24E034D9940C79A24306E5B4656BF15DC3A9EC3953CCC5B87A60BDCD611D2A69", 16)
+	if priv.D.Cmp(want) != 0 {
+		t.Fatalf("D = %X, want %X", priv.D, want)
+	}
+	x, y := priv.Curve.ScalarBaseMult(priv.D.Bytes())
+	if priv.X.Cmp(x) != 0 || priv.Y.Cmp(y) != 0 {
+		t.Fatal("public key does not match D")
+	}
+
+	again, _ := GenerateKeyFromSeed(seed)
+	if again.D.Cmp(priv.D) != 0 {
+		t.Fatal("same seed gave different keys")
+	}
+	other, _ := GenerateKeyFromSeed(append(seed, 0))
+	if other.D.Cmp(priv.D) == 0 {
+		t.Fatal("different seeds gave the same key")
+	}
+	if _, err := GenerateKeyFromSeed(seed[:15]); err == nil {
+		t.Fatal("expected error for a short seed")
+	}
+}
+
+// cancelingReader returns bytes that are never a valid key and cancels its
+// context after a few reads.
+type cancelingReader struct {
+	reads  int
+	cancel context.CancelFunc
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	if r.reads++; r.reads == 3 {
+		r.cancel()
+	}
+	copy(p, bytes.Repeat([]byte{0xff}, len(p)))
+	return len(p), nil
+}
+
+func TestGenerateKeyContext(t *testing.T) {
+	priv, err := GenerateKeyContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &cancelingReader{cancel: cancel}
+	if _, err := generateKeyContext(ctx, r); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if r.reads != 3 {
+		t.Fatalf("%d reads after the cancellation", r.reads-3)
+	}
+}
+
+func TestSignWithSM3Opts(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sm3.Sm3Sum([]byte("test"))
+	sign, err := priv.Sign(rand.Reader, digest, SM3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.Verify(digest, sign) {
+		t.Fatal("signature over SM3 digest does not verify")
+	}
+	if _, err := priv.Sign(rand.Reader, []byte("test"), sm3.CryptoHash); err == nil {
+		t.Fatal("expected error for a message that is not an SM3 digest")
+	}
+}
+
 func BenchmarkSM2(t *testing.B) {
 	t.ReportAllocs()
 	for i := 0; i < t.N; i++ {
@@ -0,0 +1,56 @@
+package sm2
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"math/big"
+)
+
+// VerifyStrict verifies the ASN.1 signature sig of msg by pub under the
+// user ID uid, DefaultUID if nil, for consensus-critical code, where two
+// verifiers that disagree on a single signature fork the chain. It returns
+// nil or a *VerifyError, and unlike Sm2Verify and VerifyEx, whatever
+// SetKeyValidation says:
+//
+//   - pub must be a valid point of the SM2 curve, as by PublicKey.Validate;
+//   - sig must be the DER encoding of SEQUENCE { r, s } exactly as Sign
+//     produces it, with no trailing data;
+//   - r and s must lie in [1, n-1] and r+s must not be 0 mod n;
+//   - e is always SM3(ZA || msg), with ZA computed from pub and uid; there
+//     is no entry point for a digest computed by the caller, which could
+//     skip or fake ZA.
+func VerifyStrict(pub *PublicKey, msg, uid, sig []byte) error {
+	fail := func(reason string) error {
+		return &VerifyError{UID: resolveUID(uid), Reason: reason}
+	}
+	if pub == nil || pub.Curve == nil || pub.Curve.Params() != P256Sm2().Params() || pub.Validate() != nil {
+		return fail("invalid public key")
+	}
+
+	var rs sm2Signature
+	rest, err := asn1.Unmarshal(sig, &rs)
+	if err != nil || len(rest) != 0 {
+		return fail("malformed signature")
+	}
+	if der, err := asn1.Marshal(rs); err != nil || !bytes.Equal(der, sig) {
+		return fail("non-canonical signature encoding")
+	}
+	n := pub.Curve.Params().N
+	if rs.R.Sign() <= 0 || rs.S.Sign() <= 0 || rs.R.Cmp(n) >= 0 || rs.S.Cmp(n) >= 0 {
+		return fail("signature values out of range")
+	}
+	t := new(big.Int).Add(rs.R, rs.S)
+	if t.Mod(t, n).Sign() == 0 {
+		return fail("signature values out of range")
+	}
+
+	za, err := ZA(pub, uid)
+	if err != nil {
+		return fail("user ID too long")
+	}
+	var e [32]byte
+	if !verifyDigest(pub, msgDigest(&e, za, msg), rs.R, rs.S) {
+		return fail("signature verification failed")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package sm2
+
+import (
+	"crypto/elliptic"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestVerifyStrict(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	msg := []byte("block 1024")
+	sig, err := SignEx(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyStrict(pub, msg, nil, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyStrict(pub, msg, DefaultUID, sig); err != nil {
+		t.Fatal(err)
+	}
+
+	reason := func(err error) string {
+		if e, ok := err.(*VerifyError); ok {
+			return e.Reason
+		}
+		return ""
+	}
+	if r := reason(VerifyStrict(pub, []byte("block 1025"), nil, sig)); r != "signature verification failed" {
+		t.Fatalf("wrong message: %q", r)
+	}
+	err = VerifyStrict(pub, msg, []byte{}, sig)
+	if e, ok := err.(*VerifyError); !ok || len(e.UID) != 0 {
+		t.Fatalf("empty UID accepted or not reported: %v", err)
+	}
+	if r := reason(VerifyStrict(pub, msg, nil, append(sig, 0))); r != "malformed signature" {
+		t.Fatalf("trailing data: %q", r)
+	}
+
+	r, s, err := SignDataToSignDigit(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := P256Sm2().Params().N
+	for _, rs := range []sm2Signature{
+		{new(big.Int).Add(r, n), s},
+		{r, new(big.Int).Add(s, n)},
+		{r, new(big.Int).Neg(s)},
+		{new(big.Int), s},
+		{r, new(big.Int).Sub(n, r)},
+	} {
+		der, _ := asn1.Marshal(rs)
+		if r := reason(VerifyStrict(pub, msg, nil, der)); r != "signature values out of range" {
+			t.Fatalf("(%x, %x): %q", rs.R, rs.S, r)
+		}
+	}
+
+	bad := []*PublicKey{
+		nil,
+		{Curve: P256Sm2(), X: pub.X, Y: new(big.Int).Add(pub.Y, big.NewInt(1))},
+		{Curve: elliptic.P256(), X: pub.X, Y: pub.Y},
+	}
+	for _, k := range bad {
+		if r := reason(VerifyStrict(k, msg, nil, sig)); r != "invalid public key" {
+			t.Fatalf("public key %v: %q", k, r)
+		}
+	}
+}
@@ -0,0 +1,692 @@
+package threshold
+
+import (
+	"context"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Distributed key generation. NewDKG runs a Pedersen (joint-Feldman) DKG in
+// which every party deals a random contribution to d, followed by an
+// inversion that converts the shares of d into the shares of (1+d)^-1 used
+// for signing. It needs n >= 2·threshold - 1 parties.
+//
+//  1. Every party deals three polynomials with Feldman commitments: a random
+//     one for d, a random mask u of the same degree, and one of degree
+//     2·threshold - 2 with a zero constant term. It broadcasts the
+//     commitments and sends each party its shares privately.
+//  2. Every party broadcasts complaints against the dealers whose shares do
+//     not match their commitments.
+//  3. Every accused dealer answers by broadcasting the disputed shares.
+//     Dealers with malformed commitments or without a valid answer to every
+//     complaint are disqualified; the others form QUAL and d, u and the zero
+//     sharing are the sums of their contributions. P = d·G.
+//  4. Every party broadcasts m_j = u_j(1+d_j) + z_j, a share of degree
+//     2·threshold - 2 of μ = u(1+d), with a DLEQ proof that it was formed
+//     correctly. Everyone interpolates μ.
+//  5. Every party sets w_j = u_j/μ, a share of (1+d)^-1, and broadcasts its
+//     verification share w_j·(P + G) with a DLEQ proof.
+//
+// Rounds 4 and 5 end in an AbortError naming the culprits if a party sends
+// an invalid message. Messages of rounds 1 to 3 from disqualified dealers
+// are tolerated, but every party must receive the same broadcast messages.
+//
+// The rounds that verify a message of every party, Round2, Round4, Round5
+// and Finalize, have Context variants that return ctx.Err() once ctx is
+// done, checking it between parties. A canceled DKG cannot be resumed.
+
+// DealMessage is broadcast by every party in round 1. It holds the Feldman
+// commitments to the party's key, mask and zero polynomials; the commitment
+// to the zero constant term is omitted.
+type DealMessage struct {
+	From int
+	Key  [][]byte
+	Mask [][]byte
+	Zero [][]byte
+}
+
+// ShareMessage carries the shares dealt by From to To. It must be delivered
+// over a confidential and authenticated channel, except when it is revealed
+// in a ResponseMessage.
+type ShareMessage struct {
+	From, To        int
+	Key, Mask, Zero *big.Int
+}
+
+// ComplaintMessage is broadcast by every party in round 2 and lists the
+// dealers whose shares were missing or invalid.
+type ComplaintMessage struct {
+	From    int
+	Against []int
+}
+
+// ResponseMessage is broadcast by every party in round 3 and reveals the
+// shares it dealt to the parties that complained against it.
+type ResponseMessage struct {
+	From   int
+	Shares []ShareMessage
+}
+
+// ProductMessage is broadcast by every party in round 4.
+type ProductMessage struct {
+	From    int
+	Product *big.Int
+	Proof   DLEQProof
+}
+
+// VerificationMessage is broadcast by every party in round 5 and holds its
+// verification share in uncompressed form.
+type VerificationMessage struct {
+	From  int
+	Share []byte
+	Proof DLEQProof
+}
+
+// Transcript records the broadcast messages of a DKG run.
+type Transcript struct {
+	SessionID     []byte
+	Threshold     int
+	Parties       int
+	Deals         []DealMessage
+	Complaints    []ComplaintMessage
+	Responses     []ResponseMessage
+	Products      []ProductMessage
+	Verifications []VerificationMessage
+}
+
+func marshalBinary(v interface{}) ([]byte, error) {
+	return asn1.Marshal(v)
+}
+
+func unmarshalBinary(data []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(data, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("threshold: trailing data after message")
+	}
+	return nil
+}
+
+// MarshalBinary returns the DER encoding of the message.
+func (m *DealMessage) MarshalBinary() ([]byte, error) { return marshalBinary(*m) }
+
+// UnmarshalBinary parses a message encoded by MarshalBinary.
+func (m *DealMessage) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, m) }
+
+// MarshalBinary returns the DER encoding of the message.
+func (m *ShareMessage) MarshalBinary() ([]byte, error) { return marshalBinary(*m) }
+
+// UnmarshalBinary parses a message encoded by MarshalBinary.
+func (m *ShareMessage) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, m) }
+
+// MarshalBinary returns the DER encoding of the message.
+func (m *ComplaintMessage) MarshalBinary() ([]byte, error) { return marshalBinary(*m) }
+
+// UnmarshalBinary parses a message encoded by MarshalBinary.
+func (m *ComplaintMessage) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, m) }
+
+// MarshalBinary returns the DER encoding of the message.
+func (m *ResponseMessage) MarshalBinary() ([]byte, error) { return marshalBinary(*m) }
+
+// UnmarshalBinary parses a message encoded by MarshalBinary.
+func (m *ResponseMessage) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, m) }
+
+// MarshalBinary returns the DER encoding of the message.
+func (m *ProductMessage) MarshalBinary() ([]byte, error) { return marshalBinary(*m) }
+
+// UnmarshalBinary parses a message encoded by MarshalBinary.
+func (m *ProductMessage) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, m) }
+
+// MarshalBinary returns the DER encoding of the message.
+func (m *VerificationMessage) MarshalBinary() ([]byte, error) { return marshalBinary(*m) }
+
+// UnmarshalBinary parses a message encoded by MarshalBinary.
+func (m *VerificationMessage) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, m) }
+
+// MarshalBinary returns the DER encoding of the transcript.
+func (t *Transcript) MarshalBinary() ([]byte, error) { return marshalBinary(*t) }
+
+// UnmarshalBinary parses a transcript encoded by MarshalBinary.
+func (t *Transcript) UnmarshalBinary(data []byte) error { return unmarshalBinary(data, t) }
+
+// dealing holds the parsed commitments of a dealer. zero includes the
+// point at infinity for the constant term.
+type dealing struct {
+	key, mask, zero []*Point
+}
+
+// DKG is one party's state in a distributed key generation run.
+type DKG struct {
+	rand      io.Reader
+	tag       []byte
+	index     int
+	threshold int
+	n         int
+
+	keyPoly, maskPoly, zeroPoly []*big.Int
+	sent                        []*ShareMessage
+
+	deals    map[int]*dealing
+	received map[int]*ShareMessage
+	accused  map[int][]int
+
+	keyShare, maskShare, zeroShare *big.Int
+	keyComm, maskComm, zeroComm    []*Point
+	pub                            *sm2.PublicKey
+	mu                             *big.Int
+	w                              *big.Int
+
+	transcript Transcript
+	round      int
+}
+
+func dkgTag(sessionID []byte, threshold, n int) []byte {
+	h := sm3.New()
+	h.Write([]byte("SM2 threshold DKG"))
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(sessionID)))
+	h.Write(b[:])
+	h.Write(sessionID)
+	binary.BigEndian.PutUint32(b[:], uint32(threshold))
+	h.Write(b[:])
+	binary.BigEndian.PutUint32(b[:], uint32(n))
+	h.Write(b[:])
+	return h.Sum(nil)
+}
+
+func allParties(n int) []int {
+	set := make([]int, n)
+	for i := range set {
+		set[i] = i + 1
+	}
+	return set
+}
+
+func marshalPoints(ps []*Point) [][]byte {
+	out := make([][]byte, len(ps))
+	for i, p := range ps {
+		out[i] = p.marshal()
+	}
+	return out
+}
+
+func unmarshalPoints(bs [][]byte, want int) ([]*Point, error) {
+	if len(bs) != want {
+		return nil, errors.New("threshold: wrong number of commitments")
+	}
+	ps := make([]*Point, len(bs))
+	for i, b := range bs {
+		p, err := unmarshalPoint(b)
+		if err != nil {
+			return nil, err
+		}
+		ps[i] = p
+	}
+	return ps, nil
+}
+
+func commitTo(coeffs []*big.Int) []*Point {
+	ps := make([]*Point, len(coeffs))
+	for i, a := range coeffs {
+		ps[i] = baseMul(a)
+	}
+	return ps
+}
+
+// NewDKG starts a DKG run for party index of n with the given threshold. All
+// parties must use the same sessionID, which must not be reused. It returns
+// the round 1 broadcast message and the shares to send to each party,
+// including this one.
+func NewDKG(rand io.Reader, sessionID []byte, index, threshold, n int) (*DKG, *DealMessage, []*ShareMessage, error) {
+	if err := checkShareParams(threshold, n); err != nil {
+		return nil, nil, nil, err
+	}
+	if n < 2*threshold-1 || index < 1 || index > n {
+		return nil, nil, nil, ErrParameters
+	}
+	g := &DKG{
+		rand:      rand,
+		tag:       dkgTag(sessionID, threshold, n),
+		index:     index,
+		threshold: threshold,
+		n:         n,
+	}
+	g.transcript.SessionID = append([]byte(nil), sessionID...)
+	g.transcript.Threshold = threshold
+	g.transcript.Parties = n
+
+	secret, err := randScalar(rand)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if g.keyPoly, err = randPoly(rand, secret, threshold); err != nil {
+		return nil, nil, nil, err
+	}
+	if secret, err = randScalar(rand); err != nil {
+		return nil, nil, nil, err
+	}
+	if g.maskPoly, err = randPoly(rand, secret, threshold); err != nil {
+		return nil, nil, nil, err
+	}
+	if g.zeroPoly, err = randPoly(rand, new(big.Int), 2*threshold-1); err != nil {
+		return nil, nil, nil, err
+	}
+
+	deal := &DealMessage{
+		From: index,
+		Key:  marshalPoints(commitTo(g.keyPoly)),
+		Mask: marshalPoints(commitTo(g.maskPoly)),
+		Zero: marshalPoints(commitTo(g.zeroPoly[1:])),
+	}
+	g.sent = make([]*ShareMessage, n)
+	for j := 1; j <= n; j++ {
+		g.sent[j-1] = &ShareMessage{
+			From: index,
+			To:   j,
+			Key:  evalPoly(g.keyPoly, j),
+			Mask: evalPoly(g.maskPoly, j),
+			Zero: evalPoly(g.zeroPoly, j),
+		}
+	}
+	g.round = 1
+	return g, deal, g.sent, nil
+}
+
+func (g *DKG) parseDeal(m *DealMessage) (*dealing, error) {
+	var d dealing
+	var err error
+	if d.key, err = unmarshalPoints(m.Key, g.threshold); err != nil {
+		return nil, err
+	}
+	if d.mask, err = unmarshalPoints(m.Mask, g.threshold); err != nil {
+		return nil, err
+	}
+	if d.zero, err = unmarshalPoints(m.Zero, 2*g.threshold-2); err != nil {
+		return nil, err
+	}
+	d.zero = append([]*Point{infinity()}, d.zero...)
+	return &d, nil
+}
+
+// validShare reports whether s holds valid shares dealt by d to s.To.
+func validShare(d *dealing, s *ShareMessage) bool {
+	return s.Key != nil && s.Mask != nil && s.Zero != nil &&
+		VerifyFeldmanShare(s.To, s.Key, d.key, nil) &&
+		VerifyFeldmanShare(s.To, s.Mask, d.mask, nil) &&
+		(s.Zero.Sign() >= 0 && s.Zero.Cmp(order()) < 0 && baseMul(s.Zero).equal(EvalCommitments(d.zero, s.To)))
+}
+
+// Round2 processes the round 1 broadcast messages and the shares addressed
+// to this party, and returns this party's complaints.
+func (g *DKG) Round2(deals []*DealMessage, shares []*ShareMessage) (*ComplaintMessage, error) {
+	return g.Round2Context(context.Background(), deals, shares)
+}
+
+// Round2Context is Round2 with a context.
+func (g *DKG) Round2Context(ctx context.Context, deals []*DealMessage, shares []*ShareMessage) (*ComplaintMessage, error) {
+	if g.round != 1 {
+		return nil, errRound
+	}
+	count := make(map[int]int, len(deals))
+	for _, m := range deals {
+		count[m.From]++
+		g.transcript.Deals = append(g.transcript.Deals, *m)
+	}
+	g.deals = make(map[int]*dealing, len(deals))
+	for _, m := range deals {
+		if m.From < 1 || m.From > g.n || count[m.From] != 1 {
+			continue
+		}
+		if d, err := g.parseDeal(m); err == nil {
+			g.deals[m.From] = d
+		}
+	}
+
+	g.received = make(map[int]*ShareMessage, len(g.deals))
+	for _, s := range shares {
+		if err := g.checkContext(ctx); err != nil {
+			return nil, err
+		}
+		d := g.deals[s.From]
+		if s.To != g.index || d == nil || g.received[s.From] != nil {
+			continue
+		}
+		if validShare(d, s) {
+			g.received[s.From] = s
+		}
+	}
+	c := &ComplaintMessage{From: g.index}
+	for _, i := range allParties(g.n) {
+		if g.deals[i] != nil && g.received[i] == nil {
+			c.Against = append(c.Against, i)
+		}
+	}
+	g.round = 2
+	return c, nil
+}
+
+// Round3 processes the complaints of all parties and returns this party's
+// answer to the complaints against it.
+func (g *DKG) Round3(complaints []*ComplaintMessage) (*ResponseMessage, error) {
+	if g.round != 2 {
+		return nil, errRound
+	}
+	g.accused = make(map[int][]int)
+	seen := make(map[[2]int]bool)
+	for _, c := range complaints {
+		g.transcript.Complaints = append(g.transcript.Complaints, *c)
+		if c.From < 1 || c.From > g.n {
+			continue
+		}
+		for _, i := range c.Against {
+			if g.deals[i] == nil || seen[[2]int{i, c.From}] {
+				continue
+			}
+			seen[[2]int{i, c.From}] = true
+			g.accused[i] = append(g.accused[i], c.From)
+		}
+	}
+	r := &ResponseMessage{From: g.index}
+	for _, j := range g.accused[g.index] {
+		r.Shares = append(r.Shares, *g.sent[j-1])
+	}
+	g.round = 3
+	return r, nil
+}
+
+// Round4 processes the answers to the complaints, fixes the set of
+// qualified dealers and the group public key, and returns this party's
+// product share.
+func (g *DKG) Round4(responses []*ResponseMessage) (*ProductMessage, error) {
+	return g.Round4Context(context.Background(), responses)
+}
+
+// Round4Context is Round4 with a context.
+func (g *DKG) Round4Context(ctx context.Context, responses []*ResponseMessage) (*ProductMessage, error) {
+	if g.round != 3 {
+		return nil, errRound
+	}
+	answers := make(map[int][]ShareMessage)
+	count := make(map[int]int)
+	for _, r := range responses {
+		g.transcript.Responses = append(g.transcript.Responses, *r)
+		count[r.From]++
+		answers[r.From] = r.Shares
+	}
+	var qual []int
+	for _, i := range allParties(g.n) {
+		if err := g.checkContext(ctx); err != nil {
+			return nil, err
+		}
+		d := g.deals[i]
+		if d == nil {
+			continue
+		}
+		ok := true
+		for _, j := range g.accused[i] {
+			var revealed *ShareMessage
+			if count[i] == 1 {
+				for k := range answers[i] {
+					if s := &answers[i][k]; s.From == i && s.To == j {
+						revealed = s
+						break
+					}
+				}
+			}
+			if revealed == nil || !validShare(d, revealed) {
+				ok = false
+				break
+			}
+			if j == g.index {
+				g.received[i] = revealed
+			}
+		}
+		if ok {
+			qual = append(qual, i)
+		}
+	}
+	if len(qual) == 0 {
+		return nil, errors.New("threshold: no qualified dealers")
+	}
+
+	n := order()
+	g.keyShare, g.maskShare, g.zeroShare = new(big.Int), new(big.Int), new(big.Int)
+	g.keyComm = make([]*Point, g.threshold)
+	g.maskComm = make([]*Point, g.threshold)
+	g.zeroComm = make([]*Point, 2*g.threshold-1)
+	for _, ps := range [][]*Point{g.keyComm, g.maskComm, g.zeroComm} {
+		for k := range ps {
+			ps[k] = infinity()
+		}
+	}
+	for _, i := range qual {
+		s, d := g.received[i], g.deals[i]
+		g.keyShare.Add(g.keyShare, s.Key)
+		g.maskShare.Add(g.maskShare, s.Mask)
+		g.zeroShare.Add(g.zeroShare, s.Zero)
+		for k := range g.keyComm {
+			g.keyComm[k] = g.keyComm[k].add(d.key[k])
+			g.maskComm[k] = g.maskComm[k].add(d.mask[k])
+		}
+		for k := range g.zeroComm {
+			g.zeroComm[k] = g.zeroComm[k].add(d.zero[k])
+		}
+	}
+	g.keyShare.Mod(g.keyShare, n)
+	g.maskShare.Mod(g.maskShare, n)
+	g.zeroShare.Mod(g.zeroShare, n)
+	g.keyPoly, g.maskPoly, g.zeroPoly, g.sent = nil, nil, nil, nil
+
+	p := g.keyComm[0]
+	if p.isInfinity() || p.add(Generator()).isInfinity() {
+		return nil, ErrRetry
+	}
+	g.pub = &sm2.PublicKey{Curve: curve(), X: p.X, Y: p.Y}
+
+	// m_j = u_j(1+d_j) + z_j, with a proof that m_j·G - z_j·G = (1+d_j)·u_j·G
+	// for the same 1+d_j as in (1+d_j)·G.
+	x := new(big.Int).Add(g.keyShare, big.NewInt(1))
+	m := new(big.Int).Mul(g.maskShare, x)
+	m.Add(m, g.zeroShare)
+	m.Mod(m, n)
+	a, b2, c := g.productStatement(g.index, m)
+	proof, err := proveDLEQ(g.rand, g.tag, g.index, x, Generator(), a, b2, c)
+	if err != nil {
+		return nil, err
+	}
+	g.round = 4
+	return &ProductMessage{From: g.index, Product: m, Proof: *proof}, nil
+}
+
+// productStatement returns the points (1+d_j)·G, u_j·G and m·G - z_j·G of
+// the product proof of party j.
+func (g *DKG) productStatement(j int, m *big.Int) (a, b2, c *Point) {
+	a = EvalCommitments(g.keyComm, j).add(Generator())
+	b2 = EvalCommitments(g.maskComm, j)
+	c = baseMul(m).add(EvalCommitments(g.zeroComm, j).neg())
+	return a, b2, c
+}
+
+// Round5 processes the product shares of all parties and returns this
+// party's verification share.
+func (g *DKG) Round5(products []*ProductMessage) (*VerificationMessage, error) {
+	return g.Round5Context(context.Background(), products)
+}
+
+// Round5Context is Round5 with a context.
+func (g *DKG) Round5Context(ctx context.Context, products []*ProductMessage) (*VerificationMessage, error) {
+	if g.round != 4 {
+		return nil, errRound
+	}
+	from := make([]int, len(products))
+	for i, m := range products {
+		from[i] = m.From
+		g.transcript.Products = append(g.transcript.Products, *m)
+	}
+	parties := allParties(g.n)
+	if err := checkSenders(from, parties); err != nil {
+		return nil, err
+	}
+	n := order()
+	values := make(map[int]*big.Int, len(products))
+	var bad []int
+	for _, m := range products {
+		if err := g.checkContext(ctx); err != nil {
+			return nil, err
+		}
+		if m.Product == nil || m.Product.Sign() < 0 || m.Product.Cmp(n) >= 0 {
+			bad = append(bad, m.From)
+			continue
+		}
+		a, b2, c := g.productStatement(m.From, m.Product)
+		if !verifyDLEQ(&m.Proof, g.tag, m.From, Generator(), a, b2, c) {
+			bad = append(bad, m.From)
+			continue
+		}
+		values[m.From] = m.Product
+	}
+	if len(bad) > 0 {
+		sort.Ints(bad)
+		return nil, &AbortError{bad, "invalid product share"}
+	}
+	g.mu = Interpolate(values)
+	if g.mu.Sign() == 0 {
+		return nil, ErrRetry
+	}
+
+	muInv := new(big.Int).ModInverse(g.mu, n)
+	g.w = new(big.Int).Mul(g.maskShare, muInv)
+	g.w.Mod(g.w, n)
+	h := base(g.pub)
+	y := h.mul(g.w)
+	proof, err := proveDLEQ(g.rand, g.tag, g.index, g.w, Generator(), baseMul(g.w), h, y)
+	if err != nil {
+		return nil, err
+	}
+	g.round = 5
+	return &VerificationMessage{From: g.index, Share: y.marshal(), Proof: *proof}, nil
+}
+
+// Finalize processes the verification shares of all parties and returns
+// this party's key share.
+func (g *DKG) Finalize(vs []*VerificationMessage) (*KeyShare, error) {
+	return g.FinalizeContext(context.Background(), vs)
+}
+
+// FinalizeContext is Finalize with a context.
+func (g *DKG) FinalizeContext(ctx context.Context, vs []*VerificationMessage) (*KeyShare, error) {
+	if g.round != 5 {
+		return nil, errRound
+	}
+	from := make([]int, len(vs))
+	for i, m := range vs {
+		from[i] = m.From
+		g.transcript.Verifications = append(g.transcript.Verifications, *m)
+	}
+	parties := allParties(g.n)
+	if err := checkSenders(from, parties); err != nil {
+		return nil, err
+	}
+	n := order()
+	muInv := new(big.Int).ModInverse(g.mu, n)
+	h := base(g.pub)
+	ys := make(map[int]*Point, len(vs))
+	var bad []int
+	for _, m := range vs {
+		if err := g.checkContext(ctx); err != nil {
+			return nil, err
+		}
+		y, err := unmarshalPoint(m.Share)
+		// w_j·G = u_j·G / μ
+		if err != nil || !verifyDLEQ(&m.Proof, g.tag, m.From, Generator(), EvalCommitments(g.maskComm, m.From).mul(muInv), h, y) {
+			bad = append(bad, m.From)
+			continue
+		}
+		ys[m.From] = y
+	}
+	if len(bad) > 0 {
+		sort.Ints(bad)
+		return nil, &AbortError{bad, "invalid verification share"}
+	}
+
+	commitments := interpolateCommitments(ys, parties[:g.threshold])
+	key := &KeyShare{
+		Index:              g.index,
+		Threshold:          g.threshold,
+		Share:              g.w,
+		PublicKey:          g.pub,
+		Commitments:        commitments,
+		VerificationShares: ys,
+	}
+	if err := key.Verify(); err != nil {
+		return nil, err
+	}
+	g.round = 6
+	return key, nil
+}
+
+// checkContext returns ctx.Err() and aborts the run if ctx is done.
+func (g *DKG) checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		g.round = 0
+		return err
+	}
+	return nil
+}
+
+// Transcript returns the broadcast messages processed so far.
+func (g *DKG) Transcript() *Transcript {
+	t := g.transcript
+	return &t
+}
+
+// interpolateCommitments returns the commitments to the coefficients of the
+// polynomial of degree len(set)-1 through the points ys[j] for j in set.
+func interpolateCommitments(ys map[int]*Point, set []int) []*Point {
+	n := order()
+	out := make([]*Point, len(set))
+	for k := range out {
+		out[k] = infinity()
+	}
+	for _, j := range set {
+		// Coefficients of the Lagrange basis polynomial of j.
+		basis := []*big.Int{big.NewInt(1)}
+		den := big.NewInt(1)
+		for _, m := range set {
+			if m == j {
+				continue
+			}
+			// basis *= (x - m)
+			next := make([]*big.Int, len(basis)+1)
+			for k := range next {
+				next[k] = new(big.Int)
+			}
+			for k, c := range basis {
+				next[k+1].Add(next[k+1], c)
+				t := new(big.Int).Mul(c, big.NewInt(int64(m)))
+				next[k].Sub(next[k], t)
+			}
+			for _, c := range next {
+				c.Mod(c, n)
+			}
+			basis = next
+			den.Mul(den, big.NewInt(int64(j-m)))
+			den.Mod(den, n)
+		}
+		den.ModInverse(den, n)
+		for k, c := range basis {
+			c.Mul(c, den).Mod(c, n)
+			out[k] = out[k].add(ys[j].mul(c))
+		}
+	}
+	return out
+}
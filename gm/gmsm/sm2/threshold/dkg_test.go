@@ -0,0 +1,215 @@
+package threshold
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// dkgHooks lets tests misbehave at the various rounds of a DKG run.
+type dkgHooks struct {
+	shares    func([]*ShareMessage)
+	responses func([]*ResponseMessage)
+	products  func([]*ProductMessage)
+}
+
+func runDKG(t *testing.T, threshold, n int, hooks dkgHooks) ([]*KeyShare, []*DKG, error) {
+	parties := make([]*DKG, n)
+	deals := make([]*DealMessage, n)
+	var shares []*ShareMessage
+	for i := range parties {
+		var s []*ShareMessage
+		var err error
+		parties[i], deals[i], s, err = NewDKG(rand.Reader, []byte("dkg"), i+1, threshold, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares = append(shares, s...)
+	}
+	if hooks.shares != nil {
+		hooks.shares(shares)
+	}
+
+	complaints := make([]*ComplaintMessage, n)
+	for i, p := range parties {
+		var mine []*ShareMessage
+		for _, s := range shares {
+			if s.To == i+1 {
+				mine = append(mine, s)
+			}
+		}
+		var err error
+		if complaints[i], err = p.Round2(deals, mine); err != nil {
+			t.Fatal(err)
+		}
+	}
+	responses := make([]*ResponseMessage, n)
+	for i, p := range parties {
+		var err error
+		if responses[i], err = p.Round3(complaints); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hooks.responses != nil {
+		hooks.responses(responses)
+	}
+	products := make([]*ProductMessage, n)
+	for i, p := range parties {
+		var err error
+		if products[i], err = p.Round4(responses); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hooks.products != nil {
+		hooks.products(products)
+	}
+	vs := make([]*VerificationMessage, n)
+	for i, p := range parties {
+		var err error
+		if vs[i], err = p.Round5(products); err != nil {
+			return nil, nil, err
+		}
+	}
+	keys := make([]*KeyShare, n)
+	for i, p := range parties {
+		var err error
+		if keys[i], err = p.Finalize(vs); err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, parties, nil
+}
+
+func checkGroupKey(t *testing.T, keys []*KeyShare, signers []int) {
+	for _, k := range keys[1:] {
+		if k.PublicKey.X.Cmp(keys[0].PublicKey.X) != 0 || k.PublicKey.Y.Cmp(keys[0].PublicKey.Y) != 0 {
+			t.Fatal("parties disagree on the group public key")
+		}
+	}
+	msg := []byte("signed with a DKG key")
+	r, s, err := runSigning(t, keys, signers, msg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.Sm2Verify(keys[0].PublicKey, msg, uid, r, s) {
+		t.Fatal("signature does not verify")
+	}
+}
+
+func TestDKG(t *testing.T) {
+	for _, p := range []struct{ threshold, n int }{{1, 1}, {2, 3}, {3, 5}} {
+		keys, _, err := runDKG(t, p.threshold, p.n, dkgHooks{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkGroupKey(t, keys, allParties(p.n)[p.n-p.threshold:])
+	}
+	if _, _, _, err := NewDKG(rand.Reader, nil, 1, 3, 4); err != ErrParameters {
+		t.Fatalf("NewDKG with n < 2t-1: %v", err)
+	}
+}
+
+func TestDKGContext(t *testing.T) {
+	g, deal, shares, err := NewDKG(rand.Reader, []byte("dkg"), 1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.Round2Context(ctx, []*DealMessage{deal}, shares); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := g.Round3(nil); err != errRound {
+		t.Fatalf("a canceled DKG went on: %v", err)
+	}
+}
+
+func TestDKGComplaints(t *testing.T) {
+	keys, parties, err := runDKG(t, 2, 4, dkgHooks{
+		shares: func(shares []*ShareMessage) {
+			for i, s := range shares {
+				// Dealer 1 sends a bad share to party 2 but answers the
+				// complaint honestly; dealer 3 sends a bad share to party 4.
+				if (s.From == 1 && s.To == 2) || (s.From == 3 && s.To == 4) {
+					c := *s
+					c.Key = new(big.Int).Add(s.Key, big.NewInt(1))
+					shares[i] = &c
+				}
+			}
+		},
+		responses: func(responses []*ResponseMessage) {
+			// Dealer 3 does not answer.
+			responses[2].Shares = nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGroupKey(t, keys, []int{2, 4})
+
+	// Dealer 3 was disqualified, so P is the sum of the other dealers'
+	// contributions.
+	tr := parties[0].Transcript()
+	p := infinity()
+	for _, d := range tr.Deals {
+		if d.From != 3 {
+			c, _ := unmarshalPoint(d.Key[0])
+			p = p.add(c)
+		}
+	}
+	if p.X.Cmp(keys[0].PublicKey.X) != 0 {
+		t.Fatal("disqualified dealer contributed to the group key")
+	}
+	if len(tr.Complaints[1].Against) != 1 || tr.Complaints[1].Against[0] != 1 {
+		t.Fatalf("party 2 complaints %v, want [1]", tr.Complaints[1].Against)
+	}
+}
+
+func TestDKGAbort(t *testing.T) {
+	_, _, err := runDKG(t, 2, 3, dkgHooks{
+		products: func(products []*ProductMessage) {
+			products[1].Product = new(big.Int).Add(products[1].Product, big.NewInt(1))
+		},
+	})
+	abort, ok := err.(*AbortError)
+	if !ok || len(abort.Culprits) != 1 || abort.Culprits[0] != 2 {
+		t.Fatalf("got error %v, want abort blaming party 2", err)
+	}
+}
+
+func TestDKGTranscript(t *testing.T) {
+	_, parties, err := runDKG(t, 2, 3, dkgHooks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := parties[1].Transcript()
+	b, err := tr.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Transcript
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	b2, _ := got.MarshalBinary()
+	if !bytes.Equal(b, b2) || len(got.Verifications) != 3 || got.Threshold != 2 {
+		t.Fatal("transcript round trip failed")
+	}
+	if err := got.UnmarshalBinary(append(b, 0)); err == nil {
+		t.Fatal("trailing data accepted")
+	}
+
+	m := tr.Products[0]
+	b, err = m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pm ProductMessage
+	if err := pm.UnmarshalBinary(b); err != nil || pm.From != m.From || pm.Product.Cmp(m.Product) != 0 || pm.Proof.Z.Cmp(m.Proof.Z) != 0 {
+		t.Fatal("product message round trip failed")
+	}
+}
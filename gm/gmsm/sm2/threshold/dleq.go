@@ -0,0 +1,54 @@
+package threshold
+
+import (
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// DLEQProof is a non-interactive Chaum-Pedersen proof that A = x·B1 and
+// C = x·B2 for the same secret x.
+type DLEQProof struct {
+	C, Z *big.Int
+}
+
+func dleqChallenge(tag []byte, from int, b1, a, b2, c, t1, t2 *Point) *big.Int {
+	h := sm3.New()
+	h.Write([]byte("SM2 threshold DLEQ"))
+	h.Write(tag)
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(from))
+	h.Write(b[:])
+	for _, p := range []*Point{b1, a, b2, c, t1, t2} {
+		h.Write(p.fixedBytes())
+	}
+	e := new(big.Int).SetBytes(h.Sum(nil))
+	return e.Mod(e, order())
+}
+
+// proveDLEQ proves that a = x·b1 and c = x·b2, binding the proof to tag and
+// the prover's index.
+func proveDLEQ(rand io.Reader, tag []byte, from int, x *big.Int, b1, a, b2, c *Point) (*DLEQProof, error) {
+	k, err := randScalar(rand)
+	if err != nil {
+		return nil, err
+	}
+	e := dleqChallenge(tag, from, b1, a, b2, c, b1.mul(k), b2.mul(k))
+	z := new(big.Int).Mul(e, x)
+	z.Add(z, k)
+	z.Mod(z, order())
+	return &DLEQProof{C: e, Z: z}, nil
+}
+
+func verifyDLEQ(p *DLEQProof, tag []byte, from int, b1, a, b2, c *Point) bool {
+	n := order()
+	if p == nil || p.C == nil || p.Z == nil || p.C.Sign() < 0 || p.C.Cmp(n) >= 0 || p.Z.Sign() < 0 || p.Z.Cmp(n) >= 0 {
+		return false
+	}
+	negC := new(big.Int).Sub(n, p.C)
+	t1 := b1.mul(p.Z).add(a.mul(negC))
+	t2 := b2.mul(p.Z).add(c.mul(negC))
+	return dleqChallenge(tag, from, b1, a, b2, c, t1, t2).Cmp(p.C) == 0
+}
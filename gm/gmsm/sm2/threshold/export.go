@@ -0,0 +1,277 @@
+package threshold
+
+import (
+	"crypto/cipher"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/xuperchain/crypto/gm/gmsm/scryptsm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+// KeySharePEMType is the PEM block type of an exported key share.
+const KeySharePEMType = "SM2 THRESHOLD KEY SHARE"
+
+// ShareFileVersion is the version of the share file format written by
+// KeyShare.Export.
+const ShareFileVersion = 1
+
+// scrypt parameters for new exports. Imports use the parameters stored in
+// the exported data.
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const (
+	saltSize = 16
+	// maxScryptN bounds the work an import can be made to do.
+	maxScryptN = 1 << 20
+)
+
+var (
+	// ErrPassword is returned when an exported share cannot be decrypted,
+	// typically because of a wrong password.
+	ErrPassword = errors.New("threshold: wrong password or corrupted data")
+	// ErrChecksum is returned when the metadata of an exported share does
+	// not match its checksum.
+	ErrChecksum = errors.New("threshold: share file checksum mismatch")
+)
+
+// The share file format is a PEM block of type KeySharePEMType holding
+//
+//	ShareFile ::= SEQUENCE {
+//		info     ShareInfo,
+//		checksum OCTET STRING, -- SM3(DER(info))
+//		salt     OCTET STRING,
+//		n, r, p  INTEGER,      -- scrypt-SM3 parameters
+//		nonce    OCTET STRING,
+//		data     OCTET STRING } -- SM4-GCM sealed share, DER(info) as AAD
+//
+//	ShareInfo ::= SEQUENCE {
+//		version            INTEGER,
+//		index              INTEGER,
+//		threshold          INTEGER,
+//		publicKey          OCTET STRING, -- uncompressed point
+//		commitments        SEQUENCE OF OCTET STRING,
+//		parties            SEQUENCE OF INTEGER,
+//		verificationShares SEQUENCE OF OCTET STRING } -- in the order of parties
+//
+// Only the share itself is encrypted. The metadata can be read without the
+// password with ParseShareInfo, and the checksum lets tools detect a
+// corrupted file before asking for one.
+
+type shareInfoASN1 struct {
+	Version            int
+	Index              int
+	Threshold          int
+	PublicKey          []byte
+	Commitments        [][]byte
+	Parties            []int
+	VerificationShares [][]byte
+}
+
+type shareFileASN1 struct {
+	Info     asn1.RawValue
+	Checksum []byte
+	Salt     []byte
+	N        int
+	R        int
+	P        int
+	Nonce    []byte
+	Data     []byte
+}
+
+// ShareInfo is the public metadata of an exported key share.
+type ShareInfo struct {
+	Version   int
+	Index     int
+	Threshold int
+	PublicKey *sm2.PublicKey
+	// Parties lists the indices of all parties holding a share of the key.
+	Parties []int
+}
+
+func newAEAD(password, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scryptsm3.Key(password, salt, n, r, p, sm4.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Export returns the key share as a PEM block in the share file format, with
+// the share encrypted under password.
+func (k *KeyShare) Export(rand io.Reader, password []byte) ([]byte, error) {
+	if err := k.Verify(); err != nil {
+		return nil, err
+	}
+	info := shareInfoASN1{
+		Version:   ShareFileVersion,
+		Index:     k.Index,
+		Threshold: k.Threshold,
+		PublicKey: elliptic.Marshal(curve(), k.PublicKey.X, k.PublicKey.Y),
+	}
+	for _, c := range k.Commitments {
+		info.Commitments = append(info.Commitments, c.marshal())
+	}
+	for j := range k.VerificationShares {
+		info.Parties = append(info.Parties, j)
+	}
+	sort.Ints(info.Parties)
+	for _, j := range info.Parties {
+		info.VerificationShares = append(info.VerificationShares, k.VerificationShares[j].marshal())
+	}
+	der, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(password, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand, nonce); err != nil {
+		return nil, err
+	}
+	share := make([]byte, 32)
+	b := k.Share.Bytes()
+	copy(share[32-len(b):], b)
+
+	out, err := asn1.Marshal(shareFileASN1{
+		Info:     asn1.RawValue{FullBytes: der},
+		Checksum: sm3.Sm3Sum(der),
+		Salt:     salt,
+		N:        scryptN,
+		R:        scryptR,
+		P:        scryptP,
+		Nonce:    nonce,
+		Data:     aead.Seal(nil, nonce, share, der),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: KeySharePEMType, Bytes: out}), nil
+}
+
+// parseShareFile decodes a share file and checks its checksum and version.
+func parseShareFile(data []byte) (*shareFileASN1, *shareInfoASN1, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != KeySharePEMType {
+		return nil, nil, errors.New("threshold: expected PEM block of type " + KeySharePEMType)
+	}
+	var f shareFileASN1
+	if err := unmarshalBinary(block.Bytes, &f); err != nil {
+		return nil, nil, err
+	}
+	if string(sm3.Sm3Sum(f.Info.FullBytes)) != string(f.Checksum) {
+		return nil, nil, ErrChecksum
+	}
+	var info shareInfoASN1
+	if err := unmarshalBinary(f.Info.FullBytes, &info); err != nil {
+		return nil, nil, err
+	}
+	if info.Version != ShareFileVersion {
+		return nil, nil, errors.New("threshold: unsupported share file version")
+	}
+	if len(info.Parties) != len(info.VerificationShares) {
+		return nil, nil, errors.New("threshold: malformed share file")
+	}
+	return &f, &info, nil
+}
+
+func parsePublicKey(b []byte) (*sm2.PublicKey, error) {
+	p, err := unmarshalPoint(b)
+	if err != nil {
+		return nil, err
+	}
+	return &sm2.PublicKey{Curve: curve(), X: p.X, Y: p.Y}, nil
+}
+
+// ParseShareInfo returns the metadata of an exported key share without
+// decrypting it.
+func ParseShareInfo(data []byte) (*ShareInfo, error) {
+	_, info, err := parseShareFile(data)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parsePublicKey(info.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &ShareInfo{
+		Version:   info.Version,
+		Index:     info.Index,
+		Threshold: info.Threshold,
+		PublicKey: pub,
+		Parties:   info.Parties,
+	}, nil
+}
+
+// ImportKeyShare restores a key share from the output of KeyShare.Export and
+// verifies it.
+func ImportKeyShare(data, password []byte) (*KeyShare, error) {
+	f, info, err := parseShareFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.N > maxScryptN || f.R <= 0 || f.P <= 0 || f.R*f.P >= 1<<20 {
+		return nil, errors.New("threshold: unsupported scrypt parameters")
+	}
+	aead, err := newAEAD(password, f.Salt, f.N, f.R, f.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != aead.NonceSize() {
+		return nil, ErrPassword
+	}
+	share, err := aead.Open(nil, f.Nonce, f.Data, f.Info.FullBytes)
+	if err != nil {
+		return nil, ErrPassword
+	}
+
+	k := &KeyShare{
+		Index:              info.Index,
+		Threshold:          info.Threshold,
+		Share:              new(big.Int).SetBytes(share),
+		VerificationShares: make(map[int]*Point, len(info.Parties)),
+	}
+	if k.PublicKey, err = parsePublicKey(info.PublicKey); err != nil {
+		return nil, err
+	}
+	for _, b := range info.Commitments {
+		c, err := unmarshalPoint(b)
+		if err != nil {
+			return nil, err
+		}
+		k.Commitments = append(k.Commitments, c)
+	}
+	for i, j := range info.Parties {
+		y, err := unmarshalPoint(info.VerificationShares[i])
+		if err != nil {
+			return nil, err
+		}
+		k.VerificationShares[j] = y
+	}
+	if err := k.Verify(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
@@ -0,0 +1,70 @@
+package threshold
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/pem"
+	"reflect"
+	"testing"
+)
+
+func init() {
+	// Keep the tests fast.
+	scryptN = 1 << 10
+}
+
+func TestExportKeyShare(t *testing.T) {
+	shares := dealShares(t, 2, 3)
+	password := []byte("correct horse")
+	data, err := shares[1].Export(rand.Reader, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ParseShareInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != ShareFileVersion || info.Index != 2 || info.Threshold != 2 ||
+		!reflect.DeepEqual(info.Parties, []int{1, 2, 3}) ||
+		info.PublicKey.X.Cmp(shares[1].PublicKey.X) != 0 || info.PublicKey.Y.Cmp(shares[1].PublicKey.Y) != 0 {
+		t.Fatalf("unexpected share info %+v", info)
+	}
+
+	k, err := ImportKeyShare(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Index != 2 || k.Share.Cmp(shares[1].Share) != 0 || len(k.VerificationShares) != 3 {
+		t.Fatal("imported share does not match")
+	}
+	if _, _, err := runSigning(t, []*KeyShare{shares[0], k, shares[2]}, []int{1, 2}, []byte("msg"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportKeyShare(data, []byte("wrong")); err != ErrPassword {
+		t.Fatalf("wrong password: got %v, want ErrPassword", err)
+	}
+}
+
+func TestExportKeyShareCorrupted(t *testing.T) {
+	shares := dealShares(t, 2, 3)
+	data, err := shares[0].Export(rand.Reader, []byte("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	// Flip a bit of the threshold in the metadata.
+	i := bytes.Index(block.Bytes, []byte{2, 1, 1, 2, 1, 2})
+	if i < 0 {
+		t.Fatal("metadata not found")
+	}
+	block.Bytes[i+5] ^= 1
+	bad := pem.EncodeToMemory(block)
+	if _, err := ParseShareInfo(bad); err != ErrChecksum {
+		t.Fatalf("ParseShareInfo: got %v, want ErrChecksum", err)
+	}
+	if _, err := ImportKeyShare(bad, []byte("pw")); err != ErrChecksum {
+		t.Fatalf("ImportKeyShare: got %v, want ErrChecksum", err)
+	}
+}
@@ -0,0 +1,83 @@
+package threshold
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrPresignatureUsed is returned when a presignature is used a second time.
+var ErrPresignatureUsed = errors.New("threshold: presignature already used")
+
+// Signing can be split into an offline phase, run before the message is
+// known, and an online phase of a single broadcast round:
+//
+//	offline: NewPresignSession, Round2, Presign (rounds 1 and 2 above)
+//	online:  Presignature.Sign, Finalize (round 3 above)
+//
+// A presignature fixes the nonce point R and the signer set, and yields
+// exactly one signature. Signing two messages with the same nonce reveals the
+// key shares, so a presignature must never be copied, persisted and restored,
+// or otherwise used twice, and all signers must use the same presignature
+// for the same message.
+
+// Presignature is one party's precomputed nonce material for a single
+// signature.
+type Presignature struct {
+	// ID is the presignID the presignature was created with.
+	ID []byte
+
+	s *Session
+}
+
+// NewPresignSession starts the offline phase of a signing session. All
+// signers must use the same presignID and signer set; presignID must not be
+// reused, and must differ from any sessionID passed to NewSession.
+func NewPresignSession(rand io.Reader, key *KeyShare, presignID []byte, signers []int) (*Session, *Round1Message, error) {
+	return newSession(rand, key, presignID, signers, nil)
+}
+
+// Presign processes the round 2 messages of all signers of a presigning
+// session and returns this party's presignature.
+func (s *Session) Presign(msgs []*Round2Message) (*Presignature, error) {
+	if s.round != 2 || s.e != nil {
+		return nil, errRound
+	}
+	if err := s.collectNonces(msgs); err != nil {
+		return nil, err
+	}
+	// The session is only reachable through the presignature until Sign
+	// moves it to round 3.
+	s.round = 0
+	return &Presignature{ID: s.id, s: s}, nil
+}
+
+// Signers returns the signer set of the presignature.
+func (p *Presignature) Signers() []int {
+	if p.s == nil {
+		return nil
+	}
+	return append([]int(nil), p.s.signers...)
+}
+
+// Sign uses the presignature for the SM2 signature of msg by the user uid.
+// It returns the session to pass the signature shares of all signers to,
+// with Finalize, and this party's share. The presignature cannot be used
+// again.
+func (p *Presignature) Sign(msg, uid []byte) (*Session, *Round3Message, error) {
+	s := p.s
+	if s == nil {
+		return nil, nil, ErrPresignatureUsed
+	}
+	p.s = nil
+	e, err := msgDigest(s.key.PublicKey, msg, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.e = e
+	m, err := s.share()
+	if err != nil {
+		s.gamma = nil
+		return nil, nil, err
+	}
+	return s, m, nil
+}
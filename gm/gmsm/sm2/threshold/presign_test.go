@@ -0,0 +1,113 @@
+package threshold
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func presign(t testing.TB, shares []*KeyShare, signers []int) []*Presignature {
+	sessions := make([]*Session, len(signers))
+	r1 := make([]*Round1Message, len(signers))
+	var err error
+	for i, j := range signers {
+		sessions[i], r1[i], err = NewPresignSession(rand.Reader, shares[j-1], []byte("presign"), signers)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r2 := make([]*Round2Message, len(signers))
+	for i, sess := range sessions {
+		if r2[i], err = sess.Round2(r1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pre := make([]*Presignature, len(signers))
+	for i, sess := range sessions {
+		if pre[i], err = sess.Presign(r2); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := sess.Finalize(nil); err != errRound {
+			t.Fatalf("Finalize before Sign: got %v, want errRound", err)
+		}
+	}
+	return pre
+}
+
+func TestPresign(t *testing.T) {
+	shares := dealShares(t, 2, 3)
+	signers := []int{1, 3}
+	pre := presign(t, shares, signers)
+	msg := []byte("message known only online")
+
+	sessions := make([]*Session, len(pre))
+	r3 := make([]*Round3Message, len(pre))
+	var err error
+	for i, p := range pre {
+		if sessions[i], r3[i], err = p.Sign(msg, uid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sess := range sessions {
+		r, s, err := sess.Finalize(r3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.Sm2Verify(shares[0].PublicKey, msg, uid, r, s) {
+			t.Fatal("signature does not verify")
+		}
+	}
+
+	if _, _, err := pre[0].Sign([]byte("another message"), uid); err != ErrPresignatureUsed {
+		t.Fatalf("reused presignature: got %v, want ErrPresignatureUsed", err)
+	}
+}
+
+func TestPresignAbort(t *testing.T) {
+	shares := dealShares(t, 2, 3)
+	pre := presign(t, shares, []int{2, 3})
+	msg := []byte("message")
+
+	sess, m2, err := pre[0].Sign(msg, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signer 3 signs a different message with its presignature.
+	_, m3, err := pre[1].Sign([]byte("other message"), uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = sess.Finalize([]*Round3Message{m2, m3})
+	ae, ok := err.(*AbortError)
+	if !ok || len(ae.Culprits) != 1 || ae.Culprits[0] != 3 {
+		t.Fatalf("got %v, want abort blaming signer 3", err)
+	}
+}
+
+func BenchmarkPresignedSign3of5(b *testing.B) {
+	priv, _ := sm2.GenerateKey()
+	shares, _ := Deal(rand.Reader, priv, 3, 5)
+	signers := []int{1, 2, 3}
+	msg := []byte("benchmark")
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		pre := presign(b, shares, signers)
+		b.StartTimer()
+		r3 := make([]*Round3Message, len(pre))
+		var sess *Session
+		for j, p := range pre {
+			s, m, err := p.Sign(msg, uid)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if j == 0 {
+				sess = s
+			}
+			r3[j] = m
+		}
+		if _, _, err := sess.Finalize(r3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package threshold
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// ErrRetry is returned when a signing session produced a degenerate
+// signature, which happens with negligible probability. Signing must be
+// restarted with a new session.
+var ErrRetry = errors.New("threshold: degenerate signature, retry with a new session")
+
+var errRound = errors.New("threshold: signing round called out of order")
+
+// The signing protocol has three broadcast rounds with no coordinator: every
+// signer sends its message of a round to all signers, including itself, and
+// processes the complete set before moving to the next round.
+//
+//  1. Commit to the nonce point Γ_i.
+//  2. Reveal Γ_i; everyone checks it against the commitment and computes
+//     R = ΣΓ_i and r = (e + R.x) mod n.
+//  3. Send s_i = γ_i + r·λ_i·w_i; everyone checks s_i·H = Γ_i + r·λ_i·Y_i and
+//     computes s = Σs_i - r.
+
+// Round1Message carries a signer's commitment to its nonce point.
+type Round1Message struct {
+	From       int
+	Commitment []byte
+}
+
+// Round2Message carries a signer's nonce point Γ_i in uncompressed form.
+type Round2Message struct {
+	From  int
+	Gamma []byte
+}
+
+// Round3Message carries a signer's signature share.
+type Round3Message struct {
+	From int
+	S    *big.Int
+}
+
+// Session is one party's state in a threshold signing session. A Session
+// must be used for a single signature only.
+type Session struct {
+	key     *KeyShare
+	id      []byte
+	signers []int
+	e       *big.Int
+	tag     []byte
+	h       *Point
+
+	gamma       *big.Int
+	commitments map[int][]byte
+	gammas      map[int]*Point
+	nonce       *Point
+	r           *big.Int
+	round       int
+}
+
+// msgDigest returns e = SM3(ZA || msg) for the SM2 signature of msg by uid.
+func msgDigest(pub *sm2.PublicKey, msg, uid []byte) (*big.Int, error) {
+	za, err := sm2.ZA(pub, uid)
+	if err != nil {
+		return nil, err
+	}
+	h := sm3.New()
+	h.Write(za)
+	h.Write(msg)
+	return new(big.Int).SetBytes(h.Sum(nil)), nil
+}
+
+// transcriptTag binds the commitments of a session to its identifier, signer
+// set and message. e is nil for a presigning session, whose message is not
+// known yet.
+func transcriptTag(sessionID []byte, signers []int, e *big.Int) []byte {
+	h := sm3.New()
+	if e == nil {
+		h.Write([]byte("SM2 threshold presigning"))
+	} else {
+		h.Write([]byte("SM2 threshold signing"))
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(len(sessionID)))
+	h.Write(b[:])
+	h.Write(sessionID)
+	for _, i := range signers {
+		binary.BigEndian.PutUint32(b[:], uint32(i))
+		h.Write(b[:])
+	}
+	if e != nil {
+		h.Write(e.Bytes())
+	}
+	return h.Sum(nil)
+}
+
+func commitment(tag []byte, from int, gamma []byte) []byte {
+	h := sm3.New()
+	h.Write(tag)
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(from))
+	h.Write(b[:])
+	h.Write(gamma)
+	return h.Sum(nil)
+}
+
+// checkSenders checks that from holds exactly one sender for every member of
+// parties.
+func checkSenders(from []int, parties []int) error {
+	seen := make(map[int]bool, len(from))
+	var bad []int
+	for _, i := range from {
+		if seen[i] {
+			bad = append(bad, i)
+		}
+		seen[i] = true
+	}
+	if len(bad) > 0 {
+		return &AbortError{bad, "duplicate message"}
+	}
+	for _, i := range parties {
+		if !seen[i] {
+			bad = append(bad, i)
+		}
+		delete(seen, i)
+	}
+	if len(bad) > 0 {
+		return &AbortError{bad, "missing message"}
+	}
+	for i := range seen {
+		bad = append(bad, i)
+	}
+	if len(bad) > 0 {
+		return &AbortError{bad, "message from an unexpected party"}
+	}
+	return nil
+}
+
+// NewSession starts a signing session for the SM2 signature of msg by the
+// user uid. All signers must use the same sessionID, signer set, msg and uid;
+// sessionID must not be reused across sessions.
+func NewSession(rand io.Reader, key *KeyShare, sessionID []byte, signers []int, msg, uid []byte) (*Session, *Round1Message, error) {
+	e, err := msgDigest(key.PublicKey, msg, uid)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newSession(rand, key, sessionID, signers, e)
+}
+
+func newSession(rand io.Reader, key *KeyShare, sessionID []byte, signers []int, e *big.Int) (*Session, *Round1Message, error) {
+	set, err := key.checkSigners(signers)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &Session{
+		key:     key,
+		id:      append([]byte(nil), sessionID...),
+		signers: set,
+		e:       e,
+		tag:     transcriptTag(sessionID, set, e),
+		h:       base(key.PublicKey),
+	}
+	if s.gamma, err = randScalar(rand); err != nil {
+		return nil, nil, err
+	}
+	c := commitment(s.tag, key.Index, s.h.mul(s.gamma).marshal())
+	s.round = 1
+	return s, &Round1Message{From: key.Index, Commitment: c}, nil
+}
+
+// Round2 processes the round 1 messages of all signers and returns this
+// party's round 2 message.
+func (s *Session) Round2(msgs []*Round1Message) (*Round2Message, error) {
+	if s.round != 1 {
+		return nil, errRound
+	}
+	from := make([]int, len(msgs))
+	for i, m := range msgs {
+		from[i] = m.From
+	}
+	if err := checkSenders(from, s.signers); err != nil {
+		return nil, err
+	}
+	s.commitments = make(map[int][]byte, len(msgs))
+	for _, m := range msgs {
+		s.commitments[m.From] = m.Commitment
+	}
+	s.round = 2
+	return &Round2Message{From: s.key.Index, Gamma: s.h.mul(s.gamma).marshal()}, nil
+}
+
+// Round3 processes the round 2 messages of all signers and returns this
+// party's signature share.
+func (s *Session) Round3(msgs []*Round2Message) (*Round3Message, error) {
+	if s.round != 2 || s.e == nil {
+		return nil, errRound
+	}
+	if err := s.collectNonces(msgs); err != nil {
+		return nil, err
+	}
+	return s.share()
+}
+
+// collectNonces checks the nonce points of all signers against their
+// commitments and computes R.
+func (s *Session) collectNonces(msgs []*Round2Message) error {
+	from := make([]int, len(msgs))
+	for i, m := range msgs {
+		from[i] = m.From
+	}
+	if err := checkSenders(from, s.signers); err != nil {
+		return err
+	}
+
+	s.gammas = make(map[int]*Point, len(msgs))
+	var bad []int
+	for _, m := range msgs {
+		g, err := unmarshalPoint(m.Gamma)
+		if err != nil || string(commitment(s.tag, m.From, m.Gamma)) != string(s.commitments[m.From]) {
+			bad = append(bad, m.From)
+			continue
+		}
+		s.gammas[m.From] = g
+	}
+	if len(bad) > 0 {
+		return &AbortError{bad, "nonce point does not match commitment"}
+	}
+
+	for _, i := range s.signers {
+		if s.nonce == nil {
+			s.nonce = s.gammas[i]
+		} else {
+			s.nonce = s.nonce.add(s.gammas[i])
+		}
+	}
+	if s.nonce.isInfinity() {
+		return ErrRetry
+	}
+	return nil
+}
+
+// share computes r from e and R and returns this party's signature share,
+// erasing its nonce.
+func (s *Session) share() (*Round3Message, error) {
+	n := order()
+	s.r = new(big.Int).Add(s.e, s.nonce.X)
+	s.r.Mod(s.r, n)
+	if s.r.Sign() == 0 {
+		return nil, ErrRetry
+	}
+
+	si := lagrange(s.key.Index, s.signers)
+	si.Mul(si, s.key.Share)
+	si.Mul(si, s.r)
+	si.Add(si, s.gamma)
+	si.Mod(si, n)
+	s.gamma = nil
+	s.round = 3
+	return &Round3Message{From: s.key.Index, S: si}, nil
+}
+
+// Finalize processes the signature shares of all signers and returns the
+// SM2 signature (r, s).
+func (s *Session) Finalize(msgs []*Round3Message) (r, sig *big.Int, err error) {
+	return s.FinalizeContext(context.Background(), msgs)
+}
+
+// FinalizeContext is Finalize with a context: it returns ctx.Err() once ctx
+// is done, checking it between signature shares. A canceled session cannot
+// be resumed.
+func (s *Session) FinalizeContext(ctx context.Context, msgs []*Round3Message) (r, sig *big.Int, err error) {
+	if s.round != 3 {
+		return nil, nil, errRound
+	}
+	from := make([]int, len(msgs))
+	for i, m := range msgs {
+		from[i] = m.From
+	}
+	if err := checkSenders(from, s.signers); err != nil {
+		return nil, nil, err
+	}
+
+	n := order()
+	sum := new(big.Int)
+	var bad []int
+	for _, m := range msgs {
+		if err := ctx.Err(); err != nil {
+			s.round = 0
+			return nil, nil, err
+		}
+		if m.S == nil || m.S.Sign() < 0 || m.S.Cmp(n) >= 0 {
+			bad = append(bad, m.From)
+			continue
+		}
+		// s_i·H = Γ_i + r·λ_i·Y_i
+		c := lagrange(m.From, s.signers)
+		c.Mul(c, s.r).Mod(c, n)
+		want := s.gammas[m.From].add(s.key.VerificationShares[m.From].mul(c))
+		if !s.h.mul(m.S).equal(want) {
+			bad = append(bad, m.From)
+			continue
+		}
+		sum.Add(sum, m.S)
+	}
+	if len(bad) > 0 {
+		return nil, nil, &AbortError{bad, "invalid signature share"}
+	}
+
+	sum.Sub(sum, s.r)
+	sum.Mod(sum, n)
+	t := new(big.Int).Add(sum, s.r)
+	if sum.Sign() == 0 || t.Cmp(n) == 0 {
+		return nil, nil, ErrRetry
+	}
+	s.round = 4
+	return new(big.Int).Set(s.r), sum, nil
+}
@@ -0,0 +1,298 @@
+// Package threshold implements t-of-n threshold SM2 signatures.
+//
+// The signing key d is held as Shamir shares of w = (1+d)^-1 rather than of d.
+// Writing the nonce as k = γ(1+d) turns the SM2 signing equation
+//
+//	s = (1+d)^-1 (k + r) - r
+//
+// into s = γ + r·w - r, with R = k·G = γ·H for H = P + G. Each signer i picks
+// a random γ_i and contributes Γ_i = γ_i·H and s_i = γ_i + r·λ_i·w_i, where
+// λ_i is its Lagrange coefficient, so the signature is a plain sum and every
+// contribution can be checked against the public verification share
+// Y_i = w_i·H. A signer that sends an invalid contribution is identified.
+//
+// The result is an ordinary SM2 signature that verifies with sm2.Sm2Verify
+// under the group public key. Since Γ_i does not depend on the message, the
+// nonce rounds can be run ahead of time with NewPresignSession, leaving a
+// single round once the message is known.
+//
+// Key shares are either dealt by a trusted dealer with Deal or generated
+// without one with the distributed key generation protocol of NewDKG. The
+// package also provides Feldman and Pedersen verifiable secret sharing over
+// the SM2 curve, which Deal uses so that every party can verify its share.
+// KeyShare.Export and ImportKeyShare store a share in a password-encrypted
+// file format.
+package threshold
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+var (
+	// ErrParameters is returned for an invalid threshold, party count or
+	// signer set.
+	ErrParameters = errors.New("threshold: invalid parameters")
+	// ErrInvalidKey is returned for an SM2 key that cannot be shared.
+	ErrInvalidKey = errors.New("threshold: invalid private key")
+)
+
+// Point is a point on the SM2 curve in affine coordinates.
+type Point struct {
+	X, Y *big.Int
+}
+
+// KeyShare is one party's share of a threshold SM2 key.
+type KeyShare struct {
+	// Index is the party's evaluation point, between 1 and the number of
+	// parties.
+	Index int
+	// Threshold is the number of parties needed to sign.
+	Threshold int
+	// Share is the party's Shamir share of (1+d)^-1.
+	Share *big.Int
+	// PublicKey is the group public key.
+	PublicKey *sm2.PublicKey
+	// Commitments are the Feldman commitments to the sharing polynomial
+	// under the base PublicKey + G.
+	Commitments []*Point
+	// VerificationShares maps the index of every party to its share times
+	// PublicKey + G, and is used to identify invalid signature shares.
+	VerificationShares map[int]*Point
+}
+
+func curve() elliptic.Curve {
+	return sm2.P256Sm2()
+}
+
+func order() *big.Int {
+	return curve().Params().N
+}
+
+// randScalar returns a uniformly random scalar in [1, n-1].
+func randScalar(rand io.Reader) (*big.Int, error) {
+	n := order()
+	b := make([]byte, n.BitLen()/8+8)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(b)
+	nMinus1 := new(big.Int).Sub(n, big.NewInt(1))
+	k.Mod(k, nMinus1)
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+func (p *Point) add(q *Point) *Point {
+	x, y := curve().Add(p.X, p.Y, q.X, q.Y)
+	return &Point{x, y}
+}
+
+func infinity() *Point {
+	return &Point{new(big.Int), new(big.Int)}
+}
+
+// mul returns k·p. The scalar is reduced first, since the SM2 scalar
+// multiplication does not handle a zero scalar.
+func (p *Point) mul(k *big.Int) *Point {
+	k = new(big.Int).Mod(k, order())
+	if k.Sign() == 0 || p.isInfinity() {
+		return infinity()
+	}
+	x, y := curve().ScalarMult(p.X, p.Y, k.Bytes())
+	return &Point{x, y}
+}
+
+func (p *Point) neg() *Point {
+	if p.isInfinity() {
+		return infinity()
+	}
+	return &Point{new(big.Int).Set(p.X), new(big.Int).Sub(curve().Params().P, p.Y)}
+}
+
+func (p *Point) equal(q *Point) bool {
+	return p.X.Cmp(q.X) == 0 && p.Y.Cmp(q.Y) == 0
+}
+
+func (p *Point) isInfinity() bool {
+	return p.X.Sign() == 0 && p.Y.Sign() == 0
+}
+
+// fixedBytes returns x || y as 32-byte big-endian integers, also for the
+// point at infinity.
+func (p *Point) fixedBytes() []byte {
+	b := make([]byte, 64)
+	x, y := p.X.Bytes(), p.Y.Bytes()
+	copy(b[32-len(x):], x)
+	copy(b[64-len(y):], y)
+	return b
+}
+
+func (p *Point) marshal() []byte {
+	return elliptic.Marshal(curve(), p.X, p.Y)
+}
+
+// unmarshalPoint parses an uncompressed point, rejecting points that are not
+// on the curve.
+func unmarshalPoint(b []byte) (*Point, error) {
+	x, y := elliptic.Unmarshal(curve(), b)
+	if x == nil {
+		return nil, errors.New("threshold: invalid point")
+	}
+	return &Point{x, y}, nil
+}
+
+func baseMul(k *big.Int) *Point {
+	k = new(big.Int).Mod(k, order())
+	if k.Sign() == 0 {
+		return infinity()
+	}
+	x, y := curve().ScalarBaseMult(k.Bytes())
+	return &Point{x, y}
+}
+
+// base returns H = P + G, the base point of the verification shares.
+func base(pub *sm2.PublicKey) *Point {
+	p := &Point{pub.X, pub.Y}
+	return p.add(&Point{curve().Params().Gx, curve().Params().Gy})
+}
+
+// evalPoly evaluates the polynomial with coefficients coeffs at x modulo n.
+func evalPoly(coeffs []*big.Int, x int) *big.Int {
+	n := order()
+	bx := big.NewInt(int64(x))
+	y := new(big.Int)
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y.Mul(y, bx)
+		y.Add(y, coeffs[i])
+		y.Mod(y, n)
+	}
+	return y
+}
+
+// lagrange returns the Lagrange coefficient of index i for interpolating at
+// zero over the indices in set.
+func lagrange(i int, set []int) *big.Int {
+	n := order()
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range set {
+		if j == i {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		num.Mod(num, n)
+		den.Mul(den, big.NewInt(int64(j-i)))
+		den.Mod(den, n)
+	}
+	return num.Mul(num, den.ModInverse(den, n)).Mod(num, n)
+}
+
+// checkSigners returns the sorted signer set, checking that it has at least
+// threshold distinct members in [1, parties] and includes self.
+func (k *KeyShare) checkSigners(signers []int) ([]int, error) {
+	set := append([]int(nil), signers...)
+	sort.Ints(set)
+	if len(set) < k.Threshold {
+		return nil, ErrParameters
+	}
+	self := false
+	for i, j := range set {
+		if _, ok := k.VerificationShares[j]; !ok || (i > 0 && set[i-1] == j) {
+			return nil, ErrParameters
+		}
+		self = self || j == k.Index
+	}
+	if !self {
+		return nil, ErrParameters
+	}
+	return set, nil
+}
+
+// Deal splits priv into n shares such that any threshold of them can sign.
+// The dealer knows the key and must be trusted with it, but not with the
+// consistency of the shares: each party should check its share with
+// KeyShare.Verify.
+func Deal(rand io.Reader, priv *sm2.PrivateKey, threshold, n int) ([]*KeyShare, error) {
+	if err := checkShareParams(threshold, n); err != nil {
+		return nil, err
+	}
+	N := order()
+	d1 := new(big.Int).Add(priv.D, big.NewInt(1))
+	if priv.D.Sign() <= 0 || d1.Cmp(N) >= 0 {
+		return nil, ErrInvalidKey
+	}
+	w := d1.ModInverse(d1, N)
+
+	pub := &sm2.PublicKey{Curve: curve(), X: priv.X, Y: priv.Y}
+	ws, commitments, err := FeldmanShare(rand, w, threshold, n, base(pub))
+	if err != nil {
+		return nil, err
+	}
+	vs := verificationShares(commitments, n)
+	shares := make([]*KeyShare, n)
+	for i := range shares {
+		shares[i] = &KeyShare{
+			Index:              i + 1,
+			Threshold:          threshold,
+			Share:              ws[i],
+			PublicKey:          pub,
+			Commitments:        commitments,
+			VerificationShares: vs,
+		}
+	}
+	return shares, nil
+}
+
+func verificationShares(commitments []*Point, n int) map[int]*Point {
+	vs := make(map[int]*Point, n)
+	for i := 1; i <= n; i++ {
+		vs[i] = EvalCommitments(commitments, i)
+	}
+	return vs
+}
+
+// Verify checks the share against the Feldman commitments of the dealer and
+// checks that the commitments and verification shares match the group
+// public key.
+func (k *KeyShare) Verify() error {
+	pub := k.PublicKey
+	if pub == nil || !curve().IsOnCurve(pub.X, pub.Y) {
+		return errors.New("threshold: invalid group public key")
+	}
+	if k.Threshold < 1 || len(k.Commitments) != k.Threshold || k.Share == nil {
+		return ErrParameters
+	}
+	// The shared secret is (1+d)^-1, so its commitment is (1+d)^-1·H = G.
+	if !k.Commitments[0].equal(Generator()) {
+		return errors.New("threshold: commitments do not match the group public key")
+	}
+	h := base(pub)
+	if !VerifyFeldmanShare(k.Index, k.Share, k.Commitments, h) {
+		return errors.New("threshold: share does not match the commitments")
+	}
+	if _, ok := k.VerificationShares[k.Index]; !ok || len(k.VerificationShares) < k.Threshold {
+		return ErrParameters
+	}
+	for j, y := range k.VerificationShares {
+		if j < 1 || !y.equal(EvalCommitments(k.Commitments, j)) {
+			return errors.New("threshold: verification share does not match the commitments")
+		}
+	}
+	return nil
+}
+
+// AbortError is returned when a protocol run fails because of invalid
+// messages. Culprits lists the indices of the parties responsible.
+type AbortError struct {
+	Culprits []int
+	Reason   string
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("threshold: aborted by parties %v: %s", e.Culprits, e.Reason)
+}
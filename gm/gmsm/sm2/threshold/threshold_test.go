@@ -0,0 +1,183 @@
+package threshold
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+var uid = []byte("1234567812345678")
+
+func dealShares(t *testing.T, threshold, n int) []*KeyShare {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := Deal(rand.Reader, priv, threshold, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shares
+}
+
+// runSigning runs a signing session among signers. tamper, if not nil, may
+// modify the round 3 messages before they are delivered.
+func runSigning(t *testing.T, shares []*KeyShare, signers []int, msg []byte, tamper func([]*Round3Message)) (r, s *big.Int, err error) {
+	sessions := make([]*Session, len(signers))
+	r1 := make([]*Round1Message, len(signers))
+	for i, j := range signers {
+		sessions[i], r1[i], err = NewSession(rand.Reader, shares[j-1], []byte("session"), signers, msg, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r2 := make([]*Round2Message, len(signers))
+	for i, sess := range sessions {
+		if r2[i], err = sess.Round2(r1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r3 := make([]*Round3Message, len(signers))
+	for i, sess := range sessions {
+		if r3[i], err = sess.Round3(r2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tamper != nil {
+		tamper(r3)
+	}
+	for _, sess := range sessions {
+		if r, s, err = sess.Finalize(r3); err != nil {
+			return nil, nil, err
+		}
+	}
+	return r, s, nil
+}
+
+func TestThresholdSign(t *testing.T) {
+	shares := dealShares(t, 3, 5)
+	msg := []byte("threshold SM2")
+	for _, signers := range [][]int{{1, 2, 3}, {5, 2, 4}, {1, 2, 3, 4, 5}} {
+		r, s, err := runSigning(t, shares, signers, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.Sm2Verify(shares[0].PublicKey, msg, uid, r, s) {
+			t.Fatalf("signers %v: signature does not verify", signers)
+		}
+		if sm2.Sm2Verify(shares[0].PublicKey, []byte("other"), uid, r, s) {
+			t.Fatalf("signers %v: signature verifies for another message", signers)
+		}
+	}
+}
+
+func TestFinalizeContext(t *testing.T) {
+	shares := dealShares(t, 1, 1)
+	sess, r1, err := NewSession(rand.Reader, shares[0], []byte("session"), []int{1}, []byte("message"), uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := sess.Round2([]*Round1Message{r1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r3, err := sess.Round3([]*Round2Message{r2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := sess.FinalizeContext(ctx, []*Round3Message{r3}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, _, err := sess.Finalize([]*Round3Message{r3}); err != errRound {
+		t.Fatalf("a canceled session went on: %v", err)
+	}
+}
+
+func TestOneOfOne(t *testing.T) {
+	shares := dealShares(t, 1, 1)
+	r, s, err := runSigning(t, shares, []int{1}, []byte("msg"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm2.Sm2Verify(shares[0].PublicKey, []byte("msg"), uid, r, s) {
+		t.Fatal("signature does not verify")
+	}
+}
+
+func TestIdentifiableAbort(t *testing.T) {
+	shares := dealShares(t, 2, 3)
+	_, _, err := runSigning(t, shares, []int{1, 3}, []byte("msg"), func(m []*Round3Message) {
+		m[1].S = new(big.Int).Add(m[1].S, big.NewInt(1))
+	})
+	abort, ok := err.(*AbortError)
+	if !ok || len(abort.Culprits) != 1 || abort.Culprits[0] != 3 {
+		t.Fatalf("got error %v, want abort blaming party 3", err)
+	}
+
+	// A nonce point that does not match its commitment is detected in round 3.
+	signers := []int{1, 2}
+	s1, m1, _ := NewSession(rand.Reader, shares[0], nil, signers, []byte("msg"), uid)
+	s2, m2, _ := NewSession(rand.Reader, shares[1], nil, signers, []byte("msg"), uid)
+	r1 := []*Round1Message{m1, m2}
+	n1, _ := s1.Round2(r1)
+	n2, _ := s2.Round2(r1)
+	n2.Gamma = n1.Gamma
+	_, err = s1.Round3([]*Round2Message{n1, n2})
+	if abort, ok := err.(*AbortError); !ok || len(abort.Culprits) != 1 || abort.Culprits[0] != 2 {
+		t.Fatalf("got error %v, want abort blaming party 2", err)
+	}
+
+	// Missing and duplicate messages.
+	s1, m1, _ = NewSession(rand.Reader, shares[0], nil, signers, []byte("msg"), uid)
+	if _, err := s1.Round2([]*Round1Message{m1}); err == nil {
+		t.Fatal("missing message accepted")
+	}
+	if _, err := s1.Round2([]*Round1Message{m1, m1}); err == nil {
+		t.Fatal("duplicate message accepted")
+	}
+	if _, _, err := s1.Finalize(nil); err != errRound {
+		t.Fatalf("Finalize before Round3: %v", err)
+	}
+}
+
+func TestSignerSet(t *testing.T) {
+	shares := dealShares(t, 3, 4)
+	for _, signers := range [][]int{{1, 2}, {1, 2, 2}, {2, 3, 4}, {1, 2, 5}} {
+		if _, _, err := NewSession(rand.Reader, shares[0], nil, signers, []byte("msg"), uid); err != ErrParameters {
+			t.Errorf("signers %v: got error %v, want ErrParameters", signers, err)
+		}
+	}
+	if _, err := Deal(rand.Reader, &sm2.PrivateKey{}, 3, 2); err != ErrParameters {
+		t.Errorf("Deal with threshold > n: %v", err)
+	}
+}
+
+func BenchmarkSign3of5(b *testing.B) {
+	priv, _ := sm2.GenerateKey()
+	shares, _ := Deal(rand.Reader, priv, 3, 5)
+	signers := []int{1, 2, 3}
+	msg := []byte("benchmark")
+	for n := 0; n < b.N; n++ {
+		sessions := make([]*Session, 3)
+		r1 := make([]*Round1Message, 3)
+		for i, j := range signers {
+			sessions[i], r1[i], _ = NewSession(rand.Reader, shares[j-1], nil, signers, msg, uid)
+		}
+		r2 := make([]*Round2Message, 3)
+		for i, s := range sessions {
+			r2[i], _ = s.Round2(r1)
+		}
+		r3 := make([]*Round3Message, 3)
+		for i, s := range sessions {
+			r3[i], _ = s.Round3(r2)
+		}
+		for _, s := range sessions {
+			s.Finalize(r3)
+		}
+	}
+}
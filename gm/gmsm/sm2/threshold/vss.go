@@ -0,0 +1,199 @@
+package threshold
+
+import (
+	"encoding/binary"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// Verifiable secret sharing. A dealer shares a secret with a random
+// polynomial f of degree threshold-1, f(0) = secret, gives f(i) to party i
+// and publishes commitments to the coefficients of f. Each party checks its
+// share against the commitments, so a dealer cannot hand out inconsistent
+// shares undetected.
+//
+// Feldman commitments are C_j = a_j·B for a base point B and reveal secret·B.
+// Pedersen commitments are C_j = a_j·G + b_j·Q with a second polynomial of
+// blinding values b_j and a generator Q of unknown discrete logarithm, and
+// reveal nothing about the secret.
+
+// Generator returns G, the base point of the SM2 curve.
+func Generator() *Point {
+	params := curve().Params()
+	return &Point{new(big.Int).Set(params.Gx), new(big.Int).Set(params.Gy)}
+}
+
+var (
+	pedersenOnce sync.Once
+	pedersenQ    *Point
+)
+
+// PedersenGenerator returns the second generator Q used by Pedersen
+// commitments. It is derived by hashing to the curve, so its discrete
+// logarithm to the base G is unknown.
+func PedersenGenerator() *Point {
+	pedersenOnce.Do(func() {
+		params := curve().Params()
+		p := params.P
+		a := new(big.Int).Sub(p, big.NewInt(3))
+		for ctr := uint32(0); ; ctr++ {
+			h := sm3.New()
+			h.Write([]byte("SM2 Pedersen generator"))
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], ctr)
+			h.Write(b[:])
+			x := new(big.Int).SetBytes(h.Sum(nil))
+			if x.Cmp(p) >= 0 {
+				continue
+			}
+			// y² = x³ + ax + b
+			y2 := new(big.Int).Mul(x, x)
+			y2.Add(y2, a)
+			y2.Mul(y2, x)
+			y2.Add(y2, params.B)
+			y2.Mod(y2, p)
+			y := new(big.Int).ModSqrt(y2, p)
+			if y == nil {
+				continue
+			}
+			if y.Bit(0) != 0 {
+				y.Sub(p, y)
+			}
+			pedersenQ = &Point{x, y}
+			return
+		}
+	})
+	return &Point{new(big.Int).Set(pedersenQ.X), new(big.Int).Set(pedersenQ.Y)}
+}
+
+// randPoly returns a random polynomial of degree threshold-1 with constant
+// term c.
+func randPoly(rand io.Reader, c *big.Int, threshold int) ([]*big.Int, error) {
+	coeffs := []*big.Int{new(big.Int).Mod(c, order())}
+	for i := 1; i < threshold; i++ {
+		a, err := randScalar(rand)
+		if err != nil {
+			return nil, err
+		}
+		coeffs = append(coeffs, a)
+	}
+	return coeffs, nil
+}
+
+func checkShareParams(threshold, n int) error {
+	if threshold < 1 || n < threshold || n > 1<<16 {
+		return ErrParameters
+	}
+	return nil
+}
+
+// FeldmanShare splits secret into n shares, any threshold of which recover
+// it, and returns the shares for the indices 1 to n with the Feldman
+// commitments to the sharing polynomial under base. A nil base means G.
+func FeldmanShare(rand io.Reader, secret *big.Int, threshold, n int, base *Point) (shares []*big.Int, commitments []*Point, err error) {
+	if err := checkShareParams(threshold, n); err != nil {
+		return nil, nil, err
+	}
+	if base == nil {
+		base = Generator()
+	}
+	coeffs, err := randPoly(rand, secret, threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	commitments = make([]*Point, threshold)
+	for j, a := range coeffs {
+		commitments[j] = base.mul(a)
+	}
+	shares = make([]*big.Int, n)
+	for i := range shares {
+		shares[i] = evalPoly(coeffs, i+1)
+	}
+	return shares, commitments, nil
+}
+
+// PedersenShare splits secret like FeldmanShare, but returns hiding Pedersen
+// commitments and the blinding share that goes with each share.
+func PedersenShare(rand io.Reader, secret *big.Int, threshold, n int) (shares, blindings []*big.Int, commitments []*Point, err error) {
+	if err := checkShareParams(threshold, n); err != nil {
+		return nil, nil, nil, err
+	}
+	coeffs, err := randPoly(rand, secret, threshold)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	r, err := randScalar(rand)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	blind, err := randPoly(rand, r, threshold)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	q := PedersenGenerator()
+	commitments = make([]*Point, threshold)
+	for j := range coeffs {
+		commitments[j] = baseMul(coeffs[j]).add(q.mul(blind[j]))
+	}
+	shares = make([]*big.Int, n)
+	blindings = make([]*big.Int, n)
+	for i := range shares {
+		shares[i] = evalPoly(coeffs, i+1)
+		blindings[i] = evalPoly(blind, i+1)
+	}
+	return shares, blindings, commitments, nil
+}
+
+// EvalCommitments returns Σ C_j·index^j, the commitment to the share of
+// index. For Feldman commitments under base B it equals share·B.
+func EvalCommitments(commitments []*Point, index int) *Point {
+	x := big.NewInt(int64(index))
+	acc := infinity()
+	for j := len(commitments) - 1; j >= 0; j-- {
+		acc = acc.mul(x).add(commitments[j])
+	}
+	return acc
+}
+
+// VerifyFeldmanShare reports whether share is the share of index committed
+// to by the Feldman commitments under base. A nil base means G.
+func VerifyFeldmanShare(index int, share *big.Int, commitments []*Point, base *Point) bool {
+	if index < 1 || len(commitments) == 0 || share.Sign() < 0 || share.Cmp(order()) >= 0 {
+		return false
+	}
+	if base == nil {
+		base = Generator()
+	}
+	return base.mul(share).equal(EvalCommitments(commitments, index))
+}
+
+// VerifyPedersenShare reports whether share and blinding are the shares of
+// index committed to by the Pedersen commitments.
+func VerifyPedersenShare(index int, share, blinding *big.Int, commitments []*Point) bool {
+	n := order()
+	if index < 1 || len(commitments) == 0 ||
+		share.Sign() < 0 || share.Cmp(n) >= 0 || blinding.Sign() < 0 || blinding.Cmp(n) >= 0 {
+		return false
+	}
+	lhs := baseMul(share).add(PedersenGenerator().mul(blinding))
+	return lhs.equal(EvalCommitments(commitments, index))
+}
+
+// Interpolate recovers the secret from threshold or more shares, given as a
+// map from index to share. It does not check the shares.
+func Interpolate(shares map[int]*big.Int) *big.Int {
+	set := make([]int, 0, len(shares))
+	for i := range shares {
+		set = append(set, i)
+	}
+	n := order()
+	s := new(big.Int)
+	for _, i := range set {
+		c := lagrange(i, set)
+		s.Add(s, c.Mul(c, shares[i]))
+	}
+	return s.Mod(s, n)
+}
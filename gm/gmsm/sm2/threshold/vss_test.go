@@ -0,0 +1,93 @@
+package threshold
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestFeldman(t *testing.T) {
+	secret, _ := randScalar(rand.Reader)
+	for _, base := range []*Point{nil, PedersenGenerator()} {
+		shares, commitments, err := FeldmanShare(rand.Reader, secret, 3, 5, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, s := range shares {
+			if !VerifyFeldmanShare(i+1, s, commitments, base) {
+				t.Fatalf("share %d does not verify", i+1)
+			}
+		}
+		bad := new(big.Int).Add(shares[1], big.NewInt(1))
+		if VerifyFeldmanShare(2, bad, commitments, base) || VerifyFeldmanShare(3, shares[1], commitments, base) {
+			t.Fatal("invalid share verifies")
+		}
+
+		got := Interpolate(map[int]*big.Int{1: shares[0], 3: shares[2], 5: shares[4]})
+		if got.Cmp(secret) != 0 {
+			t.Fatal("interpolated secret does not match")
+		}
+	}
+}
+
+func TestPedersen(t *testing.T) {
+	q := PedersenGenerator()
+	if !curve().IsOnCurve(q.X, q.Y) || q.equal(Generator()) {
+		t.Fatal("invalid Pedersen generator")
+	}
+
+	secret, _ := randScalar(rand.Reader)
+	shares, blindings, commitments, err := PedersenShare(rand.Reader, secret, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range shares {
+		if !VerifyPedersenShare(i+1, shares[i], blindings[i], commitments) {
+			t.Fatalf("share %d does not verify", i+1)
+		}
+	}
+	if VerifyPedersenShare(1, shares[0], blindings[1], commitments) {
+		t.Fatal("share with wrong blinding verifies")
+	}
+	// The commitments hide the secret.
+	if commitments[0].equal(baseMul(secret)) {
+		t.Fatal("Pedersen commitment reveals secret·G")
+	}
+	if Interpolate(map[int]*big.Int{2: shares[1], 4: shares[3]}).Cmp(secret) != 0 {
+		t.Fatal("interpolated secret does not match")
+	}
+
+	if _, _, _, err := PedersenShare(rand.Reader, secret, 0, 4); err != ErrParameters {
+		t.Fatalf("threshold 0: %v", err)
+	}
+}
+
+func TestKeyShareVerify(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	shares, err := Deal(rand.Reader, priv, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range shares {
+		if err := k.Verify(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A dealer handing out an inconsistent share is caught.
+	k := *shares[1]
+	k.Share = new(big.Int).Add(k.Share, big.NewInt(1))
+	if k.Verify() == nil {
+		t.Fatal("inconsistent share verifies")
+	}
+
+	// So is a dealer sharing a key other than the published one.
+	other, _ := sm2.GenerateKey()
+	k = *shares[1]
+	k.PublicKey = &other.PublicKey
+	if k.Verify() == nil {
+		t.Fatal("share of another key verifies")
+	}
+}
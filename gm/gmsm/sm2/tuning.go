@@ -0,0 +1,30 @@
+package sm2
+
+// Tuning describes how this build computes on the curve. It is chosen at
+// build time with tags, from benchmarks of the targets:
+//
+//	sm2small   narrows the window of ScalarMult from 4 to 2 bits, for
+//	           devices short of stack
+//	sm2serial  computes field products one at a time instead of two at once
+//	           with AVX2, for amd64 machines without AVX2 or where it is
+//	           slower; other architectures always do
+//
+// The tags only change speed and memory, never results.
+//
+// There is no strategy based on an endomorphism: the GLV method splits a
+// scalar in two halves with an efficiently computable endomorphism of the
+// curve, which only curves with j-invariant 0 (a = 0, as secp256k1) or 1728
+// (b = 0) have among curves of prime order over a prime field. The SM2
+// curve has a = p - 3 and b ≠ 0, like NIST P-256, so its endomorphisms all
+// cost more than the doublings they would save.
+type Tuning struct {
+	// Window is the width in bits of the fixed window of ScalarMult.
+	Window int
+	// TwoWay reports whether field products are computed two at once.
+	TwoWay bool
+}
+
+// CurveTuning returns the Tuning of this build.
+func CurveTuning() Tuning {
+	return Tuning{Window: sm2P256Window, TwoWay: sm2P256TwoWay}
+}
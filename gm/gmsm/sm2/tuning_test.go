@@ -0,0 +1,39 @@
+package sm2
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCurveTuning(t *testing.T) {
+	tuning := CurveTuning()
+	if tuning.Window < 1 || tuning.Window > 6 {
+		t.Fatalf("window %d", tuning.Window)
+	}
+	t.Logf("%+v", tuning)
+
+	// The claim of the Tuning documentation that GLV does not apply: the
+	// j-invariant is neither 0 nor 1728.
+	params := P256Sm2().Params()
+	a := new(big.Int).Sub(params.P, big.NewInt(3))
+	if a.Sign() == 0 || params.B.Sign() == 0 {
+		t.Fatal("SM2 curve has j-invariant 0 or 1728")
+	}
+}
+
+func BenchmarkFieldMul(b *testing.B) {
+	x := sm2P256FieldElement{406862363, 81838615, 518199610, 213356114, 48208314, 12670465, 387225316, 157273097, 505796632}
+	y := sm2P256FieldElement{19019391, 84912409, 1025760432, 156511594, 430707246, 52894858, 115667788, 70162044, 20023025}
+	var c, c2 sm2P256FieldElement
+	b.Run("Serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sm2P256Mul(&c, &x, &y)
+			sm2P256Mul(&c2, &y, &x)
+		}
+	})
+	b.Run("Mul2Way", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sm2P256Mul2Way(&c, &x, &y, &c2, &y, &x)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package sm2
+
+import (
+	"errors"
+	"math/big"
+	"sync/atomic"
+)
+
+var (
+	ErrInvalidPrivateKey = errors.New("sm2: invalid private key")
+	ErrInvalidPublicKey  = errors.New("sm2: invalid public key")
+)
+
+// Validate checks that pub is a point of the curve other than the point at
+// infinity, with coordinates in [0, p). The SM2 curve has cofactor 1, so
+// such a point is in the group generated by G.
+func (pub *PublicKey) Validate() error {
+	if pub == nil || pub.Curve == nil || pub.X == nil || pub.Y == nil {
+		return ErrInvalidPublicKey
+	}
+	p := pub.Curve.Params().P
+	if pub.X.Sign() < 0 || pub.X.Cmp(p) >= 0 || pub.Y.Sign() < 0 || pub.Y.Cmp(p) >= 0 {
+		return ErrInvalidPublicKey
+	}
+	if pub.X.Sign() == 0 && pub.Y.Sign() == 0 {
+		return ErrInvalidPublicKey
+	}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return ErrInvalidPublicKey
+	}
+	return nil
+}
+
+// Validate checks that 1 ≤ D ≤ n-2 and that the public key is D·G. D = n-1
+// is excluded as by GB/T 32918.1, since signing needs the inverse of 1+D.
+func (priv *PrivateKey) Validate() error {
+	if priv == nil || priv.D == nil {
+		return ErrInvalidPrivateKey
+	}
+	if err := priv.PublicKey.Validate(); err != nil {
+		return err
+	}
+	nMinus1 := new(big.Int).Sub(priv.Curve.Params().N, one)
+	if priv.D.Sign() <= 0 || priv.D.Cmp(nMinus1) >= 0 {
+		return ErrInvalidPrivateKey
+	}
+	x, y := priv.Curve.ScalarBaseMult(priv.D.Bytes())
+	if x.Cmp(priv.X) != 0 || y.Cmp(priv.Y) != 0 {
+		return ErrInvalidPrivateKey
+	}
+	return nil
+}
+
+var validateKeys int32
+
+// SetKeyValidation turns on or off the validation of keys by the
+// operations of the package: when on, PrivateKey.Sign, Sm2Sign and Decrypt
+// fail with ErrInvalidPrivateKey or ErrInvalidPublicKey for an invalid
+// key, and Verify, Sm2Verify and Encrypt reject an invalid public key,
+// instead of producing garbage. It is off by default, since validating a
+// private key costs a scalar multiplication per operation.
+func SetKeyValidation(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&validateKeys, v)
+}
+
+func checkPrivateKey(priv *PrivateKey) error {
+	if atomic.LoadInt32(&validateKeys) == 0 {
+		return nil
+	}
+	return priv.Validate()
+}
+
+func checkPublicKey(pub *PublicKey) error {
+	if atomic.LoadInt32(&validateKeys) == 0 {
+		return nil
+	}
+	return pub.Validate()
+}
@@ -0,0 +1,109 @@
+package sm2
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func TestValidate(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.PublicKey.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := P256Sm2()
+	n := c.Params().N
+	for name, d := range map[string]*big.Int{
+		"zero":  new(big.Int),
+		"n-1":   new(big.Int).Sub(n, one),
+		"n":     new(big.Int).Set(n),
+		"other": new(big.Int).Add(priv.D, one),
+	} {
+		bad := &PrivateKey{PublicKey: priv.PublicKey, D: d}
+		if err := bad.Validate(); err != ErrInvalidPrivateKey {
+			t.Errorf("D = %s: expected ErrInvalidPrivateKey, got %v", name, err)
+		}
+	}
+
+	p := c.Params().P
+	for name, pub := range map[string]*PublicKey{
+		"infinity":  {Curve: c, X: new(big.Int), Y: new(big.Int)},
+		"off curve": {Curve: c, X: priv.X, Y: new(big.Int).Add(priv.Y, one)},
+		"x >= p":    {Curve: c, X: new(big.Int).Add(priv.X, p), Y: priv.Y},
+		"nil":       {Curve: c},
+	} {
+		if err := pub.Validate(); err != ErrInvalidPublicKey {
+			t.Errorf("%s: expected ErrInvalidPublicKey, got %v", name, err)
+		}
+		if err := (&PrivateKey{PublicKey: *pub, D: priv.D}).Validate(); err != ErrInvalidPublicKey {
+			t.Errorf("%s: expected ErrInvalidPublicKey for the private key, got %v", name, err)
+		}
+	}
+}
+
+func TestKeyValidation(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sm3.Sm3Sum([]byte("message"))
+	ct, err := Encrypt(&priv.PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := &PrivateKey{PublicKey: priv.PublicKey, D: new(big.Int).Add(priv.D, one)}
+	offCurve := &PublicKey{Curve: priv.Curve, X: priv.X, Y: new(big.Int).Add(priv.Y, one)}
+
+	// Without validation, a corrupt key signs garbage.
+	sig, err := corrupt.Sign(nil, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv.PublicKey.Verify(digest, sig) {
+		t.Fatal("a corrupt key made a valid signature")
+	}
+
+	SetKeyValidation(true)
+	defer SetKeyValidation(false)
+	if _, err := corrupt.Sign(nil, digest, nil); err != ErrInvalidPrivateKey {
+		t.Fatalf("Sign: expected ErrInvalidPrivateKey, got %v", err)
+	}
+	if _, _, err := Sm2Sign(corrupt, []byte("message"), nil); err != ErrInvalidPrivateKey {
+		t.Fatalf("Sm2Sign: expected ErrInvalidPrivateKey, got %v", err)
+	}
+	if _, err := Decrypt(corrupt, ct); err != ErrInvalidPrivateKey {
+		t.Fatalf("Decrypt: expected ErrInvalidPrivateKey, got %v", err)
+	}
+	if _, err := Encrypt(offCurve, []byte("secret")); err != ErrInvalidPublicKey {
+		t.Fatalf("Encrypt: expected ErrInvalidPublicKey, got %v", err)
+	}
+
+	sig, err = priv.Sign(nil, digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Verify(digest, sig) {
+		t.Fatal("a valid signature does not verify")
+	}
+	if offCurve.Verify(digest, sig) {
+		t.Fatal("Verify accepted an invalid public key")
+	}
+	r, s, err := Sm2Sign(priv, []byte("message"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Sm2Verify(&priv.PublicKey, []byte("message"), nil, r, s) || Sm2Verify(offCurve, []byte("message"), nil, r, s) {
+		t.Fatal("Sm2Verify does not validate the public key")
+	}
+	if pt, err := Decrypt(priv, ct); err != nil || string(pt) != "secret" {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+}
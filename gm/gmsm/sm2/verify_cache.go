@@ -0,0 +1,161 @@
+package sm2
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/ctutil"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// VerifyCache remembers the signatures that verified, so that a signature
+// checked again, as gossip-based consensus does with every message it
+// relays, costs a hash of the message instead of a verification.
+//
+// Entries are keyed by the SM3 hash of the public key, the user ID, the
+// options, the message and the signature, and evicted least recently used
+// first. Only successful verifications are cached: a flood of invalid
+// signatures cannot evict the valid ones, and an invalid signature is never
+// accepted from the cache.
+//
+// A VerifyCache is safe for concurrent use.
+type VerifyCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // of [sm3.Size]byte, most recently used first
+	entries map[[sm3.Size]byte]*list.Element
+
+	// Guarded by mu: 64-bit atomics would need an alignment that 32-bit
+	// platforms do not give fields at this offset.
+	hits, misses, evictions uint64
+}
+
+// VerifyCacheStats are the counters of a VerifyCache.
+type VerifyCacheStats struct {
+	// Hits and Misses count the lookups that found a verified signature
+	// and those that had to verify.
+	Hits, Misses uint64
+	// Evictions counts the entries dropped to make room for others.
+	Evictions uint64
+	// Len is the number of entries.
+	Len int
+}
+
+// NewVerifyCache returns a cache of up to size verified signatures, about
+// 100 bytes each. A size below 1 is taken as 1.
+func NewVerifyCache(size int) *VerifyCache {
+	if size < 1 {
+		size = 1
+	}
+	return &VerifyCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[[sm3.Size]byte]*list.Element),
+	}
+}
+
+// VerifyEx is VerifyEx answered from the cache when possible.
+func (c *VerifyCache) VerifyEx(pub *PublicKey, msg, sig []byte, opts ...Option) bool {
+	if !cacheableKey(pub) {
+		return VerifyEx(pub, msg, sig, opts...)
+	}
+	o := newOptions(opts)
+	key := verifyCacheKey(pub, msg, sig, o)
+	if c.lookup(key) {
+		return true
+	}
+	if !VerifyEx(pub, msg, sig, opts...) {
+		return false
+	}
+	c.add(key)
+	return true
+}
+
+// Stats returns the counters of c.
+func (c *VerifyCache) Stats() VerifyCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return VerifyCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       c.order.Len(),
+	}
+}
+
+// Purge removes all entries, e.g. once a key is revoked. The counters are
+// kept.
+func (c *VerifyCache) Purge() {
+	c.mu.Lock()
+	c.order.Init()
+	c.entries = make(map[[sm3.Size]byte]*list.Element)
+	c.mu.Unlock()
+}
+
+func (c *VerifyCache) lookup(key [sm3.Size]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return false
+	}
+	c.hits++
+	c.order.MoveToFront(e)
+	return true
+}
+
+func (c *VerifyCache) add(key [sm3.Size]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		// verified concurrently by another goroutine
+		c.order.MoveToFront(e)
+		return
+	}
+	for c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		delete(c.entries, c.order.Remove(oldest).([sm3.Size]byte))
+		c.evictions++
+	}
+	c.entries[key] = c.order.PushFront(key)
+}
+
+// cacheableKey reports whether pub is a key of the SM2 curve with
+// coordinates that verifyCacheKey encodes without loss.
+func cacheableKey(pub *PublicKey) bool {
+	if pub == nil || pub.X == nil || pub.Y == nil {
+		return false
+	}
+	if _, ok := pub.Curve.(sm2P256Curve); !ok {
+		return false
+	}
+	return pub.X.Sign() >= 0 && pub.X.BitLen() <= 256 && pub.Y.Sign() >= 0 && pub.Y.BitLen() <= 256
+}
+
+// verifyCacheKey hashes everything the result of VerifyEx depends on, each
+// variable-length field prefixed with its length.
+func verifyCacheKey(pub *PublicKey, msg, sig []byte, o *options) [sm3.Size]byte {
+	h := sm3.New()
+	h.Write([]byte("sm2 verify cache"))
+	h.Write(ctutil.FixedBytes(pub.X, 32))
+	h.Write(ctutil.FixedBytes(pub.Y, 32))
+	var flags byte
+	if o.canonical {
+		flags |= 1
+	}
+	if o.strict {
+		flags |= 2
+	}
+	h.Write([]byte{flags})
+	for _, field := range [][]byte{resolveUID(o.uid), msg, sig} {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(field)))
+		h.Write(n[:])
+		h.Write(field)
+	}
+	var key [sm3.Size]byte
+	h.Sum(key[:0])
+	return key
+}
@@ -0,0 +1,93 @@
+package sm2
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestVerifyCache(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &priv.PublicKey
+	c := NewVerifyCache(2)
+
+	var msgs [][]byte
+	var sigs [][]byte
+	for _, m := range []string{"a", "b", "c"} {
+		sig, err := SignEx(priv, []byte(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, []byte(m))
+		sigs = append(sigs, sig)
+	}
+
+	if !c.VerifyEx(pub, msgs[0], sigs[0]) || !c.VerifyEx(pub, msgs[0], sigs[0]) {
+		t.Fatal("valid signature rejected")
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Misses != 1 || st.Len != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+
+	// Lookups under other parameters miss and fail.
+	if c.VerifyEx(pub, msgs[1], sigs[0]) || c.VerifyEx(pub, msgs[0], sigs[0], WithUID([]byte("bob"))) {
+		t.Fatal("cache accepted a different message or user ID")
+	}
+	if c.VerifyEx(pub, msgs[1], sigs[0]) {
+		t.Fatal("invalid signature accepted on second try")
+	}
+	if st := c.Stats(); st.Hits != 1 || st.Len != 1 {
+		t.Fatalf("failed verification cached: %+v", st)
+	}
+
+	// a, b, then c evicts a.
+	c.VerifyEx(pub, msgs[1], sigs[1])
+	c.VerifyEx(pub, msgs[0], sigs[0])
+	c.VerifyEx(pub, msgs[2], sigs[2])
+	st := c.Stats()
+	if st.Evictions != 1 || st.Len != 2 {
+		t.Fatalf("stats after eviction %+v", st)
+	}
+	hits := st.Hits
+	c.VerifyEx(pub, msgs[0], sigs[0])
+	if c.Stats().Hits != hits+1 {
+		t.Fatal("recently used entry was evicted")
+	}
+
+	c.Purge()
+	if st := c.Stats(); st.Len != 0 {
+		t.Fatalf("Purge left %d entries", st.Len)
+	}
+}
+
+func TestVerifyCacheConcurrent(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("gossip")
+	sig, err := SignEx(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewVerifyCache(16)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if !c.VerifyEx(&priv.PublicKey, msg, sig) {
+					t.Error("valid signature rejected")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if st := c.Stats(); st.Hits+st.Misses != 80 || st.Len != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+}
@@ -0,0 +1,196 @@
+// Package vrf implements a verifiable random function on the SM2 curve.
+//
+// The construction is ECVRF of RFC 9381 with the try-and-increment
+// encode-to-curve method, instantiated on the SM2 curve with SM3 as the hash
+// function. It follows the ECVRF-P256-SHA256-TAI suite with SM2 and SM3
+// substituted:
+//
+//	suite_string = 0xF1 (not assigned by RFC 9381)
+//	points are 33-byte compressed SEC 1 strings, cofactor 1
+//	nonces are generated as in RFC 6979 with HMAC-SM3
+//	cLen = 16, so a proof is Gamma || c || s, 81 bytes
+//	the output beta = SM3(suite_string || 0x03 || Gamma || 0x00), 32 bytes
+//
+// The holder of an SM2 private key computes, for any input alpha, an output
+// beta that looks random to everyone else together with a proof pi that lets
+// anyone with the public key check that beta is the unique output for alpha.
+package vrf
+
+import (
+	"crypto/hmac"
+	"errors"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2/internal/ecpoint"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const (
+	// ProofSize is the size of a proof in bytes.
+	ProofSize = 33 + cLen + 32
+	// OutputSize is the size of a VRF output in bytes.
+	OutputSize = sm3.Size
+
+	suiteString = 0xF1
+	cLen        = 16
+)
+
+var (
+	errInvalidKey = errors.New("vrf: invalid key")
+	errProof      = errors.New("vrf: malformed proof")
+	// ErrInvalidProof is returned by Verify for a proof that does not verify.
+	ErrInvalidProof = errors.New("vrf: invalid proof")
+)
+
+func publicPoint(pub *sm2.PublicKey) (*ecpoint.Point, error) {
+	p, ok := ecpoint.FromPublicKey(pub)
+	if !ok {
+		return nil, errInvalidKey
+	}
+	return p, nil
+}
+
+// encodeToCurve maps alpha to a curve point with the try-and-increment
+// method, salted with the public key.
+func encodeToCurve(pk, alpha []byte) (*ecpoint.Point, error) {
+	for ctr := 0; ctr < 256; ctr++ {
+		h := sm3.New()
+		h.Write([]byte{suiteString, 0x01})
+		h.Write(pk)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+		if p, err := ecpoint.Decompress(append([]byte{2}, h.Sum(nil)...), false); err == nil {
+			return p, nil
+		}
+	}
+	return nil, errors.New("vrf: encode to curve failed")
+}
+
+// nonce generates the nonce for the proof of x over h as in RFC 6979
+// section 3.2.
+func nonce(x *big.Int, h *ecpoint.Point) *big.Int {
+	n := ecpoint.Order()
+	h1 := new(big.Int).SetBytes(sm3.Sm3Sum(h.Compress()))
+	h1.Mod(h1, n)
+	xo, ho := ecpoint.ScalarBytes(x), ecpoint.ScalarBytes(h1)
+
+	k := make([]byte, sm3.Size)
+	v := make([]byte, sm3.Size)
+	for i := range v {
+		v[i] = 1
+	}
+	mac := func(key []byte, data ...[]byte) []byte {
+		m := hmac.New(sm3.New, key)
+		for _, d := range data {
+			m.Write(d)
+		}
+		return m.Sum(nil)
+	}
+	k = mac(k, v, []byte{0}, xo, ho)
+	v = mac(k, v)
+	k = mac(k, v, []byte{1}, xo, ho)
+	v = mac(k, v)
+	for {
+		v = mac(k, v)
+		t := new(big.Int).SetBytes(v)
+		if t.Sign() > 0 && t.Cmp(n) < 0 {
+			return t
+		}
+		k = mac(k, v, []byte{0})
+		v = mac(k, v)
+	}
+}
+
+// challenge returns the truncated hash of the points.
+func challenge(points ...*ecpoint.Point) *big.Int {
+	h := sm3.New()
+	h.Write([]byte{suiteString, 0x02})
+	for _, p := range points {
+		h.Write(p.Compress())
+	}
+	h.Write([]byte{0x00})
+	return new(big.Int).SetBytes(h.Sum(nil)[:cLen])
+}
+
+// Prove returns the proof pi for the input alpha under priv.
+func Prove(priv *sm2.PrivateKey, alpha []byte) ([]byte, error) {
+	if priv == nil || priv.D == nil || priv.D.Sign() <= 0 || priv.D.Cmp(ecpoint.Order()) >= 0 {
+		return nil, errInvalidKey
+	}
+	y := ecpoint.BaseMul(priv.D)
+	h, err := encodeToCurve(y.Compress(), alpha)
+	if err != nil {
+		return nil, err
+	}
+	gamma := h.Mul(priv.D)
+	k := nonce(priv.D, h)
+	c := challenge(y, h, gamma, ecpoint.BaseMul(k), h.Mul(k))
+	// s = k + c·x
+	s := new(big.Int).Mul(c, priv.D)
+	s.Add(s, k)
+	s.Mod(s, ecpoint.Order())
+
+	pi := make([]byte, 0, ProofSize)
+	pi = append(pi, gamma.Compress()...)
+	pi = append(pi, ecpoint.ScalarBytes(c)[32-cLen:]...)
+	return append(pi, ecpoint.ScalarBytes(s)...), nil
+}
+
+func decodeProof(pi []byte) (gamma *ecpoint.Point, c, s *big.Int, err error) {
+	if len(pi) != ProofSize {
+		return nil, nil, nil, errProof
+	}
+	if gamma, err = ecpoint.Decompress(pi[:33], false); err != nil {
+		return nil, nil, nil, errProof
+	}
+	c = new(big.Int).SetBytes(pi[33 : 33+cLen])
+	s = new(big.Int).SetBytes(pi[33+cLen:])
+	if s.Cmp(ecpoint.Order()) >= 0 {
+		return nil, nil, nil, errProof
+	}
+	return gamma, c, s, nil
+}
+
+// ProofToHash returns the VRF output beta of the proof pi. It does not
+// verify the proof; use Verify for output from an untrusted source.
+func ProofToHash(pi []byte) ([]byte, error) {
+	gamma, _, _, err := decodeProof(pi)
+	if err != nil {
+		return nil, err
+	}
+	return gammaToHash(gamma), nil
+}
+
+func gammaToHash(gamma *ecpoint.Point) []byte {
+	h := sm3.New()
+	h.Write([]byte{suiteString, 0x03})
+	h.Write(gamma.Compress())
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// Verify checks the proof pi for the input alpha under pub and returns the
+// VRF output beta.
+func Verify(pub *sm2.PublicKey, alpha, pi []byte) ([]byte, error) {
+	y, err := publicPoint(pub)
+	if err != nil {
+		return nil, err
+	}
+	gamma, c, s, err := decodeProof(pi)
+	if err != nil {
+		return nil, err
+	}
+	h, err := encodeToCurve(y.Compress(), alpha)
+	if err != nil {
+		return nil, err
+	}
+	// U = s·B - c·Y, V = s·H - c·Gamma
+	negC := new(big.Int).Sub(ecpoint.Order(), c)
+	u := ecpoint.BaseMul(s).Add(y.Mul(negC))
+	v := h.Mul(s).Add(gamma.Mul(negC))
+	if challenge(y, h, gamma, u, v).Cmp(c) != 0 {
+		return nil, ErrInvalidProof
+	}
+	return gammaToHash(gamma), nil
+}
@@ -0,0 +1,107 @@
+package vrf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func testKey() *sm2.PrivateKey {
+	d, _ := new(big.Int).SetString("3945208F7B2144B13F36E38AC6D39F95889393692860B51A42FB81EF4DF7C5B8", 16)
+	priv := new(sm2.PrivateKey)
+	priv.Curve = sm2.P256Sm2()
+	priv.D = d
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(d.Bytes())
+	return priv
+}
+
+func TestProveVerify(t *testing.T) {
+	priv := testKey()
+	for _, alpha := range [][]byte{nil, []byte("sample"), bytes.Repeat([]byte{0xab}, 1000)} {
+		pi, err := Prove(priv, alpha)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pi) != ProofSize {
+			t.Fatalf("proof is %d bytes, want %d", len(pi), ProofSize)
+		}
+		beta, err := Verify(&priv.PublicKey, alpha, pi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := ProofToHash(pi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(beta, hash) || len(beta) != OutputSize {
+			t.Fatal("Verify and ProofToHash disagree")
+		}
+
+		// Proofs are deterministic.
+		pi2, _ := Prove(priv, alpha)
+		if !bytes.Equal(pi, pi2) {
+			t.Fatal("proof is not deterministic")
+		}
+	}
+}
+
+// TestVector pins the output for a fixed key so that the suite does not
+// change by accident.
+func TestVector(t *testing.T) {
+	pi, err := Prove(testKey(), []byte("sample"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decodeHex("030bef223749a0c04dcb7e935f7d576736b984a7c4b90c7ee4e04153fca7517f22" +
+		"46a46fd833657934ddeeab209d868f42" +
+		"13c59ecf131633d4dc3f3e8311a586118dbed345dfb7f46f80cbb4dfd93feacb")
+	if !bytes.Equal(pi, want) {
+		t.Fatalf("pi = %x, want %x", pi, want)
+	}
+	beta, _ := ProofToHash(pi)
+	if want := decodeHex("5f5068a4503a217f88c5c937653631e8031d1d5a42d87f9246ceb31f8bbdf73a"); !bytes.Equal(beta, want) {
+		t.Fatalf("beta = %x, want %x", beta, want)
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	priv := testKey()
+	pi, _ := Prove(priv, []byte("sample"))
+	if _, err := Verify(&priv.PublicKey, []byte("other"), pi); err != ErrInvalidProof {
+		t.Fatalf("other input: got %v, want ErrInvalidProof", err)
+	}
+	other, _ := sm2.GenerateKey()
+	if _, err := Verify(&other.PublicKey, []byte("sample"), pi); err != ErrInvalidProof {
+		t.Fatalf("other key: got %v, want ErrInvalidProof", err)
+	}
+	for i := 0; i < len(pi); i += 9 {
+		bad := append([]byte(nil), pi...)
+		bad[i] ^= 1
+		if _, err := Verify(&priv.PublicKey, []byte("sample"), bad); err == nil {
+			t.Fatalf("accepted proof with byte %d flipped", i)
+		}
+	}
+	if _, err := Verify(&priv.PublicKey, []byte("sample"), pi[:ProofSize-1]); err == nil {
+		t.Fatal("accepted a short proof")
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	priv := testKey()
+	pi, _ := Prove(priv, []byte("sample"))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Verify(&priv.PublicKey, []byte("sample"), pi)
+	}
+}
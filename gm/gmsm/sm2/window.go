@@ -0,0 +1,9 @@
+//go:build !sm2small
+// +build !sm2small
+
+package sm2
+
+// sm2P256Window is the width in bits of the fixed window of
+// sm2P256ScalarMult, whose table of 1<<sm2P256Window Jacobian points takes
+// 1.7 KB of stack.
+const sm2P256Window = 4
@@ -23,7 +23,6 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/md5"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -220,8 +219,12 @@ func init() {
 	RegisterHash(SM3, sm3.New)
 }
 
-// HashFunc simply returns the value of h so that Hash implements SignerOpts.
+// HashFunc returns the crypto.Hash of h so that Hash implements SignerOpts.
+// SM3 maps to sm3.CryptoHash, the other values are shared with crypto.Hash.
 func (h Hash) HashFunc() crypto.Hash {
+	if h == SM3 {
+		return sm3.CryptoHash
+	}
 	return crypto.Hash(h)
 }
 
@@ -2444,7 +2447,7 @@ func ReadCertificateRequestFromPem(FileName string) (*CertificateRequest, error)
 }
 
 func CreateCertificateRequestToMem(template *CertificateRequest, privKey *PrivateKey) ([]byte, error) {
-	der, err := CreateCertificateRequest(rand.Reader, template, privKey)
+	der, err := CreateCertificateRequest(RandSource(), template, privKey)
 	if err != nil {
 		return nil, err
 	}
@@ -2457,7 +2460,7 @@ func CreateCertificateRequestToMem(template *CertificateRequest, privKey *Privat
 
 func CreateCertificateRequestToPem(FileName string, template *CertificateRequest,
 	privKey *PrivateKey) (bool, error) {
-	der, err := CreateCertificateRequest(rand.Reader, template, privKey)
+	der, err := CreateCertificateRequest(RandSource(), template, privKey)
 	if err != nil {
 		return false, err
 	}
@@ -2494,7 +2497,7 @@ func ReadCertificateFromPem(FileName string) (*Certificate, error) {
 }
 
 func CreateCertificateToMem(template, parent *Certificate, pubKey *PublicKey, privKey *PrivateKey) ([]byte, error) {
-	der, err := CreateCertificate(rand.Reader, template, parent, pubKey, privKey)
+	der, err := CreateCertificate(RandSource(), template, parent, pubKey, privKey)
 	if err != nil {
 		return nil, err
 	}
@@ -2506,7 +2509,7 @@ func CreateCertificateToMem(template, parent *Certificate, pubKey *PublicKey, pr
 }
 
 func CreateCertificateToPem(FileName string, template, parent *Certificate, pubKey *PublicKey, privKey *PrivateKey) (bool, error) {
-	der, err := CreateCertificate(rand.Reader, template, parent, pubKey, privKey)
+	der, err := CreateCertificate(RandSource(), template, parent, pubKey, privKey)
 	if err != nil {
 		return false, err
 	}
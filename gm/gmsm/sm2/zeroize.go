@@ -0,0 +1,46 @@
+package sm2
+
+import (
+	"crypto/cipher"
+	"math/big"
+)
+
+// zeroize overwrites b with zeros.
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// zeroizeInt overwrites the words of x with zeros and sets x to 0. Earlier
+// copies that big.Int made while growing x are not reachable to wipe, so
+// this is best effort.
+func zeroizeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	b := x.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+	x.SetInt64(0)
+}
+
+// Destroy overwrites the private scalar of the key, which must not be used
+// afterwards: with key validation on, operations on it fail with
+// ErrInvalidPrivateKey. Copies of the scalar made outside the package,
+// e.g. by D.Bytes() or MarshalSm2PrivateKey, are not affected.
+func (priv *PrivateKey) Destroy() {
+	zeroizeInt(priv.D)
+}
+
+// Destroy overwrites the secret scratch values of the signer: the entropy,
+// the AES key, the nonce and the values derived from it. Sign calls it
+// once the signature is encoded; the PrivateKey is left to its owner.
+func (signer *Signer) Destroy() {
+	zeroize(signer.Entropy)
+	zeroize(signer.AES_key)
+	signer.CSPRNG = cipher.StreamReader{}
+	zeroizeInt(signer.k)
+	zeroizeInt(signer.t)
+}
@@ -0,0 +1,74 @@
+package sm2
+
+import (
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+func TestDestroy(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := priv.D
+	words := d.Bits()
+	priv.Destroy()
+	if d.Sign() != 0 {
+		t.Fatal("D was not cleared")
+	}
+	for _, w := range words[:cap(words)] {
+		if w != 0 {
+			t.Fatal("the words of D were not overwritten")
+		}
+	}
+
+	SetKeyValidation(true)
+	defer SetKeyValidation(false)
+	if _, err := priv.Sign(nil, sm3.Sm3Sum(nil), nil); err != ErrInvalidPrivateKey {
+		t.Fatalf("expected ErrInvalidPrivateKey after Destroy, got %v", err)
+	}
+}
+
+func TestSignerDestroy(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &Signer{PrivateKey: *priv, Msg: sm3.Sm3Sum(nil)}
+	sig, err := signer.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Verify(signer.Msg, sig) {
+		t.Fatal("the signature does not verify")
+	}
+	for _, b := range [][]byte{signer.Entropy, signer.AES_key} {
+		for _, v := range b {
+			if v != 0 {
+				t.Fatal("a scratch buffer was not wiped")
+			}
+		}
+	}
+	if signer.k.Sign() != 0 {
+		t.Fatal("the nonce was not wiped")
+	}
+	if priv.D.Sign() == 0 {
+		t.Fatal("the signer wiped the private key")
+	}
+}
+
+func TestDecryptFailureReturnsNoPlaintext(t *testing.T) {
+	priv, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := Encrypt(&priv.PublicKey, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct[70] ^= 1
+	if pt, err := Decrypt(priv, ct); err == nil || pt != nil {
+		t.Fatalf("Decrypt returned %q, %v", pt, err)
+	}
+}
@@ -0,0 +1,142 @@
+package sm2age
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"strings"
+)
+
+const (
+	intro          = "sm2age-encryption/v1"
+	stanzaPrefix   = "-> "
+	footerPrefix   = "---"
+	columnsPerLine = 64
+)
+
+var b64 = base64.RawStdEncoding.Strict()
+
+type header struct {
+	stanzas []*Stanza
+	mac     []byte
+}
+
+// validArg reports whether s can be a stanza type or argument: non-empty
+// printable ASCII without spaces.
+func validArg(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Stanza) valid() bool {
+	if !validArg(s.Type) {
+		return false
+	}
+	for _, a := range s.Args {
+		if !validArg(a) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Stanza) marshal(b *bytes.Buffer) {
+	b.WriteString(stanzaPrefix)
+	b.WriteString(strings.Join(append([]string{s.Type}, s.Args...), " "))
+	b.WriteByte('\n')
+	body := b64.EncodeToString(s.Body)
+	for len(body) >= columnsPerLine {
+		b.WriteString(body[:columnsPerLine])
+		b.WriteByte('\n')
+		body = body[columnsPerLine:]
+	}
+	b.WriteString(body)
+	b.WriteByte('\n')
+}
+
+// marshalWithoutMAC returns the header up to and including "---", the
+// bytes the MAC covers.
+func (h *header) marshalWithoutMAC() []byte {
+	var b bytes.Buffer
+	b.WriteString(intro)
+	b.WriteByte('\n')
+	for _, s := range h.stanzas {
+		s.marshal(&b)
+	}
+	b.WriteString(footerPrefix)
+	return b.Bytes()
+}
+
+func (h *header) marshal() []byte {
+	b := h.marshalWithoutMAC()
+	b = append(b, ' ')
+	b = append(b, b64.EncodeToString(h.mac)...)
+	return append(b, '\n')
+}
+
+// readLine returns the next line of br without its newline. Lines longer
+// than the buffer of br are malformed, which bounds the memory a header
+// line can take.
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadSlice('\n')
+	if err != nil {
+		return "", ErrMalformedHeader
+	}
+	return string(line[:len(line)-1]), nil
+}
+
+// parseHeader reads a header from br, leaving br at the payload. It only
+// accepts the encoding marshal produces, so that the MAC computed over
+// marshalWithoutMAC covers the bytes that were read.
+func parseHeader(br *bufio.Reader) (*header, error) {
+	line, err := readLine(br)
+	if err != nil || line != intro {
+		return nil, ErrMalformedHeader
+	}
+	h := &header{}
+	for {
+		if line, err = readLine(br); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, footerPrefix+" ") {
+			mac, err := b64.DecodeString(line[len(footerPrefix)+1:])
+			if err != nil || len(mac) != 32 {
+				return nil, ErrMalformedHeader
+			}
+			h.mac = mac
+			return h, nil
+		}
+		if !strings.HasPrefix(line, stanzaPrefix) {
+			return nil, ErrMalformedHeader
+		}
+		args := strings.Split(line[len(stanzaPrefix):], " ")
+		s := &Stanza{Type: args[0], Args: args[1:]}
+		if !s.valid() {
+			return nil, ErrMalformedHeader
+		}
+		for {
+			if line, err = readLine(br); err != nil {
+				return nil, err
+			}
+			if len(line) > columnsPerLine {
+				return nil, ErrMalformedHeader
+			}
+			chunk, err := b64.DecodeString(line)
+			if err != nil {
+				return nil, ErrMalformedHeader
+			}
+			s.Body = append(s.Body, chunk...)
+			if len(line) < columnsPerLine {
+				break
+			}
+		}
+		h.stanzas = append(h.stanzas, s)
+	}
+}
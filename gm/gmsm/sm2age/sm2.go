@@ -0,0 +1,85 @@
+package sm2age
+
+import (
+	"crypto/elliptic"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const sm2StanzaType = "SM2"
+
+// sm2CiphertextSize is the size of the SM2 ciphertext of a file key:
+// 04 || x1 || y1 || C3 || C2.
+const sm2CiphertextSize = 65 + sm3.Size + fileKeySize
+
+// SM2Recipient wraps file keys for an SM2 public key.
+type SM2Recipient struct {
+	pub *sm2.PublicKey
+	tag string
+}
+
+// NewSM2Recipient returns the recipient of pub, which must be a valid SM2
+// public key.
+func NewSM2Recipient(pub *sm2.PublicKey) (*SM2Recipient, error) {
+	if err := pub.Validate(); err != nil {
+		return nil, err
+	}
+	return &SM2Recipient{pub: pub, tag: keyTag(pub)}, nil
+}
+
+// Wrap returns one SM2 stanza with fileKey encrypted to the public key.
+func (r *SM2Recipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	ct, err := sm2.Encrypt(r.pub, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return []*Stanza{{Type: sm2StanzaType, Args: []string{r.tag}, Body: ct}}, nil
+}
+
+// SM2Identity unwraps file keys with an SM2 private key.
+type SM2Identity struct {
+	priv *sm2.PrivateKey
+	tag  string
+}
+
+// NewSM2Identity returns the identity of priv.
+func NewSM2Identity(priv *sm2.PrivateKey) (*SM2Identity, error) {
+	if err := priv.PublicKey.Validate(); err != nil {
+		return nil, err
+	}
+	return &SM2Identity{priv: priv, tag: keyTag(&priv.PublicKey)}, nil
+}
+
+// Recipient returns the recipient files for the identity are encrypted to.
+func (i *SM2Identity) Recipient() *SM2Recipient {
+	return &SM2Recipient{pub: &i.priv.PublicKey, tag: i.tag}
+}
+
+// Unwrap decrypts the file key of the first SM2 stanza with the tag of the
+// identity that decrypts. A stanza that fails is skipped rather than fatal,
+// as tags of different keys may collide.
+func (i *SM2Identity) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type != sm2StanzaType {
+			continue
+		}
+		if len(s.Args) != 1 || len(s.Body) != sm2CiphertextSize {
+			return nil, ErrMalformedHeader
+		}
+		if s.Args[0] != i.tag {
+			continue
+		}
+		if fileKey, err := sm2.Decrypt(i.priv, s.Body); err == nil {
+			return fileKey, nil
+		}
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+// keyTag returns the stanza tag of pub: the first 4 bytes of the SM3 hash
+// of its uncompressed point, in base64.
+func keyTag(pub *sm2.PublicKey) string {
+	h := sm3.Sm3Sum(elliptic.Marshal(sm2.P256Sm2(), pub.X, pub.Y))
+	return b64.EncodeToString(h[:4])
+}
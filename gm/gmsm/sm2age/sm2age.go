@@ -0,0 +1,190 @@
+// Package sm2age encrypts files to one or more SM2 public keys, in the
+// manner of age (https://age-encryption.org/v1) with GM algorithms only.
+//
+// An encrypted file is a text header followed by a binary payload:
+//
+//	sm2age-encryption/v1
+//	-> SM2 <tag>
+//	<base64 of the SM2 ciphertext of the file key>
+//	-> SM2 <tag>
+//	...
+//	--- <base64 of the header MAC>
+//	<payload>
+//
+// The file key is 16 random bytes, wrapped for each recipient with SM2 in a
+// stanza whose tag is the first 4 bytes of the SM3 hash of the recipient's
+// uncompressed point. The tag only saves an identity from trying stanzas of
+// other recipients; it is not authenticated by itself. Stanza bodies are
+// standard base64 without padding, in lines of 64 columns, the last one
+// shorter, possibly empty.
+//
+// The MAC is HMAC-SM3 over the header up to and including "---", keyed with
+// HKDF-SM3(file key, info "header"), so that a header cannot be altered by
+// anyone without the file key. The payload is an sm4stream SM4-GCM stream
+// keyed with HKDF-SM3(file key, info "payload").
+package sm2age
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/xuperchain/crypto/gm/gmsm/hkdf"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4stream"
+)
+
+const fileKeySize = 16
+
+var (
+	// ErrIncorrectIdentity is returned by Decrypt when no identity can
+	// unwrap any stanza of the file.
+	ErrIncorrectIdentity = errors.New("sm2age: no identity matches a recipient")
+	// ErrMalformedHeader is returned for a header that does not follow the
+	// format.
+	ErrMalformedHeader = errors.New("sm2age: malformed header")
+	// ErrHeaderMAC is returned for a header that was modified.
+	ErrHeaderMAC = errors.New("sm2age: header MAC mismatch")
+
+	errNoRecipients = errors.New("sm2age: no recipients")
+	errStanza       = errors.New("sm2age: recipient returned an invalid stanza")
+)
+
+// Stanza is a recipient's entry in the header: a type, arguments and a body
+// carrying the wrapped file key.
+type Stanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+// Recipient wraps a file key for one party. Wrap may return several stanzas.
+type Recipient interface {
+	Wrap(fileKey []byte) ([]*Stanza, error)
+}
+
+// Identity unwraps a file key from the stanzas of a header. It returns
+// ErrIncorrectIdentity if none of them is for it.
+type Identity interface {
+	Unwrap(stanzas []*Stanza) ([]byte, error)
+}
+
+// Encrypt writes the header for recipients to dst and returns a writer of
+// the plaintext. Close must be called to write the last payload chunk; it
+// does not close dst.
+func Encrypt(dst io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errNoRecipients
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, err
+	}
+	defer zeroize(fileKey)
+	h := &header{}
+	for _, r := range recipients {
+		stanzas, err := r.Wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stanzas {
+			if !s.valid() {
+				return nil, errStanza
+			}
+		}
+		h.stanzas = append(h.stanzas, stanzas...)
+	}
+	mac, err := headerMAC(fileKey, h.marshalWithoutMAC())
+	if err != nil {
+		return nil, err
+	}
+	h.mac = mac
+	if _, err := dst.Write(h.marshal()); err != nil {
+		return nil, err
+	}
+	aead, err := payloadAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return sm4stream.NewWriter(aead, dst)
+}
+
+// Decrypt reads the header from src, unwraps the file key with the first
+// identity that can, checks the header MAC and returns a reader of the
+// plaintext. As with sm4stream, the reader reports a truncated or modified
+// payload as an error, and no data of a chunk before it is authenticated.
+// The reader is an *sm4stream.Reader, whose SkipChunks resumes an
+// interrupted decryption.
+func Decrypt(src io.Reader, identities ...Identity) (io.Reader, error) {
+	br := bufio.NewReader(src)
+	h, err := parseHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := unwrap(h.stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(fileKey)
+	mac, err := headerMAC(fileKey, h.marshalWithoutMAC())
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, h.mac) {
+		return nil, ErrHeaderMAC
+	}
+	aead, err := payloadAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return sm4stream.NewReader(aead, br)
+}
+
+func unwrap(stanzas []*Stanza, identities []Identity) ([]byte, error) {
+	for _, id := range identities {
+		fileKey, err := id.Unwrap(stanzas)
+		if err == ErrIncorrectIdentity {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(fileKey) != fileKeySize {
+			return nil, ErrMalformedHeader
+		}
+		return fileKey, nil
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func headerMAC(fileKey, header []byte) ([]byte, error) {
+	key, err := hkdf.Key(fileKey, nil, []byte("header"), sm3.Size)
+	if err != nil {
+		return nil, err
+	}
+	m := hmac.New(sm3.New, key)
+	m.Write(header)
+	return m.Sum(nil), nil
+}
+
+func payloadAEAD(fileKey []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(fileKey, nil, []byte("payload"), sm4.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
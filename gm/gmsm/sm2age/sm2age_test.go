@@ -0,0 +1,171 @@
+package sm2age
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4stream"
+)
+
+func newIdentity(t *testing.T) *SM2Identity {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := NewSM2Identity(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func encrypt(t *testing.T, msg []byte, recipients ...Recipient) []byte {
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(file []byte, identities ...Identity) ([]byte, error) {
+	r, err := Decrypt(bytes.NewReader(file), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	alice, bob, eve := newIdentity(t), newIdentity(t), newIdentity(t)
+	for _, size := range []int{0, 1, sm4stream.ChunkSize, 3*sm4stream.ChunkSize + 7} {
+		msg := make([]byte, size)
+		rand.Read(msg)
+		file := encrypt(t, msg, alice.Recipient(), bob.Recipient())
+		if !bytes.HasPrefix(file, []byte(intro+"\n-> SM2 "+alice.tag+"\n")) {
+			t.Fatalf("unexpected header\n%s", file[:100])
+		}
+		for _, id := range []*SM2Identity{alice, bob} {
+			pt, err := decrypt(file, eve, id)
+			if err != nil || !bytes.Equal(pt, msg) {
+				t.Fatalf("size %d: %v", size, err)
+			}
+		}
+		if _, err := decrypt(file, eve); err != ErrIncorrectIdentity {
+			t.Fatalf("size %d: got %v for a stranger", size, err)
+		}
+	}
+	if _, err := Encrypt(ioutil.Discard); err == nil {
+		t.Fatal("encrypted to no recipients")
+	}
+}
+
+func TestTamper(t *testing.T) {
+	alice, bob := newIdentity(t), newIdentity(t)
+	msg := []byte("quarterly key ceremony minutes")
+	file := encrypt(t, msg, alice.Recipient(), bob.Recipient())
+
+	// Dropping bob's stanza leaves a well-formed header alice can unwrap,
+	// but not one that matches the MAC.
+	lines := strings.SplitAfter(string(file), "\n")
+	dropped := strings.Join(append(append([]string(nil), lines[:5]...), lines[9:]...), "")
+	if _, err := decrypt([]byte(dropped), alice); err != ErrHeaderMAC {
+		t.Fatalf("dropped stanza: got %v", err)
+	}
+
+	body := bytes.Index(file, []byte("\n--- ")) + 1
+	for name, mutate := range map[string]func(b []byte){
+		"mac":     func(b []byte) { b[body+5] ^= 1 },
+		"payload": func(b []byte) { b[len(b)-1] ^= 1 },
+	} {
+		bad := append([]byte(nil), file...)
+		mutate(bad)
+		if pt, err := decrypt(bad, alice); err == nil {
+			t.Errorf("%s: decrypted to %q", name, pt)
+		}
+	}
+	if _, err := decrypt(file[:len(file)-20], alice); err == nil {
+		t.Error("truncated file decrypted")
+	}
+}
+
+func TestMalformedHeader(t *testing.T) {
+	alice := newIdentity(t)
+	file := string(encrypt(t, []byte("x"), alice.Recipient()))
+	for name, bad := range map[string]string{
+		"intro":        strings.Replace(file, "v1", "v2", 1),
+		"no footer":    intro + "\n-> SM2 AAAA\n\n",
+		"double space": strings.Replace(file, "-> SM2 ", "-> SM2  ", 1),
+		"crlf":         strings.Replace(file, "\n", "\r\n", 1),
+		"padding":      strings.Replace(file, "\n--- ", "=\n--- ", 1),
+		"long line":    intro + "\n-> X\n" + strings.Repeat("A", 68) + "\n--- \n",
+		"long header":  intro + "\n-> " + strings.Repeat("A", 8192) + "\n",
+		"empty":        "",
+	} {
+		if _, err := decrypt([]byte(bad), alice); err != ErrMalformedHeader {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+// plainRecipient is a test Recipient whose stanza carries the file key in
+// the clear, with a body of 48 bytes that fills a line exactly.
+type plainRecipient struct{}
+
+func (plainRecipient) Wrap(fileKey []byte) ([]*Stanza, error) {
+	body := append(append([]byte(nil), fileKey...), make([]byte, 32)...)
+	return []*Stanza{{Type: "plain", Args: []string{"a", "b"}, Body: body}}, nil
+}
+
+func (plainRecipient) Unwrap(stanzas []*Stanza) ([]byte, error) {
+	for _, s := range stanzas {
+		if s.Type == "plain" {
+			return s.Body[:fileKeySize], nil
+		}
+	}
+	return nil, ErrIncorrectIdentity
+}
+
+func TestCustomStanza(t *testing.T) {
+	alice := newIdentity(t)
+	msg := []byte("custom")
+	file := encrypt(t, msg, alice.Recipient(), plainRecipient{})
+	if !bytes.Contains(file, []byte("\n-> plain a b\n")) {
+		t.Fatal("custom stanza missing")
+	}
+	// A full last line is followed by an empty one.
+	var r plainRecipient
+	stanzas, _ := r.Wrap(make([]byte, fileKeySize))
+	var b bytes.Buffer
+	stanzas[0].marshal(&b)
+	if want := "-> plain a b\n" + strings.Repeat("A", 64) + "\n\n"; b.String() != want {
+		t.Fatalf("got %q", b.String())
+	}
+	for _, id := range []Identity{plainRecipient{}, alice} {
+		if pt, err := decrypt(file, id); err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("got %q, %v", pt, err)
+		}
+	}
+}
+
+type badRecipient struct{}
+
+func (badRecipient) Wrap([]byte) ([]*Stanza, error) {
+	return []*Stanza{{Type: "has space"}}, nil
+}
+
+func TestInvalidStanza(t *testing.T) {
+	if _, err := Encrypt(ioutil.Discard, badRecipient{}); err == nil {
+		t.Fatal("invalid stanza written")
+	}
+}
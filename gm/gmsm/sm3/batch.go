@@ -0,0 +1,54 @@
+package sm3
+
+import (
+	"runtime"
+	"sync"
+)
+
+// batchMinPerWorker keeps small batches on few goroutines, where the cost of
+// starting a goroutine would exceed the hashing itself.
+const batchMinPerWorker = 16
+
+// SumBatch returns the SM3 checksums of msgs, in the same order. The messages
+// are independent and are hashed concurrently on up to GOMAXPROCS goroutines.
+// There is no multi-lane SIMD implementation yet, so each message is hashed
+// by the generic code.
+func SumBatch(msgs [][]byte) [][32]byte {
+	out := make([][32]byte, len(msgs))
+
+	workers := runtime.GOMAXPROCS(0)
+	if max := (len(msgs) + batchMinPerWorker - 1) / batchMinPerWorker; workers > max {
+		workers = max
+	}
+	if workers <= 1 {
+		sumRange(msgs, out)
+		return out
+	}
+
+	var wg sync.WaitGroup
+	per := (len(msgs) + workers - 1) / workers
+	for start := 0; start < len(msgs); start += per {
+		end := start + per
+		if end > len(msgs) {
+			end = len(msgs)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			sumRange(msgs[start:end], out[start:end])
+		}(start, end)
+	}
+	wg.Wait()
+
+	return out
+}
+
+func sumRange(msgs [][]byte, out [][32]byte) {
+	var h SM3
+
+	for i, msg := range msgs {
+		h.Reset()
+		h.Write(msg)
+		h.Sum(out[i][:0])
+	}
+}
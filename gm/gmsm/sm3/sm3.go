@@ -16,10 +16,27 @@ limitations under the License.
 package sm3
 
 import (
+	"crypto"
 	"encoding/binary"
+	"errors"
 	"hash"
 )
 
+// CryptoHash identifies SM3 as the crypto.SignerOpts of a signature over an
+// SM3 digest, for example the opts of sm2.PrivateKey.Sign.
+//
+// CryptoHash is NOT a registered crypto.Hash. crypto.RegisterHash only
+// accepts the identifiers known to the standard library, so the value is
+// taken from outside that range (401 is the last arc of the SM3 OID). The
+// only supported use is comparing opts.HashFunc() with CryptoHash: its
+// Available method reports false, and its Size and New methods panic. Code
+// that resolves an arbitrary crypto.Hash must special-case CryptoHash and
+// use Size and New of this package instead.
+const CryptoHash crypto.Hash = 401
+
+// Size is the size of an SM3 checksum in bytes.
+const Size = 32
+
 type SM3 struct {
 	digest      [8]uint32 // digest represents the partial evaluation of V
 	length      uint64    // length of the message
@@ -152,7 +169,13 @@ func (sm3 *SM3) Reset() {
 	sm3.digest[7] = 0xb0fb0e4e
 
 	sm3.length = 0 // Reset numberic states
-	sm3.unhandleMsg = []byte{}
+	// The buffer is kept for the next message, without the last one, which
+	// may be secret.
+	buf := sm3.unhandleMsg[:cap(sm3.unhandleMsg)]
+	for i := range buf {
+		buf[i] = 0
+	}
+	sm3.unhandleMsg = buf[:0]
 }
 
 // Write, required by the hash.Hash interface.
@@ -162,12 +185,26 @@ func (sm3 *SM3) Write(p []byte) (int, error) {
 	toWrite := len(p)
 	sm3.length += uint64(len(p) * 8)
 
-	msg := append(sm3.unhandleMsg, p...)
-	nblocks := len(msg) / sm3.BlockSize()
-	sm3.update(msg, nblocks)
+	// Complete the buffered block, then hash the whole blocks of p in place
+	// rather than copying p after the buffered bytes.
+	if n := len(sm3.unhandleMsg); n > 0 {
+		fill := sm3.BlockSize() - n
+		if fill > len(p) {
+			fill = len(p)
+		}
+		sm3.unhandleMsg = append(sm3.unhandleMsg, p[:fill]...)
+		p = p[fill:]
+		if len(sm3.unhandleMsg) < sm3.BlockSize() {
+			return toWrite, nil
+		}
+		sm3.update(sm3.unhandleMsg, 1)
+		sm3.unhandleMsg = sm3.unhandleMsg[:0]
+	}
+	nblocks := len(p) / sm3.BlockSize()
+	sm3.update(p[:nblocks*sm3.BlockSize()], nblocks)
 
 	// Update unhandleMsg
-	sm3.unhandleMsg = msg[nblocks*sm3.BlockSize():]
+	sm3.unhandleMsg = append(sm3.unhandleMsg, p[nblocks*sm3.BlockSize():]...)
 
 	return toWrite, nil
 }
@@ -176,25 +213,77 @@ func (sm3 *SM3) Write(p []byte) (int, error) {
 // Sum appends the current hash to b and returns the resulting slice.
 // It does not change the underlying hash state.
 func (sm3 *SM3) Sum(in []byte) []byte {
-	sm3.Write(in)
-	msg := sm3.pad()
+	// Make a copy of sm3 so that the caller can keep writing and summing.
+	d := *sm3
+	// The padded last blocks fit in buf, which spares an allocation.
+	var buf [2 * 64]byte
+	d.unhandleMsg = append(buf[:0], sm3.unhandleMsg...)
+	msg := d.pad()
 
 	// Finialize
-	sm3.update(msg, len(msg)/sm3.BlockSize())
+	d.update(msg, len(msg)/d.BlockSize())
 
 	// save hash to in
-	needed := sm3.Size()
-	if cap(in)-len(in) < needed {
-		newIn := make([]byte, len(in), len(in)+needed)
-		copy(newIn, in)
-		in = newIn
+	var out [32]byte
+	for i := 0; i < 8; i++ {
+		binary.BigEndian.PutUint32(out[i*4:], d.digest[i])
+	}
+	return append(in, out[:]...)
+}
+
+const (
+	magic         = "sm3\x03"
+	marshaledSize = len(magic) + 8*4 + 64 + 8
+)
+
+// MarshalBinary, required by the encoding.BinaryMarshaler interface.
+// MarshalBinary saves the running hash state, so that the hashing of a long
+// input can be checkpointed and resumed later, possibly in another process.
+func (sm3 *SM3) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, marshaledSize)
+	b = append(b, magic...)
+	for i := 0; i < 8; i++ {
+		b = appendUint32(b, sm3.digest[i])
 	}
-	out := in[len(in) : len(in)+needed]
+	b = append(b, sm3.unhandleMsg...)
+	b = b[:len(b)+64-len(sm3.unhandleMsg)] // already zero
+	b = appendUint64(b, sm3.length)
+	return b, nil
+}
 
+// UnmarshalBinary, required by the encoding.BinaryUnmarshaler interface.
+// UnmarshalBinary restores a hash state saved by MarshalBinary.
+func (sm3 *SM3) UnmarshalBinary(b []byte) error {
+	if len(b) < len(magic) || string(b[:len(magic)]) != magic {
+		return errors.New("sm3: invalid hash state identifier")
+	}
+	if len(b) != marshaledSize {
+		return errors.New("sm3: invalid hash state size")
+	}
+	b = b[len(magic):]
 	for i := 0; i < 8; i++ {
-		binary.BigEndian.PutUint32(out[i*4:], sm3.digest[i])
+		sm3.digest[i] = binary.BigEndian.Uint32(b)
+		b = b[4:]
 	}
-	return out
+	block := b[:64]
+	sm3.length = binary.BigEndian.Uint64(b[64:])
+	if sm3.length%8 != 0 {
+		return errors.New("sm3: invalid hash state length")
+	}
+	sm3.unhandleMsg = append([]byte{}, block[:(sm3.length/8)%64]...)
+	return nil
+}
+
+func appendUint32(b []byte, x uint32) []byte {
+	var a [4]byte
+	binary.BigEndian.PutUint32(a[:], x)
+	return append(b, a[:]...)
+}
+
+func appendUint64(b []byte, x uint64) []byte {
+	var a [8]byte
+	binary.BigEndian.PutUint64(a[:], x)
+	return append(b, a[:]...)
 }
 
 func Sm3Sum(data []byte) []byte {
@@ -16,6 +16,10 @@ limitations under the License.
 package sm3
 
 import (
+	"bytes"
+	"crypto"
+	"encoding"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -63,3 +67,114 @@ func BenchmarkSm3(t *testing.B) {
 		Sm3Sum(msg)
 	}
 }
+
+func TestSm3SumKeepsState(t *testing.T) {
+	hw := New()
+	hw.Write([]byte("abc"))
+	first := hw.Sum(nil)
+	if second := hw.Sum(nil); !bytes.Equal(first, second) {
+		t.Fatalf("Sum changed the hash state: %x != %x", first, second)
+	}
+
+	// GB/T 32905 example 1
+	want, _ := hex.DecodeString("66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0")
+	if !bytes.Equal(first, want) {
+		t.Fatalf("sm3(\"abc\") = %x, want %x", first, want)
+	}
+
+	prefix := []byte{1, 2, 3}
+	if out := hw.Sum(prefix); !bytes.Equal(out[:3], prefix) || !bytes.Equal(out[3:], want) {
+		t.Fatalf("Sum did not append to its argument: %x", out)
+	}
+}
+
+func TestSm3MarshalBinary(t *testing.T) {
+	msg := bytes.Repeat([]byte("0123456789"), 20)
+	want := Sm3Sum(msg)
+
+	for split := 0; split <= len(msg); split += 7 {
+		h := New()
+		h.Write(msg[:split])
+		state, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resumed := New()
+		if err := resumed.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+		resumed.Write(msg[split:])
+		if got := resumed.Sum(nil); !bytes.Equal(got, want) {
+			t.Fatalf("split %d: resumed hash %x, want %x", split, got, want)
+		}
+	}
+
+	if err := New().(encoding.BinaryUnmarshaler).UnmarshalBinary([]byte("sha\x03")); err == nil {
+		t.Fatal("expected error for foreign hash state")
+	}
+}
+
+func TestSumBatch(t *testing.T) {
+	msgs := make([][]byte, 100)
+	for i := range msgs {
+		msgs[i] = bytes.Repeat([]byte{byte(i)}, i)
+	}
+	for i, sum := range SumBatch(msgs) {
+		if want := Sm3Sum(msgs[i]); !bytes.Equal(sum[:], want) {
+			t.Fatalf("message %d: %x, want %x", i, sum, want)
+		}
+	}
+}
+
+func TestSm3Write(t *testing.T) {
+	msg := make([]byte, 300)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	want := Sm3Sum(msg)
+	for _, step := range []int{1, 3, 63, 64, 65, 130} {
+		h := New()
+		for i := 0; i < len(msg); i += step {
+			end := i + step
+			if end > len(msg) {
+				end = len(msg)
+			}
+			h.Write(msg[i:end])
+		}
+		if got := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Fatalf("writes of %d bytes: %x, want %x", step, got, want)
+		}
+	}
+
+	h := New()
+	h.Write(msg[:10])
+	if n := testing.AllocsPerRun(10, func() { h.Write(msg) }); n != 0 {
+		t.Fatalf("Write allocates %v times", n)
+	}
+}
+
+// TestCryptoHashUnregistered pins that CryptoHash is only an identifier:
+// crypto.Hash cannot size or construct it.
+func TestCryptoHashUnregistered(t *testing.T) {
+	if CryptoHash.Available() {
+		t.Fatal("CryptoHash.Available() = true, want false")
+	}
+	for name, f := range map[string]func(){
+		"Size": func() { CryptoHash.Size() },
+		"New":  func() { CryptoHash.New() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CryptoHash.%s did not panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+	var opts crypto.SignerOpts = CryptoHash
+	if opts.HashFunc() != CryptoHash {
+		t.Fatal("HashFunc() does not identify CryptoHash")
+	}
+}
@@ -0,0 +1,111 @@
+package sm3
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Domain separation prefixes of the tree hash.
+const (
+	treeLeafPrefix = 0x00
+	treeNodePrefix = 0x01
+)
+
+var errTreeHashParams = errors.New("sm3: chunkSize and workers must be positive")
+
+type treeChunk struct {
+	index int
+	data  []byte
+}
+
+// TreeHash hashes the content of r in chunks of chunkSize bytes using up to
+// workers goroutines, and combines the chunk hashes in a binary tree:
+//
+//	leaf = SM3(0x00 || chunk)
+//	node = SM3(0x01 || left || right)
+//
+// Leaves are paired from left to right at each level; an unpaired last node is
+// promoted to the next level unchanged. An empty input is hashed as a single
+// empty leaf. The result depends on chunkSize but not on workers, so both sides
+// of a verification must agree on the chunk size.
+func TreeHash(r io.Reader, chunkSize int, workers int) ([]byte, error) {
+	if chunkSize <= 0 || workers <= 0 {
+		return nil, errTreeHashParams
+	}
+
+	var (
+		mu     sync.Mutex
+		leaves [][]byte
+		wg     sync.WaitGroup
+	)
+	chunks := make(chan treeChunk, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				leaf := treeLeaf(c.data)
+				mu.Lock()
+				leaves[c.index] = leaf
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var err error
+	for index := 0; ; index++ {
+		buf := make([]byte, chunkSize)
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 || index == 0 {
+			mu.Lock()
+			leaves = append(leaves, nil)
+			mu.Unlock()
+			chunks <- treeChunk{index: index, data: buf[:n]}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			err = rerr
+			break
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, treeNode(level[i], level[i+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		level = next
+	}
+	return level[0], nil
+}
+
+func treeLeaf(chunk []byte) []byte {
+	var h SM3
+
+	h.Reset()
+	h.Write([]byte{treeLeafPrefix})
+	h.Write(chunk)
+	return h.Sum(nil)
+}
+
+func treeNode(left, right []byte) []byte {
+	var h SM3
+
+	h.Reset()
+	h.Write([]byte{treeNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
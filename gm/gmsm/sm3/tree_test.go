@@ -0,0 +1,59 @@
+package sm3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTreeHash(t *testing.T) {
+	data := bytes.Repeat([]byte("tree"), 1000)
+
+	want, err := TreeHash(bytes.NewReader(data), 256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{2, 3, 8} {
+		got, err := TreeHash(bytes.NewReader(data), 256, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("workers %d: %x, want %x", workers, got, want)
+		}
+	}
+
+	single, _ := TreeHash(bytes.NewReader(data[:100]), 256, 4)
+	if !bytes.Equal(single, treeLeaf(data[:100])) {
+		t.Fatal("single chunk input must hash to its leaf")
+	}
+}
+
+// The digests were computed with an independent implementation, over
+// prefixes of "0123456789abcdefghij" hashed in chunks of 4 bytes: trees of 1,
+// 2, 3 and 5 leaves, with the empty input as a single empty leaf.
+func TestTreeHashVectors(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	tests := []struct {
+		size int
+		want string
+	}{
+		{0, "2daef60e7a0b8f5e024c81cd2ab3109f2b4f155cf83adeb2ae5532f74a157fdf"},
+		{4, "e08f1fe3d0d476641cabc35ee4c0c4667fb67854eb53233735b7babaa74b16e1"},
+		{8, "13e89e23afe63ab80f2587e79821c49e07417d57e86757b7edfa67067c4a6fee"},
+		// The third leaf is promoted past the first level.
+		{12, "b97ca022f032af481b338d100b3eec5c4715543709e45b40cbb5b976d4e8635b"},
+		// A short last chunk is a leaf of its own.
+		{10, "d27b2aac2eb9fa34fcc5df278a19aadd3a5c7f59107eb4ebaa6cf778a74dfbd4"},
+		{20, "b7c5d0b518b516b7b72b21c07d6c673566fda665e4dd0fff9be89c4edd700d35"},
+	}
+	for _, tt := range tests {
+		got, err := TreeHash(bytes.NewReader(data[:tt.size]), 4, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("%d bytes: %x, want %s", tt.size, got, tt.want)
+		}
+	}
+}
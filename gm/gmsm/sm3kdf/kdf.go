@@ -0,0 +1,198 @@
+// Package sm3kdf implements the key derivation function of GB/T 32918.4
+// (SM2 public key encryption), based on the SM3 hash:
+//
+//	K = SM3(Z || ct_1) || SM3(Z || ct_2) || ...
+//
+// where ct_i is a 32-bit big-endian counter starting from 1.
+package sm3kdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+const maxLength = (1<<32 - 1) * 32
+
+var (
+	// ErrAllZero is returned when the derived key consists of zero bytes only,
+	// in which case GB/T 32918.4 requires a new ephemeral key to be chosen.
+	ErrAllZero = errors.New("sm3kdf: derived key is all zero")
+	// ErrLength is returned for a negative or too large output length.
+	ErrLength = errors.New("sm3kdf: invalid key length")
+)
+
+// Derive returns length bytes of keying material derived from the shared
+// secret z. The caller's z is never modified.
+func Derive(z []byte, length int) ([]byte, error) {
+	if length < 0 || uint64(length) > maxLength {
+		return nil, ErrLength
+	}
+	out, err := AppendDerive(make([]byte, 0, length), z, length)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AppendDerive is Derive appending the keying material to dst, which it
+// reuses if it has room. On error dst is returned unchanged.
+func AppendDerive(dst, z []byte, length int) ([]byte, error) {
+	if length < 0 || uint64(length) > maxLength {
+		return dst, ErrLength
+	}
+	n := len(dst)
+	if cap(dst)-n < length {
+		grown := make([]byte, n, n+length)
+		copy(grown, dst)
+		dst = grown
+	}
+	out := dst[n : n+length]
+
+	r := getReader(z)
+	r.Read(out)
+	putReader(r)
+
+	var acc byte
+	for _, b := range out {
+		acc |= b
+	}
+	if acc == 0 && length > 0 {
+		return dst[:n], ErrAllZero
+	}
+	return dst[:n+length], nil
+}
+
+// XORKeyStream sets dst to src XOR the len(src) bytes of keying material
+// derived from z, as SM2 encryption computes C2, without a buffer for the
+// keying material. dst must be at least as long as src, and may overlap it
+// only if it starts at the same byte. It returns ErrAllZero, with dst
+// unspecified, if the keying material is all zero.
+func XORKeyStream(dst, src, z []byte) error {
+	if uint64(len(src)) > maxLength {
+		return ErrLength
+	}
+	r := getReader(z)
+	defer putReader(r)
+
+	var acc byte
+	for i := 0; i < len(src); {
+		if len(r.block) == 0 {
+			r.fill()
+		}
+		n := len(r.block)
+		if n > len(src)-i {
+			n = len(src) - i
+		}
+		for j, b := range r.block[:n] {
+			acc |= b
+			dst[i+j] = src[i+j] ^ b
+		}
+		r.block = r.block[n:]
+		i += n
+	}
+	if acc == 0 && len(src) > 0 {
+		return ErrAllZero
+	}
+	return nil
+}
+
+// NewReader returns a reader that streams the keying material derived from z.
+// It returns io.EOF once the counter is exhausted.
+func NewReader(z []byte) io.Reader {
+	r := &reader{h: sm3.New()}
+	r.reset(z)
+	return r
+}
+
+// readerPool holds the readers of AppendDerive and XORKeyStream, whose
+// hash state and buffers are reused across calls.
+var readerPool = sync.Pool{
+	New: func() interface{} { return &reader{h: sm3.New()} },
+}
+
+func getReader(z []byte) *reader {
+	r := readerPool.Get().(*reader)
+	r.reset(z)
+	return r
+}
+
+// putReader wipes the secret and keying material of r and pools it.
+func putReader(r *reader) {
+	for i := range r.z {
+		r.z[i] = 0
+	}
+	for i := range r.buf {
+		r.buf[i] = 0
+	}
+	r.h.Reset()
+	readerPool.Put(r)
+}
+
+// NewReaderAt is NewReader for the keying material from byte offset on, so
+// that the parts of a long key can be derived on several goroutines. The
+// reader returns io.EOF at once if offset is negative or past the last byte.
+func NewReaderAt(z []byte, offset int64) io.Reader {
+	r := NewReader(z).(*reader)
+	if offset < 0 || uint64(offset) >= maxLength {
+		r.done = true
+		return r
+	}
+	r.counter = uint32(offset/32) + 1
+	if skip := offset % 32; skip != 0 {
+		r.fill()
+		r.block = r.block[skip:]
+	}
+	return r
+}
+
+type reader struct {
+	h       hash.Hash
+	z       []byte
+	counter uint32
+	ct      [4]byte
+	buf     [sm3.Size]byte
+	block   []byte
+	done    bool
+}
+
+// reset makes r stream the keying material of z from the start.
+func (r *reader) reset(z []byte) {
+	r.z = append(r.z[:0], z...)
+	r.counter = 1
+	r.block = nil
+	r.done = false
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.block) == 0 {
+			if r.done {
+				return n, io.EOF
+			}
+			r.fill()
+		}
+		c := copy(p[n:], r.block)
+		r.block = r.block[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// fill sets the block to SM3(z || counter) and advances the counter.
+func (r *reader) fill() {
+	binary.BigEndian.PutUint32(r.ct[:], r.counter)
+	r.h.Reset()
+	r.h.Write(r.z)
+	r.h.Write(r.ct[:])
+	r.block = r.h.Sum(r.buf[:0])
+	if r.counter == 1<<32-1 {
+		r.done = true
+	}
+	r.counter++
+}
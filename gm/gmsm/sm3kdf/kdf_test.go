@@ -0,0 +1,105 @@
+package sm3kdf
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestDeriveDoesNotMutateInput(t *testing.T) {
+	backing := make([]byte, 64, 128)
+	for i := range backing {
+		backing[i] = byte(i)
+	}
+	z := backing[:32]
+	tail := append([]byte{}, backing[32:]...)
+
+	key, err := Derive(z, 70)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 70 {
+		t.Fatalf("got %d bytes, want 70", len(key))
+	}
+	if !bytes.Equal(backing[32:64], tail) {
+		t.Fatal("Derive wrote into the backing array of z")
+	}
+
+	streamed := make([]byte, 70)
+	if _, err := io.ReadFull(NewReader(z), streamed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, streamed) {
+		t.Fatalf("Derive and NewReader disagree:\n%x\n%x", key, streamed)
+	}
+}
+
+func TestDeriveLength(t *testing.T) {
+	if _, err := Derive(nil, -1); err != ErrLength {
+		t.Fatalf("expected ErrLength, got %v", err)
+	}
+	if key, err := Derive([]byte("z"), 0); err != nil || len(key) != 0 {
+		t.Fatalf("zero length: %x, %v", key, err)
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+	z := []byte("shared secret")
+	key, err := Derive(z, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int64{0, 1, 31, 32, 33, 100, 199} {
+		got := make([]byte, 200-offset)
+		if _, err := io.ReadFull(NewReaderAt(z, offset), got); err != nil {
+			t.Fatalf("offset %d: %v", offset, err)
+		}
+		if !bytes.Equal(got, key[offset:]) {
+			t.Fatalf("offset %d: keying material differs", offset)
+		}
+	}
+	for _, offset := range []int64{-1, maxLength} {
+		if n, err := NewReaderAt(z, offset).Read(make([]byte, 1)); n != 0 || err != io.EOF {
+			t.Fatalf("offset %d: read %d bytes, %v", offset, n, err)
+		}
+	}
+}
+
+func TestAppendDeriveAndXOR(t *testing.T) {
+	z := []byte("shared secret")
+	key, err := Derive(z, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := []byte("prefix")
+	for _, dst := range [][]byte{prefix, append(make([]byte, 0, 200), prefix...)} {
+		out, err := AppendDerive(dst, z, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out[:6], prefix) || !bytes.Equal(out[6:], key) {
+			t.Fatalf("AppendDerive(cap %d) = %x", cap(dst), out)
+		}
+	}
+	if out, err := AppendDerive(prefix, z, -1); err != ErrLength || !bytes.Equal(out, prefix) {
+		t.Fatalf("negative length: %x, %v", out, err)
+	}
+
+	src := make([]byte, 100)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	dst := make([]byte, 100)
+	if err := XORKeyStream(dst, src, z); err != nil {
+		t.Fatal(err)
+	}
+	for i := range dst {
+		if dst[i] != src[i]^key[i] {
+			t.Fatalf("XORKeyStream byte %d", i)
+		}
+	}
+	if err := XORKeyStream(dst, dst, z); err != nil || !bytes.Equal(dst, src) {
+		t.Fatalf("in-place XORKeyStream did not invert: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"math/bits"
+)
+
+// The bitsliced backend evaluates the S-box as a boolean circuit instead of a
+// table lookup, so that no memory access depends on the key or the data. The
+// S-box is S(x) = A*inv(A*x + 0xd3) + 0xd3 over GF(2^8) modulo
+// x^8+x^7+x^6+x^5+x^4+x^2+1, where A is the circulant matrix built from 0xa7.
+//
+// Each of the 8 bit planes holds one bit of up to 32 S-box inputs, which is
+// the 4 bytes of a round word for 8 blocks.
+
+// bitslicedBlocks is the number of blocks processed in parallel.
+const bitslicedBlocks = 8
+
+// sboxAffine is the matrix A, row i being the input mask of output bit i.
+var sboxAffine = [8]byte{0xa7, 0x4f, 0x9e, 0x3d, 0x7a, 0xf4, 0xe9, 0xd3}
+
+const sboxConst = 0xd3
+
+type planes [8]uint32
+
+// affine returns A*x + sboxConst on every slice of x.
+func affine(x *planes) planes {
+	var y planes
+	for i := 0; i < 8; i++ {
+		row := sboxAffine[i]
+		for j := 0; j < 8; j++ {
+			// the matrix is public, branching on it leaks nothing
+			if row>>uint(j)&1 == 1 {
+				y[i] ^= x[j]
+			}
+		}
+		if sboxConst>>uint(i)&1 == 1 {
+			y[i] = ^y[i]
+		}
+	}
+	return y
+}
+
+// gfMul multiplies a and b in GF(2^8) on every slice.
+func gfMul(a, b *planes) planes {
+	var c [15]uint32
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			c[i+j] ^= a[i] & b[j]
+		}
+	}
+	// x^8 = x^7 + x^6 + x^5 + x^4 + x^2 + 1
+	for k := 14; k >= 8; k-- {
+		t := c[k]
+		c[k-1] ^= t
+		c[k-2] ^= t
+		c[k-3] ^= t
+		c[k-4] ^= t
+		c[k-6] ^= t
+		c[k-8] ^= t
+	}
+	var r planes
+	copy(r[:], c[:8])
+	return r
+}
+
+// gfInv returns x^254, the multiplicative inverse of x (0 for 0), on every slice.
+func gfInv(x *planes) planes {
+	x2 := gfMul(x, x)
+	x3 := gfMul(&x2, x)
+	x6 := gfMul(&x3, &x3)
+	x12 := gfMul(&x6, &x6)
+	x15 := gfMul(&x12, &x3)
+	x240 := x15
+	for i := 0; i < 4; i++ {
+		x240 = gfMul(&x240, &x240)
+	}
+	x252 := gfMul(&x240, &x12)
+	return gfMul(&x252, &x2)
+}
+
+func sboxPlanes(x *planes) planes {
+	y := affine(x)
+	y = gfInv(&y)
+	return affine(&y)
+}
+
+// tauBitsliced applies the S-box to each byte of the words in w.
+func tauBitsliced(w []uint32) {
+	var x planes
+	for k, v := range w {
+		for b := uint(0); b < 4; b++ {
+			by := v >> (8 * b)
+			pos := uint(k)*4 + b
+			for i := uint(0); i < 8; i++ {
+				x[i] |= (by >> i & 1) << pos
+			}
+		}
+	}
+
+	y := sboxPlanes(&x)
+
+	for k := range w {
+		var v uint32
+		for b := uint(0); b < 4; b++ {
+			pos := uint(k)*4 + b
+			var by uint32
+			for i := uint(0); i < 8; i++ {
+				by |= (y[i] >> pos & 1) << i
+			}
+			v |= by << (8 * b)
+		}
+		w[k] = v
+	}
+}
+
+func expandKeyBitsliced(key []byte, enc, dec *[32]uint32) {
+	var k [4]uint32
+	for i := 0; i < 4; i++ {
+		k[i] = binary.BigEndian.Uint32(key[4*i:]) ^ fk[i]
+	}
+	for i := 0; i < 32; i++ {
+		w := []uint32{k[1] ^ k[2] ^ k[3] ^ ck[i]}
+		tauBitsliced(w)
+		rk := k[0] ^ w[0] ^ bits.RotateLeft32(w[0], 13) ^ bits.RotateLeft32(w[0], 23)
+		enc[i] = rk
+		dec[31-i] = rk
+		k[0], k[1], k[2], k[3] = k[1], k[2], k[3], rk
+	}
+}
+
+// cryptBlocksBitsliced processes len(src)/BlockSize blocks, up to 8 at a time.
+func cryptBlocksBitsliced(rk *[32]uint32, dst, src []byte) {
+	for len(src) >= BlockSize {
+		n := len(src) / BlockSize
+		if n > bitslicedBlocks {
+			n = bitslicedBlocks
+		}
+
+		var x [4][bitslicedBlocks]uint32
+		for k := 0; k < n; k++ {
+			for j := 0; j < 4; j++ {
+				x[j][k] = binary.BigEndian.Uint32(src[k*BlockSize+4*j:])
+			}
+		}
+
+		var w [bitslicedBlocks]uint32
+		for i := 0; i < 32; i++ {
+			a, b, c, d := i%4, (i+1)%4, (i+2)%4, (i+3)%4
+			for k := 0; k < n; k++ {
+				w[k] = x[b][k] ^ x[c][k] ^ x[d][k] ^ rk[i]
+			}
+			tauBitsliced(w[:n])
+			for k := 0; k < n; k++ {
+				v := w[k]
+				x[a][k] ^= v ^ bits.RotateLeft32(v, 2) ^ bits.RotateLeft32(v, 10) ^ bits.RotateLeft32(v, 18) ^ bits.RotateLeft32(v, 24)
+			}
+		}
+
+		for k := 0; k < n; k++ {
+			binary.BigEndian.PutUint32(dst[k*BlockSize:], x[3][k])
+			binary.BigEndian.PutUint32(dst[k*BlockSize+4:], x[2][k])
+			binary.BigEndian.PutUint32(dst[k*BlockSize+8:], x[1][k])
+			binary.BigEndian.PutUint32(dst[k*BlockSize+12:], x[0][k])
+		}
+		dst, src = dst[n*BlockSize:], src[n*BlockSize:]
+	}
+}
+
+// NewBitslicedCipher is like NewCipher, but the returned cipher.Block never
+// looks up the S-box in a table, neither in the key schedule nor in the
+// rounds, which removes the cache-timing channel of the default backend at
+// the cost of speed. The CTR and CBC decryption modes of crypto/cipher
+// process 8 blocks at a time with it.
+func NewBitslicedCipher(key []byte) (cipher.Block, error) {
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+
+	c := &sm4Cipher{bitsliced: true}
+	expandKeyBitsliced(key, &c.enc, &c.dec)
+	return c, nil
+}
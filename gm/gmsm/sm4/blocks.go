@@ -0,0 +1,109 @@
+package sm4
+
+import (
+	"crypto/cipher"
+)
+
+// batchBlocks is the number of blocks handed to cryptBlocks at a time by the
+// multi-block modes below.
+const batchBlocks = 8
+
+// cryptBlocksGeneric processes len(src)/BlockSize blocks one by one.
+func cryptBlocksGeneric(rk *[32]uint32, dst, src []byte) {
+	for len(src) >= BlockSize {
+		cryptBlock(rk, dst, src)
+		dst, src = dst[BlockSize:], src[BlockSize:]
+	}
+}
+
+// NewCTR implements the optional interface used by cipher.NewCTR, so that
+// counter mode encrypts several blocks at a time.
+func (c *sm4Cipher) NewCTR(iv []byte) cipher.Stream {
+	if len(iv) != BlockSize {
+		panic("cipher.NewCTR: IV length must equal block size")
+	}
+	s := &ctr{c: c}
+	copy(s.counter[:], iv)
+	return s
+}
+
+type ctr struct {
+	c       *sm4Cipher
+	counter [BlockSize]byte
+	in      [batchBlocks * BlockSize]byte
+	out     [batchBlocks * BlockSize]byte
+	used    int
+	filled  bool
+}
+
+func (s *ctr) refill() {
+	for i := 0; i < batchBlocks; i++ {
+		copy(s.in[i*BlockSize:], s.counter[:])
+		for j := BlockSize - 1; j >= 0; j-- {
+			s.counter[j]++
+			if s.counter[j] != 0 {
+				break
+			}
+		}
+	}
+	s.c.cryptBlocks(&s.c.enc, s.out[:], s.in[:])
+	s.used = 0
+	s.filled = true
+}
+
+func (s *ctr) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("crypto/cipher: output smaller than input")
+	}
+	for len(src) > 0 {
+		if !s.filled || s.used == len(s.out) {
+			s.refill()
+		}
+		n := xorBytes(dst, src, s.out[s.used:])
+		s.used += n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// NewCBCDecrypter implements the optional interface used by
+// cipher.NewCBCDecrypter, so that CBC decryption runs several blocks at a time.
+func (c *sm4Cipher) NewCBCDecrypter(iv []byte) cipher.BlockMode {
+	if len(iv) != BlockSize {
+		panic("cipher.NewCBCDecrypter: IV length must equal block size")
+	}
+	d := &cbcDecrypter{c: c}
+	copy(d.iv[:], iv)
+	return d
+}
+
+type cbcDecrypter struct {
+	c   *sm4Cipher
+	iv  [BlockSize]byte
+	ct  [batchBlocks * BlockSize]byte
+	buf [batchBlocks * BlockSize]byte
+}
+
+func (d *cbcDecrypter) BlockSize() int { return BlockSize }
+
+func (d *cbcDecrypter) CryptBlocks(dst, src []byte) {
+	if len(src)%BlockSize != 0 {
+		panic("crypto/cipher: input not full blocks")
+	}
+	if len(dst) < len(src) {
+		panic("crypto/cipher: output smaller than input")
+	}
+
+	for len(src) > 0 {
+		n := len(d.buf)
+		if len(src) < n {
+			n = len(src)
+		}
+		// src may alias dst, so work on a copy of the ciphertext
+		copy(d.ct[:n], src[:n])
+		d.c.cryptBlocks(&d.c.dec, d.buf[:n], d.ct[:n])
+		xorBytes(dst[:BlockSize], d.buf[:BlockSize], d.iv[:])
+		xorBytes(dst[BlockSize:n], d.buf[BlockSize:n], d.ct[:n-BlockSize])
+		copy(d.iv[:], d.ct[n-BlockSize:n])
+		dst, src = dst[n:], src[n:]
+	}
+}
@@ -0,0 +1,84 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+var (
+	ErrInvalidPadding = errors.New("sm4: invalid padding")
+	errNotFullBlocks  = errors.New("sm4: input not full blocks")
+)
+
+// PKCS7Pad returns src padded to a multiple of the block size as described in
+// RFC 5652, section 6.3. A full block of padding is added when len(src) is
+// already a multiple of the block size.
+func PKCS7Pad(src []byte) []byte {
+	n := BlockSize - len(src)%BlockSize
+	out := make([]byte, len(src)+n)
+	copy(out, src)
+	for i := len(src); i < len(out); i++ {
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// PKCS7Unpad removes the PKCS#7 padding of src. The padding is checked in
+// constant time, so that the time taken does not reveal where a malformed
+// padding went wrong. It returns ErrInvalidPadding for any malformed input.
+func PKCS7Unpad(src []byte) ([]byte, error) {
+	if len(src) == 0 || len(src)%BlockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+
+	last := src[len(src)-BlockSize:]
+	n := last[BlockSize-1]
+
+	// good is 1 when 1 <= n <= BlockSize and the last n bytes all equal n.
+	good := 1 - subtle.ConstantTimeByteEq(n, 0)
+	good &= subtle.ConstantTimeLessOrEq(int(n), BlockSize)
+	for i := 0; i < BlockSize; i++ {
+		// inPad is 1 for the bytes that are covered by the padding.
+		inPad := subtle.ConstantTimeLessOrEq(BlockSize-i, int(n))
+		good &= subtle.ConstantTimeSelect(inPad, subtle.ConstantTimeByteEq(last[i], n), 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+
+	return src[:len(src)-int(n)], nil
+}
+
+// CBCEncrypt pads plaintext with PKCS#7 and encrypts it with SM4 in CBC mode.
+// The iv must be BlockSize bytes and unpredictable; it is not included in the
+// output.
+func CBCEncrypt(key, iv, plaintext []byte) ([]byte, error) {
+	block, err := newBlockWithIV(key, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	out := PKCS7Pad(plaintext)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out, nil
+}
+
+// CBCDecrypt decrypts ciphertext with SM4 in CBC mode and removes the PKCS#7
+// padding. All padding failures are reported as ErrInvalidPadding.
+//
+// CBC provides no integrity; unless the ciphertext is authenticated before
+// decryption, prefer an AEAD mode.
+func CBCDecrypt(key, iv, ciphertext []byte) ([]byte, error) {
+	block, err := newBlockWithIV(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%BlockSize != 0 {
+		return nil, errNotFullBlocks
+	}
+
+	out := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, ciphertext)
+	return PKCS7Unpad(out)
+}
@@ -0,0 +1,194 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errCCMNonceSize = errors.New("sm4: CCM nonce size must be between 7 and 13 bytes")
+	errCCMTagSize   = errors.New("sm4: CCM tag size must be an even number between 4 and 16")
+	errCCMTooLong   = errors.New("sm4: CCM message too long for the nonce size")
+	errOpen         = errors.New("sm4: message authentication failed")
+)
+
+// ccm implements the CCM mode of NIST SP 800-38C (RFC 3610).
+type ccm struct {
+	block     cipher.Block
+	nonceSize int
+	tagSize   int
+}
+
+// NewCCM returns SM4 in Counter with CBC-MAC mode as a cipher.AEAD. The
+// nonceSize (7 to 13 bytes) trades the nonce space against the maximum
+// message length of 2^(8*(15-nonceSize)) - 1 bytes; the tagSize is an even
+// number of bytes between 4 and 16. GB/T 36624 and most device profiles use a
+// 12-byte nonce and a 16-byte tag.
+func NewCCM(key []byte, nonceSize, tagSize int) (cipher.AEAD, error) {
+	if nonceSize < 7 || nonceSize > 13 {
+		return nil, errCCMNonceSize
+	}
+	if tagSize < 4 || tagSize > 16 || tagSize%2 != 0 {
+		return nil, errCCMTagSize
+	}
+
+	block, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ccm{block: block, nonceSize: nonceSize, tagSize: tagSize}, nil
+}
+
+func (c *ccm) NonceSize() int { return c.nonceSize }
+
+func (c *ccm) Overhead() int { return c.tagSize }
+
+// maxLength returns the largest message length for the nonce size.
+func (c *ccm) maxLength() uint64 {
+	l := uint(15 - c.nonceSize)
+	if l >= 8 {
+		return 1<<63 - 1
+	}
+	return 1<<(8*l) - 1
+}
+
+func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != c.nonceSize {
+		panic("sm4: incorrect nonce length given to CCM")
+	}
+	if uint64(len(plaintext)) > c.maxLength() {
+		panic(errCCMTooLong.Error())
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+c.tagSize)
+	tag := c.mac(nonce, plaintext, additionalData)
+	c.ctr(nonce, out[:len(plaintext)], plaintext)
+	copy(out[len(plaintext):], tag)
+	return ret
+}
+
+func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != c.nonceSize {
+		panic("sm4: incorrect nonce length given to CCM")
+	}
+	if len(ciphertext) < c.tagSize || uint64(len(ciphertext)-c.tagSize) > c.maxLength() {
+		return nil, errOpen
+	}
+
+	tag := ciphertext[len(ciphertext)-c.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-c.tagSize]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	c.ctr(nonce, out, ciphertext)
+	if subtle.ConstantTimeCompare(c.mac(nonce, out, additionalData), tag) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errOpen
+	}
+	return ret, nil
+}
+
+// counterBlock returns the counter block with index i.
+func (c *ccm) counterBlock(nonce []byte, i uint64) [BlockSize]byte {
+	var ctr [BlockSize]byte
+	ctr[0] = byte(14 - c.nonceSize)
+	copy(ctr[1:], nonce)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], i)
+	copy(ctr[1+c.nonceSize:], n[8-(15-c.nonceSize):])
+	return ctr
+}
+
+// ctr XORs src with the key stream starting at counter block 1.
+func (c *ccm) ctr(nonce, dst, src []byte) {
+	var ks [BlockSize]byte
+	for i := uint64(1); len(src) > 0; i++ {
+		ctr := c.counterBlock(nonce, i)
+		c.block.Encrypt(ks[:], ctr[:])
+		n := xorBytes(dst, src, ks[:])
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+// mac computes the encrypted CBC-MAC tag.
+func (c *ccm) mac(nonce, plaintext, additionalData []byte) []byte {
+	var b0 [BlockSize]byte
+	b0[0] = byte(8*((c.tagSize-2)/2) + (14 - c.nonceSize))
+	if len(additionalData) > 0 {
+		b0[0] |= 0x40
+	}
+	copy(b0[1:], nonce)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(plaintext)))
+	copy(b0[1+c.nonceSize:], n[8-(15-c.nonceSize):])
+
+	var y [BlockSize]byte
+	c.block.Encrypt(y[:], b0[:])
+
+	if len(additionalData) > 0 {
+		var prefix []byte
+		switch a := uint64(len(additionalData)); {
+		case a < 1<<16-1<<8:
+			prefix = []byte{byte(a >> 8), byte(a)}
+		case a <= 1<<32-1:
+			prefix = make([]byte, 6)
+			prefix[0], prefix[1] = 0xff, 0xfe
+			binary.BigEndian.PutUint32(prefix[2:], uint32(a))
+		default:
+			prefix = make([]byte, 10)
+			prefix[0], prefix[1] = 0xff, 0xff
+			binary.BigEndian.PutUint64(prefix[2:], a)
+		}
+		c.cbcMAC(&y, append(prefix, additionalData...))
+	}
+	c.cbcMAC(&y, plaintext)
+
+	s0 := c.counterBlock(nonce, 0)
+	c.block.Encrypt(s0[:], s0[:])
+	tag := make([]byte, c.tagSize)
+	xorBytes(tag, y[:c.tagSize], s0[:c.tagSize])
+	return tag
+}
+
+// cbcMAC absorbs data, zero padded to a full block, into the CBC-MAC state y.
+func (c *ccm) cbcMAC(y *[BlockSize]byte, data []byte) {
+	for len(data) > 0 {
+		n := xorBytes(y[:], y[:], data)
+		if n < BlockSize {
+			// xorBytes stops at the shorter input, the rest is zero padding
+			n = len(data)
+		}
+		c.block.Encrypt(y[:], y[:])
+		data = data[n:]
+	}
+}
+
+// xorBytes sets dst[i] = x[i] ^ y[i] for the length of the shorter input,
+// and returns that length.
+func xorBytes(dst, x, y []byte) int {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	for i := 0; i < n; i++ {
+		dst[i] = x[i] ^ y[i]
+	}
+	return n
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,199 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	gcmSIVNonceSize = 12
+	gcmSIVTagSize   = 16
+	gcmSIVMaxLength = 1 << 36
+)
+
+var errGCMSIVTooLong = errors.New("sm4: GCM-SIV input too long")
+
+// gcmSIV is the construction of AES-GCM-SIV (RFC 8452) instantiated with
+// SM4-128: per-nonce keys are derived from the key-generating key, the tag is
+// POLYVAL over the additional data and the plaintext encrypted with the
+// derived key, and the tag is the initial counter of the CTR encryption.
+type gcmSIV struct {
+	kgk cipher.Block
+}
+
+// NewGCMSIV returns SM4-GCM-SIV as a cipher.AEAD with 12-byte nonces and
+// 16-byte tags. Repeating a nonce only reveals whether the same message was
+// encrypted twice under it, which makes the mode usable where nonce
+// uniqueness cannot be guaranteed, e.g. by independent writers. It is not
+// interoperable with AES-GCM-SIV.
+func NewGCMSIV(key []byte) (cipher.AEAD, error) {
+	block, err := NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmSIV{kgk: block}, nil
+}
+
+func (g *gcmSIV) NonceSize() int { return gcmSIVNonceSize }
+
+func (g *gcmSIV) Overhead() int { return gcmSIVTagSize }
+
+// deriveKeys returns the message-authentication key and the encryption
+// cipher for nonce, RFC 8452 section 4.
+func (g *gcmSIV) deriveKeys(nonce []byte) ([16]byte, cipher.Block) {
+	var in, out [BlockSize]byte
+	var keys [32]byte
+	copy(in[4:], nonce)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint32(in[:4], uint32(i))
+		g.kgk.Encrypt(out[:], in[:])
+		copy(keys[8*i:], out[:8])
+	}
+
+	var authKey [16]byte
+	copy(authKey[:], keys[:16])
+	enc, _ := NewCipher(keys[16:])
+	return authKey, enc
+}
+
+func (g *gcmSIV) tag(authKey [16]byte, enc cipher.Block, nonce, plaintext, additionalData []byte) [16]byte {
+	p := newPolyval(authKey)
+	p.update(additionalData)
+	p.update(plaintext)
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[:8], uint64(len(additionalData))*8)
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(plaintext))*8)
+	p.update(lengths[:])
+
+	s := p.sum()
+	for i := range nonce {
+		s[i] ^= nonce[i]
+	}
+	s[15] &= 0x7f
+	enc.Encrypt(s[:], s[:])
+	return s
+}
+
+// gcmSIVCTR is the counter mode of RFC 8452: the counter is the first 32 bits of
+// the block, little endian, and wraps around.
+func gcmSIVCTR(enc cipher.Block, tag [16]byte, dst, src []byte) {
+	block := tag
+	block[15] |= 0x80
+	ctr := binary.LittleEndian.Uint32(block[:4])
+
+	var ks [BlockSize]byte
+	for len(src) > 0 {
+		binary.LittleEndian.PutUint32(block[:4], ctr)
+		enc.Encrypt(ks[:], block[:])
+		n := xorBytes(dst, src, ks[:])
+		dst, src = dst[n:], src[n:]
+		ctr++
+	}
+}
+
+func (g *gcmSIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("sm4: incorrect nonce length given to GCM-SIV")
+	}
+	if uint64(len(plaintext)) > gcmSIVMaxLength || uint64(len(additionalData)) > gcmSIVMaxLength {
+		panic(errGCMSIVTooLong.Error())
+	}
+
+	authKey, enc := g.deriveKeys(nonce)
+	tag := g.tag(authKey, enc, nonce, plaintext, additionalData)
+
+	ret, out := sliceForAppend(dst, len(plaintext)+gcmSIVTagSize)
+	gcmSIVCTR(enc, tag, out[:len(plaintext)], plaintext)
+	copy(out[len(plaintext):], tag[:])
+	return ret
+}
+
+func (g *gcmSIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != gcmSIVNonceSize {
+		panic("sm4: incorrect nonce length given to GCM-SIV")
+	}
+	if len(ciphertext) < gcmSIVTagSize || uint64(len(ciphertext)) > gcmSIVMaxLength+gcmSIVTagSize ||
+		uint64(len(additionalData)) > gcmSIVMaxLength {
+		return nil, errOpen
+	}
+
+	var tag [16]byte
+	copy(tag[:], ciphertext[len(ciphertext)-gcmSIVTagSize:])
+	ciphertext = ciphertext[:len(ciphertext)-gcmSIVTagSize]
+
+	authKey, enc := g.deriveKeys(nonce)
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	gcmSIVCTR(enc, tag, out, ciphertext)
+
+	expected := g.tag(authKey, enc, nonce, out, additionalData)
+	if subtle.ConstantTimeCompare(expected[:], tag[:]) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, errOpen
+	}
+	return ret, nil
+}
+
+// polyval computes POLYVAL of RFC 8452 through its relation with GHASH:
+// POLYVAL(H, X) = ByteReverse(GHASH(mulX_GHASH(ByteReverse(H)), ByteReverse(X))).
+type polyval struct {
+	h [2]uint64 // mulX_GHASH(ByteReverse(H)) in GHASH bit order
+	s [2]uint64
+}
+
+func newPolyval(key [16]byte) *polyval {
+	var h [2]uint64
+	for i := 0; i < 16; i++ {
+		h[i/8] |= uint64(key[15-i]) << (8 * uint(7-i%8))
+	}
+	// multiply by x in the GHASH representation
+	lsb := h[1] & 1
+	h[1] = h[1]>>1 | h[0]<<63
+	h[0] = h[0]>>1 ^ (0xe1<<56)&-lsb
+	return &polyval{h: h}
+}
+
+// update absorbs data, zero padded to a multiple of 16 bytes.
+func (p *polyval) update(data []byte) {
+	for len(data) > 0 {
+		var block [16]byte
+		n := copy(block[:], data)
+		data = data[n:]
+
+		var x [2]uint64
+		for i := 0; i < 16; i++ {
+			x[i/8] |= uint64(block[15-i]) << (8 * uint(7-i%8))
+		}
+		p.s[0] ^= x[0]
+		p.s[1] ^= x[1]
+		p.s = gfMul128(p.s, p.h)
+	}
+}
+
+func (p *polyval) sum() [16]byte {
+	var out [16]byte
+	for i := 0; i < 16; i++ {
+		out[15-i] = byte(p.s[i/8] >> (8 * uint(7-i%8)))
+	}
+	return out
+}
+
+// gfMul128 multiplies x and y in GF(2^128) with the GCM bit order, without
+// data dependent branches.
+func gfMul128(x, y [2]uint64) [2]uint64 {
+	var z [2]uint64
+	v := y
+	for i := 0; i < 128; i++ {
+		bit := x[i/64] >> (63 - uint(i%64)) & 1
+		z[0] ^= v[0] & -bit
+		z[1] ^= v[1] & -bit
+
+		lsb := v[1] & 1
+		v[1] = v[1]>>1 | v[0]<<63
+		v[0] = v[0]>>1 ^ (0xe1<<56)&-lsb
+	}
+	return z
+}
@@ -0,0 +1,88 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrWrapKeyLength = errors.New("sm4: wrapped key must be a multiple of 8 bytes and at least 16 bytes")
+	ErrUnwrap        = errors.New("sm4: key unwrap integrity check failed")
+)
+
+// defaultIV is the initial value of RFC 3394, section 2.2.3.1.
+var defaultIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// WrapKey wraps key under the key-encryption key kek with the RFC 3394 key
+// wrap algorithm, using SM4 as the block cipher. key must be a multiple of
+// 8 bytes and at least 16 bytes long; the result is 8 bytes longer.
+func WrapKey(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, ErrWrapKeyLength
+	}
+	block, err := NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	out := make([]byte, len(key)+8)
+	copy(out, defaultIV)
+	copy(out[8:], key)
+
+	var b [BlockSize]byte
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[:8], out[:8])
+			copy(b[8:], out[8*i:])
+			block.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[8*i:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// UnwrapKey unwraps a key wrapped by WrapKey and checks its integrity. It
+// returns ErrUnwrap if wrapped was not produced under kek or was modified.
+func UnwrapKey(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, ErrWrapKeyLength
+	}
+	block, err := NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return unwrap(block, wrapped)
+}
+
+func unwrap(block cipher.Block, wrapped []byte) ([]byte, error) {
+	n := len(wrapped)/8 - 1
+	var a [8]byte
+	copy(a[:], wrapped[:8])
+	out := make([]byte, len(wrapped)-8)
+	copy(out, wrapped[8:])
+
+	var b [BlockSize]byte
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a[:])^t)
+			copy(b[8:], out[8*(i-1):8*i])
+			block.Decrypt(b[:], b[:])
+			copy(a[:], b[:8])
+			copy(out[8*(i-1):], b[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(a[:], defaultIV) != 1 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrUnwrap
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+var errIVSize = errors.New("sm4: IV length must equal block size")
+
+// NewCTR returns a cipher.Stream which encrypts/decrypts using SM4 in
+// counter mode. The iv is the initial counter block.
+func NewCTR(key, iv []byte) (cipher.Stream, error) {
+	block, err := newBlockWithIV(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewCTR(block, iv), nil
+}
+
+// NewCFBEncrypter returns a cipher.Stream which encrypts using SM4 in
+// full-block cipher feedback mode.
+func NewCFBEncrypter(key, iv []byte) (cipher.Stream, error) {
+	block, err := newBlockWithIV(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewCFBEncrypter(block, iv), nil
+}
+
+// NewCFBDecrypter returns a cipher.Stream which decrypts using SM4 in
+// full-block cipher feedback mode.
+func NewCFBDecrypter(key, iv []byte) (cipher.Stream, error) {
+	block, err := newBlockWithIV(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewCFBDecrypter(block, iv), nil
+}
+
+// NewOFB returns a cipher.Stream which encrypts/decrypts using SM4 in
+// output feedback mode.
+func NewOFB(key, iv []byte) (cipher.Stream, error) {
+	block, err := newBlockWithIV(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewOFB(block, iv), nil
+}
+
+func newBlockWithIV(key, iv []byte) (cipher.Block, error) {
+	if len(iv) != BlockSize {
+		return nil, errIVSize
+	}
+	return NewCipher(key)
+}
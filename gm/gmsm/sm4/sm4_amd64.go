@@ -0,0 +1,77 @@
+//go:build amd64
+// +build amd64
+
+package sm4
+
+// crypt4GFNI encrypts or decrypts four consecutive blocks, depending on the
+// order of the round keys, using GFNI for the S-box.
+//
+//go:noescape
+func crypt4GFNI(rk *uint32, dst, src *byte)
+
+// crypt4AESNI is crypt4GFNI with the S-box computed by AESENCLAST between
+// two affine transforms, for CPUs with AES-NI but without GFNI.
+//
+//go:noescape
+func crypt4AESNI(rk *uint32, dst, src *byte)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+func xgetbv() (eax, edx uint32)
+
+// useGFNI and useAESNI report whether the CPU and the OS support AVX and
+// GFNI, or AVX and AES-NI. GFNI is preferred when both are present.
+var useGFNI, useAESNI = detect()
+
+// GFNI reports whether blocks are encrypted four at a time with AVX and
+// GFNI on this CPU, rather than one at a time in Go.
+func GFNI() bool { return useGFNI }
+
+// AESNI reports whether blocks are encrypted four at a time with AVX and
+// AES-NI on this CPU, which happens when it lacks GFNI.
+func AESNI() bool { return useAESNI && !useGFNI }
+
+// SM4E reports whether blocks are encrypted with the ARMv8 SM4
+// instructions, which only arm64 builds do.
+func SM4E() bool { return false }
+
+func detect() (gfni, aesni bool) {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return false, false
+	}
+
+	_, _, ecx1, _ := cpuid(1, 0)
+	osxsave := ecx1&(1<<27) != 0
+	avx := ecx1&(1<<28) != 0
+	if !osxsave || !avx {
+		return false, false
+	}
+	// the OS must save the XMM and YMM registers
+	if eax, _ := xgetbv(); eax&6 != 6 {
+		return false, false
+	}
+	aesni = ecx1&(1<<25) != 0
+
+	if maxID >= 7 {
+		_, _, ecx7, _ := cpuid(7, 0)
+		gfni = ecx7&(1<<8) != 0
+	}
+	return gfni, aesni
+}
+
+func cryptBlocks(rk *[32]uint32, dst, src []byte) {
+	switch {
+	case useGFNI:
+		for len(src) >= 4*BlockSize {
+			crypt4GFNI(&rk[0], &dst[0], &src[0])
+			dst, src = dst[4*BlockSize:], src[4*BlockSize:]
+		}
+	case useAESNI:
+		for len(src) >= 4*BlockSize {
+			crypt4AESNI(&rk[0], &dst[0], &src[0])
+			dst, src = dst[4*BlockSize:], src[4*BlockSize:]
+		}
+	}
+	cryptBlocksGeneric(rk, dst, src)
+}
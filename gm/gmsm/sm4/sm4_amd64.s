@@ -0,0 +1,225 @@
+#include "textflag.h"
+
+// Affine transforms mapping the SM4 S-box onto the AES field inversion done by
+// VGF2P8AFFINEINVQB: S(x) = G(inv(F(x))), F(x) = Mf*x ^ 0x3e, G(y) = Mg*y ^ 0xd3.
+DATA affineF<>+0x00(SB)/8, $0x4c287db91a22505d
+DATA affineF<>+0x08(SB)/8, $0x4c287db91a22505d
+GLOBL affineF<>(SB), RODATA|NOPTR, $16
+
+DATA affineG<>+0x00(SB)/8, $0xf3ab34a974a6b589
+DATA affineG<>+0x08(SB)/8, $0xf3ab34a974a6b589
+GLOBL affineG<>(SB), RODATA|NOPTR, $16
+
+// Nibble tables of the affine transforms mapping the SM4 S-box onto the AES
+// S-box done by AESENCLAST, for CPUs without GFNI: S(x) = post(aes(pre(x))),
+// each transform being the XOR of a lookup of the low and the high nibble.
+DATA preLo<>+0x00(SB)/8, $0x9197e2e474720701
+DATA preLo<>+0x08(SB)/8, $0xc7c1b4b222245157
+GLOBL preLo<>(SB), RODATA|NOPTR, $16
+
+DATA preHi<>+0x00(SB)/8, $0xe240ab09eb49a200
+DATA preHi<>+0x08(SB)/8, $0xf052b91bf95bb012
+GLOBL preHi<>(SB), RODATA|NOPTR, $16
+
+DATA postLo<>+0x00(SB)/8, $0x5b67f2cea19d0834
+DATA postLo<>+0x08(SB)/8, $0xedd14478172bbe82
+GLOBL postLo<>(SB), RODATA|NOPTR, $16
+
+DATA postHi<>+0x00(SB)/8, $0xae7201dd73afdc00
+DATA postHi<>+0x08(SB)/8, $0x11cdbe62cc1063bf
+GLOBL postHi<>(SB), RODATA|NOPTR, $16
+
+DATA nibbleMask<>+0x00(SB)/8, $0x0f0f0f0f0f0f0f0f
+DATA nibbleMask<>+0x08(SB)/8, $0x0f0f0f0f0f0f0f0f
+GLOBL nibbleMask<>(SB), RODATA|NOPTR, $16
+
+// inverse of the ShiftRows step of AESENCLAST
+DATA invShiftRows<>+0x00(SB)/8, $0x0b0e0104070a0d00
+DATA invShiftRows<>+0x08(SB)/8, $0x0306090c0f020508
+GLOBL invShiftRows<>(SB), RODATA|NOPTR, $16
+
+// byte swap of each 32-bit word
+DATA bswapMask<>+0x00(SB)/8, $0x0405060700010203
+DATA bswapMask<>+0x08(SB)/8, $0x0c0d0e0f08090a0b
+GLOBL bswapMask<>(SB), RODATA|NOPTR, $16
+
+// rotate each 32-bit word left by 8, 16 and 24 bits
+DATA rotl8Mask<>+0x00(SB)/8, $0x0605040702010003
+DATA rotl8Mask<>+0x08(SB)/8, $0x0e0d0c0f0a09080b
+GLOBL rotl8Mask<>(SB), RODATA|NOPTR, $16
+
+DATA rotl16Mask<>+0x00(SB)/8, $0x0504070601000302
+DATA rotl16Mask<>+0x08(SB)/8, $0x0d0c0f0e09080b0a
+GLOBL rotl16Mask<>(SB), RODATA|NOPTR, $16
+
+DATA rotl24Mask<>+0x00(SB)/8, $0x0407060500030201
+DATA rotl24Mask<>+0x08(SB)/8, $0x0c0f0e0d080b0a09
+GLOBL rotl24Mask<>(SB), RODATA|NOPTR, $16
+
+// TRANSPOSE turns four rows of four words into four columns, using t0-t3.
+#define TRANSPOSE(r0, r1, r2, r3, t0, t1, t2, t3) \
+	VPUNPCKLDQ  r1, r0, t0; \
+	VPUNPCKHDQ  r1, r0, t1; \
+	VPUNPCKLDQ  r3, r2, t2; \
+	VPUNPCKHDQ  r3, r2, t3; \
+	VPUNPCKLQDQ t2, t0, r0; \
+	VPUNPCKHQDQ t2, t0, r1; \
+	VPUNPCKLQDQ t3, t1, r2; \
+	VPUNPCKHQDQ t3, t1, r3
+
+// ROUNDINPUT sets X8 = b ^ c ^ d ^ rk[off/4] on four lanes.
+#define ROUNDINPUT(off, b, c, d) \
+	VBROADCASTSS off(AX), X8; \
+	VPXOR        b, X8, X8; \
+	VPXOR        c, X8, X8; \
+	VPXOR        d, X8, X8
+
+// SBOXGFNI applies the S-box to each byte of X8 with GFNI.
+#define SBOXGFNI \
+	VGF2P8AFFINEQB    $0x3e, X13, X8, X8; \
+	VGF2P8AFFINEINVQB $0xd3, X14, X8, X8
+
+// AFFINE applies the transform of the nibble tables lo and hi to each byte
+// of X8, using X9.
+#define AFFINE(lo, hi) \
+	VPAND   X6, X8, X9; \
+	VPSRLD  $4, X8, X8; \
+	VPAND   X6, X8, X8; \
+	VPSHUFB X9, lo, X9; \
+	VPSHUFB X8, hi, X8; \
+	VPXOR   X9, X8, X8
+
+// SBOXAESNI applies the S-box to each byte of X8 with AESENCLAST and a zero
+// round key, undoing its ShiftRows beforehand.
+#define SBOXAESNI \
+	AFFINE(X4, X5); \
+	VPSHUFB     invShiftRows<>(SB), X8, X8; \
+	VPXOR       X9, X9, X9; \
+	VAESENCLAST X9, X8, X8; \
+	AFFINE(X13, X14)
+
+// LINEAR computes a ^= L(X8) on four lanes, where
+// L(y) = y ^ rotl24(y) ^ rotl2(y ^ rotl8(y) ^ rotl16(y)).
+#define LINEAR(a) \
+	VPSHUFB X12, X8, X9; \
+	VPSHUFB X11, X8, X10; \
+	VPXOR   X8, X9, X9; \
+	VPXOR   X10, X9, X9; \
+	VPSLLD  $2, X9, X10; \
+	VPSRLD  $30, X9, X9; \
+	VPOR    X10, X9, X9; \
+	VPXOR   X9, a, a; \
+	VPXOR   X8, a, a; \
+	VPSHUFB X7, X8, X10; \
+	VPXOR   X10, a, a
+
+// ROUNDGFNI and ROUNDAESNI compute a ^= T(b ^ c ^ d ^ rk[off/4]) on four
+// lanes, where T(x) = L(S(x)).
+#define ROUNDGFNI(off, a, b, c, d) \
+	ROUNDINPUT(off, b, c, d); \
+	SBOXGFNI; \
+	LINEAR(a)
+
+#define ROUNDAESNI(off, a, b, c, d) \
+	ROUNDINPUT(off, b, c, d); \
+	SBOXAESNI; \
+	LINEAR(a)
+
+// LOAD4 loads four blocks from CX as four lanes of words, X0 holding the
+// first word of each block.
+#define LOAD4 \
+	VMOVDQU bswapMask<>(SB), X15; \
+	VMOVDQU 0(CX), X0; \
+	VMOVDQU 16(CX), X1; \
+	VMOVDQU 32(CX), X2; \
+	VMOVDQU 48(CX), X3; \
+	VPSHUFB X15, X0, X0; \
+	VPSHUFB X15, X1, X1; \
+	VPSHUFB X15, X2, X2; \
+	VPSHUFB X15, X3, X3; \
+	TRANSPOSE(X0, X1, X2, X3, X4, X5, X6, X7); \
+	VMOVDQU rotl24Mask<>(SB), X7; \
+	VMOVDQU rotl16Mask<>(SB), X11; \
+	VMOVDQU rotl8Mask<>(SB), X12
+
+// STORE4 stores the output (x35, x34, x33, x32) of the four lanes to BX.
+#define STORE4 \
+	TRANSPOSE(X3, X2, X1, X0, X4, X5, X6, X7); \
+	VPSHUFB X15, X3, X3; \
+	VPSHUFB X15, X2, X2; \
+	VPSHUFB X15, X1, X1; \
+	VPSHUFB X15, X0, X0; \
+	VMOVDQU X3, 0(BX); \
+	VMOVDQU X2, 16(BX); \
+	VMOVDQU X1, 32(BX); \
+	VMOVDQU X0, 48(BX)
+
+// func crypt4GFNI(rk *uint32, dst, src *byte)
+TEXT ·crypt4GFNI(SB), NOSPLIT, $0-24
+	MOVQ rk+0(FP), AX
+	MOVQ dst+8(FP), BX
+	MOVQ src+16(FP), CX
+
+	LOAD4
+	VMOVDQU affineF<>(SB), X13
+	VMOVDQU affineG<>(SB), X14
+
+	MOVQ $8, DX
+
+gfniLoop:
+	ROUNDGFNI(0, X0, X1, X2, X3)
+	ROUNDGFNI(4, X1, X2, X3, X0)
+	ROUNDGFNI(8, X2, X3, X0, X1)
+	ROUNDGFNI(12, X3, X0, X1, X2)
+	ADDQ $16, AX
+	DECQ DX
+	JNZ  gfniLoop
+
+	STORE4
+	RET
+
+// func crypt4AESNI(rk *uint32, dst, src *byte)
+TEXT ·crypt4AESNI(SB), NOSPLIT, $0-24
+	MOVQ rk+0(FP), AX
+	MOVQ dst+8(FP), BX
+	MOVQ src+16(FP), CX
+
+	LOAD4
+	VMOVDQU preLo<>(SB), X4
+	VMOVDQU preHi<>(SB), X5
+	VMOVDQU nibbleMask<>(SB), X6
+	VMOVDQU postLo<>(SB), X13
+	VMOVDQU postHi<>(SB), X14
+
+	MOVQ $8, DX
+
+aesniLoop:
+	ROUNDAESNI(0, X0, X1, X2, X3)
+	ROUNDAESNI(4, X1, X2, X3, X0)
+	ROUNDAESNI(8, X2, X3, X0, X1)
+	ROUNDAESNI(12, X3, X0, X1, X2)
+	ADDQ $16, AX
+	DECQ DX
+	JNZ  aesniLoop
+
+	STORE4
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
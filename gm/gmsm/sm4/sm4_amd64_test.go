@@ -0,0 +1,44 @@
+//go:build amd64
+// +build amd64
+
+package sm4
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestCrypt4 checks each four-block kernel the CPU supports against the
+// generic implementation, whichever of them cryptBlocks selects.
+func TestCrypt4(t *testing.T) {
+	block, _ := NewCipher(decodeHex("0123456789abcdeffedcba9876543210"))
+	c := block.(*sm4Cipher)
+	src := make([]byte, 4*BlockSize)
+	for i := range src {
+		src[i] = byte(i * 13)
+	}
+
+	kernels := []struct {
+		name      string
+		supported bool
+		crypt4    func(rk *uint32, dst, src *byte)
+	}{
+		{"GFNI", useGFNI, crypt4GFNI},
+		{"AESNI", useAESNI, crypt4AESNI},
+	}
+	for _, k := range kernels {
+		if !k.supported {
+			t.Logf("%s: not supported by this CPU", k.name)
+			continue
+		}
+		for _, rk := range []*[32]uint32{&c.enc, &c.dec} {
+			want := make([]byte, len(src))
+			cryptBlocksGeneric(rk, want, src)
+			got := make([]byte, len(src))
+			k.crypt4(&rk[0], &got[0], &src[0])
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: got %x, want %x", k.name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+//go:build arm64
+// +build arm64
+
+package sm4
+
+import "golang.org/x/sys/cpu"
+
+// cryptBlocksSM4E encrypts or decrypts n consecutive blocks, depending on
+// the order of the round keys, with the SM4E instruction.
+//
+//go:noescape
+func cryptBlocksSM4E(rk *uint32, dst, src *byte, n int)
+
+// useSM4E reports whether the CPU implements the ARMv8.2 SM4 instructions.
+var useSM4E = cpu.ARM64.HasSM4
+
+// GFNI reports whether blocks are encrypted with AVX and GFNI, which only
+// amd64 builds do.
+func GFNI() bool { return false }
+
+// AESNI reports whether blocks are encrypted with AVX and AES-NI, which only
+// amd64 builds do.
+func AESNI() bool { return false }
+
+// SM4E reports whether blocks are encrypted with the ARMv8 SM4 instructions
+// on this CPU, rather than one at a time in Go.
+func SM4E() bool { return useSM4E }
+
+func cryptBlocks(rk *[32]uint32, dst, src []byte) {
+	if !useSM4E {
+		cryptBlocksGeneric(rk, dst, src)
+		return
+	}
+	if n := len(src) / BlockSize; n > 0 {
+		cryptBlocksSM4E(&rk[0], &dst[0], &src[0], n)
+	}
+}
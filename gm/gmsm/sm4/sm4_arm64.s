@@ -0,0 +1,71 @@
+#include "textflag.h"
+
+// SM4E(d, n) is SM4E Vd.S4, V(16+n).S4, which runs four rounds on the block
+// in Vd with the round keys rk[4n:4n+4] kept in V(16+n). The Go assembler
+// does not know the ARMv8.2 SM4 instructions, so they are emitted as WORD
+// directives.
+#define SM4E(d, n) WORD $(0xcec08600 | (d) | ((n) << 5))
+
+// ROUNDS1 runs the 32 rounds on the block in V0.
+#define ROUNDS1 \
+	SM4E(0, 0); SM4E(0, 1); SM4E(0, 2); SM4E(0, 3); \
+	SM4E(0, 4); SM4E(0, 5); SM4E(0, 6); SM4E(0, 7)
+
+// ROUNDS4 runs the 32 rounds on the blocks in V0-V3, interleaved so that the
+// four blocks hide the latency of each other.
+#define ROUNDS4 \
+	SM4E(0, 0); SM4E(1, 0); SM4E(2, 0); SM4E(3, 0); \
+	SM4E(0, 1); SM4E(1, 1); SM4E(2, 1); SM4E(3, 1); \
+	SM4E(0, 2); SM4E(1, 2); SM4E(2, 2); SM4E(3, 2); \
+	SM4E(0, 3); SM4E(1, 3); SM4E(2, 3); SM4E(3, 3); \
+	SM4E(0, 4); SM4E(1, 4); SM4E(2, 4); SM4E(3, 4); \
+	SM4E(0, 5); SM4E(1, 5); SM4E(2, 5); SM4E(3, 5); \
+	SM4E(0, 6); SM4E(1, 6); SM4E(2, 6); SM4E(3, 6); \
+	SM4E(0, 7); SM4E(1, 7); SM4E(2, 7); SM4E(3, 7)
+
+// OUTPUT turns the state (x32, x33, x34, x35) of a block into its output
+// (x35, x34, x33, x32) in big-endian byte order.
+#define OUTPUT(v) \
+	VREV64 v.S4, v.S4; \
+	VEXT   $8, v.B16, v.B16, v.B16; \
+	VREV32 v.B16, v.B16
+
+// func cryptBlocksSM4E(rk *uint32, dst, src *byte, n int)
+TEXT ·cryptBlocksSM4E(SB), NOSPLIT, $0-32
+	MOVD rk+0(FP), R0
+	MOVD dst+8(FP), R1
+	MOVD src+16(FP), R2
+	MOVD n+24(FP), R3
+
+	VLD1.P 64(R0), [V16.S4, V17.S4, V18.S4, V19.S4]
+	VLD1   (R0), [V20.S4, V21.S4, V22.S4, V23.S4]
+
+loop4:
+	CMP  $4, R3
+	BLT  loop1
+	VLD1.P 64(R2), [V0.B16, V1.B16, V2.B16, V3.B16]
+	VREV32 V0.B16, V0.B16
+	VREV32 V1.B16, V1.B16
+	VREV32 V2.B16, V2.B16
+	VREV32 V3.B16, V3.B16
+	ROUNDS4
+	OUTPUT(V0)
+	OUTPUT(V1)
+	OUTPUT(V2)
+	OUTPUT(V3)
+	VST1.P [V0.B16, V1.B16, V2.B16, V3.B16], 64(R1)
+	SUB  $4, R3
+	B    loop4
+
+loop1:
+	CBZ  R3, done
+	VLD1.P 16(R2), [V0.B16]
+	VREV32 V0.B16, V0.B16
+	ROUNDS1
+	OUTPUT(V0)
+	VST1.P [V0.B16], 16(R1)
+	SUB  $1, R3
+	B    loop1
+
+done:
+	RET
@@ -0,0 +1,20 @@
+//go:build !amd64 && !arm64
+// +build !amd64,!arm64
+
+package sm4
+
+// GFNI reports whether blocks are encrypted with AVX and GFNI, which only
+// amd64 builds do.
+func GFNI() bool { return false }
+
+// AESNI reports whether blocks are encrypted with AVX and AES-NI, which only
+// amd64 builds do.
+func AESNI() bool { return false }
+
+// SM4E reports whether blocks are encrypted with the ARMv8 SM4
+// instructions, which only arm64 builds do.
+func SM4E() bool { return false }
+
+func cryptBlocks(rk *[32]uint32, dst, src []byte) {
+	cryptBlocksGeneric(rk, dst, src)
+}
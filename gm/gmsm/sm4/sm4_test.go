@@ -0,0 +1,443 @@
+package sm4
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// GB/T 32907-2016 Appendix A
+func TestSM4Block(t *testing.T) {
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	want := decodeHex("681edf34d206965e86b3e94f536e4246")
+
+	c, err := NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]byte, BlockSize)
+	c.Encrypt(out, key)
+	if !bytes.Equal(out, want) {
+		t.Fatalf("encrypt: %x, want %x", out, want)
+	}
+	c.Decrypt(out, out)
+	if !bytes.Equal(out, key) {
+		t.Fatalf("decrypt: %x, want %x", out, key)
+	}
+
+	if testing.Short() {
+		return
+	}
+	out = append([]byte{}, key...)
+	for i := 0; i < 1000000; i++ {
+		c.Encrypt(out, out)
+	}
+	if want := decodeHex("595298c7c6fd271f0402f804c33d3f66"); !bytes.Equal(out, want) {
+		t.Fatalf("1000000 rounds: %x, want %x", out, want)
+	}
+}
+
+func TestSM4Streams(t *testing.T) {
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	iv := decodeHex("000102030405060708090a0b0c0d0e0f")
+	plain := []byte("The quick brown fox jumps over the lazy dog, twice!!")
+
+	// generated with OpenSSL 3.0 enc -sm4-{ctr,cfb,ofb}
+	tests := []struct {
+		name string
+		enc  func(key, iv []byte) (cipher.Stream, error)
+		dec  func(key, iv []byte) (cipher.Stream, error)
+		want string
+	}{
+		{"CTR", NewCTR, NewCTR, "52f0f9414cd301ce41ad95f08edf974a0968756b2ad69171a9b17c93e4728d6e74bf728cab558e4e66821ee2d368f304a7cd775b"},
+		{"CFB", NewCFBEncrypter, NewCFBDecrypter, "52f0f9414cd301ce41ad95f08edf974aebdab6ed9ce21b3172640c3512ebed77dc634ef8e55749e62a86e5b3eff765550865e168"},
+		{"OFB", NewOFB, NewOFB, "52f0f9414cd301ce41ad95f08edf974a95803a6cddf6370d127f83e2b851c8543322b824ee737e085481630a04c4298b547769e4"},
+	}
+	for _, test := range tests {
+		stream, err := test.enc(key, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]byte, len(plain))
+		stream.XORKeyStream(out, plain)
+		if got := hex.EncodeToString(out); got != test.want {
+			t.Fatalf("%s: %s, want %s", test.name, got, test.want)
+		}
+
+		stream, _ = test.dec(key, iv)
+		stream.XORKeyStream(out, out)
+		if !bytes.Equal(out, plain) {
+			t.Fatalf("%s: decrypted %q", test.name, out)
+		}
+	}
+
+	if _, err := NewCTR(key, iv[:8]); err == nil {
+		t.Fatal("expected error for short IV")
+	}
+}
+
+func TestCBC(t *testing.T) {
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	iv := decodeHex("000102030405060708090a0b0c0d0e0f")
+	plain := []byte("The quick brown fox jumps over the lazy dog, twice!!")
+
+	// generated with OpenSSL 3.0 enc -sm4-cbc
+	want := "b6556613480f80c2a4c4beadbdc795ced203d6945466924b4faa7bf47bfb403497d9321856de2b5a03362a9db664236850f7705d0b9845bd4d5cc389aa676569"
+	out, err := CBCEncrypt(key, iv, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(out); got != want {
+		t.Fatalf("CBCEncrypt: %s, want %s", got, want)
+	}
+
+	dec, err := CBCDecrypt(key, iv, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, plain) {
+		t.Fatalf("CBCDecrypt: %q", dec)
+	}
+}
+
+func TestPKCS7Unpad(t *testing.T) {
+	for n := 0; n <= 2*BlockSize; n++ {
+		src := bytes.Repeat([]byte{0xaa}, n)
+		out, err := PKCS7Unpad(PKCS7Pad(src))
+		if err != nil || !bytes.Equal(out, src) {
+			t.Fatalf("length %d: %x, %v", n, out, err)
+		}
+	}
+
+	bad := [][]byte{
+		nil,
+		make([]byte, BlockSize),
+		append(bytes.Repeat([]byte{1}, BlockSize-1), 17),
+		append(bytes.Repeat([]byte{1}, BlockSize-2), 3, 2),
+		append(bytes.Repeat([]byte{3}, BlockSize-3), 3, 4, 3),
+	}
+	for _, b := range bad {
+		if _, err := PKCS7Unpad(b); err != ErrInvalidPadding {
+			t.Fatalf("%x: expected ErrInvalidPadding, got %v", b, err)
+		}
+	}
+}
+
+func TestXTSRoundTrip(t *testing.T) {
+	key := decodeHex("0123456789abcdeffedcba9876543210fedcba98765432100123456789abcdef")
+	x, err := NewXTS(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{16, 17, 31, 32, 512, 517} {
+		data := bytes.Repeat([]byte{0x3c}, n)
+		enc, err := x.EncryptSector(7, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, _ := x.EncryptSector(8, data); bytes.Equal(enc, other) {
+			t.Fatalf("length %d: sectors 7 and 8 encrypt alike", n)
+		}
+		dec, err := x.DecryptSector(7, enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dec, data) {
+			t.Fatalf("length %d: round trip failed", n)
+		}
+	}
+
+	if _, err := x.EncryptSector(0, make([]byte, 15)); err == nil {
+		t.Fatal("expected error for a short sector")
+	}
+}
+
+// TestXTSKnownAnswer uses the SM4-XTS vector of GB/T 17964-2021 with the
+// IEEE P1619 tweak multiplication, as published in OpenSSL's
+// evpciph_sm4.txt. Its 128-bit tweak is not a sector number, so the vector
+// drives crypt directly; the sector vector was computed with an independent
+// implementation over OpenSSL's SM4.
+func TestXTSKnownAnswer(t *testing.T) {
+	x, err := NewXTS(decodeHex("2b7e151628aed2a6abf7158809cf4f3c000102030405060708090a0b0c0d0e0f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tweak [BlockSize]byte
+	copy(tweak[:], decodeHex("f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"))
+	plain := decodeHex("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17")
+	want := decodeHex("e9538251c71d7b80bbe4483fef497bd1b3db1a3e60408c575d63ff7db39f83260869f9e2585fec9f0b863bf8fd784b8627d16c0db6d2cfc7")
+	got, err := x.crypt(tweak, plain, true)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("encrypt: got %x, %v, want %x", got, err, want)
+	}
+	if got, err = x.crypt(tweak, want, false); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("decrypt: got %x, %v", got, err)
+	}
+
+	plain = make([]byte, 40)
+	for i := range plain {
+		plain[i] = byte(i)
+	}
+	want = decodeHex("6d8a1418991ac5332024474d10c95590e67aabf9f19f7f60ae4e852be56421e9ae20b9dcc471b019")
+	if got, err = x.EncryptSector(7, plain); err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("sector 7: got %x, %v, want %x", got, err, want)
+	}
+}
+
+func TestCCM(t *testing.T) {
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	plain := []byte("The quick brown fox jumps over the lazy dog, twice!!")
+	ad := []byte("header")
+
+	for _, sizes := range [][2]int{{7, 4}, {12, 16}, {13, 8}} {
+		aead, err := NewCCM(key, sizes[0], sizes[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := make([]byte, aead.NonceSize())
+		sealed := aead.Seal(nil, nonce, plain, ad)
+		if len(sealed) != len(plain)+aead.Overhead() {
+			t.Fatalf("%v: sealed length %d", sizes, len(sealed))
+		}
+		opened, err := aead.Open(nil, nonce, sealed, ad)
+		if err != nil || !bytes.Equal(opened, plain) {
+			t.Fatalf("%v: open: %q, %v", sizes, opened, err)
+		}
+		sealed[0] ^= 1
+		if _, err := aead.Open(nil, nonce, sealed, ad); err == nil {
+			t.Fatalf("%v: tampered ciphertext accepted", sizes)
+		}
+	}
+
+	if _, err := NewCCM(key, 6, 16); err == nil {
+		t.Fatal("expected error for a 6-byte nonce")
+	}
+	if _, err := NewCCM(key, 12, 5); err == nil {
+		t.Fatal("expected error for an odd tag size")
+	}
+}
+
+// RFC 8998 Appendix A.2, AEAD_SM4_CCM.
+func TestCCMKnownAnswer(t *testing.T) {
+	aead, err := NewCCM(decodeHex("0123456789abcdeffedcba9876543210"), 12, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := decodeHex("00001234567800000000abcd")
+	ad := decodeHex("feedfacedeadbeeffeedfacedeadbeefabaddad2")
+	plain := decodeHex("aaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbccccccccccccccccddddddddddddddddeeeeeeeeeeeeeeeeffffffffffffffffeeeeeeeeeeeeeeeeaaaaaaaaaaaaaaaa")
+	want := decodeHex("48af93501fa62adbcd414cce6034d895dda1bf8f132f042098661572e7483094fd12e518ce062c98acee28d95df4416bed31a2f04476c18bb40c84a74b97dc5b" +
+		"16842d4fa186f56ab33256971fa110f4")
+
+	if got := aead.Seal(nil, nonce, plain, ad); !bytes.Equal(got, want) {
+		t.Fatalf("seal: got %x, want %x", got, want)
+	}
+	if got, err := aead.Open(nil, nonce, want, ad); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("open: got %x, %v", got, err)
+	}
+}
+
+func TestKeyWrap(t *testing.T) {
+	kek := decodeHex("000102030405060708090a0b0c0d0e0f")
+	key := decodeHex("00112233445566778899aabbccddeeff0001020304050607")
+
+	wrapped, err := WrapKey(kek, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wrapped) != len(key)+8 {
+		t.Fatalf("wrapped length %d", len(wrapped))
+	}
+	unwrapped, err := UnwrapKey(kek, wrapped)
+	if err != nil || !bytes.Equal(unwrapped, key) {
+		t.Fatalf("unwrap: %x, %v", unwrapped, err)
+	}
+
+	wrapped[len(wrapped)-1] ^= 1
+	if _, err := UnwrapKey(kek, wrapped); err != ErrUnwrap {
+		t.Fatalf("expected ErrUnwrap, got %v", err)
+	}
+	if _, err := WrapKey(kek, key[:12]); err != ErrWrapKeyLength {
+		t.Fatalf("expected ErrWrapKeyLength, got %v", err)
+	}
+}
+
+// TestKeyWrapKnownAnswer uses the inputs of RFC 3394 sections 4.1 and 4.2
+// with SM4 in place of AES. The expected outputs were computed with an
+// independent implementation over OpenSSL's SM4 that reproduces the RFC
+// vectors over AES.
+func TestKeyWrapKnownAnswer(t *testing.T) {
+	kek := decodeHex("000102030405060708090a0b0c0d0e0f")
+	for _, tt := range []struct{ key, wrapped string }{
+		{"00112233445566778899aabbccddeeff", "c72e8dbfefe856259fff77de2023b380a9e2d0b8acb9b6f6"},
+		{"00112233445566778899aabbccddeeff0001020304050607", "a874c3d64c7a639b7e8c97243550f528090df4cdcfb2cb81d403899fced7b88a"},
+	} {
+		key, want := decodeHex(tt.key), decodeHex(tt.wrapped)
+		if got, err := WrapKey(kek, key); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("wrap %s: got %x, %v, want %x", tt.key, got, err, want)
+		}
+		if got, err := UnwrapKey(kek, want); err != nil || !bytes.Equal(got, key) {
+			t.Fatalf("unwrap %s: got %x, %v", tt.wrapped, got, err)
+		}
+	}
+}
+
+func TestMultiBlockModes(t *testing.T) {
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	iv := decodeHex("000102030405060708090a0b0c0d0e0f")
+	block, _ := NewCipher(key)
+	plain := make([]byte, 37*BlockSize)
+	for i := range plain {
+		plain[i] = byte(i * 7)
+	}
+
+	// the batched paths must agree with the block-at-a-time reference
+	want := make([]byte, len(plain))
+	cryptBlocksGeneric(&block.(*sm4Cipher).enc, want, plain)
+	got := make([]byte, len(plain))
+	cryptBlocks(&block.(*sm4Cipher).enc, got, plain)
+	if !bytes.Equal(got, want) {
+		t.Fatal("cryptBlocks disagrees with the generic implementation")
+	}
+
+	ctrWant := make([]byte, len(plain))
+	ctr := make([]byte, BlockSize)
+	copy(ctr, iv)
+	for i := 0; i < len(plain); i += BlockSize {
+		block.Encrypt(ctrWant[i:], ctr)
+		for j := range ctr[:BlockSize] {
+			ctrWant[i+j] ^= plain[i+j]
+		}
+		for j := BlockSize - 1; j >= 0; j-- {
+			if ctr[j]++; ctr[j] != 0 {
+				break
+			}
+		}
+	}
+	stream := cipher.NewCTR(block, iv)
+	stream.XORKeyStream(got[:5], plain[:5])
+	stream.XORKeyStream(got[5:], plain[5:])
+	if !bytes.Equal(got, ctrWant) {
+		t.Fatal("CTR disagrees with the block-at-a-time reference")
+	}
+
+	enc := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(enc, plain)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(enc, enc)
+	if !bytes.Equal(enc, plain) {
+		t.Fatal("in-place CBC decryption failed")
+	}
+}
+
+func BenchmarkSM4CTR(b *testing.B) {
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	stream, _ := NewCTR(key, make([]byte, BlockSize))
+	buf := make([]byte, 8192)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		stream.XORKeyStream(buf, buf)
+	}
+}
+
+func TestBitsliced(t *testing.T) {
+	for i := 0; i < 256; i += 4 {
+		w := []uint32{uint32(i)<<24 | uint32(i+1)<<16 | uint32(i+2)<<8 | uint32(i+3)}
+		want := tau(w[0])
+		tauBitsliced(w)
+		if w[0] != want {
+			t.Fatalf("S-box circuit at %d: %08x, want %08x", i, w[0], want)
+		}
+	}
+
+	key := decodeHex("0123456789abcdeffedcba9876543210")
+	ref, _ := NewCipher(key)
+	c, err := NewBitslicedCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.(*sm4Cipher).enc != ref.(*sm4Cipher).enc {
+		t.Fatal("bitsliced key schedule differs")
+	}
+
+	plain := make([]byte, 11*BlockSize)
+	for i := range plain {
+		plain[i] = byte(i * 13)
+	}
+	want := make([]byte, len(plain))
+	got := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(ref, key).CryptBlocks(want, plain)
+	cipher.NewCBCEncrypter(c, key).CryptBlocks(got, plain)
+	if !bytes.Equal(got, want) {
+		t.Fatal("bitsliced CBC encryption differs")
+	}
+	cipher.NewCBCDecrypter(c, key).CryptBlocks(got, got)
+	if !bytes.Equal(got, plain) {
+		t.Fatal("bitsliced CBC decryption failed")
+	}
+}
+
+func TestGCMSIV(t *testing.T) {
+	aead, err := NewGCMSIV(decodeHex("0123456789abcdeffedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	plain := []byte("The quick brown fox jumps over the lazy dog, twice!!")
+	ad := []byte("header")
+
+	sealed := aead.Seal(nil, nonce, plain, ad)
+	if again := aead.Seal(nil, nonce, plain, ad); !bytes.Equal(sealed, again) {
+		t.Fatal("GCM-SIV must be deterministic for a given nonce")
+	}
+	if other := aead.Seal(nil, nonce, plain[1:], ad); bytes.Equal(sealed[len(sealed)-16:], other[len(other)-16:]) {
+		t.Fatal("different messages gave the same tag")
+	}
+
+	opened, err := aead.Open(nil, nonce, sealed, ad)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Fatalf("open: %q, %v", opened, err)
+	}
+	if _, err := aead.Open(nil, nonce, sealed, ad[1:]); err == nil {
+		t.Fatal("wrong additional data accepted")
+	}
+}
+
+// TestGCMSIVKnownAnswer pins SM4-GCM-SIV on the RFC 8998 AEAD inputs and on
+// an empty message. The expected outputs were computed with an independent
+// implementation of RFC 8452 over OpenSSL's SM4 that reproduces the RFC 8452
+// AES-128 vectors over AES.
+func TestGCMSIVKnownAnswer(t *testing.T) {
+	aead, err := NewGCMSIV(decodeHex("0123456789abcdeffedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := decodeHex("00001234567800000000abcd")
+	for _, tt := range []struct{ plain, ad, sealed string }{
+		{"", "", "00165c53d227ab36a50832027ee312ed"},
+		{
+			"aaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbccccccccccccccccddddddddddddddddeeeeeeeeeeeeeeeeffffffffffffffffeeeeeeeeeeeeeeeeaaaaaaaaaaaaaaaa",
+			"feedfacedeadbeeffeedfacedeadbeefabaddad2",
+			"1dab402da790959f979a00a65b38acdf25be37f77177fe51636d7d633f2dc36047d2b3f4a4eb0335fcd992e23bb28729ad160aeee225d0e7f7c44ee9ac036cff" +
+				"b4be9c2699249e5f9f4b947e3197b8d6",
+		},
+	} {
+		plain, ad, want := decodeHex(tt.plain), decodeHex(tt.ad), decodeHex(tt.sealed)
+		if got := aead.Seal(nil, nonce, plain, ad); !bytes.Equal(got, want) {
+			t.Fatalf("seal: got %x, want %x", got, want)
+		}
+		if got, err := aead.Open(nil, nonce, want, ad); err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("open: got %x, %v", got, err)
+		}
+	}
+}
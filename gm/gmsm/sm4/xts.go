@@ -0,0 +1,124 @@
+package sm4
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+var errXTSLength = errors.New("sm4: XTS data must be at least one block")
+
+// XTS implements XTS-SM4 (IEEE P1619) with two independent keys, matching the
+// Linux kernel's xts(sm4): the sector number is the little-endian 128-bit
+// tweak (plain64) and sectors that are not a multiple of the block size are
+// handled with ciphertext stealing.
+type XTS struct {
+	k1, k2 cipher.Block
+}
+
+// NewXTS creates an XTS-SM4 cipher from a 32-byte key, the first half being
+// the data key and the second half the tweak key.
+func NewXTS(key []byte) (*XTS, error) {
+	if len(key) != 2*KeySize {
+		return nil, KeySizeError(len(key))
+	}
+
+	k1, err := NewCipher(key[:KeySize])
+	if err != nil {
+		return nil, err
+	}
+	k2, err := NewCipher(key[KeySize:])
+	if err != nil {
+		return nil, err
+	}
+	return &XTS{k1: k1, k2: k2}, nil
+}
+
+// EncryptSector encrypts one sector of at least BlockSize bytes and returns
+// the ciphertext, which has the same length as data.
+func (x *XTS) EncryptSector(sectorNum uint64, data []byte) ([]byte, error) {
+	return x.crypt(sectorTweak(sectorNum), data, true)
+}
+
+// DecryptSector decrypts one sector produced by EncryptSector.
+func (x *XTS) DecryptSector(sectorNum uint64, data []byte) ([]byte, error) {
+	return x.crypt(sectorTweak(sectorNum), data, false)
+}
+
+// sectorTweak returns the plain64 tweak of a sector.
+func sectorTweak(sectorNum uint64) (tweak [BlockSize]byte) {
+	binary.LittleEndian.PutUint64(tweak[:8], sectorNum)
+	return tweak
+}
+
+func (x *XTS) crypt(tweak [BlockSize]byte, data []byte, encrypt bool) ([]byte, error) {
+	if len(data) < BlockSize {
+		return nil, errXTSLength
+	}
+
+	x.k2.Encrypt(tweak[:], tweak[:])
+
+	out := make([]byte, len(data))
+	full := len(data) / BlockSize
+	tail := len(data) % BlockSize
+	if tail != 0 {
+		// the last full block takes part in the ciphertext stealing
+		full--
+	}
+
+	for i := 0; i < full; i++ {
+		x.cryptBlock(out[i*BlockSize:], data[i*BlockSize:], &tweak, encrypt)
+		mulAlpha(&tweak)
+	}
+	if tail == 0 {
+		return out, nil
+	}
+
+	off := full * BlockSize
+	var cc [BlockSize]byte
+	if encrypt {
+		x.cryptBlock(cc[:], data[off:], &tweak, true)
+		mulAlpha(&tweak)
+		copy(out[off+BlockSize:], cc[:tail])
+		copy(cc[:], data[off+BlockSize:])
+		x.cryptBlock(out[off:], cc[:], &tweak, true)
+		return out, nil
+	}
+
+	last := tweak
+	mulAlpha(&last)
+	x.cryptBlock(cc[:], data[off:], &last, false)
+	copy(out[off+BlockSize:], cc[:tail])
+	copy(cc[:], data[off+BlockSize:])
+	x.cryptBlock(out[off:], cc[:], &tweak, false)
+	return out, nil
+}
+
+func (x *XTS) cryptBlock(dst, src []byte, tweak *[BlockSize]byte, encrypt bool) {
+	var b [BlockSize]byte
+	for i := range b {
+		b[i] = src[i] ^ tweak[i]
+	}
+	if encrypt {
+		x.k1.Encrypt(b[:], b[:])
+	} else {
+		x.k1.Decrypt(b[:], b[:])
+	}
+	for i := range b {
+		dst[i] = b[i] ^ tweak[i]
+	}
+}
+
+// mulAlpha multiplies the tweak by the primitive element of GF(2^128), using
+// the little-endian convention of IEEE P1619.
+func mulAlpha(tweak *[BlockSize]byte) {
+	var carry byte
+	for i := range tweak {
+		next := tweak[i] >> 7
+		tweak[i] = tweak[i]<<1 | carry
+		carry = next
+	}
+	if carry != 0 {
+		tweak[0] ^= 0x87
+	}
+}
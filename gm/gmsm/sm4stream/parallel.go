@@ -0,0 +1,159 @@
+package sm4stream
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Seal encrypts plaintext into the stream a Writer would produce for it,
+// sealing its chunks on up to workers goroutines, or GOMAXPROCS if workers
+// is not positive. The stream may be decrypted with Reader or Open.
+func Seal(aead cipher.AEAD, plaintext []byte, workers int) ([]byte, error) {
+	n, err := newNonce(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	chunks := (len(plaintext) + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	if uint64(chunks) > 1<<32 {
+		return nil, ErrTooManyParts
+	}
+
+	frame := frameHeaderSize + aead.Overhead()
+	out := make([]byte, n.prefix+chunks*frame+len(plaintext))
+	prefix := out[:n.prefix]
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+
+	parallel(chunks, workers, func(i int) {
+		lo := i * ChunkSize
+		hi := lo + ChunkSize
+		if hi > len(plaintext) {
+			hi = len(plaintext)
+		}
+		final := i == chunks-1
+		off := n.prefix + i*(frame+ChunkSize)
+		header := out[off : off+frameHeaderSize]
+		if final {
+			header[0] = flagFinal
+		}
+		binary.BigEndian.PutUint32(header[1:], uint32(hi-lo+aead.Overhead()))
+		sealed := out[off+frameHeaderSize : off+frameHeaderSize : off+frame+hi-lo]
+		aead.Seal(sealed, chunkNonce(prefix, len(n.buf), uint32(i), final), plaintext[lo:hi], header)
+	})
+	return out, nil
+}
+
+// Open decrypts a whole stream produced by Writer or Seal, opening its
+// frames on up to workers goroutines, or GOMAXPROCS if workers is not
+// positive. It accepts exactly the streams Reader accepts and returns
+// nothing unless every frame is authentic.
+func Open(aead cipher.AEAD, stream []byte, workers int) ([]byte, error) {
+	n, err := newNonce(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) < n.prefix {
+		return nil, ErrTruncated
+	}
+	prefix := stream[:n.prefix]
+
+	// The frame boundaries are found first, since each header gives the
+	// offset of the next one.
+	type frame struct {
+		off, size, plain int
+	}
+	var frames []frame
+	plain := 0
+	for off := n.prefix; ; {
+		if len(stream)-off < frameHeaderSize {
+			return nil, ErrTruncated
+		}
+		flag := stream[off]
+		if flag > flagFinal {
+			return nil, ErrCorrupted
+		}
+		size := binary.BigEndian.Uint32(stream[off+1:])
+		if size < uint32(aead.Overhead()) || size > uint32(ChunkSize+aead.Overhead()) {
+			return nil, ErrCorrupted
+		}
+		if uint64(len(stream)-off-frameHeaderSize) < uint64(size) {
+			return nil, ErrTruncated
+		}
+		if uint64(len(frames)) == 1<<32 {
+			return nil, ErrTooManyParts
+		}
+		frames = append(frames, frame{off, int(size), plain})
+		plain += int(size) - aead.Overhead()
+		off += frameHeaderSize + int(size)
+		if flag == flagFinal {
+			if off != len(stream) {
+				return nil, ErrCorrupted
+			}
+			break
+		}
+	}
+
+	out := make([]byte, plain)
+	var failed int32
+	parallel(len(frames), workers, func(i int) {
+		f := frames[i]
+		final := i == len(frames)-1
+		header := stream[f.off : f.off+frameHeaderSize]
+		sealed := stream[f.off+frameHeaderSize : f.off+frameHeaderSize+f.size]
+		dst := out[f.plain : f.plain : f.plain+f.size-aead.Overhead()]
+		if _, err := aead.Open(dst, chunkNonce(prefix, len(n.buf), uint32(i), final), sealed, header); err != nil {
+			atomic.StoreInt32(&failed, 1)
+		}
+	})
+	if failed != 0 {
+		return nil, ErrCorrupted
+	}
+	return out, nil
+}
+
+// chunkNonce returns the nonce of size bytes of chunk i of a stream.
+func chunkNonce(prefix []byte, size int, i uint32, final bool) []byte {
+	nonce := make([]byte, size)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], i)
+	if final {
+		nonce[size-1] = flagFinal
+	}
+	return nonce
+}
+
+// parallel calls f(i) for each i in [0, n) on up to workers goroutines and
+// returns once all calls have.
+func parallel(n, workers int, f func(i int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	next := int64(-1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1)
+				if i >= int64(n) {
+					return
+				}
+				f(int(i))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,325 @@
+// Package sm4stream encrypts arbitrarily large streams with an AEAD such as
+// SM4-GCM or SM4-CCM, one chunk at a time.
+//
+// The stream starts with a random nonce prefix, followed by frames:
+//
+//	flag (1 byte) || length (4 bytes, big endian) || sealed chunk
+//
+// Each chunk is sealed under the nonce prefix || chunk counter (4 bytes) ||
+// flag, where flag is 1 for the last frame and 0 otherwise. Reordered, dropped
+// or modified frames fail authentication, and a stream that ends without its
+// last frame is reported as truncated.
+//
+// NewWriterContext and NewReaderContext bind a stream to a context, so that
+// encrypting or decrypting a large stream stops once the request it serves
+// is abandoned.
+package sm4stream
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// ChunkSize is the amount of plaintext in each frame but the last.
+const ChunkSize = 64 * 1024
+
+const (
+	frameHeaderSize = 5
+	flagFinal       = 1
+)
+
+var (
+	ErrTruncated    = errors.New("sm4stream: stream truncated")
+	ErrCorrupted    = errors.New("sm4stream: stream corrupted")
+	ErrNonceSize    = errors.New("sm4stream: AEAD nonce size must be at least 8 bytes")
+	ErrTooManyParts = errors.New("sm4stream: stream too long")
+	ErrClosed       = errors.New("sm4stream: write to closed writer")
+	ErrSkip         = errors.New("sm4stream: cannot skip the last or a partly read chunk")
+)
+
+type nonce struct {
+	buf     []byte
+	prefix  int
+	counter uint32
+	wrapped bool
+}
+
+func newNonce(size int) (*nonce, error) {
+	if size < 8 {
+		return nil, ErrNonceSize
+	}
+	return &nonce{buf: make([]byte, size), prefix: size - 5}, nil
+}
+
+func (n *nonce) next(final bool) ([]byte, error) {
+	if n.wrapped {
+		return nil, ErrTooManyParts
+	}
+	binary.BigEndian.PutUint32(n.buf[n.prefix:], n.counter)
+	n.buf[len(n.buf)-1] = 0
+	if final {
+		n.buf[len(n.buf)-1] = flagFinal
+	}
+	n.counter++
+	n.wrapped = n.counter == 0
+	return n.buf, nil
+}
+
+// Writer encrypts what is written to it. Close must be called to write the
+// last frame, otherwise readers will report the stream as truncated.
+type Writer struct {
+	ctx    context.Context
+	aead   cipher.AEAD
+	w      io.Writer
+	nonce  *nonce
+	buf    []byte
+	out    []byte
+	closed bool
+}
+
+// NewWriter writes the stream header to w and returns a Writer that encrypts
+// with aead.
+func NewWriter(aead cipher.AEAD, w io.Writer) (*Writer, error) {
+	return NewWriterContext(context.Background(), aead, w)
+}
+
+// NewWriterContext is NewWriter for a Writer that fails with ctx.Err() once
+// ctx is done. ctx is checked before each frame is written.
+func NewWriterContext(ctx context.Context, aead cipher.AEAD, w io.Writer) (*Writer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	n, err := newNonce(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, n.buf[:n.prefix]); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(n.buf[:n.prefix]); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		ctx:   ctx,
+		aead:  aead,
+		w:     w,
+		nonce: n,
+		buf:   make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+func (sw *Writer) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, ErrClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// a full chunk is only flushed once more data follows, so that the
+		// last frame is never empty unless the whole stream is
+		if len(sw.buf) == ChunkSize {
+			if err := sw.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(sw.buf[len(sw.buf):ChunkSize], p)
+		sw.buf = sw.buf[:len(sw.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last frame. It does not close the underlying writer.
+func (sw *Writer) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	return sw.flush(true)
+}
+
+func (sw *Writer) flush(final bool) error {
+	if err := sw.ctx.Err(); err != nil {
+		return err
+	}
+	nonce, err := sw.nonce.next(final)
+	if err != nil {
+		return err
+	}
+
+	var header [frameHeaderSize]byte
+	if final {
+		header[0] = flagFinal
+	}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sw.buf)+sw.aead.Overhead()))
+
+	sw.out = append(sw.out[:0], header[:]...)
+	sw.out = sw.aead.Seal(sw.out, nonce, sw.buf, header[:])
+	sw.buf = sw.buf[:0]
+	_, err = sw.w.Write(sw.out)
+	return err
+}
+
+// Reader decrypts a stream produced by Writer. Data is only returned after the
+// frame it belongs to has been authenticated.
+type Reader struct {
+	ctx   context.Context
+	aead  cipher.AEAD
+	r     io.Reader
+	nonce *nonce
+	buf   []byte
+	plain []byte
+	done  bool
+	err   error
+}
+
+// NewReader returns a Reader that decrypts the stream read from r with aead.
+func NewReader(aead cipher.AEAD, r io.Reader) (*Reader, error) {
+	return NewReaderContext(context.Background(), aead, r)
+}
+
+// NewReaderContext is NewReader for a Reader that fails with ctx.Err() once
+// ctx is done. ctx is checked before each frame is read.
+func NewReaderContext(ctx context.Context, aead cipher.AEAD, r io.Reader) (*Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	n, err := newNonce(aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, n.buf[:n.prefix]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+
+	return &Reader{ctx: ctx, aead: aead, r: r, nonce: n}, nil
+}
+
+func (sr *Reader) Read(p []byte) (int, error) {
+	for len(sr.plain) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.done {
+			return 0, io.EOF
+		}
+		sr.err = sr.next()
+	}
+
+	n := copy(p, sr.plain)
+	sr.plain = sr.plain[n:]
+	return n, nil
+}
+
+// SkipChunks discards the next n frames without opening them, to resume a
+// decryption whose first n*ChunkSize bytes of plaintext were read before.
+// The skipped frames are not authenticated, but the frames after them are
+// opened under the counter they must have, so that a stream cut, reordered
+// or modified after the skipped part is still reported. Only full frames
+// before the last one can be skipped, and not while data of a frame is left
+// to read: SkipChunks fails with ErrSkip otherwise. After any error the
+// Reader only returns that error.
+func (sr *Reader) SkipChunks(n int64) error {
+	if sr.err == nil {
+		if sr.done || len(sr.plain) > 0 || n < 0 || n >= 1<<32 {
+			return ErrSkip
+		}
+		sr.err = sr.skip(n)
+	}
+	return sr.err
+}
+
+func (sr *Reader) skip(n int64) error {
+	size := ChunkSize + sr.aead.Overhead()
+	for ; n > 0; n-- {
+		if err := sr.ctx.Err(); err != nil {
+			return err
+		}
+		var header [frameHeaderSize]byte
+		if _, err := io.ReadFull(sr.r, header[:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return ErrTruncated
+			}
+			return err
+		}
+		if header[0] == flagFinal {
+			return ErrSkip
+		}
+		if header[0] != 0 || binary.BigEndian.Uint32(header[1:]) != uint32(size) {
+			return ErrCorrupted
+		}
+		if _, err := io.CopyN(ioutil.Discard, sr.r, int64(size)); err != nil {
+			if err == io.EOF {
+				return ErrTruncated
+			}
+			return err
+		}
+		if _, err := sr.nonce.next(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sr *Reader) next() error {
+	if err := sr.ctx.Err(); err != nil {
+		return err
+	}
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(sr.r, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	final := header[0] == flagFinal
+	if header[0] > flagFinal {
+		return ErrCorrupted
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size < uint32(sr.aead.Overhead()) || size > uint32(ChunkSize+sr.aead.Overhead()) {
+		return ErrCorrupted
+	}
+
+	if cap(sr.buf) < int(size) {
+		sr.buf = make([]byte, size)
+	}
+	sr.buf = sr.buf[:size]
+	if _, err := io.ReadFull(sr.r, sr.buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	nonce, err := sr.nonce.next(final)
+	if err != nil {
+		return err
+	}
+	plain, err := sr.aead.Open(sr.buf[:0], nonce, sr.buf, header[:])
+	if err != nil {
+		return ErrCorrupted
+	}
+	sr.plain = plain
+
+	if final {
+		sr.done = true
+		// nothing may follow the last frame
+		var extra [1]byte
+		if n, _ := sr.r.Read(extra[:]); n != 0 {
+			return ErrCorrupted
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package sm4stream
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"io/ioutil"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	block, err := sm4.NewCipher(bytes.Repeat([]byte{1}, sm4.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func seal(t *testing.T, aead cipher.AEAD, plain []byte) []byte {
+	var out bytes.Buffer
+	w, err := NewWriter(aead, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(plain[:10])
+	w.Write(plain[10:])
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := newAEAD(t)
+	for _, n := range []int{0, 10, ChunkSize, 3*ChunkSize + 17} {
+		plain := bytes.Repeat([]byte{0x42}, n)
+		if n < 10 {
+			plain = append(plain, make([]byte, 10)...)
+		}
+		r, err := NewReader(aead, bytes.NewReader(seal(t, aead, plain)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("length %d: %v", n, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("length %d: round trip failed", n)
+		}
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	aead := newAEAD(t)
+	sealed := seal(t, aead, bytes.Repeat([]byte{0x42}, 2*ChunkSize+1))
+
+	// drop the last frame
+	cut := len(sealed) - (frameHeaderSize + 1 + aead.Overhead())
+	r, _ := NewReader(aead, bytes.NewReader(sealed[:cut]))
+	if _, err := ioutil.ReadAll(r); err != ErrTruncated {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	r, _ = NewReader(aead, bytes.NewReader(sealed))
+	if _, err := ioutil.ReadAll(r); err != ErrCorrupted {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	aead := newAEAD(t)
+	plain := bytes.Repeat([]byte{0x42}, 3*ChunkSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out bytes.Buffer
+	w, err := NewWriterContext(ctx, aead, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain[:2*ChunkSize]); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := w.Write(plain[2*ChunkSize:]); err != context.Canceled {
+		t.Fatalf("Write: expected context.Canceled, got %v", err)
+	}
+	if err := w.Close(); err != context.Canceled {
+		t.Fatalf("Close: expected context.Canceled, got %v", err)
+	}
+	if _, err := NewWriterContext(ctx, aead, &out); err != context.Canceled {
+		t.Fatalf("NewWriterContext: expected context.Canceled, got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	r, err := NewReaderContext(ctx, aead, bytes.NewReader(seal(t, aead, plain)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, ChunkSize)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := ioutil.ReadAll(r); err != context.Canceled {
+		t.Fatalf("Read: expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	aead := newAEAD(t)
+	for _, n := range []int{0, 10, ChunkSize, 5*ChunkSize + 17} {
+		plain := bytes.Repeat([]byte{0x42}, n)
+
+		sealed, err := Seal(aead, plain, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(aead, bytes.NewReader(sealed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ioutil.ReadAll(r); err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("length %d: Reader cannot read Seal: %v", n, err)
+		}
+
+		var written bytes.Buffer
+		w, _ := NewWriter(aead, &written)
+		w.Write(plain)
+		w.Close()
+		for _, stream := range [][]byte{sealed, written.Bytes()} {
+			if got, err := Open(aead, stream, 0); err != nil || !bytes.Equal(got, plain) {
+				t.Fatalf("length %d: Open failed: %v", n, err)
+			}
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	aead := newAEAD(t)
+	sealed, err := Seal(aead, bytes.Repeat([]byte{0x42}, 2*ChunkSize+1), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := len(sealed) - (frameHeaderSize + 1 + aead.Overhead())
+
+	modified := append([]byte(nil), sealed...)
+	modified[100] ^= 1
+	reordered := append([]byte(nil), sealed...)
+	first := len(sealed[aead.NonceSize()-5 : last])
+	copy(reordered[aead.NonceSize()-5:], sealed[aead.NonceSize()-5+first/2:last])
+	copy(reordered[aead.NonceSize()-5+first/2:], sealed[aead.NonceSize()-5:aead.NonceSize()-5+first/2])
+
+	for name, c := range map[string]struct {
+		stream []byte
+		err    error
+	}{
+		"empty":     {nil, ErrTruncated},
+		"no final":  {sealed[:last], ErrTruncated},
+		"cut frame": {sealed[:len(sealed)-1], ErrTruncated},
+		"trailing":  {append(append([]byte(nil), sealed...), 0), ErrCorrupted},
+		"modified":  {modified, ErrCorrupted},
+		"reordered": {reordered, ErrCorrupted},
+	} {
+		if got, err := Open(aead, c.stream, 0); err != c.err || got != nil {
+			t.Errorf("%s: got %v, want %v", name, err, c.err)
+		}
+	}
+}
+
+func TestSkipChunks(t *testing.T) {
+	aead := newAEAD(t)
+	plain := make([]byte, 3*ChunkSize+10)
+	for i := range plain {
+		plain[i] = byte(i / ChunkSize)
+	}
+	sealed := seal(t, aead, plain)
+
+	for n := int64(0); n <= 3; n++ {
+		r, _ := NewReader(aead, bytes.NewReader(sealed))
+		if err := r.SkipChunks(n); err != nil {
+			t.Fatalf("skip %d: %v", n, err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(got, plain[n*ChunkSize:]) {
+			t.Fatalf("skip %d: read after skipping failed: %v", n, err)
+		}
+	}
+
+	r, _ := NewReader(aead, bytes.NewReader(sealed))
+	if err := r.SkipChunks(4); err != ErrSkip {
+		t.Errorf("skipping the last chunk: %v", err)
+	}
+	r, _ = NewReader(aead, bytes.NewReader(sealed))
+	r.Read(make([]byte, 1))
+	if err := r.SkipChunks(1); err != ErrSkip {
+		t.Errorf("skipping within a chunk: %v", err)
+	}
+	r, _ = NewReader(aead, bytes.NewReader(sealed[:len(sealed)/2]))
+	if err := r.SkipChunks(3); err != ErrTruncated {
+		t.Errorf("skipping past the end: %v", err)
+	}
+
+	// frames after the skipped ones are still authenticated
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	r, _ = NewReader(aead, bytes.NewReader(tampered))
+	if err := r.SkipChunks(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); err != ErrCorrupted {
+		t.Errorf("tampered frame after skipping: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package sm9
+
+import (
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm9/bn256"
+)
+
+// The DER structures below follow the ASN.1 modules used with GM/T 0044 by
+// commercial KGC products (and GmSSL). Points are carried in BIT STRINGs as
+// uncompressed octets, 04 || x || y; a G2 coordinate is written as its
+// coefficient of i followed by its constant term.
+//
+//	SM9SignMasterKey ::= SEQUENCE { ks INTEGER, Ppubs BIT STRING }
+//	SM9SignMasterPublicKey ::= SEQUENCE { Ppubs BIT STRING }
+//	SM9SignPrivateKey ::= SEQUENCE { ds BIT STRING, Ppubs BIT STRING }
+//	SM9EncMasterKey ::= SEQUENCE { ke INTEGER, Ppube BIT STRING }
+//	SM9EncMasterPublicKey ::= SEQUENCE { Ppube BIT STRING }
+//	SM9EncPrivateKey ::= SEQUENCE { de BIT STRING, Ppube BIT STRING }
+//	SM9Signature ::= SEQUENCE { h OCTET STRING, S BIT STRING }
+//	SM9Cipher ::= SEQUENCE {
+//		EnType     INTEGER, -- 0 for the stream cipher mode
+//		C1         BIT STRING,
+//		C3         OCTET STRING,
+//		CipherText OCTET STRING }
+
+type masterKeyASN1 struct {
+	D   *big.Int
+	Pub asn1.BitString
+}
+
+type masterPublicKeyASN1 struct {
+	Pub asn1.BitString
+}
+
+type privateKeyASN1 struct {
+	Priv asn1.BitString
+	Pub  asn1.BitString
+}
+
+type signatureASN1 struct {
+	H []byte
+	S asn1.BitString
+}
+
+type cipherASN1 struct {
+	EnType     int
+	C1         asn1.BitString
+	C3         []byte
+	CipherText []byte
+}
+
+// encTypeXOR identifies the stream cipher mode of encryption.
+const encTypeXOR = 0
+
+var errPoint = errors.New("sm9: invalid point encoding")
+
+func bitString(b []byte) asn1.BitString {
+	return asn1.BitString{Bytes: b, BitLength: 8 * len(b)}
+}
+
+func marshalG1(p *bn256.G1) asn1.BitString {
+	return bitString(append([]byte{4}, p.Marshal()...))
+}
+
+func marshalG2(p *bn256.G2) asn1.BitString {
+	// bn256 prefixes a finite point with 0x01.
+	m := p.Marshal()
+	m[0] = 4
+	return bitString(m)
+}
+
+func parseG1(bs asn1.BitString) (*bn256.G1, error) {
+	b := bs.RightAlign()
+	if len(b) != 65 || b[0] != 4 || isZero(b[1:]) {
+		return nil, errPoint
+	}
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(b[1:]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseG2(bs asn1.BitString) (*bn256.G2, error) {
+	b := bs.RightAlign()
+	if len(b) != 129 || b[0] != 4 || isZero(b[1:]) {
+		return nil, errPoint
+	}
+	m := append([]byte{1}, b[1:]...)
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(m); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func unmarshalDER(der []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(der, v)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("sm9: trailing data after ASN.1 structure")
+	}
+	return nil
+}
+
+// MarshalASN1 returns the DER encoding of the signature master key.
+func (master *SignMasterPrivateKey) MarshalASN1() ([]byte, error) {
+	return asn1.Marshal(masterKeyASN1{master.D, marshalG2(master.MasterPublicKey)})
+}
+
+// ParseSignMasterPrivateKey parses a DER encoded signature master key.
+func ParseSignMasterPrivateKey(der []byte) (*SignMasterPrivateKey, error) {
+	var v masterKeyASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	master, err := NewSignMasterPrivateKey(v.D)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parseG2(v.Pub)
+	if err != nil {
+		return nil, err
+	}
+	if string(pub.Marshal()) != string(master.MasterPublicKey.Marshal()) {
+		return nil, errors.New("sm9: master public key does not match private key")
+	}
+	return master, nil
+}
+
+// MarshalASN1 returns the DER encoding of the signature master public key.
+func (pub *SignMasterPublicKey) MarshalASN1() ([]byte, error) {
+	return asn1.Marshal(masterPublicKeyASN1{marshalG2(pub.MasterPublicKey)})
+}
+
+// ParseSignMasterPublicKey parses a DER encoded signature master public key.
+func ParseSignMasterPublicKey(der []byte) (*SignMasterPublicKey, error) {
+	var v masterPublicKeyASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	p, err := parseG2(v.Pub)
+	if err != nil {
+		return nil, err
+	}
+	return &SignMasterPublicKey{p}, nil
+}
+
+// MarshalASN1 returns the DER encoding of the user signing key.
+func (priv *SignPrivateKey) MarshalASN1() ([]byte, error) {
+	return asn1.Marshal(privateKeyASN1{marshalG1(priv.PrivateKey), marshalG2(priv.MasterPublicKey)})
+}
+
+// ParseSignPrivateKey parses a DER encoded user signing key.
+func ParseSignPrivateKey(der []byte) (*SignPrivateKey, error) {
+	var v privateKeyASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	d, err := parseG1(v.Priv)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parseG2(v.Pub)
+	if err != nil {
+		return nil, err
+	}
+	return &SignPrivateKey{d, SignMasterPublicKey{pub}}, nil
+}
+
+// MarshalASN1 returns the DER encoding of the encryption master key.
+func (master *EncryptMasterPrivateKey) MarshalASN1() ([]byte, error) {
+	return asn1.Marshal(masterKeyASN1{master.D, marshalG1(master.MasterPublicKey)})
+}
+
+// ParseEncryptMasterPrivateKey parses a DER encoded encryption master key.
+func ParseEncryptMasterPrivateKey(der []byte) (*EncryptMasterPrivateKey, error) {
+	var v masterKeyASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	master, err := NewEncryptMasterPrivateKey(v.D)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parseG1(v.Pub)
+	if err != nil {
+		return nil, err
+	}
+	if string(pub.Marshal()) != string(master.MasterPublicKey.Marshal()) {
+		return nil, errors.New("sm9: master public key does not match private key")
+	}
+	return master, nil
+}
+
+// MarshalASN1 returns the DER encoding of the encryption master public key.
+func (pub *EncryptMasterPublicKey) MarshalASN1() ([]byte, error) {
+	return asn1.Marshal(masterPublicKeyASN1{marshalG1(pub.MasterPublicKey)})
+}
+
+// ParseEncryptMasterPublicKey parses a DER encoded encryption master public
+// key.
+func ParseEncryptMasterPublicKey(der []byte) (*EncryptMasterPublicKey, error) {
+	var v masterPublicKeyASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	p, err := parseG1(v.Pub)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptMasterPublicKey{p}, nil
+}
+
+// MarshalASN1 returns the DER encoding of the user decryption key.
+func (priv *EncryptPrivateKey) MarshalASN1() ([]byte, error) {
+	return asn1.Marshal(privateKeyASN1{marshalG2(priv.PrivateKey), marshalG1(priv.MasterPublicKey)})
+}
+
+// ParseEncryptPrivateKey parses a DER encoded user decryption key.
+func ParseEncryptPrivateKey(der []byte) (*EncryptPrivateKey, error) {
+	var v privateKeyASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	d, err := parseG2(v.Priv)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := parseG1(v.Pub)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptPrivateKey{d, EncryptMasterPublicKey{pub}}, nil
+}
+
+// MarshalSignature returns the DER encoding of the signature (h, S).
+func MarshalSignature(h *big.Int, s *bn256.G1) ([]byte, error) {
+	if h.Sign() <= 0 || h.Cmp(order) >= 0 {
+		return nil, errors.New("sm9: invalid signature")
+	}
+	hb := make([]byte, 32)
+	b := h.Bytes()
+	copy(hb[32-len(b):], b)
+	return asn1.Marshal(signatureASN1{hb, marshalG1(s)})
+}
+
+// ParseSignature parses a DER encoded signature.
+func ParseSignature(der []byte) (h *big.Int, s *bn256.G1, err error) {
+	var v signatureASN1
+	if err = unmarshalDER(der, &v); err != nil {
+		return nil, nil, err
+	}
+	if len(v.H) != 32 {
+		return nil, nil, errors.New("sm9: invalid signature")
+	}
+	if s, err = parseG1(v.S); err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(v.H), s, nil
+}
+
+// EncryptASN1 encrypts msg to the identity uid like Encrypt, and returns the
+// ciphertext as a DER encoded SM9Cipher structure.
+func EncryptASN1(rand io.Reader, pub *EncryptMasterPublicKey, uid []byte, hid byte, msg []byte) ([]byte, error) {
+	c1, c3, c2, err := encrypt(rand, pub, uid, hid, msg)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(cipherASN1{encTypeXOR, marshalG1(c1), c3, c2})
+}
+
+// DecryptASN1 decrypts a DER encoded SM9Cipher structure for the identity uid.
+func DecryptASN1(priv *EncryptPrivateKey, uid, der []byte) ([]byte, error) {
+	var v cipherASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, ErrDecryption
+	}
+	if v.EnType != encTypeXOR || len(v.C3) != sm3.Size {
+		return nil, ErrDecryption
+	}
+	c1, err := parseG1(v.C1)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return decrypt(priv, uid, c1, v.C3, v.CipherText)
+}
+
+// CiphertextToASN1 converts a C1 || C3 || C2 ciphertext, as returned by
+// Encrypt, into a DER encoded SM9Cipher structure.
+func CiphertextToASN1(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 64+sm3.Size {
+		return nil, errors.New("sm9: ciphertext too short")
+	}
+	c1 := append([]byte{4}, ciphertext[:64]...)
+	return asn1.Marshal(cipherASN1{
+		encTypeXOR,
+		bitString(c1),
+		ciphertext[64 : 64+sm3.Size],
+		ciphertext[64+sm3.Size:],
+	})
+}
+
+// ASN1ToCiphertext converts a DER encoded SM9Cipher structure into the
+// C1 || C3 || C2 form accepted by Decrypt.
+func ASN1ToCiphertext(der []byte) ([]byte, error) {
+	var v cipherASN1
+	if err := unmarshalDER(der, &v); err != nil {
+		return nil, err
+	}
+	if v.EnType != encTypeXOR {
+		return nil, errors.New("sm9: unsupported encryption type")
+	}
+	c1 := v.C1.RightAlign()
+	if len(c1) != 65 || c1[0] != 4 || len(v.C3) != sm3.Size {
+		return nil, errors.New("sm9: invalid ciphertext")
+	}
+	out := make([]byte, 0, 64+len(v.C3)+len(v.CipherText))
+	out = append(out, c1[1:]...)
+	out = append(out, v.C3...)
+	return append(out, v.CipherText...), nil
+}
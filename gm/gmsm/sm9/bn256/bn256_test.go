@@ -0,0 +1,199 @@
+package bn256
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestGenerators(t *testing.T) {
+	if !curveGen.IsOnCurve() {
+		t.Fatal("G1 generator is not on the curve")
+	}
+	if !twistGen.IsOnCurve() {
+		t.Fatal("G2 generator is not on the twist")
+	}
+	if !new(G1).ScalarBaseMult(Order).p.IsInfinity() {
+		t.Fatal("G1 generator does not have order n")
+	}
+	if !new(G2).ScalarBaseMult(Order).p.IsInfinity() {
+		t.Fatal("G2 generator does not have order n")
+	}
+
+	g := Pair(new(G1).ScalarBaseMult(big.NewInt(1)), new(G2).ScalarBaseMult(big.NewInt(1)))
+	if *g.p != *gfP12Gen {
+		t.Fatal("e(g1, g2) does not match the GT generator")
+	}
+	if g.p.IsOne() || !new(GT).ScalarMult(g, Order).p.IsOne() {
+		t.Fatal("GT generator does not have order n")
+	}
+}
+
+// TestGMT0044Example checks the master public key and the pairing value g
+// from the signature example of GM/T 0044 part 5.
+func TestGMT0044Example(t *testing.T) {
+	ks := bigFromBase16("0130E78459D78545CB54C587E02CF480CE0B66340F319F348A1D5B1F2DC5F4")
+	pub := new(G2).ScalarBaseMult(ks)
+	want := decodeHex("01" +
+		"9F64080B3084F733E48AFF4B41B565011CE0711C5E392CFB0AB1B6791B94C408" +
+		"29DBA116152D1F786CE843ED24A3B573414D2177386A92DD8F14D65696EA5E32" +
+		"69850938ABEA0112B57329F447E3A0CBAD3E2FDB1A77F335E89E1408D0EF1C25" +
+		"41E00A53DDA532DA1A7CE027B7A46F741006E85F5CDFF0730E75C05FB4E3216D")
+	if got := pub.Marshal(); !bytes.Equal(got, want) {
+		t.Fatalf("Ppub-s = %x, want %x", got, want)
+	}
+
+	g := Pair(new(G1).ScalarBaseMult(big.NewInt(1)), pub)
+	want = decodeHex(
+		"4E378FB5561CD0668F906B731AC58FEE25738EDF09CADC7A29C0ABC0177AEA6D" +
+			"28B3404A61908F5D6198815C99AF1990C8AF38655930058C28C21BB539CE0000" +
+			"38BFFE40A22D529A0C66124B2C308DAC9229912656F62B4FACFCED408E02380F" +
+			"A01F2C8BEE81769609462C69C96AA923FD863E209D3CE26DD889B55E2E3873DB" +
+			"67E0E0C2EED7A6993DCE28FE9AA2EF56834307860839677F96685F2B44D0911F" +
+			"5A1AE172102EFD95DF7338DBC577C66D8D6C15E0A0158C7507228EFB078F42A6" +
+			"1604A3FCFA9783E667CE9FCB1062C2A5C6685C316DDA62DE0548BAA6BA30038B" +
+			"93634F44FA13AF76169F3CC8FBEA880ADAFF8475D5FD28A75DEB83C44362B439" +
+			"B3129A75D31D17194675A1BC56947920898FBF390A5BF5D931CE6CBB3340F66D" +
+			"4C744E69C4A2E1C8ED72F796D151A17CE2325B943260FC460B9F73CB57C9014B" +
+			"84B87422330D7936EABA1109FA5A7A7181EE16F2438B0AEB2F38FD5F7554E57A" +
+			"AAB9F06A4EEBA4323A7833DB202E4E35639D93FA3305AF73F0F071D7D284FCFB")
+	if got := g.Marshal(); !bytes.Equal(got, want) {
+		t.Fatalf("g = %x, want %x", got, want)
+	}
+}
+
+func TestBilinearity(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		a, p1, _ := RandomG1(rand.Reader)
+		b, p2, _ := RandomG2(rand.Reader)
+		e1 := Pair(p1, p2)
+
+		e2 := Pair(new(G1).ScalarBaseMult(big.NewInt(1)), new(G2).ScalarBaseMult(big.NewInt(1)))
+		e2.ScalarMult(e2, a)
+		e2.ScalarMult(e2, b)
+
+		if !bytes.Equal(e1.Marshal(), e2.Marshal()) {
+			t.Fatal("bad pairing result")
+		}
+
+		e3 := new(GT).ScalarBaseMult(new(big.Int).Mul(a, b))
+		if !bytes.Equal(e1.Marshal(), e3.Marshal()) {
+			t.Fatal("pairing does not match GT generator")
+		}
+	}
+}
+
+func TestMillerFinalize(t *testing.T) {
+	_, p1, _ := RandomG1(rand.Reader)
+	_, p2, _ := RandomG2(rand.Reader)
+
+	e1 := Pair(p1, p2)
+	e2 := Miller(p1, p2).Finalize()
+	if !bytes.Equal(e1.Marshal(), e2.Marshal()) {
+		t.Fatal("Miller(a, b).Finalize() != Pair(a, b)")
+	}
+
+	// e(a, b)·e(-a, b) = 1
+	e3 := Pair(new(G1).Neg(p1), p2)
+	if !e3.Add(e3, e1).p.IsOne() {
+		t.Fatal("e(a, b)·e(-a, b) != 1")
+	}
+}
+
+func TestG1Marshal(t *testing.T) {
+	_, g, _ := RandomG1(rand.Reader)
+	m := g.Marshal()
+	g2 := new(G1)
+	if _, err := g2.Unmarshal(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m, g2.Marshal()) {
+		t.Fatal("G1 round trip failed")
+	}
+
+	m[len(m)-1] ^= 1
+	if _, err := new(G1).Unmarshal(m); err == nil {
+		t.Fatal("point off the curve accepted")
+	}
+	copy(m, decodeHex("B640000002A3A6F1D603AB4FF58EC74521F2934B1A7AEEDBE56F9B27E351457D"))
+	if _, err := new(G1).Unmarshal(m); err == nil {
+		t.Fatal("unreduced coordinate accepted")
+	}
+}
+
+func TestG2Marshal(t *testing.T) {
+	_, g, _ := RandomG2(rand.Reader)
+	m := g.Marshal()
+	g2 := new(G2)
+	if _, err := g2.Unmarshal(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m, g2.Marshal()) {
+		t.Fatal("G2 round trip failed")
+	}
+
+	inf := new(G2).ScalarBaseMult(new(big.Int))
+	if _, err := g2.Unmarshal(inf.Marshal()); err != nil || !g2.p.IsInfinity() {
+		t.Fatal("point at infinity round trip failed")
+	}
+
+	m[len(m)-1] ^= 1
+	if _, err := new(G2).Unmarshal(m); err == nil {
+		t.Fatal("point off the twist accepted")
+	}
+}
+
+func TestGTMarshal(t *testing.T) {
+	_, g, _ := RandomGT(rand.Reader)
+	m := g.Marshal()
+	g2 := new(GT)
+	if _, err := g2.Unmarshal(m); err != nil {
+		t.Fatal(err)
+	}
+	if *g.p != *g2.p {
+		t.Fatal("GT round trip failed")
+	}
+}
+
+func BenchmarkG1(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, Order)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		new(G1).ScalarBaseMult(x)
+	}
+}
+
+func BenchmarkG2(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, Order)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		new(G2).ScalarBaseMult(x)
+	}
+}
+
+func BenchmarkGT(b *testing.B) {
+	x, _ := rand.Int(rand.Reader, Order)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		new(GT).ScalarBaseMult(x)
+	}
+}
+
+func BenchmarkPairing(b *testing.B) {
+	g1 := new(G1).ScalarBaseMult(big.NewInt(1))
+	g2 := new(G2).ScalarBaseMult(big.NewInt(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Pair(g1, g2)
+	}
+}
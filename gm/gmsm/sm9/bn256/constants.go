@@ -0,0 +1,64 @@
+package bn256
+
+import (
+	"math/big"
+)
+
+func bigFromBase16(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 16)
+	return n
+}
+
+// u is the BN parameter that determines the prime, called t in GM/T 0044.
+var u = bigFromBase16("600000000058F98A")
+
+// p is a prime over which we form a basic field: 36u⁴+36u³+24u²+6u+1.
+var p = bigFromBase16("B640000002A3A6F1D603AB4FF58EC74521F2934B1A7AEEDBE56F9B27E351457D")
+
+// Order is the number of elements in both G₁ and G₂: 36u⁴+36u³+18u²+6u+1.
+var Order = bigFromBase16("B640000002A3A6F1D603AB4FF58EC74449F2934B18EA8BEEE56EE19CD69ECF25")
+
+// xiToPMinus1Over6 is ξ^((p-1)/6) where ξ = i.
+var xiToPMinus1Over6 = &gfP2{gfP{0x0, 0x0, 0x0, 0x0}, gfP{0x1a98dfbd4575299f, 0x9ec8547b245c54fd, 0xf51f5eac13df846c, 0x9ef74015d5a16393}}
+
+// xiToPMinus1Over3 is ξ^((p-1)/3) where ξ = i.
+var xiToPMinus1Over3 = &gfP2{gfP{0x0, 0x0, 0x0, 0x0}, gfP{0xb626197dce4736ca, 0x8296b3557ed0186, 0x9c705db2fd91512a, 0x1c753e748601c992}}
+
+// xiTo2PMinus2Over3 is ξ^((2p-2)/3) where ξ = i.
+var xiTo2PMinus2Over3 = &gfP2{gfP{0x0, 0x0, 0x0, 0x0}, gfP{0x81054fcd94e9c1c4, 0x4c0e91cb8ce2df3e, 0x4877b452e8aedfb4, 0x88f53e748b491776}}
+
+// xiToPSquaredMinus1Over3 is ξ^((p²-1)/3) where ξ = i.
+var xiToPSquaredMinus1Over3 = &gfP{0x81054fcd94e9c1c4, 0x4c0e91cb8ce2df3e, 0x4877b452e8aedfb4, 0x88f53e748b491776}
+
+// xiTo2PSquaredMinus2Over3 is ξ^((2p²-2)/3) where ξ = i (a cubic root of unity, mod p).
+var xiTo2PSquaredMinus2Over3 = &gfP{0x2f4981aa150a0eb3, 0x19c92815c28ded55, 0x39934d9cf7fd761b, 0x99cac18b7ca1dd5f}
+
+// xiToPSquaredMinus1Over6 is ξ^((p²-1)/6) where ξ = i (a cubic root of -1, mod p).
+var xiToPSquaredMinus1Over6 = &gfP{0xb626197dce4736ca, 0x8296b3557ed0186, 0x9c705db2fd91512a, 0x1c753e748601c992}
+
+// xiToOneMinusPOver3 is ξ^((1-p)/3) where ξ = i.
+var xiToOneMinusPOver3 = &gfP2{gfP{0x0, 0x0, 0x0, 0x0}, gfP{0x646a4b5a4e6783b9, 0xd5e4017f8d980f9d, 0x8d8bf6fd0cdfe790, 0x2d4ac18b775a8f7b}}
+
+// xiToOneMinusPOver2 is ξ^((1-p)/2) where ξ = i.
+var xiToOneMinusPOver2 = &gfP2{gfP{0x0, 0x0, 0x0, 0x0}, gfP{0xabbaac18a46a2054, 0x46ee57561222c759, 0x1dae609fa0e23561, 0x1df7113dae0adc3c}}
+
+// xiToOneMinusPSquaredOver3 is ξ^((1-p²)/3) where ξ = i.
+var xiToOneMinusPSquaredOver3 = &gfP{0x2f4981aa150a0eb3, 0x19c92815c28ded55, 0x39934d9cf7fd761b, 0x99cac18b7ca1dd5f}
+
+// p2 is p, represented as little-endian 64-bit words.
+var p2 = [4]uint64{0xe56f9b27e351457d, 0x21f2934b1a7aeedb, 0xd603ab4ff58ec745, 0xb640000002a3a6f1}
+
+// np is the negative inverse of p, mod 2^256.
+var np = [4]uint64{0x892bc42c2f2ee42b, 0x181ae39613c8dbaf, 0x966a4b291522b137, 0xafd2bac5558a13b3}
+
+// rN1 is R^-1 where R = 2^256 mod p.
+var rN1 = &gfP{0xa1c7970e5df544d, 0xe74504e9a96b56cc, 0xcda02d92d4d62924, 0x7d2bc576fdf597d1}
+
+// r2 is R^2 where R = 2^256 mod p.
+var r2 = &gfP{0x27dea312b417e2d2, 0x88f8105fae1a5d3f, 0xe479b522d6706e7b, 0x2ea795a656f62fbd}
+
+// r3 is R^3 where R = 2^256 mod p.
+var r3 = &gfP{0x130257769df5827e, 0x36920fc0837ec76e, 0xcbec24519c22a142, 0x219be84a7c687090}
+
+// pMinus2 is p-2.
+var pMinus2 = [4]uint64{0xe56f9b27e351457b, 0x21f2934b1a7aeedb, 0xd603ab4ff58ec745, 0xb640000002a3a6f1}
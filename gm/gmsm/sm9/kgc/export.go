@@ -0,0 +1,297 @@
+package kgc
+
+import (
+	"crypto/cipher"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/scryptsm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+	"github.com/xuperchain/crypto/gm/gmsm/sm9"
+)
+
+// PEM block types used by the export formats.
+const (
+	MasterKeyPEMType              = "SM9 ENCRYPTED MASTER KEY"
+	UserKeyPEMType                = "SM9 ENCRYPTED USER KEY"
+	SignMasterPublicKeyPEMType    = "SM9 SIGN MASTER PUBLIC KEY"
+	EncryptMasterPublicKeyPEMType = "SM9 ENC MASTER PUBLIC KEY"
+)
+
+// scrypt parameters for new exports. Imports use the parameters stored in
+// the exported data.
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const (
+	saltSize = 16
+	// maxScryptN bounds the work an import can be made to do.
+	maxScryptN = 1 << 20
+)
+
+// ErrPassword is returned when exported data cannot be decrypted, typically
+// because of a wrong password.
+var ErrPassword = errors.New("kgc: wrong password or corrupted data")
+
+// encryptedASN1 is the content of an encrypted PEM block: the plaintext is
+// sealed with SM4-GCM under a key derived from the password with scrypt over
+// SM3, and the PEM type is authenticated as additional data.
+type encryptedASN1 struct {
+	Salt  []byte
+	N     int
+	R     int
+	P     int
+	Nonce []byte
+	Data  []byte
+}
+
+type masterASN1 struct {
+	Domain      string `asn1:"utf8"`
+	MaxValidity int64
+	Sign        []byte
+	Encrypt     []byte
+}
+
+type userASN1 struct {
+	Name      string `asn1:"utf8"`
+	NotBefore int64
+	NotAfter  int64
+	Sign      []byte
+	Encrypt   []byte
+}
+
+func newAEAD(password, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scryptsm3.Key(password, salt, n, r, p, sm4.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(rand io.Reader, typ string, password, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand, salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(password, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand, nonce); err != nil {
+		return nil, err
+	}
+
+	der, err := asn1.Marshal(encryptedASN1{
+		Salt:  salt,
+		N:     scryptN,
+		R:     scryptR,
+		P:     scryptP,
+		Nonce: nonce,
+		Data:  aead.Seal(nil, nonce, plaintext, []byte(typ)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), nil
+}
+
+func open(typ string, data, password []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != typ {
+		return nil, errors.New("kgc: expected PEM block of type " + typ)
+	}
+	var v encryptedASN1
+	rest, err := asn1.Unmarshal(block.Bytes, &v)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("kgc: trailing data after encrypted key")
+	}
+	if v.N > maxScryptN || v.R <= 0 || v.P <= 0 || v.R*v.P >= 1<<20 {
+		return nil, errors.New("kgc: unsupported scrypt parameters")
+	}
+
+	aead, err := newAEAD(password, v.Salt, v.N, v.R, v.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.Nonce) != aead.NonceSize() {
+		return nil, ErrPassword
+	}
+	plaintext, err := aead.Open(nil, v.Nonce, v.Data, []byte(typ))
+	if err != nil {
+		return nil, ErrPassword
+	}
+	return plaintext, nil
+}
+
+// Export returns the master keys and policy of the KGC as a PEM block
+// encrypted under password.
+func (k *KGC) Export(rand io.Reader, password []byte) ([]byte, error) {
+	sign, err := k.sign.MarshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	enc, err := k.enc.MarshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(masterASN1{k.domain, int64(k.MaxValidity), sign, enc})
+	if err != nil {
+		return nil, err
+	}
+	return seal(rand, MasterKeyPEMType, password, der)
+}
+
+// Import restores a KGC from the output of Export.
+func Import(data, password []byte) (*KGC, error) {
+	der, err := open(MasterKeyPEMType, data, password)
+	if err != nil {
+		return nil, err
+	}
+	var v masterASN1
+	if _, err := asn1.Unmarshal(der, &v); err != nil {
+		return nil, err
+	}
+	if v.Domain != "" {
+		if err := checkName(v.Domain); err != nil {
+			return nil, err
+		}
+	}
+	sign, err := sm9.ParseSignMasterPrivateKey(v.Sign)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := sm9.ParseEncryptMasterPrivateKey(v.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+	return &KGC{
+		MaxValidity: time.Duration(v.MaxValidity),
+		domain:      v.Domain,
+		sign:        sign,
+		enc:         enc,
+		now:         time.Now,
+	}, nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrZero(s int64) time.Time {
+	if s == 0 {
+		return time.Time{}
+	}
+	return time.Unix(s, 0).UTC()
+}
+
+// Export returns the user key as a PEM block encrypted under password, for
+// delivery to its owner.
+func (u *UserKey) Export(rand io.Reader, password []byte) ([]byte, error) {
+	sign, err := u.Sign.MarshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	enc, err := u.Encrypt.MarshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(userASN1{
+		Name:      u.Identity.Name,
+		NotBefore: unixOrZero(u.Identity.NotBefore),
+		NotAfter:  unixOrZero(u.Identity.NotAfter),
+		Sign:      sign,
+		Encrypt:   enc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return seal(rand, UserKeyPEMType, password, der)
+}
+
+// ImportUserKey restores a user key from the output of UserKey.Export.
+func ImportUserKey(data, password []byte) (*UserKey, error) {
+	der, err := open(UserKeyPEMType, data, password)
+	if err != nil {
+		return nil, err
+	}
+	var v userASN1
+	if _, err := asn1.Unmarshal(der, &v); err != nil {
+		return nil, err
+	}
+	sign, err := sm9.ParseSignPrivateKey(v.Sign)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := sm9.ParseEncryptPrivateKey(v.Encrypt)
+	if err != nil {
+		return nil, err
+	}
+	return &UserKey{
+		Identity: Identity{
+			Name:      v.Name,
+			NotBefore: timeOrZero(v.NotBefore),
+			NotAfter:  timeOrZero(v.NotAfter),
+		},
+		Sign:    sign,
+		Encrypt: enc,
+	}, nil
+}
+
+// ExportPublicParams returns the master public keys of the KGC as PEM
+// blocks, to be published to signers, verifiers and encryptors.
+func (k *KGC) ExportPublicParams() ([]byte, error) {
+	sign, err := k.SignMasterPublicKey().MarshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	enc, err := k.EncryptMasterPublicKey().MarshalASN1()
+	if err != nil {
+		return nil, err
+	}
+	out := pem.EncodeToMemory(&pem.Block{Type: SignMasterPublicKeyPEMType, Bytes: sign})
+	return append(out, pem.EncodeToMemory(&pem.Block{Type: EncryptMasterPublicKeyPEMType, Bytes: enc})...), nil
+}
+
+// ParsePublicParams parses the output of ExportPublicParams.
+func ParsePublicParams(data []byte) (*sm9.SignMasterPublicKey, *sm9.EncryptMasterPublicKey, error) {
+	var sign *sm9.SignMasterPublicKey
+	var enc *sm9.EncryptMasterPublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var err error
+		switch block.Type {
+		case SignMasterPublicKeyPEMType:
+			sign, err = sm9.ParseSignMasterPublicKey(block.Bytes)
+		case EncryptMasterPublicKeyPEMType:
+			enc, err = sm9.ParseEncryptMasterPublicKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if sign == nil || enc == nil {
+		return nil, nil, errors.New("kgc: missing master public key")
+	}
+	return sign, enc, nil
+}
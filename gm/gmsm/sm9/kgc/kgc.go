@@ -0,0 +1,205 @@
+// Package kgc implements an SM9 key generation center: it manages the SM9
+// master secrets and issues user keys for identities.
+//
+// Identities are hierarchical names whose components are separated by "/",
+// such as "example.com/sales/alice", optionally bound to a validity period.
+// The validity period is part of the identity string the keys are derived
+// from (see Identity.Bytes), so a key is only usable for the period it was
+// issued for and expired keys need no revocation.
+//
+// SM9 has no cryptographic key delegation: a sub-KGC returned by Sub shares
+// the master secrets of its parent and is only restricted, by policy, to
+// issuing keys within its subtree of the identity namespace.
+package kgc
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm9"
+)
+
+// Separator separates the components of a hierarchical identity name.
+const Separator = "/"
+
+// validityLayout is the time format of the validity period in Identity.Bytes.
+const validityLayout = "20060102150405Z"
+
+var (
+	// ErrIdentity is returned for malformed identity names.
+	ErrIdentity = errors.New("kgc: invalid identity")
+	// ErrOutsideDomain is returned when an identity is not within the
+	// domain of the KGC.
+	ErrOutsideDomain = errors.New("kgc: identity outside the domain of this KGC")
+	// ErrValidity is returned for an empty, expired or too long validity
+	// period.
+	ErrValidity = errors.New("kgc: invalid validity period")
+)
+
+// Identity is an SM9 identity with an optional validity period.
+type Identity struct {
+	// Name is the hierarchical name, e.g. "example.com/sales/alice".
+	Name string
+	// NotBefore and NotAfter bound the validity period. The period is
+	// unbounded if both are zero.
+	NotBefore, NotAfter time.Time
+}
+
+// Bytes returns the identity string that SM9 keys are derived from. It is
+// Name, followed by "|" and the validity period as two UTC timestamps
+// joined by "-" if the period is set:
+//
+//	example.com/sales/alice|20260101000000Z-20270101000000Z
+//
+// Signers and encryptors must use the same string as the KGC.
+func (id Identity) Bytes() []byte {
+	if id.NotBefore.IsZero() && id.NotAfter.IsZero() {
+		return []byte(id.Name)
+	}
+	return []byte(id.Name + "|" +
+		id.NotBefore.UTC().Format(validityLayout) + "-" +
+		id.NotAfter.UTC().Format(validityLayout))
+}
+
+// Valid reports whether the identity's validity period includes t.
+func (id Identity) Valid(t time.Time) bool {
+	if id.NotBefore.IsZero() && id.NotAfter.IsZero() {
+		return true
+	}
+	return !t.Before(id.NotBefore) && !t.After(id.NotAfter)
+}
+
+// Parent returns the name of the parent of name in the hierarchy, or "" for
+// a top-level name.
+func Parent(name string) string {
+	if i := strings.LastIndex(name, Separator); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// Within reports whether name equals domain or lies in its subtree. Every
+// name is within the empty domain.
+func Within(name, domain string) bool {
+	if domain == "" || name == domain {
+		return true
+	}
+	return strings.HasPrefix(name, domain+Separator)
+}
+
+func checkName(name string) error {
+	if name == "" || strings.Contains(name, "|") {
+		return ErrIdentity
+	}
+	for _, c := range strings.Split(name, Separator) {
+		if c == "" {
+			return ErrIdentity
+		}
+	}
+	return nil
+}
+
+// UserKey is the key material issued to an identity.
+type UserKey struct {
+	Identity Identity
+	Sign     *sm9.SignPrivateKey
+	Encrypt  *sm9.EncryptPrivateKey
+}
+
+// KGC is an SM9 key generation center.
+type KGC struct {
+	// MaxValidity limits the validity period of issued keys. If it is
+	// non-zero, only identities with a validity period of at most
+	// MaxValidity are accepted.
+	MaxValidity time.Duration
+
+	domain string
+	sign   *sm9.SignMasterPrivateKey
+	enc    *sm9.EncryptMasterPrivateKey
+	now    func() time.Time
+}
+
+// New creates a KGC with fresh master keys, issuing keys for identities
+// within domain ("" for any identity).
+func New(rand io.Reader, domain string) (*KGC, error) {
+	if domain != "" {
+		if err := checkName(domain); err != nil {
+			return nil, err
+		}
+	}
+	sign, err := sm9.GenerateSignMasterKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := sm9.GenerateEncryptMasterKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &KGC{domain: domain, sign: sign, enc: enc, now: time.Now}, nil
+}
+
+// Domain returns the subtree of the identity namespace the KGC issues keys
+// for.
+func (k *KGC) Domain() string {
+	return k.domain
+}
+
+// Sub returns a KGC sharing the master keys of k that only issues keys within
+// domain, which must lie within the domain of k.
+func (k *KGC) Sub(domain string) (*KGC, error) {
+	if err := checkName(domain); err != nil {
+		return nil, err
+	}
+	if !Within(domain, k.domain) {
+		return nil, ErrOutsideDomain
+	}
+	sub := *k
+	sub.domain = domain
+	return &sub, nil
+}
+
+// SignMasterPublicKey returns the master public key for signatures.
+func (k *KGC) SignMasterPublicKey() *sm9.SignMasterPublicKey {
+	return k.sign.Public()
+}
+
+// EncryptMasterPublicKey returns the master public key for encryption.
+func (k *KGC) EncryptMasterPublicKey() *sm9.EncryptMasterPublicKey {
+	return k.enc.Public()
+}
+
+// Issue issues the signing and decryption keys of id.
+func (k *KGC) Issue(id Identity) (*UserKey, error) {
+	if err := checkName(id.Name); err != nil {
+		return nil, err
+	}
+	if !Within(id.Name, k.domain) {
+		return nil, ErrOutsideDomain
+	}
+
+	bounded := !id.NotBefore.IsZero() || !id.NotAfter.IsZero()
+	if bounded {
+		if !id.NotAfter.After(id.NotBefore) || id.NotAfter.Before(k.now()) {
+			return nil, ErrValidity
+		}
+	}
+	if k.MaxValidity > 0 && (!bounded || id.NotAfter.Sub(id.NotBefore) > k.MaxValidity) {
+		return nil, ErrValidity
+	}
+	// The identity string only has a resolution of one second.
+	id.NotBefore = id.NotBefore.UTC().Truncate(time.Second)
+	id.NotAfter = id.NotAfter.UTC().Truncate(time.Second)
+
+	uid := id.Bytes()
+	sign, err := k.sign.GenerateUserKey(uid, sm9.DefaultSignHID)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := k.enc.GenerateUserKey(uid, sm9.DefaultEncryptHID)
+	if err != nil {
+		return nil, err
+	}
+	return &UserKey{Identity: id, Sign: sign, Encrypt: enc}, nil
+}
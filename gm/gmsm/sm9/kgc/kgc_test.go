@@ -0,0 +1,180 @@
+package kgc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm9"
+)
+
+func init() {
+	// Keep the tests fast.
+	scryptN = 1 << 10
+}
+
+func TestIssue(t *testing.T) {
+	k, err := New(rand.Reader, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	id := Identity{Name: "example.com/sales/alice", NotBefore: now, NotAfter: now.Add(24 * time.Hour)}
+	key, err := k.Issue(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uid := key.Identity.Bytes()
+	msg := []byte("hello")
+	sig, err := key.Sign.Sign(rand.Reader, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.SignMasterPublicKey().Verify(uid, sm9.DefaultSignHID, msg, sig) {
+		t.Fatal("signature does not verify")
+	}
+	if k.SignMasterPublicKey().Verify([]byte(id.Name), sm9.DefaultSignHID, msg, sig) {
+		t.Fatal("signature verifies without the validity period")
+	}
+
+	ct, err := sm9.Encrypt(rand.Reader, k.EncryptMasterPublicKey(), uid, sm9.DefaultEncryptHID, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := sm9.Decrypt(key.Encrypt, uid, ct)
+	if err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("Decrypt = %q, %v", pt, err)
+	}
+
+	if !key.Identity.Valid(now.Add(time.Hour)) || key.Identity.Valid(now.Add(48*time.Hour)) {
+		t.Fatal("Valid does not follow the validity period")
+	}
+}
+
+func TestIssuePolicy(t *testing.T) {
+	k, err := New(rand.Reader, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.MaxValidity = 30 * 24 * time.Hour
+	now := time.Now()
+
+	tests := []struct {
+		id  Identity
+		err error
+	}{
+		{Identity{Name: "example.org/bob", NotBefore: now, NotAfter: now.Add(time.Hour)}, ErrOutsideDomain},
+		{Identity{Name: "example.community/bob", NotBefore: now, NotAfter: now.Add(time.Hour)}, ErrOutsideDomain},
+		{Identity{Name: "example.com//bob", NotBefore: now, NotAfter: now.Add(time.Hour)}, ErrIdentity},
+		{Identity{Name: "example.com/b|ob", NotBefore: now, NotAfter: now.Add(time.Hour)}, ErrIdentity},
+		{Identity{Name: "example.com/bob"}, ErrValidity},
+		{Identity{Name: "example.com/bob", NotBefore: now, NotAfter: now.Add(365 * 24 * time.Hour)}, ErrValidity},
+		{Identity{Name: "example.com/bob", NotBefore: now.Add(-2 * time.Hour), NotAfter: now.Add(-time.Hour)}, ErrValidity},
+		{Identity{Name: "example.com/bob", NotBefore: now, NotAfter: now}, ErrValidity},
+	}
+	for i, test := range tests {
+		if _, err := k.Issue(test.id); err != test.err {
+			t.Errorf("#%d: got error %v, want %v", i, err, test.err)
+		}
+	}
+
+	sub, err := k.Sub("example.com/sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Issue(Identity{Name: "example.com/hr/carol", NotBefore: now, NotAfter: now.Add(time.Hour)}); err != ErrOutsideDomain {
+		t.Fatalf("sub-KGC issued outside its subtree: %v", err)
+	}
+	if _, err := sub.Sub("example.com/hr"); err != ErrOutsideDomain {
+		t.Fatalf("sub-KGC delegated outside its subtree: %v", err)
+	}
+	if _, err := sub.Issue(Identity{Name: "example.com/sales/carol", NotBefore: now, NotAfter: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIdentityBytes(t *testing.T) {
+	id := Identity{
+		Name:      "example.com/alice",
+		NotBefore: time.Date(2026, 1, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+		NotAfter:  time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if got, want := string(id.Bytes()), "example.com/alice|20260101000000Z-20270101000000Z"; got != want {
+		t.Fatalf("Bytes() = %q, want %q", got, want)
+	}
+	if got := string((Identity{Name: "alice"}).Bytes()); got != "alice" {
+		t.Fatalf("Bytes() = %q, want %q", got, "alice")
+	}
+	if Parent("example.com/sales/alice") != "example.com/sales" || Parent("alice") != "" {
+		t.Fatal("Parent")
+	}
+}
+
+func TestExport(t *testing.T) {
+	k, err := New(rand.Reader, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.MaxValidity = time.Hour
+	password := []byte("correct horse battery staple")
+
+	data, err := k.Export(rand.Reader, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(data, []byte("wrong")); err != ErrPassword {
+		t.Fatalf("Import with wrong password: %v", err)
+	}
+	k2, err := Import(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k2.Domain() != k.Domain() || k2.MaxValidity != k.MaxValidity {
+		t.Fatal("policy not preserved")
+	}
+
+	now := time.Now()
+	id := Identity{Name: "example.com/alice", NotBefore: now, NotAfter: now.Add(time.Hour)}
+	key, err := k.Issue(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := k2.Issue(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key.Sign.PrivateKey.Marshal(), key2.Sign.PrivateKey.Marshal()) {
+		t.Fatal("imported KGC issues different keys")
+	}
+
+	data, err = key.Export(rand.Reader, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(data, password); err == nil {
+		t.Fatal("user key imported as master key")
+	}
+	key3, err := ImportUserKey(data, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key3.Identity.Bytes(), key.Identity.Bytes()) ||
+		!bytes.Equal(key3.Encrypt.PrivateKey.Marshal(), key.Encrypt.PrivateKey.Marshal()) {
+		t.Fatal("user key not preserved")
+	}
+
+	params, err := k.ExportPublicParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spub, epub, err := ParsePublicParams(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(spub.MasterPublicKey.Marshal(), k.SignMasterPublicKey().MasterPublicKey.Marshal()) ||
+		!bytes.Equal(epub.MasterPublicKey.Marshal(), k.EncryptMasterPublicKey().MasterPublicKey.Marshal()) {
+		t.Fatal("public parameters not preserved")
+	}
+}
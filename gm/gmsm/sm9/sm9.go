@@ -0,0 +1,373 @@
+// Package sm9 implements the SM9 identity-based cryptographic algorithms of
+// GM/T 0044: digital signatures and public key encryption.
+//
+// A key generation center (KGC) holds a master private key and publishes the
+// matching master public key. User private keys are derived from the master
+// private key and the user's identity; anyone can verify signatures or
+// encrypt to an identity knowing only the master public key.
+package sm9
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3kdf"
+	"github.com/xuperchain/crypto/gm/gmsm/sm9/bn256"
+)
+
+// Default identity hash identifiers (hid) of GM/T 0044.
+const (
+	DefaultSignHID     byte = 0x01
+	DefaultEncryptHID  byte = 0x03
+	DefaultExchangeHID byte = 0x02
+)
+
+var (
+	one    = big.NewInt(1)
+	order  = bn256.Order
+	orderM = new(big.Int).Sub(bn256.Order, one)
+)
+
+var (
+	// ErrMasterKey is returned when a user key cannot be derived from the
+	// master key for the given identity; the KGC must then generate a new
+	// master key.
+	ErrMasterKey = errors.New("sm9: master key cannot be used for this identity")
+	// ErrDecryption is returned when a ciphertext cannot be decrypted.
+	ErrDecryption = errors.New("sm9: decryption error")
+)
+
+// SignMasterPublicKey is the public half of a signature master key, Ppub-s.
+type SignMasterPublicKey struct {
+	MasterPublicKey *bn256.G2
+}
+
+// SignMasterPrivateKey is a signature master key held by the KGC.
+type SignMasterPrivateKey struct {
+	SignMasterPublicKey
+	D *big.Int
+}
+
+// SignPrivateKey is a user's signing key, ds, together with the master
+// public key it was issued under.
+type SignPrivateKey struct {
+	PrivateKey *bn256.G1
+	SignMasterPublicKey
+}
+
+// EncryptMasterPublicKey is the public half of an encryption master key,
+// Ppub-e.
+type EncryptMasterPublicKey struct {
+	MasterPublicKey *bn256.G1
+}
+
+// EncryptMasterPrivateKey is an encryption master key held by the KGC.
+type EncryptMasterPrivateKey struct {
+	EncryptMasterPublicKey
+	D *big.Int
+}
+
+// EncryptPrivateKey is a user's decryption key, de, together with the master
+// public key it was issued under.
+type EncryptPrivateKey struct {
+	PrivateKey *bn256.G2
+	EncryptMasterPublicKey
+}
+
+// hash implements the functions H1 (prefix 0x01) and H2 (prefix 0x02) of
+// GM/T 0044, mapping z to an integer in [1, n-1].
+func hash(prefix byte, z ...[]byte) *big.Int {
+	// hlen = 8·⌈5·log2(n)/32⌉ = 320 bits
+	const hlen = 40
+
+	var ha []byte
+	var ct [4]byte
+	for i := uint32(1); len(ha) < hlen; i++ {
+		h := sm3.New()
+		h.Write([]byte{prefix})
+		for _, b := range z {
+			h.Write(b)
+		}
+		binary.BigEndian.PutUint32(ct[:], i)
+		h.Write(ct[:])
+		ha = h.Sum(ha)
+	}
+
+	k := new(big.Int).SetBytes(ha[:hlen])
+	k.Mod(k, orderM)
+	return k.Add(k, one)
+}
+
+func randFieldElement(rand io.Reader) (*big.Int, error) {
+	b := make([]byte, order.BitLen()/8+8)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	k := new(big.Int).SetBytes(b)
+	k.Mod(k, orderM)
+	return k.Add(k, one), nil
+}
+
+// userScalar returns ks·(H1(uid||hid)+ks)⁻¹ mod n.
+func userScalar(d *big.Int, uid []byte, hid byte) (*big.Int, error) {
+	t1 := hash(0x01, uid, []byte{hid})
+	t1.Add(t1, d).Mod(t1, order)
+	if t1.Sign() == 0 {
+		return nil, ErrMasterKey
+	}
+	t1.ModInverse(t1, order)
+	return t1.Mul(t1, d).Mod(t1, order), nil
+}
+
+// GenerateSignMasterKey generates a new signature master key.
+func GenerateSignMasterKey(rand io.Reader) (*SignMasterPrivateKey, error) {
+	d, err := randFieldElement(rand)
+	if err != nil {
+		return nil, err
+	}
+	return NewSignMasterPrivateKey(d)
+}
+
+// NewSignMasterPrivateKey returns the signature master key with secret d.
+func NewSignMasterPrivateKey(d *big.Int) (*SignMasterPrivateKey, error) {
+	if d.Sign() <= 0 || d.Cmp(order) >= 0 {
+		return nil, errors.New("sm9: master private key out of range")
+	}
+	priv := new(SignMasterPrivateKey)
+	priv.D = new(big.Int).Set(d)
+	priv.MasterPublicKey = new(bn256.G2).ScalarBaseMult(d)
+	return priv, nil
+}
+
+// Public returns the master public key.
+func (master *SignMasterPrivateKey) Public() *SignMasterPublicKey {
+	return &master.SignMasterPublicKey
+}
+
+// GenerateUserKey derives the signing key of the identity uid.
+func (master *SignMasterPrivateKey) GenerateUserKey(uid []byte, hid byte) (*SignPrivateKey, error) {
+	t2, err := userScalar(master.D, uid, hid)
+	if err != nil {
+		return nil, err
+	}
+	priv := new(SignPrivateKey)
+	priv.PrivateKey = new(bn256.G1).ScalarBaseMult(t2)
+	priv.SignMasterPublicKey = master.SignMasterPublicKey
+	return priv, nil
+}
+
+// GenerateEncryptMasterKey generates a new encryption master key.
+func GenerateEncryptMasterKey(rand io.Reader) (*EncryptMasterPrivateKey, error) {
+	d, err := randFieldElement(rand)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptMasterPrivateKey(d)
+}
+
+// NewEncryptMasterPrivateKey returns the encryption master key with secret d.
+func NewEncryptMasterPrivateKey(d *big.Int) (*EncryptMasterPrivateKey, error) {
+	if d.Sign() <= 0 || d.Cmp(order) >= 0 {
+		return nil, errors.New("sm9: master private key out of range")
+	}
+	priv := new(EncryptMasterPrivateKey)
+	priv.D = new(big.Int).Set(d)
+	priv.MasterPublicKey = new(bn256.G1).ScalarBaseMult(d)
+	return priv, nil
+}
+
+// Public returns the master public key.
+func (master *EncryptMasterPrivateKey) Public() *EncryptMasterPublicKey {
+	return &master.EncryptMasterPublicKey
+}
+
+// GenerateUserKey derives the decryption key of the identity uid.
+func (master *EncryptMasterPrivateKey) GenerateUserKey(uid []byte, hid byte) (*EncryptPrivateKey, error) {
+	t2, err := userScalar(master.D, uid, hid)
+	if err != nil {
+		return nil, err
+	}
+	priv := new(EncryptPrivateKey)
+	priv.PrivateKey = new(bn256.G2).ScalarBaseMult(t2)
+	priv.EncryptMasterPublicKey = master.EncryptMasterPublicKey
+	return priv, nil
+}
+
+var (
+	g1Gen = new(bn256.G1).ScalarBaseMult(one)
+	g2Gen = new(bn256.G2).ScalarBaseMult(one)
+)
+
+// Sign signs msg with priv, returning the signature (h, S).
+func Sign(rand io.Reader, priv *SignPrivateKey, msg []byte) (h *big.Int, s *bn256.G1, err error) {
+	g := bn256.Pair(g1Gen, priv.MasterPublicKey)
+	for {
+		r, err := randFieldElement(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		if h, s = sign(g, priv, msg, r); s != nil {
+			return h, s, nil
+		}
+	}
+}
+
+// sign computes the signature of msg for the ephemeral scalar r. It returns
+// a nil S if r must be discarded.
+func sign(g *bn256.GT, priv *SignPrivateKey, msg []byte, r *big.Int) (*big.Int, *bn256.G1) {
+	w := new(bn256.GT).ScalarMult(g, r)
+	h := hash(0x02, msg, w.Marshal())
+
+	l := new(big.Int).Sub(r, h)
+	l.Mod(l, order)
+	if l.Sign() == 0 {
+		return nil, nil
+	}
+	return h, new(bn256.G1).ScalarMult(priv.PrivateKey, l)
+}
+
+// Verify reports whether (h, s) is a valid signature of msg by the identity
+// uid under the master public key pub.
+func Verify(pub *SignMasterPublicKey, uid []byte, hid byte, msg []byte, h *big.Int, s *bn256.G1) bool {
+	if h.Sign() <= 0 || h.Cmp(order) >= 0 {
+		return false
+	}
+
+	g := bn256.Pair(g1Gen, pub.MasterPublicKey)
+	t := new(bn256.GT).ScalarMult(g, h)
+
+	h1 := hash(0x01, uid, []byte{hid})
+	p := new(bn256.G2).ScalarBaseMult(h1)
+	p.Add(p, pub.MasterPublicKey)
+
+	w := bn256.Pair(s, p)
+	w.Add(w, t)
+	return hash(0x02, msg, w.Marshal()).Cmp(h) == 0
+}
+
+// Sign signs msg with priv and returns the DER encoded signature.
+func (priv *SignPrivateKey) Sign(rand io.Reader, msg []byte) ([]byte, error) {
+	h, s, err := Sign(rand, priv, msg)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalSignature(h, s)
+}
+
+// Verify reports whether sig is a valid DER encoded signature of msg by the
+// identity uid.
+func (pub *SignMasterPublicKey) Verify(uid []byte, hid byte, msg, sig []byte) bool {
+	h, s, err := ParseSignature(sig)
+	if err != nil {
+		return false
+	}
+	return Verify(pub, uid, hid, msg, h, s)
+}
+
+// kdfKeys derives the keystream K1 of length n and the MAC key K2 from
+// C1 || w || uid.
+func kdfKeys(c1, w, uid []byte, n int) (k1, k2 []byte) {
+	z := make([]byte, 0, len(c1)+len(w)+len(uid))
+	z = append(append(append(z, c1...), w...), uid...)
+	k := make([]byte, n+sm3.Size)
+	sm3kdf.NewReader(z).Read(k)
+	return k[:n], k[n:]
+}
+
+func isZero(b []byte) bool {
+	var acc byte
+	for _, v := range b {
+		acc |= v
+	}
+	return acc == 0
+}
+
+func mac(k2, c2 []byte) []byte {
+	h := sm3.New()
+	h.Write(c2)
+	h.Write(k2)
+	return h.Sum(nil)
+}
+
+// encrypt returns the components C1, C3 and C2 of the encryption of msg to
+// the identity uid.
+func encrypt(rand io.Reader, pub *EncryptMasterPublicKey, uid []byte, hid byte, msg []byte) (c1 *bn256.G1, c3, c2 []byte, err error) {
+	q := new(bn256.G1).ScalarBaseMult(hash(0x01, uid, []byte{hid}))
+	q.Add(q, pub.MasterPublicKey)
+	g := bn256.Pair(pub.MasterPublicKey, g2Gen)
+
+	for {
+		r, err := randFieldElement(rand)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if c1, c3, c2 = encryptWithR(q, g, uid, msg, r); c1 != nil {
+			return c1, c3, c2, nil
+		}
+	}
+}
+
+// encryptWithR encrypts msg for the ephemeral scalar r. It returns a nil C1
+// if r must be discarded.
+func encryptWithR(q *bn256.G1, g *bn256.GT, uid, msg []byte, r *big.Int) (c1 *bn256.G1, c3, c2 []byte) {
+	c1 = new(bn256.G1).ScalarMult(q, r)
+	w := new(bn256.GT).ScalarMult(g, r)
+
+	k1, k2 := kdfKeys(c1.Marshal(), w.Marshal(), uid, len(msg))
+	if len(msg) > 0 && isZero(k1) {
+		return nil, nil, nil
+	}
+
+	c2 = make([]byte, len(msg))
+	for i := range msg {
+		c2[i] = msg[i] ^ k1[i]
+	}
+	return c1, mac(k2, c2), c2
+}
+
+func decrypt(priv *EncryptPrivateKey, uid []byte, c1 *bn256.G1, c3, c2 []byte) ([]byte, error) {
+	w := bn256.Pair(c1, priv.PrivateKey)
+	k1, k2 := kdfKeys(c1.Marshal(), w.Marshal(), uid, len(c2))
+	if len(c2) > 0 && isZero(k1) {
+		return nil, ErrDecryption
+	}
+	if subtle.ConstantTimeCompare(mac(k2, c2), c3) != 1 {
+		return nil, ErrDecryption
+	}
+
+	msg := make([]byte, len(c2))
+	for i := range c2 {
+		msg[i] = c2[i] ^ k1[i]
+	}
+	return msg, nil
+}
+
+// Encrypt encrypts msg to the identity uid, using the stream cipher mode of
+// GM/T 0044 part 4. The result is C1 || C3 || C2, where C1 is the 64-byte
+// encoding of the ephemeral point and C3 is the 32-byte SM3 MAC.
+func Encrypt(rand io.Reader, pub *EncryptMasterPublicKey, uid []byte, hid byte, msg []byte) ([]byte, error) {
+	c1, c3, c2, err := encrypt(rand, pub, uid, hid, msg)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 64+len(c3)+len(c2))
+	out = append(out, c1.Marshal()...)
+	out = append(out, c3...)
+	return append(out, c2...), nil
+}
+
+// Decrypt decrypts a ciphertext produced by Encrypt for the identity uid.
+func Decrypt(priv *EncryptPrivateKey, uid, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 64+sm3.Size || isZero(ciphertext[:64]) {
+		return nil, ErrDecryption
+	}
+	c1 := new(bn256.G1)
+	if _, err := c1.Unmarshal(ciphertext[:64]); err != nil {
+		return nil, ErrDecryption
+	}
+	return decrypt(priv, uid, c1, ciphertext[64:64+sm3.Size], ciphertext[64+sm3.Size:])
+}
@@ -0,0 +1,269 @@
+package sm9
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm9/bn256"
+)
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func bigFromHex(s string) *big.Int {
+	return new(big.Int).SetBytes(decodeHex(s))
+}
+
+// Values from the examples of GM/T 0044 part 5.
+var (
+	exampleKs = bigFromHex("0130E78459D78545CB54C587E02CF480CE0B66340F319F348A1D5B1F2DC5F4")
+	exampleKe = bigFromHex("01EDEE3778F441F8DEA3D9FA0ACC4E07EE36C93F9A08618AF4AD85CEDE1C22")
+)
+
+func TestSignUserKeyVector(t *testing.T) {
+	master, err := NewSignMasterPrivateKey(exampleKs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := master.GenerateUserKey([]byte("Alice"), DefaultSignHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decodeHex("A5702F05CF1315305E2D6EB64B0DEB923DB1A0BCF0CAFF90523AC8754AA69820" +
+		"78559A844411F9825C109F5EE3F52D720DD01785392A727BB1556952B2B013D3")
+	if got := priv.PrivateKey.Marshal(); !bytes.Equal(got, want) {
+		t.Fatalf("dsA = %x, want %x", got, want)
+	}
+}
+
+func TestEncryptVector(t *testing.T) {
+	master, err := NewEncryptMasterPrivateKey(exampleKe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := []byte("Bob")
+	priv, err := master.GenerateUserKey(uid, DefaultEncryptHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := new(bn256.G1).ScalarBaseMult(hash(0x01, uid, []byte{DefaultEncryptHID}))
+	q.Add(q, master.MasterPublicKey)
+	g := bn256.Pair(master.MasterPublicKey, g2Gen)
+	r := bigFromHex("AAC0541779C8FC45E3E2CB25C12B5D2576B2129AE8BB5EE2CBE5EC9E785C")
+	msg := []byte("Chinese IBE standard")
+
+	c1, c3, c2 := encryptWithR(q, g, uid, msg, r)
+	got := append(append(c1.Marshal(), c3...), c2...)
+	want := decodeHex("2445471164490618E1EE20528FF1D545B0F14C8BCAA44544F03DAB5DAC07D8FF" +
+		"42FFCA97D57CDDC05EA405F2E586FEB3A6930715532B8000759F13059ED59AC0" +
+		"BA672387BCD6DE5016A158A52BB2E7FC429197BCAB70B25AFEE37A2B9DB9F367" +
+		"1B5F5B0E951489682F3E64E1378CDD5DA9513B1C")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("C = %x, want %x", got, want)
+	}
+
+	pt, err := Decrypt(priv, uid, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pt, msg) {
+		t.Fatalf("decrypted %q, want %q", pt, msg)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	master, err := GenerateSignMasterKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := []byte("alice@example.com")
+	priv, err := master.GenerateUserKey(uid, DefaultSignHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("test message")
+
+	sig, err := priv.Sign(rand.Reader, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := master.Public()
+	if !pub.Verify(uid, DefaultSignHID, msg, sig) {
+		t.Fatal("signature does not verify")
+	}
+	if pub.Verify([]byte("bob@example.com"), DefaultSignHID, msg, sig) {
+		t.Fatal("signature verifies for another identity")
+	}
+	if pub.Verify(uid, DefaultSignHID, []byte("other message"), sig) {
+		t.Fatal("signature verifies for another message")
+	}
+	sig[len(sig)-1] ^= 1
+	if pub.Verify(uid, DefaultSignHID, msg, sig) {
+		t.Fatal("tampered signature verifies")
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	master, err := GenerateEncryptMasterKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := []byte("bob@example.com")
+	priv, err := master.GenerateUserKey(uid, DefaultEncryptHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("attack at dawn")
+
+	ct, err := Encrypt(rand.Reader, master.Public(), uid, DefaultEncryptHID, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := Decrypt(priv, uid, ct)
+	if err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("Decrypt = %q, %v", pt, err)
+	}
+	if _, err := Decrypt(priv, []byte("eve@example.com"), ct); err == nil {
+		t.Fatal("decryption with a different identity succeeded")
+	}
+	ct[len(ct)-1] ^= 1
+	if _, err := Decrypt(priv, uid, ct); err != ErrDecryption {
+		t.Fatalf("tampered ciphertext: err = %v", err)
+	}
+
+	der, err := EncryptASN1(rand.Reader, master.Public(), uid, DefaultEncryptHID, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err = DecryptASN1(priv, uid, der)
+	if err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("DecryptASN1 = %q, %v", pt, err)
+	}
+
+	raw, err := ASN1ToCiphertext(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err = Decrypt(priv, uid, raw); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("Decrypt(ASN1ToCiphertext) = %q, %v", pt, err)
+	}
+	der2, err := CiphertextToASN1(raw)
+	if err != nil || !bytes.Equal(der, der2) {
+		t.Fatal("CiphertextToASN1 does not invert ASN1ToCiphertext")
+	}
+}
+
+func TestKeyASN1(t *testing.T) {
+	sm, err := NewSignMasterPrivateKey(exampleKs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := sm.MarshalASN1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm2, err := ParseSignMasterPrivateKey(der)
+	if err != nil || sm2.D.Cmp(sm.D) != 0 {
+		t.Fatalf("ParseSignMasterPrivateKey: %v", err)
+	}
+
+	der, err = sm.Public().MarshalASN1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The BIT STRING carries 04 || Ppub-s.
+	if want := append([]byte{4}, sm.MasterPublicKey.Marshal()[1:]...); !bytes.Contains(der, want) {
+		t.Fatal("master public key is not encoded as an uncompressed point")
+	}
+	spub, err := ParseSignMasterPublicKey(der)
+	if err != nil || !bytes.Equal(spub.MasterPublicKey.Marshal(), sm.MasterPublicKey.Marshal()) {
+		t.Fatalf("ParseSignMasterPublicKey: %v", err)
+	}
+
+	spriv, err := sm.GenerateUserKey([]byte("Alice"), DefaultSignHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = spriv.MarshalASN1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spriv2, err := ParseSignPrivateKey(der)
+	if err != nil || !bytes.Equal(spriv2.PrivateKey.Marshal(), spriv.PrivateKey.Marshal()) {
+		t.Fatalf("ParseSignPrivateKey: %v", err)
+	}
+
+	em, err := NewEncryptMasterPrivateKey(exampleKe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = em.MarshalASN1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	em2, err := ParseEncryptMasterPrivateKey(der)
+	if err != nil || em2.D.Cmp(em.D) != 0 {
+		t.Fatalf("ParseEncryptMasterPrivateKey: %v", err)
+	}
+
+	der, err = em.Public().MarshalASN1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epub, err := ParseEncryptMasterPublicKey(der)
+	if err != nil || !bytes.Equal(epub.MasterPublicKey.Marshal(), em.MasterPublicKey.Marshal()) {
+		t.Fatalf("ParseEncryptMasterPublicKey: %v", err)
+	}
+
+	epriv, err := em.GenerateUserKey([]byte("Bob"), DefaultEncryptHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = epriv.MarshalASN1()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epriv2, err := ParseEncryptPrivateKey(der)
+	if err != nil || !bytes.Equal(epriv2.PrivateKey.Marshal(), epriv.PrivateKey.Marshal()) {
+		t.Fatalf("ParseEncryptPrivateKey: %v", err)
+	}
+
+	// A signature master key must not parse as an encryption master key.
+	der, _ = sm.MarshalASN1()
+	if _, err := ParseEncryptMasterPrivateKey(der); err == nil {
+		t.Fatal("parsed a signature master key as an encryption master key")
+	}
+	if _, err := ParseSignPrivateKey(append(der, 0)); err == nil {
+		t.Fatal("accepted trailing data")
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	master, _ := NewSignMasterPrivateKey(exampleKs)
+	priv, _ := master.GenerateUserKey([]byte("Alice"), DefaultSignHID)
+	msg := []byte("Chinese IBS")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		priv.Sign(rand.Reader, msg)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	master, _ := NewSignMasterPrivateKey(exampleKs)
+	priv, _ := master.GenerateUserKey([]byte("Alice"), DefaultSignHID)
+	msg := []byte("Chinese IBS")
+	sig, _ := priv.Sign(rand.Reader, msg)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		master.Public().Verify([]byte("Alice"), DefaultSignHID, msg, sig)
+	}
+}
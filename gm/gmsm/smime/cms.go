@@ -0,0 +1,438 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/compat/openssl"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	// The content types of GM/T 0010, accepted when reading.
+	oidGMData          = asn1.ObjectIdentifier{1, 2, 156, 10197, 6, 1, 4, 2, 1}
+	oidGMSignedData    = asn1.ObjectIdentifier{1, 2, 156, 10197, 6, 1, 4, 2, 2}
+	oidGMEnvelopedData = asn1.ObjectIdentifier{1, 2, 156, 10197, 6, 1, 4, 2, 3}
+
+	oidAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSM3 = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401}
+	// oidSM3Alt is the SM3 OID of the sm2 package, accepted when reading.
+	oidSM3Alt     = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 401, 1}
+	oidSM2Sign    = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301, 1}
+	oidSM2WithSM3 = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 501}
+	oidSM2Encrypt = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301, 3}
+	oidSM4CBC     = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 104, 2}
+)
+
+const contentKeySize = sm4.KeySize
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type keyTransRecipientInfo struct {
+	Version                int
+	RID                    issuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"optional,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	OriginatorInfo       asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+	UnprotectedAttrs     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+func isData(oid asn1.ObjectIdentifier) bool {
+	return oid.Equal(oidData) || oid.Equal(oidGMData)
+}
+
+func isSM3(alg pkix.AlgorithmIdentifier) bool {
+	return alg.Algorithm.Equal(oidSM3) || alg.Algorithm.Equal(oidSM3Alt)
+}
+
+// sm2PublicKey returns the SM2 key of a certificate, which sm2 parses as
+// *ecdsa.PublicKey on the SM2 curve.
+func sm2PublicKey(pub interface{}) (*sm2.PublicKey, bool) {
+	switch pub := pub.(type) {
+	case *sm2.PublicKey:
+		return pub, true
+	case *ecdsa.PublicKey:
+		if pub.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}, true
+		}
+	}
+	return nil, false
+}
+
+func certID(cert *sm2.Certificate) issuerAndSerial {
+	return issuerAndSerial{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, Serial: cert.SerialNumber}
+}
+
+func (id issuerAndSerial) matches(cert *sm2.Certificate) bool {
+	return bytes.Equal(id.Issuer.FullBytes, cert.RawIssuer) && id.Serial.Cmp(cert.SerialNumber) == 0
+}
+
+// explicit wraps the DER der in a [0] EXPLICIT tag.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func marshalContentInfo(contentType asn1.ObjectIdentifier, content interface{}) ([]byte, error) {
+	der, err := asn1.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{ContentType: contentType, Content: explicit(der)})
+}
+
+// parseContentInfo returns the content of a ContentInfo of one of the given
+// types. Only DER is read.
+func parseContentInfo(der []byte, types ...asn1.ObjectIdentifier) ([]byte, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	for _, t := range types {
+		if ci.ContentType.Equal(t) {
+			return ci.Content.Bytes, nil
+		}
+	}
+	return nil, ErrUnsupported
+}
+
+// signedAttributes returns the DER SET OF the attributes of a signature
+// over content, sorted as DER requires.
+func signedAttributes(content []byte, now time.Time) ([]byte, error) {
+	digest := sm3.Sm3Sum(content)
+	values := []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidAttributeContentType, oidData},
+		{oidAttributeMessageDigest, digest},
+		{oidAttributeSigningTime, now.UTC()},
+	}
+	var encoded [][]byte
+	for _, v := range values {
+		der, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := asn1.Marshal(attribute{Type: v.oid, Values: []asn1.RawValue{{FullBytes: der}}})
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, attr)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encoded, nil)})
+}
+
+// signDetached returns a SignedData ContentInfo with one SM2 signature of
+// content by cert and priv, without the content. The certificates carried
+// are cert and chain.
+func signDetached(content []byte, cert *sm2.Certificate, priv *sm2.PrivateKey, chain []*sm2.Certificate, now time.Time) ([]byte, error) {
+	if pub, ok := sm2PublicKey(cert.PublicKey); !ok || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+		return nil, ErrCertificate
+	}
+	attrs, err := signedAttributes(content, now)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sm2.SignEx(priv, attrs)
+	if err != nil {
+		return nil, err
+	}
+	var certs []byte
+	for _, c := range append([]*sm2.Certificate{cert}, chain...) {
+		certs = append(certs, c.Raw...)
+	}
+	// The signed attributes are carried under an IMPLICIT [0] tag in place
+	// of the SET tag that was signed.
+	attrs[0] = 0xa0
+	sm3ID := pkix.AlgorithmIdentifier{Algorithm: oidSM3}
+	return marshalContentInfo(oidSignedData, signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sm3ID},
+		EncapContentInfo: encapContentInfo{EContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                certID(cert),
+			DigestAlgorithm:    sm3ID,
+			SignedAttrs:        asn1.RawValue{FullBytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSM2Sign},
+			Signature:          sig,
+		}},
+	})
+}
+
+// signed is a parsed SignedData.
+type signed struct {
+	content []byte // nil when detached
+	certs   []*sm2.Certificate
+	signer  signerInfo
+}
+
+// parseSigned parses a SignedData ContentInfo with exactly one signer.
+func parseSigned(der []byte) (*signed, error) {
+	inner, err := parseContentInfo(der, oidSignedData, oidGMSignedData)
+	if err != nil {
+		return nil, err
+	}
+	var sd signedData
+	if rest, err := asn1.Unmarshal(inner, &sd); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	if !isData(sd.EncapContentInfo.EContentType) {
+		return nil, ErrUnsupported
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, ErrUnsupported
+	}
+	s := &signed{signer: sd.SignerInfos[0]}
+	if e := sd.EncapContentInfo.EContent; len(e.Bytes) != 0 {
+		if rest, err := asn1.Unmarshal(e.Bytes, &s.content); err != nil || len(rest) != 0 {
+			return nil, ErrMalformed
+		}
+	}
+	if len(sd.Certificates.Bytes) != 0 {
+		if s.certs, err = sm2.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, ErrMalformed
+		}
+	}
+	return s, nil
+}
+
+// verify checks the signature of s over content and returns the carried
+// certificate that made it. The certificate itself is not verified.
+func (s *signed) verify(content []byte) (*sm2.Certificate, error) {
+	si := s.signer
+	if !isSM3(si.DigestAlgorithm) ||
+		!si.SignatureAlgorithm.Algorithm.Equal(oidSM2Sign) && !si.SignatureAlgorithm.Algorithm.Equal(oidSM2WithSM3) {
+		return nil, ErrUnsupported
+	}
+	var cert *sm2.Certificate
+	for _, c := range s.certs {
+		if si.SID.matches(c) {
+			cert = c
+			break
+		}
+	}
+	if cert == nil {
+		return nil, ErrCertificate
+	}
+	pub, ok := sm2PublicKey(cert.PublicKey)
+	if !ok {
+		return nil, ErrCertificate
+	}
+	// Without signed attributes the signature is over the content itself.
+	signedBytes := content
+	if attrs := si.SignedAttrs.FullBytes; len(attrs) != 0 {
+		if err := checkAttributes(si.SignedAttrs.Bytes, content); err != nil {
+			return nil, err
+		}
+		signedBytes = append([]byte{0x31}, attrs[1:]...)
+	}
+	if !sm2.VerifyEx(pub, signedBytes, si.Signature) {
+		return nil, ErrSignature
+	}
+	return cert, nil
+}
+
+// checkAttributes checks that the signed attributes name data as the
+// content type and carry the SM3 digest of content.
+func checkAttributes(attrs, content []byte) error {
+	var contentType, digest bool
+	for len(attrs) > 0 {
+		var a attribute
+		var err error
+		if attrs, err = asn1.Unmarshal(attrs, &a); err != nil || len(a.Values) != 1 {
+			return ErrMalformed
+		}
+		switch {
+		case a.Type.Equal(oidAttributeContentType):
+			var oid asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &oid); err != nil || contentType || !isData(oid) {
+				return ErrMalformed
+			}
+			contentType = true
+		case a.Type.Equal(oidAttributeMessageDigest):
+			var d []byte
+			if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &d); err != nil || digest {
+				return ErrMalformed
+			}
+			if sum := sm3.Sm3Sum(content); !bytes.Equal(d, sum) {
+				return ErrSignature
+			}
+			digest = true
+		}
+	}
+	if !contentType || !digest {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// envelope returns an EnvelopedData ContentInfo of content encrypted with
+// SM4-CBC under a random key, which is encrypted to the SM2 key of each
+// recipient.
+func envelope(content []byte, recipients []*sm2.Certificate) ([]byte, error) {
+	key := make([]byte, contentKeySize+sm4.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	defer zeroize(key)
+	key, iv := key[:contentKeySize], key[contentKeySize:]
+
+	var infos []asn1.RawValue
+	for _, cert := range recipients {
+		pub, ok := sm2PublicKey(cert.PublicKey)
+		if !ok {
+			return nil, ErrCertificate
+		}
+		// The encrypted key is the DER ciphertext of GM/T 0009.
+		ek, err := openssl.Encrypt(pub, key)
+		if err != nil {
+			return nil, err
+		}
+		der, err := asn1.Marshal(keyTransRecipientInfo{
+			RID:                    certID(cert),
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSM2Encrypt},
+			EncryptedKey:           ek,
+		})
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, asn1.RawValue{FullBytes: der})
+	}
+	ct, err := sm4.CBCEncrypt(key, iv, content)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	return marshalContentInfo(oidEnvelopedData, envelopedData{
+		RecipientInfos: infos,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSM4CBC, Parameters: asn1.RawValue{FullBytes: params}},
+			EncryptedContent:           ct,
+		},
+	})
+}
+
+// openEnvelope decrypts an EnvelopedData ContentInfo with the key of the
+// recipient cert.
+func openEnvelope(der []byte, cert *sm2.Certificate, priv *sm2.PrivateKey) ([]byte, error) {
+	inner, err := parseContentInfo(der, oidEnvelopedData, oidGMEnvelopedData)
+	if err != nil {
+		return nil, err
+	}
+	var ed envelopedData
+	if rest, err := asn1.Unmarshal(inner, &ed); err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	eci := ed.EncryptedContentInfo
+	if !isData(eci.ContentType) || !eci.ContentEncryptionAlgorithm.Algorithm.Equal(oidSM4CBC) {
+		return nil, ErrUnsupported
+	}
+	var iv []byte
+	if rest, err := asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv); err != nil ||
+		len(rest) != 0 || len(iv) != sm4.BlockSize {
+		return nil, ErrMalformed
+	}
+
+	var key []byte
+	for _, raw := range ed.RecipientInfos {
+		// Recipient infos of other kinds have other tags and are skipped.
+		var ri keyTransRecipientInfo
+		if _, err := asn1.Unmarshal(raw.FullBytes, &ri); err != nil || ri.RID.Serial == nil || !ri.RID.matches(cert) {
+			continue
+		}
+		if !ri.KeyEncryptionAlgorithm.Algorithm.Equal(oidSM2Encrypt) {
+			return nil, ErrUnsupported
+		}
+		if key, err = openssl.Decrypt(priv, ri.EncryptedKey); err != nil || len(key) != contentKeySize {
+			return nil, ErrDecryption
+		}
+		break
+	}
+	if key == nil {
+		return nil, ErrNotRecipient
+	}
+	defer zeroize(key)
+	content, err := sm4.CBCDecrypt(key, iv, eci.EncryptedContent)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	return content, nil
+}
+
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,283 @@
+// Package smime signs and encrypts MIME entities as S/MIME messages with
+// SM2, SM3 and SM4: multipart/signed with a detached SM2 signature, and
+// application/pkcs7-mime enveloped data encrypted with SM4-CBC under a key
+// transported with SM2.
+//
+// The CMS structures of RFC 5652 are written with the algorithm identifiers
+// of GM/T 0010: SM3 for digests, sm2sign with the default user ID of
+// sm2.DefaultUID for signatures, sm2encrypt with the DER ciphertext of
+// GM/T 0009 for key transport, and SM4-CBC with the IV as parameter for
+// content. Content types are those of RFC 5652, which mail agents expect;
+// those of GM/T 0010 are also accepted when reading, as is an encapsulated
+// signature (smime-type=signed-data). CMS is read as DER only.
+//
+// Entities are canonicalized to CRLF line endings before signing, and
+// messages before they are read. To sign and encrypt, encrypt the output of
+// Sign; Decrypt then returns the signed message to pass to Verify.
+package smime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+var (
+	// ErrMalformed is returned for messages and CMS structures that cannot
+	// be parsed.
+	ErrMalformed = errors.New("smime: malformed message")
+	// ErrUnsupported is returned for valid messages this package does not
+	// implement, such as other algorithms or several signers.
+	ErrUnsupported = errors.New("smime: unsupported message")
+	// ErrSignature is returned for a signature that does not verify.
+	ErrSignature = errors.New("smime: invalid signature")
+	// ErrCertificate is returned when a certificate does not hold an SM2
+	// key, or the signer certificate is not carried in the signature.
+	ErrCertificate = errors.New("smime: unusable certificate")
+	// ErrNotRecipient is returned by Decrypt when the message is not
+	// encrypted to the given certificate.
+	ErrNotRecipient = errors.New("smime: not a recipient of the message")
+	// ErrDecryption is returned when the content key or content does not
+	// decrypt.
+	ErrDecryption = errors.New("smime: decryption failed")
+)
+
+// canonicalize returns b with every line ending as CRLF.
+func canonicalize(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1)
+}
+
+// appendBase64 appends the base64 of b in lines of 76 characters.
+func appendBase64(out, b []byte) []byte {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 76 {
+		out = append(append(out, s[:76]...), "\r\n"...)
+		s = s[76:]
+	}
+	return append(append(out, s...), "\r\n"...)
+}
+
+// Sign returns entity, a MIME entity with its headers, as a multipart/signed
+// message signed with priv, the key of cert. The signature carries cert and
+// chain, the certificates between cert and the root.
+func Sign(entity []byte, cert *sm2.Certificate, priv *sm2.PrivateKey, chain ...*sm2.Certificate) ([]byte, error) {
+	return sign(entity, cert, priv, chain, time.Now())
+}
+
+func sign(entity []byte, cert *sm2.Certificate, priv *sm2.PrivateKey, chain []*sm2.Certificate, now time.Time) ([]byte, error) {
+	entity = canonicalize(entity)
+	sig, err := signDetached(entity, cert, priv, chain, now)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [16]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+	boundary := "----" + strings.ToUpper(hex.EncodeToString(nonce[:]))
+
+	b := []byte("MIME-Version: 1.0\r\nContent-Type: ")
+	b = append(b, mime.FormatMediaType("multipart/signed", map[string]string{
+		"protocol": "application/pkcs7-signature",
+		"micalg":   "sm3",
+		"boundary": boundary,
+	})...)
+	b = append(b, "\r\n\r\nThis is an S/MIME signed message\r\n\r\n--"+boundary+"\r\n"...)
+	b = append(b, entity...)
+	b = append(b, "\r\n--"+boundary+"\r\n"...)
+	b = append(b, "Content-Type: application/pkcs7-signature; name=smime.p7s\r\n"+
+		"Content-Transfer-Encoding: base64\r\n"+
+		"Content-Disposition: attachment; filename=smime.p7s\r\n\r\n"...)
+	b = appendBase64(b, sig)
+	return append(b, "\r\n--"+boundary+"--\r\n"...), nil
+}
+
+// Encrypt returns entity, a MIME entity with its headers, as an
+// application/pkcs7-mime message encrypted to recipients.
+func Encrypt(entity []byte, recipients ...*sm2.Certificate) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("smime: no recipients")
+	}
+	der, err := envelope(canonicalize(entity), recipients)
+	if err != nil {
+		return nil, err
+	}
+	b := []byte("MIME-Version: 1.0\r\n" +
+		"Content-Type: application/pkcs7-mime; smime-type=enveloped-data; name=smime.p7m\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=smime.p7m\r\n\r\n")
+	return appendBase64(b, der), nil
+}
+
+// entityPart is a parsed MIME entity.
+type entityPart struct {
+	mediaType string
+	params    map[string]string
+	header    mail.Header
+	body      []byte
+}
+
+func parseEntity(b []byte) (*entityPart, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	body, err := ioutil.ReadAll(m.Body)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return &entityPart{mediaType: mediaType, params: params, header: m.Header, body: body}, nil
+}
+
+// der returns the base64-decoded body of a CMS part.
+func (p *entityPart) der() ([]byte, error) {
+	if !strings.EqualFold(p.header.Get("Content-Transfer-Encoding"), "base64") {
+		return nil, ErrUnsupported
+	}
+	der, err := base64.StdEncoding.DecodeString(string(p.body))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return der, nil
+}
+
+// isPKCS7 tells whether mediaType is application/pkcs7-suffix or its
+// legacy x- form.
+func isPKCS7(mediaType, suffix string) bool {
+	return mediaType == "application/pkcs7-"+suffix || mediaType == "application/x-pkcs7-"+suffix
+}
+
+// splitSigned returns the signed entity and the signature part of the body
+// of a multipart/signed message. The CRLF before each delimiter belongs to
+// the delimiter, as RFC 2046 defines it.
+func splitSigned(body []byte, boundary string) (entity, sig []byte, err error) {
+	delim := []byte("\r\n--" + boundary)
+	// The first delimiter may open the body, with no CRLF before it.
+	rest := append([]byte("\r\n"), body...)
+	var parts [][]byte
+	for {
+		i := bytes.Index(rest, delim)
+		if i < 0 {
+			return nil, nil, ErrMalformed
+		}
+		if len(parts) > 0 {
+			parts[len(parts)-1] = rest[:i]
+		}
+		rest = rest[i+len(delim):]
+		if bytes.HasPrefix(rest, []byte("--")) {
+			break
+		}
+		// Only transport padding may follow a delimiter on its line.
+		eol := bytes.Index(rest, []byte("\r\n"))
+		if eol < 0 || len(bytes.Trim(rest[:eol], " \t")) != 0 {
+			return nil, nil, ErrMalformed
+		}
+		rest = rest[eol+2:]
+		parts = append(parts, nil)
+	}
+	if len(parts) != 2 {
+		return nil, nil, ErrMalformed
+	}
+	return parts[0], parts[1], nil
+}
+
+// Verify checks a signed message and returns the entity that was signed and
+// the certificate of the signer. Both multipart/signed and
+// application/pkcs7-mime signed-data messages are accepted.
+//
+// The signer certificate is verified with opts. Certificates carried in the
+// signature are used as intermediates when opts.Intermediates is nil, and
+// email protection is required when opts.KeyUsages is empty.
+func Verify(msg []byte, opts sm2.VerifyOptions) ([]byte, *sm2.Certificate, error) {
+	top, err := parseEntity(canonicalize(msg))
+	if err != nil {
+		return nil, nil, err
+	}
+	var entity, der []byte
+	switch {
+	case top.mediaType == "multipart/signed":
+		if !isPKCS7(top.params["protocol"], "signature") || top.params["boundary"] == "" {
+			return nil, nil, ErrUnsupported
+		}
+		var sigPart []byte
+		if entity, sigPart, err = splitSigned(top.body, top.params["boundary"]); err != nil {
+			return nil, nil, err
+		}
+		p, err := parseEntity(sigPart)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isPKCS7(p.mediaType, "signature") {
+			return nil, nil, ErrUnsupported
+		}
+		if der, err = p.der(); err != nil {
+			return nil, nil, err
+		}
+	case isPKCS7(top.mediaType, "mime") && top.params["smime-type"] == "signed-data":
+		if der, err = top.der(); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, ErrUnsupported
+	}
+
+	s, err := parseSigned(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.content != nil {
+		if entity != nil {
+			return nil, nil, ErrMalformed
+		}
+		entity = s.content
+	}
+	cert, err := s.verify(entity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Intermediates == nil {
+		opts.Intermediates = sm2.NewCertPool()
+		for _, c := range s.certs {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []sm2.ExtKeyUsage{sm2.ExtKeyUsageEmailProtection}
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		return nil, nil, err
+	}
+	return entity, cert, nil
+}
+
+// Decrypt decrypts an application/pkcs7-mime enveloped-data message with
+// priv, the key of cert, and returns the entity it carries.
+func Decrypt(msg []byte, cert *sm2.Certificate, priv *sm2.PrivateKey) ([]byte, error) {
+	p, err := parseEntity(canonicalize(msg))
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.params["smime-type"]; !isPKCS7(p.mediaType, "mime") || ok && t != "enveloped-data" {
+		return nil, ErrUnsupported
+	}
+	der, err := p.der()
+	if err != nil {
+		return nil, err
+	}
+	return openEnvelope(der, cert, priv)
+}
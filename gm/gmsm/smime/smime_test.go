@@ -0,0 +1,185 @@
+package smime
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+type identity struct {
+	cert *sm2.Certificate
+	priv *sm2.PrivateKey
+}
+
+// newIdentity returns an email protection certificate issued by parent, or
+// a self-signed CA when parent is nil.
+func newIdentity(t *testing.T, name string, serial int64, parent *identity) *identity {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &sm2.Certificate{
+		SerialNumber:       big.NewInt(serial),
+		Subject:            pkix.Name{CommonName: name},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		KeyUsage:           sm2.KeyUsageDigitalSignature | sm2.KeyUsageKeyEncipherment,
+		ExtKeyUsage:        []sm2.ExtKeyUsage{sm2.ExtKeyUsageEmailProtection},
+		SignatureAlgorithm: sm2.SM2WithSM3,
+	}
+	issuer, key := template, priv
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage |= sm2.KeyUsageCertSign
+	} else {
+		issuer, key = parent.cert, parent.priv
+	}
+	der, err := sm2.CreateCertificate(rand.Reader, template, issuer, &priv.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := sm2.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &identity{cert, priv}
+}
+
+func verifyOptions(ca *identity) sm2.VerifyOptions {
+	roots := sm2.NewCertPool()
+	roots.AddCert(ca.cert)
+	return sm2.VerifyOptions{Roots: roots}
+}
+
+const entity = "Content-Type: text/plain; charset=utf-8\n\nDelivery note 42\nline two\n"
+
+func TestSignVerify(t *testing.T) {
+	ca := newIdentity(t, "CA", 1, nil)
+	alice := newIdentity(t, "alice", 2, ca)
+	msg, err := Sign([]byte(entity), alice.cert, alice.priv, ca.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(msg, []byte("micalg=sm3")) || !bytes.Contains(msg, []byte("\r\n\r\nDelivery note 42\r\nline two\r\n")) {
+		t.Fatalf("unexpected message\n%s", msg)
+	}
+	got, signer, err := Verify(msg, verifyOptions(ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(entity, "\n", "\r\n", -1); string(got) != want || !signer.Equal(alice.cert) {
+		t.Fatalf("got %q", got)
+	}
+
+	// Mail transports may turn CRLF into LF and add headers.
+	relayed := append([]byte("To: bob@example.com\n"), bytes.Replace(msg, []byte("\r\n"), []byte("\n"), -1)...)
+	if _, _, err := Verify(relayed, verifyOptions(ca)); err != nil {
+		t.Fatalf("relayed: %v", err)
+	}
+
+	tampered := bytes.Replace(msg, []byte("note 42"), []byte("note 43"), 1)
+	if _, _, err := Verify(tampered, verifyOptions(ca)); err != ErrSignature {
+		t.Fatalf("tampered: got %v", err)
+	}
+	other := newIdentity(t, "other CA", 1, nil)
+	if _, _, err := Verify(msg, verifyOptions(other)); err == nil {
+		t.Fatal("verified under an unrelated root")
+	}
+	if _, err := Sign([]byte(entity), alice.cert, ca.priv); err != ErrCertificate {
+		t.Fatalf("signed with a key not of the certificate: %v", err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ca := newIdentity(t, "CA", 1, nil)
+	alice, bob, eve := newIdentity(t, "alice", 2, ca), newIdentity(t, "bob", 3, ca), newIdentity(t, "eve", 4, ca)
+	signed, err := Sign([]byte(entity), alice.cert, alice.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Encrypt(signed, alice.cert, bob.cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(msg, []byte("application/pkcs7-mime; smime-type=enveloped-data")) {
+		t.Fatalf("unexpected message\n%s", msg)
+	}
+	for _, id := range []*identity{alice, bob} {
+		inner, err := Decrypt(msg, id.cert, id.priv)
+		if err != nil || !bytes.Equal(inner, signed) {
+			t.Fatalf("%s: %v", id.cert.Subject.CommonName, err)
+		}
+		if _, _, err := Verify(inner, verifyOptions(ca)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Decrypt(msg, eve.cert, eve.priv); err != ErrNotRecipient {
+		t.Fatalf("stranger: got %v", err)
+	}
+	if _, err := Decrypt(msg, alice.cert, bob.priv); err != ErrDecryption {
+		t.Fatalf("wrong key: got %v", err)
+	}
+	if _, err := Decrypt(signed, alice.cert, alice.priv); err != ErrUnsupported {
+		t.Fatalf("signed message: got %v", err)
+	}
+	if _, err := Encrypt(signed); err == nil {
+		t.Fatal("encrypted to no recipients")
+	}
+}
+
+// TestEncapsulated checks an opaque signed-data message carrying the content
+// and the content types of GM/T 0010, as some GM mail clients write.
+func TestEncapsulated(t *testing.T) {
+	ca := newIdentity(t, "CA", 1, nil)
+	alice := newIdentity(t, "alice", 2, ca)
+	content := []byte("Content-Type: text/plain\r\n\r\nopaque\r\n")
+	der, err := signDetached(content, alice.cert, alice.priv, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ci contentInfo
+	var sd signedData
+	asn1.Unmarshal(der, &ci)
+	asn1.Unmarshal(ci.Content.Bytes, &sd)
+	octets, _ := asn1.Marshal(content)
+	sd.EncapContentInfo = encapContentInfo{EContentType: oidGMData, EContent: explicit(octets)}
+	if der, err = marshalContentInfo(oidGMSignedData, sd); err != nil {
+		t.Fatal(err)
+	}
+	msg := appendBase64([]byte("Content-Type: application/pkcs7-mime; smime-type=signed-data\r\n"+
+		"Content-Transfer-Encoding: base64\r\n\r\n"), der)
+	got, _, err := Verify(msg, verifyOptions(ca))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	ca := newIdentity(t, "CA", 1, nil)
+	alice := newIdentity(t, "alice", 2, ca)
+	msg, err := Sign([]byte(entity), alice.cert, alice.priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(msg)
+	end := strings.LastIndex(s, "\r\n--")
+	for name, bad := range map[string]string{
+		"no header":      "hello",
+		"plain":          "Content-Type: text/plain\r\n\r\nhello",
+		"no closing":     s[:end],
+		"no signature":   s[:strings.Index(s, "Content-Type: application/pkcs7-signature")] + "\r\n",
+		"bad base64":     strings.Replace(s, "filename=smime.p7s\r\n\r\n", "filename=smime.p7s\r\n\r\n!", 1),
+		"wrong protocol": strings.Replace(s, "pkcs7-signature\"", "pgp-signature\"", 1),
+	} {
+		if _, _, err := Verify([]byte(bad), verifyOptions(ca)); err == nil {
+			t.Errorf("%s: verified", name)
+		}
+	}
+}
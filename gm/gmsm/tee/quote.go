@@ -0,0 +1,257 @@
+package tee
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+)
+
+// The layout of an SGX ECDSA quote, version 3, from the Intel SGX ECDSA
+// Quote Library reference.
+const (
+	quoteHeaderSize = 48
+	reportSize      = 384
+	quoteVersion    = 3
+	attKeyECDSAP256 = 2
+	certTypePCKPEM  = 5
+
+	// attributeDebug is the DEBUG bit of the first attribute byte.
+	attributeDebug = 0x02
+)
+
+var (
+	ErrQuoteFormat    = errors.New("tee: malformed SGX quote")
+	ErrQuoteSignature = errors.New("tee: invalid SGX quote signature")
+	ErrPolicy         = errors.New("tee: enclave does not match the policy")
+	ErrKeyBinding     = errors.New("tee: report data does not bind the key")
+)
+
+// Report is the report body of an enclave.
+type Report struct {
+	CPUSVN     [16]byte
+	MiscSelect uint32
+	Attributes [16]byte
+	// MRENCLAVE is the measurement of the enclave code and data.
+	MRENCLAVE [32]byte
+	// MRSIGNER is the hash of the key that signed the enclave.
+	MRSIGNER   [32]byte
+	ISVProdID  uint16
+	ISVSVN     uint16
+	ReportData [64]byte
+}
+
+// Debug reports whether the enclave runs in debug mode, in which its
+// memory, and so its keys, can be read by the host.
+func (r *Report) Debug() bool {
+	return r.Attributes[0]&attributeDebug != 0
+}
+
+func parseReport(b []byte) *Report {
+	r := &Report{
+		MiscSelect: binary.LittleEndian.Uint32(b[16:]),
+		ISVProdID:  binary.LittleEndian.Uint16(b[256:]),
+		ISVSVN:     binary.LittleEndian.Uint16(b[258:]),
+	}
+	copy(r.CPUSVN[:], b[0:16])
+	copy(r.Attributes[:], b[48:64])
+	copy(r.MRENCLAVE[:], b[64:96])
+	copy(r.MRSIGNER[:], b[128:160])
+	copy(r.ReportData[:], b[320:384])
+	return r
+}
+
+// Quote is an SGX ECDSA quote: a report of an enclave signed by an
+// attestation key, which the quoting enclave vouches for with a report
+// signed by the PCK certificate of the platform.
+type Quote struct {
+	Report
+
+	signed       []byte // header and report body
+	signature    []byte
+	attestKey    []byte
+	qeReport     []byte
+	qeReportSig  []byte
+	qeAuthData   []byte
+	pckCertChain []*x509.Certificate
+}
+
+// ParseQuote parses a version 3 quote with an ECDSA P-256 attestation key
+// and a PEM PCK certificate chain, the format of SGX DCAP.
+func ParseQuote(b []byte) (*Quote, error) {
+	if len(b) < quoteHeaderSize+reportSize+4 {
+		return nil, ErrQuoteFormat
+	}
+	if binary.LittleEndian.Uint16(b[0:]) != quoteVersion || binary.LittleEndian.Uint16(b[2:]) != attKeyECDSAP256 {
+		return nil, ErrQuoteFormat
+	}
+	q := &Quote{
+		Report: *parseReport(b[quoteHeaderSize:]),
+		signed: b[:quoteHeaderSize+reportSize],
+	}
+
+	sig := b[quoteHeaderSize+reportSize:]
+	if binary.LittleEndian.Uint32(sig) != uint32(len(sig)-4) {
+		return nil, ErrQuoteFormat
+	}
+	sig = sig[4:]
+	if len(sig) < 64+64+reportSize+64+2 {
+		return nil, ErrQuoteFormat
+	}
+	q.signature, sig = sig[:64], sig[64:]
+	q.attestKey, sig = sig[:64], sig[64:]
+	q.qeReport, sig = sig[:reportSize], sig[reportSize:]
+	q.qeReportSig, sig = sig[:64], sig[64:]
+
+	n := int(binary.LittleEndian.Uint16(sig))
+	sig = sig[2:]
+	if len(sig) < n+6 {
+		return nil, ErrQuoteFormat
+	}
+	q.qeAuthData, sig = sig[:n], sig[n:]
+
+	certType := binary.LittleEndian.Uint16(sig)
+	n = int(binary.LittleEndian.Uint32(sig[2:]))
+	sig = sig[6:]
+	if certType != certTypePCKPEM || len(sig) != n {
+		return nil, ErrQuoteFormat
+	}
+	for rest := sig; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		q.pckCertChain = append(q.pckCertChain, cert)
+	}
+	if len(q.pckCertChain) == 0 {
+		return nil, ErrQuoteFormat
+	}
+	return q, nil
+}
+
+// Verify checks the signature chain of the quote: the PCK certificate
+// chain up to roots, normally the Intel SGX root CA, at time now; the
+// report of the quoting enclave under the PCK key; the binding of the
+// attestation key to that report; and the quote under the attestation key.
+// It does not check the TCB level or the revocation of the platform, which
+// need the collateral of the Intel provisioning service.
+func (q *Quote) Verify(roots *x509.CertPool, now time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range q.pckCertChain[1:] {
+		intermediates.AddCert(c)
+	}
+	pck := q.pckCertChain[0]
+	if _, err := pck.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return err
+	}
+	pckKey, ok := pck.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !verifyP256(pckKey, q.qeReport, q.qeReportSig) {
+		return ErrQuoteSignature
+	}
+
+	h := sha256.New()
+	h.Write(q.attestKey)
+	h.Write(q.qeAuthData)
+	qeReportData := parseReport(q.qeReport).ReportData
+	if !bytes.Equal(qeReportData[:32], h.Sum(nil)) || !bytes.Equal(qeReportData[32:], make([]byte, 32)) {
+		return ErrQuoteSignature
+	}
+
+	attestKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(q.attestKey[:32]),
+		Y:     new(big.Int).SetBytes(q.attestKey[32:]),
+	}
+	if !attestKey.Curve.IsOnCurve(attestKey.X, attestKey.Y) || !verifyP256(attestKey, q.signed, q.signature) {
+		return ErrQuoteSignature
+	}
+	return nil
+}
+
+// verifyP256 verifies a raw r || s ECDSA signature of SHA-256(msg).
+func verifyP256(pub *ecdsa.PublicKey, msg, sig []byte) bool {
+	digest := sha256.Sum256(msg)
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	return ecdsa.Verify(pub, digest[:], r, s)
+}
+
+// Policy is the enclave a relying party accepts. Zero fields accept any
+// value, except that debug enclaves are rejected unless AllowDebug is set.
+type Policy struct {
+	MRENCLAVE  []byte
+	MRSIGNER   []byte
+	ISVProdID  uint16
+	MinISVSVN  uint16
+	AllowDebug bool
+}
+
+// Check checks the report against the policy.
+func (p *Policy) Check(r *Report) error {
+	if p.MRENCLAVE != nil && !bytes.Equal(p.MRENCLAVE, r.MRENCLAVE[:]) {
+		return ErrPolicy
+	}
+	if p.MRSIGNER != nil && !bytes.Equal(p.MRSIGNER, r.MRSIGNER[:]) {
+		return ErrPolicy
+	}
+	if p.ISVProdID != 0 && p.ISVProdID != r.ISVProdID {
+		return ErrPolicy
+	}
+	if r.ISVSVN < p.MinISVSVN {
+		return ErrPolicy
+	}
+	if r.Debug() && !p.AllowDebug {
+		return ErrPolicy
+	}
+	return nil
+}
+
+// ReportDataForKey is the report data with which an enclave attests that
+// it holds the private key of pub: SM3 of the uncompressed point, followed
+// by 32 bytes of nonce, e.g. a challenge of the relying party.
+func ReportDataForKey(pub *sm2.PublicKey, nonce []byte) ([64]byte, error) {
+	var data [64]byte
+	if len(nonce) > 32 {
+		return data, errors.New("tee: nonce longer than 32 bytes")
+	}
+	point := make([]byte, 65)
+	point[0] = 4
+	x, y := pub.X.Bytes(), pub.Y.Bytes()
+	copy(point[33-len(x):33], x)
+	copy(point[65-len(y):], y)
+	copy(data[:32], sm3.Sm3Sum(point))
+	copy(data[32:], nonce)
+	return data, nil
+}
+
+// CheckKeyBinding checks that the report data is ReportDataForKey of pub
+// and nonce.
+func CheckKeyBinding(r *Report, pub *sm2.PublicKey, nonce []byte) error {
+	data, err := ReportDataForKey(pub, nonce)
+	if err != nil {
+		return err
+	}
+	if data != r.ReportData {
+		return ErrKeyBinding
+	}
+	return nil
+}
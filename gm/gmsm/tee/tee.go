@@ -0,0 +1,119 @@
+// Package tee binds SM2 private keys to code running in a trusted execution
+// environment, such as an SGX enclave or a TrustZone trusted application,
+// instead of storing them on disk.
+//
+// Sealer is the sealing primitive of the TEE: inside an enclave, data
+// sealed with a key derived from the enclave measurement can only be
+// unsealed by the same code on the same platform. SealPrivateKey and
+// UnsealPrivateKey store SM2 keys with it. NewSoftwareSealer implements
+// Sealer outside of a TEE for development and tests.
+//
+// Other parties check that a key lives in the expected enclave with an
+// attestation quote: the enclave puts ReportDataForKey of its public key
+// in the report data of an SGX quote, and the relying party verifies the
+// quote with ParseQuote, Quote.Verify, Policy.Check and CheckKeyBinding.
+package tee
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/gmsm/sm3"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+var (
+	ErrUnseal    = errors.New("tee: sealed data corrupt or sealed by other code")
+	ErrSealedKey = errors.New("tee: sealed data is not an SM2 private key")
+)
+
+// Sealer seals data to the code of a TEE. additionalData is authenticated
+// but not encrypted, and must be the same for Unseal.
+type Sealer interface {
+	Seal(plaintext, additionalData []byte) ([]byte, error)
+	Unseal(sealed, additionalData []byte) ([]byte, error)
+}
+
+// keyLabel is the additional data of sealed SM2 private keys, which keeps
+// them from being confused with other sealed data.
+var keyLabel = []byte("tee: SM2 private key v1")
+
+// SealPrivateKey seals the scalar of priv.
+func SealPrivateKey(s Sealer, priv *sm2.PrivateKey) ([]byte, error) {
+	d := make([]byte, 32)
+	b := priv.D.Bytes()
+	copy(d[32-len(b):], b)
+	sealed, err := s.Seal(d, keyLabel)
+	for i := range d {
+		d[i] = 0
+	}
+	return sealed, err
+}
+
+// UnsealPrivateKey unseals a key sealed by SealPrivateKey.
+func UnsealPrivateKey(s Sealer, sealed []byte) (*sm2.PrivateKey, error) {
+	d, err := s.Unseal(sealed, keyLabel)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range d {
+			d[i] = 0
+		}
+	}()
+
+	curve := sm2.P256Sm2()
+	k := new(big.Int).SetBytes(d)
+	if len(d) != 32 || k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, ErrSealedKey
+	}
+	priv := &sm2.PrivateKey{PublicKey: sm2.PublicKey{Curve: curve}, D: k}
+	priv.X, priv.Y = curve.ScalarBaseMult(d)
+	return priv, nil
+}
+
+// softwareSealer seals with SM4-GCM under a key derived from a secret and
+// a measurement.
+type softwareSealer struct {
+	aead cipher.AEAD
+}
+
+// NewSoftwareSealer returns a Sealer for development and tests that seals
+// with SM4-GCM under SM3(secret || measurement), emulating the sealing key
+// of an enclave with the given measurement. It offers none of the
+// protection of a TEE.
+func NewSoftwareSealer(secret, measurement []byte) Sealer {
+	key := sm3.Sm3Sum(append(append([]byte(nil), secret...), measurement...))[:sm4.KeySize]
+	block, err := sm4.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &softwareSealer{aead: aead}
+}
+
+func (s *softwareSealer) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(sm2.RandSource(), nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (s *softwareSealer) Unseal(sealed, additionalData []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(sealed) < n+s.aead.Overhead() {
+		return nil, ErrUnseal
+	}
+	pt, err := s.aead.Open(nil, sealed[:n], sealed[n:], additionalData)
+	if err != nil {
+		return nil, ErrUnseal
+	}
+	return pt, nil
+}
@@ -0,0 +1,273 @@
+package tee
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+func TestSealPrivateKey(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSoftwareSealer([]byte("platform secret"), []byte("enclave v1"))
+	sealed, err := SealPrivateKey(s, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnsealPrivateKey(s, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.D.Cmp(priv.D) != 0 || got.X.Cmp(priv.X) != 0 || got.Y.Cmp(priv.Y) != 0 {
+		t.Fatal("wrong unsealed key")
+	}
+
+	other := NewSoftwareSealer([]byte("platform secret"), []byte("enclave v2"))
+	if _, err := UnsealPrivateKey(other, sealed); err != ErrUnseal {
+		t.Fatalf("expected ErrUnseal for other code, got %v", err)
+	}
+	data, err := s.Seal(make([]byte, 32), []byte("other data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnsealPrivateKey(s, data); err != ErrUnseal {
+		t.Fatalf("expected ErrUnseal for other sealed data, got %v", err)
+	}
+	zero, err := s.Seal(make([]byte, 32), keyLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnsealPrivateKey(s, zero); err != ErrSealedKey {
+		t.Fatalf("expected ErrSealedKey, got %v", err)
+	}
+}
+
+// testPlatform is a fake SGX platform with its own root CA.
+type testPlatform struct {
+	roots     *x509.CertPool
+	pckKey    *ecdsa.PrivateKey
+	pckPEM    []byte
+	attestKey *ecdsa.PrivateKey
+}
+
+func newTestPlatform(t *testing.T) *testPlatform {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test SGX Root CA"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(1<<32, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := x509.ParseCertificate(rootDER)
+
+	pckKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pckTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test SGX PCK Certificate"},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(1<<32, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	pckDER, err := x509.CreateCertificate(rand.Reader, pckTemplate, root, &pckKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &testPlatform{roots: x509.NewCertPool(), pckKey: pckKey, attestKey: attestKey}
+	p.roots.AddCert(root)
+	p.pckPEM = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pckDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER})...)
+	return p
+}
+
+func signP256(t *testing.T, key *ecdsa.PrivateKey, msg []byte) []byte {
+	digest := sha256.Sum256(msg)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	return sig
+}
+
+func point(pub *ecdsa.PublicKey) []byte {
+	b := make([]byte, 64)
+	x, y := pub.X.Bytes(), pub.Y.Bytes()
+	copy(b[32-len(x):32], x)
+	copy(b[64-len(y):], y)
+	return b
+}
+
+// quote returns a quote of an enclave with the measurement, attributes and
+// report data.
+func (p *testPlatform) quote(t *testing.T, mrenclave byte, attributes byte, reportData [64]byte) []byte {
+	header := make([]byte, quoteHeaderSize)
+	binary.LittleEndian.PutUint16(header[0:], quoteVersion)
+	binary.LittleEndian.PutUint16(header[2:], attKeyECDSAP256)
+	body := make([]byte, reportSize)
+	body[48] = attributes
+	for i := 64; i < 96; i++ {
+		body[i] = mrenclave
+	}
+	for i := 128; i < 160; i++ {
+		body[i] = 0x5e
+	}
+	binary.LittleEndian.PutUint16(body[256:], 7)
+	binary.LittleEndian.PutUint16(body[258:], 3)
+	copy(body[320:], reportData[:])
+
+	authData := []byte("qe auth data")
+	attestKey := point(&p.attestKey.PublicKey)
+	qeReport := make([]byte, reportSize)
+	h := sha256.Sum256(append(append([]byte(nil), attestKey...), authData...))
+	copy(qeReport[320:], h[:])
+
+	var sig bytes.Buffer
+	sig.Write(signP256(t, p.attestKey, append(append([]byte(nil), header...), body...)))
+	sig.Write(attestKey)
+	sig.Write(qeReport)
+	sig.Write(signP256(t, p.pckKey, qeReport))
+	binary.Write(&sig, binary.LittleEndian, uint16(len(authData)))
+	sig.Write(authData)
+	binary.Write(&sig, binary.LittleEndian, uint16(certTypePCKPEM))
+	binary.Write(&sig, binary.LittleEndian, uint32(len(p.pckPEM)))
+	sig.Write(p.pckPEM)
+
+	var q bytes.Buffer
+	q.Write(header)
+	q.Write(body)
+	binary.Write(&q, binary.LittleEndian, uint32(sig.Len()))
+	q.Write(sig.Bytes())
+	return q.Bytes()
+}
+
+func TestQuote(t *testing.T) {
+	p := newTestPlatform(t)
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("relying party challenge")
+	data, err := ReportDataForKey(&priv.PublicKey, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := p.quote(t, 0xe1, 0, data)
+
+	q, err := ParseQuote(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Verify(p.roots, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if q.MRENCLAVE[0] != 0xe1 || q.MRSIGNER[0] != 0x5e || q.ISVProdID != 7 || q.ISVSVN != 3 || q.Debug() {
+		t.Fatalf("report %+v", q.Report)
+	}
+	policy := &Policy{MRENCLAVE: bytes.Repeat([]byte{0xe1}, 32), ISVProdID: 7, MinISVSVN: 3}
+	if err := policy.Check(&q.Report); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKeyBinding(&q.Report, &priv.PublicKey, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKeyBinding(&q.Report, &priv.PublicKey, []byte("replayed")); err != ErrKeyBinding {
+		t.Fatalf("expected ErrKeyBinding, got %v", err)
+	}
+
+	for _, bad := range []*Policy{
+		{MRENCLAVE: bytes.Repeat([]byte{0xe2}, 32)},
+		{MRSIGNER: make([]byte, 32)},
+		{ISVProdID: 8},
+		{MinISVSVN: 4},
+	} {
+		if err := bad.Check(&q.Report); err != ErrPolicy {
+			t.Fatalf("policy %+v: expected ErrPolicy, got %v", bad, err)
+		}
+	}
+	debug, err := ParseQuote(p.quote(t, 0xe1, attributeDebug, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Policy{}).Check(&debug.Report); err != ErrPolicy {
+		t.Fatalf("expected a debug enclave to be rejected, got %v", err)
+	}
+	if err := (&Policy{AllowDebug: true}).Check(&debug.Report); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuoteVerifyRejects(t *testing.T) {
+	p := newTestPlatform(t)
+	raw := p.quote(t, 0xe1, 0, [64]byte{})
+
+	// A change to the report body breaks the attestation signature.
+	tampered := append([]byte(nil), raw...)
+	tampered[quoteHeaderSize+64] ^= 1
+	q, err := ParseQuote(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Verify(p.roots, time.Now()); err != ErrQuoteSignature {
+		t.Fatalf("expected ErrQuoteSignature, got %v", err)
+	}
+
+	// A change to the attestation key breaks the quoting enclave report.
+	tampered = append([]byte(nil), raw...)
+	tampered[quoteHeaderSize+reportSize+4+64] ^= 1
+	q, err = ParseQuote(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Verify(p.roots, time.Now()); err != ErrQuoteSignature {
+		t.Fatalf("expected ErrQuoteSignature, got %v", err)
+	}
+
+	// A chain to another root is rejected.
+	q, err = ParseQuote(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Verify(newTestPlatform(t).roots, time.Now()); err == nil {
+		t.Fatal("expected an untrusted PCK chain to be rejected")
+	}
+
+	for _, b := range [][]byte{raw[:100], raw[:len(raw)-1], append([]byte{4}, raw[1:]...)} {
+		if _, err := ParseQuote(b); err != ErrQuoteFormat {
+			t.Fatalf("expected ErrQuoteFormat, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package tickets seals opaque blobs with SM4-GCM for stateless session
+// state, such as TLS session tickets and web session cookies, where only
+// GM algorithms may be used.
+//
+// A Keys value holds one encryption key and any number of older keys kept
+// for decryption only, so that a key can be rotated without invalidating
+// the tickets issued under the previous one. Each key is derived from a
+// secret of at least MinSecretSize bytes with HKDF-SM3, which gives the
+// SM4 key and an 8-byte key name. A sealed blob is
+//
+//	version (1) || key name (8) || nonce (12) || ciphertext || tag (16)
+//
+// with the version, key name and nonce authenticated along with the
+// additional data of the caller. Nonces are random, so a key should be
+// rotated well before it has sealed 2^32 blobs.
+package tickets
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/hkdf"
+	"github.com/xuperchain/crypto/gm/gmsm/sm4"
+)
+
+const (
+	// MinSecretSize is the smallest secret a key is derived from.
+	MinSecretSize = 16
+
+	version   = 1
+	nameSize  = 8
+	nonceSize = 12
+	tagSize   = 16
+
+	// Overhead is the number of bytes Seal adds to the plaintext.
+	Overhead = 1 + nameSize + nonceSize + tagSize
+)
+
+var (
+	// ErrSecretSize is returned for a secret shorter than MinSecretSize.
+	ErrSecretSize = errors.New("tickets: secret too short")
+	// ErrUnknownKey is returned by Open for a blob sealed under a key that
+	// is not, or no longer, held.
+	ErrUnknownKey = errors.New("tickets: unknown key name")
+	// ErrInvalid is returned by Open for a blob that is malformed or fails
+	// authentication.
+	ErrInvalid = errors.New("tickets: invalid ticket")
+	// ErrExpired is returned by OpenToken for a token past its expiry.
+	ErrExpired = errors.New("tickets: token expired")
+)
+
+type key struct {
+	name [nameSize]byte
+	aead cipher.AEAD
+}
+
+func newKey(secret []byte) (*key, error) {
+	if len(secret) < MinSecretSize {
+		return nil, ErrSecretSize
+	}
+	name, err := hkdf.Key(secret, nil, []byte("tickets name"), nameSize)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := hkdf.Key(secret, nil, []byte("tickets key"), sm4.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := sm4.NewCipher(sk)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	k := &key{aead: aead}
+	copy(k.name[:], name)
+	return k, nil
+}
+
+// Keys is a set of ticket keys. It is safe for concurrent use.
+type Keys struct {
+	mu sync.RWMutex
+	// keys[0] encrypts; all of them decrypt.
+	keys []*key
+}
+
+// NewKeys returns a key set that seals with the key derived from secret
+// and opens with it and the keys derived from old.
+func NewKeys(secret []byte, old ...[]byte) (*Keys, error) {
+	ks := &Keys{}
+	for _, s := range append([][]byte{secret}, old...) {
+		k, err := newKey(s)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys = append(ks.keys, k)
+	}
+	return ks, nil
+}
+
+// Rotate makes the key derived from secret the encryption key. The
+// previous encryption key and the keep-1 most recent older keys are kept
+// for decryption; the others are dropped.
+func (ks *Keys) Rotate(secret []byte, keep int) error {
+	k, err := newKey(secret)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if keep > len(ks.keys) {
+		keep = len(ks.keys)
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	ks.keys = append([]*key{k}, ks.keys[:keep]...)
+	return nil
+}
+
+// Seal encrypts and authenticates plaintext and additionalData under the
+// encryption key, and returns the blob. additionalData is not included in
+// the blob and must be given to Open again.
+func (ks *Keys) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	ks.mu.RLock()
+	k := ks.keys[0]
+	ks.mu.RUnlock()
+
+	out := make([]byte, 1+nameSize+nonceSize, Overhead+len(plaintext))
+	out[0] = version
+	copy(out[1:], k.name[:])
+	if _, err := io.ReadFull(rand.Reader, out[1+nameSize:]); err != nil {
+		return nil, err
+	}
+	header := out[:1+nameSize+nonceSize]
+	nonce := header[1+nameSize:]
+	return k.aead.Seal(out, nonce, plaintext, aad(header, additionalData)), nil
+}
+
+// Open authenticates and decrypts a blob made by Seal with any key of the
+// set, and returns the plaintext.
+func (ks *Keys) Open(blob, additionalData []byte) ([]byte, error) {
+	if len(blob) < Overhead || blob[0] != version {
+		return nil, ErrInvalid
+	}
+	header := blob[:1+nameSize+nonceSize]
+	name, nonce := header[1:1+nameSize], header[1+nameSize:]
+
+	ks.mu.RLock()
+	var k *key
+	for _, c := range ks.keys {
+		if string(c.name[:]) == string(name) {
+			k = c
+			break
+		}
+	}
+	ks.mu.RUnlock()
+	if k == nil {
+		return nil, ErrUnknownKey
+	}
+	plaintext, err := k.aead.Open(nil, nonce, blob[len(header):], aad(header, additionalData))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return plaintext, nil
+}
+
+func aad(header, additionalData []byte) []byte {
+	return append(append([]byte(nil), header...), additionalData...)
+}
+
+// SealToken seals payload with an expiry time into a string safe for
+// cookies and URLs, the unpadded base64url encoding of the blob. The
+// additional data typically binds the token to its purpose, such as the
+// cookie name.
+func (ks *Keys) SealToken(payload, additionalData []byte, expires time.Time) (string, error) {
+	plaintext := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint64(plaintext, uint64(expires.Unix()))
+	blob, err := ks.Seal(append(plaintext, payload...), additionalData)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(blob), nil
+}
+
+// OpenToken opens a token made by SealToken and returns its payload,
+// unless it expired before now.
+func (ks *Keys) OpenToken(token string, additionalData []byte, now time.Time) ([]byte, error) {
+	blob, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	plaintext, err := ks.Open(blob, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 8 {
+		return nil, ErrInvalid
+	}
+	if now.Unix() >= int64(binary.BigEndian.Uint64(plaintext)) {
+		return nil, ErrExpired
+	}
+	return plaintext[8:], nil
+}
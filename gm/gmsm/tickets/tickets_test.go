@@ -0,0 +1,114 @@
+package tickets
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+var (
+	secret1 = bytes.Repeat([]byte{1}, 32)
+	secret2 = bytes.Repeat([]byte{2}, 32)
+	secret3 = bytes.Repeat([]byte{3}, 32)
+)
+
+func TestSealOpen(t *testing.T) {
+	ks, err := NewKeys(secret1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("session state")
+	blob, err := ks.Seal(msg, []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blob) != len(msg)+Overhead {
+		t.Fatalf("blob is %d bytes, want %d", len(blob), len(msg)+Overhead)
+	}
+	if pt, err := ks.Open(blob, []byte("aad")); err != nil || !bytes.Equal(pt, msg) {
+		t.Fatalf("Open = %q, %v", pt, err)
+	}
+	if _, err := ks.Open(blob, []byte("other")); err != ErrInvalid {
+		t.Errorf("wrong additional data: %v", err)
+	}
+	for i := range blob {
+		b := append([]byte(nil), blob...)
+		b[i] ^= 1
+		if _, err := ks.Open(b, []byte("aad")); err == nil {
+			t.Fatalf("flipped byte %d accepted", i)
+		}
+	}
+	if _, err := ks.Open(blob[:Overhead-1], nil); err != ErrInvalid {
+		t.Errorf("short blob: %v", err)
+	}
+	if _, err := NewKeys(secret1[:MinSecretSize-1]); err != ErrSecretSize {
+		t.Errorf("short secret: %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ks, err := NewKeys(secret1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob1, _ := ks.Seal([]byte("one"), nil)
+	if err := ks.Rotate(secret2, 1); err != nil {
+		t.Fatal(err)
+	}
+	blob2, _ := ks.Seal([]byte("two"), nil)
+	if bytes.Equal(blob1[1:1+nameSize], blob2[1:1+nameSize]) {
+		t.Fatal("rotation kept the key name")
+	}
+	if pt, err := ks.Open(blob1, nil); err != nil || string(pt) != "one" {
+		t.Fatalf("previous key: %q, %v", pt, err)
+	}
+
+	// A set built from the same secrets opens the same blobs.
+	other, err := NewKeys(secret2, secret1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := other.Open(blob2, nil); err != nil || string(pt) != "two" {
+		t.Fatalf("other set: %q, %v", pt, err)
+	}
+
+	if err := ks.Rotate(secret3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Open(blob1, nil); err != ErrUnknownKey {
+		t.Errorf("dropped key: %v", err)
+	}
+	if _, err := ks.Open(blob2, nil); err != nil {
+		t.Errorf("kept key: %v", err)
+	}
+	if err := ks.Rotate(secret1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Open(blob2, nil); err != ErrUnknownKey {
+		t.Errorf("keep 0: %v", err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	ks, err := NewKeys(secret1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	token, err := ks.SealToken([]byte("user=42"), []byte("session"), now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt, err := ks.OpenToken(token, []byte("session"), now); err != nil || string(pt) != "user=42" {
+		t.Fatalf("OpenToken = %q, %v", pt, err)
+	}
+	if _, err := ks.OpenToken(token, []byte("session"), now.Add(time.Hour)); err != ErrExpired {
+		t.Errorf("expired token: %v", err)
+	}
+	if _, err := ks.OpenToken(token, []byte("csrf"), now); err != ErrInvalid {
+		t.Errorf("other purpose: %v", err)
+	}
+	if _, err := ks.OpenToken(token+"!", []byte("session"), now); err != ErrInvalid {
+		t.Errorf("bad encoding: %v", err)
+	}
+}
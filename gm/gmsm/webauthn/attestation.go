@@ -0,0 +1,117 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// oidFIDOGenCeAAGUID is the extension that binds an attestation certificate
+// to the AAGUID of an authenticator model.
+var oidFIDOGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// sm2PublicKey returns the SM2 key of a certificate, which sm2 parses as
+// *ecdsa.PublicKey on the SM2 curve.
+func sm2PublicKey(pub interface{}) (*sm2.PublicKey, bool) {
+	switch pub := pub.(type) {
+	case *sm2.PublicKey:
+		return pub, true
+	case *ecdsa.PublicKey:
+		if pub.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: pub.Curve, X: pub.X, Y: pub.Y}, true
+		}
+	}
+	return nil, false
+}
+
+// verifyPacked verifies a packed attestation statement over signed, the
+// authenticator data followed by the client data hash, and records the
+// attestation in cred.
+func (rp *RelyingParty) verifyPacked(cred *Credential, stmt map[interface{}]interface{}, signed []byte) error {
+	alg, _ := rp.coseIDs()
+	if stmt["alg"] != alg {
+		return ErrUnsupported
+	}
+	sig, ok := stmt["sig"].([]byte)
+	if !ok {
+		return ErrMalformed
+	}
+	x5c, ok := stmt["x5c"]
+	if !ok {
+		// Self attestation, made with the credential key itself.
+		if !rp.AllowUnattested || !sm2.VerifyEx(cred.PublicKey, signed, sig) {
+			return ErrAttestation
+		}
+		cred.AttestationType = "self"
+		return nil
+	}
+
+	list, ok := x5c.([]interface{})
+	if !ok || len(list) == 0 {
+		return ErrMalformed
+	}
+	var certs []*sm2.Certificate
+	for _, v := range list {
+		der, ok := v.([]byte)
+		if !ok {
+			return ErrMalformed
+		}
+		cert, err := sm2.ParseCertificate(der)
+		if err != nil {
+			return ErrMalformed
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+	pub, ok := sm2PublicKey(leaf.PublicKey)
+	if !ok {
+		return ErrUnsupported
+	}
+	if !sm2.VerifyEx(pub, signed, sig) || !checkAttestationCertificate(leaf, cred.AAGUID) {
+		return ErrAttestation
+	}
+	if rp.AttestationRoots == nil {
+		return ErrAttestation
+	}
+	intermediates := sm2.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	chains, err := leaf.Verify(sm2.VerifyOptions{
+		Roots:         rp.AttestationRoots,
+		Intermediates: intermediates,
+		KeyUsages:     []sm2.ExtKeyUsage{sm2.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return ErrAttestation
+	}
+	cred.AttestationType = "basic"
+	cred.AttestationChain = chains[0]
+	return nil
+}
+
+// checkAttestationCertificate checks the requirements WebAuthn, section
+// 8.2.1 puts on packed attestation certificates.
+func checkAttestationCertificate(cert *sm2.Certificate, aaguid [16]byte) bool {
+	s := cert.Subject
+	if cert.Version != 3 || len(s.Country) == 0 || len(s.Organization) == 0 || s.CommonName == "" ||
+		len(s.OrganizationalUnit) != 1 || s.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return false
+	}
+	if !cert.BasicConstraintsValid || cert.IsCA {
+		return false
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOGenCeAAGUID) {
+			continue
+		}
+		var value []byte
+		if rest, err := asn1.Unmarshal(ext.Value, &value); err != nil || len(rest) != 0 ||
+			ext.Critical || !bytes.Equal(value, aaguid[:]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// maxCBORDepth bounds the nesting of decoded CBOR items.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the CBOR data item at the start of b, returning it and
+// the number of bytes it takes. Only what WebAuthn uses is supported:
+// integers, byte and text strings, arrays, maps and the simple values
+// false, true and null, all with definite lengths. Integers are returned as
+// int64, byte strings as []byte, maps as map[interface{}]interface{} with
+// int64 or string keys.
+func decodeCBOR(b []byte) (interface{}, int, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (interface{}, int, error) {
+	if depth > maxCBORDepth || len(b) == 0 {
+		return nil, 0, ErrMalformed
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	n := 1
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b) < 1+size {
+			return nil, 0, ErrMalformed
+		}
+		var buf [8]byte
+		copy(buf[8-size:], b[1:1+size])
+		arg = binary.BigEndian.Uint64(buf[:])
+		n += size
+	default:
+		// Reserved values and indefinite lengths.
+		return nil, 0, ErrMalformed
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, ErrMalformed
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, ErrMalformed
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(b)-n) {
+			return nil, 0, ErrMalformed
+		}
+		s := b[n : n+int(arg)]
+		n += int(arg)
+		if major == 3 {
+			return string(s), n, nil
+		}
+		return append([]byte(nil), s...), n, nil
+	case 4:
+		if arg > uint64(len(b)-n) {
+			return nil, 0, ErrMalformed
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, m, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, v)
+			n += m
+		}
+		return items, n, nil
+	case 5:
+		if arg > uint64(len(b)-n)/2 {
+			return nil, 0, ErrMalformed
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, kn, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += kn
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, 0, ErrMalformed
+			}
+			if _, dup := m[k]; dup {
+				return nil, 0, ErrMalformed
+			}
+			v, vn, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += vn
+			m[k] = v
+		}
+		return m, n, nil
+	case 7:
+		switch {
+		case info == 20:
+			return false, n, nil
+		case info == 21:
+			return true, n, nil
+		case info == 22:
+			return nil, n, nil
+		}
+	}
+	// Tags, floats and other simple values.
+	return nil, 0, ErrUnsupported
+}
+
+// decodeCBORMap decodes b, which must be exactly one CBOR map.
+func decodeCBORMap(b []byte) (map[interface{}]interface{}, error) {
+	v, n, err := decodeCBOR(b)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok || n != len(b) {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
@@ -0,0 +1,55 @@
+package webauthn
+
+import (
+	"math/big"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// COSE key parameters of RFC 9052 and RFC 9053.
+const (
+	coseKty    = 1
+	coseAlg    = 3
+	coseCrv    = -1
+	coseX      = -2
+	coseY      = -3
+	coseKtyEC2 = 2
+)
+
+// COSE identifiers given to SM2 by default. SM2 has no IANA COSE
+// registration; authenticators that use other values are supported by
+// setting them in RelyingParty.
+const (
+	COSEAlgorithmSM2SM3 = -48
+	COSECurveSM2        = 10
+)
+
+// ParseCOSEKey parses a COSE_Key of key type EC2 that holds an SM2 public
+// key, with the given algorithm and curve identifiers.
+func ParseCOSEKey(data []byte, alg, crv int64) (*sm2.PublicKey, error) {
+	m, err := decodeCBORMap(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseCOSEKey(m, alg, crv)
+}
+
+func parseCOSEKey(m map[interface{}]interface{}, alg, crv int64) (*sm2.PublicKey, error) {
+	if m[int64(coseKty)] != int64(coseKtyEC2) {
+		return nil, ErrUnsupported
+	}
+	// The algorithm is optional in a COSE_Key, but WebAuthn requires it.
+	if m[int64(coseAlg)] != alg || m[int64(coseCrv)] != crv {
+		return nil, ErrUnsupported
+	}
+	x, ok1 := m[int64(coseX)].([]byte)
+	y, ok2 := m[int64(coseY)].([]byte)
+	if !ok1 || !ok2 || len(x) != 32 || len(y) != 32 {
+		return nil, ErrMalformed
+	}
+	pub := &sm2.PublicKey{Curve: sm2.P256Sm2(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if err := pub.Validate(); err != nil {
+		return nil, ErrMalformed
+	}
+	return pub, nil
+}
@@ -0,0 +1,271 @@
+// Package webauthn verifies WebAuthn registrations and assertions of
+// authenticators with SM2 credentials, for relying parties that accept
+// domestic FIDO2 keys.
+//
+// Credential public keys are COSE_Key EC2 structures on the SM2 curve, and
+// signatures DER SM2 signatures with SM3 under sm2.DefaultUID, over the
+// authenticator data and the client data hash as WebAuthn defines them.
+// The RP ID hash and client data hash remain SHA-256, which browsers and
+// authenticators compute whatever the credential algorithm.
+//
+// Attestation statements of the "packed" format are verified, both self
+// attestation and basic attestation with an SM2 certificate chain to the
+// roots of the relying party, as is the "none" format when unattested
+// credentials are allowed. Other formats are rejected.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+var (
+	// ErrMalformed is returned for data that cannot be parsed.
+	ErrMalformed = errors.New("webauthn: malformed data")
+	// ErrUnsupported is returned for valid data this package does not
+	// implement, such as other algorithms or attestation formats.
+	ErrUnsupported = errors.New("webauthn: unsupported algorithm or format")
+	// ErrClientData is returned when the client data has the wrong type,
+	// challenge or origin.
+	ErrClientData = errors.New("webauthn: client data does not match the ceremony")
+	// ErrRelyingParty is returned when the authenticator data is for
+	// another RP ID.
+	ErrRelyingParty = errors.New("webauthn: RP ID hash mismatch")
+	// ErrUserVerification is returned when the user presence or required
+	// user verification flag is not set.
+	ErrUserVerification = errors.New("webauthn: user not present or not verified")
+	// ErrAttestation is returned for an attestation statement that does not
+	// verify or is not trusted.
+	ErrAttestation = errors.New("webauthn: attestation not accepted")
+	// ErrSignature is returned for an assertion signature that does not
+	// verify.
+	ErrSignature = errors.New("webauthn: invalid signature")
+	// ErrSignCount is returned when the signature counter did not grow,
+	// which suggests a cloned authenticator.
+	ErrSignCount = errors.New("webauthn: signature counter did not increase")
+)
+
+// Flags of the authenticator data.
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagAttestedCredData = 0x40
+	flagExtensionData    = 0x80
+)
+
+// RelyingParty holds the settings a relying party verifies ceremonies
+// against.
+type RelyingParty struct {
+	// ID is the RP ID, such as "example.com".
+	ID string
+	// Origins are the accepted origins of the client data, such as
+	// "https://login.example.com".
+	Origins []string
+	// RequireUserVerification rejects ceremonies without the user verified
+	// flag.
+	RequireUserVerification bool
+	// AttestationRoots are the roots basic attestation must chain to. With
+	// nil roots, basic attestation is rejected.
+	AttestationRoots *sm2.CertPool
+	// AllowUnattested accepts the "none" format and self attestation.
+	AllowUnattested bool
+	// COSEAlgorithm and COSECurve identify SM2 in COSE keys and attestation
+	// statements. Zero values select COSEAlgorithmSM2SM3 and COSECurveSM2.
+	COSEAlgorithm, COSECurve int64
+}
+
+func (rp *RelyingParty) coseIDs() (alg, crv int64) {
+	alg, crv = rp.COSEAlgorithm, rp.COSECurve
+	if alg == 0 {
+		alg = COSEAlgorithmSM2SM3
+	}
+	if crv == 0 {
+		crv = COSECurveSM2
+	}
+	return alg, crv
+}
+
+// Credential is a registered credential.
+type Credential struct {
+	ID        []byte
+	PublicKey *sm2.PublicKey
+	// SignCount is the last signature counter seen; VerifyAssertion
+	// updates it.
+	SignCount uint32
+	AAGUID    [16]byte
+	// AttestationType is "none", "self" or "basic".
+	AttestationType string
+	// AttestationChain is the verified chain of basic attestation, from the
+	// attestation certificate to a root.
+	AttestationChain []*sm2.Certificate
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// checkClientData checks the client data of a ceremony of the given type
+// and returns its hash.
+func (rp *RelyingParty) checkClientData(data []byte, typ string, challenge []byte) ([]byte, error) {
+	var cd clientData
+	if err := json.Unmarshal(data, &cd); err != nil {
+		return nil, ErrMalformed
+	}
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || cd.Type != typ || !bytes.Equal(got, challenge) {
+		return nil, ErrClientData
+	}
+	origin := false
+	for _, o := range rp.Origins {
+		origin = origin || o == cd.Origin
+	}
+	if !origin {
+		return nil, ErrClientData
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+type authenticatorData struct {
+	flags        byte
+	signCount    uint32
+	aaguid       [16]byte
+	credentialID []byte
+	credentialPK map[interface{}]interface{}
+}
+
+// parseAuthenticatorData parses authenticator data and checks its RP ID
+// hash and flags.
+func (rp *RelyingParty) parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, ErrMalformed
+	}
+	ad := &authenticatorData{flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37])}
+	rest := b[37:]
+	if ad.flags&flagAttestedCredData != 0 {
+		if len(rest) < 18 {
+			return nil, ErrMalformed
+		}
+		copy(ad.aaguid[:], rest)
+		n := int(binary.BigEndian.Uint16(rest[16:]))
+		rest = rest[18:]
+		if len(rest) < n {
+			return nil, ErrMalformed
+		}
+		ad.credentialID, rest = rest[:n], rest[n:]
+		v, m, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if ad.credentialPK, ok = v.(map[interface{}]interface{}); !ok {
+			return nil, ErrMalformed
+		}
+		rest = rest[m:]
+	}
+	if ad.flags&flagExtensionData != 0 {
+		v, m, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := v.(map[interface{}]interface{}); !ok {
+			return nil, ErrMalformed
+		}
+		rest = rest[m:]
+	}
+	if len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+
+	if rpIDHash := sha256.Sum256([]byte(rp.ID)); !bytes.Equal(b[:32], rpIDHash[:]) {
+		return nil, ErrRelyingParty
+	}
+	if ad.flags&flagUserPresent == 0 || rp.RequireUserVerification && ad.flags&flagUserVerified == 0 {
+		return nil, ErrUserVerification
+	}
+	return ad, nil
+}
+
+// VerifyRegistration verifies the response of navigator.credentials.create
+// to challenge: the client data JSON and the attestation object. It returns
+// the new credential, which the caller stores with the user account.
+func (rp *RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	clientDataHash, err := rp.checkClientData(clientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := decodeCBORMap(attestationObject)
+	if err != nil {
+		return nil, err
+	}
+	format, ok1 := obj["fmt"].(string)
+	stmt, ok2 := obj["attStmt"].(map[interface{}]interface{})
+	rawAuthData, ok3 := obj["authData"].([]byte)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, ErrMalformed
+	}
+	ad, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if ad.credentialPK == nil {
+		return nil, ErrMalformed
+	}
+	alg, crv := rp.coseIDs()
+	pub, err := parseCOSEKey(ad.credentialPK, alg, crv)
+	if err != nil {
+		return nil, err
+	}
+	cred := &Credential{
+		ID:        ad.credentialID,
+		PublicKey: pub,
+		SignCount: ad.signCount,
+		AAGUID:    ad.aaguid,
+	}
+	switch format {
+	case "none":
+		if len(stmt) != 0 || !rp.AllowUnattested {
+			return nil, ErrAttestation
+		}
+		cred.AttestationType = "none"
+	case "packed":
+		if err := rp.verifyPacked(cred, stmt, append(append([]byte(nil), rawAuthData...), clientDataHash...)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+	return cred, nil
+}
+
+// VerifyAssertion verifies the response of navigator.credentials.get to
+// challenge made with cred, and updates cred.SignCount. The caller looks
+// up cred by the credential ID of the response and stores it back after.
+func (rp *RelyingParty) VerifyAssertion(cred *Credential, challenge, clientDataJSON, authenticatorData, signature []byte) error {
+	clientDataHash, err := rp.checkClientData(clientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return err
+	}
+	ad, err := rp.parseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return err
+	}
+	signed := append(append([]byte(nil), authenticatorData...), clientDataHash...)
+	if !sm2.VerifyEx(cred.PublicKey, signed, signature) {
+		return ErrSignature
+	}
+	// Authenticators without a counter always report zero.
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return ErrSignCount
+	}
+	cred.SignCount = ad.signCount
+	return nil
+}
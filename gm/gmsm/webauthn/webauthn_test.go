@@ -0,0 +1,322 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// cborMap is a CBOR map with its keys in the order to encode them.
+type cborMap []struct{ k, v interface{} }
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+	b := []byte{major<<5 | 27, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(b[1:], n)
+	return b
+}
+
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		b := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			b = append(b, encodeCBOR(item)...)
+		}
+		return b
+	case cborMap:
+		b := cborHead(5, uint64(len(v)))
+		for _, kv := range v {
+			b = append(append(b, encodeCBOR(kv.k)...), encodeCBOR(kv.v)...)
+		}
+		return b
+	}
+	panic("unsupported CBOR value")
+}
+
+func coseKey(pub *sm2.PublicKey) []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	copy(x[32-len(pub.X.Bytes()):], pub.X.Bytes())
+	copy(y[32-len(pub.Y.Bytes()):], pub.Y.Bytes())
+	return encodeCBOR(cborMap{
+		{1, 2}, {3, COSEAlgorithmSM2SM3}, {-1, COSECurveSM2}, {-2, x}, {-3, y},
+	})
+}
+
+// authenticator is a software SM2 authenticator.
+type authenticator struct {
+	aaguid    [16]byte
+	credID    []byte
+	key       *sm2.PrivateKey
+	signCount uint32
+	// attestation holds the attestation key and certificate chain; nil
+	// makes self attestation.
+	attKey   *sm2.PrivateKey
+	attChain [][]byte
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &authenticator{key: key, credID: []byte("credential-1")}
+	rand.Read(a.aaguid[:])
+	return a
+}
+
+func clientDataJSON(typ string, challenge []byte, origin string) []byte {
+	return []byte(`{"type":"` + typ + `","challenge":"` + base64.RawURLEncoding.EncodeToString(challenge) +
+		`","origin":"` + origin + `","crossOrigin":false}`)
+}
+
+func (a *authenticator) authData(rpID string, flags byte, attested bool) []byte {
+	h := sha256.Sum256([]byte(rpID))
+	b := append(h[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[33:], a.signCount)
+	if attested {
+		b = append(b, a.aaguid[:]...)
+		b = append(b, byte(len(a.credID)>>8), byte(len(a.credID)))
+		b = append(b, a.credID...)
+		b = append(b, coseKey(&a.key.PublicKey)...)
+	}
+	return b
+}
+
+func sign(t *testing.T, key *sm2.PrivateKey, authData, clientData []byte) []byte {
+	h := sha256.Sum256(clientData)
+	sig, err := sm2.SignEx(key, append(append([]byte(nil), authData...), h[:]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func (a *authenticator) register(t *testing.T, rpID string, clientData []byte) []byte {
+	authData := a.authData(rpID, flagUserPresent|flagUserVerified|flagAttestedCredData, true)
+	stmt := cborMap{{"alg", COSEAlgorithmSM2SM3}}
+	if a.attKey == nil {
+		stmt = append(stmt, cborMap{{"sig", sign(t, a.key, authData, clientData)}}...)
+	} else {
+		x5c := make([]interface{}, len(a.attChain))
+		for i, der := range a.attChain {
+			x5c[i] = der
+		}
+		stmt = append(stmt, cborMap{{"sig", sign(t, a.attKey, authData, clientData)}, {"x5c", x5c}}...)
+	}
+	return encodeCBOR(cborMap{{"fmt", "packed"}, {"attStmt", stmt}, {"authData", authData}})
+}
+
+func (a *authenticator) assert(t *testing.T, rpID string, clientData []byte) (authData, sig []byte) {
+	a.signCount++
+	authData = a.authData(rpID, flagUserPresent, false)
+	return authData, sign(t, a.key, authData, clientData)
+}
+
+var rp = &RelyingParty{ID: "example.com", Origins: []string{"https://login.example.com"}, AllowUnattested: true}
+
+func TestSelfAttestation(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := []byte("registration challenge")
+	cd := clientDataJSON("webauthn.create", challenge, rp.Origins[0])
+	obj := a.register(t, rp.ID, cd)
+	cred, err := rp.VerifyRegistration(challenge, cd, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.AttestationType != "self" || !bytes.Equal(cred.ID, a.credID) || cred.AAGUID != a.aaguid ||
+		cred.PublicKey.X.Cmp(a.key.X) != 0 {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	strict := *rp
+	strict.AllowUnattested = false
+	if _, err := strict.VerifyRegistration(challenge, cd, obj); err != ErrAttestation {
+		t.Fatalf("self attestation without AllowUnattested: %v", err)
+	}
+	if _, err := rp.VerifyRegistration([]byte("other"), cd, obj); err != ErrClientData {
+		t.Fatalf("wrong challenge: %v", err)
+	}
+	evil := clientDataJSON("webauthn.create", challenge, "https://login.example.net")
+	if _, err := rp.VerifyRegistration(challenge, evil, a.register(t, rp.ID, evil)); err != ErrClientData {
+		t.Fatalf("wrong origin: %v", err)
+	}
+	if _, err := rp.VerifyRegistration(challenge, cd, a.register(t, "example.net", cd)); err != ErrRelyingParty {
+		t.Fatalf("wrong RP ID: %v", err)
+	}
+	// A signature over other client data does not attest this one.
+	other := clientDataJSON("webauthn.create", challenge, rp.Origins[0]+"/")
+	if _, err := rp.VerifyRegistration(challenge, cd, a.register(t, rp.ID, other)); err != ErrAttestation {
+		t.Fatalf("mismatched attestation signature: %v", err)
+	}
+}
+
+func TestAssertion(t *testing.T) {
+	a := newAuthenticator(t)
+	challenge := []byte("registration")
+	cd := clientDataJSON("webauthn.create", challenge, rp.Origins[0])
+	cred, err := rp.VerifyRegistration(challenge, cd, a.register(t, rp.ID, cd))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge = []byte("login challenge")
+	cd = clientDataJSON("webauthn.get", challenge, rp.Origins[0])
+	authData, sig := a.assert(t, rp.ID, cd)
+	if err := rp.VerifyAssertion(cred, challenge, cd, authData, sig); err != nil {
+		t.Fatal(err)
+	}
+	if cred.SignCount != 1 {
+		t.Fatalf("sign count %d", cred.SignCount)
+	}
+	// A replayed assertion, or one of a clone, does not advance the counter.
+	if err := rp.VerifyAssertion(cred, challenge, cd, authData, sig); err != ErrSignCount {
+		t.Fatalf("replay: %v", err)
+	}
+
+	authData, sig = a.assert(t, rp.ID, cd)
+	bad := append([]byte(nil), sig...)
+	bad[len(bad)-1] ^= 1
+	if err := rp.VerifyAssertion(cred, challenge, cd, authData, bad); err != ErrSignature {
+		t.Fatalf("bad signature: %v", err)
+	}
+	if err := rp.VerifyAssertion(cred, challenge, clientDataJSON("webauthn.create", challenge, rp.Origins[0]), authData, sig); err != ErrClientData {
+		t.Fatalf("wrong type: %v", err)
+	}
+	uv := *rp
+	uv.RequireUserVerification = true
+	if err := uv.VerifyAssertion(cred, challenge, cd, authData, sig); err != ErrUserVerification {
+		t.Fatalf("user not verified: %v", err)
+	}
+	if err := rp.VerifyAssertion(cred, challenge, cd, authData, sig); err != nil || cred.SignCount != 2 {
+		t.Fatalf("got %v, count %d", err, cred.SignCount)
+	}
+}
+
+// newCertificate issues template under parent, or self-signs it when parent
+// is nil.
+func newCertificate(t *testing.T, template, parent *sm2.Certificate, pub *sm2.PublicKey, priv *sm2.PrivateKey) *sm2.Certificate {
+	if parent == nil {
+		parent = template
+	}
+	der, err := sm2.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := sm2.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestBasicAttestation(t *testing.T) {
+	rootKey, _ := sm2.GenerateKey()
+	root := newCertificate(t, &sm2.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "GM FIDO Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              sm2.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SignatureAlgorithm:    sm2.SM2WithSM3,
+	}, nil, &rootKey.PublicKey, rootKey)
+
+	a := newAuthenticator(t)
+	a.attKey, _ = sm2.GenerateKey()
+	aaguidExt, _ := asn1.Marshal(a.aaguid[:])
+	template := &sm2.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			Country:            []string{"CN"},
+			Organization:       []string{"Vendor"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Vendor SM2 Key",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidFIDOGenCeAAGUID, Value: aaguidExt}},
+		SignatureAlgorithm:    sm2.SM2WithSM3,
+	}
+	a.attChain = [][]byte{newCertificate(t, template, root, &a.attKey.PublicKey, rootKey).Raw}
+
+	roots := sm2.NewCertPool()
+	roots.AddCert(root)
+	trusting := *rp
+	trusting.AttestationRoots = roots
+	trusting.AllowUnattested = false
+
+	challenge := []byte("attested registration")
+	cd := clientDataJSON("webauthn.create", challenge, rp.Origins[0])
+	obj := a.register(t, rp.ID, cd)
+	cred, err := trusting.VerifyRegistration(challenge, cd, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.AttestationType != "basic" || len(cred.AttestationChain) != 2 || !cred.AttestationChain[1].Equal(root) {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+	if _, err := rp.VerifyRegistration(challenge, cd, obj); err != ErrAttestation {
+		t.Fatalf("no roots: %v", err)
+	}
+
+	// An attestation certificate for another model is rejected.
+	b := newAuthenticator(t)
+	b.attKey, b.attChain = a.attKey, a.attChain
+	if _, err := trusting.VerifyRegistration(challenge, cd, b.register(t, rp.ID, cd)); err != ErrAttestation {
+		t.Fatalf("AAGUID mismatch: %v", err)
+	}
+}
+
+func TestCOSEKey(t *testing.T) {
+	key, _ := sm2.GenerateKey()
+	enc := coseKey(&key.PublicKey)
+	pub, err := ParseCOSEKey(enc, COSEAlgorithmSM2SM3, COSECurveSM2)
+	if err != nil || pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+		t.Fatalf("round trip: %v", err)
+	}
+	if _, err := ParseCOSEKey(enc, -7, 1); err != ErrUnsupported {
+		t.Fatalf("P-256 identifiers: %v", err)
+	}
+	x := make([]byte, 32)
+	for name, data := range map[string][]byte{
+		"trailing":   append(append([]byte(nil), enc...), 0),
+		"truncated":  enc[:len(enc)-1],
+		"short x":    encodeCBOR(cborMap{{1, 2}, {3, COSEAlgorithmSM2SM3}, {-1, COSECurveSM2}, {-2, x[:31]}, {-3, x}}),
+		"off curve":  encodeCBOR(cborMap{{1, 2}, {3, COSEAlgorithmSM2SM3}, {-1, COSECurveSM2}, {-2, x}, {-3, x}}),
+		"duplicate":  encodeCBOR(cborMap{{1, 2}, {1, 2}}),
+		"indefinite": {0xbf, 0x01, 0x02, 0xff},
+		"deep":       bytes.Repeat([]byte{0x81}, 100),
+	} {
+		if _, err := ParseCOSEKey(data, COSEAlgorithmSM2SM3, COSECurveSM2); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package zuc
+
+import "encoding/binary"
+
+// eea3IV returns the ZUC IV used by 128-EEA3 for the given COUNT, BEARER and
+// DIRECTION.
+func eea3IV(count uint32, bearer, direction uint8) []byte {
+	iv := make([]byte, IVSize)
+	binary.BigEndian.PutUint32(iv, count)
+	iv[4] = bearer<<3 | (direction&1)<<2
+	copy(iv[8:], iv[:8])
+	return iv
+}
+
+// NewEEA3 returns the 128-EEA3 keystream for the confidentiality key ck and
+// the given COUNT, 5-bit BEARER and 1-bit DIRECTION.
+//
+// 128-EEA3 messages are measured in bits. For a message whose length is not a
+// multiple of 8, XOR the enclosing bytes and clear the unused low-order bits
+// of the last byte, or use EEA3.
+func NewEEA3(ck []byte, count uint32, bearer, direction uint8) (*Stream, error) {
+	if len(ck) != KeySize {
+		return nil, KeySizeError(len(ck))
+	}
+	return NewCipher(ck, eea3IV(count, bearer, direction))
+}
+
+// EEA3 encrypts or decrypts the first bits bits of src with 128-EEA3 and
+// returns the result in a new slice of (bits+7)/8 bytes, with the unused
+// bits of the last byte set to zero.
+func EEA3(ck []byte, count uint32, bearer, direction uint8, src []byte, bits int) ([]byte, error) {
+	if bits < 0 || bits > 8*len(src) {
+		panic("zuc: invalid bit length")
+	}
+	c, err := NewEEA3(ck, count, bearer, direction)
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, (bits+7)/8)
+	c.XORKeyStream(dst, src[:len(dst)])
+	if r := bits % 8; r != 0 {
+		dst[len(dst)-1] &= 0xff << uint(8-r)
+	}
+	return dst, nil
+}
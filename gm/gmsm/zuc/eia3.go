@@ -0,0 +1,152 @@
+package zuc
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// MACSize is the size of a 128-EIA3 MAC in bytes.
+const MACSize = 4
+
+// eia3IV returns the ZUC IV used by 128-EIA3 for the given COUNT, BEARER and
+// DIRECTION.
+func eia3IV(count uint32, bearer, direction uint8) []byte {
+	iv := make([]byte, IVSize)
+	binary.BigEndian.PutUint32(iv, count)
+	iv[4] = bearer << 3
+	copy(iv[8:], iv[:8])
+	iv[8] ^= (direction & 1) << 7
+	iv[14] ^= (direction & 1) << 7
+	return iv
+}
+
+// mac computes a 128-EIA3 MAC incrementally. The message bit at position i
+// selects the keystream bits i..i+31; the keystream is kept as a 64-bit
+// window starting at the current message word.
+type mac struct {
+	initial state
+	st      state
+	k0, k1  uint32
+	t       uint32
+	buf     [4]byte
+	n       int
+	len     uint64
+}
+
+// NewMAC returns a hash.Hash computing a 32-bit MAC under key and iv: the
+// ZUC-128 MAC of 128-EIA3 for a 16-byte key and 16-byte iv, or the ZUC-256
+// MAC for a 32-byte key and 25-byte iv. The message is the sequence of bytes
+// written; use EIA3 for messages whose length is not a multiple of 8 bits.
+func NewMAC(key, iv []byte) (hash.Hash, error) {
+	if len(key) == KeySize256 {
+		return NewMAC256(key, iv, MACSize)
+	}
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(iv) != IVSize {
+		return nil, IVSizeError(len(iv))
+	}
+	return newMAC(key, iv), nil
+}
+
+func newMAC(key, iv []byte) *mac {
+	m := &mac{initial: *newState128(key, iv)}
+	m.Reset()
+	return m
+}
+
+func (m *mac) Size() int      { return MACSize }
+func (m *mac) BlockSize() int { return 4 }
+
+func (m *mac) Reset() {
+	m.st = m.initial
+	m.k0 = m.st.next()
+	m.k1 = m.st.next()
+	m.t = 0
+	m.n = 0
+	m.len = 0
+}
+
+// window returns the keystream word starting i bits into the window.
+func (m *mac) window(i uint) uint32 {
+	if i == 0 {
+		return m.k0
+	}
+	return m.k0<<i | m.k1>>(32-i)
+}
+
+// block absorbs the first bits bits of the message word w.
+func (m *mac) block(w uint32, bits uint) {
+	for i := uint(0); i < bits; i++ {
+		if w&(0x80000000>>i) != 0 {
+			m.t ^= m.window(i)
+		}
+	}
+}
+
+func (m *mac) Write(p []byte) (int, error) {
+	n := len(p)
+	m.len += uint64(n)
+	if m.n > 0 {
+		c := copy(m.buf[m.n:], p)
+		m.n += c
+		p = p[c:]
+		if m.n < 4 {
+			return n, nil
+		}
+		m.block(binary.BigEndian.Uint32(m.buf[:]), 32)
+		m.k0, m.k1 = m.k1, m.st.next()
+		m.n = 0
+	}
+	for len(p) >= 4 {
+		m.block(binary.BigEndian.Uint32(p), 32)
+		m.k0, m.k1 = m.k1, m.st.next()
+		p = p[4:]
+	}
+	m.n = copy(m.buf[:], p)
+	return n, nil
+}
+
+// checksum returns the MAC of the message written so far followed by the
+// top tailBits bits of tail.
+func (m *mac) checksum(tail byte, tailBits uint) uint32 {
+	var buf [4]byte
+	copy(buf[:], m.buf[:m.n])
+	if tailBits > 0 {
+		buf[m.n] = tail
+	}
+	r := 8*uint(m.n) + tailBits
+	m.block(binary.BigEndian.Uint32(buf[:]), r)
+
+	// T ^= z_LENGTH, then MAC = T ^ z_{32(L-1)} with L = ceil(LENGTH/32) + 2.
+	t := m.t ^ m.window(r)
+	if r == 0 {
+		return t ^ m.k1
+	}
+	return t ^ m.st.next()
+}
+
+func (m *mac) Sum(in []byte) []byte {
+	d := *m
+	var out [MACSize]byte
+	binary.BigEndian.PutUint32(out[:], d.checksum(0, 0))
+	return append(in, out[:]...)
+}
+
+// EIA3 returns the 128-EIA3 MAC of the first bits bits of msg under the
+// integrity key ik and the given COUNT, 5-bit BEARER and 1-bit DIRECTION.
+func EIA3(ik []byte, count uint32, bearer, direction uint8, msg []byte, bits int) (uint32, error) {
+	if bits < 0 || bits > 8*len(msg) {
+		panic("zuc: invalid bit length")
+	}
+	if len(ik) != KeySize {
+		return 0, KeySizeError(len(ik))
+	}
+	m := newMAC(ik, eia3IV(count, bearer, direction))
+	m.Write(msg[:bits/8])
+	if r := uint(bits % 8); r != 0 {
+		return m.checksum(msg[bits/8], r), nil
+	}
+	return m.checksum(0, 0), nil
+}
@@ -0,0 +1,243 @@
+// Package zuc implements the ZUC stream cipher as defined in GB/T 33133-2016
+// and the 3GPP confidentiality and integrity algorithms 128-EEA3 and 128-EIA3
+// built on it.
+//
+// ZUC-128 takes a 128-bit key and a 128-bit IV, and ZUC-256 a 256-bit key and
+// a 184-bit IV; both share the same LFSR and nonlinear function and produce a
+// keystream of 32-bit words. The Stream returned by NewCipher implements
+// cipher.Stream.
+package zuc
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"strconv"
+)
+
+// KeySize is the ZUC-128 key size in bytes.
+const KeySize = 16
+
+// IVSize is the ZUC-128 IV size in bytes.
+const IVSize = 16
+
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "zuc: invalid key size " + strconv.Itoa(int(k))
+}
+
+type IVSizeError int
+
+func (k IVSizeError) Error() string {
+	return "zuc: invalid IV size " + strconv.Itoa(int(k))
+}
+
+var s0 = [256]byte{
+	0x3e, 0x72, 0x5b, 0x47, 0xca, 0xe0, 0x00, 0x33, 0x04, 0xd1, 0x54, 0x98, 0x09, 0xb9, 0x6d, 0xcb,
+	0x7b, 0x1b, 0xf9, 0x32, 0xaf, 0x9d, 0x6a, 0xa5, 0xb8, 0x2d, 0xfc, 0x1d, 0x08, 0x53, 0x03, 0x90,
+	0x4d, 0x4e, 0x84, 0x99, 0xe4, 0xce, 0xd9, 0x91, 0xdd, 0xb6, 0x85, 0x48, 0x8b, 0x29, 0x6e, 0xac,
+	0xcd, 0xc1, 0xf8, 0x1e, 0x73, 0x43, 0x69, 0xc6, 0xb5, 0xbd, 0xfd, 0x39, 0x63, 0x20, 0xd4, 0x38,
+	0x76, 0x7d, 0xb2, 0xa7, 0xcf, 0xed, 0x57, 0xc5, 0xf3, 0x2c, 0xbb, 0x14, 0x21, 0x06, 0x55, 0x9b,
+	0xe3, 0xef, 0x5e, 0x31, 0x4f, 0x7f, 0x5a, 0xa4, 0x0d, 0x82, 0x51, 0x49, 0x5f, 0xba, 0x58, 0x1c,
+	0x4a, 0x16, 0xd5, 0x17, 0xa8, 0x92, 0x24, 0x1f, 0x8c, 0xff, 0xd8, 0xae, 0x2e, 0x01, 0xd3, 0xad,
+	0x3b, 0x4b, 0xda, 0x46, 0xeb, 0xc9, 0xde, 0x9a, 0x8f, 0x87, 0xd7, 0x3a, 0x80, 0x6f, 0x2f, 0xc8,
+	0xb1, 0xb4, 0x37, 0xf7, 0x0a, 0x22, 0x13, 0x28, 0x7c, 0xcc, 0x3c, 0x89, 0xc7, 0xc3, 0x96, 0x56,
+	0x07, 0xbf, 0x7e, 0xf0, 0x0b, 0x2b, 0x97, 0x52, 0x35, 0x41, 0x79, 0x61, 0xa6, 0x4c, 0x10, 0xfe,
+	0xbc, 0x26, 0x95, 0x88, 0x8a, 0xb0, 0xa3, 0xfb, 0xc0, 0x18, 0x94, 0xf2, 0xe1, 0xe5, 0xe9, 0x5d,
+	0xd0, 0xdc, 0x11, 0x66, 0x64, 0x5c, 0xec, 0x59, 0x42, 0x75, 0x12, 0xf5, 0x74, 0x9c, 0xaa, 0x23,
+	0x0e, 0x86, 0xab, 0xbe, 0x2a, 0x02, 0xe7, 0x67, 0xe6, 0x44, 0xa2, 0x6c, 0xc2, 0x93, 0x9f, 0xf1,
+	0xf6, 0xfa, 0x36, 0xd2, 0x50, 0x68, 0x9e, 0x62, 0x71, 0x15, 0x3d, 0xd6, 0x40, 0xc4, 0xe2, 0x0f,
+	0x8e, 0x83, 0x77, 0x6b, 0x25, 0x05, 0x3f, 0x0c, 0x30, 0xea, 0x70, 0xb7, 0xa1, 0xe8, 0xa9, 0x65,
+	0x8d, 0x27, 0x1a, 0xdb, 0x81, 0xb3, 0xa0, 0xf4, 0x45, 0x7a, 0x19, 0xdf, 0xee, 0x78, 0x34, 0x60,
+}
+
+var s1 = [256]byte{
+	0x55, 0xc2, 0x63, 0x71, 0x3b, 0xc8, 0x47, 0x86, 0x9f, 0x3c, 0xda, 0x5b, 0x29, 0xaa, 0xfd, 0x77,
+	0x8c, 0xc5, 0x94, 0x0c, 0xa6, 0x1a, 0x13, 0x00, 0xe3, 0xa8, 0x16, 0x72, 0x40, 0xf9, 0xf8, 0x42,
+	0x44, 0x26, 0x68, 0x96, 0x81, 0xd9, 0x45, 0x3e, 0x10, 0x76, 0xc6, 0xa7, 0x8b, 0x39, 0x43, 0xe1,
+	0x3a, 0xb5, 0x56, 0x2a, 0xc0, 0x6d, 0xb3, 0x05, 0x22, 0x66, 0xbf, 0xdc, 0x0b, 0xfa, 0x62, 0x48,
+	0xdd, 0x20, 0x11, 0x06, 0x36, 0xc9, 0xc1, 0xcf, 0xf6, 0x27, 0x52, 0xbb, 0x69, 0xf5, 0xd4, 0x87,
+	0x7f, 0x84, 0x4c, 0xd2, 0x9c, 0x57, 0xa4, 0xbc, 0x4f, 0x9a, 0xdf, 0xfe, 0xd6, 0x8d, 0x7a, 0xeb,
+	0x2b, 0x53, 0xd8, 0x5c, 0xa1, 0x14, 0x17, 0xfb, 0x23, 0xd5, 0x7d, 0x30, 0x67, 0x73, 0x08, 0x09,
+	0xee, 0xb7, 0x70, 0x3f, 0x61, 0xb2, 0x19, 0x8e, 0x4e, 0xe5, 0x4b, 0x93, 0x8f, 0x5d, 0xdb, 0xa9,
+	0xad, 0xf1, 0xae, 0x2e, 0xcb, 0x0d, 0xfc, 0xf4, 0x2d, 0x46, 0x6e, 0x1d, 0x97, 0xe8, 0xd1, 0xe9,
+	0x4d, 0x37, 0xa5, 0x75, 0x5e, 0x83, 0x9e, 0xab, 0x82, 0x9d, 0xb9, 0x1c, 0xe0, 0xcd, 0x49, 0x89,
+	0x01, 0xb6, 0xbd, 0x58, 0x24, 0xa2, 0x5f, 0x38, 0x78, 0x99, 0x15, 0x90, 0x50, 0xb8, 0x95, 0xe4,
+	0xd0, 0x91, 0xc7, 0xce, 0xed, 0x0f, 0xb4, 0x6f, 0xa0, 0xcc, 0xf0, 0x02, 0x4a, 0x79, 0xc3, 0xde,
+	0xa3, 0xef, 0xea, 0x51, 0xe6, 0x6b, 0x18, 0xec, 0x1b, 0x2c, 0x80, 0xf7, 0x74, 0xe7, 0xff, 0x21,
+	0x5a, 0x6a, 0x54, 0x1e, 0x41, 0x31, 0x92, 0x35, 0xc4, 0x33, 0x07, 0x0a, 0xba, 0x7e, 0x0e, 0x34,
+	0x88, 0xb1, 0x98, 0x7c, 0xf3, 0x3d, 0x60, 0x6c, 0x7b, 0xca, 0xd3, 0x1f, 0x32, 0x65, 0x04, 0x28,
+	0x64, 0xbe, 0x85, 0x9b, 0x2f, 0x59, 0x8a, 0xd7, 0xb0, 0x25, 0xac, 0xaf, 0x12, 0x03, 0xe2, 0xf2,
+}
+
+// d holds the 15-bit constants loaded into the LFSR during ZUC-128 key
+// setup.
+var d = [16]uint32{
+	0x44d7, 0x26bc, 0x626b, 0x135e, 0x5789, 0x35e2, 0x7135, 0x09af,
+	0x4d78, 0x2f13, 0x6bc4, 0x1af1, 0x5e26, 0x3c4d, 0x789a, 0x47ac,
+}
+
+// state is the ZUC state: a linear feedback shift register of sixteen 31-bit
+// cells over GF(2^31-1) and the two 32-bit memory cells of the nonlinear
+// function F.
+type state struct {
+	s      [16]uint32
+	r1, r2 uint32
+	// x0..x3 are the outputs of the bit reorganization.
+	x0, x1, x2, x3 uint32
+}
+
+// addMod adds two elements of GF(2^31-1).
+func addMod(a, b uint32) uint32 {
+	c := a + b
+	return (c & 0x7fffffff) + (c >> 31)
+}
+
+// rotMod multiplies an element of GF(2^31-1) by 2^k.
+func rotMod(a uint32, k uint) uint32 {
+	return ((a << k) | (a >> (31 - k))) & 0x7fffffff
+}
+
+func rotl(x uint32, k uint) uint32 {
+	return x<<k | x>>(32-k)
+}
+
+func l1(x uint32) uint32 {
+	return x ^ rotl(x, 2) ^ rotl(x, 10) ^ rotl(x, 18) ^ rotl(x, 24)
+}
+
+func l2(x uint32) uint32 {
+	return x ^ rotl(x, 8) ^ rotl(x, 14) ^ rotl(x, 22) ^ rotl(x, 30)
+}
+
+func sbox(x uint32) uint32 {
+	return uint32(s0[x>>24])<<24 | uint32(s1[x>>16&0xff])<<16 |
+		uint32(s0[x>>8&0xff])<<8 | uint32(s1[x&0xff])
+}
+
+func (st *state) bitReorganization() {
+	s := &st.s
+	st.x0 = (s[15]&0x7fff8000)<<1 | s[14]&0xffff
+	st.x1 = (s[11]&0xffff)<<16 | s[9]>>15
+	st.x2 = (s[7]&0xffff)<<16 | s[5]>>15
+	st.x3 = (s[2]&0xffff)<<16 | s[0]>>15
+}
+
+func (st *state) f() uint32 {
+	w := (st.x0 ^ st.r1) + st.r2
+	w1 := st.r1 + st.x1
+	w2 := st.r2 ^ st.x2
+	st.r1 = sbox(l1(w1<<16 | w2>>16))
+	st.r2 = sbox(l2(w2<<16 | w1>>16))
+	return w
+}
+
+// lfsr clocks the LFSR, adding u to the feedback. u is zero in working
+// mode.
+func (st *state) lfsr(u uint32) {
+	s := &st.s
+	v := s[0]
+	v = addMod(v, rotMod(s[0], 8))
+	v = addMod(v, rotMod(s[4], 20))
+	v = addMod(v, rotMod(s[10], 21))
+	v = addMod(v, rotMod(s[13], 17))
+	v = addMod(v, rotMod(s[15], 15))
+	v = addMod(v, u)
+	if v == 0 {
+		v = 0x7fffffff
+	}
+	copy(s[:15], s[1:])
+	s[15] = v
+}
+
+// init runs the initialization rounds on a loaded LFSR.
+func (st *state) init(rounds int) {
+	st.r1, st.r2 = 0, 0
+	for i := 0; i < rounds; i++ {
+		st.bitReorganization()
+		st.lfsr(st.f() >> 1)
+	}
+	st.bitReorganization()
+	st.f()
+	st.lfsr(0)
+}
+
+// next returns the next keystream word.
+func (st *state) next() uint32 {
+	st.bitReorganization()
+	z := st.f() ^ st.x3
+	st.lfsr(0)
+	return z
+}
+
+func newState128(key, iv []byte) *state {
+	st := new(state)
+	for i := range st.s {
+		st.s[i] = uint32(key[i])<<23 | d[i]<<8 | uint32(iv[i])
+	}
+	st.init(32)
+	return st
+}
+
+// Stream generates a ZUC keystream. It implements cipher.Stream.
+type Stream struct {
+	st  *state
+	buf [4]byte
+	// off is the number of bytes of buf already used.
+	off int
+}
+
+// NewCipher returns a cipher.Stream for key and iv: ZUC-128 for a 16-byte key
+// and 16-byte iv, ZUC-256 for a 32-byte key and 25-byte iv.
+func NewCipher(key, iv []byte) (*Stream, error) {
+	if len(key) == KeySize256 {
+		if err := checkKeyIV256(key, iv); err != nil {
+			return nil, err
+		}
+		return &Stream{st: newState256(key, iv, &d256), off: 4}, nil
+	}
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(iv) != IVSize {
+		return nil, IVSizeError(len(iv))
+	}
+	return &Stream{st: newState128(key, iv), off: 4}, nil
+}
+
+// KeyStream fills words with the next keystream words. It must not be mixed
+// with XORKeyStream unless the stream is at a word boundary.
+func (c *Stream) KeyStream(words []uint32) {
+	for i := range words {
+		words[i] = c.st.next()
+	}
+}
+
+// XORKeyStream XORs each byte in src with a byte from the keystream, taking
+// the keystream words in big-endian order. Dst and src must overlap entirely
+// or not at all.
+func (c *Stream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("zuc: output smaller than input")
+	}
+	for len(src) > 0 && c.off < 4 {
+		dst[0] = src[0] ^ c.buf[c.off]
+		c.off++
+		dst, src = dst[1:], src[1:]
+	}
+	for len(src) >= 4 {
+		k := c.st.next()
+		binary.BigEndian.PutUint32(dst, binary.BigEndian.Uint32(src)^k)
+		dst, src = dst[4:], src[4:]
+	}
+	if len(src) > 0 {
+		binary.BigEndian.PutUint32(c.buf[:], c.st.next())
+		c.off = 0
+		for i := range src {
+			dst[i] = src[i] ^ c.buf[i]
+		}
+		c.off = len(src)
+	}
+}
+
+var _ cipher.Stream = (*Stream)(nil)
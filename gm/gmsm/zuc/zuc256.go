@@ -0,0 +1,187 @@
+package zuc
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// KeySize256 is the ZUC-256 key size in bytes.
+const KeySize256 = 32
+
+// IVSize256 is the ZUC-256 IV size in bytes. The IV has 184 bits: bytes 0 to
+// 16 are full bytes and bytes 17 to 24 carry 6 bits each.
+const IVSize256 = 25
+
+var errIV256 = errors.New("zuc: ZUC-256 IV bytes 17 to 24 must be 6-bit values")
+
+// d256 holds the 7-bit constants loaded into the LFSR by ZUC-256 for
+// keystream generation. d256MAC holds the constants used for MACs with 32,
+// 64 and 128-bit tags.
+var (
+	d256 = [16]uint32{
+		0x22, 0x2f, 0x24, 0x2a, 0x6d, 0x40, 0x40, 0x40,
+		0x40, 0x40, 0x40, 0x40, 0x40, 0x52, 0x10, 0x30,
+	}
+	d256MAC = [3][16]uint32{
+		{0x22, 0x2f, 0x25, 0x2a, 0x6d, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x52, 0x10, 0x30},
+		{0x23, 0x2f, 0x24, 0x2a, 0x6d, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x52, 0x10, 0x30},
+		{0x23, 0x2f, 0x25, 0x2a, 0x6d, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x40, 0x52, 0x10, 0x30},
+	}
+)
+
+func checkKeyIV256(key, iv []byte) error {
+	if len(key) != KeySize256 {
+		return KeySizeError(len(key))
+	}
+	if len(iv) != IVSize256 {
+		return IVSizeError(len(iv))
+	}
+	for _, b := range iv[17:] {
+		if b > 0x3f {
+			return errIV256
+		}
+	}
+	return nil
+}
+
+func newState256(key, iv []byte, d *[16]uint32) *state {
+	k, v := key, iv
+	cell := func(a byte, d uint32, b, c byte) uint32 {
+		return uint32(a)<<23 | d<<16 | uint32(b)<<8 | uint32(c)
+	}
+	st := new(state)
+	st.s = [16]uint32{
+		cell(k[0], d[0], k[21], k[16]),
+		cell(k[1], d[1], k[22], k[17]),
+		cell(k[2], d[2], k[23], k[18]),
+		cell(k[3], d[3], k[24], k[19]),
+		cell(k[4], d[4], k[25], k[20]),
+		cell(v[0], d[5]|uint32(v[17]), k[5], k[26]),
+		cell(v[1], d[6]|uint32(v[18]), k[6], k[27]),
+		cell(v[10], d[7]|uint32(v[19]), k[7], v[2]),
+		cell(k[8], d[8]|uint32(v[20]), v[3], v[11]),
+		cell(k[9], d[9]|uint32(v[21]), v[12], v[4]),
+		cell(v[5], d[10]|uint32(v[22]), k[10], k[28]),
+		cell(k[11], d[11]|uint32(v[23]), v[6], v[13]),
+		cell(k[12], d[12]|uint32(v[24]), v[7], v[14]),
+		cell(k[13], d[13], v[15], v[8]),
+		cell(k[14], d[14]|uint32(k[31]>>4), v[16], v[9]),
+		cell(k[15], d[15]|uint32(k[31]&0x0f), k[30], k[29]),
+	}
+	st.init(32)
+	return st
+}
+
+// mac256 computes a ZUC-256 MAC of 1 to 4 words. The tag starts as the first
+// keystream bits; the message bit at position i selects the keystream bits
+// t+i..2t+i-1, which are kept as a window of tag+1 words starting at the
+// current message word.
+type mac256 struct {
+	initial state
+	st      state
+	words   int
+	tag     [4]uint32
+	k       [5]uint32
+	buf     [4]byte
+	n       int
+}
+
+// NewMAC256 returns a hash.Hash computing the ZUC-256 MAC under the 32-byte
+// key and 25-byte iv. tagSize is the MAC size in bytes and must be 4, 8 or
+// 16.
+func NewMAC256(key, iv []byte, tagSize int) (hash.Hash, error) {
+	if err := checkKeyIV256(key, iv); err != nil {
+		return nil, err
+	}
+	var d *[16]uint32
+	switch tagSize {
+	case 4:
+		d = &d256MAC[0]
+	case 8:
+		d = &d256MAC[1]
+	case 16:
+		d = &d256MAC[2]
+	default:
+		return nil, errors.New("zuc: ZUC-256 MAC size must be 4, 8 or 16 bytes")
+	}
+	m := &mac256{initial: *newState256(key, iv, d), words: tagSize / 4}
+	m.Reset()
+	return m, nil
+}
+
+func (m *mac256) Size() int      { return 4 * m.words }
+func (m *mac256) BlockSize() int { return 4 }
+
+func (m *mac256) Reset() {
+	m.st = m.initial
+	for i := 0; i < m.words; i++ {
+		m.tag[i] = m.st.next()
+	}
+	for i := 0; i <= m.words; i++ {
+		m.k[i] = m.st.next()
+	}
+	m.n = 0
+}
+
+// xorWindow XORs the tag-sized keystream word starting i bits into the
+// window into the tag.
+func (m *mac256) xorWindow(i uint) {
+	for j := 0; j < m.words; j++ {
+		if i == 0 {
+			m.tag[j] ^= m.k[j]
+		} else {
+			m.tag[j] ^= m.k[j]<<i | m.k[j+1]>>(32-i)
+		}
+	}
+}
+
+// block absorbs the first bits bits of the message word w.
+func (m *mac256) block(w uint32, bits uint) {
+	for i := uint(0); i < bits; i++ {
+		if w&(0x80000000>>i) != 0 {
+			m.xorWindow(i)
+		}
+	}
+}
+
+func (m *mac256) advance() {
+	copy(m.k[:m.words], m.k[1:m.words+1])
+	m.k[m.words] = m.st.next()
+}
+
+func (m *mac256) Write(p []byte) (int, error) {
+	n := len(p)
+	if m.n > 0 {
+		c := copy(m.buf[m.n:], p)
+		m.n += c
+		p = p[c:]
+		if m.n < 4 {
+			return n, nil
+		}
+		m.block(binary.BigEndian.Uint32(m.buf[:]), 32)
+		m.advance()
+		m.n = 0
+	}
+	for len(p) >= 4 {
+		m.block(binary.BigEndian.Uint32(p), 32)
+		m.advance()
+		p = p[4:]
+	}
+	m.n = copy(m.buf[:], p)
+	return n, nil
+}
+
+func (m *mac256) Sum(in []byte) []byte {
+	d := *m
+	var buf [4]byte
+	copy(buf[:], d.buf[:d.n])
+	r := 8 * uint(d.n)
+	d.block(binary.BigEndian.Uint32(buf[:]), r)
+	d.xorWindow(r)
+
+	for i := 0; i < d.words; i++ {
+		in = append(in, byte(d.tag[i]>>24), byte(d.tag[i]>>16), byte(d.tag[i]>>8), byte(d.tag[i]))
+	}
+	return in
+}
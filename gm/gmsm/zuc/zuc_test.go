@@ -0,0 +1,259 @@
+package zuc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func decodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// GB/T 33133-2016 Appendix A
+func TestKeyStream(t *testing.T) {
+	tests := []struct {
+		key, iv string
+		want    [2]uint32
+	}{
+		{
+			"00000000000000000000000000000000", "00000000000000000000000000000000",
+			[2]uint32{0x27bede74, 0x018082da},
+		},
+		{
+			"ffffffffffffffffffffffffffffffff", "ffffffffffffffffffffffffffffffff",
+			[2]uint32{0x0657cfa0, 0x7096398b},
+		},
+	}
+	for i, test := range tests {
+		c, err := NewCipher(decodeHex(test.key), decodeHex(test.iv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var z [2]uint32
+		c.KeyStream(z[:])
+		if z != test.want {
+			t.Errorf("#%d: keystream %08x, want %08x", i, z, test.want)
+		}
+	}
+}
+
+// 3GPP 128-EEA3 & 128-EIA3 Implementers' Test Data, EEA3 test set 1
+func TestEEA3(t *testing.T) {
+	key := decodeHex("173d14ba5003731d7a60049470f00a29")
+	pt := decodeHex("6cf65340735552ab0c9752fa6f9025fe0bd675d9005875b200000000")
+	want := decodeHex("a6c85fc66afb8533aafc2518dfe784940ee1e4b030238cc800000000")
+
+	ct, err := EEA3(key, 0x66035492, 0xf, 0, pt, 193)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ct, want[:len(ct)]) {
+		t.Fatalf("EEA3 = %x, want %x", ct, want[:len(ct)])
+	}
+	back, err := EEA3(key, 0x66035492, 0xf, 0, ct, 193)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, pt[:len(back)]) {
+		t.Fatalf("EEA3 decryption = %x, want %x", back, pt[:len(back)])
+	}
+}
+
+func TestXORKeyStreamSplit(t *testing.T) {
+	key := decodeHex("3d4c4be96a82fdaeb58f641db17b455b")
+	iv := decodeHex("84319aa8de6938a1a27df0f8c1afb0f4")
+	msg := make([]byte, 97)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+
+	c, _ := NewCipher(key, iv)
+	want := make([]byte, len(msg))
+	c.XORKeyStream(want, msg)
+
+	for _, step := range []int{1, 3, 5, 17} {
+		c, _ := NewCipher(key, iv)
+		got := make([]byte, len(msg))
+		for i := 0; i < len(msg); i += step {
+			j := i + step
+			if j > len(msg) {
+				j = len(msg)
+			}
+			c.XORKeyStream(got[i:j], msg[i:j])
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("step %d: %x, want %x", step, got, want)
+		}
+	}
+}
+
+func TestBadSizes(t *testing.T) {
+	if _, err := NewCipher(make([]byte, 15), make([]byte, IVSize)); err != KeySizeError(15) {
+		t.Errorf("NewCipher with short key: %v", err)
+	}
+	if _, err := NewCipher(make([]byte, KeySize), make([]byte, 8)); err != IVSizeError(8) {
+		t.Errorf("NewCipher with short IV: %v", err)
+	}
+}
+
+func BenchmarkXORKeyStream(b *testing.B) {
+	c, _ := NewCipher(make([]byte, KeySize), make([]byte, IVSize))
+	buf := make([]byte, 1024)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		c.XORKeyStream(buf, buf)
+	}
+}
+
+// 3GPP 128-EEA3 & 128-EIA3 Implementers' Test Data, EIA3 test sets 1 and 2
+func TestEIA3(t *testing.T) {
+	tests := []struct {
+		key       string
+		count     uint32
+		bearer    uint8
+		direction uint8
+		bits      int
+		msg       string
+		want      uint32
+	}{
+		{"00000000000000000000000000000000", 0, 0, 0, 1, "00000000", 0xc8a9595e},
+		{"47054125561eb2dda94059da05097850", 0x561eb2dd, 0x14, 0, 90, "000000000000000000000000", 0x6719a088},
+	}
+	for i, test := range tests {
+		got, err := EIA3(decodeHex(test.key), test.count, test.bearer, test.direction, decodeHex(test.msg), test.bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("#%d: MAC %08x, want %08x", i, got, test.want)
+		}
+	}
+}
+
+func TestMACWrite(t *testing.T) {
+	key := decodeHex("47054125561eb2dda94059da05097850")
+	iv := eia3IV(0x561eb2dd, 0x14, 1)
+	msg := make([]byte, 71)
+	for i := range msg {
+		msg[i] = byte(i * 7)
+	}
+
+	for n := 0; n <= len(msg); n++ {
+		want, _ := EIA3(key, 0x561eb2dd, 0x14, 1, msg[:n], 8*n)
+
+		h, err := NewMAC(key, iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i += 3 {
+			j := i + 3
+			if j > n {
+				j = n
+			}
+			h.Write(msg[i:j])
+			h.Sum(nil)
+		}
+		got := h.Sum(nil)
+		if w := []byte{byte(want >> 24), byte(want >> 16), byte(want >> 8), byte(want)}; !bytes.Equal(got, w) {
+			t.Fatalf("%d bytes: MAC %x, want %x", n, got, w)
+		}
+		h.Reset()
+		h.Write(msg[:n])
+		if !bytes.Equal(h.Sum(nil), got) {
+			t.Fatalf("%d bytes: MAC differs after Reset", n)
+		}
+	}
+}
+
+func fill(n int, b byte) []byte {
+	return bytes.Repeat([]byte{b}, n)
+}
+
+// The ZUC-256 Stream Cipher, test vectors for keystream generation
+func TestKeyStream256(t *testing.T) {
+	tests := []struct {
+		key, iv []byte
+		want    string
+	}{
+		{
+			fill(KeySize256, 0), fill(IVSize256, 0),
+			"58d03ad62e032ce2dafc683a39bdcb0352a2bc67f1b7de74163ce3a101ef5558" +
+				"9639d75b95fa681b7f090df756391ccc903b7612744d544c17bc3fad8b163b08" +
+				"21787c0b97775bb84943c6bbe8ad8afd",
+		},
+		{
+			fill(KeySize256, 0xff), append(fill(17, 0xff), fill(8, 0x3f)...),
+			"3356cbaed1a1c18b6baa4ffe343f777c9e15128f251ab65b949f7b26ef7157f2" +
+				"96dd2fa9df95e3ee7a5be02ec32ba585505af316c2f9ded27cdbd935e441ce11" +
+				"15fd0a80bb7aef6768989416b8fac8c2",
+		},
+	}
+	for i, test := range tests {
+		c, err := NewCipher(test.key, test.iv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := decodeHex(test.want)
+		got := make([]byte, len(want))
+		c.XORKeyStream(got, got)
+		if !bytes.Equal(got, want) {
+			t.Errorf("#%d: keystream %x, want %x", i, got, want)
+		}
+	}
+}
+
+// The ZUC-256 Stream Cipher, test vectors for MAC generation
+func TestMAC256(t *testing.T) {
+	zeroKey, zeroIV := fill(KeySize256, 0), fill(IVSize256, 0)
+	onesKey, onesIV := fill(KeySize256, 0xff), append(fill(17, 0xff), fill(8, 0x3f)...)
+	tests := []struct {
+		key, iv, msg []byte
+		want         [3]string
+	}{
+		{
+			zeroKey, zeroIV, fill(50, 0),
+			[3]string{"9b972a74", "673e54990034d38c", "d85e54bbcb9600967084c952a1654b26"},
+		},
+		{
+			zeroKey, zeroIV, fill(500, 0x11),
+			[3]string{"8754f5cf", "130dc225e72240cc", "df1e8307b31cc62beca1ac6f8190c22f"},
+		},
+		{
+			onesKey, onesIV, fill(50, 0),
+			[3]string{"1f3079b4", "8c71394d39957725", "a35bb274b567c48b28319f111af34fbd"},
+		},
+	}
+	for i, test := range tests {
+		for j, size := range []int{4, 8, 16} {
+			h, err := NewMAC256(test.key, test.iv, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.Write(test.msg[:7])
+			h.Write(test.msg[7:])
+			if got, want := h.Sum(nil), decodeHex(test.want[j]); !bytes.Equal(got, want) {
+				t.Errorf("#%d: %d-byte MAC %x, want %x", i, size, got, want)
+			}
+		}
+	}
+}
+
+func TestBadSizes256(t *testing.T) {
+	if _, err := NewCipher(make([]byte, KeySize256), make([]byte, IVSize)); err != IVSizeError(IVSize) {
+		t.Errorf("NewCipher with short IV: %v", err)
+	}
+	if _, err := NewCipher(make([]byte, KeySize256), fill(IVSize256, 0x40)); err != errIV256 {
+		t.Errorf("NewCipher with 7-bit IV bytes: %v", err)
+	}
+	if _, err := NewMAC256(make([]byte, KeySize256), make([]byte, IVSize256), 12); err == nil {
+		t.Error("NewMAC256 accepted a 12-byte tag")
+	}
+	if _, err := NewEEA3(make([]byte, KeySize256), 0, 0, 0); err != KeySizeError(KeySize256) {
+		t.Errorf("NewEEA3 with 32-byte key: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package hardwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/xuperchain/crypto/gm/account"
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+	"github.com/xuperchain/crypto/gm/hdwallet/keychain"
+)
+
+// APDU instructions of the reference device application.
+//
+// GET PUBLIC KEY (INS 0x02), P1 = 0x01 to display and confirm the address:
+//
+//	data:     path
+//	response: public key (65 bytes, uncompressed) || address length (1) || address
+//
+// SIGN DIGEST (INS 0x04), always confirmed on the device:
+//
+//	data:     path || digest
+//	response: signature (DER, as sm2.SignDigitToSignData)
+//
+// where path = count (1) || index (4, big-endian) * count. Every response
+// ends with a status word, 0x9000 for success.
+const (
+	claSM2 = 0xE0
+
+	insGetPublicKey = 0x02
+	insSignDigest   = 0x04
+
+	p1NoConfirm = 0x00
+	p1Confirm   = 0x01
+
+	swOK       = 0x9000
+	swRejected = 0x6985
+
+	// maxAPDUData is the largest data field of a short APDU.
+	maxAPDUData = 255
+)
+
+// StatusError is returned when the device answers with an error status word.
+type StatusError struct {
+	SW uint16
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("hardwallet: device returned status 0x%04x", e.SW)
+}
+
+// Transport exchanges APDUs with a device, e.g. over USB HID with
+// NewHIDTransport.
+type Transport interface {
+	// Exchange sends a command APDU and returns the response APDU,
+	// including the trailing status word.
+	Exchange(apdu []byte) ([]byte, error)
+	Close() error
+}
+
+// APDUDevice is a Device that drives the reference device application over
+// a Transport. It is safe for concurrent use; commands are serialized.
+type APDUDevice struct {
+	mu        sync.Mutex
+	transport Transport
+}
+
+// NewAPDUDevice returns a Device that speaks APDUs over transport.
+func NewAPDUDevice(transport Transport) *APDUDevice {
+	return &APDUDevice{transport: transport}
+}
+
+// exchange sends one command and returns the response data without the
+// status word.
+func (d *APDUDevice) exchange(ins, p1 byte, data []byte) ([]byte, error) {
+	if len(data) > maxAPDUData {
+		return nil, fmt.Errorf("hardwallet: APDU data too long: %d bytes", len(data))
+	}
+	apdu := append([]byte{claSM2, ins, p1, 0x00, byte(len(data))}, data...)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.transport == nil {
+		return nil, ErrClosed
+	}
+	resp, err := d.transport.Exchange(apdu)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 {
+		return nil, ErrInvalidResponse
+	}
+
+	switch sw := binary.BigEndian.Uint16(resp[len(resp)-2:]); sw {
+	case swOK:
+		return resp[:len(resp)-2], nil
+	case swRejected:
+		return nil, ErrRejected
+	default:
+		return nil, &StatusError{SW: sw}
+	}
+}
+
+func encodePath(path string) ([]byte, error) {
+	indexes, err := keychain.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	// The path and, for signing, the digest must fit into one APDU.
+	if 1+4*len(indexes) > maxAPDUData {
+		return nil, keychain.ErrInvalidPath
+	}
+
+	b := make([]byte, 1+4*len(indexes))
+	b[0] = byte(len(indexes))
+	for i, index := range indexes {
+		binary.BigEndian.PutUint32(b[1+4*i:], index)
+	}
+	return b, nil
+}
+
+func (d *APDUDevice) getPublicKey(path string, p1 byte) (*ecdsa.PublicKey, string, error) {
+	data, err := encodePath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := d.exchange(insGetPublicKey, p1, data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(resp) < 66 || len(resp) != 66+int(resp[65]) {
+		return nil, "", ErrInvalidResponse
+	}
+
+	curve := sm2.P256Sm2()
+	x, y := elliptic.Unmarshal(curve, resp[:65])
+	if x == nil {
+		return nil, "", ErrInvalidResponse
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, string(resp[66:]), nil
+}
+
+// PublicKey returns the public key of the node at path.
+func (d *APDUDevice) PublicKey(path string) (*ecdsa.PublicKey, error) {
+	publicKey, _, err := d.getPublicKey(path, p1NoConfirm)
+	return publicKey, err
+}
+
+// DisplayAddress shows the address of the key at path on the device and
+// returns it after the user confirms. The address reported by the device is
+// checked against its public key.
+func (d *APDUDevice) DisplayAddress(path string) (string, error) {
+	publicKey, address, err := d.getPublicKey(path, p1Confirm)
+	if err != nil {
+		return "", err
+	}
+	if ok, _ := account.VerifyAddressUsingPublicKey(address, publicKey); !ok {
+		return "", ErrInvalidResponse
+	}
+
+	return address, nil
+}
+
+// SignDigest signs the digest with the key at path after the user confirms
+// on the device.
+func (d *APDUDevice) SignDigest(path string, digest []byte) ([]byte, error) {
+	data, err := encodePath(path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.exchange(insSignDigest, p1Confirm, append(data, digest...))
+	if err != nil {
+		return nil, err
+	}
+
+	r, s, err := sm2.SignDataToSignDigit(resp)
+	if err != nil || r.Sign() <= 0 || s.Sign() <= 0 {
+		return nil, ErrInvalidResponse
+	}
+
+	return resp, nil
+}
+
+// Close closes the transport. Later calls return ErrClosed.
+func (d *APDUDevice) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.transport == nil {
+		return nil
+	}
+	err := d.transport.Close()
+	d.transport = nil
+	return err
+}
@@ -0,0 +1,266 @@
+package hardwallet
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/account"
+	"github.com/xuperchain/crypto/gm/config"
+	"github.com/xuperchain/crypto/gm/hdwallet/keychain"
+	"github.com/xuperchain/crypto/gm/sign"
+)
+
+// mockTransport plays the reference device application with an HD key tree
+// in memory.
+type mockTransport struct {
+	t      *testing.T
+	master *keychain.ExtendedKey
+	apdus  [][]byte
+	// If set, the response to the next command, status word included.
+	response []byte
+	// If set, replaces the address in GET PUBLIC KEY responses.
+	address string
+	closed  bool
+}
+
+func newMockTransport(t *testing.T) *mockTransport {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := keychain.NewMaster(seed, config.Gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &mockTransport{t: t, master: master}
+}
+
+func (m *mockTransport) key(path []byte) *keychain.ExtendedKey {
+	key := m.master
+	for i := 0; i < int(path[0]); i++ {
+		var err error
+		if key, err = key.Child(binary.BigEndian.Uint32(path[1+4*i:])); err != nil {
+			m.t.Fatal(err)
+		}
+	}
+	return key
+}
+
+func (m *mockTransport) Exchange(apdu []byte) ([]byte, error) {
+	m.apdus = append(m.apdus, append([]byte(nil), apdu...))
+	if m.response != nil {
+		resp := m.response
+		m.response = nil
+		return resp, nil
+	}
+	if len(apdu) < 5 || apdu[0] != claSM2 || apdu[3] != 0 || int(apdu[4]) != len(apdu)-5 {
+		return []byte{0x6a, 0x80}, nil
+	}
+	data := apdu[5:]
+	pathLen := 1 + 4*int(data[0])
+	key := m.key(data[:pathLen])
+	priv, err := key.ECPrivateKey()
+	if err != nil {
+		m.t.Fatal(err)
+	}
+
+	var resp []byte
+	switch apdu[1] {
+	case insGetPublicKey:
+		address, _ := account.GetAddressFromPublicKey(&priv.PublicKey)
+		if m.address != "" {
+			address = m.address
+		}
+		resp = elliptic.Marshal(priv.Curve, priv.X, priv.Y)
+		resp = append(resp, byte(len(address)))
+		resp = append(resp, address...)
+	case insSignDigest:
+		if resp, err = sign.SignECDSA(priv, data[pathLen:]); err != nil {
+			m.t.Fatal(err)
+		}
+	default:
+		return []byte{0x6d, 0x00}, nil
+	}
+	return append(resp, 0x90, 0x00), nil
+}
+
+func (m *mockTransport) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestAPDUDevice(t *testing.T) {
+	mock := newMockTransport(t)
+	device := NewAPDUDevice(mock)
+	const path = "m/44'/1"
+
+	pub, err := device.PublicKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAPDU := "e0020000" + "09" + "02" + "8000002c" + "00000001"
+	if got := hex.EncodeToString(mock.apdus[0]); got != wantAPDU {
+		t.Fatalf("GET PUBLIC KEY APDU = %s, want %s", got, wantAPDU)
+	}
+	key, _ := mock.master.Derive(path)
+	want, _ := key.ECPublicKey()
+	if pub.X.Cmp(want.X) != 0 || pub.Y.Cmp(want.Y) != 0 {
+		t.Fatal("wrong public key")
+	}
+
+	address, err := device.DisplayAddress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantAddress, _ := key.Address(); address != wantAddress {
+		t.Fatalf("address %s, want %s", address, wantAddress)
+	}
+	if mock.apdus[1][2] != p1Confirm {
+		t.Fatal("DisplayAddress does not ask for confirmation")
+	}
+
+	signer, err := NewSigner(device, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := bytes.Repeat([]byte{0xab}, 32)
+	sig, err := signer.Sign(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apdu := mock.apdus[len(mock.apdus)-1]
+	if !bytes.Equal(apdu[:5], []byte{claSM2, insSignDigest, p1Confirm, 0, 9 + 32}) || !bytes.Equal(apdu[14:], digest) {
+		t.Fatalf("SIGN DIGEST APDU = %x", apdu)
+	}
+	if ok, _ := sign.VerifyECDSA(signer.PublicKey(), sig, digest); !ok {
+		t.Fatal("signature does not verify")
+	}
+
+	if err := device.Close(); err != nil || !mock.closed {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := device.PublicKey(path); err != ErrClosed {
+		t.Fatalf("after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestAPDUDeviceErrors(t *testing.T) {
+	mock := newMockTransport(t)
+	device := NewAPDUDevice(mock)
+
+	mock.response = []byte{0x69, 0x85}
+	if _, err := device.SignDigest("m/0", make([]byte, 32)); err != ErrRejected {
+		t.Fatalf("rejected: got %v", err)
+	}
+	mock.response = []byte{0x6a, 0x82}
+	var statusErr *StatusError
+	if _, err := device.PublicKey("m/0"); !errors.As(err, &statusErr) || statusErr.SW != 0x6a82 {
+		t.Fatalf("error status: got %v", err)
+	}
+	mock.response = []byte{0x90}
+	if _, err := device.PublicKey("m/0"); err != ErrInvalidResponse {
+		t.Fatalf("short response: got %v", err)
+	}
+	mock.response = []byte{0x04, 0x00, 0x90, 0x00}
+	if _, err := device.PublicKey("m/0"); err != ErrInvalidResponse {
+		t.Fatalf("truncated public key: got %v", err)
+	}
+	mock.response = []byte{0x30, 0x00, 0x90, 0x00}
+	if _, err := device.SignDigest("m/0", make([]byte, 32)); err != ErrInvalidResponse {
+		t.Fatalf("malformed signature: got %v", err)
+	}
+
+	// A host must not trust an address that does not match the public key.
+	other, _ := mock.master.Derive("m/1")
+	mock.address, _ = other.Address()
+	if _, err := device.DisplayAddress("m/0"); err != ErrInvalidResponse {
+		t.Fatalf("substituted address: got %v", err)
+	}
+
+	n := len(mock.apdus)
+	if _, err := device.PublicKey("m/x"); err != keychain.ErrInvalidPath {
+		t.Fatalf("invalid path: got %v", err)
+	}
+	if _, err := device.SignDigest("m/0", make([]byte, 251)); err == nil {
+		t.Fatal("accepted data longer than an APDU")
+	}
+	if len(mock.apdus) != n {
+		t.Fatal("invalid commands were sent to the device")
+	}
+}
+
+// loopbackHID records the reports written to it and answers with the
+// reports of response.
+type loopbackHID struct {
+	written [][]byte
+	replies [][]byte
+}
+
+func (d *loopbackHID) Write(report []byte) (int, error) {
+	d.written = append(d.written, append([]byte(nil), report...))
+	return len(report), nil
+}
+
+func (d *loopbackHID) Read(report []byte) (int, error) {
+	if len(d.replies) == 0 {
+		return 0, errors.New("no reply")
+	}
+	n := copy(report, d.replies[0])
+	d.replies = d.replies[1:]
+	return n, nil
+}
+
+func (d *loopbackHID) Close() error { return nil }
+
+// frame splits data into HID reports as the device does.
+func frame(data []byte) [][]byte {
+	t := &hidTransport{device: &loopbackHID{}}
+	t.write(data)
+	return t.device.(*loopbackHID).written
+}
+
+func TestHIDTransport(t *testing.T) {
+	apdu := make([]byte, 100)
+	for i := range apdu {
+		apdu[i] = byte(i)
+	}
+	response := append(bytes.Repeat([]byte{0x5a}, 120), 0x90, 0x00)
+	hid := &loopbackHID{replies: frame(response)}
+	transport := NewHIDTransport(hid)
+
+	got, err := transport.Exchange(apdu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, response) {
+		t.Fatalf("response %x", got)
+	}
+
+	// 2 + 100 bytes in reports of 59 bytes of payload each.
+	if len(hid.written) != 2 {
+		t.Fatalf("%d reports written, want 2", len(hid.written))
+	}
+	if got := hex.EncodeToString(hid.written[0][:9]); got != "01010500000064"+"0001" {
+		t.Fatalf("first report header %s", got)
+	}
+	if got := hex.EncodeToString(hid.written[1][:5]); got != "0101050001" {
+		t.Fatalf("second report header %s", got)
+	}
+	if rest := hid.written[1][5+100+2-59:]; !bytes.Equal(rest, make([]byte, len(rest))) {
+		t.Fatal("last report not zero-padded")
+	}
+
+	replies := frame(response)
+	replies[1][4] = 2
+	hid.replies = replies
+	if _, err := transport.Exchange(apdu); err != errHIDFrame {
+		t.Fatalf("wrong sequence number: got %v", err)
+	}
+	replies = frame(response)
+	replies[0][0] = 0
+	hid.replies = replies
+	if _, err := transport.Exchange(apdu); err != errHIDFrame {
+		t.Fatalf("wrong channel: got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Package hardwallet drives hardware wallets that hold SM2 keys.
+//
+// A hardware wallet keeps an HD key tree (see gm/hdwallet/keychain) on the
+// device and exposes the public keys of its nodes and a signing operation
+// that the user confirms on the device screen. Device is the interface the
+// rest of the library programs against; APDUDevice is a reference driver
+// for Ledger-like devices that speak ISO 7816-4 APDUs over USB HID.
+package hardwallet
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/xuperchain/crypto/gm/account"
+	"github.com/xuperchain/crypto/gm/hdwallet/keychain"
+)
+
+var (
+	// 用户在设备上拒绝了操作
+	ErrRejected = errors.New("hardwallet: operation rejected on the device")
+	// 设备已被关闭或断开
+	ErrClosed = errors.New("hardwallet: device closed")
+	// 设备的响应不符合协议
+	ErrInvalidResponse = errors.New("hardwallet: invalid response from the device")
+)
+
+// Device is a hardware wallet holding an SM2 HD key tree. Paths have the
+// form accepted by keychain.ParsePath, e.g. "m/44'/0'/0/1".
+type Device interface {
+	// PublicKey returns the public key of the node at path.
+	PublicKey(path string) (*ecdsa.PublicKey, error)
+	// SignDigest signs the digest with the key at path after the user
+	// confirms on the device, and returns the signature in the format of
+	// sign.SignECDSA, so that it verifies with sign.VerifyECDSA.
+	SignDigest(path string, digest []byte) ([]byte, error)
+	// DisplayAddress shows the address of the key at path on the device
+	// and returns it once the user confirms that it matches the one shown
+	// by the host, which guards against a compromised host substituting
+	// its own address.
+	DisplayAddress(path string) (string, error)
+	// Close releases the device.
+	Close() error
+}
+
+// Signer signs with one key of a Device, mirroring the account APIs that
+// take an *ecdsa.PrivateKey.
+type Signer struct {
+	device    Device
+	path      string
+	publicKey *ecdsa.PublicKey
+}
+
+// NewSigner returns a Signer for the key at path on the device.
+func NewSigner(device Device, path string) (*Signer, error) {
+	if _, err := keychain.ParsePath(path); err != nil {
+		return nil, err
+	}
+	publicKey, err := device.PublicKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{device: device, path: path, publicKey: publicKey}, nil
+}
+
+// PublicKey returns the public key of the signer.
+func (s *Signer) PublicKey() *ecdsa.PublicKey {
+	return s.publicKey
+}
+
+// Address returns the address of the signer, as account.GetAddressFromPublicKey.
+func (s *Signer) Address() (string, error) {
+	return account.GetAddressFromPublicKey(s.publicKey)
+}
+
+// Sign signs msg on the device, as sign.SignECDSA does with a private key.
+func (s *Signer) Sign(msg []byte) ([]byte, error) {
+	return s.device.SignDigest(s.path, msg)
+}
@@ -0,0 +1,104 @@
+package hardwallet
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// HID framing of APDUs, as used by Ledger devices. An APDU is split into
+// reports of hidReportSize bytes, each starting with
+//
+//	channel (2) || tag 0x05 (1) || sequence number (2)
+//
+// The first report of an APDU continues with its length (2); the last report
+// is zero-padded. Responses are framed the same way.
+const (
+	hidReportSize = 64
+	hidTagAPDU    = 0x05
+	hidChannel    = 0x0101
+)
+
+// HIDDevice is an open USB HID device, as provided by a platform HID library.
+// Write sends one output report and Read receives one input report, both
+// without a report ID; implementations that need a report ID prefix must add
+// and strip it.
+type HIDDevice interface {
+	Write(report []byte) (int, error)
+	Read(report []byte) (int, error)
+	Close() error
+}
+
+var errHIDFrame = errors.New("hardwallet: invalid HID frame")
+
+type hidTransport struct {
+	device HIDDevice
+}
+
+// NewHIDTransport returns a Transport that exchanges APDUs with the device
+// using Ledger's HID framing.
+func NewHIDTransport(device HIDDevice) Transport {
+	return &hidTransport{device: device}
+}
+
+func (t *hidTransport) Exchange(apdu []byte) ([]byte, error) {
+	if len(apdu) > 0xffff {
+		return nil, errHIDFrame
+	}
+	if err := t.write(apdu); err != nil {
+		return nil, err
+	}
+	return t.read()
+}
+
+func (t *hidTransport) write(apdu []byte) error {
+	data := make([]byte, 2+len(apdu))
+	binary.BigEndian.PutUint16(data, uint16(len(apdu)))
+	copy(data[2:], apdu)
+
+	for seq := uint16(0); len(data) > 0; seq++ {
+		report := make([]byte, hidReportSize)
+		binary.BigEndian.PutUint16(report, hidChannel)
+		report[2] = hidTagAPDU
+		binary.BigEndian.PutUint16(report[3:], seq)
+		n := copy(report[5:], data)
+		data = data[n:]
+		if _, err := t.device.Write(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *hidTransport) read() ([]byte, error) {
+	var resp []byte
+	length := -1
+	report := make([]byte, hidReportSize)
+	for seq := uint16(0); length < 0 || len(resp) < length; seq++ {
+		n, err := t.device.Read(report)
+		if err != nil {
+			return nil, err
+		}
+		if n < 5 || binary.BigEndian.Uint16(report) != hidChannel || report[2] != hidTagAPDU ||
+			binary.BigEndian.Uint16(report[3:]) != seq {
+			return nil, errHIDFrame
+		}
+		payload := report[5:n]
+		if seq == 0 {
+			if len(payload) < 2 {
+				return nil, errHIDFrame
+			}
+			length = int(binary.BigEndian.Uint16(payload))
+			payload = payload[2:]
+			resp = make([]byte, 0, length)
+		}
+		if rest := length - len(resp); len(payload) > rest {
+			payload = payload[:rest]
+		}
+		resp = append(resp, payload...)
+	}
+	return resp, nil
+}
+
+func (t *hidTransport) Close() error {
+	return t.device.Close()
+}
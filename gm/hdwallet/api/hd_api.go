@@ -70,6 +70,27 @@ func GenerateChildKey(key string, i uint32) (string, error) {
 	return string(jsonChildKey), nil
 }
 
+// 通过分层确定性私钥/公钥（如根私钥）按路径推导出子私钥/公钥，例如 "m/44'/0'/0/1"
+func GenerateChildKeyByPath(key, path string) (string, error) {
+	var extendedKey *keychain.ExtendedKey
+	err := json.Unmarshal([]byte(key), &extendedKey)
+	if err != nil {
+		return "", err
+	}
+
+	childKey, err := extendedKey.Derive(path)
+	if err != nil {
+		return "", err
+	}
+
+	jsonChildKey, err := json.Marshal(childKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(jsonChildKey), nil
+}
+
 // 将分层确定性私钥转化为公钥
 func ConvertPrvKeyToPubKey(key string) (string, error) {
 	var extendedKey *keychain.ExtendedKey
@@ -337,6 +337,7 @@ func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
 		// 2. If Public parent key → public child key
 		// It is only defined for non-hardened child keys.
 		// If so hardened child: return failure(ErrDeriveHardenKeyFromPublicKey)
+		isChildHardened = i >= HardenedKeyStart
 		if isChildHardened {
 			return nil, ErrDeriveHardenKeyFromPublicKey
 		}
@@ -403,9 +404,11 @@ func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
 	}
 	parentFP := hash.HashUsingRipemd160(pubKeyBytes)[:4]
 
-	var accountNum map[uint8]uint32 = map[uint8]uint32{}
-	if len(k.AccountNum) != 0 {
-		accountNum = k.AccountNum
+	// Copy the bloodline of the parent so that deriving siblings does not
+	// overwrite each other's child numbers.
+	var accountNum map[uint8]uint32 = make(map[uint8]uint32, len(k.AccountNum)+1)
+	for depth, num := range k.AccountNum {
+		accountNum[depth] = num
 	}
 	accountNum[k.Depth+1] = i
 
@@ -517,10 +520,10 @@ func (k *ExtendedKey) ECPrivateKey() (*ecdsa.PrivateKey, error) {
 func (k *ExtendedKey) Address() (string, error) {
 	pubKey, err := k.ECPublicKey()
 	if err != nil {
-		return account.GetAddressFromPublicKey(pubKey)
+		return "", err
 	}
 
-	return "", err
+	return account.GetAddressFromPublicKey(pubKey)
 }
 
 // Convert an extended key to a base58-encoded String.
@@ -560,6 +563,12 @@ func NewKeyFromString(key string) (*ExtendedKey, error) {
 	// The base58-decoded extended key consists of a serialized payload plus an additional 4 bytes checksum.
 	decoded := base58.Decode(key)
 
+	// version (4) || depth (1) || parent fingerprint (4) || child num (4) ||
+	// chain code (32) || cryptography (1) || key data (at least 1) || checksum (4)
+	if len(decoded) < 51 {
+		return nil, ErrWrongChecksum
+	}
+
 	// The serialized format is:
 	//   version (4) || depth (1) || parent fingerprint (4)) ||
 	//   child num (4) || chain code (32) || key data (33) || checksum (4)
@@ -653,6 +662,9 @@ func parsePubKey(pubKeyStr []byte) (key *ecdsa.PublicKey, err error) {
 		pubkey.Curve = curve
 		pubkey.X = new(big.Int).SetBytes(pubKeyStr[2:34])
 		pubkey.Y = new(big.Int).SetBytes(pubKeyStr[34:])
+		if !curve.IsOnCurve(pubkey.X, pubkey.Y) {
+			return nil, ErrWrongPubKeyStr
+		}
 
 		return &pubkey, nil
 	}
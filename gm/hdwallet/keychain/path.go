@@ -0,0 +1,74 @@
+package keychain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// The path is not a valid BIP32 derivation path.
+var ErrInvalidPath = errors.New("invalid derivation path")
+
+// ParsePath parses a BIP32 derivation path such as "m/44'/0'/0/1" into child
+// indexes. A trailing ' (or h, H) marks a hardened index, which is offset by
+// HardenedKeyStart. The leading "m" is optional and the path "m" is empty.
+func ParsePath(path string) ([]uint32, error) {
+	segments := strings.Split(path, "/")
+	if segments[0] == "m" {
+		segments = segments[1:]
+	}
+
+	indexes := make([]uint32, 0, len(segments))
+	for _, segment := range segments {
+		hardened := false
+		if n := len(segment); n > 0 {
+			switch segment[n-1] {
+			case '\'', 'h', 'H':
+				hardened = true
+				segment = segment[:n-1]
+			}
+		}
+
+		// Only plain decimal numbers are allowed, i.e. no sign and no spaces.
+		if segment == "" || segment[0] < '0' || segment[0] > '9' {
+			return nil, ErrInvalidPath
+		}
+		index, err := strconv.ParseUint(segment, 10, 32)
+		if err != nil || index >= HardenedKeyStart {
+			return nil, ErrInvalidPath
+		}
+		if hardened {
+			index += HardenedKeyStart
+		}
+		indexes = append(indexes, uint32(index))
+	}
+
+	if len(indexes) > MaxUint8 {
+		return nil, ErrDeriveBeyondMaxDepth
+	}
+
+	return indexes, nil
+}
+
+// Derive returns the descendant of this extended key at the given path,
+// relative to this key, e.g. k.Derive("m/44'/0'/0/1") on a master key.
+//
+// A public extended key can only derive non-hardened descendants. If an index
+// on the path is invalid, ErrInvalidIndexForGenerateChildKey is returned and
+// the caller should move on to the next index at that level.
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, i := range indexes {
+		key, err = key.Child(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
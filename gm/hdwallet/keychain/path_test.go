@@ -0,0 +1,120 @@
+package keychain
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/config"
+)
+
+func testMaster(t *testing.T) *ExtendedKey {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMaster(seed, config.Gm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return master
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []uint32
+	}{
+		{"m", []uint32{}},
+		{"", nil},
+		{"m/0", []uint32{0}},
+		{"m/44'/0h/1H/2", []uint32{HardenedKeyStart + 44, HardenedKeyStart, HardenedKeyStart + 1, 2}},
+		{"0/1", []uint32{0, 1}},
+		{"m/2147483647'", []uint32{HardenedKeyStart + HardenedKeyStart - 1}},
+		{"m/2147483648", nil},
+		{"m/-1", nil},
+		{"m/+1", nil},
+		{"m/ 1", nil},
+		{"m//1", nil},
+		{"m/1''", nil},
+		{"m/x", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParsePath(tt.path)
+		if tt.want == nil {
+			if err != ErrInvalidPath {
+				t.Errorf("ParsePath(%q) = %v, %v, want ErrInvalidPath", tt.path, got, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePath(%q) = %v, %v, want %v", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+// The vectors follow BIP32 with HMAC-SHA512 under MasterKey and SM2 keys,
+// computed with an independent implementation.
+func TestDerive(t *testing.T) {
+	master := testMaster(t)
+	if got := hex.EncodeToString(master.Key); got != "a59e2d75427b6ef6c57c6a3a9e544651e6b4374d9ac9685a558a9e61ec43bc03" {
+		t.Fatalf("master key = %s", got)
+	}
+	if got := master.ToString(); got != "4sLFKUxAKk7ctfdkU2w28v2uc14fg5C6GQMxg27A7kgbrqwhr7B9zCia3MUzbJTGLK8p1tVHVGysP2iA6wXFiJVdrKPHciTHxLXe23TbZwqV56wie" {
+		t.Fatalf("master ToString = %s", got)
+	}
+
+	vectors := []struct {
+		path, key, chainCode, parentFP string
+	}{
+		{"m/0'", "f9ecc878b1798d6f556e181b5ab66fd47eaf8fadbd6871c9ad416a22c62ac0b8", "6f8141fd75855730c0c5dec85a63811c4e2dbc5ea013163d56d5e2efc4de51ab", "d084fd64"},
+		{"m/0'/1", "300eb8b3f162d2c9aec66aa9e514f480de7b3de30f4ff863a9c5ecbb56db8b4b", "1ce94d3db1b6bdadf433c1db1cd1c6f913d624c55765c3a5bb90d450c7070184", "49a50055"},
+		{"m/0'/1/2'", "50472f4d6e80705f06f85bc0e944555f88b84e15c264966a16416665778cf071", "570038f17e00ef693156f2122f0433312a50e7d27412d73e191da40608c70747", "ec7d29f7"},
+		{"m/0'/1/2'/2", "59df638a7560c645e892e4a6cb5696c0e09f698dd0b0e546942045b602346a29", "a719bb6b6c4079b01fa9a4b70f37d5bc95792b4d91ece07067cc9578b50fcaa1", "828fb866"},
+	}
+	for i, v := range vectors {
+		k, err := master.Derive(v.path)
+		if err != nil {
+			t.Fatalf("%s: %v", v.path, err)
+		}
+		if hex.EncodeToString(k.Key) != v.key || hex.EncodeToString(k.ChainCode) != v.chainCode ||
+			hex.EncodeToString(k.ParentFP) != v.parentFP {
+			t.Errorf("%s: got key %x, chain code %x, parent %x", v.path, k.Key, k.ChainCode, k.ParentFP)
+		}
+		if k.Depth != uint8(i+1) {
+			t.Errorf("%s: depth %d", v.path, k.Depth)
+		}
+	}
+}
+
+func TestDerivePublic(t *testing.T) {
+	account, err := testMaster(t).Derive("m/0'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := account.Neuter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wantKey = "04026d514936450d015e8294c19355c2553102dbf637cfb39216a4e5ec95f752be7401be339f98b7733fabb833a63b444d50c16d1298e216c09a3ff0678e74e7f327"
+	const wantChainCode = "209d1d8f8985bea5cc5a8fa02521880e663fffb71469b16aad30928762ff4239"
+	child, err := pub.Derive("1/5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.IsPrivate || hex.EncodeToString(child.Key) != wantKey || hex.EncodeToString(child.ChainCode) != wantChainCode {
+		t.Fatalf("got key %x, chain code %x", child.Key, child.ChainCode)
+	}
+
+	// The public child is the neutered private child.
+	priv, err := account.Derive("1/5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	neutered, _ := priv.Neuter()
+	if !reflect.DeepEqual(neutered.Key, child.Key) || !reflect.DeepEqual(neutered.ChainCode, child.ChainCode) {
+		t.Fatal("public derivation differs from the neutered private derivation")
+	}
+
+	if _, err := pub.Derive("1/2'"); err != ErrDeriveHardenKeyFromPublicKey {
+		t.Fatalf("hardened child of a public key: got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package keychain
+
+import (
+	"errors"
+
+	"github.com/xuperchain/crypto/gm/sign"
+)
+
+// A private extended key was given where only a public extended key is accepted.
+var ErrNotPubExtKey = errors.New("expected a public extended key, got a private extended key")
+
+// NewWatchOnlyKey retrieves a public extended key from a base58-encoded string,
+// e.g. the output of ToString on an account key returned by Neuter.
+//
+// A watch-only key holds no private material: it can derive the non-hardened
+// child public keys and addresses of its subtree and verify their signatures,
+// but it cannot sign. Private extended keys are rejected so that a watch-only
+// service never stores spending keys by mistake.
+func NewWatchOnlyKey(key string) (*ExtendedKey, error) {
+	extendedKey, err := NewKeyFromString(key)
+	if err != nil {
+		return nil, err
+	}
+	if extendedKey.IsPrivate {
+		return nil, ErrNotPubExtKey
+	}
+
+	return extendedKey, nil
+}
+
+// ChildAddresses returns the addresses of the count non-hardened children of
+// this extended key starting at index start, e.g. the deposit addresses of an
+// account to be monitored. The address at position i belongs to child
+// start+i; it is empty for the rare index that does not yield a valid child,
+// which wallets skip as well.
+func (k *ExtendedKey) ChildAddresses(start, count uint32) ([]string, error) {
+	if start >= HardenedKeyStart || count > HardenedKeyStart-start {
+		return nil, ErrDeriveHardenKeyFromPublicKey
+	}
+
+	addresses := make([]string, count)
+	for i := uint32(0); i < count; i++ {
+		child, err := k.Child(start + i)
+		if err == ErrInvalidIndexForGenerateChildKey {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		addresses[i], err = child.Address()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return addresses, nil
+}
+
+// Verify reports whether sig is a valid signature of msg by the key of this
+// extended key, as produced by sign.SignECDSA with the corresponding private key.
+func (k *ExtendedKey) Verify(msg, sig []byte) (bool, error) {
+	pubKey, err := k.ECPublicKey()
+	if err != nil {
+		return false, err
+	}
+
+	return sign.VerifyECDSA(pubKey, sig, msg)
+}
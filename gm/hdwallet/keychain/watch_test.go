@@ -0,0 +1,54 @@
+package keychain
+
+import (
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/sign"
+)
+
+func TestWatchOnlyKey(t *testing.T) {
+	account, err := testMaster(t).Derive("m/44'/0'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := account.Neuter()
+
+	watch, err := NewWatchOnlyKey(pub.ToString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWatchOnlyKey(account.ToString()); err != ErrNotPubExtKey {
+		t.Fatalf("private key: got %v, want ErrNotPubExtKey", err)
+	}
+
+	addresses, err := watch.ChildAddresses(3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, addr := range addresses {
+		child, err := account.Child(3 + uint32(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := child.Address()
+		if addr != want {
+			t.Errorf("address %d: got %s, want %s", i, addr, want)
+		}
+	}
+	if _, err := watch.ChildAddresses(HardenedKeyStart-1, 2); err != ErrDeriveHardenKeyFromPublicKey {
+		t.Fatalf("range into hardened children: got %v", err)
+	}
+
+	priv, _ := account.ECPrivateKey()
+	msg := []byte("watch-only")
+	sig, err := sign.SignECDSA(priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := watch.Verify(msg, sig); !ok || err != nil {
+		t.Fatalf("signature rejected: %v", err)
+	}
+	if ok, _ := watch.Verify([]byte("other"), sig); ok {
+		t.Fatal("signature of another message accepted")
+	}
+}
@@ -114,6 +114,11 @@ func getWordListByLanguage(language int) ([]string, error) {
 	return wordList, nil
 }
 
+// 根据指定的语言类型来选择助记词list，供其他包使用
+func GetWordList(language int) ([]string, error) {
+	return getWordListByLanguage(language)
+}
+
 // 根据指定的语言类型来选择反向助记词Map
 func getReversedWordMapByLanguage(language int) (map[string]int, error) {
 	var reversedWordMap map[string]int = map[string]int{}
@@ -0,0 +1,185 @@
+// Package recovery regenerates an HD account tree from its mnemonic and
+// checks it against what the wallet recorded before, for guided recovery.
+//
+// When a wallet is created it stores a key-check value of the master key,
+// KeyCheckValue, which is safe to keep next to the backup since it reveals
+// nothing but a 4-byte SM3 fingerprint of the master public key and chain
+// code. It may also be written down as a single KeyCheckWord. Recovery then
+// tells a wrong mnemonic or passphrase (ErrKeyCheckMismatch) apart from a
+// right master key whose derived accounts differ from the recorded ones, e.g.
+// because they were created under another derivation path (Report.Mismatches).
+package recovery
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/xuperchain/crypto/gm/account"
+	"github.com/xuperchain/crypto/gm/config"
+	"github.com/xuperchain/crypto/gm/hash"
+	"github.com/xuperchain/crypto/gm/hdwallet/keychain"
+
+	walletRand "github.com/xuperchain/crypto/gm/hdwallet/rand"
+)
+
+// defaultPassphrase is the passphrase used by api.GenerateMasterKeyByMnemonic
+// and account.GenerateAccountByMnemonic, so that trees created by them are
+// recovered with an empty passphrase.
+const defaultPassphrase = "jingbo is handsome!"
+
+// seedLen is the seed length used by api.GenerateMasterKeyByMnemonic.
+const seedLen = 40
+
+// KeyCheckLen is the length of a key-check value.
+const KeyCheckLen = 4
+
+var keyCheckTag = []byte("XuperCrypto key check")
+
+var (
+	// 助记词和口令恢复出的根密钥与保存的校验值不符，通常是口令错误或者助记词有误
+	ErrKeyCheckMismatch = errors.New("the recovered master key does not match the key-check value")
+	// 校验值的长度不正确
+	ErrInvalidKeyCheck = errors.New("invalid key-check value")
+)
+
+// NewMasterFromMnemonic regenerates the master key of an HD account tree from
+// its mnemonic and passphrase. An empty passphrase selects the one used by
+// api.GenerateMasterKeyByMnemonic.
+func NewMasterFromMnemonic(mnemonic, passphrase string, language int) (*keychain.ExtendedKey, error) {
+	cryptography, err := account.GetCryptoByteFromMnemonic(mnemonic, language)
+	if err != nil {
+		return nil, err
+	}
+	if cryptography != config.Gm {
+		return nil, keychain.ErrCryptographyNotSupported
+	}
+
+	if passphrase == "" {
+		passphrase = defaultPassphrase
+	}
+	seed, err := walletRand.GenerateSeedWithErrorChecking(mnemonic, passphrase, seedLen, language)
+	if err != nil {
+		return nil, err
+	}
+
+	return keychain.NewMaster(seed, cryptography)
+}
+
+// KeyCheckValue returns the key-check value of a master key: the first
+// KeyCheckLen bytes of SM3(tag || public key || chain code).
+func KeyCheckValue(master *keychain.ExtendedKey) ([]byte, error) {
+	pub, err := master.Neuter()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(keyCheckTag)+len(pub.Key)+len(pub.ChainCode))
+	data = append(data, keyCheckTag...)
+	data = append(data, pub.Key...)
+	data = append(data, pub.ChainCode...)
+	return hash.HashUsingSM3(data)[:KeyCheckLen], nil
+}
+
+// KeyCheckWord returns the word of the mnemonic word list of language given
+// by the first 11 bits of the key-check value, for users to write down next
+// to the mnemonic. It is a weaker check than the full value: a wrong
+// passphrase goes unnoticed with probability 1/2048.
+func KeyCheckWord(master *keychain.ExtendedKey, language int) (string, error) {
+	kcv, err := KeyCheckValue(master)
+	if err != nil {
+		return "", err
+	}
+	wordList, err := walletRand.GetWordList(language)
+	if err != nil {
+		return "", err
+	}
+
+	index := (int(kcv[0])<<8 | int(kcv[1])) >> 5
+	return wordList[index%len(wordList)], nil
+}
+
+// Account is an account recorded by the wallet: the derivation path of its
+// key and its address.
+type Account struct {
+	Path    string
+	Address string
+}
+
+// Mismatch is a recorded account whose path derives another address.
+type Mismatch struct {
+	Account
+	// Derived is the address derived at Path, empty if Path could not be
+	// derived.
+	Derived string
+	// Err is the derivation error, if any.
+	Err error
+}
+
+// Report is the result of a recovery.
+type Report struct {
+	// Master is the recovered master key.
+	Master *keychain.ExtendedKey
+	// Recovered lists the recorded accounts that were derived as recorded.
+	Recovered []Account
+	// Mismatches lists the recorded accounts that were not.
+	Mismatches []Mismatch
+}
+
+// Recover regenerates the HD account tree from mnemonic and passphrase,
+// checks the master key against the stored key-check value and re-derives
+// every recorded account. keyCheck is either a key-check value or, if it is
+// nil, skipped; use CheckWord to verify a key-check word instead.
+//
+// Recover returns ErrKeyCheckMismatch if the master key does not match the
+// key-check value. Accounts that derive to other addresses do not make
+// Recover fail; they are listed in the Mismatches of the report.
+func Recover(mnemonic, passphrase string, language int, keyCheck []byte, accounts []Account) (*Report, error) {
+	if keyCheck != nil && len(keyCheck) != KeyCheckLen {
+		return nil, ErrInvalidKeyCheck
+	}
+
+	master, err := NewMasterFromMnemonic(mnemonic, passphrase, language)
+	if err != nil {
+		return nil, err
+	}
+	if keyCheck != nil {
+		kcv, err := KeyCheckValue(master)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(kcv, keyCheck) {
+			return nil, ErrKeyCheckMismatch
+		}
+	}
+
+	report := &Report{Master: master}
+	for _, a := range accounts {
+		derived, err := deriveAddress(master, a.Path)
+		if err != nil || derived != a.Address {
+			report.Mismatches = append(report.Mismatches, Mismatch{Account: a, Derived: derived, Err: err})
+			continue
+		}
+		report.Recovered = append(report.Recovered, a)
+	}
+
+	return report, nil
+}
+
+// CheckWord reports whether word is the key-check word of master.
+func CheckWord(master *keychain.ExtendedKey, word string, language int) (bool, error) {
+	want, err := KeyCheckWord(master, language)
+	if err != nil {
+		return false, err
+	}
+
+	return want == word, nil
+}
+
+func deriveAddress(master *keychain.ExtendedKey, path string) (string, error) {
+	key, err := master.Derive(path)
+	if err != nil {
+		return "", err
+	}
+
+	return key.Address()
+}
@@ -0,0 +1,138 @@
+package recovery
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	walletRand "github.com/xuperchain/crypto/gm/hdwallet/rand"
+)
+
+// testMnemonic encodes the entropy 000102..0e followed by the tag of Gm
+// cryptography. It and the key-check values below were computed with an
+// independent implementation.
+const testMnemonic = "abandon amount liar amount expire adjust cage candy arch gather drum dose"
+
+const (
+	// The key-check value and word under the default passphrase.
+	testKeyCheck     = "d0bd7db4"
+	testKeyCheckWord = "spawn"
+	// The key-check value under the passphrase "passphrase".
+	testKeyCheckPassphrase = "8caaf016"
+)
+
+func TestMnemonic(t *testing.T) {
+	entropy := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 0x20}
+	mnemonic, err := walletRand.GenerateMnemonic(entropy, walletRand.English)
+	if err != nil || mnemonic != testMnemonic {
+		t.Fatalf("GenerateMnemonic = %q, %v", mnemonic, err)
+	}
+}
+
+func TestKeyCheck(t *testing.T) {
+	tests := []struct {
+		passphrase string
+		want       string
+	}{
+		{"", testKeyCheck},
+		{defaultPassphrase, testKeyCheck},
+		{"passphrase", testKeyCheckPassphrase},
+	}
+	for _, tt := range tests {
+		master, err := NewMasterFromMnemonic(testMnemonic, tt.passphrase, walletRand.English)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kcv, err := KeyCheckValue(master)
+		if err != nil || hex.EncodeToString(kcv) != tt.want {
+			t.Fatalf("passphrase %q: KeyCheckValue = %x, %v, want %s", tt.passphrase, kcv, err, tt.want)
+		}
+	}
+
+	master, _ := NewMasterFromMnemonic(testMnemonic, "", walletRand.English)
+	if word, err := KeyCheckWord(master, walletRand.English); err != nil || word != testKeyCheckWord {
+		t.Fatalf("KeyCheckWord = %q, %v", word, err)
+	}
+	for _, tt := range []struct {
+		word string
+		want bool
+	}{{testKeyCheckWord, true}, {"abandon", false}} {
+		if ok, err := CheckWord(master, tt.word, walletRand.English); err != nil || ok != tt.want {
+			t.Errorf("CheckWord(%q) = %v, %v", tt.word, ok, err)
+		}
+	}
+}
+
+func TestRecover(t *testing.T) {
+	master, _ := NewMasterFromMnemonic(testMnemonic, "", walletRand.English)
+	var accounts []Account
+	for _, path := range []string{"m/44'/0'/0", "m/44'/0'/1"} {
+		key, _ := master.Derive(path)
+		address, _ := key.Address()
+		accounts = append(accounts, Account{Path: path, Address: address})
+	}
+	// An account created under another path than the one recorded.
+	moved := Account{Path: "m/44'/1'/0", Address: accounts[0].Address}
+
+	kcv, _ := hex.DecodeString(testKeyCheck)
+	report, err := Recover(testMnemonic, "", walletRand.English, kcv, append(accounts, moved))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Recovered) != 2 || report.Recovered[1] != accounts[1] {
+		t.Fatalf("recovered %v", report.Recovered)
+	}
+	if len(report.Mismatches) != 1 {
+		t.Fatalf("mismatches %v", report.Mismatches)
+	}
+	m := report.Mismatches[0]
+	if m.Account != moved || m.Err != nil || m.Derived == "" || m.Derived == moved.Address {
+		t.Fatalf("mismatch %+v", m)
+	}
+
+	report, err = Recover(testMnemonic, "", walletRand.English, nil, []Account{{Path: "m/x", Address: "x"}})
+	if err != nil || len(report.Mismatches) != 1 || report.Mismatches[0].Err == nil {
+		t.Fatalf("invalid path: %+v, %v", report, err)
+	}
+	if _, err := Recover(testMnemonic, "", walletRand.English, kcv[:3], nil); err != ErrInvalidKeyCheck {
+		t.Fatalf("short key-check value: got %v", err)
+	}
+}
+
+// replaceWord returns testMnemonic with its word at i replaced by word.
+func replaceWord(i int, word string) string {
+	words := strings.Fields(testMnemonic)
+	words[i] = word
+	return strings.Join(words, " ")
+}
+
+func TestRecoverWrongWord(t *testing.T) {
+	kcv, _ := hex.DecodeString(testKeyCheck)
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      error
+	}{
+		// The mnemonic checksum catches most wrong words.
+		{"bad checksum", replaceWord(5, "ability"), nil},
+		// This one passes the 4-bit checksum, so only the key-check value
+		// catches it.
+		{"good checksum", replaceWord(5, "abandon"), ErrKeyCheckMismatch},
+		{"unknown word", replaceWord(5, "adjustment"), nil},
+		{"missing word", strings.Join(strings.Fields(testMnemonic)[1:], " "), walletRand.ErrMnemonicNumNotValid},
+	}
+	for _, tt := range tests {
+		_, err := Recover(tt.mnemonic, "", walletRand.English, kcv, nil)
+		if err == nil || (tt.err != nil && err != tt.err) || (tt.err == nil && err == ErrKeyCheckMismatch) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	if _, err := Recover(testMnemonic, "passphrase", walletRand.English, kcv, nil); err != ErrKeyCheckMismatch {
+		t.Fatalf("wrong passphrase: got %v", err)
+	}
+	// Without a key-check value the wrong word goes unnoticed.
+	if _, err := Recover(replaceWord(5, "abandon"), "", walletRand.English, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
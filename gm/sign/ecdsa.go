@@ -2,7 +2,6 @@ package sign
 
 import (
 	"crypto/ecdsa"
-	"crypto/rand"
 	"encoding/asn1"
 	"encoding/json"
 	"fmt"
@@ -29,7 +28,7 @@ func SignECDSA(k *ecdsa.PrivateKey, msg []byte) (signature []byte, err error) {
 	key.Y = k.Y
 	key.D = k.D
 
-	signature, err = key.Sign(rand.Reader, msg, nil)
+	signature, err = key.Sign(nil, msg, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to sign the msg [%s]", err)
 	}
@@ -69,7 +68,7 @@ func SignV2ECDSA(k *ecdsa.PrivateKey, msg []byte) (signature []byte, err error)
 	key.Y = k.Y
 	key.D = k.D
 
-	sign, err := key.Sign(rand.Reader, msg, nil)
+	sign, err := key.Sign(nil, msg, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to sign the msg [%s]", err)
 	}
@@ -0,0 +1,162 @@
+// Package signenvelope binds a message to its SM2 signature, signer and
+// signing parameters in one canonical serialization.
+//
+// An Envelope carries the message bytes, the algorithm identifier, the
+// signer's public key, the SM2 user ID and the signature. The signature is
+// computed over the canonical protobuf encoding of all other fields, so none
+// of them can be swapped without invalidating it, and every party hashes
+// exactly the same bytes:
+//
+//	message Envelope {
+//		string algorithm  = 1; // AlgorithmSM2SM3
+//		bytes  public_key = 2; // uncompressed SM2 point, 65 bytes
+//		bytes  uid        = 3; // SM2 user ID, hashed into ZA
+//		bytes  message    = 4;
+//		bytes  signature  = 5; // DER SEQUENCE { r, s }, omitted when signing
+//	}
+//
+// The encoding writes the fields in field number order, omits empty fields
+// as proto3 does and is accepted by any protobuf library. Unmarshal only
+// accepts this canonical form. The JSON form has the same fields in the same
+// order, with bytes in standard base64.
+package signenvelope
+
+import (
+	"crypto/elliptic"
+	"encoding/json"
+	"errors"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// AlgorithmSM2SM3 identifies SM2 signatures over SM3(ZA || M) as in
+// GM/T 0003-2012.
+const AlgorithmSM2SM3 = "SM2-SM3"
+
+// DefaultUID is the SM2 user ID of GM/T 0009-2012, used by Sign when no UID
+// is given. It is sm2.DefaultUID.
+var DefaultUID = sm2.DefaultUID
+
+var (
+	// ErrInvalidSignature is returned by Verify for a signature that does
+	// not match the envelope.
+	ErrInvalidSignature = errors.New("signenvelope: invalid signature")
+	// ErrUnsupportedAlgorithm is returned for an unknown algorithm identifier.
+	ErrUnsupportedAlgorithm = errors.New("signenvelope: unsupported algorithm")
+	// ErrMalformed is returned for an encoding that is not a canonical envelope.
+	ErrMalformed = errors.New("signenvelope: malformed envelope")
+)
+
+// Envelope is a signed message together with everything needed to verify it.
+type Envelope struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey []byte `json:"public_key"`
+	UID       []byte `json:"uid"`
+	Message   []byte `json:"message"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign signs msg with priv under the user ID uid, or DefaultUID if uid is
+// empty, and returns the envelope.
+func Sign(priv *sm2.PrivateKey, uid, msg []byte) (*Envelope, error) {
+	if len(uid) == 0 {
+		uid = DefaultUID
+	}
+	e := &Envelope{
+		Algorithm: AlgorithmSM2SM3,
+		PublicKey: elliptic.Marshal(priv.Curve, priv.X, priv.Y),
+		UID:       append([]byte(nil), uid...),
+		Message:   append([]byte(nil), msg...),
+	}
+	r, s, err := sm2.Sm2Sign(priv, e.SigningBytes(), e.UID)
+	if err != nil {
+		return nil, err
+	}
+	if e.Signature, err = sm2.SignDigitToSignData(r, s); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// SigningBytes returns the bytes the signature is computed over: the
+// canonical protobuf encoding of the envelope without its signature.
+func (e *Envelope) SigningBytes() []byte {
+	return e.marshal(false)
+}
+
+// Verify checks the signature of the envelope against its public key. It
+// does not say anything about who the public key belongs to; callers must
+// check that it is a key they expect.
+func (e *Envelope) Verify() error {
+	if e.Algorithm != AlgorithmSM2SM3 {
+		return ErrUnsupportedAlgorithm
+	}
+	pub, err := e.PublicKeySM2()
+	if err != nil {
+		return err
+	}
+	r, s, err := sm2.SignDataToSignDigit(e.Signature)
+	if err != nil || r.Sign() <= 0 || s.Sign() <= 0 {
+		return ErrInvalidSignature
+	}
+	if !sm2.Sm2Verify(pub, e.SigningBytes(), e.UID, r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// PublicKeySM2 parses the public key of the envelope.
+func (e *Envelope) PublicKeySM2() (*sm2.PublicKey, error) {
+	curve := sm2.P256Sm2()
+	x, y := elliptic.Unmarshal(curve, e.PublicKey)
+	if x == nil {
+		return nil, ErrMalformed
+	}
+	return &sm2.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Marshal returns the canonical protobuf encoding of the envelope.
+func (e *Envelope) Marshal() []byte {
+	return e.marshal(true)
+}
+
+// Unmarshal parses the canonical protobuf encoding of an envelope. It does
+// not verify the signature.
+func Unmarshal(data []byte) (*Envelope, error) {
+	e := new(Envelope)
+	if err := e.unmarshal(data); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// MarshalJSON returns the JSON form of the envelope, with the fields in
+// field number order.
+func (e *Envelope) MarshalJSON() ([]byte, error) {
+	// The alias type drops the methods of Envelope to avoid recursion.
+	type envelope Envelope
+	return json.Marshal((*envelope)(e))
+}
+
+// UnmarshalJSON parses the JSON form of an envelope, rejecting unknown
+// fields. It does not verify the signature.
+func (e *Envelope) UnmarshalJSON(data []byte) error {
+	type envelope Envelope
+	var v envelope
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name := range fields {
+		switch name {
+		case "algorithm", "public_key", "uid", "message", "signature":
+		default:
+			return ErrMalformed
+		}
+	}
+	*e = Envelope(v)
+	return nil
+}
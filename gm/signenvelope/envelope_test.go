@@ -0,0 +1,166 @@
+package signenvelope
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/xuperchain/crypto/gm/gmsm/sm2"
+)
+
+// fixedEnvelope has the base point as its public key and a placeholder
+// signature, so that its encodings are fixed.
+func fixedEnvelope() *Envelope {
+	curve := sm2.P256Sm2()
+	return &Envelope{
+		Algorithm: AlgorithmSM2SM3,
+		PublicKey: elliptic.Marshal(curve, curve.Params().Gx, curve.Params().Gy),
+		UID:       DefaultUID,
+		Message:   []byte("hello"),
+		Signature: []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x02},
+	}
+}
+
+// The encodings of fixedEnvelope, computed with an independent
+// implementation.
+const (
+	fixedSigningBytes = "0a07534d322d534d33" +
+		"12410432c4ae2c1f1981195f9904466a39c9948fe30bbff2660be1715a4589334c74c7bc3736a2f4f6779c59bdcee36b692153d0a9877cc62a474002df32e52139f0a0" +
+		"1a1031323334353637383132333435363738" +
+		"220568656c6c6f"
+	fixedProto = fixedSigningBytes + "2a083006020101020102"
+	fixedJSON  = `{"algorithm":"SM2-SM3","public_key":"BDLEriwfGYEZX5kERmo5yZSP4wu/8mYL4XFaRYkzTHTHvDc2ovT2d5xZvc7ja2khU9Cph3zGKkdAAt8y5SE58KA=","uid":"MTIzNDU2NzgxMjM0NTY3OA==","message":"aGVsbG8=","signature":"MAYCAQECAQI="}`
+)
+
+func TestCanonicalEncoding(t *testing.T) {
+	e := fixedEnvelope()
+	if got := hex.EncodeToString(e.SigningBytes()); got != fixedSigningBytes {
+		t.Fatalf("SigningBytes = %s", got)
+	}
+	if got := hex.EncodeToString(e.Marshal()); got != fixedProto {
+		t.Fatalf("Marshal = %s", got)
+	}
+	j, err := json.Marshal(e)
+	if err != nil || string(j) != fixedJSON {
+		t.Fatalf("MarshalJSON = %s, %v", j, err)
+	}
+
+	data, _ := hex.DecodeString(fixedProto)
+	got, err := Unmarshal(data)
+	if err != nil || !equal(got, e) {
+		t.Fatalf("Unmarshal = %+v, %v", got, err)
+	}
+	got = new(Envelope)
+	if err := json.Unmarshal([]byte(fixedJSON), got); err != nil || !equal(got, e) {
+		t.Fatalf("UnmarshalJSON = %+v, %v", got, err)
+	}
+}
+
+func equal(a, b *Envelope) bool {
+	return a.Algorithm == b.Algorithm && bytes.Equal(a.PublicKey, b.PublicKey) &&
+		bytes.Equal(a.UID, b.UID) && bytes.Equal(a.Message, b.Message) &&
+		bytes.Equal(a.Signature, b.Signature)
+}
+
+func TestRoundTrip(t *testing.T) {
+	priv, err := sm2.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Sign(priv, nil, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(e.UID, DefaultUID) {
+		t.Fatalf("UID = %q", e.UID)
+	}
+
+	fromProto, err := Unmarshal(e.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := new(Envelope)
+	if err := json.Unmarshal(j, fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []*Envelope{fromProto, fromJSON} {
+		if !equal(got, e) {
+			t.Fatalf("round trip changed the envelope: %+v", got)
+		}
+		if err := got.Verify(); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Marshal(), e.Marshal()) {
+			t.Fatal("round trip changed the canonical bytes")
+		}
+	}
+}
+
+// Every field is covered by the signature.
+func TestTamper(t *testing.T) {
+	priv, _ := sm2.GenerateKey()
+	other, _ := sm2.GenerateKey()
+	tests := []struct {
+		name string
+		f    func(e *Envelope)
+		err  error
+	}{
+		{"algorithm", func(e *Envelope) { e.Algorithm = "SM2" }, ErrUnsupportedAlgorithm},
+		{"public key", func(e *Envelope) { e.PublicKey = elliptic.Marshal(other.Curve, other.X, other.Y) }, ErrInvalidSignature},
+		{"invalid public key", func(e *Envelope) { e.PublicKey[64] ^= 1 }, ErrMalformed},
+		{"uid", func(e *Envelope) { e.UID = []byte("alice") }, ErrInvalidSignature},
+		{"message", func(e *Envelope) { e.Message[0] ^= 1 }, ErrInvalidSignature},
+		{"signature", func(e *Envelope) { e.Signature[len(e.Signature)-1] ^= 1 }, ErrInvalidSignature},
+		{"no signature", func(e *Envelope) { e.Signature = nil }, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		e, err := Sign(priv, []byte("bob"), []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.f(e)
+		if err := e.Verify(); err != tt.err {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestUnmarshalNonCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+	}{
+		{"out of order", "220568656c6c6f" + "0a07534d322d534d33"},
+		{"duplicate field", "220568656c6c6f" + "220568656c6c6f"},
+		{"empty field", "0a00" + "220568656c6c6f"},
+		{"non-minimal length", "228500" + "68656c6c6f"},
+		{"non-minimal key", "a200" + "0568656c6c6f"},
+		{"varint wire type", "2005"},
+		{"unknown field", "220568656c6c6f" + "320100"},
+		{"truncated", "220668656c6c6f"},
+		{"truncated key", "a2"},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		if _, err := Unmarshal(data); err != ErrMalformed {
+			t.Errorf("%s: got %v, want ErrMalformed", tt.name, err)
+		}
+	}
+	data, _ := hex.DecodeString(fixedProto)
+	for i := 1; i < len(data); i++ {
+		if e, err := Unmarshal(data[:i]); err == nil && bytes.Equal(e.Signature, fixedEnvelope().Signature) {
+			t.Fatalf("accepted %d bytes of %d", i, len(data))
+		}
+	}
+
+	var e Envelope
+	if err := json.Unmarshal([]byte(`{"algorithm":"SM2-SM3","extra":1}`), &e); err != ErrMalformed {
+		t.Fatalf("unknown JSON field: got %v", err)
+	}
+}
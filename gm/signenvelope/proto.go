@@ -0,0 +1,85 @@
+package signenvelope
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Protobuf field numbers of Envelope.
+const (
+	fieldAlgorithm = 1
+	fieldPublicKey = 2
+	fieldUID       = 3
+	fieldMessage   = 4
+	fieldSignature = 5
+
+	wireBytes = 2
+)
+
+func appendField(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], uint64(field<<3|wireBytes))
+	b = append(b, tmp[:n]...)
+	n = binary.PutUvarint(tmp[:], uint64(len(v)))
+	b = append(b, tmp[:n]...)
+	return append(b, v...)
+}
+
+func (e *Envelope) marshal(withSignature bool) []byte {
+	var b []byte
+	b = appendField(b, fieldAlgorithm, []byte(e.Algorithm))
+	b = appendField(b, fieldPublicKey, e.PublicKey)
+	b = appendField(b, fieldUID, e.UID)
+	b = appendField(b, fieldMessage, e.Message)
+	if withSignature {
+		b = appendField(b, fieldSignature, e.Signature)
+	}
+	return b
+}
+
+// unmarshal parses length-delimited fields in strictly increasing field
+// order, then checks that re-encoding gives back the input, which rules out
+// non-minimal varints and empty fields.
+func (e *Envelope) unmarshal(data []byte) error {
+	*e = Envelope{}
+	last := 0
+	for rest := data; len(rest) > 0; {
+		key, n := binary.Uvarint(rest)
+		if n <= 0 || key&7 != wireBytes {
+			return ErrMalformed
+		}
+		rest = rest[n:]
+		field := key >> 3
+		if field <= uint64(last) || field > fieldSignature {
+			return ErrMalformed
+		}
+		last = int(field)
+
+		l, n := binary.Uvarint(rest)
+		if n <= 0 || l > uint64(len(rest)-n) {
+			return ErrMalformed
+		}
+		v := append([]byte(nil), rest[n:n+int(l)]...)
+		rest = rest[n+int(l):]
+
+		switch field {
+		case fieldAlgorithm:
+			e.Algorithm = string(v)
+		case fieldPublicKey:
+			e.PublicKey = v
+		case fieldUID:
+			e.UID = v
+		case fieldMessage:
+			e.Message = v
+		case fieldSignature:
+			e.Signature = v
+		}
+	}
+	if !bytes.Equal(e.Marshal(), data) {
+		return ErrMalformed
+	}
+	return nil
+}
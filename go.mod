@@ -7,4 +7,5 @@ require (
         github.com/consensys/gnark v0.2.1-alpha
         github.com/consensys/gurvy v0.1.2-0.20200512111154-1662e289e29b
         golang.org/x/crypto v0.0.0-20200728195943-123391ffb6de
+        golang.org/x/sys v0.0.0-20200806125547-5acd03effb82
 )
\ No newline at end of file